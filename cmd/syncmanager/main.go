@@ -2,17 +2,33 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"jarvist/internal/syncmanager/api"
+	"jarvist/internal/syncmanager/components"
+	"jarvist/internal/syncmanager/fleetstatus"
 	"jarvist/internal/syncmanager/interfaces"
+	"jarvist/internal/syncmanager/maintmode"
 	"jarvist/internal/syncmanager/mqtt"
+	"jarvist/internal/syncmanager/network"
+	"jarvist/internal/syncmanager/power"
+	"jarvist/internal/syncmanager/preflight"
+	"jarvist/internal/syncmanager/recovery"
+	"jarvist/internal/syncmanager/scheduler"
 	"jarvist/internal/syncmanager/services/cleanup"
+	"jarvist/internal/syncmanager/services/integrity"
 	logService "jarvist/internal/syncmanager/services/log"
+	"jarvist/internal/syncmanager/services/maintenance"
 	"jarvist/internal/syncmanager/services/message"
 	"jarvist/internal/syncmanager/services/stats"
+	"jarvist/internal/syncmanager/snapshots"
+	"jarvist/internal/syncmanager/startup"
 	syncService "jarvist/internal/syncmanager/sync"
+	"jarvist/internal/syncmanager/tracing"
+	"jarvist/internal/syncmanager/verify"
+	"jarvist/internal/syncmanager/watchdog"
 	"jarvist/pkg/logger"
 	"log"
 	"net/http"
@@ -22,10 +38,13 @@ import (
 	"syscall"
 	"time"
 
+	"jarvist/internal/common/bandwidth"
 	"jarvist/internal/common/buildinfo"
 	baseConfig "jarvist/internal/common/config"
 	"jarvist/internal/common/database"
+	"jarvist/internal/common/redact"
 	"jarvist/internal/syncmanager/config"
+	"jarvist/internal/syncmanager/uptime"
 )
 
 var (
@@ -38,6 +57,11 @@ var (
 	isRestart   = flag.Bool("restart", false, "Restart Windows Service")
 	isStatus    = flag.Bool("status", false, "Get Windows Service status")
 	isDebug     = flag.Bool("debug", false, "Run with debug logging")
+	isVersion   = flag.Bool("version", false, "Print build info as JSON and exit")
+	isPreflight = flag.Bool("preflight", false, "Validate config and environment, print report as JSON and exit")
+	isVerify    = flag.Bool("verify", false, "Run the installation verification in the running service, print report as JSON and exit")
+	installer   = flag.String("installer", "", "With --verify, sign off a passed report as this installer")
+	signOffNote = flag.String("notes", "", "With --verify and --installer, notes stored with the sign-off")
 )
 
 const (
@@ -51,6 +75,18 @@ func main() {
 
 	buildInfoService := buildinfo.NewBuildInfoService()
 
+	// Dipakai oleh aplikasi desktop untuk cek kompatibilitas versi
+	if *isVersion {
+		info, _ := json.Marshal(buildInfoService.LoadBuildInfo())
+		fmt.Println(string(info))
+		return
+	}
+
+	// Direktori dipindahkan sebelum config dimuat dan sebelum log maupun database dibuka
+	if *isService {
+		baseConfig.RunPendingRelocations()
+	}
+
 	baseConfig, err := baseConfig.LoadConfig(buildMode, buildInfoService)
 	if err != nil {
 		log.Fatal("Failed to load config: ", err)
@@ -58,6 +94,20 @@ func main() {
 
 	appConfig := config.LoadConfig(buildMode, baseConfig)
 
+	if *isPreflight {
+		report := preflight.Run(appConfig)
+		output, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(output))
+		if !report.Passed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *isVerify {
+		os.Exit(runVerification(baseConfig, appConfig, *installer, *signOffNote))
+	}
+
 	// Create root context
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -67,6 +117,8 @@ func main() {
 	logOptions.LogDir = baseConfig.LogDir
 	logOptions.EnableMQTT = appConfig.Logger.EnableMQTTLogs
 	logOptions.EnableDatabase = appConfig.Logger.EnableDBLogs
+	logOptions.DbMaxRows = appConfig.Logger.DbMaxRows
+	logOptions.DbMaxSizeMB = appConfig.Logger.DbMaxSizeMB
 	logOptions.MQTTTopic = appConfig.MQTT.Topic + "/logs"
 
 	if *isDebug {
@@ -83,6 +135,8 @@ func main() {
 
 	appLogger := logger.New(logOptions)
 	appLogger.SetDB(database.GetDB())
+	stopReopen := appLogger.ReopenOnSignal()
+	defer stopReopen()
 	mainLogger := appLogger.WithComponent("syncmanager")
 
 	mainLogger.Info("Starting Jarvist Sync Manager v%s in %s mode",
@@ -97,73 +151,17 @@ func main() {
 		return
 	}
 
-	// Create database connection
-	mainLogger.Info("Initializing database...")
-	err = database.SetupDatabase(baseConfig, appLogger.WithComponent("database"))
-	if err != nil {
-		mainLogger.Fatal("Failed to create database: %v", err)
-	}
-
-	// Run database migrations
-	mainLogger.Info("Running database migrations...")
-	if err := database.RunMigrations(appLogger.WithComponent("database")); err != nil {
-		mainLogger.Fatal("Failed to run migrations: %v", err)
-	}
-
-	// Create services and components
-	mainLogger.Info("Initializing services...")
-	db := database.GetDB()
-	messageService := message.NewMessageService(db, appLogger)
-	logSvc := logService.NewLogService(db, baseConfig, appLogger, baseConfig.LogDir, 10)
-	statsService := stats.NewStatsService(db, logSvc)
-
-	// Create MQTT sender without starting it
-	mainLogger.Info("Creating MQTT sender...")
-	mqttSender, err := mqtt.NewSender(ctx, appConfig, db, messageService, statsService, appLogger)
-	if err != nil {
-		mainLogger.Fatal("Failed to create MQTT sender: %v", err)
-	}
-
-	mqttAdapter := mqtt.NewLoggerAdapter(mqttSender)
-	appLogger.SetMQTTPublisher(mqttAdapter)
-
-	// Initialize synchronizer
-	mainLogger.Info("Creating synchronizer...")
-	synchronizer := syncService.NewSynchronizer(appConfig, appLogger, db, mqttSender)
-
-	// Create cleanup service
-	mainLogger.Info("Creating cleanup service...")
-	cleanupConfig := cleanup.DefaultConfig()
-	cleanupConfig.DataDirectory = baseConfig.ServicesDataDir
-	cleanupService := cleanup.NewCleanupService(db, appLogger, logSvc, cleanupConfig)
-
-	// Initialize API server
-	mainLogger.Info("Creating API server...")
-	apiServer := api.NewServer(
-		appConfig,
-		appLogger,
-		synchronizer,
-		mqttSender,
-		messageService,
-		statsService,
-		logSvc,
-		cleanupService,
-	)
-
-	// Set up signal handling
-	setupSignalHandling(ctx, cancel, mainLogger)
-
-	// Prepare service components
-	components := []interfaces.ServiceComponent{
-		mqttSender,
-		synchronizer,
-		cleanupService,
+	// Preflight, database and components wait for the startup conditions, as a service this
+	// happens while the service is start pending
+	prepare := func() ([]interfaces.ServiceComponent, interfaces.ServerController) {
+		waitForStartup(ctx, appConfig, appLogger, mainLogger)
+		return setupService(ctx, cancel, baseConfig, appConfig, appLogger, mainLogger)
 	}
 
 	// Run as service or interactively
 	if *isService {
 		mainLogger.Info("Running as Windows service")
-		if err := Run(components, apiServer, appLogger); err != nil {
+		if err := Run(prepare, appLogger); err != nil {
 			mainLogger.Fatal("Service error: %v", err)
 		}
 		return
@@ -171,6 +169,7 @@ func main() {
 
 	// If we're running interactively, start all components manually
 	mainLogger.Info("Running in interactive mode")
+	components, apiServer := prepare()
 
 	// Start components in sequence with proper error handling
 	for i, component := range components {
@@ -185,8 +184,10 @@ func main() {
 		if err := component.Start(); err != nil {
 			mainLogger.Fatal("Failed to start component %s: %v", componentName, err)
 		}
+		uptime.MarkComponentStarted(componentName)
 		mainLogger.Info("Component started successfully: %s", componentName)
 	}
+	uptime.MarkServiceStarted()
 
 	// Start API server in its own goroutine
 	apiServerStarted := make(chan struct{})
@@ -269,6 +270,11 @@ func main() {
 		}
 	}
 
+	// Persist bandwidth counters before closing the database
+	if err := bandwidth.Flush(); err != nil {
+		mainLogger.Warning("Failed to flush bandwidth usage: %v", err)
+	}
+
 	// Close database connection
 	mainLogger.Info("Closing database connection...")
 	database.CloseDatabase()
@@ -276,6 +282,218 @@ func main() {
 	mainLogger.Info("Shutdown complete")
 }
 
+// waitForStartup holds back the components until the startup conditions are met. A broken
+// conditions file is ignored so it cannot keep the service from starting. The broker of the
+// config is used, the sync settings overriding it are in the database that is opened later.
+func waitForStartup(ctx context.Context, appConfig *config.Config, appLogger *logger.Logger, mainLogger *logger.ContextLogger) {
+	conditions, err := baseConfig.LoadStartupConditions()
+	if err != nil {
+		mainLogger.Warning("Ignoring startup conditions: %v", err)
+		conditions = baseConfig.DefaultStartupConditions()
+	}
+	startup.Wait(ctx, conditions, appConfig.MQTT.Broker, appLogger)
+}
+
+// setupService runs the preflight checks, opens the database and creates the components and
+// the API server, in the order they start
+func setupService(ctx context.Context, cancel context.CancelFunc, baseConfig *baseConfig.Config, appConfig *config.Config,
+	appLogger *logger.Logger, mainLogger *logger.ContextLogger) ([]interfaces.ServiceComponent, interfaces.ServerController) {
+	// Run preflight checks before touching the database or network
+	mainLogger.Info("Running preflight checks...")
+	report := preflight.Run(appConfig)
+	for _, check := range report.Checks {
+		if check.Status == preflight.StatusWarn {
+			mainLogger.Warn("Preflight %s: %s (%s)", check.Name, check.Message, check.Hint)
+		}
+	}
+	if failures := report.Failures(); len(failures) > 0 {
+		for _, check := range failures {
+			mainLogger.Error("Preflight %s failed: %s. %s", check.Name, check.Message, check.Hint)
+		}
+		mainLogger.Fatal("Preflight checks failed, run with --preflight for the full report")
+	}
+
+	// Create database connection
+	mainLogger.Info("Initializing database...")
+	err := database.SetupDatabase(baseConfig, appLogger.WithComponent("database"))
+	if err != nil {
+		mainLogger.Fatal("Failed to create database: %v", err)
+	}
+
+	// Run database migrations
+	mainLogger.Info("Running database migrations...")
+	if err := database.RunMigrations(appLogger.WithComponent("database")); err != nil {
+		mainLogger.Fatal("Failed to run migrations: %v", err)
+	}
+
+	// Create services and components
+	mainLogger.Info("Initializing services...")
+	db := database.GetDB()
+
+	// Sync settings changed from the desktop app override the built-in config
+	syncSettings := config.LoadSyncSettings(db)
+	appConfig.ApplySyncSettings(syncSettings)
+
+	// Redaction rules apply before any payload or log leaves the device
+	if err := redact.Load(appConfig.Redaction, db); err != nil {
+		mainLogger.Warning("Failed to load redaction rules, using the default rules: %v", err)
+	}
+
+	messageService := message.NewMessageService(db, appLogger)
+	logSvc := logService.NewLogService(db, baseConfig, appLogger, baseConfig.LogDir, 10)
+	statsService := stats.NewStatsService(db, logSvc)
+
+	// Optional tracing of the pipeline and the API, off until enabled in the settings
+	tracer := tracing.New(appConfig, db, appLogger)
+
+	// Create MQTT sender without starting it
+	mainLogger.Info("Creating MQTT sender...")
+	mqttSender, err := mqtt.NewSender(ctx, appConfig, db, messageService, statsService, appLogger)
+	if err != nil {
+		mainLogger.Fatal("Failed to create MQTT sender: %v", err)
+	}
+
+	// Create connectivity monitor
+	networkMonitor := network.NewMonitor(appConfig, appLogger)
+	mqttSender.SetNetworkMonitor(networkMonitor)
+
+	// Create power monitor, workers pause on sleep and reconnect and rescan on resume
+	powerMonitor := power.NewMonitor(appLogger)
+
+	// Maintenance mode pauses publishing and alerts, switched from the API or an MQTT command
+	maintenanceMode := maintmode.New(appConfig, db, appLogger)
+	maintenanceMode.Subscribe(mqttSender.SetMaintenance)
+	mqttSender.HandleCommand(maintmode.CommandName, maintenanceMode.HandleCommand)
+
+	// Last known camera frames, attached to the camera offline alerts
+	snapshotStore := snapshots.New(db, appLogger)
+
+	mqttAdapter := mqtt.NewLoggerAdapter(mqttSender)
+	appLogger.SetMQTTPublisher(mqttAdapter)
+
+	// Initialize synchronizer
+	mainLogger.Info("Creating synchronizer...")
+	synchronizer := syncService.NewSynchronizer(appConfig, appLogger, db, mqttSender)
+
+	// Recovery report after an unclean shutdown, completed once the journal is recovered
+	recoveryReporter := recovery.New(appConfig, db, mqttSender, appLogger)
+	synchronizer.SetRecoveryListener(recoveryReporter.JournalRecovered)
+
+	// Create cleanup service
+	mainLogger.Info("Creating cleanup service...")
+	cleanupConfig := cleanup.DefaultConfig()
+	cleanupConfig.DataDirectory = baseConfig.ServicesDataDir
+	cleanupConfig.ApplyRetention(syncSettings)
+	cleanupService := cleanup.NewCleanupService(db, appLogger, logSvc, cleanupConfig)
+
+	// Create integrity scanner
+	mainLogger.Info("Creating integrity scanner...")
+	integrityConfig := integrity.DefaultConfig()
+	integrityConfig.DataDirectory = baseConfig.ServicesDataDir
+	integrityConfig.FernetKey = appConfig.Advanced.FernetKey
+	integrityService := integrity.NewIntegrityService(db, appLogger, integrityConfig)
+
+	// Periodic and on-demand maintenance jobs of the services run on the shared scheduler
+	maintenanceService := maintenance.NewMaintenanceService(db, appLogger, maintenance.DefaultConfig())
+	jobScheduler := scheduler.New(appLogger)
+	for _, registrar := range []interface {
+		RegisterJobs(*scheduler.Scheduler) error
+	}{cleanupService, integrityService, mqttSender, synchronizer, maintenanceService} {
+		if err := registrar.RegisterJobs(jobScheduler); err != nil {
+			mainLogger.Fatal("Failed to register jobs: %v", err)
+		}
+	}
+
+	// Components that can be restarted from the API
+	componentRegistry := components.NewRegistry(appLogger)
+	componentRegistry.Register("synchronizer", synchronizer)
+	componentRegistry.Register("mqtt_sender", mqttSender)
+	componentRegistry.Register("cleanup", cleanupService)
+
+	// Watchdog of the OS service manager, notified while the components are healthy
+	serviceWatchdog := watchdog.New(watchdog.Config{
+		Interval:       time.Duration(appConfig.Service.WatchdogIntervalSec) * time.Second,
+		FailureTimeout: time.Duration(appConfig.Service.WatchdogFailureSec) * time.Second,
+	}, appLogger, watchdog.ChecksFor([]interfaces.ServiceComponent{mqttSender, synchronizer, cleanupService, jobScheduler}))
+
+	powerMonitor.Subscribe(serviceWatchdog)
+	powerMonitor.Subscribe(networkMonitor)
+	powerMonitor.Subscribe(mqttSender)
+	powerMonitor.Subscribe(synchronizer)
+
+	// Data gaps during maintenance or sleep are expected, they are not alerted or backfilled
+	synchronizer.SetGapExplainer(func(from, to time.Time) string {
+		if len(maintenanceMode.WindowsBetween(from, to)) > 0 {
+			return "maintenance"
+		}
+		for _, window := range powerMonitor.GetHistory() {
+			if window.SuspendedAt.Before(to) && window.ResumedAt.After(from) {
+				return "sleep"
+			}
+		}
+		return ""
+	})
+
+	// Status document the fleet dashboard builds device liveness from
+	statusPublisher := fleetstatus.New(appConfig, db, mqttSender, networkMonitor, serviceWatchdog, appLogger)
+
+	// Installation verification, run by the installer from the desktop app or --verify
+	verifier := verify.New(appConfig, db, synchronizer, mqttSender, componentRegistry, maintenanceMode, appLogger)
+
+	// Initialize API server
+	mainLogger.Info("Creating API server...")
+	apiServer := api.NewServer(
+		appConfig,
+		appLogger,
+		synchronizer,
+		mqttSender,
+		messageService,
+		statsService,
+		logSvc,
+		cleanupService,
+		networkMonitor,
+		integrityService,
+		componentRegistry,
+		powerMonitor,
+		serviceWatchdog,
+		jobScheduler,
+		maintenanceMode,
+		snapshotStore,
+		verifier,
+		tracer,
+		statusPublisher,
+		recoveryReporter,
+	)
+
+	// Set up signal handling
+	setupSignalHandling(ctx, cancel, mainLogger)
+
+	// Prepare service components
+	components := []interfaces.ServiceComponent{
+		// Started first and stopped last, so the spans of the other components are exported
+		tracer,
+		// Inspects the state of a crashed run before the sender and synchronizer change it, and
+		// removes the run marker only after they stopped
+		recoveryReporter,
+		powerMonitor,
+		// Started before the sender so nothing is published during a saved maintenance window
+		maintenanceMode,
+		networkMonitor,
+		mqttSender,
+		synchronizer,
+		cleanupService,
+		integrityService,
+		// Jobs run on start, so the scheduler starts after the services
+		jobScheduler,
+		statusPublisher,
+	}
+
+	// The watchdog starts last and stops first, so a slow start or stop is not a hang
+	components = append(components, serviceWatchdog)
+
+	return components, apiServer
+}
+
 // handleServiceCommands handles the service-related command line flags.
 // Returns true if a service command was handled, false otherwise.
 func handleServiceCommands(logger *logger.ContextLogger) bool {
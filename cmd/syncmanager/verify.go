@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	baseConfig "jarvist/internal/common/config"
+	"jarvist/internal/syncmanager/config"
+	"jarvist/internal/syncmanager/verify"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// verifyTimeout is longer than the run timeout of the service so its report comes back
+const verifyTimeout = 6 * time.Minute
+
+// runVerification asks the running service to run the installation verification, prints
+// the report as JSON and signs it off when an installer is given and it passed. Returns the
+// exit code, 1 when the verification failed or the service is not reachable.
+func runVerification(base *baseConfig.Config, appConfig *config.Config, installer, notes string) int {
+	var report verify.Report
+	if err := verifyRequest(base, appConfig, "/verify", nil, &report); err != nil {
+		fmt.Fprintf(os.Stderr, "Installation verification failed to run: %v\n", err)
+		return 1
+	}
+
+	if report.Passed && installer != "" {
+		request := map[string]string{"installer": installer, "notes": notes}
+		if err := verifyRequest(base, appConfig, "/verify/signoff", request, &report); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to sign off the report: %v\n", err)
+		}
+	}
+
+	output, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(output))
+
+	if !report.Passed {
+		return 1
+	}
+	return 0
+}
+
+// verifyRequest posts to the API of the running service
+func verifyRequest(base *baseConfig.Config, appConfig *config.Config, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(base.SyncApiURL(), "/")+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if appConfig.API.Username != "" {
+		req.SetBasicAuth(appConfig.API.Username, appConfig.API.Password)
+	}
+
+	client := &http.Client{Timeout: verifyTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sync service not reachable, is it running? %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("%s", apiErr.Error)
+		}
+		return fmt.Errorf("sync service returned %s", resp.Status)
+	}
+
+	return json.Unmarshal(data, out)
+}
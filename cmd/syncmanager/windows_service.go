@@ -2,11 +2,13 @@ package main
 
 import (
 	"fmt"
+	"jarvist/internal/common/bandwidth"
 	"jarvist/internal/common/buildinfo"
 	baseConfig "jarvist/internal/common/config"
 	"jarvist/internal/common/database"
 	"jarvist/internal/syncmanager/config"
 	"jarvist/internal/syncmanager/interfaces"
+	"jarvist/internal/syncmanager/uptime"
 	"jarvist/pkg/logger"
 	"os"
 	"os/exec"
@@ -18,6 +20,7 @@ import (
 	"time"
 
 	"github.com/kardianos/service"
+	"golang.org/x/sys/windows/svc/mgr"
 )
 
 const (
@@ -67,6 +70,7 @@ func GetServiceDescription() string {
 
 // Program implements service.Program interface for kardianos/service
 type Program struct {
+	prepare    func() ([]interfaces.ServiceComponent, interfaces.ServerController)
 	components []interfaces.ServiceComponent
 	apiServer  interfaces.ServerController
 	logger     *logger.Logger
@@ -83,6 +87,11 @@ func (p *Program) Start(s service.Service) error {
 	p.stopChan = make(chan struct{})
 	p.apiRunning = false
 
+	// Waits for the startup conditions, the service stays start pending meanwhile
+	if p.components == nil && p.prepare != nil {
+		p.components, p.apiServer = p.prepare()
+	}
+
 	// Load configuration
 	buildInfoService := buildinfo.NewBuildInfoService()
 	baseConfig, err := baseConfig.LoadConfig(buildMode, buildInfoService)
@@ -118,8 +127,10 @@ func (p *Program) Start(s service.Service) error {
 			p.logger.Error("service", "Failed to start component %s: %v", componentName, err)
 			return err
 		}
+		uptime.MarkComponentStarted(componentName)
 		p.logger.Info("service", "Component started successfully: %s", componentName)
 	}
+	uptime.MarkServiceStarted()
 
 	// Start API server if available
 	if p.apiServer != nil {
@@ -161,6 +172,11 @@ func (p *Program) Start(s service.Service) error {
 		}
 	}
 
+	// Service yang di-install versi lama belum punya recovery actions
+	if err := setRecoveryActions(GetServiceName()); err != nil {
+		p.logger.Warning("service", "Failed to set service recovery actions: %v", err)
+	}
+
 	p.logger.Info("service", "Service started successfully")
 
 	// Run forever
@@ -221,6 +237,9 @@ func (p *Program) Stop(s service.Service) error {
 	}
 
 	// Third step: Close database connection
+	if err := bandwidth.Flush(); err != nil {
+		p.logger.Warning("service", "Failed to flush bandwidth usage: %v", err)
+	}
 	p.logger.Info("service", "Closing database connection")
 	database.CloseDatabase()
 	p.logger.Info("service", "Database connection closed")
@@ -247,11 +266,10 @@ func (p *Program) Stop(s service.Service) error {
 	return nil
 }
 
-// NewProgram creates a new service program
-func NewProgram(components []interfaces.ServiceComponent, apiServer interfaces.ServerController, logger *logger.Logger) *Program {
+// NewProgram creates a new service program, prepare creates the components when it starts
+func NewProgram(prepare func() ([]interfaces.ServiceComponent, interfaces.ServerController), logger *logger.Logger) *Program {
 	return &Program{
-		components: components,
-		apiServer:  apiServer,
+		prepare:    prepare,
 		logger:     logger,
 		stopChan:   make(chan struct{}),
 		apiRunning: false,
@@ -259,7 +277,7 @@ func NewProgram(components []interfaces.ServiceComponent, apiServer interfaces.S
 }
 
 // Run runs the Windows service
-func Run(components []interfaces.ServiceComponent, apiServer interfaces.ServerController, logger *logger.Logger) error {
+func Run(prepare func() ([]interfaces.ServiceComponent, interfaces.ServerController), logger *logger.Logger) error {
 	configMutex.RLock()
 	if serviceConfig == nil {
 		configMutex.RUnlock()
@@ -273,7 +291,7 @@ func Run(components []interfaces.ServiceComponent, apiServer interfaces.ServerCo
 		Description: GetServiceDescription(),
 	}
 
-	prg := NewProgram(components, apiServer, logger)
+	prg := NewProgram(prepare, logger)
 	svc, err := service.New(prg, svcConfig)
 	if err != nil {
 		return fmt.Errorf("failed to create service: %v", err)
@@ -302,9 +320,40 @@ func Install() error {
 		return fmt.Errorf("failed to install service: %v", err)
 	}
 
+	if err := setRecoveryActions(GetServiceName()); err != nil {
+		fmt.Printf("Warning: Failed to set service recovery actions: %v\n", err)
+	}
+
 	return nil
 }
 
+// setRecoveryActions lets the service manager restart the service when the process exits
+// unexpectedly, for example after the watchdog found it unhealthy
+func setRecoveryActions(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	actions := []mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 2 * time.Minute},
+	}
+	// Hitungan kegagalan di-reset setelah satu hari tanpa kegagalan
+	if err := s.SetRecoveryActions(actions, uint32((24 * time.Hour).Seconds())); err != nil {
+		return err
+	}
+	return s.SetRecoveryActionsOnNonCrashFailures(true)
+}
+
 // Uninstall removes the Windows service
 func Uninstall() error {
 	svcConfig := &service.Config{
@@ -523,6 +572,13 @@ func GetServiceStatus() (string, error) {
 
 	switch status {
 	case service.StatusRunning:
+		// Start pending is reported as running, the startup state tells them apart
+		if state, ok, _ := baseConfig.LoadStartupState(); ok && state.InProgress() {
+			if len(state.Pending) == 0 {
+				return "Starting, delaying start", nil
+			}
+			return fmt.Sprintf("Starting, waiting for %s", strings.Join(state.Pending, ", ")), nil
+		}
 		return "Running", nil
 	case service.StatusStopped:
 		return "Stopped", nil
@@ -0,0 +1,272 @@
+package bandwidth
+
+import (
+	"io"
+	"jarvist/internal/common/database"
+	"jarvist/internal/common/models"
+	"jarvist/internal/common/residency"
+	"net/http"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Destination names used for accounting
+const (
+	DestinationMQTT    = "mqtt_broker"
+	DestinationAPI     = "api"
+	DestinationUpdate  = "update_server"
+	DestinationTracing = "trace_collector"
+	DestinationLicense = "license_server"
+	DestinationUpload  = "upload_endpoint"
+)
+
+// residencyExempt are the destinations still reachable in local-only mode, they carry no
+// site data: license validation and software updates
+var residencyExempt = map[string]bool{
+	DestinationLicense: true,
+	DestinationUpdate:  true,
+}
+
+const (
+	flushInterval = time.Minute
+	dayFormat     = "2006-01-02"
+
+	// mqttOverhead is an estimate of MQTT fixed header and packet id per publish
+	mqttOverhead = 6
+)
+
+type counter struct {
+	sent     int64
+	received int64
+	requests int64
+}
+
+// Totals is the aggregated usage of one destination over a period
+type Totals struct {
+	Destination   string `json:"destination"`
+	BytesSent     int64  `json:"bytes_sent"`
+	BytesReceived int64  `json:"bytes_received"`
+	Requests      int64  `json:"requests"`
+}
+
+var (
+	mu        sync.Mutex
+	pending   = make(map[string]map[string]*counter) // day -> destination -> counter
+	lastFlush = time.Now()
+)
+
+// Record adds traffic for a destination. Counters are kept in memory and
+// written to the database at most once per minute.
+func Record(destination string, sent, received int64) {
+	day := time.Now().Format(dayFormat)
+
+	mu.Lock()
+	byDest, ok := pending[day]
+	if !ok {
+		byDest = make(map[string]*counter)
+		pending[day] = byDest
+	}
+	c, ok := byDest[destination]
+	if !ok {
+		c = &counter{}
+		byDest[destination] = c
+	}
+	c.sent += sent
+	c.received += received
+	c.requests++
+
+	shouldFlush := time.Since(lastFlush) >= flushInterval
+	if shouldFlush {
+		lastFlush = time.Now()
+	}
+	mu.Unlock()
+
+	if shouldFlush {
+		go Flush()
+	}
+}
+
+// RecordMQTTPublish records a single MQTT publish of the given topic and payload
+func RecordMQTTPublish(topic string, payload []byte) {
+	Record(DestinationMQTT, int64(len(topic)+len(payload)+mqttOverhead), 0)
+}
+
+// Flush writes the in-memory counters to the database. Counters that fail to write go back
+// to the queue for the next flush, the first error is returned.
+func Flush() error {
+	db := database.GetDB()
+	if db == nil {
+		return nil
+	}
+
+	mu.Lock()
+	snapshot := pending
+	pending = make(map[string]map[string]*counter)
+	mu.Unlock()
+
+	var flushErr error
+	for day, byDest := range snapshot {
+		for destination, c := range byDest {
+			usage := models.BandwidthUsage{
+				Destination:   destination,
+				Day:           day,
+				BytesSent:     c.sent,
+				BytesReceived: c.received,
+				Requests:      c.requests,
+				UpdatedAt:     time.Now(),
+			}
+
+			err := db.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "destination"}, {Name: "day"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"bytes_sent":     gorm.Expr("bytes_sent + ?", c.sent),
+					"bytes_received": gorm.Expr("bytes_received + ?", c.received),
+					"requests":       gorm.Expr("requests + ?", c.requests),
+					"updated_at":     time.Now(),
+				}),
+			}).Create(&usage).Error
+
+			if err != nil {
+				// Kembalikan ke antrian agar tidak hilang, dicoba lagi pada flush berikutnya
+				restore(day, destination, c)
+				if flushErr == nil {
+					flushErr = err
+				}
+			}
+		}
+	}
+
+	return flushErr
+}
+
+// GetUsage returns the daily usage rows for the last N days, oldest first
+func GetUsage(db *gorm.DB, days int) ([]models.BandwidthUsage, error) {
+	Flush()
+
+	since := time.Now().AddDate(0, 0, -days+1).Format(dayFormat)
+
+	var usage []models.BandwidthUsage
+	err := db.Where("day >= ?", since).Order("day ASC, destination ASC").Find(&usage).Error
+	return usage, err
+}
+
+// GetTotals returns the usage per destination summed over the last N days
+func GetTotals(db *gorm.DB, days int) ([]Totals, error) {
+	Flush()
+
+	since := time.Now().AddDate(0, 0, -days+1).Format(dayFormat)
+
+	var totals []Totals
+	err := db.Model(&models.BandwidthUsage{}).
+		Select("destination, SUM(bytes_sent) as bytes_sent, SUM(bytes_received) as bytes_received, SUM(requests) as requests").
+		Where("day >= ?", since).
+		Group("destination").
+		Scan(&totals).Error
+	return totals, err
+}
+
+func restore(day, destination string, c *counter) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	byDest, ok := pending[day]
+	if !ok {
+		byDest = make(map[string]*counter)
+		pending[day] = byDest
+	}
+	existing, ok := byDest[destination]
+	if !ok {
+		byDest[destination] = c
+		return
+	}
+	existing.sent += c.sent
+	existing.received += c.received
+	existing.requests += c.requests
+}
+
+// Transport wraps an http.RoundTripper and records request and response sizes. In
+// local-only mode it refuses every destination that could carry site data.
+type Transport struct {
+	Destination string
+	Base        http.RoundTripper
+}
+
+// NewTransport returns a RoundTripper that accounts traffic to destination
+func NewTransport(destination string) *Transport {
+	return &Transport{Destination: destination}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if residency.LocalOnly() && !residencyExempt[t.Destination] {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, residency.ErrLocalOnly
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	sent := estimateRequestSize(req)
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		Record(t.Destination, sent, 0)
+		return nil, err
+	}
+
+	resp.Body = &countingBody{
+		ReadCloser:  resp.Body,
+		destination: t.Destination,
+		sent:        sent,
+		received:    estimateHeaderSize(resp.Header),
+	}
+	return resp, nil
+}
+
+// countingBody records the traffic once the response body is closed
+type countingBody struct {
+	io.ReadCloser
+	destination string
+	sent        int64
+	received    int64
+	once        sync.Once
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.received += int64(n)
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		Record(b.destination, b.sent, b.received)
+	})
+	return err
+}
+
+func estimateRequestSize(req *http.Request) int64 {
+	size := int64(len(req.Method) + len(req.URL.RequestURI()) + 12)
+	size += estimateHeaderSize(req.Header)
+	if req.ContentLength > 0 {
+		size += req.ContentLength
+	}
+	return size
+}
+
+func estimateHeaderSize(header http.Header) int64 {
+	var size int64
+	for key, values := range header {
+		for _, value := range values {
+			size += int64(len(key) + len(value) + 4)
+		}
+	}
+	return size
+}
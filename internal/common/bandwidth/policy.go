@@ -0,0 +1,126 @@
+package bandwidth
+
+import (
+	"errors"
+	"jarvist/internal/common/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Setting keys shared by the desktop app and the sync service
+const (
+	MeteredKey   = "metered_connection"
+	DailyCapKey  = "daily_data_cap_mb"
+	PauseModeKey = "upload_pause_mode"
+)
+
+// Pause modes: auto follows the metered flag and daily cap, pause/resume are manual overrides
+const (
+	PauseModeAuto   = "auto"
+	PauseModePause  = "pause"
+	PauseModeResume = "resume"
+)
+
+// PauseState describes whether non-critical uploads are currently held back
+type PauseState struct {
+	Paused         bool   `json:"paused"`
+	Reason         string `json:"reason,omitempty"`
+	Mode           string `json:"mode"`
+	Metered        bool   `json:"metered"`
+	DailyCapMB     int    `json:"daily_cap_mb"`
+	UsedTodayBytes int64  `json:"used_today_bytes"`
+}
+
+// IsNonCritical returns true for logs, summaries and telemetry topics, which may be
+// held back on metered connections. Logs are published per level under <topic>/logs/,
+// or logs/ when the logger has no topic. Data topics are never paused.
+func IsNonCritical(topic string) bool {
+	return strings.HasPrefix(topic, "logs/") || strings.Contains(topic, "/logs/") || strings.HasSuffix(topic, "/logs") ||
+		strings.Contains(topic, "/summary/") ||
+		strings.Contains(topic, "/telemetry")
+}
+
+// GetPauseState evaluates the pause settings against today's usage
+func GetPauseState(db *gorm.DB) PauseState {
+	state := PauseState{
+		Mode: getSetting(db, PauseModeKey, PauseModeAuto),
+	}
+	state.Metered, _ = strconv.ParseBool(getSetting(db, MeteredKey, "false"))
+	state.DailyCapMB, _ = strconv.Atoi(getSetting(db, DailyCapKey, "0"))
+	state.UsedTodayBytes = usedToday(db)
+
+	switch state.Mode {
+	case PauseModePause:
+		state.Paused, state.Reason = true, "manual"
+	case PauseModeResume:
+		state.Paused = false
+	default:
+		state.Mode = PauseModeAuto
+		if state.Metered {
+			state.Paused, state.Reason = true, "metered"
+		} else if state.DailyCapMB > 0 && state.UsedTodayBytes >= int64(state.DailyCapMB)<<20 {
+			state.Paused, state.Reason = true, "daily_cap"
+		}
+	}
+
+	return state
+}
+
+// SetPauseMode stores a manual override or returns to automatic mode
+func SetPauseMode(db *gorm.DB, mode string) error {
+	switch mode {
+	case PauseModeAuto, PauseModePause, PauseModeResume:
+	default:
+		return errors.New("invalid pause mode, expected auto, pause or resume")
+	}
+	return saveSetting(db, PauseModeKey, mode)
+}
+
+// SetMetered flags the current connection as metered
+func SetMetered(db *gorm.DB, metered bool) error {
+	return saveSetting(db, MeteredKey, strconv.FormatBool(metered))
+}
+
+// SetDailyCap sets the daily data cap in MB, 0 disables the cap
+func SetDailyCap(db *gorm.DB, capMB int) error {
+	if capMB < 0 {
+		return errors.New("daily cap must not be negative")
+	}
+	return saveSetting(db, DailyCapKey, strconv.Itoa(capMB))
+}
+
+func usedToday(db *gorm.DB) int64 {
+	Flush()
+
+	var total int64
+	db.Model(&models.BandwidthUsage{}).
+		Where("day = ?", time.Now().Format(dayFormat)).
+		Select("COALESCE(SUM(bytes_sent + bytes_received), 0)").
+		Scan(&total)
+	return total
+}
+
+func getSetting(db *gorm.DB, key, defaultValue string) string {
+	var setting models.Setting
+	if err := db.Where("key = ?", key).First(&setting).Error; err != nil || setting.Value == "" {
+		return defaultValue
+	}
+	return setting.Value
+}
+
+func saveSetting(db *gorm.DB, key, value string) error {
+	var setting models.Setting
+	result := db.Where("key = ?", key).First(&setting)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return db.Create(&models.Setting{Key: key, Value: value}).Error
+	} else if result.Error != nil {
+		return result.Error
+	}
+
+	setting.Value = value
+	return db.Save(&setting).Error
+}
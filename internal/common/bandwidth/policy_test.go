@@ -0,0 +1,29 @@
+package bandwidth
+
+import "testing"
+
+func TestIsNonCritical(t *testing.T) {
+	tests := []struct {
+		topic string
+		want  bool
+	}{
+		// Logger topics, <MQTT topic>/logs/<level> or logs/<level> without a topic
+		{"jarvist/site/logs/debug", true},
+		{"jarvist/site/logs/info", true},
+		{"jarvist/site/logs/warn", true},
+		{"jarvist/site/logs/error", true},
+		{"logs/info", true},
+		{"jarvist/site/logs", true},
+		{"jarvist/site/summary/folders", true},
+		{"jarvist/site/telemetry", true},
+		{"jarvist/data/20250101", false},
+		{"jarvist/site/recovery", false},
+		{"jarvist/data/20250101/catalogs", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsNonCritical(tt.topic); got != tt.want {
+			t.Errorf("IsNonCritical(%q) = %v, want %v", tt.topic, got, tt.want)
+		}
+	}
+}
@@ -20,7 +20,8 @@ type RawInfoJSON struct {
 	Fixed struct {
 		FileVersion string `json:"file_version"`
 	} `json:"fixed"`
-	Info map[string]struct {
+	Compat CompatRange `json:"compat"`
+	Info   map[string]struct {
 		ProductVersion  string `json:"ProductVersion"`
 		CompanyName     string `json:"CompanyName"`
 		FileDescription string `json:"FileDescription"`
@@ -31,13 +32,14 @@ type RawInfoJSON struct {
 }
 
 type BuildInfo struct {
-	FileVersion     string `json:"file_version"`
-	ProductVersion  string `json:"product_version"`
-	CompanyName     string `json:"company_name"`
-	FileDescription string `json:"file_description"`
-	LegalCopyright  string `json:"legal_copyright"`
-	ProductName     string `json:"product_name"`
-	Comments        string `json:"comments"`
+	FileVersion     string      `json:"file_version"`
+	ProductVersion  string      `json:"product_version"`
+	CompanyName     string      `json:"company_name"`
+	FileDescription string      `json:"file_description"`
+	LegalCopyright  string      `json:"legal_copyright"`
+	ProductName     string      `json:"product_name"`
+	Comments        string      `json:"comments"`
+	Compat          CompatRange `json:"compat"`
 }
 
 // NewBuildInfoService creates a new BuildInfoService
@@ -58,6 +60,7 @@ func (s *BuildInfoService) LoadBuildInfo() BuildInfo {
 	// Add fixed information
 	if s.rawInfo != nil {
 		buildInfo.FileVersion = s.rawInfo.Fixed.FileVersion
+		buildInfo.Compat = s.rawInfo.Compat
 	}
 
 	// Add info details
@@ -0,0 +1,99 @@
+package buildinfo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CompatRange describes the syncmanager versions a desktop build supports
+type CompatRange struct {
+	MinServiceVersion string `json:"min_service_version"`
+	MaxServiceVersion string `json:"max_service_version"`
+}
+
+// CompatResult is the outcome of comparing the app build against the installed service
+type CompatResult struct {
+	Compatible        bool        `json:"compatible"`
+	AppVersion        string      `json:"app_version"`
+	ServiceVersion    string      `json:"service_version"`
+	Range             CompatRange `json:"range"`
+	UpdateRecommended bool        `json:"update_recommended"`
+	Message           string      `json:"message"`
+}
+
+// CheckCompatibility compares serviceVersion against r. An empty bound is treated as unbounded.
+func CheckCompatibility(appVersion, serviceVersion string, r CompatRange) CompatResult {
+	result := CompatResult{
+		Compatible:     true,
+		AppVersion:     appVersion,
+		ServiceVersion: serviceVersion,
+		Range:          r,
+		Message:        "Service version is compatible",
+	}
+
+	if serviceVersion == "" || serviceVersion == "Unknown" {
+		result.Compatible = false
+		result.UpdateRecommended = true
+		result.Message = "Unable to determine service version"
+		return result
+	}
+
+	if r.MinServiceVersion != "" && CompareVersions(serviceVersion, r.MinServiceVersion) < 0 {
+		result.Compatible = false
+		result.UpdateRecommended = true
+		result.Message = fmt.Sprintf("Service version %s is older than the minimum supported %s", serviceVersion, r.MinServiceVersion)
+		return result
+	}
+
+	if r.MaxServiceVersion != "" && CompareVersions(serviceVersion, r.MaxServiceVersion) > 0 {
+		result.Compatible = false
+		result.Message = fmt.Sprintf("Service version %s is newer than the maximum supported %s, please update the application", serviceVersion, r.MaxServiceVersion)
+		return result
+	}
+
+	return result
+}
+
+// CompareVersions compares two dotted versions (e.g. "1.0.1") and returns -1, 0 or 1.
+// Non-numeric suffixes such as "-beta" are ignored.
+func CompareVersions(a, b string) int {
+	pa := parseVersion(a)
+	pb := parseVersion(b)
+
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var va, vb int
+		if i < len(pa) {
+			va = pa[i]
+		}
+		if i < len(pb) {
+			vb = pb[i]
+		}
+
+		if va < vb {
+			return -1
+		}
+		if va > vb {
+			return 1
+		}
+	}
+	return 0
+}
+
+func parseVersion(version string) []int {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if idx := strings.IndexAny(version, "-+ "); idx >= 0 {
+		version = version[:idx]
+	}
+
+	parts := strings.Split(version, ".")
+	result := make([]int, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			n = 0
+		}
+		result = append(result, n)
+	}
+	return result
+}
@@ -0,0 +1,65 @@
+// Package camerahealth shares the camera health report between the desktop app, which
+// checks the cameras and sends the report on a schedule, and the sync service, which
+// validates it and publishes it over MQTT.
+package camerahealth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// IntervalKey is the setting with the minutes between reports, 0 disables them. The desktop
+// app reads it on every check of its reporter.
+const IntervalKey = "camera_health_report_minutes"
+
+// Camera statuses of a report
+const (
+	StatusOnline  = "online"
+	StatusOffline = "offline"
+	StatusUnknown = "unknown" // Not checked since the desktop app started
+)
+
+// maxCameras bounds the cameras of one report
+const maxCameras = 1000
+
+// Camera is the last connection check of one camera
+type Camera struct {
+	CameraUUID  string     `json:"camera_uuid"`
+	CameraID    uint       `json:"camera_id,omitempty"`
+	CameraName  string     `json:"camera_name,omitempty"`
+	Location    string     `json:"location,omitempty"`
+	Status      string     `json:"status"`
+	LastChecked *time.Time `json:"last_checked,omitempty"`
+	Message     string     `json:"message,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// Report is the camera health summary the desktop app sends on a schedule
+type Report struct {
+	GeneratedAt     time.Time `json:"generated_at"`
+	IntervalMinutes int       `json:"interval_minutes"`
+	Online          int       `json:"online"`
+	Offline         int       `json:"offline"`
+	Unknown         int       `json:"unknown"`
+	Cameras         []Camera  `json:"cameras"`
+}
+
+// Validate checks the cameras of a report
+func (r Report) Validate() error {
+	if len(r.Cameras) > maxCameras {
+		return fmt.Errorf("a report holds at most %d cameras", maxCameras)
+	}
+	for i, camera := range r.Cameras {
+		if camera.CameraUUID == "" {
+			return fmt.Errorf("camera %d: camera_uuid is required", i+1)
+		}
+		if camera.Status != StatusOnline && camera.Status != StatusOffline && camera.Status != StatusUnknown {
+			return fmt.Errorf("camera %d: status must be online, offline or unknown", i+1)
+		}
+	}
+	if r.GeneratedAt.IsZero() {
+		return errors.New("generated_at is required")
+	}
+	return nil
+}
@@ -8,12 +8,16 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 // Config berisi konfigurasi aplikasi
+// DefaultDesktopApiPort is the port of the desktop REST API, next to the counter status port
+const DefaultDesktopApiPort = 8766
+
 type Config struct {
 	// App info
 	AppName     string `json:"appName"`
@@ -21,7 +25,8 @@ type Config struct {
 	Environment string `json:"environment"`
 
 	// Database
-	DatabasePath string `json:"databasePath"`
+	DatabasePath string          `json:"databasePath"`
+	Database     DatabaseOptions `json:"database"`
 
 	// Paths
 	BinDir    string `json:"binDir"`
@@ -47,7 +52,14 @@ type Config struct {
 	ServicesDir      string `json:"servicesDir"`
 	ServicesDataDir  string `json:"servicesDarDir"`
 
-	SyncApi string `json:"sync_api"`
+	SyncApi         string `json:"sync_api"`
+	SyncApiUsername string `json:"sync_api_username"`
+	SyncApiPassword string `json:"sync_api_password"`
+
+	// Local REST API of the desktop app for scripts and external tooling, localhost only
+	DesktopApiEnabled bool   `json:"desktopApiEnabled"`
+	DesktopApiPort    int    `json:"desktopApiPort"`
+	DesktopApiToken   string `json:"-"` // Generated and kept in DataDir when empty
 
 	BuildInfo buildinfo.BuildInfo `json:"buildInfo"`
 }
@@ -57,9 +69,19 @@ func (c *Config) IsDev() bool {
 	return c.Environment == "development"
 }
 
-// GetDBConnectionString mengembalikan string koneksi database
+// GetDBConnectionString mengembalikan string koneksi database. The pragmas are part of the
+// DSN so the driver sets them on every pooled connection, not only the first one.
 func (c *Config) GetDBConnectionString() string {
-	return fmt.Sprintf("file:%s?mode=rwc&cache=shared", c.DatabasePath)
+	options := c.Database
+	if options.JournalMode == "" {
+		options = DefaultDatabaseOptions()
+	}
+	cache := "private"
+	if options.SharedCache {
+		cache = "shared"
+	}
+	return fmt.Sprintf("file:%s?mode=rwc&cache=%s&_journal_mode=%s&_busy_timeout=%d&_synchronous=NORMAL",
+		c.DatabasePath, cache, options.JournalMode, options.BusyTimeoutMs)
 }
 
 // LoadConfig memuat konfigurasi dari file dan environment variables
@@ -95,6 +117,10 @@ func LoadConfig(buildMode string, buildInfo *buildinfo.BuildInfoService) (*Confi
 		CameraConfigPath: filepath.Join(currentDir, "bin", "services"),
 		ServicesDir:      filepath.Join(currentDir, "bin", "services"),
 		ServicesDataDir:  filepath.Join(currentDir, "bin", "services", "data"),
+		SyncApiUsername:  "admin",
+		SyncApiPassword:  "admin",
+		DesktopApiPort:   DefaultDesktopApiPort,
+		Database:         DefaultDatabaseOptions(),
 	}
 
 	// Setup paths based on environment
@@ -104,6 +130,9 @@ func LoadConfig(buildMode string, buildInfo *buildinfo.BuildInfoService) (*Confi
 		setupProdPaths(config)
 	}
 
+	// Apply relocated directories, moving them first if a relocation is pending
+	applyPathOverrides(config)
+
 	// Override with environment variables
 	applyEnvOverrides(config)
 
@@ -200,6 +229,30 @@ func applyEnvOverrides(config *Config) {
 		config.ApiKey = val
 	}
 
+	if val := os.Getenv("SYNC_API_USERNAME"); val != "" {
+		config.SyncApiUsername = val
+	}
+
+	if val := os.Getenv("SYNC_API_PASSWORD"); val != "" {
+		config.SyncApiPassword = val
+	}
+
+	if val := os.Getenv("DESKTOP_API_ENABLED"); val != "" {
+		config.DesktopApiEnabled = val == "true"
+	}
+
+	if val := os.Getenv("DESKTOP_API_PORT"); val != "" {
+		if port, err := strconv.Atoi(val); err == nil && port > 0 && port < 65536 {
+			config.DesktopApiPort = port
+		}
+	}
+
+	if val := os.Getenv("DESKTOP_API_TOKEN"); val != "" {
+		config.DesktopApiToken = val
+	}
+
+	applyDatabaseEnv(config)
+
 	if val := os.Getenv("DEBUG_MODE"); val != "" {
 		config.DebugMode = val == "true"
 	}
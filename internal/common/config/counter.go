@@ -0,0 +1,156 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"jarvist/internal/common/models"
+	"jarvist/pkg/utils"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+const (
+	// CounterInstancesFile holds the counter instance definitions in the services directory
+	CounterInstancesFile = "counter_instances.json"
+	// CounterEnvFile is the environment file of the people counter in the services directory
+	CounterEnvFile = ".env"
+
+	// SaveIntervalEnv is how often the counter writes a data file per camera, in seconds
+	SaveIntervalEnv = "SAVE_TO_LOCAL_INTERVAL"
+	// BackfillDirEnv tells the counter where backfill requests are written
+	BackfillDirEnv = "BACKFILL_REQUEST_DIR"
+
+	// BackfillDirName is the directory of backfill requests in the services directory
+	BackfillDirName = "backfill"
+
+	// DefaultSaveInterval is the counter default when SAVE_TO_LOCAL_INTERVAL is not set
+	DefaultSaveInterval = 60 * time.Second
+)
+
+// CounterCadence is how often the counter writes a data file, per camera when counter
+// instances override SAVE_TO_LOCAL_INTERVAL
+type CounterCadence struct {
+	Default   time.Duration
+	PerCamera map[int]time.Duration
+	// Cameras are the cameras the counter is configured to count
+	Cameras []int
+}
+
+// Interval returns the save interval of a camera
+func (c CounterCadence) Interval(cctvID int) time.Duration {
+	if interval, ok := c.PerCamera[cctvID]; ok {
+		return interval
+	}
+	return c.Default
+}
+
+// LoadCounterCadence reads the save interval from the counter .env and the overrides of the
+// counter instances, and the cameras from the camera config or the instances
+func (c *Config) LoadCounterCadence() CounterCadence {
+	cadence := CounterCadence{
+		Default:   DefaultSaveInterval,
+		PerCamera: make(map[int]time.Duration),
+	}
+
+	if env, err := godotenv.Read(filepath.Join(c.ServicesDir, CounterEnvFile)); err == nil {
+		if interval, ok := parseSaveInterval(env[SaveIntervalEnv]); ok {
+			cadence.Default = interval
+		}
+	}
+
+	var instances []struct {
+		Cameras []uint            `json:"cameras"`
+		Env     map[string]string `json:"env"`
+	}
+	if data, err := os.ReadFile(filepath.Join(c.ServicesDir, CounterInstancesFile)); err == nil {
+		json.Unmarshal(data, &instances)
+	}
+
+	// Instance menggantikan counter default, kamera diambil dari definisi instance
+	if len(instances) > 0 {
+		for _, instance := range instances {
+			interval, override := parseSaveInterval(instance.Env[SaveIntervalEnv])
+			for _, camera := range instance.Cameras {
+				cadence.Cameras = append(cadence.Cameras, int(camera))
+				if override {
+					cadence.PerCamera[int(camera)] = interval
+				}
+			}
+		}
+		return cadence
+	}
+
+	var cameraConfig models.CameraConfig
+	if data, err := os.ReadFile(filepath.Join(c.CameraConfigPath, c.CameraConfigName)); err == nil {
+		if json.Unmarshal(data, &cameraConfig) == nil {
+			for _, camera := range cameraConfig.CONFIG {
+				cadence.Cameras = append(cadence.Cameras, int(camera.ID))
+			}
+		}
+	}
+	return cadence
+}
+
+func parseSaveInterval(value string) (time.Duration, bool) {
+	seconds, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || seconds <= 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// BackfillRequest asks the people counter to export the data files of a window again from
+// its local cache. The sync service writes one file per request to the backfill directory,
+// the counter that counts the camera exports the files and deletes the request to
+// acknowledge. Requests for cameras of another counter instance are left alone.
+type BackfillRequest struct {
+	ID          string    `json:"id"`
+	CCTVID      int       `json:"cctv_id"`
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	Reason      string    `json:"reason,omitempty"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// BackfillDir returns the directory the counter reads backfill requests from
+func (c *Config) BackfillDir() string {
+	return filepath.Join(c.ServicesDir, BackfillDirName)
+}
+
+// WriteBackfillRequest writes a request to the backfill directory
+func (c *Config) WriteBackfillRequest(request BackfillRequest) error {
+	dir := c.BackfillDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	data, err := json.MarshalIndent(request, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(c.backfillRequestPath(request.ID), data, 0644)
+}
+
+// BackfillRequestPending reports whether the counter has not acknowledged a request yet
+func (c *Config) BackfillRequestPending(id string) bool {
+	_, err := os.Stat(c.backfillRequestPath(id))
+	return err == nil
+}
+
+// RemoveBackfillRequest withdraws a request the counter did not pick up
+func (c *Config) RemoveBackfillRequest(id string) error {
+	err := os.Remove(c.backfillRequestPath(id))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (c *Config) backfillRequestPath(id string) string {
+	return filepath.Join(c.BackfillDir(), id+".json")
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults of the SQLite connection shared by the desktop app and the sync service
+const (
+	DefaultJournalMode        = "WAL"
+	DefaultBusyTimeoutMs      = 10000
+	DefaultMaxOpenConns       = 20
+	DefaultMaxIdleConns       = 5
+	DefaultConnMaxLifetimeMin = 60
+)
+
+// journalModes are the SQLite journal modes accepted in DB_JOURNAL_MODE
+var journalModes = map[string]bool{
+	"DELETE": true, "TRUNCATE": true, "PERSIST": true, "MEMORY": true, "WAL": true, "OFF": true,
+}
+
+// DatabaseOptions tune the SQLite connection. Both processes open the same file, so WAL and
+// a busy timeout let one wait for the other instead of failing with "database is locked".
+type DatabaseOptions struct {
+	JournalMode        string `json:"journal_mode"`
+	BusyTimeoutMs      int    `json:"busy_timeout_ms"`
+	MaxOpenConns       int    `json:"max_open_conns"`
+	MaxIdleConns       int    `json:"max_idle_conns"`
+	ConnMaxLifetimeMin int    `json:"conn_max_lifetime_min"`
+	// SharedCache shares one page cache between the connections of a process. Its table
+	// locks fail right away without waiting for the busy timeout, so it is off by default.
+	SharedCache bool `json:"shared_cache"`
+}
+
+// DefaultDatabaseOptions returns the options used when no DB_* variable is set
+func DefaultDatabaseOptions() DatabaseOptions {
+	return DatabaseOptions{
+		JournalMode:        DefaultJournalMode,
+		BusyTimeoutMs:      DefaultBusyTimeoutMs,
+		MaxOpenConns:       DefaultMaxOpenConns,
+		MaxIdleConns:       DefaultMaxIdleConns,
+		ConnMaxLifetimeMin: DefaultConnMaxLifetimeMin,
+	}
+}
+
+// ConnMaxLifetime returns how long a pooled connection is reused
+func (o DatabaseOptions) ConnMaxLifetime() time.Duration {
+	return time.Duration(o.ConnMaxLifetimeMin) * time.Minute
+}
+
+// applyDatabaseEnv reads the DB_* variables, invalid values keep the defaults
+func applyDatabaseEnv(config *Config) {
+	options := DefaultDatabaseOptions()
+
+	if val := os.Getenv("DB_JOURNAL_MODE"); val != "" {
+		if mode := strings.ToUpper(val); journalModes[mode] {
+			options.JournalMode = mode
+		}
+	}
+	envInt("DB_BUSY_TIMEOUT_MS", 0, 5*60*1000, &options.BusyTimeoutMs)
+	envInt("DB_MAX_OPEN_CONNS", 1, 100, &options.MaxOpenConns)
+	envInt("DB_MAX_IDLE_CONNS", 0, 100, &options.MaxIdleConns)
+	envInt("DB_CONN_MAX_LIFETIME_MIN", 1, 24*60, &options.ConnMaxLifetimeMin)
+	if val := os.Getenv("DB_SHARED_CACHE"); val != "" {
+		options.SharedCache = val == "true"
+	}
+
+	if options.MaxIdleConns > options.MaxOpenConns {
+		options.MaxIdleConns = options.MaxOpenConns
+	}
+	config.Database = options
+}
+
+func envInt(key string, min, max int, target *int) {
+	val := os.Getenv(key)
+	if val == "" {
+		return
+	}
+	if n, err := strconv.Atoi(val); err == nil && n >= min && n <= max {
+		*target = n
+	}
+}
@@ -0,0 +1,467 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"jarvist/pkg/utils"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Direktori yang bisa dipindahkan
+const (
+	PathData         = "data"
+	PathLogs         = "logs"
+	PathServicesData = "services_data"
+)
+
+const (
+	pathOverridesFile = "paths.json"
+	pathLockFile      = "paths.lock"
+	pathLockStale     = 30 * time.Minute
+	probeFileName     = ".jarvist-write-probe"
+	relocatingSuffix  = ".relocating"
+)
+
+// PendingMove is a relocation that is carried out on the next start of the sync service,
+// before any file in the directory is opened
+type PendingMove struct {
+	Kind        string    `json:"kind"`
+	From        string    `json:"from"`
+	To          string    `json:"to"`
+	RequestedAt time.Time `json:"requestedAt"`
+}
+
+// MoveResult records the outcome of the last relocation
+type MoveResult struct {
+	PendingMove
+	FinishedAt time.Time `json:"finishedAt"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// PathOverrides holds relocated directories. It is stored outside the data directory,
+// in a machine-wide location shared by the desktop app and the sync service.
+type PathOverrides struct {
+	DataDir         string        `json:"dataDir,omitempty"`
+	LogDir          string        `json:"logDir,omitempty"`
+	ServicesDataDir string        `json:"servicesDataDir,omitempty"`
+	Pending         []PendingMove `json:"pending,omitempty"`
+	LastResults     []MoveResult  `json:"lastResults,omitempty"`
+}
+
+// RelocationPlan describes a validated relocation
+type RelocationPlan struct {
+	Kind     string `json:"kind"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Files    int    `json:"files"`
+	Bytes    int64  `json:"bytes"`
+	UNC      bool   `json:"unc"`
+	LongPath bool   `json:"longPath"` // Target paths exceed the classic 260 character limit
+}
+
+// pathOverridesDir returns the directory of the overrides file. ProgramData is used on
+// Windows since the service account has a different APPDATA than the desktop user.
+func pathOverridesDir() string {
+	if runtime.GOOS == "windows" {
+		if programData := os.Getenv("ProgramData"); programData != "" {
+			return filepath.Join(programData, "jarvist")
+		}
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, "jarvist")
+	}
+	return filepath.Join(os.TempDir(), "jarvist")
+}
+
+// LoadPathOverrides reads the relocated directories, a missing file means no overrides
+func LoadPathOverrides() (PathOverrides, error) {
+	var overrides PathOverrides
+
+	data, err := os.ReadFile(filepath.Join(pathOverridesDir(), pathOverridesFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return overrides, nil
+	} else if err != nil {
+		return overrides, err
+	}
+
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return overrides, fmt.Errorf("invalid %s: %w", pathOverridesFile, err)
+	}
+	return overrides, nil
+}
+
+func savePathOverrides(overrides PathOverrides) error {
+	dir := pathOverridesDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	data, err := json.MarshalIndent(overrides, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(filepath.Join(dir, pathOverridesFile), data, 0644)
+}
+
+// applyPathOverrides applies the stored directories. Pending relocations are left to the
+// sync service, a relocation in progress is waited for so that every process uses the same paths.
+func applyPathOverrides(config *Config) {
+	waitForRelocation()
+
+	overrides, err := LoadPathOverrides()
+	if err != nil {
+		log.Printf("Failed to load path overrides: %v", err)
+		return
+	}
+
+	if overrides.DataDir != "" {
+		dbName := filepath.Base(config.DatabasePath)
+		config.DataDir = overrides.DataDir
+		config.DatabasePath = filepath.Join(config.DataDir, dbName)
+	}
+	if overrides.LogDir != "" {
+		config.LogDir = overrides.LogDir
+	}
+	if overrides.ServicesDataDir != "" {
+		config.ServicesDataDir = overrides.ServicesDataDir
+	}
+}
+
+// waitForRelocation blocks while another process holds the relocation lock
+func waitForRelocation() {
+	lockPath := filepath.Join(pathOverridesDir(), pathLockFile)
+	for {
+		info, err := os.Stat(lockPath)
+		if err != nil || time.Since(info.ModTime()) > pathLockStale {
+			return
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// RunPendingRelocations moves the directories of the pending relocations. Only the sync
+// service calls it on start, before its config is loaded, and only while no other process of
+// the installation runs. The desktop app and the counters keep files open in the directories,
+// a relocation is postponed to the next start of the service while they are running.
+func RunPendingRelocations() {
+	overrides, err := LoadPathOverrides()
+	if err != nil {
+		log.Printf("Failed to load path overrides: %v", err)
+		return
+	}
+	if len(overrides.Pending) == 0 {
+		return
+	}
+
+	lockPath := filepath.Join(pathOverridesDir(), pathLockFile)
+	if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > pathLockStale {
+		os.Remove(lockPath)
+	}
+
+	lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("Relocation already in progress in another process")
+		return
+	}
+	lock.Close()
+	defer os.Remove(lockPath)
+
+	// Baca ulang setelah lock, proses lain mungkin sudah selesai memindahkan
+	if fresh, err := LoadPathOverrides(); err == nil {
+		overrides = fresh
+	}
+
+	// Dicek setelah lock, aplikasi yang baru start menunggu lock sebelum membaca path
+	running, err := runningInstallProcesses()
+	if err == nil && len(running) > 0 {
+		err = fmt.Errorf("%s still running", strings.Join(running, ", "))
+	}
+	if err != nil {
+		log.Printf("Relocation postponed: %v", err)
+		overrides.LastResults = nil
+		for _, move := range overrides.Pending {
+			overrides.LastResults = append(overrides.LastResults, MoveResult{
+				PendingMove: move,
+				FinishedAt:  time.Now(),
+				Error:       "postponed to the next service start: " + err.Error(),
+			})
+		}
+		if err := savePathOverrides(overrides); err != nil {
+			log.Printf("Failed to save path overrides: %v", err)
+		}
+		return
+	}
+
+	overrides.LastResults = nil
+	for _, move := range overrides.Pending {
+		result := MoveResult{PendingMove: move}
+
+		if err := moveDir(move.From, move.To); err != nil {
+			result.Error = err.Error()
+			log.Printf("Failed to move %s from %s to %s: %v", move.Kind, move.From, move.To, err)
+		} else {
+			setOverride(&overrides, move.Kind, move.To)
+			log.Printf("Moved %s from %s to %s", move.Kind, move.From, move.To)
+		}
+
+		result.FinishedAt = time.Now()
+		overrides.LastResults = append(overrides.LastResults, result)
+	}
+	overrides.Pending = nil
+
+	if err := savePathOverrides(overrides); err != nil {
+		log.Printf("Failed to save path overrides: %v", err)
+	}
+}
+
+func setOverride(overrides *PathOverrides, kind, path string) {
+	switch kind {
+	case PathData:
+		overrides.DataDir = path
+	case PathLogs:
+		overrides.LogDir = path
+	case PathServicesData:
+		overrides.ServicesDataDir = path
+	}
+}
+
+// DataPaths returns the relocatable directories
+func (c *Config) DataPaths() map[string]string {
+	return map[string]string{
+		PathData:         c.DataDir,
+		PathLogs:         c.LogDir,
+		PathServicesData: c.ServicesDataDir,
+	}
+}
+
+// PlanRelocation validates moving a directory to target: the target must be an absolute
+// local or UNC path outside the current directory, empty and writable
+func (c *Config) PlanRelocation(kind, target string) (RelocationPlan, error) {
+	from, ok := c.DataPaths()[kind]
+	if !ok {
+		return RelocationPlan{}, fmt.Errorf("unknown directory %q", kind)
+	}
+
+	to := filepath.Clean(utils.StripLongPath(strings.TrimSpace(target)))
+	if !filepath.IsAbs(to) {
+		return RelocationPlan{}, fmt.Errorf("target must be an absolute path or UNC share")
+	}
+	if samePath(from, to) || isSubPath(from, to) || isSubPath(to, from) {
+		return RelocationPlan{}, fmt.Errorf("target must not overlap the current directory %s", from)
+	}
+
+	if entries, err := os.ReadDir(utils.LongPath(to)); err == nil && len(entries) > 0 {
+		return RelocationPlan{}, fmt.Errorf("target directory %s is not empty", to)
+	}
+	if err := probeWritable(to); err != nil {
+		return RelocationPlan{}, err
+	}
+
+	plan := RelocationPlan{
+		Kind: kind,
+		From: from,
+		To:   to,
+		UNC:  utils.IsUNCPath(to),
+	}
+
+	err := filepath.WalkDir(utils.LongPath(from), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(utils.LongPath(from), path)
+		if err != nil {
+			return err
+		}
+		if len(filepath.Join(to, rel)) >= 260 {
+			plan.LongPath = true
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		plan.Files++
+		plan.Bytes += info.Size()
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return RelocationPlan{}, fmt.Errorf("failed to read %s: %w", from, err)
+	}
+
+	return plan, nil
+}
+
+// ScheduleRelocation validates the relocation and stores it, the directory is moved on
+// the next start of the sync service while the application and the counters are closed
+func (c *Config) ScheduleRelocation(kind, target string) (RelocationPlan, error) {
+	plan, err := c.PlanRelocation(kind, target)
+	if err != nil {
+		return plan, err
+	}
+
+	overrides, err := LoadPathOverrides()
+	if err != nil {
+		return plan, err
+	}
+
+	pending := overrides.Pending[:0]
+	for _, move := range overrides.Pending {
+		if move.Kind != kind {
+			pending = append(pending, move)
+		}
+	}
+	overrides.Pending = append(pending, PendingMove{
+		Kind:        kind,
+		From:        plan.From,
+		To:          plan.To,
+		RequestedAt: time.Now(),
+	})
+
+	return plan, savePathOverrides(overrides)
+}
+
+// CancelRelocation removes a scheduled relocation
+func CancelRelocation(kind string) error {
+	overrides, err := LoadPathOverrides()
+	if err != nil {
+		return err
+	}
+
+	pending := overrides.Pending[:0]
+	for _, move := range overrides.Pending {
+		if move.Kind != kind {
+			pending = append(pending, move)
+		}
+	}
+	overrides.Pending = pending
+
+	return savePathOverrides(overrides)
+}
+
+// probeWritable creates the target and writes a probe file, network shares often
+// refuse writes for the service account even when the directory can be listed
+func probeWritable(dir string) error {
+	if err := os.MkdirAll(utils.LongPath(dir), 0755); err != nil {
+		return fmt.Errorf("cannot create %s: %w", dir, err)
+	}
+
+	probe := filepath.Join(utils.LongPath(dir), probeFileName)
+	if err := os.WriteFile(probe, []byte("ok"), 0644); err != nil {
+		return fmt.Errorf("cannot write to %s: %w", dir, err)
+	}
+	return os.Remove(probe)
+}
+
+// moveDir renames from to to, or copies and verifies the tree when they are on
+// different volumes. A failed move leaves the source in place and the target empty.
+func moveDir(from, to string) error {
+	src, dst := utils.LongPath(from), utils.LongPath(to)
+
+	if _, err := os.Stat(src); errors.Is(err, os.ErrNotExist) {
+		return os.MkdirAll(dst, 0755)
+	}
+
+	// Rename only works onto a missing target, the planned target is empty
+	os.Remove(dst)
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	// Sumber di-rename dulu di volume yang sama: gagal bila ada file yang masih dibuka, dan
+	// setelahnya tidak ada proses yang bisa menulis ke lokasi lama selama penyalinan
+	staging := src + relocatingSuffix
+	if err := os.Rename(src, staging); err != nil {
+		return fmt.Errorf("%s is in use: %w", from, err)
+	}
+
+	if err := copyTree(staging, dst); err != nil {
+		if cleanErr := os.RemoveAll(dst); cleanErr != nil {
+			log.Printf("Failed to remove incomplete copy %s: %v", to, cleanErr)
+		}
+		if restoreErr := os.Rename(staging, src); restoreErr != nil {
+			return fmt.Errorf("%w, and failed to restore %s from %s: %v", err, from, staging, restoreErr)
+		}
+		return err
+	}
+
+	if err := os.RemoveAll(staging); err != nil {
+		// Data sudah lengkap di tujuan dan sisa ini tidak dipakai proses mana pun
+		log.Printf("Moved data but could not remove all of %s: %v", staging, err)
+	}
+	return nil
+}
+
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	written, err := io.Copy(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	if written != info.Size() {
+		return fmt.Errorf("failed to copy %s: wrote %d of %d bytes", src, written, info.Size())
+	}
+
+	// Waktu modifikasi dipakai scan incremental dan cleanup
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// samePath compares paths case-insensitively, as Windows file systems do
+func samePath(a, b string) bool {
+	return strings.EqualFold(filepath.Clean(a), filepath.Clean(b))
+}
+
+// isSubPath reports whether child is inside parent
+func isSubPath(parent, child string) bool {
+	parent = strings.ToLower(filepath.Clean(parent)) + string(filepath.Separator)
+	return strings.HasPrefix(strings.ToLower(filepath.Clean(child)), parent)
+}
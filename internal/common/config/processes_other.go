@@ -0,0 +1,8 @@
+//go:build !windows
+
+package config
+
+// runningInstallProcesses is only implemented on Windows, elsewhere no process is reported
+func runningInstallProcesses() ([]string, error) {
+	return nil, nil
+}
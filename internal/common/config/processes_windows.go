@@ -0,0 +1,59 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// runningInstallProcesses returns the names of the other processes started from the
+// installation directory: the desktop app, the counters and their ffmpeg
+func runningInstallProcesses() ([]string, error) {
+	execPath, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	installDir := filepath.Dir(execPath)
+
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer windows.CloseHandle(snapshot)
+
+	var names []string
+	self := uint32(os.Getpid())
+
+	var entry windows.ProcessEntry32
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	for err = windows.Process32First(snapshot, &entry); err == nil; err = windows.Process32Next(snapshot, &entry) {
+		if entry.ProcessID == self || entry.ProcessID == 0 {
+			continue
+		}
+		if path := processImagePath(entry.ProcessID); path != "" && isSubPath(installDir, path) {
+			names = append(names, windows.UTF16ToString(entry.ExeFile[:]))
+		}
+	}
+	if err != windows.ERROR_NO_MORE_FILES {
+		return names, err
+	}
+	return names, nil
+}
+
+// processImagePath returns the executable of a process, empty if it cannot be opened
+func processImagePath(pid uint32) string {
+	handle, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return ""
+	}
+	defer windows.CloseHandle(handle)
+
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	size := uint32(len(buf))
+	if err := windows.QueryFullProcessImageName(handle, 0, &buf[0], &size); err != nil {
+		return ""
+	}
+	return windows.UTF16ToString(buf[:size])
+}
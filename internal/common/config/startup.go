@@ -0,0 +1,152 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/pkg/utils"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	startupFile      = "startup.json"
+	startupStateFile = "startup_state.json"
+
+	maxStartupDelaySec = 10 * 60
+	maxStartupWaitSec  = 60 * 60
+)
+
+// Phases of the startup conditions
+const (
+	StartupDelaying = "delaying"  // Waiting out the fixed startup delay
+	StartupWaiting  = "waiting"   // Waiting for the network or paths
+	StartupReady    = "ready"     // All conditions met, the components start
+	StartupTimedOut = "timed_out" // Max wait reached, the components start anyway
+)
+
+// StartupConditions are waited for before the sync service starts its components, for
+// machines where the service starts before the network or a mounted disk is ready. They
+// are stored next to the path overrides since the data directory may be on such a disk.
+type StartupConditions struct {
+	DelaySec    int  `json:"delaySec"` // Fixed delay before the conditions are checked
+	WaitNetwork bool `json:"waitNetwork"`
+	// NetworkHost is a host:port that must accept connections, empty only needs a LAN
+	// address and a resolvable broker
+	NetworkHost string   `json:"networkHost,omitempty"`
+	WaitPaths   []string `json:"waitPaths,omitempty"` // Directories or files that must exist, e.g. a mapped drive
+	MaxWaitSec  int      `json:"maxWaitSec"`          // The components start anyway after this long
+}
+
+// StartupState is the progress of the startup conditions, written by the sync service so
+// the desktop app can show why the service is still starting
+type StartupState struct {
+	Phase      string     `json:"phase"`
+	Pending    []string   `json:"pending,omitempty"` // Conditions not met yet
+	StartedAt  time.Time  `json:"startedAt"`
+	Deadline   time.Time  `json:"deadline"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	PID        int        `json:"pid"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// InProgress reports whether the service is still waiting. A state left behind by a process
+// that stopped while waiting is no longer in progress once its deadline passed.
+func (s StartupState) InProgress() bool {
+	return s.FinishedAt == nil && s.Phase != "" && time.Now().Before(s.Deadline.Add(time.Minute))
+}
+
+// DefaultStartupConditions returns the conditions used until they are changed, no delay
+// and nothing to wait for
+func DefaultStartupConditions() StartupConditions {
+	return StartupConditions{MaxWaitSec: 300}
+}
+
+// Enabled reports whether the service has anything to wait for
+func (c StartupConditions) Enabled() bool {
+	return c.DelaySec > 0 || c.WaitNetwork || len(c.WaitPaths) > 0
+}
+
+// Validate checks the limits of the conditions
+func (c StartupConditions) Validate() error {
+	if c.DelaySec < 0 || c.DelaySec > maxStartupDelaySec {
+		return fmt.Errorf("delaySec must be between 0 and %d", maxStartupDelaySec)
+	}
+	if c.MaxWaitSec < 0 || c.MaxWaitSec > maxStartupWaitSec {
+		return fmt.Errorf("maxWaitSec must be between 0 and %d", maxStartupWaitSec)
+	}
+	if c.NetworkHost != "" {
+		if _, _, err := net.SplitHostPort(c.NetworkHost); err != nil {
+			return fmt.Errorf("networkHost must be host:port: %w", err)
+		}
+	}
+	for _, path := range c.WaitPaths {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("wait path %q must be absolute", path)
+		}
+	}
+	return nil
+}
+
+// LoadStartupConditions reads the startup conditions, a missing file gives the defaults
+func LoadStartupConditions() (StartupConditions, error) {
+	conditions := DefaultStartupConditions()
+
+	data, err := os.ReadFile(filepath.Join(pathOverridesDir(), startupFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return conditions, nil
+	} else if err != nil {
+		return conditions, err
+	}
+
+	if err := json.Unmarshal(data, &conditions); err != nil {
+		return DefaultStartupConditions(), fmt.Errorf("invalid %s: %w", startupFile, err)
+	}
+	return conditions, conditions.Validate()
+}
+
+// SaveStartupConditions stores the startup conditions, they apply on the next service start
+func SaveStartupConditions(conditions StartupConditions) error {
+	if err := conditions.Validate(); err != nil {
+		return err
+	}
+	return writeMachineFile(startupFile, conditions)
+}
+
+// SaveStartupState records the progress of the startup conditions
+func SaveStartupState(state StartupState) error {
+	state.UpdatedAt = time.Now()
+	return writeMachineFile(startupStateFile, state)
+}
+
+// LoadStartupState reads the last recorded startup progress, false if none was recorded
+func LoadStartupState() (StartupState, bool, error) {
+	var state StartupState
+
+	data, err := os.ReadFile(filepath.Join(pathOverridesDir(), startupStateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return state, false, nil
+	} else if err != nil {
+		return state, false, err
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, false, fmt.Errorf("invalid %s: %w", startupStateFile, err)
+	}
+	return state, true, nil
+}
+
+func writeMachineFile(name string, value interface{}) error {
+	dir := pathOverridesDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(filepath.Join(dir, name), data, 0644)
+}
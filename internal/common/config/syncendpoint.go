@@ -0,0 +1,96 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/pkg/utils"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const syncEndpointFile = "sync_endpoint.json"
+
+// PortOwner is the process listening on a port
+type PortOwner struct {
+	PID  int    `json:"pid"`
+	Name string `json:"name,omitempty"`
+}
+
+func (o PortOwner) String() string {
+	if o.Name == "" {
+		return fmt.Sprintf("PID %d", o.PID)
+	}
+	return fmt.Sprintf("%s (PID %d)", o.Name, o.PID)
+}
+
+// SyncEndpoint is the API port chosen by the sync service. It is stored next to the path
+// overrides so the desktop app can find the API after a fallback to another port.
+type SyncEndpoint struct {
+	Port           int        `json:"port"`
+	ConfiguredPort int        `json:"configuredPort"`
+	TLS            bool       `json:"tls"`
+	Fallback       bool       `json:"fallback"`           // Port was taken from the fallback range
+	Conflict       *PortOwner `json:"conflict,omitempty"` // Process holding the configured port, nil if unknown
+	Error          string     `json:"error,omitempty"`    // Set when the API could not listen at all
+	PID            int        `json:"pid"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+// SaveSyncEndpoint records the API port the sync service listens on, or why it could not
+func SaveSyncEndpoint(endpoint SyncEndpoint) error {
+	dir := pathOverridesDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	endpoint.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(endpoint, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(filepath.Join(dir, syncEndpointFile), data, 0644)
+}
+
+// LoadSyncEndpoint reads the recorded API port, false if the sync service has not recorded one
+func LoadSyncEndpoint() (SyncEndpoint, bool, error) {
+	var endpoint SyncEndpoint
+
+	data, err := os.ReadFile(filepath.Join(pathOverridesDir(), syncEndpointFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return endpoint, false, nil
+	} else if err != nil {
+		return endpoint, false, err
+	}
+
+	if err := json.Unmarshal(data, &endpoint); err != nil {
+		return endpoint, false, fmt.Errorf("invalid %s: %w", syncEndpointFile, err)
+	}
+	return endpoint, true, nil
+}
+
+// SyncApiURL returns the sync API base URL with the port the sync service actually listens
+// on. SyncApi is returned unchanged when no port was recorded.
+func (c *Config) SyncApiURL() string {
+	endpoint, ok, err := LoadSyncEndpoint()
+	if err != nil || !ok || endpoint.Error != "" || endpoint.Port <= 0 {
+		return c.SyncApi
+	}
+
+	base, err := url.Parse(c.SyncApi)
+	if err != nil || base.Host == "" {
+		return c.SyncApi
+	}
+
+	base.Host = net.JoinHostPort(base.Hostname(), strconv.Itoa(endpoint.Port))
+	if endpoint.TLS {
+		base.Scheme = "https"
+	} else {
+		base.Scheme = "http"
+	}
+	return base.String()
+}
@@ -4,7 +4,6 @@ import (
 	"jarvist/internal/common/config"
 	"jarvist/internal/common/models"
 	"jarvist/pkg/logger"
-	"time"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -48,17 +47,26 @@ func SetupDatabase(cfg *config.Config, logger *logger.ContextLogger) error {
 		return err
 	}
 
-	// Improve SQLite performance and reduce lock chances
-	sqlDB.SetMaxIdleConns(5)
-	sqlDB.SetMaxOpenConns(20)
-	sqlDB.SetConnMaxLifetime(time.Hour)
+	// Pool dan pragma dari konfigurasi, journal mode dan busy timeout sudah di DSN
+	databasePath = cfg.DatabasePath
+	options = cfg.Database
+	if options.JournalMode == "" {
+		options = config.DefaultDatabaseOptions()
+	}
+	sqlDB.SetMaxIdleConns(options.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(options.MaxOpenConns)
+	sqlDB.SetConnMaxLifetime(options.ConnMaxLifetime())
 
 	// SQLite specific optimizations
-	sqlDB.Exec("PRAGMA journal_mode = WAL;")
-	sqlDB.Exec("PRAGMA synchronous = NORMAL;")
 	sqlDB.Exec("PRAGMA cache_size = 5000;")
 	sqlDB.Exec("PRAGMA temp_store = MEMORY;")
-	sqlDB.Exec("PRAGMA busy_timeout = 10000;")
+
+	if err := registerLockStats(db); err != nil {
+		logger.Warn("Failed to register lock statistics: %s", err.Error())
+	}
+
+	logger.Info("Database opened (journal mode: %s, busy timeout: %dms, max connections: %d)",
+		options.JournalMode, options.BusyTimeoutMs, options.MaxOpenConns)
 
 	logger.Info("Database connection established")
 
@@ -80,6 +88,14 @@ func RunMigrations(logger *logger.ContextLogger) error {
 		return nil
 	}
 
+	// Desktop app dan sync service bisa migrasi bersamaan setelah update
+	lock, err := acquireMigrationLock(DB, logger)
+	if err != nil {
+		logger.Error("Failed to acquire migration lock: %s", err.Error())
+		return err
+	}
+	defer lock.release()
+
 	// Auto-migrate models
 	logger.Info("Running database migrations...")
 	if err := DB.AutoMigrate(
@@ -91,6 +107,22 @@ func RunMigrations(logger *logger.ContextLogger) error {
 		&models.PendingMessage{},
 		&models.ProcessedFile{},
 		&models.SyncedFolder{},
+		&models.BandwidthUsage{},
+		&models.ConfigVersion{},
+		&models.SyncJournal{},
+		&models.AuditEntry{},
+		&models.DataRecord{},
+		&models.BufferedEvent{},
+		&models.CameraSnapshot{},
+		&models.QuarantinedEntry{},
+		&models.LifetimeCounter{},
+		&models.CounterMilestone{},
+		&models.CameraSyncState{},
+		&models.CameraSyncOutbox{},
+		&models.FolderStat{},
+		&models.APIKey{},
+		&models.RecoveryReport{},
+		&models.DeadLetterMessage{},
 	); err != nil {
 		logger.Error("Failed to migrate database: %s", err.Error())
 		return err
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"errors"
+	"jarvist/internal/common/config"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lockStatsCallback is the name of the callback that counts lock errors
+const lockStatsCallback = "jarvist:lock_stats"
+
+// The path and connection options the database was opened with
+var (
+	databasePath string
+	options      config.DatabaseOptions
+)
+
+// lockStats counts the statements that failed because the other process held the database
+var lockStats struct {
+	errors atomic.Int64
+
+	mu        sync.Mutex
+	lastAt    time.Time
+	lastError string
+	byTable   map[string]int64
+}
+
+// PoolStats are the connection pool counters of database/sql
+type PoolStats struct {
+	MaxOpen           int   `json:"max_open"`
+	Open              int   `json:"open"`
+	InUse             int   `json:"in_use"`
+	Idle              int   `json:"idle"`
+	WaitCount         int64 `json:"wait_count"`
+	WaitDurationMs    int64 `json:"wait_duration_ms"`
+	MaxIdleClosed     int64 `json:"max_idle_closed"`
+	MaxLifetimeClosed int64 `json:"max_lifetime_closed"`
+}
+
+// LockStats are the lock errors seen since the process started
+type LockStats struct {
+	Errors    int64            `json:"errors"`
+	ByTable   map[string]int64 `json:"by_table"`
+	LastAt    *time.Time       `json:"last_at,omitempty"`
+	LastError string           `json:"last_error,omitempty"`
+}
+
+// Health is the connection state of the database and its lock contention
+type Health struct {
+	Path          string                 `json:"path"`
+	Configured    config.DatabaseOptions `json:"configured"`
+	JournalMode   string                 `json:"journal_mode"`
+	BusyTimeoutMs int                    `json:"busy_timeout_ms"`
+	Pool          PoolStats              `json:"pool"`
+	Locks         LockStats              `json:"locks"`
+}
+
+// isLockError reports whether an error is SQLITE_BUSY or SQLITE_LOCKED
+func isLockError(err error) bool {
+	if err == nil {
+		return false
+	}
+	message := err.Error()
+	return strings.Contains(message, "database is locked") || strings.Contains(message, "database table is locked")
+}
+
+// registerLockStats counts lock errors of every statement run through gorm
+func registerLockStats(db *gorm.DB) error {
+	record := func(tx *gorm.DB) {
+		if !isLockError(tx.Error) {
+			return
+		}
+		lockStats.errors.Add(1)
+
+		table := tx.Statement.Table
+		if table == "" {
+			table = "raw"
+		}
+		lockStats.mu.Lock()
+		lockStats.lastAt = time.Now()
+		lockStats.lastError = tx.Error.Error()
+		if lockStats.byTable == nil {
+			lockStats.byTable = make(map[string]int64)
+		}
+		lockStats.byTable[table]++
+		lockStats.mu.Unlock()
+	}
+
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().After("gorm:create").Register(lockStatsCallback, record),
+		callbacks.Query().After("gorm:query").Register(lockStatsCallback, record),
+		callbacks.Update().After("gorm:update").Register(lockStatsCallback, record),
+		callbacks.Delete().After("gorm:delete").Register(lockStatsCallback, record),
+		callbacks.Row().After("gorm:row").Register(lockStatsCallback, record),
+		callbacks.Raw().After("gorm:raw").Register(lockStatsCallback, record),
+	)
+}
+
+// GetLockStats returns the lock errors seen since the process started
+func GetLockStats() LockStats {
+	lockStats.mu.Lock()
+	defer lockStats.mu.Unlock()
+
+	stats := LockStats{
+		Errors:    lockStats.errors.Load(),
+		ByTable:   make(map[string]int64, len(lockStats.byTable)),
+		LastError: lockStats.lastError,
+	}
+	for table, count := range lockStats.byTable {
+		stats.ByTable[table] = count
+	}
+	if !lockStats.lastAt.IsZero() {
+		lastAt := lockStats.lastAt
+		stats.LastAt = &lastAt
+	}
+	return stats
+}
+
+// GetHealth reads the pragmas in effect and the pool counters of the database
+func GetHealth() (Health, error) {
+	if DB == nil {
+		return Health{}, errors.New("database is not set up")
+	}
+
+	health := Health{
+		Path:       databasePath,
+		Configured: options,
+		Locks:      GetLockStats(),
+	}
+	if err := DB.Raw("PRAGMA journal_mode").Scan(&health.JournalMode).Error; err != nil {
+		return health, err
+	}
+	if err := DB.Raw("PRAGMA busy_timeout").Scan(&health.BusyTimeoutMs).Error; err != nil {
+		return health, err
+	}
+	health.JournalMode = strings.ToUpper(health.JournalMode)
+
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return health, err
+	}
+	stats := sqlDB.Stats()
+	health.Pool = PoolStats{
+		MaxOpen:           stats.MaxOpenConnections,
+		Open:              stats.OpenConnections,
+		InUse:             stats.InUse,
+		Idle:              stats.Idle,
+		WaitCount:         stats.WaitCount,
+		WaitDurationMs:    stats.WaitDuration.Milliseconds(),
+		MaxIdleClosed:     stats.MaxIdleClosed,
+		MaxLifetimeClosed: stats.MaxLifetimeClosed,
+	}
+	return health, nil
+}
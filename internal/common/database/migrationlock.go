@@ -0,0 +1,160 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"jarvist/pkg/logger"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// migrationLockTimeout is how long a process waits for another one to finish migrating
+	migrationLockTimeout = 2 * time.Minute
+	// migrationLockStale is when a lock whose holder stopped updating it may be taken over,
+	// the holder crashed or was killed in the middle of a migration
+	migrationLockStale = 30 * time.Second
+
+	migrationLockHeartbeat = 5 * time.Second
+	migrationLockPoll      = 500 * time.Millisecond
+)
+
+// ErrMigrationLocked is returned when another process keeps migrating past the timeout
+var ErrMigrationLocked = errors.New("database migration is locked by another process")
+
+// migrationLockHolder is the single row of the migration_lock table
+type migrationLockHolder struct {
+	Owner       string
+	PID         int
+	AcquiredAt  int64
+	HeartbeatAt int64
+}
+
+// migrationLock is an advisory lock in the database shared by the desktop app and the sync
+// service, so only one of them migrates the schema after an update
+type migrationLock struct {
+	db     *gorm.DB
+	owner  string
+	stop   chan struct{}
+	done   chan struct{}
+	logger *logger.ContextLogger
+}
+
+// acquireMigrationLock waits until no other process holds the lock or the timeout passes
+func acquireMigrationLock(db *gorm.DB, logger *logger.ContextLogger) (*migrationLock, error) {
+	// CREATE TABLE IF NOT EXISTS bersifat atomik di SQLite, aman dijalankan bersamaan
+	if err := db.Exec(`CREATE TABLE IF NOT EXISTS migration_lock (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		owner TEXT NOT NULL,
+		pid INTEGER NOT NULL,
+		acquired_at INTEGER NOT NULL,
+		heartbeat_at INTEGER NOT NULL
+	)`).Error; err != nil {
+		return nil, fmt.Errorf("failed to create migration lock table: %w", err)
+	}
+
+	lock := &migrationLock{
+		db:     db,
+		owner:  fmt.Sprintf("%s:%d", filepath.Base(os.Args[0]), os.Getpid()),
+		logger: logger,
+	}
+
+	deadline := time.Now().Add(migrationLockTimeout)
+	waiting := false
+
+	for {
+		acquired, err := lock.tryAcquire()
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			if waiting {
+				logger.Info("Acquired migration lock after waiting for another process")
+			}
+			lock.stop = make(chan struct{})
+			lock.done = make(chan struct{})
+			go lock.heartbeat()
+			return lock, nil
+		}
+
+		holder, _ := lock.holder()
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: held by %s (PID %d) since %s, last seen %s ago",
+				ErrMigrationLocked, holder.Owner, holder.PID,
+				time.UnixMilli(holder.AcquiredAt).Format(time.RFC3339),
+				time.Since(time.UnixMilli(holder.HeartbeatAt)).Round(time.Second))
+		}
+
+		if !waiting && holder.Owner != "" {
+			logger.Info("Database migration is running in %s (PID %d), waiting up to %v", holder.Owner, holder.PID, migrationLockTimeout)
+			waiting = true
+		}
+		time.Sleep(migrationLockPoll)
+	}
+}
+
+// tryAcquire inserts the lock row, or takes it over when its holder stopped heartbeating
+func (l *migrationLock) tryAcquire() (bool, error) {
+	now := time.Now().UnixMilli()
+	staleBefore := now - migrationLockStale.Milliseconds()
+
+	result := l.db.Exec(`INSERT INTO migration_lock (id, owner, pid, acquired_at, heartbeat_at)
+		VALUES (1, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET owner = excluded.owner, pid = excluded.pid,
+			acquired_at = excluded.acquired_at, heartbeat_at = excluded.heartbeat_at
+		WHERE migration_lock.heartbeat_at < ? OR migration_lock.owner = excluded.owner`,
+		l.owner, os.Getpid(), now, now, staleBefore)
+	if result.Error != nil {
+		// Database sedang dikunci proses lain, coba lagi pada putaran berikutnya
+		if isBusy(result.Error) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to acquire migration lock: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (l *migrationLock) holder() (migrationLockHolder, error) {
+	var holder migrationLockHolder
+	err := l.db.Raw("SELECT owner, pid, acquired_at, heartbeat_at FROM migration_lock WHERE id = 1").Scan(&holder).Error
+	return holder, err
+}
+
+// heartbeat keeps the lock fresh while migrations run
+func (l *migrationLock) heartbeat() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(migrationLockHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := l.db.Exec("UPDATE migration_lock SET heartbeat_at = ? WHERE id = 1 AND owner = ?",
+				time.Now().UnixMilli(), l.owner).Error; err != nil {
+				l.logger.Warn("Failed to refresh migration lock: %v", err)
+			}
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// release stops the heartbeat and removes the lock row if it is still ours
+func (l *migrationLock) release() {
+	close(l.stop)
+	<-l.done
+
+	if err := l.db.Exec("DELETE FROM migration_lock WHERE id = 1 AND owner = ?", l.owner).Error; err != nil {
+		l.logger.Warn("Failed to release migration lock, it expires in %v: %v", migrationLockStale, err)
+	}
+}
+
+func isBusy(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "database is locked") || strings.Contains(message, "SQLITE_BUSY")
+}
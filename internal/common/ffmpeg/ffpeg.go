@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"jarvist/internal/common/config" // Update this import to match your project structure
 	"jarvist/pkg/logger"             // Update this import to match your project structure
-	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -18,6 +17,7 @@ import (
 	"time"
 )
 
+// RTSPConfig describes an RTSP stream, credentials and path are given unescaped
 type RTSPConfig struct {
 	Schema   string `json:"schema"`
 	Host     string `json:"host"`
@@ -27,8 +27,14 @@ type RTSPConfig struct {
 	Password string `json:"password,omitempty"`
 }
 
+// RTSPOptions configures a connection check, empty fields use the defaults
 type RTSPOptions struct {
-	TakeScreenshot bool `json:"takeScreenshot"`
+	TakeScreenshot    bool   `json:"takeScreenshot"`
+	Transport         string `json:"transport,omitempty"`         // tcp (default) or udp
+	ConnectTimeoutSec int    `json:"connectTimeoutSec,omitempty"` // Socket I/O timeout, FFmpeg default when 0
+	ReadTimeoutSec    int    `json:"readTimeoutSec,omitempty"`    // Whole check, 30 seconds when 0
+	AnalyzeDurationMs int    `json:"analyzeDurationMs,omitempty"` // Stream analysis, FFmpeg default when 0
+	ProbeMode         string `json:"probeMode,omitempty"`         // decode (default) or probe
 }
 
 type ResponseJSON struct {
@@ -85,45 +91,14 @@ func GetFFmpegPath() string {
 	return FFmpegPath
 }
 
-// GenerateRTSPURL constructs an RTSP URL from the provided configuration
-func GenerateRTSPURL(rtspConfig RTSPConfig) (string, error) {
-	if rtspConfig.Host == "" {
-		return "", errors.New("host is required")
-	}
-
-	if rtspConfig.Schema == "" {
-		rtspConfig.Schema = "rtsp"
-	}
-
-	var credentials string
-	if rtspConfig.Username != "" {
-		if rtspConfig.Password != "" {
-			credentials = fmt.Sprintf("%s:%s@", rtspConfig.Username, url.QueryEscape(rtspConfig.Password))
-		} else {
-			credentials = fmt.Sprintf("%s@", rtspConfig.Username)
-		}
-	}
-
-	var portPart string
-	if rtspConfig.Port != 0 {
-		portPart = fmt.Sprintf(":%d", rtspConfig.Port)
-	}
-
-	var pathPart string
-	if rtspConfig.Path != "" {
-		if strings.HasPrefix(rtspConfig.Path, "/") {
-			pathPart = rtspConfig.Path
-		} else {
-			pathPart = "/" + rtspConfig.Path
-		}
-	}
-
-	rtspURL := fmt.Sprintf("%s://%s%s%s%s", rtspConfig.Schema, credentials, rtspConfig.Host, portPart, pathPart)
-	return rtspURL, nil
-}
-
 // CheckRTSPConnectionWithConfig checks an RTSP connection using the provided configuration
 func CheckRTSPConnectionWithConfig(config RTSPConfig, options RTSPOptions) string {
+	return CheckRTSPConnectionWithConfigContext(context.Background(), config, options)
+}
+
+// CheckRTSPConnectionWithConfigContext is CheckRTSPConnectionWithConfig, cancelling ctx
+// kills the running FFmpeg process
+func CheckRTSPConnectionWithConfigContext(ctx context.Context, config RTSPConfig, options RTSPOptions) string {
 	// Initialize response with timestamp and config data
 	response := ResponseJSON{
 		Timestamp: time.Now(),
@@ -143,21 +118,27 @@ func CheckRTSPConnectionWithConfig(config RTSPConfig, options RTSPOptions) strin
 	response.URL = rtspURL
 
 	// Check the connection
-	return checkRTSPConnectionInternal(rtspURL, options, response)
+	return checkRTSPConnectionInternal(ctx, rtspURL, options, response)
 }
 
 // CheckRTSPConnection checks an RTSP connection with the provided URL
 func CheckRTSPConnection(rtspURL string, options RTSPOptions) string {
+	return CheckRTSPConnectionContext(context.Background(), rtspURL, options)
+}
+
+// CheckRTSPConnectionContext is CheckRTSPConnection, cancelling ctx kills the running
+// FFmpeg process
+func CheckRTSPConnectionContext(ctx context.Context, rtspURL string, options RTSPOptions) string {
 	response := ResponseJSON{
 		URL:       rtspURL,
 		Timestamp: time.Now(),
 	}
 
-	return checkRTSPConnectionInternal(rtspURL, options, response)
+	return checkRTSPConnectionInternal(ctx, rtspURL, options, response)
 }
 
 // checkRTSPConnectionInternal handles the actual connection check
-func checkRTSPConnectionInternal(rtspURL string, options RTSPOptions, response ResponseJSON) string {
+func checkRTSPConnectionInternal(parent context.Context, rtspURL string, options RTSPOptions, response ResponseJSON) string {
 	// Make sure we have FFmpeg
 	ffmpegPath := GetFFmpegPath()
 	if _, err := os.Stat(ffmpegPath); os.IsNotExist(err) {
@@ -169,11 +150,12 @@ func checkRTSPConnectionInternal(rtspURL string, options RTSPOptions, response R
 	}
 
 	// Set up a timeout context
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	readTimeout := options.readTimeout()
+	ctx, cancel := context.WithTimeout(parent, readTimeout)
 	defer cancel()
 
 	// Run FFmpeg command to check connection
-	cmd := exec.CommandContext(ctx, ffmpegPath, "-rtsp_transport", "tcp", "-i", rtspURL, "-t", "2", "-f", "null", "-")
+	cmd := exec.CommandContext(ctx, ffmpegPath, options.probeArgs(rtspURL)...)
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		HideWindow:    true,
 		CreationFlags: 0x08000000,
@@ -187,10 +169,19 @@ func checkRTSPConnectionInternal(rtspURL string, options RTSPOptions, response R
 	output, err := cmd.CombinedOutput()
 	outputStr := string(output)
 
+	// Check for cancellation by the caller
+	if parent.Err() != nil {
+		response.Success = false
+		response.Message = "RTSP connection check cancelled"
+		response.Error = parent.Err().Error()
+		jsonResponse, _ := json.Marshal(response)
+		return string(jsonResponse)
+	}
+
 	// Check for timeout
 	if ctx.Err() == context.DeadlineExceeded {
 		response.Success = false
-		response.Message = "RTSP connection timed out after 30 seconds"
+		response.Message = fmt.Sprintf("RTSP connection timed out after %s", readTimeout)
 		response.Error = "operation timed out"
 		jsonResponse, _ := json.Marshal(response)
 		return string(jsonResponse)
@@ -237,7 +228,7 @@ func checkRTSPConnectionInternal(rtspURL string, options RTSPOptions, response R
 				}
 			}()
 
-			screenshotPath, screenshotErr := captureScreenshot(rtspURL)
+			screenshotPath, screenshotErr := captureScreenshot(parent, rtspURL, options)
 			if screenshotErr != nil {
 				response.Data = map[string]string{
 					"screenshotError": screenshotErr.Error(),
@@ -254,7 +245,7 @@ func checkRTSPConnectionInternal(rtspURL string, options RTSPOptions, response R
 }
 
 // captureScreenshot captures a frame from the RTSP stream
-func captureScreenshot(rtspURL string) (string, error) {
+func captureScreenshot(ctx context.Context, rtspURL string, options RTSPOptions) (string, error) {
 	// Check for valid configuration
 	if cfg == nil {
 		return "", fmt.Errorf("configuration not initialized, cannot capture screenshot")
@@ -283,16 +274,14 @@ func captureScreenshot(rtspURL string) (string, error) {
 	}
 
 	// Create FFmpeg command
-	cmd := exec.Command(
-		ffmpegPath,
-		"-y",
-		"-rtsp_transport", "tcp",
-		"-i", rtspURL,
+	args := append([]string{"-y"}, options.inputArgs(rtspURL)...)
+	args = append(args,
 		"-frames:v", "1",
 		"-q:v", "2",
 		"-s", "480x360",
 		outputPath,
 	)
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		HideWindow:    true,
 		CreationFlags: 0x08000000,
@@ -315,6 +304,52 @@ func captureScreenshot(rtspURL string) (string, error) {
 	return outputPath, nil
 }
 
+// CaptureThumbnail grabs one frame of an RTSP stream as a JPEG scaled to width pixels,
+// the height keeps the aspect ratio
+func CaptureThumbnail(ctx context.Context, config RTSPConfig, options RTSPOptions, width int) ([]byte, error) {
+	rtspURL, err := GenerateRTSPURL(config)
+	if err != nil {
+		return nil, err
+	}
+
+	ffmpegPath := GetFFmpegPath()
+	if _, err := os.Stat(ffmpegPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("ffmpeg not found at %s", ffmpegPath)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, options.readTimeout())
+	defer cancel()
+
+	args := append(options.inputArgs(rtspURL),
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale=%d:-2", width),
+		"-q:v", "8",
+		"-f", "image2",
+		"-c:v", "mjpeg",
+		"pipe:1",
+	)
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow:    true,
+		CreationFlags: 0x08000000,
+	}
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	TrackProcess(cmd)
+	defer UntrackProcess(cmd)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture thumbnail: %w: %s", err, limitOutputSize(stderr.String(), 500))
+	}
+	if len(output) == 0 {
+		return nil, errors.New("ffmpeg returned no frame")
+	}
+	return output, nil
+}
+
 // TrackProcess adds a process to the tracking list
 func TrackProcess(cmd *exec.Cmd) {
 	processMutex.Lock()
@@ -0,0 +1,121 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// RTSP transports
+const (
+	TransportTCP = "tcp"
+	TransportUDP = "udp"
+)
+
+// Probe modes
+const (
+	// ProbeModeDecode decodes a few seconds of the stream, the most reliable check
+	ProbeModeDecode = "decode"
+	// ProbeModeProbe only opens the stream and copies one video packet without decoding,
+	// for NVRs that drop the connection before frames decode or for slow machines
+	ProbeModeProbe = "probe"
+)
+
+// ProbeOptionsKey is the setting holding the global RTSP probe options as JSON, shared by
+// the camera checks of the desktop app and the installation verification of the sync service
+const ProbeOptionsKey = "rtsp_probe_options"
+
+const (
+	defaultReadTimeoutSec = 30
+	decodeSeconds         = "2"
+)
+
+// Defaults fills the empty fields of the options with the defaults
+func (o RTSPOptions) Defaults() RTSPOptions {
+	if o.Transport == "" {
+		o.Transport = TransportTCP
+	}
+	if o.ReadTimeoutSec <= 0 {
+		o.ReadTimeoutSec = defaultReadTimeoutSec
+	}
+	if o.ProbeMode == "" {
+		o.ProbeMode = ProbeModeDecode
+	}
+	return o
+}
+
+// Merge returns the options with the set fields of override applied, used to apply the
+// options of one camera over the global options
+func (o RTSPOptions) Merge(override RTSPOptions) RTSPOptions {
+	if override.Transport != "" {
+		o.Transport = override.Transport
+	}
+	if override.ConnectTimeoutSec > 0 {
+		o.ConnectTimeoutSec = override.ConnectTimeoutSec
+	}
+	if override.ReadTimeoutSec > 0 {
+		o.ReadTimeoutSec = override.ReadTimeoutSec
+	}
+	if override.AnalyzeDurationMs > 0 {
+		o.AnalyzeDurationMs = override.AnalyzeDurationMs
+	}
+	if override.ProbeMode != "" {
+		o.ProbeMode = override.ProbeMode
+	}
+	return o
+}
+
+// Validate checks the probe options, empty fields are valid and use the defaults
+func (o RTSPOptions) Validate() error {
+	switch o.Transport {
+	case "", TransportTCP, TransportUDP:
+	default:
+		return fmt.Errorf("invalid transport %q, expected tcp or udp", o.Transport)
+	}
+
+	switch o.ProbeMode {
+	case "", ProbeModeDecode, ProbeModeProbe:
+	default:
+		return fmt.Errorf("invalid probe mode %q, expected decode or probe", o.ProbeMode)
+	}
+
+	if o.ConnectTimeoutSec < 0 || o.ConnectTimeoutSec > 120 {
+		return fmt.Errorf("connect timeout must be between 0 and 120 seconds")
+	}
+	if o.ReadTimeoutSec < 0 || o.ReadTimeoutSec > 300 {
+		return fmt.Errorf("read timeout must be between 0 and 300 seconds")
+	}
+	if o.AnalyzeDurationMs < 0 || o.AnalyzeDurationMs > 60000 {
+		return fmt.Errorf("analyze duration must be between 0 and 60000 ms")
+	}
+	return nil
+}
+
+// readTimeout is how long the FFmpeg process may run
+func (o RTSPOptions) readTimeout() time.Duration {
+	return time.Duration(o.Defaults().ReadTimeoutSec) * time.Second
+}
+
+// inputArgs returns the FFmpeg arguments that open the RTSP input
+func (o RTSPOptions) inputArgs(rtspURL string) []string {
+	o = o.Defaults()
+
+	args := []string{"-rtsp_transport", o.Transport}
+	if o.ConnectTimeoutSec > 0 {
+		// rw_timeout dipakai karena arti -timeout untuk RTSP berbeda antar versi FFmpeg
+		args = append(args, "-rw_timeout", strconv.Itoa(o.ConnectTimeoutSec*1000000))
+	}
+	if o.AnalyzeDurationMs > 0 {
+		args = append(args, "-analyzeduration", strconv.Itoa(o.AnalyzeDurationMs*1000))
+	}
+	return append(args, "-i", rtspURL)
+}
+
+// probeArgs returns the FFmpeg arguments of a connection check
+func (o RTSPOptions) probeArgs(rtspURL string) []string {
+	args := o.inputArgs(rtspURL)
+	if o.Defaults().ProbeMode == ProbeModeProbe {
+		return append(args, "-map", "0:v:0", "-c", "copy", "-frames:v", "1", "-f", "null", "-")
+	}
+	return append(args, "-t", decodeSeconds, "-f", "null", "-")
+}
@@ -0,0 +1,203 @@
+package ffmpeg
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// schemaPattern matches a URL scheme like rtsp or rtsps
+var schemaPattern = regexp.MustCompile(`^[a-z][a-z0-9+.-]*$`)
+
+// Validate checks the configuration before an URL is generated from it
+func (c RTSPConfig) Validate() error {
+	_, err := c.normalize()
+	return err
+}
+
+// normalize trims the configuration and splits a port given in the host
+func (c RTSPConfig) normalize() (RTSPConfig, error) {
+	c.Schema = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(c.Schema), "://"))
+	c.Host = strings.TrimSpace(c.Host)
+	c.Path = strings.TrimSpace(c.Path)
+
+	if c.Schema == "" {
+		c.Schema = "rtsp"
+	}
+	if !schemaPattern.MatchString(c.Schema) {
+		return c, fmt.Errorf("invalid schema %q, use rtsp or rtsps", c.Schema)
+	}
+
+	if c.Host == "" {
+		return c, errors.New("host is required")
+	}
+	if strings.Contains(c.Host, "://") {
+		return c, errors.New("host must not contain a schema, enter only the IP address or hostname")
+	}
+	if strings.ContainsAny(c.Host, "@/?#\\ \t") {
+		return c, fmt.Errorf("invalid host %q, enter only the IP address or hostname", c.Host)
+	}
+
+	// Host berisi port, misalnya 192.168.1.10:554 atau [fe80::1]:554
+	if host, port, err := net.SplitHostPort(c.Host); err == nil {
+		portNumber, err := strconv.Atoi(port)
+		if err != nil {
+			return c, fmt.Errorf("invalid port %q in host", port)
+		}
+		if c.Port != 0 && c.Port != portNumber {
+			return c, fmt.Errorf("host contains port %d but port is set to %d", portNumber, c.Port)
+		}
+		c.Host, c.Port = host, portNumber
+	}
+	c.Host = strings.TrimSuffix(strings.TrimPrefix(c.Host, "["), "]")
+	if c.Host == "" {
+		return c, errors.New("host is required")
+	}
+	if strings.Contains(c.Host, ":") && net.ParseIP(c.Host) == nil {
+		return c, fmt.Errorf("invalid host %q", c.Host)
+	}
+
+	if c.Port < 0 || c.Port > 65535 {
+		return c, fmt.Errorf("invalid port %d, must be between 1 and 65535", c.Port)
+	}
+
+	if c.Username == "" && c.Password != "" {
+		return c, errors.New("username is required when a password is set")
+	}
+	if strings.ContainsAny(c.Username+c.Password, "\r\n") {
+		return c, errors.New("username and password must not contain line breaks")
+	}
+
+	return c, nil
+}
+
+// EndpointKey identifies the stream of the configuration regardless of credentials, case and
+// formatting, two cameras with the same key read the same stream. Returns "" if the
+// configuration is invalid.
+func (c RTSPConfig) EndpointKey() string {
+	config, err := c.normalize()
+	if err != nil {
+		return ""
+	}
+
+	port := config.Port
+	if port == 0 {
+		port = 554
+		if config.Schema == "rtsps" {
+			port = 322
+		}
+	}
+	path, query, _ := strings.Cut(config.Path, "?")
+	path = "/" + strings.Trim(path, "/")
+	if query != "" {
+		path += "?" + query
+	}
+	return fmt.Sprintf("%s://%s%s", config.Schema, net.JoinHostPort(strings.ToLower(config.Host), strconv.Itoa(port)), path)
+}
+
+// GenerateRTSPURL constructs an RTSP URL from the provided configuration. Credentials and
+// path segments are percent-encoded, so characters like @, / or # in a password are kept.
+// A query in the path, like ?channel=1&subtype=0, is passed on.
+func GenerateRTSPURL(rtspConfig RTSPConfig) (string, error) {
+	config, err := rtspConfig.normalize()
+	if err != nil {
+		return "", err
+	}
+
+	var builder strings.Builder
+	builder.WriteString(config.Schema)
+	builder.WriteString("://")
+
+	if config.Username != "" {
+		builder.WriteString(escapeUserinfo(config.Username))
+		if config.Password != "" {
+			builder.WriteByte(':')
+			builder.WriteString(escapeUserinfo(config.Password))
+		}
+		builder.WriteByte('@')
+	}
+
+	host := config.Host
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	builder.WriteString(host)
+	if config.Port != 0 {
+		builder.WriteString(":" + strconv.Itoa(config.Port))
+	}
+
+	if config.Path != "" {
+		path, query, hasQuery := strings.Cut(config.Path, "?")
+		builder.WriteString(escapePath(path))
+		if hasQuery {
+			builder.WriteByte('?')
+			builder.WriteString(escapeQuery(query))
+		}
+	}
+
+	return builder.String(), nil
+}
+
+// escapeUserinfo percent-encodes everything except unreserved characters, FFmpeg decodes
+// the credentials before authenticating
+func escapeUserinfo(value string) string {
+	return escapeBytes(value, func(c byte) bool { return isUnreserved(c) })
+}
+
+// escapePath percent-encodes each segment of the path. Valid escapes already in the path
+// are kept, so an encoded path is not encoded twice.
+func escapePath(path string) string {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, segment := range segments {
+		if decoded, err := url.PathUnescape(segment); err == nil {
+			segment = decoded
+		}
+		segments[i] = escapeBytes(segment, func(c byte) bool {
+			return isUnreserved(c) || strings.IndexByte("!$&'()*+,;=:@", c) >= 0
+		})
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// escapeQuery percent-encodes characters that are not allowed in a query, the parameters
+// and their order stay unchanged
+func escapeQuery(query string) string {
+	var builder strings.Builder
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '%' && i+2 < len(query) && isHex(query[i+1]) && isHex(query[i+2]):
+			builder.WriteByte(c)
+		case isUnreserved(c) || strings.IndexByte("!$&'()*+,;=:@/?", c) >= 0:
+			builder.WriteByte(c)
+		default:
+			fmt.Fprintf(&builder, "%%%02X", c)
+		}
+	}
+	return builder.String()
+}
+
+func escapeBytes(value string, keep func(byte) bool) string {
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; keep(c) {
+			builder.WriteByte(c)
+		} else {
+			fmt.Fprintf(&builder, "%%%02X", c)
+		}
+	}
+	return builder.String()
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
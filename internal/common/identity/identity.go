@@ -0,0 +1,298 @@
+package identity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Setting keys that make up the device identity
+const (
+	TenantIDKey    = "tenant_id"
+	ClientIDKey    = "client_id"
+	SiteIDKey      = "site_id"
+	SiteCodeKey    = "site_code"
+	TopicPrefixKey = "mqtt_topic_prefix"
+	DeviceTagsKey  = "device_tags"
+)
+
+// DefaultTopicPrefix is the first level of every data topic
+const DefaultTopicPrefix = "jarvist"
+
+// AuditAction is the action name used for identity changes in the audit table
+const AuditAction = "identity.update"
+
+const maxTopicPrefixLength = 64
+
+var tagKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// Limits of the device tags, they are sent with every data message and heartbeat
+const (
+	maxTags           = 20
+	maxTagKeyLength   = 32
+	maxTagValueLength = 64
+)
+
+// Identity is the tenant, client and site this device reports as
+type Identity struct {
+	TenantID    string `json:"tenant_id"`
+	ClientID    string `json:"client_id"`
+	SiteID      string `json:"site_id"`
+	SiteCode    string `json:"site_code"`
+	TopicPrefix string `json:"topic_prefix"`
+	// Tags group devices in the fleet, like region, customer or store format
+	Tags map[string]string `json:"tags"`
+}
+
+// Change describes one field that differs between two identities
+type Change struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Get reads the effective identity from the settings table
+func Get(db *gorm.DB) Identity {
+	id := Identity{
+		TenantID:    getSetting(db, TenantIDKey),
+		ClientID:    getSetting(db, ClientIDKey),
+		SiteID:      getSetting(db, SiteIDKey),
+		SiteCode:    getSetting(db, SiteCodeKey),
+		TopicPrefix: getSetting(db, TopicPrefixKey),
+		Tags:        decodeTags(getSetting(db, DeviceTagsKey)),
+	}
+	if id.TopicPrefix == "" {
+		id.TopicPrefix = DefaultTopicPrefix
+	}
+	return id
+}
+
+// DataTopic returns the topic data files of a date folder are published to
+func (i Identity) DataTopic(folderName string) string {
+	prefix := i.TopicPrefix
+	if prefix == "" {
+		prefix = DefaultTopicPrefix
+	}
+	return fmt.Sprintf("%s/data/%s", prefix, folderName)
+}
+
+// Normalize trims whitespace and turns float formatted client IDs ("1937.000000") into integers
+func (i Identity) Normalize() Identity {
+	i.TenantID = strings.TrimSpace(i.TenantID)
+	i.ClientID = strings.TrimSpace(i.ClientID)
+	i.SiteID = strings.TrimSpace(i.SiteID)
+	i.SiteCode = strings.TrimSpace(i.SiteCode)
+	i.TopicPrefix = strings.Trim(strings.TrimSpace(i.TopicPrefix), "/")
+
+	// Key tag tidak peka huruf besar, tag dengan nilai kosong dihapus
+	tags := make(map[string]string, len(i.Tags))
+	for key, value := range i.Tags {
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if value != "" {
+			tags[key] = value
+		}
+	}
+	i.Tags = tags
+
+	if f, err := strconv.ParseFloat(i.ClientID, 64); err == nil && f == float64(int64(f)) {
+		i.ClientID = strconv.FormatInt(int64(f), 10)
+	}
+	return i
+}
+
+// Validate returns all problems with the identity, nil if it can be saved
+func (i Identity) Validate() []string {
+	var problems []string
+
+	if i.TenantID == "" {
+		problems = append(problems, "tenant_id is required")
+	} else if strings.ContainsAny(i.TenantID, " /+#") {
+		problems = append(problems, "tenant_id must not contain spaces, '/', '+' or '#'")
+	}
+
+	if n, err := strconv.ParseInt(i.ClientID, 10, 64); err != nil || n <= 0 {
+		problems = append(problems, "client_id must be a positive number")
+	}
+
+	if n, err := strconv.ParseInt(i.SiteID, 10, 64); err != nil || n <= 0 {
+		problems = append(problems, "site_id must be a positive number")
+	}
+
+	if problem := validateTopicPrefix(i.TopicPrefix); problem != "" {
+		problems = append(problems, problem)
+	}
+
+	problems = append(problems, validateTags(i.Tags)...)
+
+	return problems
+}
+
+// Diff returns the fields that differ from other
+func (i Identity) Diff(other Identity) []Change {
+	var changes []Change
+	add := func(field, old, new string) {
+		if old != new {
+			changes = append(changes, Change{Field: field, Old: old, New: new})
+		}
+	}
+	add(TenantIDKey, i.TenantID, other.TenantID)
+	add(ClientIDKey, i.ClientID, other.ClientID)
+	add(SiteIDKey, i.SiteID, other.SiteID)
+	add(SiteCodeKey, i.SiteCode, other.SiteCode)
+	add(TopicPrefixKey, i.TopicPrefix, other.TopicPrefix)
+	add(DeviceTagsKey, encodeTags(i.Tags), encodeTags(other.Tags))
+	return changes
+}
+
+// Save validates next, stores the changed fields and writes an audit entry in one
+// transaction. Returns the applied changes, empty if nothing changed.
+func Save(db *gorm.DB, next Identity, actor, source string) ([]Change, error) {
+	next = next.Normalize()
+	if problems := next.Validate(); len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "; "))
+	}
+
+	current := Get(db)
+	changes := current.Diff(next)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	detail, err := json.Marshal(changes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, change := range changes {
+			if err := saveSetting(tx, change.Field, change.New); err != nil {
+				return fmt.Errorf("failed to save %s: %w", change.Field, err)
+			}
+		}
+
+		return tx.Create(&models.AuditEntry{
+			Timestamp: time.Now(),
+			Action:    AuditAction,
+			Actor:     actor,
+			Source:    source,
+			Detail:    string(detail),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// GetAudit returns the latest identity audit entries, newest first
+func GetAudit(db *gorm.DB, limit int) ([]models.AuditEntry, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	var entries []models.AuditEntry
+	err := db.Where("action = ?", AuditAction).Order("id DESC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+func validateTopicPrefix(prefix string) string {
+	if prefix == "" {
+		return "topic_prefix is required"
+	}
+	if len(prefix) > maxTopicPrefixLength {
+		return fmt.Sprintf("topic_prefix must be at most %d characters", maxTopicPrefixLength)
+	}
+	if strings.ContainsAny(prefix, "+#\x00 ") {
+		return "topic_prefix must not contain wildcards, spaces or null characters"
+	}
+	if strings.HasPrefix(prefix, "$") {
+		return "topic_prefix must not start with '$'"
+	}
+	for _, level := range strings.Split(prefix, "/") {
+		if level == "" {
+			return "topic_prefix must not contain empty levels"
+		}
+	}
+	return ""
+}
+
+func validateTags(tags map[string]string) []string {
+	var problems []string
+
+	if len(tags) > maxTags {
+		problems = append(problems, fmt.Sprintf("at most %d tags are allowed", maxTags))
+	}
+
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !tagKeyPattern.MatchString(key) {
+			problems = append(problems, fmt.Sprintf("tag %q must start with a letter and contain only a-z, 0-9, '_' or '-', up to %d characters", key, maxTagKeyLength))
+		}
+		if len(tags[key]) > maxTagValueLength {
+			problems = append(problems, fmt.Sprintf("value of tag %q must be at most %d characters", key, maxTagValueLength))
+		}
+	}
+	return problems
+}
+
+// GetTags reads only the device tags, for payloads built often like heartbeats
+func GetTags(db *gorm.DB) map[string]string {
+	return decodeTags(getSetting(db, DeviceTagsKey))
+}
+
+// encodeTags stores tags as JSON, map keys are sorted so equal tags give equal text
+func encodeTags(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func decodeTags(value string) map[string]string {
+	tags := make(map[string]string)
+	if value != "" {
+		json.Unmarshal([]byte(value), &tags)
+	}
+	return tags
+}
+
+func getSetting(db *gorm.DB, key string) string {
+	var setting models.Setting
+	if err := db.Where("key = ?", key).First(&setting).Error; err != nil {
+		return ""
+	}
+	return setting.Value
+}
+
+func saveSetting(tx *gorm.DB, key, value string) error {
+	var setting models.Setting
+	result := tx.Where("key = ?", key).First(&setting)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return tx.Create(&models.Setting{Key: key, Value: value}).Error
+	} else if result.Error != nil {
+		return result.Error
+	}
+
+	setting.Value = value
+	return tx.Save(&setting).Error
+}
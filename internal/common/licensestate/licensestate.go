@@ -0,0 +1,62 @@
+// Package licensestate shares the license degradation between the desktop app, which
+// validates the license, and the sync service, which pauses cloud publishing while the
+// license is expired past its grace period.
+package licensestate
+
+import (
+	"encoding/json"
+	"errors"
+	"jarvist/internal/common/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Key is the setting key of the degradation state
+const Key = "license_degradation"
+
+// ReasonExpired is the only degradation reason so far, a license past its grace period
+const ReasonExpired = "expired"
+
+// State describes whether the device runs degraded. Counting continues and data is kept
+// locally, cloud publishing is paused until the license is renewed.
+type State struct {
+	Degraded  bool       `json:"degraded"`
+	Reason    string     `json:"reason,omitempty"`
+	Since     *time.Time `json:"since,omitempty"`
+	ExpiredAt *time.Time `json:"expired_at,omitempty"`
+}
+
+// Get returns the stored state, a device without one is not degraded
+func Get(db *gorm.DB) State {
+	var setting models.Setting
+	if err := db.Where("key = ?", Key).First(&setting).Error; err != nil || setting.Value == "" {
+		return State{}
+	}
+
+	var state State
+	if json.Unmarshal([]byte(setting.Value), &state) != nil {
+		return State{}
+	}
+	return state
+}
+
+// Set stores the state
+func Set(db *gorm.DB, state State) error {
+	value, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	var setting models.Setting
+	result := db.Where("key = ?", Key).First(&setting)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return db.Create(&models.Setting{Key: Key, Value: string(value)}).Error
+	} else if result.Error != nil {
+		return result.Error
+	}
+
+	setting.Value = string(value)
+	return db.Save(&setting).Error
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// APIKey adalah kredensial API terbatas untuk pihak ketiga, token aslinya tidak disimpan
+type APIKey struct {
+	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name         string     `gorm:"not null" json:"name"`
+	Scope        string     `gorm:"not null" json:"scope"`
+	Prefix       string     `gorm:"index" json:"prefix"` // First characters of the token to recognise it
+	TokenHash    string     `gorm:"uniqueIndex;not null" json:"-"`
+	CreatedBy    string     `json:"created_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    time.Time  `gorm:"index" json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy    string     `json:"revoked_by,omitempty"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+	LastUsedFrom string     `json:"last_used_from,omitempty"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditEntry mencatat perubahan konfigurasi penting beserta pelakunya
+type AuditEntry struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Timestamp time.Time `gorm:"index;default:CURRENT_TIMESTAMP" json:"timestamp"`
+	Action    string    `gorm:"index;not null" json:"action"`
+	Actor     string    `json:"actor"`
+	Source    string    `json:"source"`
+	Detail    string    `gorm:"type:text" json:"detail"`
+}
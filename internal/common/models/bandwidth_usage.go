@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// BandwidthUsage menyimpan jumlah byte per tujuan per hari
+type BandwidthUsage struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Destination   string    `gorm:"uniqueIndex:idx_bandwidth_dest_day;not null" json:"destination"`
+	Day           string    `gorm:"uniqueIndex:idx_bandwidth_dest_day;not null" json:"day"`
+	BytesSent     int64     `gorm:"default:0" json:"bytes_sent"`
+	BytesReceived int64     `gorm:"default:0" json:"bytes_received"`
+	Requests      int64     `gorm:"default:0" json:"requests"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// BufferedEvent menyimpan event frontend terakhir per channel agar window yang baru dibuka
+// bisa mengambil event yang terlewat
+type BufferedEvent struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"seq"`
+	Channel   string    `gorm:"index;not null" json:"channel"`
+	Data      string    `gorm:"type:text" json:"data"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
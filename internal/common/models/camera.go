@@ -8,24 +8,29 @@ import (
 )
 
 type Camera struct {
-	ID          uint    `gorm:"primaryKey;autoIncrement"`
-	UUID        string  `json:"uuid" gorm:"type:text"`
-	Name        string  `gorm:"not null"`
-	LocationID  string  `gorm:"type:text;not null;index"`
-	Description string  `gorm:"type:text"`
-	Tags        string  `gorm:"type:text"`
-	Schema      string  `gorm:"not null"`
-	Host        string  `gorm:"not null"`
-	Port        int     `gorm:"default:0"`
-	Path        string  `gorm:"type:text"`
-	Username    string  `gorm:"type:text"`
-	Password    string  `gorm:"type:text"`
-	ImageData   string  `gorm:"type:text"`
-	Direction   string  `gorm:"type:text"`
-	Status      string  `gorm:"type:text"`
-	Payload     string  `gorm:"type:json"`
-	CreatedAt   string  `gorm:"autoCreateTime" json:"created_at"`
-	DeletedAt   *string `json:"deleted_at,omitempty"`
+	ID          uint   `gorm:"primaryKey;autoIncrement"`
+	UUID        string `json:"uuid" gorm:"type:text"`
+	Name        string `gorm:"not null"`
+	LocationID  string `gorm:"type:text;not null;index"`
+	Description string `gorm:"type:text"`
+	Tags        string `gorm:"type:text"`
+	Schema      string `gorm:"not null"`
+	Host        string `gorm:"not null"`
+	Port        int    `gorm:"default:0"`
+	Path        string `gorm:"type:text"`
+	Username    string `gorm:"type:text"`
+	Password    string `gorm:"type:text"`
+	ImageData   string `gorm:"type:text"`
+	Direction   string `gorm:"type:text"`
+	Status      string `gorm:"type:text"`
+	Payload     string `gorm:"type:json"`
+	// RTSP probe options of this camera as JSON, applied over the global options
+	ProbeOptions string `gorm:"type:text" json:"probe_options,omitempty"`
+	// Position on the floorplan of the location, relative 0..1 from the top left
+	FloorplanX *float64 `json:"floorplan_x,omitempty"`
+	FloorplanY *float64 `json:"floorplan_y,omitempty"`
+	CreatedAt  string   `gorm:"autoCreateTime" json:"created_at"`
+	DeletedAt  *string  `json:"deleted_at,omitempty"`
 
 	IsConnected   *bool   `gorm:"-" json:"is_connected,omitempty"`
 	LastChecked   *string `gorm:"-" json:"last_checked,omitempty"`
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// CameraSnapshot menyimpan thumbnail terakhir dari kamera yang online, dipakai untuk
+// melampirkan frame terakhir pada alert kamera offline
+type CameraSnapshot struct {
+	CameraUUID  string    `gorm:"primaryKey" json:"camera_uuid"`
+	ContentType string    `json:"content_type"`
+	Data        []byte    `json:"-"`
+	Size        int       `json:"size"`
+	CapturedAt  time.Time `gorm:"index" json:"captured_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// CameraSyncOutbox mencatat sinkronisasi kamera ke server yang gagal dan menunggu dicoba
+// lagi. Gagal berulang digabung ke satu baris karena setiap percobaan mengirim kondisi terbaru.
+type CameraSyncOutbox struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `gorm:"type:text" json:"last_error"`
+	NextAttemptAt time.Time `gorm:"index" json:"next_attempt_at"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// CameraSyncState menyimpan hash kamera yang terakhir berhasil dikirim ke server, dipakai
+// untuk mengirim hanya kamera yang berubah
+type CameraSyncState struct {
+	UUID     string    `gorm:"primaryKey" json:"uuid"`
+	CameraID uint      `json:"camera_id"`
+	Hash     string    `gorm:"not null" json:"hash"`
+	SyncedAt time.Time `json:"synced_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// ConfigVersion menyimpan salinan config.camera.json setiap kali isinya berubah
+type ConfigVersion struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Hash      string    `gorm:"index;not null" json:"hash"`
+	Content   string    `gorm:"type:text" json:"content"`
+	Diff      string    `gorm:"type:text" json:"diff"`
+	Source    string    `json:"source"`
+	Cameras   int       `json:"cameras"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// DataRecord menyimpan data counter lengkap dari setiap file yang sudah dikirim, untuk
+// analitik lokal, replay dan export tanpa membaca ulang file terenkripsi
+type DataRecord struct {
+	ID                 uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Filename           string    `gorm:"uniqueIndex;not null" json:"filename"`
+	DateFolder         string    `gorm:"index;not null" json:"date_folder"`
+	EntryID            string    `json:"entry_id"`
+	CCTVID             int       `gorm:"index" json:"cctv_id"`
+	DeviceID           string    `json:"device_id"`
+	DeviceTimestamp    string    `json:"device_timestamp"`
+	DeviceTimestampUTC float64   `gorm:"index" json:"device_timestamp_utc"`
+	InCount            int       `json:"in_count"`
+	OutCount           int       `json:"out_count"`
+	StartTime          string    `json:"start_time"`
+	SyncStatus         bool      `json:"sync_status"`
+	StoredAt           time.Time `gorm:"index" json:"stored_at"`
+	// Data historis dari export CSV sistem lama, tidak pernah dikirim ulang ke cloud
+	Imported bool   `gorm:"index;default:false" json:"imported"`
+	ImportID string `gorm:"index" json:"import_id,omitempty"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// DeadLetterMessage is a message that failed to publish more often than the retry policy
+// allows. It is not sent again until an operator requeues it.
+type DeadLetterMessage struct {
+	ID             uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	MessageID      uint      `gorm:"index" json:"message_id"` // ID of the pending message it was moved from
+	Topic          string    `gorm:"type:text;index" json:"topic"`
+	Payload        string    `gorm:"type:text" json:"payload"`
+	IdempotencyKey string    `gorm:"size:64" json:"-"`
+	Attempts       int       `json:"attempts"`
+	LastError      string    `gorm:"type:text" json:"last_error"`
+	StoredAt       time.Time `json:"stored_at"` // When the message was stored for sending
+	FailedAt       time.Time `gorm:"index" json:"failed_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// FolderStat menyimpan statistik pemrosesan per folder tanggal, dijumlahkan setiap kali folder
+// selesai diproses. Satu baris per hari sehingga tidak ikut retensi.
+type FolderStat struct {
+	DateFolder    string `gorm:"primaryKey" json:"date_folder"`
+	Files         int64  `gorm:"not null;default:0" json:"files"`       // Files attempted, including failures
+	Entries       int64  `gorm:"not null;default:0" json:"entries"`     // Data entries queued for sending
+	Quarantined   int64  `gorm:"not null;default:0" json:"quarantined"` // Entries held back by the validation rules
+	Errors        int64  `gorm:"not null;default:0" json:"errors"`
+	DurationMs    int64  `gorm:"not null;default:0" json:"duration_ms"` // Total processing time of the files
+	MaxDurationMs int64  `gorm:"not null;default:0" json:"max_duration_ms"`
+	// Passes counts the folder passes that processed at least one file
+	Passes           int64     `gorm:"not null;default:0" json:"passes"`
+	FirstProcessedAt time.Time `json:"first_processed_at"`
+	LastProcessedAt  time.Time `gorm:"index" json:"last_processed_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// LifetimeCounter menyimpan total in/out per kamera sejak instalasi. Total ini tidak ikut
+// dihapus oleh retensi data record.
+type LifetimeCounter struct {
+	CCTVID    int       `gorm:"primaryKey;autoIncrement:false" json:"cctv_id"`
+	InTotal   int64     `gorm:"not null;default:0" json:"in_total"`
+	OutTotal  int64     `gorm:"not null;default:0" json:"out_total"`
+	Records   int64     `gorm:"not null;default:0" json:"records"`
+	FirstAt   time.Time `json:"first_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CounterMilestone mencatat kapan total pengunjung site melewati kelipatan milestone
+type CounterMilestone struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Visitors  int64     `gorm:"uniqueIndex;not null" json:"visitors"`
+	InTotal   int64     `json:"in_total"` // Site total when the milestone was detected
+	ReachedAt time.Time `gorm:"index" json:"reached_at"`
+	// Baseline marks the milestone recorded when the counter was seeded from existing data,
+	// it was reached before the counter existed and was not announced
+	Baseline  bool `json:"baseline"`
+	MessageID uint `json:"message_id,omitempty"`
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -8,11 +9,20 @@ import (
 )
 
 type Location struct {
-	ID          string  `json:"id" gorm:"type:text;primaryKey"`
-	Name        string  `gorm:"not null" json:"name"`
-	Description string  `gorm:"type:text" json:"description"`
-	CreatedAt   string  `gorm:"autoCreateTime" json:"created_at"`
-	DeletedAt   *string `json:"deleted_at,omitempty"`
+	ID            string   `json:"id" gorm:"type:text;primaryKey"`
+	Name          string   `gorm:"not null" json:"name"`
+	Description   string   `gorm:"type:text" json:"description"`
+	Address       string   `gorm:"type:text" json:"address,omitempty"`
+	Latitude      *float64 `json:"latitude,omitempty"`
+	Longitude     *float64 `json:"longitude,omitempty"`
+	FloorplanPath string   `json:"floorplan_path,omitempty"` // Floorplan image di data dir
+	ParentID      *string  `gorm:"type:text;index" json:"parent_id,omitempty"`
+	Category      string   `gorm:"type:text" json:"category,omitempty"`
+	RemoteID      string   `gorm:"type:text;index" json:"remote_id,omitempty"` // ID di backend, kosong untuk lokasi lokal
+	LocalModified bool     `json:"local_modified"`                             // Diubah lokal sejak sync terakhir
+	SyncedAt      *string  `json:"synced_at,omitempty"`
+	CreatedAt     string   `gorm:"autoCreateTime" json:"created_at"`
+	DeletedAt     *string  `json:"deleted_at,omitempty"`
 }
 
 func (p *Location) BeforeCreate(tx *gorm.DB) (err error) {
@@ -24,6 +34,25 @@ func (p *Location) BeforeCreate(tx *gorm.DB) (err error) {
 }
 
 type LocationInput struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Address     string   `json:"address"`
+	Latitude    *float64 `json:"latitude"`
+	Longitude   *float64 `json:"longitude"`
+	ParentID    *string  `json:"parent_id"`
+	Category    string   `json:"category"`
+}
+
+// ValidateCoordinates checks a latitude and longitude pair, both or neither must be set
+func ValidateCoordinates(latitude, longitude *float64) error {
+	if (latitude == nil) != (longitude == nil) {
+		return fmt.Errorf("latitude and longitude must be set together")
+	}
+	if latitude != nil && (*latitude < -90 || *latitude > 90) {
+		return fmt.Errorf("latitude %v is out of range -90 to 90", *latitude)
+	}
+	if longitude != nil && (*longitude < -180 || *longitude > 180) {
+		return fmt.Errorf("longitude %v is out of range -180 to 180", *longitude)
+	}
+	return nil
 }
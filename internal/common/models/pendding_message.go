@@ -7,14 +7,17 @@ import (
 )
 
 type PendingMessage struct {
-	ID              uint      `gorm:"primaryKey;autoIncrement"`
-	Topic           string    `gorm:"type:text;index"`
-	Payload         string    `gorm:"type:text"`
-	Timestamp       time.Time `gorm:"default:CURRENT_TIMESTAMP"`
-	Sent            bool      `gorm:"default:false;index"`
-	RetryCount      int       `gorm:"default:0"`
-	ConnectionState bool      `gorm:"default:false"`
-	ExtraInfo       string    `gorm:"type:text"`
+	ID              uint       `gorm:"primaryKey;autoIncrement"`
+	Topic           string     `gorm:"type:text;index"`
+	Payload         string     `gorm:"type:text"`
+	Timestamp       time.Time  `gorm:"default:CURRENT_TIMESTAMP"`
+	Sent            bool       `gorm:"default:false;index"`
+	RetryCount      int        `gorm:"default:0"`
+	ConnectionState bool       `gorm:"default:false"`
+	ExtraInfo       string     `gorm:"type:text"`
+	IdempotencyKey  string     `gorm:"size:64;index"` // Same content, same key, only the first is sent
+	NextAttemptAt   *time.Time `gorm:"index"`         // Not sent before, set after a failed publish
+	LastError       string     `gorm:"type:text"`
 }
 
 func (pm *PendingMessage) BeforeCreate(tx *gorm.DB) (err error) {
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// QuarantinedEntry menyimpan data counter yang melanggar aturan validasi. Data tidak dikirim
+// sampai operator melepas atau membuangnya.
+type QuarantinedEntry struct {
+	ID            uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Filename      string     `gorm:"uniqueIndex;not null" json:"filename"`
+	DateFolder    string     `gorm:"index;not null" json:"date_folder"`
+	CCTVID        int        `gorm:"index" json:"cctv_id"`
+	Entry         string     `gorm:"type:text" json:"entry"`   // Data entry as JSON
+	Reasons       string     `gorm:"type:text" json:"reasons"` // Violated rules as JSON array
+	Status        string     `gorm:"index;not null" json:"status"`
+	QuarantinedAt time.Time  `gorm:"index" json:"quarantined_at"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
+	ReviewedBy    string     `json:"reviewed_by,omitempty"`
+	MessageID     uint       `json:"message_id,omitempty"` // Message of a released entry
+}
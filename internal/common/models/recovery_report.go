@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// RecoveryReport dibuat saat service start setelah shutdown tidak bersih (crash atau listrik
+// padam), berisi data yang belum pasti terkirim dan tindakan pemulihan saat startup
+type RecoveryReport struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	DetectedAt      time.Time  `gorm:"index" json:"detected_at"`
+	PreviousStart   time.Time  `json:"previous_start"`           // Start of the run that did not stop cleanly
+	LastAlive       *time.Time `json:"last_alive,omitempty"`     // Last sign of life of that run
+	LastPublished   *time.Time `json:"last_published,omitempty"` // Last successful publish before the shutdown
+	UnfinishedFiles int        `json:"unfinished_files"`         // Files in-doubt in the sync journal
+	InDoubtMessages int        `json:"in_doubt_messages"`        // Messages being sent at the shutdown
+	PendingMessages int64      `json:"pending_messages"`         // Messages not sent yet
+	IntegrityOK     bool       `json:"integrity_ok"`
+	IntegrityResult string     `gorm:"type:text" json:"integrity_result"` // Result of PRAGMA integrity_check
+	DataAffected    bool       `json:"data_affected"`
+	Completed       bool       `json:"completed"`                 // Startup recovery finished and actions recorded
+	Published       bool       `json:"published"`                 // Report queued for MQTT
+	Detail          string     `gorm:"type:text" json:"-"`        // JSON of the files and actions
+	AcknowledgedAt  *time.Time `json:"acknowledged_at,omitempty"` // Operator has seen the report
+	AcknowledgedBy  string     `json:"acknowledged_by,omitempty"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// SyncJournal mencatat tahap pemrosesan setiap file data agar bisa dipulihkan setelah crash
+type SyncJournal struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Filename   string    `gorm:"uniqueIndex;not null" json:"filename"`
+	DateFolder string    `gorm:"not null" json:"date_folder"`
+	State      string    `gorm:"index;not null" json:"state"`
+	MessageID  uint      `json:"message_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
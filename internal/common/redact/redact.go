@@ -0,0 +1,351 @@
+// Package redact masks credentials, camera stream URLs and personal data before logs and
+// payloads leave the device. Rules match either field names of JSON payloads or patterns in
+// text, the default rules are always applied unless switched off in config.
+package redact
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+// Replacement is written in place of a redacted value
+const Replacement = "[REDACTED]"
+
+// RulesKey is the setting holding extra rules as a JSON array
+const RulesKey = "redaction_rules"
+
+// Rule masks the value of fields whose name contains Key, or every match of Pattern in
+// text. The replacement of a pattern may refer to its groups as ${1}.
+type Rule struct {
+	Name        string `json:"name"`
+	Key         string `json:"key,omitempty"`
+	Pattern     string `json:"pattern,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// DefaultRules mask credentials, RTSP URLs, e-mail addresses and phone numbers
+var DefaultRules = []Rule{
+	{Name: "password-field", Key: "password"},
+	{Name: "passwd-field", Key: "passwd"},
+	{Name: "secret-field", Key: "secret"},
+	{Name: "token-field", Key: "token"},
+	{Name: "api-key-field", Key: "api_key"},
+	{Name: "apikey-field", Key: "apikey"},
+	{Name: "authorization-field", Key: "authorization"},
+	{Name: "credential-field", Key: "credential"},
+	{Name: "rtsp-url", Pattern: `(?i)rtsps?://[^\s"'<>]+`, Replacement: "rtsp://" + Replacement},
+	{Name: "url-credentials", Pattern: `(?i)\b([a-z][a-z0-9+.-]*://)[^/\s:@"']+:[^/\s@"']+@`, Replacement: "${1}" + Replacement + "@"},
+	{Name: "bearer-token", Pattern: `(?i)\b(bearer\s+)[a-z0-9._~+/=-]+`, Replacement: "${1}" + Replacement},
+	{Name: "secret-assignment", Pattern: `(?i)\b(password|passwd|pwd|secret|token|api[_-]?key)(\s*[=:]\s*)[^\s,;&"']+`, Replacement: "${1}${2}" + Replacement},
+	{Name: "email", Pattern: `(?i)\b[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}\b`},
+	{Name: "phone-id", Pattern: `(?:\+62|\b62|\b0)8[1-9][0-9]{7,10}\b`},
+}
+
+// Engine applies a set of rules
+type Engine struct {
+	rules    []Rule
+	keys     []keyRule
+	patterns []patternRule
+}
+
+type keyRule struct {
+	name        string
+	key         string
+	replacement string
+}
+
+type patternRule struct {
+	name        string
+	re          *regexp.Regexp
+	replacement string
+}
+
+// New compiles the rules, each rule needs either a key or a pattern
+func New(rules []Rule) (*Engine, error) {
+	engine := &Engine{rules: append([]Rule(nil), rules...)}
+	for i, rule := range rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("rule %d", i+1)
+		}
+		replacement := rule.Replacement
+		if replacement == "" {
+			replacement = Replacement
+		}
+
+		switch {
+		case rule.Key != "" && rule.Pattern != "":
+			return nil, fmt.Errorf("%s: set either key or pattern, not both", name)
+		case rule.Key != "":
+			engine.keys = append(engine.keys, keyRule{name: name, key: strings.ToLower(rule.Key), replacement: replacement})
+		case rule.Pattern != "":
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid pattern: %w", name, err)
+			}
+			engine.patterns = append(engine.patterns, patternRule{name: name, re: re, replacement: replacement})
+		default:
+			return nil, fmt.Errorf("%s: key or pattern is required", name)
+		}
+	}
+	return engine, nil
+}
+
+// Rules returns the rules of the engine
+func (e *Engine) Rules() []Rule {
+	if e == nil {
+		return []Rule{}
+	}
+	return append([]Rule{}, e.rules...)
+}
+
+// String masks every pattern match in text
+func (e *Engine) String(text string) string {
+	text, _ = e.redactString(text, nil)
+	return text
+}
+
+// JSON masks a JSON payload. The payload is returned unchanged when nothing matched or it
+// is not JSON, so untouched payloads keep their exact bytes.
+func (e *Engine) JSON(payload []byte) []byte {
+	if e == nil || len(payload) == 0 || (len(e.keys) == 0 && len(e.patterns) == 0) {
+		return payload
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(payload, &value); err != nil {
+		return payload
+	}
+	redacted, changed := e.value(value, nil)
+	if !changed {
+		return payload
+	}
+	out, err := json.Marshal(redacted)
+	if err != nil {
+		return payload
+	}
+	return out
+}
+
+// Value masks a decoded JSON value in place and returns it
+func (e *Engine) Value(value interface{}) interface{} {
+	value, _ = e.value(value, nil)
+	return value
+}
+
+// Preview is the result of applying the rules to a sample
+type Preview struct {
+	Input   string   `json:"input"`
+	Output  string   `json:"output"`
+	JSON    bool     `json:"json"`
+	Matched []string `json:"matched"` // Names of the rules that changed the sample
+}
+
+// Preview applies the rules to a sample, JSON samples are masked field by field
+func (e *Engine) Preview(sample string) Preview {
+	matched := make(map[string]bool)
+	preview := Preview{Input: sample, Output: sample}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(sample), &value); err == nil {
+		preview.JSON = true
+		if redacted, changed := e.value(value, matched); changed {
+			if out, err := json.Marshal(redacted); err == nil {
+				preview.Output = string(out)
+			}
+		}
+	} else {
+		preview.Output, _ = e.redactString(sample, matched)
+	}
+
+	preview.Matched = []string{}
+	for _, rule := range e.ruleNames() {
+		if matched[rule] {
+			preview.Matched = append(preview.Matched, rule)
+		}
+	}
+	return preview
+}
+
+func (e *Engine) value(value interface{}, matched map[string]bool) (interface{}, bool) {
+	changed := false
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if rule, ok := e.matchKey(key); ok {
+				if child != rule.replacement {
+					v[key] = rule.replacement
+					changed = true
+				}
+				if matched != nil {
+					matched[rule.name] = true
+				}
+				continue
+			}
+			redacted, childChanged := e.value(child, matched)
+			if childChanged {
+				v[key] = redacted
+				changed = true
+			}
+		}
+		return v, changed
+	case []interface{}:
+		for i, child := range v {
+			redacted, childChanged := e.value(child, matched)
+			if childChanged {
+				v[i] = redacted
+				changed = true
+			}
+		}
+		return v, changed
+	case string:
+		redacted, childChanged := e.redactString(v, matched)
+		return redacted, childChanged
+	default:
+		return v, false
+	}
+}
+
+func (e *Engine) matchKey(name string) (keyRule, bool) {
+	if e == nil {
+		return keyRule{}, false
+	}
+	name = strings.ToLower(name)
+	for _, rule := range e.keys {
+		if strings.Contains(name, rule.key) {
+			return rule, true
+		}
+	}
+	return keyRule{}, false
+}
+
+func (e *Engine) redactString(text string, matched map[string]bool) (string, bool) {
+	if e == nil {
+		return text, false
+	}
+	original := text
+	for _, rule := range e.patterns {
+		if !rule.re.MatchString(text) {
+			continue
+		}
+		text = rule.re.ReplaceAllString(text, rule.replacement)
+		if matched != nil {
+			matched[rule.name] = true
+		}
+	}
+	return text, text != original
+}
+
+func (e *Engine) ruleNames() []string {
+	names := make([]string, 0, len(e.keys)+len(e.patterns))
+	for _, rule := range e.keys {
+		names = append(names, rule.name)
+	}
+	for _, rule := range e.patterns {
+		names = append(names, rule.name)
+	}
+	return names
+}
+
+// Config selects the rules of the device
+type Config struct {
+	Enabled  bool   `json:"enabled"`
+	Defaults bool   `json:"defaults"` // Apply DefaultRules before the configured rules
+	Rules    []Rule `json:"rules"`
+}
+
+// Rules returns the rules of a config followed by the stored extra rules
+func Rules(cfg Config, db *gorm.DB) ([]Rule, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	var rules []Rule
+	if cfg.Defaults {
+		rules = append(rules, DefaultRules...)
+	}
+	rules = append(rules, cfg.Rules...)
+
+	stored, err := StoredRules(db)
+	if err != nil {
+		return nil, err
+	}
+	return append(rules, stored...), nil
+}
+
+// StoredRules reads the extra rules of the settings table
+func StoredRules(db *gorm.DB) ([]Rule, error) {
+	if db == nil {
+		return nil, nil
+	}
+	var setting models.Setting
+	if err := db.Where("key = ?", RulesKey).First(&setting).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return ParseRules(setting.Value)
+}
+
+// ParseRules reads and checks rules stored as a JSON array
+func ParseRules(value string) ([]Rule, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	var rules []Rule
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return nil, fmt.Errorf("redaction rules must be a JSON array of rules: %w", err)
+	}
+	if _, err := New(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// current is the engine used by the package functions, the default rules until Load
+var current atomic.Pointer[Engine]
+
+func init() {
+	engine, err := New(DefaultRules)
+	if err != nil {
+		panic(err)
+	}
+	current.Store(engine)
+}
+
+// Load builds the engine of a config and the stored rules and makes it current. On error
+// the current engine is kept.
+func Load(cfg Config, db *gorm.DB) error {
+	rules, err := Rules(cfg, db)
+	if err != nil {
+		return err
+	}
+	engine, err := New(rules)
+	if err != nil {
+		return err
+	}
+	current.Store(engine)
+	return nil
+}
+
+// Current returns the engine in use
+func Current() *Engine {
+	return current.Load()
+}
+
+// String masks text with the current engine
+func String(text string) string {
+	return Current().String(text)
+}
+
+// JSON masks a payload with the current engine
+func JSON(payload []byte) []byte {
+	return Current().JSON(payload)
+}
@@ -0,0 +1,151 @@
+// Package residency holds the data residency mode of the site. In local-only mode counts
+// never leave the premises: the sync service stops publishing to the broker and outbound
+// API calls are refused, data is kept in the local database and exports only.
+package residency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Key is the setting key of the residency state
+const Key = "data_residency"
+
+// Residency modes
+const (
+	ModeCloud     = "cloud"
+	ModeLocalOnly = "local_only"
+)
+
+// Modes lists the valid residency modes
+var Modes = []string{ModeCloud, ModeLocalOnly}
+
+// AuditAction is the action name used for residency changes in the audit table
+const AuditAction = "data.residency"
+
+// ErrLocalOnly is returned for anything that would send data off the site in local-only mode
+var ErrLocalOnly = errors.New("local-only mode is on, data may not leave the site")
+
+// State is the residency mode and who switched it last
+type State struct {
+	Mode      string     `json:"mode"`
+	Since     *time.Time `json:"since,omitempty"`
+	ChangedBy string     `json:"changed_by,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+// LocalOnly reports whether data must stay on the site
+func (s State) LocalOnly() bool {
+	return s.Mode == ModeLocalOnly
+}
+
+// Validate checks the mode name
+func Validate(mode string) error {
+	for _, valid := range Modes {
+		if mode == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown residency mode %q, must be %s or %s", mode, ModeCloud, ModeLocalOnly)
+}
+
+// Get returns the stored state, a site without one publishes to the cloud
+func Get(db *gorm.DB) State {
+	var setting models.Setting
+	if err := db.Where("key = ?", Key).First(&setting).Error; err != nil || setting.Value == "" {
+		return State{Mode: ModeCloud}
+	}
+
+	var state State
+	if json.Unmarshal([]byte(setting.Value), &state) != nil || Validate(state.Mode) != nil {
+		return State{Mode: ModeCloud}
+	}
+	return state
+}
+
+// Set switches the mode and writes the audit entry in the same transaction. Setting the
+// current mode again changes nothing and is not audited.
+func Set(db *gorm.DB, mode, actor, source, reason string) (State, error) {
+	if err := Validate(mode); err != nil {
+		return State{}, err
+	}
+
+	previous := Get(db)
+	if previous.Mode == mode {
+		return previous, nil
+	}
+
+	now := time.Now()
+	next := State{Mode: mode, Since: &now, ChangedBy: actor, Reason: reason}
+	value, err := json.Marshal(next)
+	if err != nil {
+		return State{}, err
+	}
+	detail, err := json.Marshal(map[string]string{"old": previous.Mode, "new": mode, "reason": reason})
+	if err != nil {
+		return State{}, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var setting models.Setting
+		result := tx.Where("key = ?", Key).First(&setting)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			if err := tx.Create(&models.Setting{Key: Key, Value: string(value)}).Error; err != nil {
+				return err
+			}
+		} else if result.Error != nil {
+			return result.Error
+		} else {
+			setting.Value = string(value)
+			if err := tx.Save(&setting).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&models.AuditEntry{
+			Timestamp: now,
+			Action:    AuditAction,
+			Actor:     actor,
+			Source:    source,
+			Detail:    string(detail),
+		}).Error
+	})
+	if err != nil {
+		return State{}, err
+	}
+
+	localOnly.Store(next.LocalOnly())
+	return next, nil
+}
+
+// GetAudit returns the latest residency audit entries, newest first
+func GetAudit(db *gorm.DB, limit int) ([]models.AuditEntry, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	var entries []models.AuditEntry
+	err := db.Where("action = ?", AuditAction).Order("id DESC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+// localOnly mirrors the stored mode for code without database access, like HTTP transports
+var localOnly atomic.Bool
+
+// LocalOnly reports the mode last read by Refresh or written by Set in this process
+func LocalOnly() bool {
+	return localOnly.Load()
+}
+
+// Refresh reads the stored mode into the process-wide flag and returns it
+func Refresh(db *gorm.DB) State {
+	state := Get(db)
+	localOnly.Store(state.LocalOnly())
+	return state
+}
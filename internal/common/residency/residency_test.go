@@ -0,0 +1,78 @@
+package residency
+
+import (
+	"jarvist/internal/common/models"
+	"jarvist/internal/testutil"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db := testutil.OpenDB(t, &models.Setting{}, &models.AuditEntry{})
+	t.Cleanup(func() { localOnly.Store(false) })
+	return db
+}
+
+func TestSetSwitchesModeWithAudit(t *testing.T) {
+	db := openTestDB(t)
+
+	if state := Refresh(db); state.Mode != ModeCloud || LocalOnly() {
+		t.Fatalf("default state = %+v, want cloud", state)
+	}
+
+	state, err := Set(db, ModeLocalOnly, "admin", "desktop", "customer policy")
+	if err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if !state.LocalOnly() || state.Since == nil || state.ChangedBy != "admin" {
+		t.Errorf("state = %+v", state)
+	}
+	if !LocalOnly() {
+		t.Errorf("process flag not updated")
+	}
+	if stored := Get(db); stored.Mode != ModeLocalOnly || stored.Reason != "customer policy" {
+		t.Errorf("stored state = %+v", stored)
+	}
+
+	// Mode yang sama tidak dicatat ulang
+	if _, err := Set(db, ModeLocalOnly, "admin", "desktop", "again"); err != nil {
+		t.Fatalf("set again: %v", err)
+	}
+
+	entries, err := GetAudit(db, 10)
+	if err != nil {
+		t.Fatalf("audit: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d audit entries, want 1", len(entries))
+	}
+	if entries[0].Actor != "admin" || entries[0].Source != "desktop" {
+		t.Errorf("audit entry = %+v", entries[0])
+	}
+}
+
+func TestSetRejectsUnknownMode(t *testing.T) {
+	db := openTestDB(t)
+
+	if _, err := Set(db, "offline", "admin", "desktop", ""); err == nil {
+		t.Fatalf("unknown mode accepted")
+	}
+
+	var count int64
+	db.Model(&models.AuditEntry{}).Count(&count)
+	if count != 0 {
+		t.Errorf("rejected switch was audited")
+	}
+}
+
+func TestInvalidStoredStateFallsBackToCloud(t *testing.T) {
+	db := openTestDB(t)
+	db.Create(&models.Setting{Key: Key, Value: `{"mode":"somewhere"}`})
+
+	if state := Get(db); state.Mode != ModeCloud {
+		t.Errorf("state = %+v, want cloud", state)
+	}
+}
@@ -0,0 +1,187 @@
+// Package snapshot keeps the last known frame of each camera. The desktop app captures a
+// small thumbnail while a camera is online, the sync service attaches it to the camera
+// offline alert so the NOC sees the last frame without connecting to the site.
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PolicyKey is the setting key of the snapshot policy, shared by the desktop app and the
+// sync service
+const PolicyKey = "camera_snapshot_policy"
+
+// Attachment modes: inline embeds the thumbnail as base64, url only sends a link built
+// from the URL template
+const (
+	ModeInline = "inline"
+	ModeURL    = "url"
+)
+
+// Limits of the snapshot policy
+const (
+	minWidth    = 64
+	maxWidth    = 640
+	maxBytes    = 256 << 10
+	maxAgeLimit = 7 * 24 * 60
+)
+
+// Policy controls whether and how thumbnails are captured and attached. Snapshots show
+// the site, so they are off until enabled and cameras can be excluded.
+type Policy struct {
+	Enabled    bool   `json:"enabled"`
+	Mode       string `json:"mode"`        // inline or url
+	Width      int    `json:"width"`       // Thumbnail width in pixels, the height keeps the aspect ratio
+	MaxBytes   int    `json:"max_bytes"`   // Larger thumbnails are not attached
+	MaxAgeMin  int    `json:"max_age_min"` // Older thumbnails are not attached
+	RefreshMin int    `json:"refresh_min"` // How often an online camera is captured again
+	// URLTemplate builds the link in url mode, {camera_uuid} is replaced
+	URLTemplate string `json:"url_template,omitempty"`
+	// SkipOnMetered leaves the inline thumbnail out while uploads are paused
+	SkipOnMetered bool `json:"skip_on_metered"`
+	// ExcludedCameras are camera UUIDs that are never captured or attached
+	ExcludedCameras []string `json:"excluded_cameras"`
+}
+
+// DefaultPolicy returns the policy used until one is saved
+func DefaultPolicy() Policy {
+	return Policy{
+		Enabled:         false,
+		Mode:            ModeInline,
+		Width:           320,
+		MaxBytes:        32 << 10,
+		MaxAgeMin:       60,
+		RefreshMin:      15,
+		SkipOnMetered:   true,
+		ExcludedCameras: []string{},
+	}
+}
+
+// Validate checks the policy against the limits
+func (p Policy) Validate() error {
+	switch {
+	case p.Mode != ModeInline && p.Mode != ModeURL:
+		return errors.New("mode must be inline or url")
+	case p.Mode == ModeURL && !strings.Contains(p.URLTemplate, "{camera_uuid}"):
+		return errors.New("url_template must contain {camera_uuid} in url mode")
+	case p.Width < minWidth || p.Width > maxWidth:
+		return fmt.Errorf("width must be between %d and %d", minWidth, maxWidth)
+	case p.MaxBytes < 1024 || p.MaxBytes > maxBytes:
+		return fmt.Errorf("max_bytes must be between 1024 and %d", maxBytes)
+	case p.MaxAgeMin < 1 || p.MaxAgeMin > maxAgeLimit:
+		return fmt.Errorf("max_age_min must be between 1 and %d", maxAgeLimit)
+	case p.RefreshMin < 1 || p.RefreshMin > p.MaxAgeMin:
+		return errors.New("refresh_min must be between 1 and max_age_min")
+	}
+	return nil
+}
+
+// Allows reports whether a camera may be captured and attached
+func (p Policy) Allows(cameraUUID string) bool {
+	return p.Enabled && cameraUUID != "" && !slices.Contains(p.ExcludedCameras, cameraUUID)
+}
+
+// URL returns the link of a camera snapshot in url mode
+func (p Policy) URL(cameraUUID string) string {
+	return strings.ReplaceAll(p.URLTemplate, "{camera_uuid}", cameraUUID)
+}
+
+// LoadPolicy reads the stored policy, an invalid policy falls back to the default
+func LoadPolicy(db *gorm.DB) Policy {
+	policy := DefaultPolicy()
+
+	var setting models.Setting
+	if err := db.Where("key = ?", PolicyKey).First(&setting).Error; err != nil || setting.Value == "" {
+		return policy
+	}
+
+	stored := DefaultPolicy()
+	if json.Unmarshal([]byte(setting.Value), &stored) != nil || stored.Validate() != nil {
+		return policy
+	}
+	if stored.ExcludedCameras == nil {
+		stored.ExcludedCameras = []string{}
+	}
+	return stored
+}
+
+// SavePolicy validates and stores the policy. Disabling it deletes the stored thumbnails.
+func SavePolicy(db *gorm.DB, policy Policy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+
+	if err := saveSetting(db, PolicyKey, string(value)); err != nil {
+		return err
+	}
+
+	// Thumbnail kamera yang dikecualikan atau fitur yang dimatikan tidak disimpan lagi
+	if !policy.Enabled {
+		return db.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&models.CameraSnapshot{}).Error
+	}
+	if len(policy.ExcludedCameras) > 0 {
+		return db.Where("camera_uuid IN ?", policy.ExcludedCameras).Delete(&models.CameraSnapshot{}).Error
+	}
+	return nil
+}
+
+// Store saves the latest thumbnail of a camera, replacing the previous one
+func Store(db *gorm.DB, cameraUUID string, data []byte, capturedAt time.Time) error {
+	return db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&models.CameraSnapshot{
+		CameraUUID:  cameraUUID,
+		ContentType: "image/jpeg",
+		Data:        data,
+		Size:        len(data),
+		CapturedAt:  capturedAt,
+	}).Error
+}
+
+// Latest returns the stored thumbnail of a camera, nil when there is none
+func Latest(db *gorm.DB, cameraUUID string) (*models.CameraSnapshot, error) {
+	var snapshot models.CameraSnapshot
+	err := db.Where("camera_uuid = ?", cameraUUID).First(&snapshot).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// CapturedAt returns when the stored thumbnail of a camera was taken, zero when there is none
+func CapturedAt(db *gorm.DB, cameraUUID string) time.Time {
+	var snapshot models.CameraSnapshot
+	if err := db.Select("captured_at").Where("camera_uuid = ?", cameraUUID).First(&snapshot).Error; err != nil {
+		return time.Time{}
+	}
+	return snapshot.CapturedAt
+}
+
+func saveSetting(db *gorm.DB, key, value string) error {
+	var setting models.Setting
+	result := db.Where("key = ?", key).First(&setting)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return db.Create(&models.Setting{Key: key, Value: value}).Error
+	} else if result.Error != nil {
+		return result.Error
+	}
+
+	setting.Value = value
+	return db.Save(&setting).Error
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"fmt"
+	"jarvist/internal/syncmanager/sync"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// prometheusContentType is the version 0.0.4 text format read by Prometheus
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metricsWriter writes metrics in the Prometheus text format
+type metricsWriter struct {
+	b strings.Builder
+}
+
+// family starts a metric with its help text and type
+func (w *metricsWriter) family(name, kind, help string) {
+	fmt.Fprintf(&w.b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes one value, labels are given as name and value pairs
+func (w *metricsWriter) sample(name string, value float64, labels ...string) {
+	w.b.WriteString(name)
+	if len(labels) > 0 {
+		w.b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.b.WriteByte(',')
+			}
+			fmt.Fprintf(&w.b, "%s=\"%s\"", labels[i], escapeLabel(labels[i+1]))
+		}
+		w.b.WriteByte('}')
+	}
+	w.b.WriteByte(' ')
+	w.b.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	w.b.WriteByte('\n')
+}
+
+// gauge writes a metric with a single unlabelled value
+func (w *metricsWriter) gauge(name, help string, value float64) {
+	w.family(name, "gauge", help)
+	w.sample(name, value)
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// getPrometheusMetrics exports the agent health for Prometheus: published messages, publish
+// failures, queue depths, file processing latency and the connection state. Counters start
+// at zero with the service and after a reset of the publish metrics.
+func (s *Server) getPrometheusMetrics(c *fiber.Ctx) error {
+	snapshot, err := s.mqttSender.StatusSnapshot()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to read sender status: "+err.Error())
+	}
+	deadLetters, err := s.messageService.CountDeadLetters()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	live := s.synchronizer.GetLiveStatus()
+	processing := s.synchronizer.GetProcessingMetrics()
+	topics := s.mqttSender.PublishMetricsSnapshot()
+
+	w := &metricsWriter{}
+
+	w.family("jarvist_messages_published_total", "counter", "Messages published successfully, by topic prefix.")
+	for _, topic := range topics {
+		w.sample("jarvist_messages_published_total", float64(topic.Successes), "prefix", topic.Prefix)
+	}
+	w.family("jarvist_publish_attempts_total", "counter", "Publish attempts, by topic prefix.")
+	for _, topic := range topics {
+		w.sample("jarvist_publish_attempts_total", float64(topic.Attempts), "prefix", topic.Prefix)
+	}
+	w.family("jarvist_publish_failures_total", "counter", "Failed publishes, by topic prefix and cause.")
+	for _, topic := range topics {
+		causes := make([]string, 0, len(topic.FailureCauses))
+		for cause := range topic.FailureCauses {
+			causes = append(causes, cause)
+		}
+		sort.Strings(causes)
+		for _, cause := range causes {
+			w.sample("jarvist_publish_failures_total", float64(topic.FailureCauses[cause]), "prefix", topic.Prefix, "cause", cause)
+		}
+	}
+	w.family("jarvist_publish_retries_total", "counter", "Messages queued again after a failed publish, by topic prefix.")
+	for _, topic := range topics {
+		w.sample("jarvist_publish_retries_total", float64(topic.Retries), "prefix", topic.Prefix)
+	}
+
+	w.gauge("jarvist_pending_messages", "Stored messages not sent yet.", float64(snapshot.Pending))
+	w.gauge("jarvist_deferred_messages", "Pending messages held back by the upload pause, maintenance or license.", float64(snapshot.Deferred))
+	w.gauge("jarvist_queued_messages", "Messages in the in-memory send queues.", float64(snapshot.InMemory))
+	w.gauge("jarvist_dead_letter_messages", "Messages moved to the dead letter table.", float64(deadLetters))
+
+	w.family("jarvist_transport_connected", "gauge", "Whether the sender is connected, labelled with the transport in use.")
+	w.sample("jarvist_transport_connected", boolValue(snapshot.Connected), "transport", s.mqttSender.GetTransportStatus().Transport)
+	w.gauge("jarvist_upload_paused", "Whether uploads are paused.", boolValue(snapshot.UploadPaused))
+
+	w.gauge("jarvist_pending_files", "Data files waiting to be processed.", float64(live.PendingFiles))
+	w.gauge("jarvist_sync_in_progress", "Whether a folder sync is running.", boolValue(live.InSyncProcess))
+	w.gauge("jarvist_watcher_active", "Whether the data folder watcher is running.", boolValue(live.WatcherActive))
+	if live.LastSyncedAt != nil {
+		w.gauge("jarvist_last_file_processed_timestamp_seconds", "Unix time the last data file was processed.", float64(live.LastSyncedAt.Unix()))
+	}
+
+	w.family("jarvist_files_processed_total", "counter", "Data files processed, by outcome.")
+	outcomes := make([]string, 0, len(processing.Files))
+	for outcome := range processing.Files {
+		outcomes = append(outcomes, outcome)
+	}
+	sort.Strings(outcomes)
+	for _, outcome := range outcomes {
+		w.sample("jarvist_files_processed_total", float64(processing.Files[outcome]), "outcome", outcome)
+	}
+
+	w.family("jarvist_file_processing_seconds", "histogram", "Time to process one data file.")
+	for i, bound := range sync.FileLatencyBuckets {
+		w.sample("jarvist_file_processing_seconds_bucket", float64(processing.BucketCounts[i]), "le", strconv.FormatFloat(bound, 'g', -1, 64))
+	}
+	w.sample("jarvist_file_processing_seconds_bucket", float64(processing.Count), "le", "+Inf")
+	w.sample("jarvist_file_processing_seconds_sum", processing.LatencySeconds)
+	w.sample("jarvist_file_processing_seconds_count", float64(processing.Count))
+
+	c.Set(fiber.HeaderContentType, prometheusContentType)
+	return c.SendString(w.b.String())
+}
@@ -1,15 +1,44 @@
 package api
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"jarvist/internal/common/camerahealth"
+	baseConfig "jarvist/internal/common/config"
+	"jarvist/internal/common/database"
+	"jarvist/internal/common/identity"
 	"jarvist/internal/common/models"
+	"jarvist/internal/common/redact"
+	"jarvist/internal/common/residency"
+	"jarvist/internal/syncmanager/apikeys"
+	"jarvist/internal/syncmanager/apiport"
+	"jarvist/internal/syncmanager/apisession"
+	"jarvist/internal/syncmanager/components"
 	"jarvist/internal/syncmanager/config"
+	"jarvist/internal/syncmanager/fleetstatus"
+	"jarvist/internal/syncmanager/maintmode"
 	"jarvist/internal/syncmanager/mqtt"
+	"jarvist/internal/syncmanager/network"
+	"jarvist/internal/syncmanager/power"
+	"jarvist/internal/syncmanager/preflight"
+	"jarvist/internal/syncmanager/recovery"
+	"jarvist/internal/syncmanager/scheduler"
 	"jarvist/internal/syncmanager/services/cleanup"
+	"jarvist/internal/syncmanager/services/integrity"
 	"jarvist/internal/syncmanager/services/log"
 	"jarvist/internal/syncmanager/services/message"
 	"jarvist/internal/syncmanager/services/stats"
+	"jarvist/internal/syncmanager/settingschema"
+	"jarvist/internal/syncmanager/snapshots"
+	"jarvist/internal/syncmanager/startup"
 	"jarvist/internal/syncmanager/sync"
+	"jarvist/internal/syncmanager/tracing"
+	"jarvist/internal/syncmanager/uptime"
+	"jarvist/internal/syncmanager/verify"
+	"jarvist/internal/syncmanager/watchdog"
 	"jarvist/pkg/logger"
 	"strconv"
 	"strings"
@@ -32,6 +61,21 @@ type Server struct {
 	statsService   *stats.StatsService
 	logService     *log.LogService
 	cleanupService *cleanup.CleanupService
+	networkMonitor *network.Monitor
+	integrity      *integrity.IntegrityService
+	components     *components.Registry
+	powerMonitor   *power.Monitor
+	watchdog       *watchdog.Watchdog
+	scheduler      *scheduler.Scheduler
+	maintenance    *maintmode.Manager
+	snapshots      *snapshots.Store
+	sessions       *apisession.Manager
+	apiKeys        *apikeys.Manager
+	verifier       *verify.Verifier
+	tracer         *tracing.Tracer
+	statusDoc      *fleetstatus.Publisher
+	recovery       *recovery.Recovery
+	endpoint       baseConfig.SyncEndpoint
 }
 
 type LogRequest struct {
@@ -64,8 +108,22 @@ func NewServer(
 	statsService *stats.StatsService,
 	logService *log.LogService,
 	cleanupService *cleanup.CleanupService,
+	networkMonitor *network.Monitor,
+	integrityService *integrity.IntegrityService,
+	componentRegistry *components.Registry,
+	powerMonitor *power.Monitor,
+	serviceWatchdog *watchdog.Watchdog,
+	jobScheduler *scheduler.Scheduler,
+	maintenanceMode *maintmode.Manager,
+	snapshotStore *snapshots.Store,
+	verifier *verify.Verifier,
+	tracer *tracing.Tracer,
+	statusPublisher *fleetstatus.Publisher,
+	recoveryReporter *recovery.Recovery,
 ) *Server {
 	app := fiber.New(fiber.Config{
+		// Lebih besar dari default 4 MB untuk import CSV data historis
+		BodyLimit: 32 * 1024 * 1024,
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			code := fiber.StatusInternalServerError
 
@@ -85,9 +143,31 @@ func NewServer(
 	app.Use(fiberLog.New(fiberLog.Config{
 		Format: "[${time}] ${status} - ${method} ${path} ${latency}\n",
 	}))
+	app.Use(tracingMiddleware())
+
+	sessions := apisession.New(apisession.Config{
+		TTL:         time.Duration(cfg.API.SessionTTLMin) * time.Minute,
+		IdleTimeout: time.Duration(cfg.API.SessionIdleMin) * time.Minute,
+		RateLimit:   cfg.API.SessionRateLimit,
+		MaxSessions: cfg.API.MaxSessions,
+	}, func() (string, string) {
+		return cfg.API.Username, cfg.API.Password
+	}, logger)
+
+	// Monitoring tools with a diagnostics key only reach the read-only endpoints
+	keys := apikeys.New(database.GetDB(), logger)
+	app.Use(apiKeyMiddleware(keys))
+
+	// Viewers with a session token skip basic auth, their requests count against the rate
+	// limit of the session
+	app.Use(sessionMiddleware(sessions))
 
 	if cfg.API.Username != "" && cfg.API.Password != "" {
 		app.Use(basicauth.New(basicauth.Config{
+			// Health is polled by the desktop app and update flow without credentials
+			Next: func(c *fiber.Ctx) bool {
+				return c.Path() == "/api/health" || c.Locals(localSession) != nil || c.Locals(localAPIKey) != nil
+			},
 			Users: map[string]string{
 				cfg.API.Username: cfg.API.Password,
 			},
@@ -103,6 +183,21 @@ func NewServer(
 		messageService: messageService,
 		statsService:   statsService,
 		logService:     logService,
+		cleanupService: cleanupService,
+		networkMonitor: networkMonitor,
+		integrity:      integrityService,
+		components:     componentRegistry,
+		powerMonitor:   powerMonitor,
+		watchdog:       serviceWatchdog,
+		scheduler:      jobScheduler,
+		maintenance:    maintenanceMode,
+		snapshots:      snapshotStore,
+		sessions:       sessions,
+		apiKeys:        keys,
+		verifier:       verifier,
+		tracer:         tracer,
+		statusDoc:      statusPublisher,
+		recovery:       recoveryReporter,
 	}
 
 	server.registerRoutes()
@@ -110,16 +205,49 @@ func NewServer(
 	return server
 }
 
-// Start starts the API server
+// Start starts the API server. When the port is taken the server moves to the fallback
+// range if enabled, the chosen port or the conflict is recorded for the desktop app.
 func (s *Server) Start(port int) error {
-	addr := fmt.Sprintf(":%d", port)
-	s.logger.Info("API", "Starting API server on %s", addr)
+	listener, endpoint, err := apiport.Listen(s.cfg, port)
+	s.endpoint = endpoint
+
+	if endpoint.Conflict != nil || errors.Is(err, apiport.ErrPortInUse) {
+		owner := "an unknown process"
+		if endpoint.Conflict != nil {
+			owner = endpoint.Conflict.String()
+		}
+		if endpoint.Fallback {
+			s.logger.Event(logger.LevelWarn, "API", logger.EventAPIPortFallback,
+				logger.F("configured", port), logger.F("owner", owner), logger.F("port", endpoint.Port))
+		} else {
+			s.logger.Event(logger.LevelError, "API", logger.EventAPIPortConflict,
+				logger.F("port", port), logger.F("owner", owner))
+		}
+	}
+
+	if saveErr := baseConfig.SaveSyncEndpoint(endpoint); saveErr != nil {
+		s.logger.Warning("API", "Failed to record API endpoint: %v", saveErr)
+	}
+
+	if err != nil {
+		return err
+	}
 
-	if s.cfg.API.EnableTLS && s.cfg.API.CertFile != "" && s.cfg.API.KeyFile != "" {
-		return s.app.ListenTLS(addr, s.cfg.API.CertFile, s.cfg.API.KeyFile)
+	s.logger.Info("API", "Starting API server on %s", listener.Addr())
+
+	if endpoint.TLS {
+		cert, err := tls.LoadX509KeyPair(s.cfg.API.CertFile, s.cfg.API.KeyFile)
+		if err != nil {
+			listener.Close()
+			return fmt.Errorf("tls: cannot load TLS key pair: %w", err)
+		}
+		listener = tls.NewListener(listener, &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{cert},
+		})
 	}
 
-	return s.app.Listen(addr)
+	return s.app.Listener(listener)
 }
 
 // Stop stops the API server
@@ -151,22 +279,129 @@ func (s *Server) Stop() error {
 
 // registerRoutes sets up all API routes
 func (s *Server) registerRoutes() {
+	// Prometheus scrapes the default path, with basic auth or a diagnostics key
+	s.app.Get("/metrics", s.getPrometheusMetrics)
+
 	// API version group
 	api := s.app.Group("/api")
 
 	// Status endpoints
 	api.Get("/status", s.getStatus)
+	api.Get("/status/document", s.getStatusDocument)
+	api.Get("/status/schema", s.getStatusSchema)
 	api.Get("/health", s.getHealth)
+	api.Get("/health/database", s.getDatabaseHealth)
+	api.Get("/preflight", s.getPreflight)
+	api.Get("/network", s.getNetworkStatus)
+	api.Post("/network/check", s.checkNetwork)
+	api.Get("/power", s.getPowerStatus)
+	api.Get("/watchdog", s.getWatchdogStatus)
+	api.Get("/endpoint", s.getEndpoint)
+	api.Get("/tracing", s.getTracingStatus)
+	api.Post("/tracing/refresh", s.refreshTracing)
+	api.Get("/startup", s.getStartup)
+	api.Put("/startup", s.updateStartupConditions)
+	api.Get("/bandwidth", s.getBandwidthUsage)
+	api.Get("/metrics", s.getPublishMetrics)
+	api.Post("/metrics/reset", s.resetPublishMetrics)
+
+	// Device identity used in payloads and topics
+	identityGroup := api.Group("/identity")
+	identityGroup.Get("/", s.getIdentity)
+	identityGroup.Put("/", s.updateIdentity)
+	identityGroup.Post("/validate", s.validateIdentity)
+	identityGroup.Get("/audit", s.getIdentityAudit)
+
+	// Data residency, switched in the desktop app with the admin PIN and read-only here
+	api.Get("/residency", s.getResidency)
+
+	// Maintenance mode, pauses publishing and alerts until it expires
+	maintenanceGroup := api.Group("/maintenance")
+	maintenanceGroup.Get("/", s.getMaintenance)
+	maintenanceGroup.Post("/", s.enableMaintenance)
+	maintenanceGroup.Delete("/", s.disableMaintenance)
+
+	// Site registration, data is published once the backend accepted the site
+	registrationGroup := api.Group("/registration")
+	registrationGroup.Get("/", s.getRegistration)
+	registrationGroup.Post("/retry", s.retryRegistration)
+	registrationGroup.Post("/skip", s.skipRegistration)
+
+	// Viewer sessions, several dashboards can watch the device with their own rate limit
+	sessionGroup := api.Group("/sessions")
+	sessionGroup.Post("/", s.createSession)
+	sessionGroup.Get("/", s.getSessions)
+	sessionGroup.Delete("/", s.revokeSessions)
+	sessionGroup.Delete("/current", s.logoutSession)
+	sessionGroup.Delete("/:id", s.revokeSession)
+
+	// Read-only diagnostics keys for third-party monitoring
+	keyGroup := api.Group("/keys")
+	keyGroup.Post("/", s.issueAPIKey)
+	keyGroup.Get("/", s.getAPIKeys)
+	keyGroup.Delete("/:id", s.revokeAPIKey)
+
+	// Redaction of payloads and logs leaving the device, preview shows what a sample becomes
+	api.Get("/redaction", s.getRedaction)
+	api.Post("/redaction/preview", s.previewRedaction)
+
+	// Recovery reports written after an unclean shutdown
+	recoveryGroup := api.Group("/recovery")
+	recoveryGroup.Get("/", s.getRecoveryReports)
+	recoveryGroup.Post("/:id/acknowledge", s.acknowledgeRecoveryReport)
+
+	// Installation verification, a smoke test the installer runs and signs off
+	verifyGroup := api.Group("/verify")
+	verifyGroup.Get("/", s.getVerification)
+	verifyGroup.Post("/", s.runVerification)
+	verifyGroup.Post("/signoff", s.signOffVerification)
+
+	// Camera alerts from the desktop app, offline alerts carry the last known frame
+	api.Post("/alerts/camera", s.createCameraAlert)
+	api.Post("/reports/camera-health", s.createCameraHealthReport)
+	api.Get("/cameras/:uuid/snapshot", s.getCameraSnapshot)
+
+	// Upload pause on metered connections
+	uploads := api.Group("/uploads")
+	uploads.Get("/policy", s.getUploadPolicy)
+	uploads.Put("/policy", s.updateUploadPolicy)
 
 	// Synchronizer endpoints
 	sync := api.Group("/sync")
 	sync.Get("/status", s.getSyncStatus)
+	sync.Get("/live", s.getSyncLive)
 	sync.Post("/start", s.startSync)
 	sync.Get("/folders", s.getSyncFolders)
+	sync.Get("/folders/stats", s.getFolderStats)
 	sync.Post("/folders/:folder/resync", s.resyncFolder)
 	sync.Post("/summary", s.sendSyncSummary)
 	sync.Get("/files/:folder/:filename", s.getFileStatus) // Added endpoint for file status
 
+	// Replay of already-sent data by date range
+	replay := api.Group("/replay")
+	replay.Post("/", s.startReplay)
+	replay.Get("/status", s.getReplayStatus)
+	replay.Post("/cancel", s.cancelReplay)
+
+	// Cached data of sent files
+	data := api.Group("/data")
+	data.Get("/", s.queryData)
+	data.Get("/summary", s.getDataSummary)
+	data.Get("/series", s.getDataSeries)
+	data.Get("/lifetime", s.getLifetimeTotals)
+	data.Get("/completeness", s.getDataCompleteness)
+	data.Get("/gaps", s.getDataGaps)
+	data.Get("/gaps/report", s.getDataGapReport)
+	data.Post("/gaps/:id/backfill", s.requestGapBackfill)
+	data.Post("/import", s.importData)
+	data.Get("/imports", s.getDataImports)
+	data.Delete("/imports/:id", s.deleteDataImport)
+	data.Get("/validation", s.getValidationRules)
+	data.Put("/validation", s.updateValidationRules)
+	data.Get("/quarantine", s.getQuarantine)
+	data.Post("/quarantine/:id/release", s.releaseQuarantined)
+	data.Post("/quarantine/:id/discard", s.discardQuarantined)
+
 	// MQTT endpoints
 	mqtt := api.Group("/mqtt")
 	mqtt.Get("/status", s.getMQTTStatus)
@@ -175,6 +410,16 @@ func (s *Server) registerRoutes() {
 	mqtt.Post("/queue/drain", s.drainQueue)
 	mqtt.Get("/stats", s.getMQTTStats)
 	mqtt.Post("/refresh", s.refreshMQTT)
+	mqtt.Get("/tuning", s.getSenderTuning)
+	mqtt.Put("/tuning", s.updateSenderTuning)
+	mqtt.Get("/session", s.getMQTTSession)
+	mqtt.Put("/session", s.updateMQTTSession)
+	mqtt.Get("/transport", s.getTransport)
+	mqtt.Get("/dead-letters", s.getDeadLetters)
+	mqtt.Post("/dead-letters/requeue", s.requeueDeadLetters)
+	mqtt.Post("/dead-letters/:id/requeue", s.requeueDeadLetter)
+	mqtt.Get("/payload-log", s.getPayloadLog)
+	mqtt.Put("/payload-log", s.updatePayloadLog)
 
 	// Message endpoints
 	messages := api.Group("/messages")
@@ -186,131 +431,1564 @@ func (s *Server) registerRoutes() {
 	logs.Get("/", s.getLogs)
 	logs.Post("/", s.createLog)
 	logs.Post("/batch", s.createBatchLogs)
+	logs.Get("/search", s.searchLogs)
 	logs.Get("/stats", s.getLogStats)
+	logs.Get("/events", s.getLogEvents)
+	logs.Post("/reopen", s.reopenLogFile)
+	logs.Post("/rotate", s.rotateLogFile)
+
+	// Settings table checked against the schema, secrets masked and changes audited
+	settingsGroup := api.Group("/settings")
+	settingsGroup.Get("/", s.getSettings)
+	settingsGroup.Put("/", s.updateSettings)
+	settingsGroup.Get("/audit", s.getSettingsAudit)
+
+	// Sync settings edited from the desktop app, applied without restarting the service
+	settingsGroup.Get("/sync", s.getSyncSettings)
+	settingsGroup.Put("/sync", s.updateSyncSettings)
+	settingsGroup.Post("/sync/apply", s.applySyncSettings)
 
 	cleanupGroup := api.Group("/cleanup")
 	cleanupGroup.Get("/status", s.getCleanupStatus)
+	cleanupGroup.Get("/preview", s.previewCleanup)
 	cleanupGroup.Post("/run", s.runCleanup)
 	cleanupGroup.Put("/config", s.updateCleanupConfig)
+
+	// Integrity scanner endpoints
+	integrityGroup := api.Group("/integrity")
+	integrityGroup.Get("/status", s.getIntegrityStatus)
+	integrityGroup.Post("/scan", s.runIntegrityScan)
+
+	// Restart of individual components
+	componentsGroup := api.Group("/components")
+	componentsGroup.Get("/", s.getComponents)
+	componentsGroup.Post("/:name/restart", s.restartComponent)
+
+	// Scheduled jobs
+	jobsGroup := api.Group("/jobs")
+	jobsGroup.Get("/", s.getJobs)
+	jobsGroup.Get("/runs/:id", s.getJobRun)
+	jobsGroup.Get("/:name", s.getJob)
+	jobsGroup.Post("/:name/run", s.runJob)
+}
+
+// getStatus returns the overall system status
+func (s *Server) getStatus(c *fiber.Ctx) error {
+	serviceUptime := uptime.Get()
+
+	status := map[string]interface{}{
+		"service":         "running",
+		"time":            time.Now().Format(time.RFC3339),
+		"uptime":          serviceUptime.Uptime,
+		"uptime_seconds":  serviceUptime.UptimeSeconds,
+		"started_at":      serviceUptime.StartedAt.Format(time.RFC3339),
+		"components":      serviceUptime.Components,
+		"startup":         startup.Get(),
+		"mqtt":            s.mqttSender.GetStatus(),
+		"version":         s.cfg.BaseConfig.BuildInfo,
+		"network":         s.networkMonitor.GetStatus(),
+		"integrity":       s.integrity.GetStatus(),
+		"viewers":         s.sessions.Summary(false),
+		"tracing":         s.tracer.GetStatus(),
+		"status_document": s.statusDoc.GetStatus(),
+	}
+
+	// Laporan recovery yang belum dilihat operator, nil kalau tidak ada
+	if report, err := s.recovery.Unacknowledged(); err == nil {
+		status["recovery"] = report
+	}
+
+	return c.JSON(status)
+}
+
+// getStatusDocument returns the fleet status document as it would be published now
+func (s *Server) getStatusDocument(c *fiber.Ctx) error {
+	doc, err := s.statusDoc.Build()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(doc)
+}
+
+// getStatusSchema returns the JSON schema of the fleet status document
+func (s *Server) getStatusSchema(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, "application/schema+json")
+	return c.Send(fleetstatus.Schema)
+}
+
+// getHealth returns a simple health check response
+func (s *Server) getHealth(c *fiber.Ctx) error {
+	networkStatus := s.networkMonitor.GetStatus()
+
+	return c.JSON(fiber.Map{
+		"status":           "ok",
+		"time":             time.Now().Format(time.RFC3339),
+		"network_state":    networkStatus.State,
+		"broker_reachable": networkStatus.BrokerReachable,
+		"quarantined":      s.integrity.GetCounts(),
+	})
+}
+
+// getDatabaseHealth returns the SQLite pragmas in effect, the connection pool and the lock
+// errors seen since the service started
+func (s *Server) getDatabaseHealth(c *fiber.Ctx) error {
+	health, err := database.GetHealth()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	// Another process can hold the file in a different journal mode until it reopens it
+	status := "ok"
+	if health.JournalMode != health.Configured.JournalMode {
+		status = "degraded"
+	}
+	return c.JSON(fiber.Map{
+		"status":   status,
+		"database": health,
+	})
+}
+
+// getNetworkStatus returns the connectivity state and its transition history
+func (s *Server) getNetworkStatus(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"status":  s.networkMonitor.GetStatus(),
+		"history": s.networkMonitor.GetHistory(),
+	})
+}
+
+// checkNetwork forces an immediate connectivity check
+func (s *Server) checkNetwork(c *fiber.Ctx) error {
+	return c.JSON(s.networkMonitor.Check())
+}
+
+// getWatchdogStatus returns the health checks the OS watchdog depends on
+func (s *Server) getWatchdogStatus(c *fiber.Ctx) error {
+	return c.JSON(s.watchdog.GetStatus())
+}
+
+// getEndpoint returns the port the API listens on and the conflict that caused a fallback
+func (s *Server) getEndpoint(c *fiber.Ctx) error {
+	return c.JSON(s.endpoint)
+}
+
+// getTracingStatus returns the tracing settings in use and the export counters
+func (s *Server) getTracingStatus(c *fiber.Ctx) error {
+	return c.JSON(s.tracer.GetStatus())
+}
+
+// refreshTracing applies changed tracing settings now instead of within 30 seconds
+func (s *Server) refreshTracing(c *fiber.Ctx) error {
+	return c.JSON(s.tracer.Refresh())
+}
+
+// getStartup returns the startup conditions and how long this start waited for them
+func (s *Server) getStartup(c *fiber.Ctx) error {
+	conditions, err := baseConfig.LoadStartupConditions()
+	response := fiber.Map{
+		"conditions": conditions,
+		"state":      startup.Get(),
+	}
+	if err != nil {
+		response["error"] = err.Error()
+	}
+	return c.JSON(response)
+}
+
+// updateStartupConditions stores the startup conditions, they apply on the next start
+func (s *Server) updateStartupConditions(c *fiber.Ctx) error {
+	conditions := baseConfig.DefaultStartupConditions()
+	if err := c.BodyParser(&conditions); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	if err := conditions.Validate(); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if err := baseConfig.SaveStartupConditions(conditions); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to save startup conditions: "+err.Error())
+	}
+
+	s.logger.Info("API", "Startup conditions updated, they apply on the next start")
+	return c.JSON(fiber.Map{"conditions": conditions})
+}
+
+// getPowerStatus returns the power state and the recorded sleep windows, used to explain
+// gaps in the data
+func (s *Server) getPowerStatus(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"status":  s.powerMonitor.GetStatus(),
+		"history": s.powerMonitor.GetHistory(),
+	})
+}
+
+// createCameraAlert publishes a camera status change over MQTT
+func (s *Server) createCameraAlert(c *fiber.Ctx) error {
+	var alert snapshots.CameraAlert
+	if err := c.BodyParser(&alert); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	if err := alert.Validate(); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	payload := s.snapshots.Payload(alert)
+	if tags := identity.GetTags(database.GetDB()); len(tags) > 0 {
+		payload["tags"] = tags
+	}
+
+	messageID, err := s.mqttSender.SendData(s.cfg.MQTT.Topic+snapshots.AlertTopic, payload)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	_, withSnapshot := payload["snapshot"]
+	s.logger.Info("API", "Camera %s %s alert queued (snapshot: %v)", alert.CameraUUID, alert.Status, withSnapshot)
+	return c.JSON(fiber.Map{
+		"status":     "queued",
+		"message_id": messageID,
+		"snapshot":   withSnapshot,
+		"omitted":    payload["snapshot_omitted"],
+	})
+}
+
+// createCameraHealthReport queues the scheduled camera health summary of the desktop app, so
+// degraded sites can be alerted on without polling the desktop app
+func (s *Server) createCameraHealthReport(c *fiber.Ctx) error {
+	var report camerahealth.Report
+	if err := c.BodyParser(&report); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	if err := report.Validate(); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	payload := snapshots.HealthPayload(report)
+	if tags := identity.GetTags(database.GetDB()); len(tags) > 0 {
+		payload["tags"] = tags
+	}
+
+	messageID, err := s.mqttSender.SendData(s.cfg.MQTT.Topic+snapshots.HealthTopic, payload)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	s.logger.Info("API", "Camera health report queued: %d online, %d offline, %d unknown",
+		payload["online"], payload["offline"], payload["unknown"])
+	return c.JSON(fiber.Map{
+		"status":     "queued",
+		"message_id": messageID,
+	})
+}
+
+// getCameraSnapshot returns the last known frame of a camera as it would be attached to an
+// alert, or the reason it is left out
+func (s *Server) getCameraSnapshot(c *fiber.Ctx) error {
+	attachment, omitted := s.snapshots.Attachment(c.Params("uuid"))
+	if attachment == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "snapshot not available",
+			"omitted": omitted,
+		})
+	}
+	return c.JSON(attachment)
+}
+
+// getUploadPolicy returns whether non-critical uploads are paused and why
+func (s *Server) getUploadPolicy(c *fiber.Ctx) error {
+	return c.JSON(s.mqttSender.RefreshUploadPolicy())
+}
+
+// updateUploadPolicy sets the pause mode (auto/pause/resume), metered flag or daily cap
+func (s *Server) updateUploadPolicy(c *fiber.Ctx) error {
+	var req struct {
+		Mode       *string `json:"mode"`
+		Metered    *bool   `json:"metered"`
+		DailyCapMB *int    `json:"daily_cap_mb"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+
+	state, err := s.mqttSender.UpdateUploadPolicy(req.Mode, req.Metered, req.DailyCapMB)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(state)
+}
+
+// getMaintenance returns the maintenance state and the past maintenance windows
+func (s *Server) getMaintenance(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"state":   s.maintenance.GetState(),
+		"history": s.maintenance.GetHistory(),
+	})
+}
+
+// enableMaintenance switches maintenance mode on, or extends it when already on
+func (s *Server) enableMaintenance(c *fiber.Ctx) error {
+	var req maintmode.Request
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+		}
+	}
+
+	state, err := s.maintenance.Enable(req, maintmode.SourceAPI)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(state)
+}
+
+// disableMaintenance switches maintenance mode off before it expires
+func (s *Server) disableMaintenance(c *fiber.Ctx) error {
+	state, err := s.maintenance.Disable(c.Query("actor"), maintmode.SourceAPI)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(state)
+}
+
+// getRegistration returns the registration of the site with the backend
+func (s *Server) getRegistration(c *fiber.Ctx) error {
+	return c.JSON(s.mqttSender.GetRegistration())
+}
+
+// retryRegistration sends the site registration again, also after a rejection
+func (s *Server) retryRegistration(c *fiber.Ctx) error {
+	state, err := s.mqttSender.RetryRegistration()
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadGateway, err.Error())
+	}
+	return c.JSON(state)
+}
+
+// skipRegistration lets the site publish without an answer of the backend
+func (s *Server) skipRegistration(c *fiber.Ctx) error {
+	actor := c.Query("actor", "api")
+	if username, ok := c.Locals("username").(string); ok && username != "" {
+		actor = username
+	}
+
+	state, err := s.mqttSender.SkipRegistration(actor)
+	if err != nil {
+		return err
+	}
+	return c.JSON(state)
+}
+
+// verificationTimeout bounds a verification run, probing many cameras takes a while
+const verificationTimeout = 5 * time.Minute
+
+// getVerification returns the report of the last installation verification
+func (s *Server) getVerification(c *fiber.Ctx) error {
+	report, err := s.verifier.Last()
+	if errors.Is(err, verify.ErrNoReport) {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	} else if err != nil {
+		return err
+	}
+	return c.JSON(report)
+}
+
+// runVerification runs the installation verification and returns the report
+func (s *Server) runVerification(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), verificationTimeout)
+	defer cancel()
+
+	report, err := s.verifier.Run(ctx)
+	if errors.Is(err, verify.ErrRunning) {
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	} else if err != nil {
+		return err
+	}
+	return c.JSON(report)
+}
+
+// signOffVerification records the installer accepting the last passed report
+func (s *Server) signOffVerification(c *fiber.Ctx) error {
+	var req struct {
+		Installer string `json:"installer"`
+		Notes     string `json:"notes"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+
+	report, err := s.verifier.Sign(req.Installer, req.Notes)
+	if errors.Is(err, verify.ErrNoReport) {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	} else if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return c.JSON(report)
+}
+
+// Locals set for requests authenticated with a session token or an API key
+const (
+	localSession      = "session"
+	localSessionToken = "session_token"
+	localAPIKey       = "api_key"
+)
+
+// apiKeyMiddleware authenticates requests that carry a diagnostics key and refuses every
+// endpoint outside its scope. Requests without a key fall through to the session and basic
+// auth.
+func apiKeyMiddleware(keys *apikeys.Manager) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := c.Get(apikeys.HeaderKey)
+		if token == "" {
+			if bearer, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer "); ok && apikeys.IsKey(bearer) {
+				token = bearer
+			}
+		}
+		if token == "" {
+			return c.Next()
+		}
+
+		key, err := keys.Authenticate(token, c.IP())
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+		}
+		if !apikeys.Allows(key, c.Method(), c.Path()) {
+			return fiber.NewError(fiber.StatusForbidden, apikeys.ErrScope.Error())
+		}
+
+		c.Locals(localAPIKey, key)
+		return c.Next()
+	}
+}
+
+// sessionMiddleware authenticates requests that carry a session token and refuses the ones
+// outside the read-only scope of a session. Requests without one fall through to basic auth.
+func sessionMiddleware(sessions *apisession.Manager) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := c.Get(apisession.HeaderToken)
+		if token == "" {
+			token, _ = strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		}
+		if token == "" || c.Locals(localAPIKey) != nil {
+			return c.Next()
+		}
+
+		session, err := sessions.Use(token)
+		if errors.Is(err, apisession.ErrRateLimited) {
+			retryAfter := int(session.RetryAfter().Seconds()) + 1
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+			return fiber.NewError(fiber.StatusTooManyRequests, err.Error())
+		} else if err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+		}
+
+		if !apisession.Allows(c.Method(), c.Path()) {
+			return fiber.NewError(fiber.StatusForbidden, apisession.ErrScope.Error())
+		}
+
+		c.Locals(localSession, session)
+		c.Locals(localSessionToken, token)
+		return c.Next()
+	}
+}
+
+// tracingMiddleware records a server span for every request. A traceparent header of the
+// caller is continued, handlers get the span context through the user context.
+func tracingMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		if parent, ok := tracing.ParseTraceparent(c.Get("traceparent")); ok {
+			ctx = tracing.ContextWithSpanContext(ctx, parent)
+		}
+
+		ctx, span := tracing.StartKind(ctx, tracing.KindServer, c.Method()+" "+c.Path(),
+			tracing.String("http.request.method", c.Method()),
+			tracing.String("url.path", c.Path()))
+		if span == nil {
+			return c.Next()
+		}
+		defer span.End()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			status = fiberErr.Code
+		} else if err != nil {
+			status = fiber.StatusInternalServerError
+		}
+
+		if route := c.Route(); route != nil && route.Path != "" {
+			span.SetName(c.Method() + " " + route.Path)
+			span.SetAttributes(tracing.String("http.route", route.Path))
+		}
+		span.SetAttributes(tracing.Int("http.response.status_code", int64(status)))
+		if status >= fiber.StatusInternalServerError {
+			if err == nil {
+				err = fmt.Errorf("request failed with status %d", status)
+			}
+			span.RecordError(err)
+		}
+		return err
+	}
+}
+
+// createSession logs a viewer in with the API credentials and returns the session token.
+// A session cannot create further sessions, that would outlive its own expiry.
+func (s *Server) createSession(c *fiber.Ctx) error {
+	if c.Locals(localSession) != nil {
+		return fiber.NewError(fiber.StatusForbidden, "Log in with the API credentials to create a session")
+	}
+	username, _ := c.Locals("username").(string)
+
+	token, session, err := s.sessions.Create(username, c.IP(), c.Get(fiber.HeaderUserAgent))
+	if errors.Is(err, apisession.ErrTooManySessions) {
+		return fiber.NewError(fiber.StatusServiceUnavailable, err.Error())
+	} else if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"token":   token,
+		"header":  apisession.HeaderToken,
+		"session": session,
+	})
+}
+
+// getSessions returns the active viewer sessions
+func (s *Server) getSessions(c *fiber.Ctx) error {
+	return c.JSON(s.sessions.Summary(true))
+}
+
+// revokeSessions ends every session, used after the API credentials were changed or leaked
+func (s *Server) revokeSessions(c *fiber.Ctx) error {
+	revoked := s.sessions.RevokeAll(c.Query("reason"))
+	return c.JSON(fiber.Map{"revoked": revoked})
+}
+
+// logoutSession ends the session of the request
+func (s *Server) logoutSession(c *fiber.Ctx) error {
+	token, _ := c.Locals(localSessionToken).(string)
+	if token == "" || !s.sessions.RevokeToken(token) {
+		return fiber.NewError(fiber.StatusBadRequest, "Request was not made with a session token")
+	}
+	return c.JSON(fiber.Map{"revoked": 1})
 }
 
-// getStatus returns the overall system status
-func (s *Server) getStatus(c *fiber.Ctx) error {
-	status := map[string]interface{}{
-		"service": "running",
-		"time":    time.Now().Format(time.RFC3339),
-		"uptime":  time.Since(time.Now()), // This should be replaced with actual service start time
-		"mqtt":    s.mqttSender.GetStatus(),
+// revokeSession ends one session by its ID
+func (s *Server) revokeSession(c *fiber.Ctx) error {
+	if !s.sessions.Revoke(c.Params("id")) {
+		return fiber.NewError(fiber.StatusNotFound, "Session not found")
+	}
+	return c.JSON(fiber.Map{"revoked": 1})
+}
+
+// issueAPIKey creates a diagnostics key, the token is only shown in this response
+func (s *Server) issueAPIKey(c *fiber.Ctx) error {
+	var req struct {
+		Name    string `json:"name"`
+		TTLDays int    `json:"ttl_days"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+
+	actor := "api"
+	if username, ok := c.Locals("username").(string); ok && username != "" {
+		actor = username
+	} else if session, ok := c.Locals(localSession).(apisession.Session); ok && session.Username != "" {
+		actor = session.Username
+	}
+
+	token, key, err := s.apiKeys.Issue(req.Name, req.TTLDays, actor)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"token":  token,
+		"header": apikeys.HeaderKey,
+		"key":    key,
+	})
+}
+
+// getAPIKeys lists the diagnostics keys without their tokens
+func (s *Server) getAPIKeys(c *fiber.Ctx) error {
+	keys, err := s.apiKeys.List()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to list API keys: "+err.Error())
+	}
+	return c.JSON(fiber.Map{"keys": keys})
+}
+
+// revokeAPIKey ends a diagnostics key, ?reason= is recorded in the audit log
+func (s *Server) revokeAPIKey(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid key ID")
+	}
+
+	actor := "api"
+	if username, ok := c.Locals("username").(string); ok && username != "" {
+		actor = username
+	} else if session, ok := c.Locals(localSession).(apisession.Session); ok && session.Username != "" {
+		actor = session.Username
+	}
+
+	err = s.apiKeys.Revoke(uint(id), actor, c.Query("reason"))
+	switch {
+	case errors.Is(err, apikeys.ErrNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, apikeys.ErrRevoked):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case err != nil:
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to revoke API key: "+err.Error())
+	}
+	return c.JSON(fiber.Map{"revoked": 1})
+}
+
+// getRedaction returns the redaction rules in use
+func (s *Server) getRedaction(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"enabled":  s.cfg.Redaction.Enabled,
+		"defaults": s.cfg.Redaction.Defaults,
+		"rules":    redact.Current().Rules(),
+	})
+}
+
+// redactionPreviewRequest is a sample to redact. Rules, when given, are tried instead of
+// the rules in use so they can be checked before saving them.
+type redactionPreviewRequest struct {
+	Sample string        `json:"sample"`
+	Rules  []redact.Rule `json:"rules"`
+}
+
+// previewRedaction shows what a sample becomes before it leaves the device
+func (s *Server) previewRedaction(c *fiber.Ctx) error {
+	var req redactionPreviewRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	if req.Sample == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "sample is required")
+	}
+
+	engine := redact.Current()
+	if req.Rules != nil {
+		var err error
+		if engine, err = redact.New(req.Rules); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+	}
+	return c.JSON(engine.Preview(req.Sample))
+}
+
+// identityRequest holds the identity fields to change, omitted fields keep their current value
+type identityRequest struct {
+	TenantID    *string            `json:"tenant_id"`
+	ClientID    *string            `json:"client_id"`
+	SiteID      *string            `json:"site_id"`
+	SiteCode    *string            `json:"site_code"`
+	TopicPrefix *string            `json:"topic_prefix"`
+	Tags        *map[string]string `json:"tags"`
+}
+
+// apply merges the request into the current identity
+func (r identityRequest) apply(current identity.Identity) identity.Identity {
+	next := current
+	if r.TenantID != nil {
+		next.TenantID = *r.TenantID
+	}
+	if r.ClientID != nil {
+		next.ClientID = *r.ClientID
+	}
+	if r.SiteID != nil {
+		next.SiteID = *r.SiteID
+	}
+	if r.SiteCode != nil {
+		next.SiteCode = *r.SiteCode
+	}
+	if r.TopicPrefix != nil {
+		next.TopicPrefix = *r.TopicPrefix
+	}
+	if r.Tags != nil {
+		next.Tags = *r.Tags
+	}
+	return next.Normalize()
+}
+
+// getIdentity returns the effective identity and any problems with it
+func (s *Server) getIdentity(c *fiber.Ctx) error {
+	current := s.synchronizer.GetIdentity()
+
+	return c.JSON(fiber.Map{
+		"identity":   current,
+		"data_topic": current.DataTopic("<date>"),
+		"problems":   current.Normalize().Validate(),
+	})
+}
+
+// validateIdentity checks a proposed identity and returns the changes it would make
+func (s *Server) validateIdentity(c *fiber.Ctx) error {
+	var req identityRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+
+	current := s.synchronizer.GetIdentity()
+	next := req.apply(current)
+	problems := next.Validate()
+
+	return c.JSON(fiber.Map{
+		"valid":    len(problems) == 0,
+		"problems": problems,
+		"changes":  current.Diff(next),
+	})
+}
+
+// updateIdentity saves the identity, following data files use the new values
+func (s *Server) updateIdentity(c *fiber.Ctx) error {
+	var req identityRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+
+	actor := "api"
+	if username, ok := c.Locals("username").(string); ok && username != "" {
+		actor = username
+	}
+
+	next := req.apply(s.synchronizer.GetIdentity())
+	changes, err := s.synchronizer.UpdateIdentity(next, actor)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"identity": s.synchronizer.GetIdentity(),
+		"changes":  changes,
+	})
+}
+
+// getIdentityAudit returns the latest identity changes, ?limit=N (default 50)
+func (s *Server) getIdentityAudit(c *fiber.Ctx) error {
+	limit, err := strconv.Atoi(c.Query("limit", "50"))
+	if err != nil || limit <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid limit parameter")
+	}
+
+	entries, err := s.synchronizer.GetIdentityAudit(limit)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(entries)
+}
+
+// getResidency returns the data residency mode and its audit trail
+func (s *Server) getResidency(c *fiber.Ctx) error {
+	limit, err := strconv.Atoi(c.Query("limit", "50"))
+	if err != nil || limit <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid limit parameter")
+	}
+
+	db := database.GetDB()
+	audit, err := residency.GetAudit(db, limit)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"state": residency.Get(db),
+		"audit": audit,
+	})
+}
+
+// getSettings returns every setting with its schema, secret values are masked
+func (s *Server) getSettings(c *fiber.Ctx) error {
+	settings, err := settingschema.List(database.GetDB())
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(settings)
+}
+
+// updateSettings validates and saves settings from a key to value object, a masked secret
+// sent back unchanged keeps its value. Identity changes apply to the following data files.
+func (s *Server) updateSettings(c *fiber.Ctx) error {
+	var values map[string]interface{}
+	if err := c.BodyParser(&values); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	if len(values) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "No settings given")
+	}
+
+	actor := "api"
+	if username, ok := c.Locals("username").(string); ok && username != "" {
+		actor = username
+	}
+
+	changes, err := settingschema.Update(database.GetDB(), values, actor, "sync_api")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	for _, change := range changes {
+		s.logger.Info("API", "Setting %s changed from %q to %q by %s", change.Key, change.Old, change.New, actor)
+		if change.Key == identity.TopicPrefixKey {
+			s.cfg.MQTT.Topic = change.New
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"changes": changes,
+	})
+}
+
+// getSettingsAudit returns the latest setting changes, ?limit=N (default 50)
+func (s *Server) getSettingsAudit(c *fiber.Ctx) error {
+	limit, err := strconv.Atoi(c.Query("limit", "50"))
+	if err != nil || limit <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid limit parameter")
+	}
+
+	entries, err := settingschema.GetAudit(database.GetDB(), limit)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(entries)
+}
+
+// getBandwidthUsage returns bytes sent per destination, ?days=N (default 7)
+func (s *Server) getBandwidthUsage(c *fiber.Ctx) error {
+	days, err := strconv.Atoi(c.Query("days", "7"))
+	if err != nil || days <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid days parameter")
+	}
+
+	usage, err := s.statsService.GetBandwidthUsage(days)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(usage)
+}
+
+// getPreflight re-runs the startup preflight checks and returns the report
+func (s *Server) getPreflight(c *fiber.Ctx) error {
+	report := preflight.Run(s.cfg)
+	if !report.Passed {
+		c.Status(fiber.StatusServiceUnavailable)
+	}
+	return c.JSON(report)
+}
+
+// getSyncStatus returns the synchronizer status
+func (s *Server) getSyncStatus(c *fiber.Ctx) error {
+	status := s.synchronizer.GetStatus()
+	return c.JSON(status)
+}
+
+// getSyncLive returns the small status the desktop app polls for its live sync view: files
+// waiting, the last processed folder and the connection and queue of the sender
+func (s *Server) getSyncLive(c *fiber.Ctx) error {
+	snapshot, err := s.mqttSender.StatusSnapshot()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to read sender status: "+err.Error())
+	}
+	deadLetters, err := s.messageService.CountDeadLetters()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"sync": s.synchronizer.GetLiveStatus(),
+		"mqtt": fiber.Map{
+			"connected":        snapshot.Connected,
+			"transport":        s.mqttSender.GetTransportStatus().Transport,
+			"upload_paused":    snapshot.UploadPaused,
+			"pending_messages": snapshot.Pending,
+			"dead_letters":     deadLetters,
+		},
+	})
+}
+
+// startSync triggers a manual synchronization
+func (s *Server) startSync(c *fiber.Ctx) error {
+	go func() {
+		s.synchronizer.SyncData() // Assuming this method is exported from the synchronizer
+	}()
+
+	return c.JSON(fiber.Map{
+		"status":  "sync_started",
+		"message": "Synchronization process started",
+	})
+}
+
+// getSyncFolders returns all synchronized folders
+func (s *Server) getSyncFolders(c *fiber.Ctx) error {
+	detailed := c.QueryBool("detailed", false)
+
+	if detailed {
+		folders, err := s.synchronizer.GetSyncedFoldersDetails()
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to get synced folders: "+err.Error())
+		}
+		return c.JSON(fiber.Map{
+			"folders": folders,
+		})
+	} else {
+		folders, err := s.synchronizer.GetSyncedFoldersList()
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to get synced folders: "+err.Error())
+		}
+		return c.JSON(fiber.Map{
+			"folders": folders,
+		})
+	}
+}
+
+// getFolderStats returns the processing statistics of the latest date folders and the trend
+// of the time per file, ?days=N (default 30)
+func (s *Server) getFolderStats(c *fiber.Ctx) error {
+	days, err := strconv.Atoi(c.Query("days", strconv.Itoa(sync.DefaultFolderStatsDays)))
+	if err != nil || days <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid days parameter")
+	}
+
+	report, err := s.synchronizer.GetFolderStats(days)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to read folder statistics: "+err.Error())
+	}
+	return c.JSON(report)
+}
+
+// resyncFolder forces a resync of a specific folder
+func (s *Server) resyncFolder(c *fiber.Ctx) error {
+	folder := c.Params("folder")
+	if folder == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Folder parameter is required")
+	}
+
+	err := s.synchronizer.ResyncFolder(folder)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to resync folder: "+err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "resync_started",
+		"folder":  folder,
+		"message": "Folder resync started",
+	})
+}
+
+// getFileStatus returns processing status for a specific file
+func (s *Server) getFileStatus(c *fiber.Ctx) error {
+	folder := c.Params("folder")
+	filename := c.Params("filename")
+
+	if folder == "" || filename == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Folder and filename parameters are required")
+	}
+
+	status, err := s.synchronizer.GetFileProcessingStatus(filename, folder)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to get file status: "+err.Error())
+	}
+
+	return c.JSON(status)
+}
+
+// sendSyncSummary sends a sync summary via MQTT
+func (s *Server) sendSyncSummary(c *fiber.Ctx) error {
+	err := s.synchronizer.SendSyncFolderSummary() // Assuming this method is implemented
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to send sync summary: "+err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "summary_sent",
+		"message": "Sync summary sent to MQTT",
+	})
+}
+
+// startReplay republishes processed files in a date range, body {"from","to","rate"}
+func (s *Server) startReplay(c *fiber.Ctx) error {
+	var req struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+		Rate int    `json:"rate"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+
+	from, err := parseDate(req.From)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid from date, expected YYYY-MM-DD")
+	}
+
+	to := from
+	if req.To != "" {
+		if to, err = parseDate(req.To); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid to date, expected YYYY-MM-DD")
+		}
+	}
+
+	status, err := s.synchronizer.StartReplay(from, to, req.Rate)
+	if err != nil {
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(status)
+}
+
+// getReplayStatus returns the progress of the current or last replay
+func (s *Server) getReplayStatus(c *fiber.Ctx) error {
+	status := s.synchronizer.GetReplayStatus()
+	if status == nil {
+		return c.JSON(fiber.Map{"state": "idle"})
+	}
+	return c.JSON(status)
+}
+
+// cancelReplay stops the running replay
+func (s *Server) cancelReplay(c *fiber.Ctx) error {
+	if err := s.synchronizer.CancelReplay(); err != nil {
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+	return c.JSON(fiber.Map{"status": "cancelling"})
+}
+
+// queryData returns cached data records, filtered by ?from=&to=&cctv_id=&limit=&offset=
+func (s *Server) queryData(c *fiber.Ctx) error {
+	query, err := dataQuery(c)
+	if err != nil {
+		return err
+	}
+	query.Limit = c.QueryInt("limit", sync.DefaultDataQueryLimit)
+	query.Offset = c.QueryInt("offset", 0)
+
+	records, total, err := s.synchronizer.QueryDataRecords(query)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"records": records,
+		"total":   total,
+		"limit":   query.Limit,
+		"offset":  query.Offset,
+	})
+}
+
+// getDataSummary returns in/out totals per day and camera
+func (s *Server) getDataSummary(c *fiber.Ctx) error {
+	query, err := dataQuery(c)
+	if err != nil {
+		return err
+	}
+
+	summary, err := s.synchronizer.GetDataSummary(query)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(fiber.Map{"summary": summary})
+}
+
+// getDataSeries returns the in/out counts summed per hour, day or week in the site time zone.
+// Without a bucket the size is chosen from the range, long ranges are not sent as raw rows.
+func (s *Server) getDataSeries(c *fiber.Ctx) error {
+	today := time.Now()
+	query := sync.SeriesQuery{
+		From:     today.AddDate(0, 0, -6),
+		To:       today,
+		CCTVID:   c.QueryInt("cctv_id", 0),
+		Bucket:   c.Query("bucket"),
+		Timezone: c.Query("timezone"),
+	}
+
+	if value := c.Query("from"); value != "" {
+		from, err := parseDate(value)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid from date, expected YYYY-MM-DD")
+		}
+		query.From = from
+	}
+	if value := c.Query("to"); value != "" {
+		to, err := parseDate(value)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid to date, expected YYYY-MM-DD")
+		}
+		query.To = to
+	}
+
+	series, err := s.synchronizer.GetDataSeries(query)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return c.JSON(series)
+}
+
+// getLifetimeTotals returns the counts since install per camera and the visitor milestones
+func (s *Server) getLifetimeTotals(c *fiber.Ctx) error {
+	totals, err := s.synchronizer.GetLifetimeTotals()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to read lifetime totals: "+err.Error())
+	}
+	return c.JSON(totals)
+}
+
+// getValidationRules returns the rules that quarantine anomalous counts
+func (s *Server) getValidationRules(c *fiber.Ctx) error {
+	return c.JSON(s.synchronizer.GetValidationRules())
+}
+
+// updateValidationRules replaces the validation rules, they apply to the next data file
+func (s *Server) updateValidationRules(c *fiber.Ctx) error {
+	rules := s.synchronizer.GetValidationRules()
+	if err := c.BodyParser(&rules); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+
+	if err := s.synchronizer.UpdateValidationRules(rules); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return c.JSON(rules)
+}
+
+// getQuarantine lists quarantined entries, by default the ones waiting for review
+func (s *Server) getQuarantine(c *fiber.Ctx) error {
+	items, counts, err := s.synchronizer.ListQuarantine(c.Query("status"), c.QueryInt("limit", sync.DefaultDataQueryLimit))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"entries": items,
+		"counts":  counts,
+	})
+}
+
+// releaseQuarantined publishes a quarantined entry after review
+func (s *Server) releaseQuarantined(c *fiber.Ctx) error {
+	return s.reviewQuarantined(c, s.synchronizer.ReleaseQuarantined)
+}
+
+// discardQuarantined drops a quarantined entry after review
+func (s *Server) discardQuarantined(c *fiber.Ctx) error {
+	return s.reviewQuarantined(c, s.synchronizer.DiscardQuarantined)
+}
+
+func (s *Server) reviewQuarantined(c *fiber.Ctx, review func(id uint, actor string) (sync.QuarantineItem, error)) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid quarantine ID")
+	}
+
+	actor := "api"
+	if username, ok := c.Locals("username").(string); ok && username != "" {
+		actor = username
+	}
+
+	item, err := review(uint(id), actor)
+	switch {
+	case errors.Is(err, sync.ErrQuarantineNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, sync.ErrQuarantineReviewed):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case err != nil:
+		return err
+	}
+	return c.JSON(item)
+}
+
+// getDataCompleteness compares produced, processed and published files per camera and day
+func (s *Server) getDataCompleteness(c *fiber.Ctx) error {
+	query, err := dataQuery(c)
+	if err != nil {
+		return err
+	}
+
+	report, err := s.synchronizer.GetCompleteness(query)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	// Maintenance windows explain gaps that were expected
+	from, _ := time.ParseInLocation(sync.DateFolderPattern, report.From, time.Local)
+	to, _ := time.ParseInLocation(sync.DateFolderPattern, report.To, time.Local)
+
+	return c.JSON(struct {
+		sync.CompletenessReport
+		Maintenance []maintmode.Window `json:"maintenance"`
+	}{report, s.maintenance.WindowsBetween(from, to.AddDate(0, 0, 1))})
+}
+
+// getDataGaps returns the gaps found by the scheduled detection and their backfill state
+func (s *Server) getDataGaps(c *fiber.Ctx) error {
+	return c.JSON(s.synchronizer.GetGapStatus())
+}
+
+// getDataGapReport compares the data files of each camera with the counter save interval
+// over the last hours (default 24, at most 7 days)
+func (s *Server) getDataGapReport(c *fiber.Ctx) error {
+	hours := c.QueryInt("hours", 24)
+	if hours <= 0 || hours > 7*24 {
+		return fiber.NewError(fiber.StatusBadRequest, "hours must be between 1 and 168")
+	}
+
+	now := time.Now()
+	report, err := s.synchronizer.DetectGaps(now.Add(-time.Duration(hours)*time.Hour), now)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(report)
+}
+
+// requestGapBackfill asks the counter to re-export the data of a detected gap
+func (s *Server) requestGapBackfill(c *fiber.Ctx) error {
+	gap, err := s.synchronizer.RequestBackfill(c.Params("id"))
+	switch {
+	case errors.Is(err, sync.ErrGapNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, sync.ErrGapOpen), errors.Is(err, sync.ErrGapOutOfCache), errors.Is(err, sync.ErrBackfillPending):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case err != nil:
+		return err
+	}
+
+	return c.JSON(gap)
+}
+
+// importData validates and stores historical counts from a legacy CSV export, sent as the
+// "file" form field or as a JSON body with the CSV text. Rows with problems are returned with
+// status 422 and nothing is stored, dry_run only validates.
+func (s *Server) importData(c *fiber.Ctx) error {
+	var req struct {
+		CSV      string `json:"csv" form:"csv"`
+		Source   string `json:"source" form:"source"`
+		Timezone string `json:"timezone" form:"timezone"`
+		DryRun   bool   `json:"dry_run" form:"dry_run"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+
+	var input io.Reader = strings.NewReader(req.CSV)
+	if header, err := c.FormFile("file"); err == nil {
+		file, err := header.Open()
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Failed to read the uploaded file: "+err.Error())
+		}
+		defer file.Close()
+		input = file
+		if req.Source == "" {
+			req.Source = header.Filename
+		}
+	} else if req.CSV == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Send the CSV as the file form field or in the csv field")
+	}
+
+	actor := "api"
+	if username, ok := c.Locals("username").(string); ok && username != "" {
+		actor = username
+	}
+
+	report, err := s.synchronizer.ImportCounts(input, sync.ImportOptions{
+		Source:   req.Source,
+		Actor:    actor,
+		Timezone: req.Timezone,
+		DryRun:   req.DryRun,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if report.ErrorCount > 0 {
+		c.Status(fiber.StatusUnprocessableEntity)
+	}
+	return c.JSON(report)
+}
+
+// getDataImports lists the stored imports of historical counts
+func (s *Server) getDataImports(c *fiber.Ctx) error {
+	imports, err := s.synchronizer.ListImports()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(imports)
+}
+
+// deleteDataImport removes the historical counts of one import
+func (s *Server) deleteDataImport(c *fiber.Ctx) error {
+	deleted, err := s.synchronizer.DeleteImport(c.Params("id"))
+	if errors.Is(err, sync.ErrImportNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	} else if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(fiber.Map{"deleted": deleted})
+}
+
+// dataQuery reads the date range and camera filter shared by the data endpoints
+func dataQuery(c *fiber.Ctx) (sync.DataQuery, error) {
+	query := sync.DataQuery{CCTVID: c.QueryInt("cctv_id", 0)}
+
+	if value := c.Query("from"); value != "" {
+		from, err := parseDate(value)
+		if err != nil {
+			return query, fiber.NewError(fiber.StatusBadRequest, "Invalid from date, expected YYYY-MM-DD")
+		}
+		query.From = from.Format(sync.DateFolderPattern)
+	}
+
+	if value := c.Query("to"); value != "" {
+		to, err := parseDate(value)
+		if err != nil {
+			return query, fiber.NewError(fiber.StatusBadRequest, "Invalid to date, expected YYYY-MM-DD")
+		}
+		query.To = to.Format(sync.DateFolderPattern)
+	}
+
+	return query, nil
+}
+
+// parseDate accepts YYYY-MM-DD or the date folder format YYYYMMDD
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(sync.DateFolderPattern, value)
+}
+
+// getMQTTStatus returns the MQTT connection status
+func (s *Server) getMQTTStatus(c *fiber.Ctx) error {
+	return c.JSON(s.mqttSender.GetStatus())
+}
+
+// getPublishMetrics returns publish counters and failure causes per topic prefix
+func (s *Server) getPublishMetrics(c *fiber.Ctx) error {
+	return c.JSON(s.mqttSender.GetPublishMetrics())
+}
+
+// resetPublishMetrics clears the publish counters
+func (s *Server) resetPublishMetrics(c *fiber.Ctx) error {
+	s.mqttSender.ResetPublishMetrics()
+	return c.JSON(fiber.Map{"status": "reset"})
+}
+
+// getSenderTuning returns the worker count, publish delay, batch and queue sizes
+func (s *Server) getSenderTuning(c *fiber.Ctx) error {
+	return c.JSON(s.mqttSender.GetTuning())
+}
+
+// updateSenderTuning changes the sender tuning, omitted fields keep their current value
+func (s *Server) updateSenderTuning(c *fiber.Ctx) error {
+	tuning := s.mqttSender.GetTuning()
+	if err := c.BodyParser(&tuning); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+
+	applied, err := s.mqttSender.UpdateTuning(tuning)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(applied)
+}
+
+// syncSettingsView is the saved sync settings over the running config. The broker password
+// is never returned, Pending lists the components not using the saved settings yet.
+type syncSettingsView struct {
+	config.SyncSettings
+	BatchSize   int      `json:"batch_size"`
+	PasswordSet bool     `json:"password_set"`
+	Pending     []string `json:"pending"`
+
+	password string
+}
+
+// syncSettings returns the saved sync settings over the running config
+func (s *Server) syncSettings() syncSettingsView {
+	stored := config.LoadSyncSettings(database.GetDB())
+
+	next := *s.cfg
+	pending := next.ApplySyncSettings(stored)
+	cleanupConfig := s.cleanupService.GetConfig()
+	if cleanupConfig.ApplyRetention(stored) {
+		pending = append(pending, "cleanup")
+	}
+
+	return syncSettingsView{
+		SyncSettings: config.SyncSettings{
+			SyncIntervalSec:         next.Sync.Interval,
+			LogRetentionDays:        cleanupConfig.LogRetention,
+			MessageRetentionDays:    cleanupConfig.MessageRetention,
+			DataRecordRetentionDays: cleanupConfig.DataRecordRetention,
+			Broker:                  next.MQTT.Broker,
+			Port:                    next.MQTT.Port,
+			Username:                next.MQTT.Username,
+			EnableTLS:               next.MQTT.EnableTLS,
+			Staging:                 next.MQTT.Staging,
+			Brokers:                 next.MQTT.Brokers,
+			FailoverAfterSec:        next.MQTT.FailoverAfterSec,
+		},
+		BatchSize:   s.mqttSender.GetTuning().BatchSize,
+		PasswordSet: next.MQTT.Password != "",
+		Pending:     pending,
+		password:    next.MQTT.Password,
+	}
+}
+
+// getSyncSettings returns the sync interval, batch size, retention and broker settings
+func (s *Server) getSyncSettings(c *fiber.Ctx) error {
+	return c.JSON(s.syncSettings())
+}
+
+// updateSyncSettings validates and saves the sync settings, omitted fields keep their
+// current value and an empty password keeps the current password. The batch size applies
+// right away, the rest once the settings are applied.
+func (s *Server) updateSyncSettings(c *fiber.Ctx) error {
+	view := s.syncSettings()
+	if err := c.BodyParser(&view); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+
+	settings := view.SyncSettings
+	if settings.Password == "" {
+		settings.Password = view.password
+	}
+	if err := settings.Validate(); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	tuning := s.mqttSender.GetTuning()
+	tuning.BatchSize = view.BatchSize
+	if err := tuning.Validate(); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 
-	return c.JSON(status)
-}
+	if err := config.SaveSyncSettings(database.GetDB(), settings); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	if tuning != s.mqttSender.GetTuning() {
+		if _, err := s.mqttSender.UpdateTuning(tuning); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		}
+	}
 
-// getHealth returns a simple health check response
-func (s *Server) getHealth(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{
-		"status": "ok",
-		"time":   time.Now().Format(time.RFC3339),
-	})
+	s.logger.Info("API", "Sync settings saved")
+	return c.JSON(s.syncSettings())
 }
 
-// getSyncStatus returns the synchronizer status
-func (s *Server) getSyncStatus(c *fiber.Ctx) error {
-	status := s.synchronizer.GetStatus()
-	return c.JSON(status)
-}
+// applySyncSettings moves the saved sync settings into the running config. Retention applies
+// right away, the returned components have to restart to use the new settings.
+func (s *Server) applySyncSettings(c *fiber.Ctx) error {
+	stored := config.LoadSyncSettings(database.GetDB())
+	restart := s.cfg.ApplySyncSettings(stored)
 
-// startSync triggers a manual synchronization
-func (s *Server) startSync(c *fiber.Ctx) error {
-	go func() {
-		s.synchronizer.SyncData() // Assuming this method is exported from the synchronizer
-	}()
+	cleanupConfig := s.cleanupService.GetConfig()
+	if cleanupConfig.ApplyRetention(stored) {
+		if err := s.cleanupService.UpdateConfig(&cleanupConfig); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		}
+	}
 
+	s.logger.Info("API", "Sync settings applied, restart required for %v", restart)
 	return c.JSON(fiber.Map{
-		"status":  "sync_started",
-		"message": "Synchronization process started",
+		"restart":  restart,
+		"settings": s.syncSettings(),
 	})
 }
 
-// getSyncFolders returns all synchronized folders
-func (s *Server) getSyncFolders(c *fiber.Ctx) error {
-	detailed := c.QueryBool("detailed", false)
+// getTransport returns whether stored messages are sent over MQTT or uploaded over HTTPS,
+// switched with the sync_transport setting
+func (s *Server) getTransport(c *fiber.Ctx) error {
+	return c.JSON(s.mqttSender.GetTransportStatus())
+}
 
-	if detailed {
-		folders, err := s.synchronizer.GetSyncedFoldersDetails()
-		if err != nil {
-			return fiber.NewError(fiber.StatusInternalServerError, "Failed to get synced folders: "+err.Error())
-		}
-		return c.JSON(fiber.Map{
-			"folders": folders,
-		})
-	} else {
-		folders, err := s.synchronizer.GetSyncedFoldersList()
-		if err != nil {
-			return fiber.NewError(fiber.StatusInternalServerError, "Failed to get synced folders: "+err.Error())
-		}
-		return c.JSON(fiber.Map{
-			"folders": folders,
-		})
-	}
+// getMQTTSession returns the clean session and session expiry settings
+func (s *Server) getMQTTSession(c *fiber.Ctx) error {
+	return c.JSON(s.mqttSender.GetSession())
 }
 
-// resyncFolder forces a resync of a specific folder
-func (s *Server) resyncFolder(c *fiber.Ctx) error {
-	folder := c.Params("folder")
-	if folder == "" {
-		return fiber.NewError(fiber.StatusBadRequest, "Folder parameter is required")
+// updateMQTTSession changes the clean session flag or session expiry and reconnects
+func (s *Server) updateMQTTSession(c *fiber.Ctx) error {
+	var req struct {
+		CleanSession *bool `json:"clean_session"`
+		ExpirySecs   *int  `json:"session_expiry_seconds"`
 	}
 
-	err := s.synchronizer.ResyncFolder(folder)
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+
+	session, err := s.mqttSender.UpdateSession(req.CleanSession, req.ExpirySecs)
 	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, "Failed to resync folder: "+err.Error())
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 
-	return c.JSON(fiber.Map{
-		"status":  "resync_started",
-		"folder":  folder,
-		"message": "Folder resync started",
-	})
+	return c.JSON(session)
 }
 
-// getFileStatus returns processing status for a specific file
-func (s *Server) getFileStatus(c *fiber.Ctx) error {
-	folder := c.Params("folder")
-	filename := c.Params("filename")
+// getPayloadLog returns the sanitized payload logging mode
+func (s *Server) getPayloadLog(c *fiber.Ctx) error {
+	payloadLog := s.mqttSender.PayloadLog()
+	if payloadLog == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "Payload logging not available")
+	}
+	return c.JSON(payloadLog.Status())
+}
 
-	if folder == "" || filename == "" {
-		return fiber.NewError(fiber.StatusBadRequest, "Folder and filename parameters are required")
+// updatePayloadLog switches sanitized payload logging on or off at runtime
+func (s *Server) updatePayloadLog(c *fiber.Ctx) error {
+	payloadLog := s.mqttSender.PayloadLog()
+	if payloadLog == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "Payload logging not available")
 	}
 
-	status, err := s.synchronizer.GetFileProcessingStatus(filename, folder)
-	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, "Failed to get file status: "+err.Error())
+	var request struct {
+		Enabled         bool   `json:"enabled"`
+		DurationMinutes int    `json:"duration_minutes"`
+		TopicFilter     string `json:"topic_filter"`
+		MaxPayloadBytes int    `json:"max_payload_bytes"`
 	}
 
-	return c.JSON(status)
-}
+	if err := c.BodyParser(&request); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
 
-// sendSyncSummary sends a sync summary via MQTT
-func (s *Server) sendSyncSummary(c *fiber.Ctx) error {
-	err := s.synchronizer.SendSyncFolderSummary() // Assuming this method is implemented
-	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, "Failed to send sync summary: "+err.Error())
+	if !request.Enabled {
+		payloadLog.Disable()
+		s.logger.Info("API", "MQTT payload logging disabled")
+		return c.JSON(payloadLog.Status())
 	}
 
-	return c.JSON(fiber.Map{
-		"status":  "summary_sent",
-		"message": "Sync summary sent to MQTT",
-	})
-}
+	duration := time.Duration(request.DurationMinutes) * time.Minute
+	if err := payloadLog.Enable(duration, request.TopicFilter, request.MaxPayloadBytes); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
 
-// getMQTTStatus returns the MQTT connection status
-func (s *Server) getMQTTStatus(c *fiber.Ctx) error {
-	return c.JSON(s.mqttSender.GetStatus())
+	status := payloadLog.Status()
+	s.logger.Info("API", "MQTT payload logging enabled until %s", status.ExpiresAt.Format(time.RFC3339))
+	return c.JSON(status)
 }
 
 // sendTestMessage sends a test message via MQTT
 func (s *Server) sendTestMessage(c *fiber.Ctx) error {
+	if residency.LocalOnly() {
+		return fiber.NewError(fiber.StatusConflict, residency.ErrLocalOnly.Error())
+	}
+
 	var request struct {
 		Topic   string      `json:"topic"`
 		Payload interface{} `json:"payload"`
@@ -346,41 +2024,41 @@ func (s *Server) sendTestMessage(c *fiber.Ctx) error {
 	})
 }
 
-// getQueueStatus returns the MQTT message queue status
+// getQueueStatus returns the MQTT message queue status and the progress of the last drain
 func (s *Server) getQueueStatus(c *fiber.Ctx) error {
-	// Assuming a GetQueueStatus method exists in the sender
-	status := map[string]interface{}{
-		"time": time.Now().Format(time.RFC3339),
-	}
+	return c.JSON(s.mqttSender.GetQueueSnapshot())
+}
 
-	// Add queue stats from the message service
-	pendingCount, err := s.messageService.CountPendingMessages()
-	if err != nil {
-		s.logger.Error("API", "Failed to get pending message count: %v", err)
-	} else {
-		status["pending_messages"] = pendingCount
+// drainQueue starts draining the in-memory message queues, progress is reported by
+// GET /api/mqtt/queue. The drain stops after timeout seconds (default 60, max 600).
+func (s *Server) drainQueue(c *fiber.Ctx) error {
+	timeout := c.QueryInt("timeout", 60)
+	if timeout < 1 || timeout > 600 {
+		return fiber.NewError(fiber.StatusBadRequest, "timeout must be between 1 and 600 seconds")
 	}
 
-	// Get additional status from the MQTT sender
-	mqttStatus := s.mqttSender.GetStatus()
-	for k, v := range mqttStatus {
-		if k == "channel_queue_len" || k == "backing_queue_len" || k == "total_queued" {
-			status[k] = v
-		}
+	snapshot := s.mqttSender.GetQueueSnapshot()
+	if !snapshot.Connected {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "Not connected to MQTT broker")
+	}
+	if snapshot.Drain != nil && snapshot.Drain.State == mqtt.DrainRunning {
+		return fiber.NewError(fiber.StatusConflict, "Queue drain already running")
 	}
 
-	return c.JSON(status)
-}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+		defer cancel()
 
-// drainQueue manually drains the message queue
-func (s *Server) drainQueue(c *fiber.Ctx) error {
-	// Assuming a DrainQueues method exists
-	// s.mqttSender.DrainQueues()
+		if _, err := s.mqttSender.DrainQueues(ctx); err != nil {
+			s.logger.Warning("API", "Queue drain failed: %v", err)
+		}
+	}()
 
-	return c.JSON(fiber.Map{
-		"status":  "queue_drain_started",
-		"message": "Queue drain process started",
-		"time":    time.Now().Format(time.RFC3339),
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"status":       "queue_drain_started",
+		"message":      "Queue drain process started",
+		"total_queued": snapshot.TotalQueued,
+		"time":         time.Now().Format(time.RFC3339),
 	})
 }
 
@@ -469,6 +2147,10 @@ func (s *Server) getMessageCount(c *fiber.Ctx) error {
 
 // resendMessage forces a resend of a specific message
 func (s *Server) resendMessage(c *fiber.Ctx) error {
+	if residency.LocalOnly() {
+		return fiber.NewError(fiber.StatusConflict, residency.ErrLocalOnly.Error())
+	}
+
 	idStr := c.Params("id")
 	if idStr == "" {
 		return fiber.NewError(fiber.StatusBadRequest, "Message ID is required")
@@ -542,7 +2224,7 @@ func (s *Server) getLogs(c *fiber.Ctx) error {
 			"timestamp": log.Timestamp.Format(time.RFC3339),
 			"level":     log.Level,
 			"component": log.Component,
-			"message":   log.Message,
+			"message":   redact.String(log.Message),
 		}
 	}
 
@@ -556,6 +2238,76 @@ func (s *Server) getLogs(c *fiber.Ctx) error {
 	})
 }
 
+// searchLogs searches the log messages by substring or regular expression, newest first.
+// The next_cursor of a result continues the search, bucket adds the matches per time slot.
+func (s *Server) searchLogs(c *fiber.Ctx) error {
+	query := log.SearchQuery{
+		Text:       c.Query("q"),
+		Regex:      c.QueryBool("regex"),
+		Levels:     splitList(strings.ToUpper(c.Query("level"))),
+		Components: splitList(c.Query("component")),
+		Cursor:     c.Query("cursor"),
+		Limit:      c.QueryInt("limit", 100),
+	}
+	for param, target := range map[string]*time.Time{"start_time": &query.Start, "end_time": &query.End} {
+		if value := c.Query(param); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, param+" must be an RFC3339 time")
+			}
+			*target = parsed
+		}
+	}
+
+	result, err := s.logService.Search(query)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	formattedLogs := make([]map[string]interface{}, len(result.Logs))
+	for i, entry := range result.Logs {
+		formattedLogs[i] = map[string]interface{}{
+			"id":        entry.ID,
+			"timestamp": entry.Timestamp.Format(time.RFC3339),
+			"level":     entry.Level,
+			"component": entry.Component,
+			"message":   entry.Message,
+		}
+	}
+
+	response := fiber.Map{
+		"logs":        formattedLogs,
+		"count":       len(result.Logs),
+		"next_cursor": result.NextCursor,
+		"scanned":     result.Scanned,
+	}
+
+	if bucket := c.Query("bucket"); bucket != "" {
+		size, err := log.ParseBucketSize(bucket)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		histogram, err := s.logService.Histogram(query, size)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		response["histogram"] = histogram
+	}
+
+	return c.JSON(response)
+}
+
+// splitList reads a comma separated query value, empty items are dropped
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func (s *Server) createLog(c *fiber.Ctx) error {
 	var request LogRequest
 	if err := c.BodyParser(&request); err != nil {
@@ -629,10 +2381,38 @@ func (s *Server) getLogStats(c *fiber.Ctx) error {
 	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, "Failed to get log statistics: "+err.Error())
 	}
+	for key, value := range s.logger.DatabaseTableStats() {
+		stats[key] = value
+	}
 
 	return c.JSON(stats)
 }
 
+// getLogEvents returns the catalog of structured log events forwarded over MQTT
+func (s *Server) getLogEvents(c *fiber.Ctx) error {
+	return c.JSON(logger.Catalog())
+}
+
+// reopenLogFile reopens the log file after an external tool moved it
+func (s *Server) reopenLogFile(c *fiber.Ctx) error {
+	if err := s.logger.Reopen(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to reopen log file: "+err.Error())
+	}
+
+	s.logger.Info("API", "Log file reopened")
+	return c.JSON(fiber.Map{"status": "reopened"})
+}
+
+// rotateLogFile rotates the log file regardless of its size
+func (s *Server) rotateLogFile(c *fiber.Ctx) error {
+	if err := s.logger.ForceRotate(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to rotate log file: "+err.Error())
+	}
+
+	s.logger.Info("API", "Log file rotated")
+	return c.JSON(fiber.Map{"status": "rotated"})
+}
+
 // getCleanupStatus returns the current status of the cleanup service
 func (s *Server) getCleanupStatus(c *fiber.Ctx) error {
 	if s.cleanupService == nil {
@@ -643,6 +2423,15 @@ func (s *Server) getCleanupStatus(c *fiber.Ctx) error {
 	return c.JSON(status)
 }
 
+// previewCleanup reports what the next cleanup would remove per category without deleting
+func (s *Server) previewCleanup(c *fiber.Ctx) error {
+	if s.cleanupService == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "Cleanup service not available")
+	}
+
+	return c.JSON(s.cleanupService.Preview())
+}
+
 // runCleanup triggers an immediate cleanup
 func (s *Server) runCleanup(c *fiber.Ctx) error {
 	if s.cleanupService == nil {
@@ -650,7 +2439,7 @@ func (s *Server) runCleanup(c *fiber.Ctx) error {
 	}
 
 	if err := s.cleanupService.ForceCleanup(); err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("Failed to run cleanup: %v", err))
+		return jobError(err)
 	}
 
 	return c.JSON(fiber.Map{
@@ -672,13 +2461,17 @@ func (s *Server) updateCleanupConfig(c *fiber.Ctx) error {
 	// Parse request
 	var request struct {
 		Enabled                *bool   `json:"enabled"`
+		Schedule               *string `json:"schedule"`
 		IntervalHours          *int    `json:"interval_hours"`
 		LogRetention           *int    `json:"log_retention_days"`
 		MessageRetention       *int    `json:"message_retention_days"`
 		ProcessedFileRetention *int    `json:"processed_file_retention_days"`
 		SyncedFolderRetention  *int    `json:"synced_folder_retention_days"`
+		DataRecordRetention    *int    `json:"data_record_retention_days"`
 		MaxLogFiles            *int    `json:"max_log_files"`
 		MaxPendingMessages     *int    `json:"max_pending_messages"`
+		LogQuotaMB             *int    `json:"log_quota_mb"`
+		DataFileQuotaMB        *int    `json:"data_file_quota_mb"`
 		DataDirectory          *string `json:"data_directory"`
 	}
 
@@ -689,14 +2482,17 @@ func (s *Server) updateCleanupConfig(c *fiber.Ctx) error {
 	// Create new config based on current values
 	newConfig := &cleanup.Config{
 		Enabled:                currentConfig["enabled"].(bool),
-		Interval:               parseDurationString(currentConfig["interval"].(string)),
+		Schedule:               currentConfig["schedule"].(string),
 		LogRetention:           currentConfig["retention"].(map[string]interface{})["logs"].(int),
 		MessageRetention:       currentConfig["retention"].(map[string]interface{})["messages"].(int),
 		ProcessedFileRetention: currentConfig["retention"].(map[string]interface{})["processed_files"].(int),
 		SyncedFolderRetention:  currentConfig["retention"].(map[string]interface{})["synced_folders"].(int),
+		DataRecordRetention:    currentConfig["retention"].(map[string]interface{})["data_records"].(int),
 		MaxLogFiles:            currentConfig["limits"].(map[string]interface{})["max_log_files"].(int),
 		MaxPendingMessages:     currentConfig["limits"].(map[string]interface{})["max_pending_messages"].(int),
-		DataDirectory:          "./data", // Default
+		LogQuotaMB:             currentConfig["quotas"].(map[string]interface{})["log_quota_mb"].(int),
+		DataFileQuotaMB:        currentConfig["quotas"].(map[string]interface{})["data_file_quota_mb"].(int),
+		DataDirectory:          currentConfig["data_directory"].(string),
 	}
 
 	// Apply changes from request
@@ -709,7 +2505,11 @@ func (s *Server) updateCleanupConfig(c *fiber.Ctx) error {
 		if hours < 1 {
 			hours = 1 // Minimum 1 hour
 		}
-		newConfig.Interval = time.Duration(hours) * time.Hour
+		newConfig.Schedule = fmt.Sprintf("@every %dh", hours)
+	}
+
+	if request.Schedule != nil {
+		newConfig.Schedule = *request.Schedule
 	}
 
 	if request.LogRetention != nil {
@@ -728,6 +2528,10 @@ func (s *Server) updateCleanupConfig(c *fiber.Ctx) error {
 		newConfig.SyncedFolderRetention = *request.SyncedFolderRetention
 	}
 
+	if request.DataRecordRetention != nil {
+		newConfig.DataRecordRetention = *request.DataRecordRetention
+	}
+
 	if request.MaxLogFiles != nil {
 		newConfig.MaxLogFiles = *request.MaxLogFiles
 	}
@@ -736,6 +2540,20 @@ func (s *Server) updateCleanupConfig(c *fiber.Ctx) error {
 		newConfig.MaxPendingMessages = *request.MaxPendingMessages
 	}
 
+	if request.LogQuotaMB != nil {
+		if *request.LogQuotaMB < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "log_quota_mb must not be negative")
+		}
+		newConfig.LogQuotaMB = *request.LogQuotaMB
+	}
+
+	if request.DataFileQuotaMB != nil {
+		if *request.DataFileQuotaMB < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "data_file_quota_mb must not be negative")
+		}
+		newConfig.DataFileQuotaMB = *request.DataFileQuotaMB
+	}
+
 	if request.DataDirectory != nil {
 		newConfig.DataDirectory = *request.DataDirectory
 	}
@@ -752,12 +2570,210 @@ func (s *Server) updateCleanupConfig(c *fiber.Ctx) error {
 	})
 }
 
-// Helper function to parse a duration string
-func parseDurationString(durationStr string) time.Duration {
-	duration, err := time.ParseDuration(durationStr)
+// getIntegrityStatus returns quarantine counts and the last scan time
+func (s *Server) getIntegrityStatus(c *fiber.Ctx) error {
+	return c.JSON(s.integrity.GetStatus())
+}
+
+// runIntegrityScan triggers an immediate integrity scan
+func (s *Server) runIntegrityScan(c *fiber.Ctx) error {
+	if err := s.integrity.ForceScan(); err != nil {
+		return jobError(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Integrity scan started",
+	})
+}
+
+// getComponents returns the restartable components with their health
+func (s *Server) getComponents(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"components": s.components.List(),
+		"time":       time.Now().Format(time.RFC3339),
+	})
+}
+
+// restartComponent stops and starts one component and waits until it is healthy
+func (s *Server) restartComponent(c *fiber.Ctx) error {
+	name := c.Params("name")
+
+	result, err := s.components.Restart(name)
+	switch {
+	case errors.Is(err, components.ErrUnknownComponent):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, components.ErrRestartRunning):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case err != nil:
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	if !result.Success {
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+	return c.JSON(result)
+}
+
+// getJobs lists the scheduled jobs with their next run and recent history
+func (s *Server) getJobs(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"jobs": s.scheduler.List(),
+		"time": time.Now().Format(time.RFC3339),
+	})
+}
+
+// getJob returns one scheduled job
+func (s *Server) getJob(c *fiber.Ctx) error {
+	job, err := s.scheduler.Get(c.Params("name"))
+	if err != nil {
+		return jobError(err)
+	}
+	return c.JSON(job)
+}
+
+// runJob starts a job right away and returns the run to poll
+func (s *Server) runJob(c *fiber.Ctx) error {
+	run, err := s.scheduler.RunNow(c.Params("name"))
+	if err != nil {
+		return jobError(err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(run)
+}
+
+// getJobRun returns a run of a job, used to poll a run started with runJob
+func (s *Server) getJobRun(c *fiber.Ctx) error {
+	run, err := s.scheduler.GetRun(c.Params("id"))
+	if err != nil {
+		return jobError(err)
+	}
+	return c.JSON(run)
+}
+
+// jobError maps scheduler errors to HTTP errors
+func jobError(err error) error {
+	switch {
+	case errors.Is(err, scheduler.ErrUnknownJob), errors.Is(err, scheduler.ErrUnknownRun):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, scheduler.ErrAlreadyRunning), errors.Is(err, scheduler.ErrLocked):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, scheduler.ErrNotRunning):
+		return fiber.NewError(fiber.StatusServiceUnavailable, err.Error())
+	default:
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+}
+
+// getRecoveryReports lists the recovery reports, newest first, ?limit= defaults to 20
+func (s *Server) getRecoveryReports(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 20)
+	if limit <= 0 || limit > 500 {
+		return fiber.NewError(fiber.StatusBadRequest, "limit must be between 1 and 500")
+	}
+
+	reports, err := s.recovery.List(limit)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to list recovery reports: "+err.Error())
+	}
+	unacknowledged, err := s.recovery.Unacknowledged()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to get recovery report: "+err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"reports":        reports,
+		"unacknowledged": unacknowledged,
+	})
+}
+
+// acknowledgeRecoveryReport records that the operator has seen a recovery report
+func (s *Server) acknowledgeRecoveryReport(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid report ID")
+	}
+
+	actor := "api"
+	if username, ok := c.Locals("username").(string); ok && username != "" {
+		actor = username
+	} else if session, ok := c.Locals(localSession).(apisession.Session); ok && session.Username != "" {
+		actor = session.Username
+	}
+
+	err = s.recovery.Acknowledge(uint(id), actor)
+	switch {
+	case errors.Is(err, recovery.ErrNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case err != nil:
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to acknowledge recovery report: "+err.Error())
+	}
+	return c.JSON(fiber.Map{"acknowledged": id})
+}
+
+// getDeadLetters lists the messages that failed to publish too often, the most recent first
+func (s *Server) getDeadLetters(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 || limit > 500 {
+		return fiber.NewError(fiber.StatusBadRequest, "limit must be between 1 and 500")
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "offset must not be negative")
+	}
+
+	deadLetters, total, err := s.messageService.ListDeadLetters(limit, offset)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(fiber.Map{
+		"dead_letters": deadLetters,
+		"total":        total,
+		"retry_policy": s.mqttSender.GetRetryPolicy(),
+	})
+}
+
+// requeueDeadLetter sends a dead letter again with a fresh retry count
+func (s *Server) requeueDeadLetter(c *fiber.Ctx) error {
+	if residency.LocalOnly() {
+		return fiber.NewError(fiber.StatusConflict, residency.ErrLocalOnly.Error())
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid dead letter ID")
+	}
+
+	messageID, err := s.mqttSender.RequeueDeadLetter(uint(id), deadLetterActor(c))
+	switch {
+	case errors.Is(err, message.ErrDeadLetterNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case err != nil:
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(fiber.Map{
+		"requeued":   id,
+		"message_id": messageID,
+	})
+}
+
+// requeueDeadLetters sends all dead letters again
+func (s *Server) requeueDeadLetters(c *fiber.Ctx) error {
+	if residency.LocalOnly() {
+		return fiber.NewError(fiber.StatusConflict, residency.ErrLocalOnly.Error())
+	}
+
+	count, err := s.mqttSender.RequeueDeadLetters(deadLetterActor(c))
 	if err != nil {
-		// Default to 24 hours if parsing fails
-		return 24 * time.Hour
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(fiber.Map{"requeued": count})
+}
+
+func deadLetterActor(c *fiber.Ctx) string {
+	if username, ok := c.Locals("username").(string); ok && username != "" {
+		return username
+	} else if session, ok := c.Locals(localSession).(apisession.Session); ok && session.Username != "" {
+		return session.Username
 	}
-	return duration
+	return "api"
 }
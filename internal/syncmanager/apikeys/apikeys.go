@@ -0,0 +1,243 @@
+// Package apikeys issues limited API credentials for third-party monitoring tools. A
+// diagnostics key reads the health, status and metrics of the device and nothing else, it
+// expires and can be revoked without changing the API password.
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"jarvist/pkg/logger"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const ComponentAPIKeys = "api-keys"
+
+// HeaderKey carries the key, a Bearer authorization header works as well
+const HeaderKey = "X-API-Key"
+
+// TokenPrefix starts every key so it is told apart from a session token
+const TokenPrefix = "jvd_"
+
+// ScopeDiagnostics is the read-only scope for monitoring
+const ScopeDiagnostics = "diagnostics"
+
+// Limits of the key lifetime
+const (
+	DefaultTTLDays = 90
+	MaxTTLDays     = 365
+)
+
+// Audit actions of issued and revoked keys
+const (
+	AuditIssue  = "api_key.issue"
+	AuditRevoke = "api_key.revoke"
+)
+
+// usageInterval limits how often the last use of a key is written
+const usageInterval = time.Minute
+
+var (
+	ErrInvalid  = errors.New("API key not found, expired or revoked")
+	ErrNotFound = errors.New("API key not found")
+	ErrRevoked  = errors.New("API key already revoked")
+	ErrScope    = errors.New("API key does not allow this request")
+)
+
+// diagnosticsPaths are the endpoints a diagnostics key may read
+var diagnosticsPaths = map[string]bool{
+	"/api/health":          true,
+	"/api/health/database": true,
+	"/api/status":          true,
+	"/api/metrics":         true,
+	"/metrics":             true,
+}
+
+// Manager issues and checks the keys
+type Manager struct {
+	db     *gorm.DB
+	logger *logger.Logger
+
+	mu       sync.Mutex
+	lastUsed map[uint]time.Time
+}
+
+// New creates the key manager
+func New(db *gorm.DB, logger *logger.Logger) *Manager {
+	return &Manager{
+		db:       db,
+		logger:   logger,
+		lastUsed: make(map[uint]time.Time),
+	}
+}
+
+// Issue creates a diagnostics key valid for ttlDays, 0 uses the default. The token is only
+// returned here.
+func (m *Manager) Issue(name string, ttlDays int, actor string) (string, models.APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", models.APIKey{}, fmt.Errorf("key name is required")
+	}
+	if ttlDays == 0 {
+		ttlDays = DefaultTTLDays
+	}
+	if ttlDays < 1 || ttlDays > MaxTTLDays {
+		return "", models.APIKey{}, fmt.Errorf("key lifetime must be between 1 and %d days", MaxTTLDays)
+	}
+
+	random := make([]byte, 20)
+	if _, err := rand.Read(random); err != nil {
+		return "", models.APIKey{}, err
+	}
+	token := TokenPrefix + hex.EncodeToString(random)
+
+	now := time.Now()
+	key := models.APIKey{
+		Name:      name,
+		Scope:     ScopeDiagnostics,
+		Prefix:    token[:len(TokenPrefix)+8],
+		TokenHash: hashToken(token),
+		CreatedBy: actor,
+		CreatedAt: now,
+		ExpiresAt: now.AddDate(0, 0, ttlDays),
+	}
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&key).Error; err != nil {
+			return err
+		}
+		return audit(tx, AuditIssue, actor, map[string]interface{}{
+			"id":         key.ID,
+			"name":       key.Name,
+			"scope":      key.Scope,
+			"prefix":     key.Prefix,
+			"expires_at": key.ExpiresAt,
+		})
+	})
+	if err != nil {
+		return "", models.APIKey{}, err
+	}
+
+	m.logger.Info(ComponentAPIKeys, "Diagnostics key %d (%s) issued by %s, expires %s",
+		key.ID, key.Name, actor, key.ExpiresAt.Format(time.RFC3339))
+	return token, key, nil
+}
+
+// List returns every key, newest first
+func (m *Manager) List() ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := m.db.Order("id DESC").Find(&keys).Error
+	return keys, err
+}
+
+// Revoke ends a key before it expires
+func (m *Manager) Revoke(id uint, actor, reason string) error {
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		var key models.APIKey
+		if err := tx.First(&key, id).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		} else if err != nil {
+			return err
+		}
+		if key.RevokedAt != nil {
+			return ErrRevoked
+		}
+
+		now := time.Now()
+		if err := tx.Model(&key).Updates(map[string]interface{}{
+			"revoked_at": now,
+			"revoked_by": actor,
+		}).Error; err != nil {
+			return err
+		}
+		return audit(tx, AuditRevoke, actor, map[string]interface{}{
+			"id":     key.ID,
+			"name":   key.Name,
+			"prefix": key.Prefix,
+			"reason": reason,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	m.logger.Info(ComponentAPIKeys, "Diagnostics key %d revoked by %s", id, actor)
+	return nil
+}
+
+// Authenticate returns the key of a token that is neither expired nor revoked and records
+// its use
+func (m *Manager) Authenticate(token, remoteAddr string) (models.APIKey, error) {
+	if !IsKey(token) {
+		return models.APIKey{}, ErrInvalid
+	}
+
+	var key models.APIKey
+	if err := m.db.Where("token_hash = ?", hashToken(token)).First(&key).Error; err != nil {
+		return models.APIKey{}, ErrInvalid
+	}
+	now := time.Now()
+	if key.RevokedAt != nil || !now.Before(key.ExpiresAt) {
+		return models.APIKey{}, ErrInvalid
+	}
+
+	// Pemakaian terakhir cukup dicatat sekali per menit, monitoring biasanya polling rapat
+	m.mu.Lock()
+	due := now.Sub(m.lastUsed[key.ID]) >= usageInterval
+	if due {
+		m.lastUsed[key.ID] = now
+	}
+	m.mu.Unlock()
+	if due {
+		if err := m.db.Model(&key).Updates(map[string]interface{}{
+			"last_used_at":   now,
+			"last_used_from": remoteAddr,
+		}).Error; err != nil {
+			m.logger.Warning(ComponentAPIKeys, "Failed to record use of key %d: %v", key.ID, err)
+		}
+	}
+	return key, nil
+}
+
+// Allows reports whether a key may make a request
+func Allows(key models.APIKey, method, path string) bool {
+	if key.Scope != ScopeDiagnostics {
+		return false
+	}
+	if method != "GET" && method != "HEAD" {
+		return false
+	}
+	return diagnosticsPaths[strings.TrimSuffix(path, "/")]
+}
+
+// IsKey reports whether a token has the form of an API key
+func IsKey(token string) bool {
+	return strings.HasPrefix(token, TokenPrefix)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func audit(tx *gorm.DB, action, actor string, detail map[string]interface{}) error {
+	data, err := json.Marshal(detail)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.AuditEntry{
+		Timestamp: time.Now(),
+		Action:    action,
+		Actor:     actor,
+		Source:    "sync_api",
+		Detail:    string(data),
+	}).Error
+}
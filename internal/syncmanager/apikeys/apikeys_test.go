@@ -0,0 +1,117 @@
+package apikeys
+
+import (
+	"errors"
+	"jarvist/internal/common/models"
+	"jarvist/internal/testutil"
+	"jarvist/pkg/logger"
+	"testing"
+	"time"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+
+	db := testutil.OpenDB(t, &models.APIKey{}, &models.AuditEntry{})
+	return New(db, logger.NewLogger())
+}
+
+func TestIssueAndAuthenticate(t *testing.T) {
+	m := newTestManager(t)
+
+	token, key, err := m.Issue("NOC vendor", 0, "admin")
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if !IsKey(token) || key.Prefix != token[:len(TokenPrefix)+8] || key.TokenHash == token {
+		t.Fatalf("token %q, key %+v", token, key)
+	}
+	if days := key.ExpiresAt.Sub(key.CreatedAt).Hours() / 24; days < DefaultTTLDays-1 || days > DefaultTTLDays+1 {
+		t.Errorf("key expires after %.0f days", days)
+	}
+
+	authenticated, err := m.Authenticate(token, "10.0.0.5")
+	if err != nil || authenticated.ID != key.ID {
+		t.Fatalf("authenticate: %+v, %v", authenticated, err)
+	}
+	var stored models.APIKey
+	m.db.First(&stored, key.ID)
+	if stored.LastUsedAt == nil || stored.LastUsedFrom != "10.0.0.5" {
+		t.Errorf("use not recorded: %+v", stored)
+	}
+
+	if _, err := m.Authenticate(TokenPrefix+"0000", "10.0.0.5"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("unknown key: %v", err)
+	}
+	if _, err := m.Authenticate("session-token", "10.0.0.5"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("session token accepted as key: %v", err)
+	}
+
+	var audits int64
+	m.db.Model(&models.AuditEntry{}).Where("action = ?", AuditIssue).Count(&audits)
+	if audits != 1 {
+		t.Errorf("issue audits = %d", audits)
+	}
+}
+
+func TestRevokeAndExpiry(t *testing.T) {
+	m := newTestManager(t)
+
+	if _, _, err := m.Issue("too long", MaxTTLDays+1, "admin"); err == nil {
+		t.Error("key issued beyond the maximum lifetime")
+	}
+	if _, _, err := m.Issue(" ", 30, "admin"); err == nil {
+		t.Error("key issued without a name")
+	}
+
+	token, key, err := m.Issue("vendor", 30, "admin")
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if err := m.Revoke(key.ID, "admin", "contract ended"); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, err := m.Authenticate(token, "10.0.0.5"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("revoked key accepted: %v", err)
+	}
+	if err := m.Revoke(key.ID, "admin", ""); !errors.Is(err, ErrRevoked) {
+		t.Errorf("second revoke: %v", err)
+	}
+	if err := m.Revoke(999, "admin", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("revoke unknown key: %v", err)
+	}
+
+	expired, key, err := m.Issue("expired", 1, "admin")
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	m.db.Model(&key).Update("expires_at", time.Now().Add(-time.Minute))
+	if _, err := m.Authenticate(expired, "10.0.0.5"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expired key accepted: %v", err)
+	}
+}
+
+func TestAllows(t *testing.T) {
+	key := models.APIKey{Scope: ScopeDiagnostics}
+
+	for _, path := range []string{"/api/health", "/api/health/database", "/api/status", "/api/metrics", "/metrics", "/api/status/"} {
+		if !Allows(key, "GET", path) {
+			t.Errorf("GET %s refused", path)
+		}
+	}
+	for _, request := range [][2]string{
+		{"POST", "/api/metrics/reset"},
+		{"POST", "/api/status"},
+		{"GET", "/api/settings"},
+		{"GET", "/api/status/document"},
+		{"DELETE", "/api/keys/1"},
+		{"POST", "/api/sessions"},
+	} {
+		if Allows(key, request[0], request[1]) {
+			t.Errorf("%s %s allowed", request[0], request[1])
+		}
+	}
+	if Allows(models.APIKey{Scope: "admin"}, "GET", "/api/health") {
+		t.Error("unknown scope allowed")
+	}
+}
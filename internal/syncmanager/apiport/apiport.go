@@ -0,0 +1,106 @@
+// Package apiport binds the sync API port and resolves conflicts with other processes
+package apiport
+
+import (
+	"errors"
+	"fmt"
+	baseConfig "jarvist/internal/common/config"
+	"jarvist/internal/syncmanager/config"
+	"net"
+	"os"
+	"syscall"
+)
+
+// fallbackRangeSize is the number of ports tried when no fallback range end is configured
+const fallbackRangeSize = 10
+
+// ErrPortInUse is returned when the configured port and every fallback port are taken
+var ErrPortInUse = errors.New("API port is in use")
+
+// Listen binds the configured API port. When it is taken by another process the owner is
+// looked up and, if fallback is enabled, the first free port of the fallback range is used.
+// The returned endpoint describes the outcome, also when an error is returned.
+func Listen(cfg *config.Config, port int) (net.Listener, baseConfig.SyncEndpoint, error) {
+	endpoint := baseConfig.SyncEndpoint{
+		ConfiguredPort: port,
+		TLS:            cfg.API.EnableTLS && cfg.API.CertFile != "" && cfg.API.KeyFile != "",
+		PID:            os.Getpid(),
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err == nil {
+		endpoint.Port = port
+		return listener, endpoint, nil
+	}
+	if !isAddrInUse(err) {
+		endpoint.Error = err.Error()
+		return nil, endpoint, err
+	}
+
+	endpoint.Conflict = FindOwner(port)
+
+	if !cfg.API.PortFallback {
+		err = conflictError(port, endpoint.Conflict)
+		endpoint.Error = err.Error()
+		return nil, endpoint, err
+	}
+
+	from, to := FallbackRange(cfg, port)
+	for candidate := from; candidate <= to; candidate++ {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", candidate))
+		if err != nil {
+			continue
+		}
+		endpoint.Port = candidate
+		endpoint.Fallback = true
+		return listener, endpoint, nil
+	}
+
+	err = fmt.Errorf("%w: %d is used by %s and no port in %d-%d is free", ErrPortInUse, port, ownerName(endpoint.Conflict), from, to)
+	endpoint.Error = err.Error()
+	return nil, endpoint, err
+}
+
+// FallbackRange returns the ports tried when the configured port is taken
+func FallbackRange(cfg *config.Config, port int) (int, int) {
+	from := cfg.API.FallbackPortFrom
+	if from <= 0 {
+		from = port + 1
+	}
+	to := cfg.API.FallbackPortTo
+	if to < from {
+		to = from + fallbackRangeSize - 1
+	}
+	return from, min(to, 65535)
+}
+
+// Check reports whether the port is free or held by this process, and otherwise who holds it
+func Check(port int) (bool, *baseConfig.PortOwner) {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err == nil {
+		listener.Close()
+		return true, nil
+	}
+
+	owner := FindOwner(port)
+	if owner != nil && owner.PID == os.Getpid() {
+		return true, owner
+	}
+	return false, owner
+}
+
+func conflictError(port int, owner *baseConfig.PortOwner) error {
+	return fmt.Errorf("%w: %d is used by %s", ErrPortInUse, port, ownerName(owner))
+}
+
+func ownerName(owner *baseConfig.PortOwner) string {
+	if owner == nil {
+		return "an unknown process"
+	}
+	return owner.String()
+}
+
+func isAddrInUse(err error) bool {
+	// WSAEADDRINUSE di Windows, EADDRINUSE di sistem lain
+	return errors.Is(err, syscall.EADDRINUSE) || errors.Is(err, syscall.Errno(10048))
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package apiport
+
+import baseConfig "jarvist/internal/common/config"
+
+// FindOwner is only implemented on Windows, elsewhere the owner is reported as unknown
+func FindOwner(port int) *baseConfig.PortOwner {
+	return nil
+}
@@ -0,0 +1,57 @@
+package apiport
+
+import (
+	"encoding/csv"
+	"fmt"
+	baseConfig "jarvist/internal/common/config"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// FindOwner returns the process listening on a TCP port, nil if it cannot be determined
+func FindOwner(port int) *baseConfig.PortOwner {
+	output, err := hiddenCommand("netstat", "-ano").Output()
+	if err != nil {
+		return nil
+	}
+
+	suffix := ":" + strconv.Itoa(port)
+	for _, line := range strings.Split(string(output), "\n") {
+		// Proto  Local Address  Foreign Address  State  PID
+		fields := strings.Fields(line)
+		if len(fields) != 5 || fields[0] != "TCP" || fields[3] != "LISTENING" || !strings.HasSuffix(fields[1], suffix) {
+			continue
+		}
+
+		pid, err := strconv.Atoi(fields[4])
+		if err != nil {
+			continue
+		}
+		return &baseConfig.PortOwner{PID: pid, Name: processName(pid)}
+	}
+	return nil
+}
+
+func processName(pid int) string {
+	output, err := hiddenCommand("tasklist", "/FI", fmt.Sprintf("PID eq %d", pid), "/FO", "CSV", "/NH").Output()
+	if err != nil {
+		return ""
+	}
+
+	record, err := csv.NewReader(strings.NewReader(string(output))).Read()
+	if err != nil || len(record) < 2 || record[1] != strconv.Itoa(pid) {
+		return ""
+	}
+	return record[0]
+}
+
+func hiddenCommand(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow:    true,
+		CreationFlags: 0x08000000,
+	}
+	return cmd
+}
@@ -0,0 +1,271 @@
+// Package apisession tracks the viewers of the sync API, for example several dashboards
+// watching the same device. A viewer logs in once with the API credentials and then sends
+// a session token. Each session has its own rate limit so one busy viewer does not starve
+// the desktop app or the other viewers. Sessions live in memory and end with the service.
+package apisession
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"jarvist/pkg/logger"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ComponentSessions = "api-sessions"
+
+// HeaderToken carries the session token, a Bearer authorization header works as well
+const HeaderToken = "X-Session-Token"
+
+// rateWindow is the window of the per-session rate limit
+const rateWindow = time.Minute
+
+var (
+	ErrInvalid         = errors.New("session not found or expired")
+	ErrRateLimited     = errors.New("session rate limit exceeded")
+	ErrTooManySessions = errors.New("too many active sessions, log out another viewer first")
+	ErrScope           = errors.New("sessions are limited to read-only requests")
+)
+
+// Allows reports whether a session may make a request. Viewers only read, the one write
+// they may make is ending their own session.
+func Allows(method, path string) bool {
+	if method == "GET" || method == "HEAD" {
+		return true
+	}
+	return method == "DELETE" && strings.TrimSuffix(path, "/") == "/api/sessions/current"
+}
+
+// Config limits the sessions
+type Config struct {
+	TTL         time.Duration // Sessions end this long after login
+	IdleTimeout time.Duration // Sessions end after this long without a request
+	RateLimit   int           // Requests per minute per session, 0 for no limit
+	MaxSessions int           // Concurrent sessions, 0 for no limit
+}
+
+// Credentials returns the current API username and password. Sessions created with other
+// credentials are no longer valid, so changing the password logs every viewer out.
+type Credentials func() (username, password string)
+
+// Session is an active viewer, the token itself is never listed
+type Session struct {
+	ID         string    `json:"id"`
+	Username   string    `json:"username,omitempty"`
+	RemoteAddr string    `json:"remote_addr"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeen   time.Time `json:"last_seen"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Requests   int64     `json:"requests"`
+	Limited    int64     `json:"limited"` // Requests rejected by the rate limit
+
+	token       string
+	credentials string
+	windowStart time.Time
+	windowCount int
+}
+
+// Summary is the viewer count shown in the service status
+type Summary struct {
+	Active      int       `json:"active"`
+	MaxSessions int       `json:"max_sessions"`
+	RateLimit   int       `json:"rate_limit_per_min"`
+	Sessions    []Session `json:"sessions,omitempty"`
+}
+
+// Manager keeps the active sessions
+type Manager struct {
+	cfg         Config
+	credentials Credentials
+	logger      *logger.Logger
+
+	mu       sync.Mutex
+	sessions map[string]*Session // by token
+}
+
+// New creates a session manager
+func New(cfg Config, credentials Credentials, logger *logger.Logger) *Manager {
+	return &Manager{
+		cfg:         cfg,
+		credentials: credentials,
+		logger:      logger,
+		sessions:    make(map[string]*Session),
+	}
+}
+
+// Create starts a session for a viewer that passed the API credentials and returns its token
+func (m *Manager) Create(username, remoteAddr, userAgent string) (string, Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.prune(now)
+	if m.cfg.MaxSessions > 0 && len(m.sessions) >= m.cfg.MaxSessions {
+		return "", Session{}, ErrTooManySessions
+	}
+
+	token, err := randomHex(32)
+	if err != nil {
+		return "", Session{}, err
+	}
+
+	session := &Session{
+		ID:          token[:12],
+		Username:    username,
+		RemoteAddr:  remoteAddr,
+		UserAgent:   userAgent,
+		CreatedAt:   now,
+		LastSeen:    now,
+		ExpiresAt:   now.Add(m.cfg.TTL),
+		token:       token,
+		credentials: m.fingerprint(),
+		windowStart: now,
+	}
+	m.sessions[token] = session
+
+	m.logger.Info(ComponentSessions, "Session %s started for %s from %s (%d active)",
+		session.ID, username, remoteAddr, len(m.sessions))
+	return token, *session, nil
+}
+
+// Use validates a token for one request and counts it against the rate limit of the session
+func (m *Manager) Use(token string) (Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	session, ok := m.sessions[token]
+	if !ok {
+		return Session{}, ErrInvalid
+	}
+	if !m.valid(session, now) {
+		delete(m.sessions, token)
+		return Session{}, ErrInvalid
+	}
+
+	if now.Sub(session.windowStart) >= rateWindow {
+		session.windowStart = now
+		session.windowCount = 0
+	}
+	if m.cfg.RateLimit > 0 && session.windowCount >= m.cfg.RateLimit {
+		session.Limited++
+		return *session, ErrRateLimited
+	}
+
+	session.windowCount++
+	session.Requests++
+	session.LastSeen = now
+	return *session, nil
+}
+
+// RetryAfter returns how long a rate limited session waits for the next window
+func (s Session) RetryAfter() time.Duration {
+	return time.Until(s.windowStart.Add(rateWindow))
+}
+
+// Revoke ends a session by its ID
+func (m *Manager) Revoke(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for token, session := range m.sessions {
+		if session.ID == id {
+			delete(m.sessions, token)
+			m.logger.Info(ComponentSessions, "Session %s of %s revoked", session.ID, session.Username)
+			return true
+		}
+	}
+	return false
+}
+
+// RevokeToken ends the session of a token, used to log out
+func (m *Manager) RevokeToken(token string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sessions[token]; !ok {
+		return false
+	}
+	delete(m.sessions, token)
+	return true
+}
+
+// RevokeAll ends every session, for example after the API credentials were shared or changed
+func (m *Manager) RevokeAll(reason string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := len(m.sessions)
+	m.sessions = make(map[string]*Session)
+
+	if reason == "" {
+		reason = "no reason given"
+	}
+	m.logger.Info(ComponentSessions, "All %d sessions revoked (%s)", count, reason)
+	return count
+}
+
+// Summary returns the active sessions, with the list when detailed is set
+func (m *Manager) Summary(detailed bool) Summary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.prune(time.Now())
+	summary := Summary{
+		Active:      len(m.sessions),
+		MaxSessions: m.cfg.MaxSessions,
+		RateLimit:   m.cfg.RateLimit,
+	}
+	if !detailed {
+		return summary
+	}
+
+	summary.Sessions = make([]Session, 0, len(m.sessions))
+	for _, session := range m.sessions {
+		summary.Sessions = append(summary.Sessions, *session)
+	}
+	sort.Slice(summary.Sessions, func(i, j int) bool {
+		return summary.Sessions[i].CreatedAt.Before(summary.Sessions[j].CreatedAt)
+	})
+	return summary
+}
+
+// prune drops expired sessions, must be called with mu held
+func (m *Manager) prune(now time.Time) {
+	for token, session := range m.sessions {
+		if !m.valid(session, now) {
+			delete(m.sessions, token)
+		}
+	}
+}
+
+// valid must be called with mu held
+func (m *Manager) valid(session *Session, now time.Time) bool {
+	if m.cfg.TTL > 0 && now.After(session.ExpiresAt) {
+		return false
+	}
+	if m.cfg.IdleTimeout > 0 && now.Sub(session.LastSeen) > m.cfg.IdleTimeout {
+		return false
+	}
+	// Sesi dari kredensial lama tidak berlaku lagi setelah password API diganti
+	return session.credentials == m.fingerprint()
+}
+
+func (m *Manager) fingerprint() string {
+	username, password := m.credentials()
+	sum := sha256.Sum256([]byte(username + ":" + password))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
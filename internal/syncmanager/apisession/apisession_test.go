@@ -0,0 +1,28 @@
+package apisession
+
+import "testing"
+
+func TestAllows(t *testing.T) {
+	for _, request := range [][2]string{
+		{"GET", "/api/status"},
+		{"GET", "/api/settings"},
+		{"HEAD", "/api/health"},
+		{"DELETE", "/api/sessions/current"},
+		{"DELETE", "/api/sessions/current/"},
+	} {
+		if !Allows(request[0], request[1]) {
+			t.Errorf("%s %s refused", request[0], request[1])
+		}
+	}
+	for _, request := range [][2]string{
+		{"POST", "/api/sessions"},
+		{"DELETE", "/api/sessions"},
+		{"DELETE", "/api/sessions/abc"},
+		{"PUT", "/api/settings/log_level"},
+		{"POST", "/api/keys"},
+	} {
+		if Allows(request[0], request[1]) {
+			t.Errorf("%s %s allowed", request[0], request[1])
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// Package components restarts individual sync manager components without restarting the
+// whole Windows service.
+package components
+
+import (
+	"errors"
+	"fmt"
+	"jarvist/internal/syncmanager/interfaces"
+	"jarvist/internal/syncmanager/uptime"
+	"jarvist/pkg/logger"
+	"sync"
+	"time"
+)
+
+const (
+	ComponentRegistry = "components"
+
+	// healthTimeout is how long a restarted component gets to report healthy
+	healthTimeout  = 15 * time.Second
+	healthInterval = 500 * time.Millisecond
+)
+
+var (
+	ErrUnknownComponent = errors.New("unknown component")
+	ErrRestartRunning   = errors.New("a component restart is already running")
+)
+
+// RestartResult describes the last restart of a component
+type RestartResult struct {
+	Component  string    `json:"component"`
+	Success    bool      `json:"success"`
+	Healthy    bool      `json:"healthy"`
+	StopMs     int64     `json:"stop_ms"`
+	StartMs    int64     `json:"start_ms"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// Info describes a restartable component
+type Info struct {
+	Key         string         `json:"key"`
+	Name        string         `json:"name"`
+	Healthy     bool           `json:"healthy"`
+	HealthError string         `json:"health_error,omitempty"`
+	Restarting  bool           `json:"restarting"`
+	LastRestart *RestartResult `json:"last_restart,omitempty"`
+}
+
+type entry struct {
+	key       string
+	name      string
+	component interfaces.ServiceComponent
+	last      *RestartResult
+}
+
+// Registry holds the components that may be restarted from the API
+type Registry struct {
+	logger     *logger.Logger
+	mu         sync.Mutex
+	restartMu  sync.Mutex
+	entries    []*entry
+	restarting string
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry(logger *logger.Logger) *Registry {
+	return &Registry{logger: logger}
+}
+
+// Register adds a component under key, the name is taken from Name() when available
+func (r *Registry) Register(key string, component interfaces.ServiceComponent) {
+	name := key
+	if named, ok := component.(interface{ Name() string }); ok {
+		name = named.Name()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, &entry{key: key, name: name, component: component})
+}
+
+// List returns the registered components with their current health
+func (r *Registry) List() []Info {
+	r.mu.Lock()
+	entries := append([]*entry(nil), r.entries...)
+	restarting := r.restarting
+	r.mu.Unlock()
+
+	infos := make([]Info, 0, len(entries))
+	for _, e := range entries {
+		info := Info{
+			Key:        e.key,
+			Name:       e.name,
+			Healthy:    true,
+			Restarting: e.key == restarting,
+		}
+		if err := checkHealth(e.component); err != nil {
+			info.Healthy = false
+			info.HealthError = err.Error()
+		}
+
+		r.mu.Lock()
+		if e.last != nil {
+			last := *e.last
+			info.LastRestart = &last
+		}
+		r.mu.Unlock()
+
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// Restart stops and starts one component and waits until it reports healthy. Only one
+// restart runs at a time.
+func (r *Registry) Restart(key string) (RestartResult, error) {
+	e := r.find(key)
+	if e == nil {
+		return RestartResult{}, fmt.Errorf("%w: %s", ErrUnknownComponent, key)
+	}
+
+	if !r.restartMu.TryLock() {
+		return RestartResult{}, ErrRestartRunning
+	}
+	defer r.restartMu.Unlock()
+
+	r.mu.Lock()
+	r.restarting = key
+	r.mu.Unlock()
+
+	result := RestartResult{Component: key, StartedAt: time.Now()}
+	r.logger.Info(ComponentRegistry, "Restarting component: %s", e.name)
+
+	stopStart := time.Now()
+	if err := e.component.Stop(); err != nil {
+		// Tetap coba start, komponen yang gagal stop biasanya sudah berhenti
+		r.logger.Warning(ComponentRegistry, "Error stopping component %s: %v", e.name, err)
+	}
+	result.StopMs = time.Since(stopStart).Milliseconds()
+
+	startStart := time.Now()
+	err := e.component.Start()
+	result.StartMs = time.Since(startStart).Milliseconds()
+
+	if err == nil {
+		uptime.MarkComponentStarted(e.name)
+		err = waitHealthy(e.component, healthTimeout)
+		result.Healthy = err == nil
+	}
+
+	result.Success = err == nil
+	if err != nil {
+		result.Error = err.Error()
+		r.logger.Error(ComponentRegistry, "Restart of component %s failed: %v", e.name, err)
+	} else {
+		r.logger.Info(ComponentRegistry, "Component %s restarted and healthy", e.name)
+	}
+	result.FinishedAt = time.Now()
+
+	r.mu.Lock()
+	r.restarting = ""
+	e.last = &result
+	r.mu.Unlock()
+
+	return result, nil
+}
+
+func (r *Registry) find(key string) *entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, e := range r.entries {
+		if e.key == key {
+			return e
+		}
+	}
+	return nil
+}
+
+func checkHealth(component interfaces.ServiceComponent) error {
+	if checker, ok := component.(interfaces.HealthChecker); ok {
+		return checker.HealthCheck()
+	}
+	return nil
+}
+
+// waitHealthy polls the health check until it passes or timeout elapses
+func waitHealthy(component interfaces.ServiceComponent, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := checkHealth(component)
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("not healthy after %s: %w", timeout, err)
+		}
+		time.Sleep(healthInterval)
+	}
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Failover limits
+const (
+	DefaultFailoverAfterSec = 30
+	minFailoverAfterSec     = 5
+	maxFailoverAfterSec     = 3600
+	maxBrokers              = 10
+)
+
+// brokersMu guards the broker settings, ApplySyncSettings replaces them while the sender
+// reads them from its own goroutines
+var brokersMu sync.RWMutex
+
+// BrokerEndpoint is one broker of the failover list. The broker with the lowest priority is
+// preferred, the sender fails back to it once it is reachable again.
+type BrokerEndpoint struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`     // 0 uses MQTT.Port
+	Priority int    `json:"priority"` // Lower is preferred, MQTT.Broker has priority 0
+}
+
+// Address returns host:port of the broker
+func (b BrokerEndpoint) Address() string {
+	return net.JoinHostPort(b.Host, strconv.Itoa(b.Port))
+}
+
+// validateBrokers checks a failover list
+func validateBrokers(brokers []BrokerEndpoint) error {
+	if len(brokers) > maxBrokers {
+		return fmt.Errorf("at most %d failover brokers are allowed", maxBrokers)
+	}
+	for i, broker := range brokers {
+		switch {
+		case broker.Host == "":
+			return fmt.Errorf("broker %d: host is required", i+1)
+		case strings.ContainsAny(broker.Host, " /:"):
+			return fmt.Errorf("broker %d: host must be a host name or IP address without scheme or port", i+1)
+		case broker.Port < 0 || broker.Port > 65535:
+			return fmt.Errorf("broker %d: port must be between 1 and 65535, 0 uses the main broker port", i+1)
+		case broker.Priority < 0:
+			return fmt.Errorf("broker %d: priority cannot be negative", i+1)
+		}
+	}
+	return nil
+}
+
+// ParseBrokers reads a failover list stored as a JSON array
+func ParseBrokers(value string) ([]BrokerEndpoint, error) {
+	var brokers []BrokerEndpoint
+	if err := json.Unmarshal([]byte(value), &brokers); err != nil {
+		return nil, fmt.Errorf("invalid broker list: %w", err)
+	}
+	if err := validateBrokers(brokers); err != nil {
+		return nil, err
+	}
+	return brokers, nil
+}
+
+// validateFailoverAfter checks the failover delay, 0 keeps the default
+func validateFailoverAfter(seconds int) error {
+	if seconds != 0 && (seconds < minFailoverAfterSec || seconds > maxFailoverAfterSec) {
+		return fmt.Errorf("failover_after_sec must be between %d and %d", minFailoverAfterSec, maxFailoverAfterSec)
+	}
+	return nil
+}
+
+// BrokerEndpoints returns the brokers in the order they are tried: MQTT.Broker with priority
+// 0 followed by the failover brokers by priority. Brokers listed twice are kept once.
+func (c *Config) BrokerEndpoints() []BrokerEndpoint {
+	brokersMu.RLock()
+	defer brokersMu.RUnlock()
+
+	var endpoints []BrokerEndpoint
+	seen := make(map[string]bool)
+	add := func(broker BrokerEndpoint) {
+		if broker.Port == 0 {
+			broker.Port = c.MQTT.Port
+		}
+		if broker.Host == "" || seen[broker.Address()] {
+			return
+		}
+		seen[broker.Address()] = true
+		endpoints = append(endpoints, broker)
+	}
+
+	add(BrokerEndpoint{Host: c.MQTT.Broker, Port: c.MQTT.Port})
+	failover := append([]BrokerEndpoint(nil), c.MQTT.Brokers...)
+	sort.SliceStable(failover, func(i, j int) bool {
+		return failover[i].Priority < failover[j].Priority
+	})
+	for _, broker := range failover {
+		add(broker)
+	}
+	return endpoints
+}
+
+// FailoverAfter returns how long a broker may be unreachable before the next one is tried
+func (c *Config) FailoverAfter() time.Duration {
+	brokersMu.RLock()
+	defer brokersMu.RUnlock()
+
+	if c.MQTT.FailoverAfterSec <= 0 {
+		return DefaultFailoverAfterSec * time.Second
+	}
+	return time.Duration(c.MQTT.FailoverAfterSec) * time.Second
+}
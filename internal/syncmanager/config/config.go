@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	baseConfig "jarvist/internal/common/config"
+	"jarvist/internal/common/redact"
 	"time"
 )
 
@@ -39,6 +40,12 @@ type Config struct {
 		EnableTLS   bool   `json:"enable_tls"`
 		CACertPath  string `json:"ca_cert_path"`
 		EncryptData bool   `json:"encrypt_data"`
+		// Staging publishes under <topic>-staging and tags payloads with "environment":"staging"
+		Staging bool `json:"staging"`
+		// Brokers are tried after Broker when it is unreachable for FailoverAfterSec, 0 uses
+		// the default. The sender fails back once a preferred broker is reachable again.
+		Brokers          []BrokerEndpoint `json:"brokers"`
+		FailoverAfterSec int              `json:"failover_after_sec"`
 	} `json:"mqtt"`
 
 	// API settings
@@ -50,6 +57,18 @@ type Config struct {
 		EnableTLS bool   `json:"enable_tls"`
 		CertFile  string `json:"cert_file"`
 		KeyFile   string `json:"key_file"`
+
+		// PortFallback listens on the first free port of the fallback range when Port is taken.
+		// The chosen port is recorded for the desktop app in sync_endpoint.json.
+		PortFallback     bool `json:"port_fallback"`
+		FallbackPortFrom int  `json:"fallback_port_from"` // 0 means Port+1
+		FallbackPortTo   int  `json:"fallback_port_to"`   // 0 means FallbackPortFrom+9
+
+		// Viewer sessions, a viewer logs in once and sends the session token afterwards
+		SessionTTLMin    int `json:"session_ttl_min"`    // Sessions end this long after login
+		SessionIdleMin   int `json:"session_idle_min"`   // Sessions end after this long without a request
+		SessionRateLimit int `json:"session_rate_limit"` // Requests per minute per session, 0 for no limit
+		MaxSessions      int `json:"max_sessions"`       // Concurrent viewers, 0 for no limit
 	} `json:"api"`
 
 	// Service settings
@@ -57,6 +76,9 @@ type Config struct {
 		Name        string `json:"name"`
 		DisplayName string `json:"display_name"`
 		Description string `json:"description"`
+
+		WatchdogIntervalSec int `json:"watchdog_interval_sec"` // Health check interval of the watchdog
+		WatchdogFailureSec  int `json:"watchdog_failure_sec"`  // Restart after being unhealthy this long, 0 to never restart
 	} `json:"service"`
 
 	// Logging settings
@@ -68,12 +90,40 @@ type Config struct {
 	//Sync setting
 	Sync struct {
 		Interval int `json:"sync_interval"`
+		// CompressAfterHours compresses unprocessed data files older than this, 0 disables
+		CompressAfterHours int `json:"compress_after_hours"`
+		// ArchiveAfterDays stops watching and scanning date folders older than this and
+		// compresses them, they are then only processed by resync. 0 disables.
+		ArchiveAfterDays int `json:"archive_after_days"`
+		// CounterCacheHours is how long the people counter keeps exported data in its local
+		// cache. Gaps within this period are requested again, 0 disables backfill requests.
+		CounterCacheHours int `json:"counter_cache_hours"`
+	}
+
+	// Sender pacing, overridable at runtime through the settings table
+	Sender struct {
+		PublishDelayMs int `json:"publish_delay_ms"`
+		BatchSize      int `json:"batch_size"`
+		BatchPauseMs   int `json:"batch_pause_ms"`
+		QueueCapacity  int `json:"queue_capacity"`
+	}
+
+	// Maintenance mode pauses publishing and alerts, it expires after the requested duration
+	Maintenance struct {
+		DefaultDurationMin int `json:"default_duration_min"`
+		MaxDurationMin     int `json:"max_duration_min"` // 0 for no limit
 	}
 
 	Logger struct {
 		EnableMQTTLogs bool `json:"mqtt_Logs"`
 		EnableDBLogs   bool `json:"db_Logs"`
+		DbMaxRows      int  `json:"db_max_rows"`    // Row limit of the log table, 0 for no limit
+		DbMaxSizeMB    int  `json:"db_max_size_mb"` // Size limit of the log table, 0 for no limit
 	}
+
+	// Redaction masks credentials, RTSP URLs and personal data in MQTT payloads and exported
+	// logs. Extra rules can also be stored in the redaction_rules setting.
+	Redaction redact.Config `json:"redaction"`
 }
 
 func LoadConfig(buildMode string, baseConfig *baseConfig.Config) *Config {
@@ -96,17 +146,41 @@ func LoadConfig(buildMode string, baseConfig *baseConfig.Config) *Config {
 	cfg.MQTT.EnableTLS = false
 	cfg.MQTT.CACertPath = ""
 	cfg.MQTT.EncryptData = true
+	cfg.MQTT.FailoverAfterSec = DefaultFailoverAfterSec
 
 	cfg.Service.Name = ServiceName
 	cfg.Service.DisplayName = ServiceDisplayName
 	cfg.Service.Description = ServiceDescription
+	cfg.Service.WatchdogIntervalSec = 30
+	cfg.Service.WatchdogFailureSec = 300
+
+	cfg.API.PortFallback = true
+	cfg.API.SessionTTLMin = 8 * 60
+	cfg.API.SessionIdleMin = 30
+	cfg.API.SessionRateLimit = 120
+	cfg.API.MaxSessions = 10
 
 	cfg.Advanced.MaxQueueWorkers = 5
 	cfg.Advanced.FernetKey = "0yhvieBf7ZfOWRAQdeKOtzTAvGD5OCFSIivbfOjn3Ug="
 
+	cfg.Sender.PublishDelayMs = 50
+	cfg.Sender.BatchSize = 20
+	cfg.Sender.BatchPauseMs = 500
+	cfg.Sender.QueueCapacity = 1000
+
+	cfg.Maintenance.DefaultDurationMin = 60
+	cfg.Maintenance.MaxDurationMin = 24 * 60
+
 	cfg.Sync.Interval = 60
+	cfg.Sync.CompressAfterHours = 24
+	cfg.Sync.ArchiveAfterDays = 7
+	cfg.Sync.CounterCacheHours = 24
 	cfg.Logger.EnableMQTTLogs = true
 	cfg.Logger.EnableDBLogs = true
+	cfg.Logger.DbMaxRows = 200000
+	cfg.Logger.DbMaxSizeMB = 100
+	cfg.Redaction.Enabled = true
+	cfg.Redaction.Defaults = true
 
 	if buildMode == "production" {
 		setupProdConfigs(cfg)
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Setting keys of the sync settings edited from the desktop app. An empty or missing value
+// keeps the built-in config.
+const (
+	SyncIntervalKey        = "sync_interval_sec"
+	LogRetentionKey        = "cleanup_log_retention_days"
+	MessageRetentionKey    = "cleanup_message_retention_days"
+	DataRecordRetentionKey = "cleanup_data_record_retention_days"
+	BrokerKey              = "mqtt_broker"
+	BrokerPortKey          = "mqtt_port"
+	BrokerUsernameKey      = "mqtt_username"
+	BrokerPasswordKey      = "mqtt_password"
+	BrokerTLSKey           = "mqtt_enable_tls"
+	StagingKey             = "mqtt_staging"
+	FailoverBrokersKey     = "mqtt_failover_brokers"
+	FailoverAfterKey       = "mqtt_failover_after_sec"
+)
+
+// Limits of the sync settings
+const (
+	minSyncIntervalSec = 10
+	maxSyncIntervalSec = 3600
+	maxRetentionDays   = 3650
+)
+
+// SyncSettings are the sync interval, retention and broker settings field engineers change
+// without editing config. Zero values are not overridden.
+type SyncSettings struct {
+	SyncIntervalSec         int    `json:"sync_interval_sec"`
+	LogRetentionDays        int    `json:"log_retention_days"`
+	MessageRetentionDays    int    `json:"message_retention_days"`
+	DataRecordRetentionDays int    `json:"data_record_retention_days"`
+	Broker                  string `json:"broker"`
+	Port                    int    `json:"port"`
+	Username                string `json:"username"`
+	Password                string `json:"password,omitempty"`
+	EnableTLS               bool   `json:"enable_tls"`
+	// Staging routes all publishes to the -staging topic namespace for test devices
+	Staging bool `json:"staging"`
+	// Brokers are the failover brokers, stored as JSON. An empty list keeps the config.
+	Brokers          []BrokerEndpoint `json:"brokers"`
+	FailoverAfterSec int              `json:"failover_after_sec"`
+}
+
+// Validate checks the settings against the limits
+func (s SyncSettings) Validate() error {
+	switch {
+	case s.SyncIntervalSec != 0 && (s.SyncIntervalSec < minSyncIntervalSec || s.SyncIntervalSec > maxSyncIntervalSec):
+		return fmt.Errorf("sync_interval_sec must be between %d and %d", minSyncIntervalSec, maxSyncIntervalSec)
+	case s.LogRetentionDays < 0 || s.LogRetentionDays > maxRetentionDays:
+		return fmt.Errorf("log_retention_days must be between 0 and %d days, 0 keeps the default", maxRetentionDays)
+	case s.MessageRetentionDays < 0 || s.MessageRetentionDays > maxRetentionDays:
+		return fmt.Errorf("message_retention_days must be between 0 and %d days, 0 keeps the default", maxRetentionDays)
+	case s.DataRecordRetentionDays < 0 || s.DataRecordRetentionDays > maxRetentionDays:
+		return fmt.Errorf("data_record_retention_days must be between 0 and %d days, 0 keeps the default", maxRetentionDays)
+	case strings.ContainsAny(s.Broker, " /:"):
+		return errors.New("broker must be a host name or IP address without scheme or port")
+	case s.Port < 0 || s.Port > 65535:
+		return errors.New("port must be between 1 and 65535, 0 keeps the default")
+	}
+	if err := validateBrokers(s.Brokers); err != nil {
+		return err
+	}
+	return validateFailoverAfter(s.FailoverAfterSec)
+}
+
+// LoadSyncSettings reads the stored overrides, invalid numbers are ignored
+func LoadSyncSettings(db *gorm.DB) SyncSettings {
+	number := func(key string) int {
+		value, err := strconv.Atoi(getSetting(db, key))
+		if err != nil || value < 0 {
+			return 0
+		}
+		return value
+	}
+
+	settings := SyncSettings{
+		SyncIntervalSec:         number(SyncIntervalKey),
+		LogRetentionDays:        number(LogRetentionKey),
+		MessageRetentionDays:    number(MessageRetentionKey),
+		DataRecordRetentionDays: number(DataRecordRetentionKey),
+		Broker:                  getSetting(db, BrokerKey),
+		Port:                    number(BrokerPortKey),
+		Username:                getSetting(db, BrokerUsernameKey),
+		Password:                getSetting(db, BrokerPasswordKey),
+		FailoverAfterSec:        number(FailoverAfterKey),
+	}
+	if value := getSetting(db, FailoverBrokersKey); value != "" {
+		if brokers, err := ParseBrokers(value); err == nil {
+			settings.Brokers = brokers
+		}
+	}
+	settings.EnableTLS, _ = strconv.ParseBool(getSetting(db, BrokerTLSKey))
+	settings.Staging, _ = strconv.ParseBool(getSetting(db, StagingKey))
+	return settings
+}
+
+// SaveSyncSettings validates and stores the overrides
+func SaveSyncSettings(db *gorm.DB, settings SyncSettings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	brokers := ""
+	if len(settings.Brokers) > 0 {
+		data, err := json.Marshal(settings.Brokers)
+		if err != nil {
+			return err
+		}
+		brokers = string(data)
+	}
+
+	values := map[string]string{
+		SyncIntervalKey:        strconv.Itoa(settings.SyncIntervalSec),
+		LogRetentionKey:        strconv.Itoa(settings.LogRetentionDays),
+		MessageRetentionKey:    strconv.Itoa(settings.MessageRetentionDays),
+		DataRecordRetentionKey: strconv.Itoa(settings.DataRecordRetentionDays),
+		BrokerKey:              settings.Broker,
+		BrokerPortKey:          strconv.Itoa(settings.Port),
+		BrokerUsernameKey:      settings.Username,
+		BrokerPasswordKey:      settings.Password,
+		BrokerTLSKey:           strconv.FormatBool(settings.EnableTLS),
+		StagingKey:             strconv.FormatBool(settings.Staging),
+		FailoverBrokersKey:     brokers,
+		FailoverAfterKey:       strconv.Itoa(settings.FailoverAfterSec),
+	}
+	for key, value := range values {
+		if err := saveSetting(db, key, value); err != nil {
+			return fmt.Errorf("failed to save %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// ApplySyncSettings overrides the sync, broker and staging config with the stored settings. It
+// returns the components that have to restart to pick up the change.
+func (c *Config) ApplySyncSettings(settings SyncSettings) []string {
+	var restart []string
+
+	if settings.SyncIntervalSec > 0 && settings.SyncIntervalSec != c.Sync.Interval {
+		c.Sync.Interval = settings.SyncIntervalSec
+		restart = append(restart, "synchronizer")
+	}
+
+	broker := c.MQTT.Broker
+	port := c.MQTT.Port
+	username := c.MQTT.Username
+	password := c.MQTT.Password
+	if settings.Broker != "" {
+		broker = settings.Broker
+	}
+	if settings.Port > 0 {
+		port = settings.Port
+	}
+	// Username dan password hanya diganti bersama agar kredensial tidak tercampur
+	if settings.Username != "" {
+		username = settings.Username
+		password = settings.Password
+	}
+
+	brokers := c.MQTT.Brokers
+	failoverAfter := c.MQTT.FailoverAfterSec
+	if len(settings.Brokers) > 0 {
+		brokers = settings.Brokers
+	}
+	if settings.FailoverAfterSec > 0 {
+		failoverAfter = settings.FailoverAfterSec
+	}
+
+	if broker != c.MQTT.Broker || port != c.MQTT.Port || username != c.MQTT.Username ||
+		password != c.MQTT.Password || settings.EnableTLS != c.MQTT.EnableTLS ||
+		settings.Staging != c.MQTT.Staging || !slices.Equal(brokers, c.MQTT.Brokers) ||
+		failoverAfter != c.MQTT.FailoverAfterSec {
+		brokersMu.Lock()
+		c.MQTT.Broker = broker
+		c.MQTT.Port = port
+		c.MQTT.Username = username
+		c.MQTT.Password = password
+		c.MQTT.EnableTLS = settings.EnableTLS
+		c.MQTT.Staging = settings.Staging
+		c.MQTT.Brokers = brokers
+		c.MQTT.FailoverAfterSec = failoverAfter
+		brokersMu.Unlock()
+		restart = append(restart, "mqtt_sender")
+	}
+
+	return restart
+}
+
+func getSetting(db *gorm.DB, key string) string {
+	var setting models.Setting
+	if err := db.Where("key = ?", key).First(&setting).Error; err != nil {
+		return ""
+	}
+	return strings.TrimSpace(setting.Value)
+}
+
+func saveSetting(db *gorm.DB, key, value string) error {
+	var setting models.Setting
+	result := db.Where("key = ?", key).First(&setting)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return db.Create(&models.Setting{Key: key, Value: value}).Error
+	} else if result.Error != nil {
+		return result.Error
+	}
+
+	setting.Value = value
+	return db.Save(&setting).Error
+}
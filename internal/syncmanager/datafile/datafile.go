@@ -0,0 +1,133 @@
+package datafile
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fernet/fernet-go"
+	"github.com/klauspost/compress/zstd"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	Suffix           = ".json.bson"
+	CompressedSuffix = ".zst"
+
+	// fernetOverhead is version (1) + timestamp (8) + IV (16) + HMAC (32)
+	fernetOverhead  = 57
+	fernetBlockSize = 16
+)
+
+var (
+	// ErrEmpty is returned for zero-byte files
+	ErrEmpty = errors.New("file is empty")
+	// ErrTruncated is returned when the token is incomplete or malformed
+	ErrTruncated = errors.New("file is truncated or malformed")
+	// ErrUndecryptable is returned when the token is complete but fails verification
+	ErrUndecryptable = errors.New("failed to decrypt: invalid token or key")
+	// ErrCorrupt is returned when the decrypted payload is not valid BSON
+	ErrCorrupt = errors.New("decrypted payload is not valid BSON")
+)
+
+// IsDataFile returns true for plain and compressed data files
+func IsDataFile(name string) bool {
+	return strings.HasSuffix(name, Suffix) || strings.HasSuffix(name, Suffix+CompressedSuffix)
+}
+
+// LogicalName strips the compression suffix so a file keeps the same
+// processed-file key before and after it is compressed
+func LogicalName(name string) string {
+	return strings.TrimSuffix(name, CompressedSuffix)
+}
+
+// Read reads a data file, transparently decompressing .zst files
+func Read(filePath string) ([]byte, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(filePath, CompressedSuffix) {
+		return data, nil
+	}
+
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
+	}
+	defer decoder.Close()
+
+	decoded, err := decoder.DecodeAll(data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTruncated, err)
+	}
+	return decoded, nil
+}
+
+// Decode decrypts a Fernet token and unmarshals the BSON payload.
+// Errors wrap ErrEmpty, ErrTruncated, ErrUndecryptable or ErrCorrupt.
+func Decode(token []byte, fernetKey string) (map[string]interface{}, error) {
+	if len(token) == 0 {
+		return nil, ErrEmpty
+	}
+
+	key, err := fernet.DecodeKey(fernetKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Fernet key: %w", err)
+	}
+
+	msg := fernet.VerifyAndDecrypt(token, 0, []*fernet.Key{key})
+	if msg == nil {
+		if !wellFormed(token) {
+			return nil, ErrTruncated
+		}
+		return nil, ErrUndecryptable
+	}
+
+	var result map[string]interface{}
+	if err := bson.Unmarshal(msg, &result); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+
+	return result, nil
+}
+
+// Encode marshals a document to BSON and encrypts it as a Fernet token, the format the
+// counter writes. Used to build synthetic data files for the installation verification.
+func Encode(doc map[string]interface{}, fernetKey string) ([]byte, error) {
+	key, err := fernet.DecodeKey(fernetKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Fernet key: %w", err)
+	}
+
+	msg, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal BSON: %w", err)
+	}
+
+	return fernet.EncryptAndSign(msg, key)
+}
+
+// ReadAndDecode reads, decompresses and decrypts a data file
+func ReadAndDecode(filePath, fernetKey string) (map[string]interface{}, error) {
+	data, err := Read(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data, fernetKey)
+}
+
+// wellFormed checks the token has a complete Fernet structure
+func wellFormed(token []byte) bool {
+	raw, err := base64.URLEncoding.DecodeString(strings.TrimSpace(string(token)))
+	if err != nil {
+		return false
+	}
+	if len(raw) < fernetOverhead+fernetBlockSize {
+		return false
+	}
+	return (len(raw)-fernetOverhead)%fernetBlockSize == 0
+}
@@ -0,0 +1,95 @@
+// Package fleetstatus publishes the status document a fleet dashboard builds device
+// liveness from. One versioned document covers component health, queue depths, versions and
+// license state, so the cloud does not have to combine heartbeats, logs and alerts.
+package fleetstatus
+
+import (
+	_ "embed"
+	"jarvist/internal/common/licensestate"
+	"time"
+)
+
+// SchemaVersion is the version of the document contract. Fields may be added within a
+// version, renaming or removing one needs a new version.
+const SchemaVersion = 1
+
+// DocumentType is the type field of every status document
+const DocumentType = "status"
+
+// Schema is the JSON schema of the document, served by the API for the backend to validate
+// against
+//
+//go:embed status.schema.json
+var Schema []byte
+
+// Document is the status of one device
+type Document struct {
+	SchemaVersion int                `json:"schema_version"`
+	Type          string             `json:"type"`
+	ClientID      string             `json:"client_id"`
+	Site          Site               `json:"site"`
+	Tags          map[string]string  `json:"tags,omitempty"`
+	Timestamp     time.Time          `json:"timestamp"`
+	Sequence      uint64             `json:"sequence"`
+	IntervalSec   int                `json:"interval_sec"`
+	Healthy       bool               `json:"healthy"`
+	Versions      Versions           `json:"versions"`
+	Uptime        Uptime             `json:"uptime"`
+	Components    []Component        `json:"components"`
+	Queues        Queues             `json:"queues"`
+	Connectivity  Connectivity       `json:"connectivity"`
+	License       licensestate.State `json:"license"`
+	Mode          Mode               `json:"mode"`
+}
+
+// Site is the identity the device reports as
+type Site struct {
+	TenantID string `json:"tenant_id"`
+	ClientID string `json:"client_id"`
+	SiteID   string `json:"site_id"`
+	SiteCode string `json:"site_code"`
+}
+
+// Versions of the sync service
+type Versions struct {
+	Product string `json:"product"`
+	File    string `json:"file"`
+}
+
+// Uptime of the sync service
+type Uptime struct {
+	StartedAt time.Time `json:"started_at"`
+	Seconds   int64     `json:"seconds"`
+}
+
+// Component is the health of one component. Components without a health check are
+// healthy once started.
+type Component struct {
+	Name      string     `json:"name"`
+	Healthy   bool       `json:"healthy"`
+	Error     string     `json:"error,omitempty"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+}
+
+// Queues are the depths of the send queues
+type Queues struct {
+	Pending  int64 `json:"pending"`
+	Deferred int64 `json:"deferred"`
+	InMemory int   `json:"in_memory"`
+}
+
+// Connectivity of the device
+type Connectivity struct {
+	Network         string `json:"network"`
+	BrokerReachable bool   `json:"broker_reachable"`
+	MQTTConnected   bool   `json:"mqtt_connected"`
+}
+
+// Mode describes why data may be held back
+type Mode struct {
+	Environment  string `json:"environment"`
+	Maintenance  bool   `json:"maintenance"`
+	UploadPaused bool   `json:"upload_paused"`
+	Residency    string `json:"residency"`
+	Registration string `json:"registration"`
+}
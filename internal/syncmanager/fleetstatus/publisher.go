@@ -0,0 +1,339 @@
+package fleetstatus
+
+import (
+	"encoding/json"
+	"fmt"
+	"jarvist/internal/common/identity"
+	"jarvist/internal/common/models"
+	"jarvist/internal/common/residency"
+	"jarvist/internal/syncmanager/config"
+	"jarvist/internal/syncmanager/mqtt"
+	"jarvist/internal/syncmanager/network"
+	"jarvist/internal/syncmanager/uptime"
+	"jarvist/internal/syncmanager/watchdog"
+	"jarvist/pkg/logger"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ComponentStatus is the log component of the status publisher
+const ComponentStatus = "fleet-status"
+
+// IntervalKey is the setting key of the seconds between status documents
+const IntervalKey = "status_interval_sec"
+
+// Limits of the status interval
+const (
+	DefaultIntervalSec = 60
+	MinIntervalSec     = 15
+	MaxIntervalSec     = 3600
+)
+
+// checkInterval is how often the publisher looks whether a document is due, a reconnect is
+// noticed within it
+var checkInterval = 5 * time.Second
+
+// SenderSource is the MQTT sender as seen by the publisher
+type SenderSource interface {
+	Connected() bool
+	StatusSnapshot() (mqtt.StatusSnapshot, error)
+	PublishStatus(payload []byte) error
+}
+
+// NetworkSource provides the connectivity state
+type NetworkSource interface {
+	GetStatus() network.Status
+}
+
+// HealthSource provides the results of the component health checks
+type HealthSource interface {
+	GetStatus() watchdog.Status
+}
+
+// Status describes the publisher
+type Status struct {
+	Running       bool       `json:"running"`
+	Topic         string     `json:"topic"`
+	SchemaVersion int        `json:"schema_version"`
+	IntervalSec   int        `json:"interval_sec"`
+	Sequence      uint64     `json:"sequence"`
+	LastPublished *time.Time `json:"last_published,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+}
+
+// Publisher publishes the status document at a fixed interval and right after every
+// broker connect
+type Publisher struct {
+	cfg     *config.Config
+	db      *gorm.DB
+	logger  *logger.Logger
+	sender  SenderSource
+	network NetworkSource
+	health  HealthSource
+
+	mu            sync.Mutex
+	running       bool
+	sequence      uint64
+	lastPublished time.Time
+	lastError     string
+	quitChan      chan struct{}
+	wg            sync.WaitGroup
+}
+
+// New creates the status publisher
+func New(cfg *config.Config, db *gorm.DB, sender SenderSource, network NetworkSource, health HealthSource, logger *logger.Logger) *Publisher {
+	return &Publisher{
+		cfg:     cfg,
+		db:      db,
+		logger:  logger,
+		sender:  sender,
+		network: network,
+		health:  health,
+	}
+}
+
+// Name returns the component name used in startup logs
+func (p *Publisher) Name() string {
+	return "Status publisher"
+}
+
+// Start begins publishing
+func (p *Publisher) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.running {
+		return nil
+	}
+	p.running = true
+	p.quitChan = make(chan struct{})
+
+	p.wg.Add(1)
+	go p.run(p.quitChan)
+
+	p.logger.Info(ComponentStatus, "Publishing status documents v%d to %s every %ds",
+		SchemaVersion, p.topic(), LoadIntervalSec(p.db))
+	return nil
+}
+
+// Stop ends publishing
+func (p *Publisher) Stop() error {
+	p.mu.Lock()
+	if !p.running {
+		p.mu.Unlock()
+		return nil
+	}
+	p.running = false
+	close(p.quitChan)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	return nil
+}
+
+// GetStatus returns the publisher state
+func (p *Publisher) GetStatus() Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := Status{
+		Running:       p.running,
+		Topic:         p.topic(),
+		SchemaVersion: SchemaVersion,
+		IntervalSec:   LoadIntervalSec(p.db),
+		Sequence:      p.sequence,
+		LastError:     p.lastError,
+	}
+	if !p.lastPublished.IsZero() {
+		lastPublished := p.lastPublished
+		status.LastPublished = &lastPublished
+	}
+	return status
+}
+
+// Build returns the current document without publishing it, the sequence is the one of
+// the last published document
+func (p *Publisher) Build() (Document, error) {
+	p.mu.Lock()
+	sequence := p.sequence
+	p.mu.Unlock()
+	return p.build(sequence)
+}
+
+func (p *Publisher) run(quitChan chan struct{}) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	wasConnected := false
+	var last time.Time
+	for {
+		select {
+		case <-ticker.C:
+		case <-quitChan:
+			return
+		}
+
+		connected := p.sender.Connected()
+		due := connected && (!wasConnected || time.Since(last) >= time.Duration(LoadIntervalSec(p.db))*time.Second)
+		wasConnected = connected
+		if !due {
+			continue
+		}
+
+		last = time.Now()
+		if err := p.publish(); err != nil {
+			p.logger.Warning(ComponentStatus, "Failed to publish status document: %v", err)
+		}
+	}
+}
+
+// publish builds the next document and publishes it
+func (p *Publisher) publish() error {
+	p.mu.Lock()
+	p.sequence++
+	sequence := p.sequence
+	p.mu.Unlock()
+
+	doc, err := p.build(sequence)
+	if err == nil {
+		var payload []byte
+		payload, err = json.Marshal(doc)
+		if err == nil {
+			err = p.sender.PublishStatus(payload)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		p.lastError = err.Error()
+		return err
+	}
+	p.lastError = ""
+	p.lastPublished = doc.Timestamp
+	return nil
+}
+
+func (p *Publisher) build(sequence uint64) (Document, error) {
+	snapshot, err := p.sender.StatusSnapshot()
+	if err != nil {
+		return Document{}, fmt.Errorf("failed to read the send queues: %w", err)
+	}
+
+	site := identity.Get(p.db)
+	info := uptime.Get()
+	now := time.Now()
+
+	doc := Document{
+		SchemaVersion: SchemaVersion,
+		Type:          DocumentType,
+		ClientID:      p.cfg.MQTT.ClientID,
+		Site: Site{
+			TenantID: site.TenantID,
+			ClientID: site.ClientID,
+			SiteID:   site.SiteID,
+			SiteCode: site.SiteCode,
+		},
+		Tags:        site.Tags,
+		Timestamp:   now,
+		Sequence:    sequence,
+		IntervalSec: LoadIntervalSec(p.db),
+		Uptime: Uptime{
+			StartedAt: info.StartedAt,
+			Seconds:   info.UptimeSeconds,
+		},
+		Queues: Queues{
+			Pending:  snapshot.Pending,
+			Deferred: snapshot.Deferred,
+			InMemory: snapshot.InMemory,
+		},
+		Connectivity: Connectivity{
+			MQTTConnected: snapshot.Connected,
+		},
+		License: snapshot.License,
+		Mode: Mode{
+			Environment:  snapshot.Environment,
+			Maintenance:  snapshot.Maintenance,
+			UploadPaused: snapshot.UploadPaused,
+			Residency:    snapshot.Residency.Mode,
+			Registration: snapshot.Registration,
+		},
+	}
+	if doc.Mode.Residency == "" {
+		doc.Mode.Residency = residency.ModeCloud
+	}
+	if p.cfg.BaseConfig != nil {
+		doc.Versions = Versions{
+			Product: p.cfg.BaseConfig.BuildInfo.ProductVersion,
+			File:    p.cfg.BaseConfig.BuildInfo.FileVersion,
+		}
+	}
+	if p.network != nil {
+		status := p.network.GetStatus()
+		doc.Connectivity.Network = status.State
+		doc.Connectivity.BrokerReachable = status.BrokerReachable
+	}
+
+	doc.Components, doc.Healthy = p.components(info.Components)
+	return doc, nil
+}
+
+// components merges the started components with the results of their health checks
+func (p *Publisher) components(started []uptime.ComponentInfo) ([]Component, bool) {
+	var checks []watchdog.CheckStatus
+	if p.health != nil {
+		checks = p.health.GetStatus().Checks
+	}
+
+	healthy := true
+	result := make([]Component, 0, len(started))
+	index := make(map[string]int, len(started))
+	for _, info := range started {
+		startedAt := info.StartedAt
+		index[info.Name] = len(result)
+		result = append(result, Component{Name: info.Name, Healthy: true, StartedAt: &startedAt})
+	}
+	for _, check := range checks {
+		i, ok := index[check.Name]
+		if !ok {
+			i = len(result)
+			result = append(result, Component{Name: check.Name})
+		}
+		result[i].Healthy = check.Healthy
+		result[i].Error = check.Error
+		if !check.Healthy {
+			healthy = false
+		}
+	}
+	return result, healthy
+}
+
+func (p *Publisher) topic() string {
+	return p.cfg.MQTT.Topic + mqtt.StatusTopic
+}
+
+// LoadIntervalSec reads the status interval, an invalid value uses the default
+func LoadIntervalSec(db *gorm.DB) int {
+	var setting models.Setting
+	if err := db.Where("key = ?", IntervalKey).First(&setting).Error; err != nil {
+		return DefaultIntervalSec
+	}
+	seconds, err := strconv.Atoi(setting.Value)
+	if err != nil || ValidateIntervalSec(seconds) != nil {
+		return DefaultIntervalSec
+	}
+	return seconds
+}
+
+// ValidateIntervalSec checks the limits of the status interval
+func ValidateIntervalSec(seconds int) error {
+	if seconds < MinIntervalSec || seconds > MaxIntervalSec {
+		return fmt.Errorf("status interval must be between %d and %d seconds", MinIntervalSec, MaxIntervalSec)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package fleetstatus
+
+import (
+	"encoding/json"
+	"jarvist/internal/common/licensestate"
+	"jarvist/internal/common/models"
+	"jarvist/internal/syncmanager/config"
+	"jarvist/internal/syncmanager/mqtt"
+	"jarvist/internal/syncmanager/network"
+	"jarvist/internal/syncmanager/watchdog"
+	"jarvist/internal/testutil"
+	"jarvist/pkg/logger"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeSender struct {
+	mu        sync.Mutex
+	connected bool
+	published [][]byte
+}
+
+func (f *fakeSender) Connected() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.connected
+}
+
+func (f *fakeSender) StatusSnapshot() (mqtt.StatusSnapshot, error) {
+	return mqtt.StatusSnapshot{
+		Connected:    f.Connected(),
+		Environment:  "production",
+		License:      licensestate.State{Degraded: true, Reason: licensestate.ReasonExpired},
+		Registration: "accepted",
+		Pending:      12,
+		Deferred:     5,
+		InMemory:     3,
+	}, nil
+}
+
+func (f *fakeSender) PublishStatus(payload []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.published = append(f.published, payload)
+	return nil
+}
+
+func (f *fakeSender) documents() [][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]byte(nil), f.published...)
+}
+
+type fakeNetwork struct{}
+
+func (fakeNetwork) GetStatus() network.Status {
+	return network.Status{State: "online", BrokerReachable: true}
+}
+
+type fakeHealth struct{}
+
+func (fakeHealth) GetStatus() watchdog.Status {
+	return watchdog.Status{Checks: []watchdog.CheckStatus{
+		{Name: "MQTT sender", Healthy: true},
+		{Name: "Synchronizer", Healthy: false, Error: "watcher stopped"},
+	}}
+}
+
+func newTestPublisher(t *testing.T, sender *fakeSender) *Publisher {
+	t.Helper()
+
+	db := testutil.OpenDB(t, &models.Setting{})
+
+	cfg := &config.Config{}
+	cfg.MQTT.Topic = "jarvist/site"
+	cfg.MQTT.ClientID = "device-1"
+	return New(cfg, db, sender, fakeNetwork{}, fakeHealth{}, logger.NewLogger())
+}
+
+// checkRequired fails for every field the schema requires that the document lacks
+func checkRequired(t *testing.T, path string, schema, doc map[string]interface{}) {
+	t.Helper()
+
+	required, _ := schema["required"].([]interface{})
+	properties, _ := schema["properties"].(map[string]interface{})
+	for _, field := range required {
+		name := field.(string)
+		value, ok := doc[name]
+		if !ok {
+			t.Errorf("document lacks required field %s%s", path, name)
+			continue
+		}
+		nestedSchema, _ := properties[name].(map[string]interface{})
+		if nested, ok := value.(map[string]interface{}); ok && nestedSchema != nil {
+			checkRequired(t, path+name+".", nestedSchema, nested)
+		}
+	}
+}
+
+func TestDocumentMatchesSchema(t *testing.T) {
+	publisher := newTestPublisher(t, &fakeSender{connected: true})
+
+	doc, err := publisher.Build()
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	payload, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	var schema, decoded map[string]interface{}
+	if err := json.Unmarshal(Schema, &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	checkRequired(t, "", schema, decoded)
+
+	version := schema["properties"].(map[string]interface{})["schema_version"].(map[string]interface{})["const"]
+	if version != float64(SchemaVersion) {
+		t.Errorf("schema describes version %v, documents carry %d", version, SchemaVersion)
+	}
+
+	if doc.Healthy {
+		t.Errorf("document healthy while a component check fails")
+	}
+	if doc.Queues.Pending != 12 || doc.Queues.Deferred != 5 || doc.Queues.InMemory != 3 {
+		t.Errorf("queues = %+v", doc.Queues)
+	}
+	if !doc.License.Degraded || doc.Mode.Residency != "cloud" || doc.Connectivity.Network != "online" {
+		t.Errorf("document = %+v", doc)
+	}
+}
+
+func TestPublishesOnConnectAndAtInterval(t *testing.T) {
+	previous := checkInterval
+	checkInterval = 10 * time.Millisecond
+	defer func() { checkInterval = previous }()
+
+	sender := &fakeSender{connected: true}
+	publisher := newTestPublisher(t, sender)
+	if err := publisher.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(sender.documents()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	// Interval default 60 detik, jadi tidak ada dokumen kedua selama tes
+	time.Sleep(50 * time.Millisecond)
+	publisher.Stop()
+
+	documents := sender.documents()
+	if len(documents) != 1 {
+		t.Fatalf("published %d documents, want 1 on connect", len(documents))
+	}
+
+	var doc Document
+	if err := json.Unmarshal(documents[0], &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if doc.Sequence != 1 || doc.Type != DocumentType || doc.ClientID != "device-1" {
+		t.Errorf("document = %+v", doc)
+	}
+
+	status := publisher.GetStatus()
+	if status.Sequence != 1 || status.LastPublished == nil || status.Topic != "jarvist/site"+mqtt.StatusTopic {
+		t.Errorf("status = %+v", status)
+	}
+}
+
+func TestIntervalSetting(t *testing.T) {
+	publisher := newTestPublisher(t, &fakeSender{})
+
+	if got := LoadIntervalSec(publisher.db); got != DefaultIntervalSec {
+		t.Errorf("default interval = %d", got)
+	}
+	publisher.db.Create(&models.Setting{Key: IntervalKey, Value: "5"})
+	if got := LoadIntervalSec(publisher.db); got != DefaultIntervalSec {
+		t.Errorf("interval below the minimum used: %d", got)
+	}
+	publisher.db.Model(&models.Setting{}).Where("key = ?", IntervalKey).Update("value", "120")
+	if got := LoadIntervalSec(publisher.db); got != 120 {
+		t.Errorf("interval = %d, want 120", got)
+	}
+}
@@ -0,0 +1,438 @@
+// Package httpupload uploads the stored messages of the sender to a REST endpoint, for sites
+// that block MQTT outbound. Messages published at the same time by the sender workers are
+// posted together as one batch. The sender keeps its pending queue and retries, a message
+// only counts as sent once the endpoint accepted its batch.
+package httpupload
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"jarvist/internal/common/bandwidth"
+	"jarvist/internal/common/models"
+	"jarvist/pkg/logger"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const ComponentUpload = "https-upload"
+
+// Setting keys of the transport, re-read with the upload policy so a site can be switched
+// to HTTPS without a restart
+const (
+	TransportKey = "sync_transport"
+	URLKey       = "https_upload_url"
+	TokenKey     = "https_upload_token"
+	BatchSizeKey = "https_upload_batch_size"
+	BatchWaitKey = "https_upload_batch_wait_ms"
+)
+
+// Transports the stored messages can be sent with
+const (
+	TransportMQTT  = "mqtt"
+	TransportHTTPS = "https"
+)
+
+// Transports lists the valid values of the transport setting
+var Transports = []string{TransportMQTT, TransportHTTPS}
+
+const (
+	DefaultBatchSize   = 50
+	DefaultBatchWaitMs = 200
+	MaxBatchSize       = 500
+	MaxBatchWaitMs     = 10000
+
+	requestTimeout = 30 * time.Second
+	initialBackoff = time.Second
+	maxBackoff     = time.Minute
+	// maxErrorBody limits the part of an error response kept for the status
+	maxErrorBody = 512
+)
+
+var (
+	ErrNotConfigured = errors.New("HTTPS upload URL is not configured")
+	ErrBackoff       = errors.New("HTTPS upload endpoint failed recently, waiting before the next attempt")
+	ErrClosed        = errors.New("HTTPS upload is stopped")
+)
+
+// Settings select the transport and the endpoint of the HTTPS upload
+type Settings struct {
+	Transport string        `json:"transport"`
+	URL       string        `json:"url,omitempty"`
+	Token     string        `json:"-"` // Sent as a Bearer token, never shown
+	BatchSize int           `json:"batch_size"`
+	BatchWait time.Duration `json:"-"`
+}
+
+// ValidateTransport checks the transport setting, empty selects MQTT
+func ValidateTransport(value string) error {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", TransportMQTT, TransportHTTPS:
+		return nil
+	}
+	return fmt.Errorf("transport must be %s or %s", TransportMQTT, TransportHTTPS)
+}
+
+// ValidateURL checks the upload endpoint, site data is only uploaded over HTTPS
+func ValidateURL(value string) error {
+	if value == "" {
+		return nil
+	}
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid upload URL: %w", err)
+	}
+	if parsed.Scheme != "https" {
+		return errors.New("upload URL must start with https://")
+	}
+	if parsed.Host == "" {
+		return errors.New("upload URL has no host")
+	}
+	return nil
+}
+
+// LoadSettings reads the transport settings. Invalid values are an error so the caller
+// keeps the transport in use.
+func LoadSettings(db *gorm.DB) (Settings, error) {
+	settings := Settings{
+		Transport: strings.ToLower(getSetting(db, TransportKey)),
+		URL:       getSetting(db, URLKey),
+		Token:     getSetting(db, TokenKey),
+		BatchSize: DefaultBatchSize,
+		BatchWait: DefaultBatchWaitMs * time.Millisecond,
+	}
+	if settings.Transport == "" {
+		settings.Transport = TransportMQTT
+	}
+	if err := ValidateTransport(settings.Transport); err != nil {
+		return Settings{}, err
+	}
+	if err := ValidateURL(settings.URL); err != nil {
+		return Settings{}, err
+	}
+	if settings.Transport == TransportHTTPS && settings.URL == "" {
+		return Settings{}, fmt.Errorf("%s is required when %s is %s", URLKey, TransportKey, TransportHTTPS)
+	}
+
+	if value := getSetting(db, BatchSizeKey); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > MaxBatchSize {
+			return Settings{}, fmt.Errorf("%s must be between 1 and %d", BatchSizeKey, MaxBatchSize)
+		}
+		settings.BatchSize = n
+	}
+	if value := getSetting(db, BatchWaitKey); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > MaxBatchWaitMs {
+			return Settings{}, fmt.Errorf("%s must be between 0 and %d", BatchWaitKey, MaxBatchWaitMs)
+		}
+		settings.BatchWait = time.Duration(n) * time.Millisecond
+	}
+	return settings, nil
+}
+
+// Status describes the HTTPS upload and the batches posted since the start
+type Status struct {
+	Connected      bool       `json:"connected"`
+	URL            string     `json:"url,omitempty"`
+	BatchSize      int        `json:"batch_size"`
+	BatchWaitMs    int64      `json:"batch_wait_ms"`
+	Batches        uint64     `json:"batches"`
+	Uploaded       uint64     `json:"uploaded"`
+	Failed         uint64     `json:"failed"` // Messages of rejected or undelivered batches, retried by the sender
+	LastUploadAt   *time.Time `json:"last_upload_at,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastErrorAt    *time.Time `json:"last_error_at,omitempty"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	CurrentBackoff string     `json:"current_backoff,omitempty"`
+}
+
+// message is one stored message of a batch
+type message struct {
+	Topic   string          `json:"topic"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// batch collects the messages published while it is open
+type batch struct {
+	messages []message
+	timer    *time.Timer
+	done     chan struct{}
+	err      error
+}
+
+// request is the body posted to the endpoint
+type request struct {
+	ClientID string    `json:"client_id"`
+	BatchID  string    `json:"batch_id"`
+	SentAt   time.Time `json:"sent_at"`
+	Messages []message `json:"messages"`
+}
+
+// Uploader posts the stored messages to the REST endpoint in batches
+type Uploader struct {
+	clientID string
+	logger   *logger.Logger
+	client   *http.Client
+
+	mutex     sync.Mutex
+	settings  Settings
+	connected bool
+	open      *batch
+	backoff   time.Duration
+	retryAt   time.Time
+
+	batches      uint64
+	uploaded     uint64
+	failed       uint64
+	lastUploadAt *time.Time
+	lastError    string
+	lastErrorAt  *time.Time
+}
+
+// New creates the uploader, it uploads nothing until configured and connected
+func New(clientID string, logger *logger.Logger) *Uploader {
+	return &Uploader{
+		clientID: clientID,
+		logger:   logger,
+		client: &http.Client{
+			Timeout:   requestTimeout,
+			Transport: bandwidth.NewTransport(bandwidth.DestinationUpload),
+		},
+	}
+}
+
+// Name returns the transport name used in logs
+func (u *Uploader) Name() string {
+	return "HTTPS upload"
+}
+
+// Configure applies changed settings, an open batch is posted with the previous endpoint
+func (u *Uploader) Configure(settings Settings) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if settings.URL != u.settings.URL || settings.Token != u.settings.Token {
+		u.backoff = 0
+		u.retryAt = time.Time{}
+	}
+	u.settings = settings
+}
+
+// Connect starts accepting messages. No request is made, the first batch shows whether
+// the endpoint is reachable.
+func (u *Uploader) Connect() error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if u.settings.URL == "" {
+		return ErrNotConfigured
+	}
+	u.connected = true
+	return nil
+}
+
+// Disconnect stops accepting messages and posts the open batch right away
+func (u *Uploader) Disconnect() {
+	u.mutex.Lock()
+	u.connected = false
+	open := u.takeOpenLocked()
+	u.mutex.Unlock()
+
+	if open != nil {
+		u.post(open)
+	}
+}
+
+// IsConnected reports whether messages are accepted now. After a failed batch the uploader
+// backs off before the next attempt, the sender keeps the messages queued meanwhile.
+func (u *Uploader) IsConnected() bool {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.connected && u.settings.URL != "" && !time.Now().Before(u.retryAt)
+}
+
+// Publish adds a message to the open batch and waits until the batch is posted. The batch
+// is posted when it is full or BatchWait after its first message.
+func (u *Uploader) Publish(topic string, payload []byte) error {
+	entry := message{Topic: topic, Payload: json.RawMessage(payload)}
+	if !json.Valid(payload) {
+		// Payload yang bukan JSON dikirim sebagai string
+		encoded, err := json.Marshal(string(payload))
+		if err != nil {
+			return err
+		}
+		entry.Payload = encoded
+	}
+
+	u.mutex.Lock()
+	switch {
+	case !u.connected:
+		u.mutex.Unlock()
+		return ErrClosed
+	case u.settings.URL == "":
+		u.mutex.Unlock()
+		return ErrNotConfigured
+	case time.Now().Before(u.retryAt):
+		u.mutex.Unlock()
+		return ErrBackoff
+	}
+
+	if u.open == nil {
+		u.open = &batch{done: make(chan struct{})}
+		if u.settings.BatchWait > 0 {
+			current := u.open
+			current.timer = time.AfterFunc(u.settings.BatchWait, func() { u.flush(current) })
+		}
+	}
+	current := u.open
+	current.messages = append(current.messages, entry)
+
+	var full *batch
+	if len(current.messages) >= u.settings.BatchSize || u.settings.BatchWait <= 0 {
+		full = u.takeOpenLocked()
+	}
+	u.mutex.Unlock()
+
+	if full != nil {
+		u.post(full)
+	}
+	<-current.done
+	return current.err
+}
+
+// flush posts a batch when its wait is over, unless it was posted already
+func (u *Uploader) flush(b *batch) {
+	u.mutex.Lock()
+	if u.open != b {
+		u.mutex.Unlock()
+		return
+	}
+	u.open = nil
+	u.mutex.Unlock()
+
+	u.post(b)
+}
+
+// takeOpenLocked closes the open batch for posting, u.mutex must be held
+func (u *Uploader) takeOpenLocked() *batch {
+	b := u.open
+	u.open = nil
+	if b != nil && b.timer != nil {
+		b.timer.Stop()
+	}
+	return b
+}
+
+// post sends a batch and releases the publishers waiting for it
+func (u *Uploader) post(b *batch) {
+	defer close(b.done)
+
+	u.mutex.Lock()
+	settings := u.settings
+	u.mutex.Unlock()
+
+	b.err = u.send(settings, b.messages)
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	now := time.Now()
+	u.batches++
+	if b.err != nil {
+		u.failed += uint64(len(b.messages))
+		u.lastError = b.err.Error()
+		u.lastErrorAt = &now
+
+		if u.backoff == 0 {
+			u.backoff = initialBackoff
+		} else {
+			u.backoff = min(u.backoff*2, maxBackoff)
+		}
+		u.retryAt = now.Add(u.backoff)
+		u.logger.Warning(ComponentUpload, "Failed to upload %d messages, retrying in %s: %v", len(b.messages), u.backoff, b.err)
+		return
+	}
+
+	u.uploaded += uint64(len(b.messages))
+	u.lastUploadAt = &now
+	u.backoff = 0
+	u.retryAt = time.Time{}
+	u.logger.Debug(ComponentUpload, "Uploaded batch of %d messages", len(b.messages))
+}
+
+// send posts the messages to the endpoint, any status other than 2xx fails the batch
+func (u *Uploader) send(settings Settings, messages []message) error {
+	body, err := json.Marshal(request{
+		ClientID: u.clientID,
+		BatchID:  fmt.Sprintf("batch_%d", time.Now().UnixNano()),
+		SentAt:   time.Now().UTC(),
+		Messages: messages,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to serialize batch: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, settings.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create upload request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if settings.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+settings.Token)
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("upload endpoint answered %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// GetStatus returns the state of the uploader
+func (u *Uploader) GetStatus() Status {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	status := Status{
+		Connected:    u.connected && u.settings.URL != "" && !time.Now().Before(u.retryAt),
+		URL:          u.settings.URL,
+		BatchSize:    u.settings.BatchSize,
+		BatchWaitMs:  u.settings.BatchWait.Milliseconds(),
+		Batches:      u.batches,
+		Uploaded:     u.uploaded,
+		Failed:       u.failed,
+		LastUploadAt: u.lastUploadAt,
+		LastError:    u.lastError,
+		LastErrorAt:  u.lastErrorAt,
+	}
+	if time.Now().Before(u.retryAt) {
+		retryAt := u.retryAt
+		status.NextAttemptAt = &retryAt
+		status.CurrentBackoff = u.backoff.String()
+	}
+	return status
+}
+
+func getSetting(db *gorm.DB, key string) string {
+	var setting models.Setting
+	if err := db.Where("key = ?", key).First(&setting).Error; err != nil {
+		return ""
+	}
+	return strings.TrimSpace(setting.Value)
+}
@@ -0,0 +1,144 @@
+package httpupload
+
+import (
+	"encoding/json"
+	"errors"
+	"jarvist/internal/common/models"
+	"jarvist/internal/testutil"
+	"jarvist/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	return testutil.OpenDB(t, &models.Setting{})
+}
+
+// newTestUploader connects an uploader to a TLS test server answering with handler
+func newTestUploader(t *testing.T, settings Settings, handler http.HandlerFunc) *Uploader {
+	t.Helper()
+
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+
+	uploader := New("device-1", logger.NewLogger())
+	uploader.client = server.Client()
+	settings.Transport = TransportHTTPS
+	settings.URL = server.URL + "/ingest"
+	uploader.Configure(settings)
+	if err := uploader.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	return uploader
+}
+
+func TestLoadSettings(t *testing.T) {
+	db := openTestDB(t)
+
+	settings, err := LoadSettings(db)
+	if err != nil {
+		t.Fatalf("load defaults: %v", err)
+	}
+	if settings.Transport != TransportMQTT || settings.BatchSize != DefaultBatchSize {
+		t.Fatalf("defaults = %+v, want mqtt with batch size %d", settings, DefaultBatchSize)
+	}
+
+	db.Create(&models.Setting{Key: TransportKey, Value: "https"})
+	if _, err := LoadSettings(db); err == nil {
+		t.Fatal("https transport without an upload URL accepted")
+	}
+
+	db.Create(&models.Setting{Key: URLKey, Value: "http://backend.example.com/ingest"})
+	if _, err := LoadSettings(db); err == nil {
+		t.Fatal("plain http upload URL accepted")
+	}
+
+	db.Model(&models.Setting{}).Where("key = ?", URLKey).Update("value", "https://backend.example.com/ingest")
+	db.Create(&models.Setting{Key: BatchSizeKey, Value: "10"})
+	db.Create(&models.Setting{Key: BatchWaitKey, Value: "0"})
+	settings, err = LoadSettings(db)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if settings.Transport != TransportHTTPS || settings.BatchSize != 10 || settings.BatchWait != 0 {
+		t.Fatalf("settings = %+v, want https with batch size 10 and no wait", settings)
+	}
+
+	db.Model(&models.Setting{}).Where("key = ?", BatchSizeKey).Update("value", "0")
+	if _, err := LoadSettings(db); err == nil {
+		t.Fatal("batch size 0 accepted")
+	}
+}
+
+func TestPublishPostsOneBatch(t *testing.T) {
+	var mu sync.Mutex
+	var requests []request
+	var authorization string
+
+	uploader := newTestUploader(t, Settings{Token: "secret", BatchSize: 3, BatchWait: 5 * time.Second},
+		func(w http.ResponseWriter, r *http.Request) {
+			var body request
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			mu.Lock()
+			requests = append(requests, body)
+			authorization = r.Header.Get("Authorization")
+			mu.Unlock()
+			w.WriteHeader(http.StatusAccepted)
+		})
+
+	payloads := [][]byte{[]byte(`{"count":1}`), []byte(`{"count":2}`), []byte("not json")}
+	var wg sync.WaitGroup
+	errs := make([]error, len(payloads))
+	for i, payload := range payloads {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = uploader.Publish("jarvist/site/data", payload)
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("publish %d: %v", i, err)
+		}
+	}
+	if len(requests) != 1 || len(requests[0].Messages) != 3 {
+		t.Fatalf("posted %d requests, want one batch of 3 messages", len(requests))
+	}
+	if requests[0].ClientID != "device-1" || authorization != "Bearer secret" {
+		t.Fatalf("client ID %q, authorization %q", requests[0].ClientID, authorization)
+	}
+	if status := uploader.GetStatus(); status.Batches != 1 || status.Uploaded != 3 || !status.Connected {
+		t.Fatalf("status = %+v, want 1 batch with 3 uploaded", status)
+	}
+}
+
+func TestFailedBatchBacksOff(t *testing.T) {
+	uploader := newTestUploader(t, Settings{BatchSize: 1}, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "backend down", http.StatusServiceUnavailable)
+	})
+
+	if err := uploader.Publish("jarvist/site/data", []byte(`{"count":1}`)); err == nil {
+		t.Fatal("publish succeeded although the endpoint failed")
+	}
+	if uploader.IsConnected() {
+		t.Fatal("uploader accepts messages right after a failed batch")
+	}
+	if err := uploader.Publish("jarvist/site/data", []byte(`{"count":1}`)); !errors.Is(err, ErrBackoff) {
+		t.Fatalf("publish during backoff = %v, want ErrBackoff", err)
+	}
+
+	status := uploader.GetStatus()
+	if status.Failed != 1 || status.LastError == "" || status.NextAttemptAt == nil {
+		t.Fatalf("status = %+v, want 1 failed message and the next attempt", status)
+	}
+}
@@ -11,3 +11,8 @@ type ServiceComponent interface {
 	Start() error
 	Stop() error
 }
+
+// HealthChecker is implemented by components that can verify they work after a start
+type HealthChecker interface {
+	HealthCheck() error
+}
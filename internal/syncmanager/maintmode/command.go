@@ -0,0 +1,47 @@
+package maintmode
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CommandName is the MQTT command handled by the manager, published to <topic>/command/maintenance
+const CommandName = "maintenance"
+
+// Command actions
+const (
+	ActionEnable  = "enable"
+	ActionDisable = "disable"
+)
+
+// Command is the payload of the maintenance MQTT command
+type Command struct {
+	Action string `json:"action"`
+	Request
+}
+
+// HandleCommand switches maintenance mode from an MQTT command. The result is visible in
+// the next heartbeat, which carries the maintenance state.
+func (m *Manager) HandleCommand(payload []byte) {
+	if err := m.handleCommand(payload); err != nil {
+		m.logger.Warning(ComponentMaintenance, "Rejected maintenance command: %v", err)
+	}
+}
+
+func (m *Manager) handleCommand(payload []byte) error {
+	var command Command
+	if err := json.Unmarshal(payload, &command); err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+
+	switch command.Action {
+	case ActionEnable:
+		_, err := m.Enable(command.Request, SourceMQTT)
+		return err
+	case ActionDisable:
+		_, err := m.Disable(command.Actor, SourceMQTT)
+		return err
+	default:
+		return fmt.Errorf("unknown action %q, expected %s or %s", command.Action, ActionEnable, ActionDisable)
+	}
+}
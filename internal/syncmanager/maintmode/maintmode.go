@@ -0,0 +1,450 @@
+// Package maintmode pauses publishing and alerts while the site is being worked on, for
+// example during camera rewiring or network work. Maintenance mode expires on its own so a
+// forgotten switch does not keep the device silent.
+package maintmode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"jarvist/internal/syncmanager/config"
+	"jarvist/pkg/logger"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const ComponentMaintenance = "maintenance"
+
+// Setting keys, the state survives a restart of the service
+const (
+	StateKey   = "maintenance_mode"
+	HistoryKey = "maintenance_history"
+)
+
+// Sources of a maintenance switch
+const (
+	SourceAPI  = "api"
+	SourceMQTT = "mqtt"
+)
+
+// Ways a maintenance window ended
+const (
+	EndedManual  = "manual"
+	EndedExpired = "expired"
+	EndedActive  = "active" // Window masih berjalan
+)
+
+const (
+	// expiryInterval is how often the expiry is compared with the wall clock, a timer would
+	// not fire on time after the machine slept
+	expiryInterval = 15 * time.Second
+
+	maxReasonLength = 200
+	maxHistory      = 100
+)
+
+// State is the current maintenance mode
+type State struct {
+	Active       bool       `json:"active"`
+	Reason       string     `json:"reason,omitempty"`
+	Actor        string     `json:"actor,omitempty"`
+	Source       string     `json:"source,omitempty"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	RemainingSec float64    `json:"remaining_sec,omitempty"`
+}
+
+// Window is a period in which the device was in maintenance, used to annotate the timeline
+type Window struct {
+	StartedAt   time.Time `json:"started_at"`
+	EndedAt     time.Time `json:"ended_at"`
+	DurationSec float64   `json:"duration_sec"`
+	Reason      string    `json:"reason,omitempty"`
+	Actor       string    `json:"actor,omitempty"`
+	Source      string    `json:"source"`
+	EndedBy     string    `json:"ended_by"`
+}
+
+// Request switches maintenance mode on, or extends a running maintenance window
+type Request struct {
+	DurationMin int    `json:"duration_min"` // 0 uses the configured default
+	Reason      string `json:"reason"`
+	Actor       string `json:"actor"`
+}
+
+// Manager holds the maintenance state and expires it
+type Manager struct {
+	cfg    *config.Config
+	db     *gorm.DB
+	logger *logger.Logger
+
+	mu        sync.Mutex
+	state     State
+	history   []Window
+	listeners []func(State)
+	quitChan  chan struct{}
+	wg        sync.WaitGroup
+}
+
+// New creates the maintenance mode manager and loads the saved state
+func New(cfg *config.Config, db *gorm.DB, logger *logger.Logger) *Manager {
+	m := &Manager{
+		cfg:    cfg,
+		db:     db,
+		logger: logger,
+	}
+
+	if value := getSetting(db, StateKey); value != "" {
+		if err := json.Unmarshal([]byte(value), &m.state); err != nil {
+			logger.Warning(ComponentMaintenance, "Ignoring invalid maintenance state: %v", err)
+			m.state = State{}
+		}
+	}
+	if value := getSetting(db, HistoryKey); value != "" {
+		if err := json.Unmarshal([]byte(value), &m.history); err != nil {
+			logger.Warning(ComponentMaintenance, "Ignoring invalid maintenance history: %v", err)
+			m.history = nil
+		}
+	}
+
+	return m
+}
+
+// Name returns the component name used in startup logs
+func (m *Manager) Name() string {
+	return "Maintenance mode"
+}
+
+// Subscribe adds a function called with the new state whenever maintenance starts or ends.
+// Subscribers are also called once on start with the saved state.
+func (m *Manager) Subscribe(fn func(State)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, fn)
+}
+
+// Start expires a window that ended while the service was stopped and starts the expiry check
+func (m *Manager) Start() error {
+	m.mu.Lock()
+	if m.quitChan != nil {
+		m.mu.Unlock()
+		return nil
+	}
+	m.quitChan = make(chan struct{})
+	quitChan := m.quitChan
+	m.mu.Unlock()
+
+	if !m.expire() {
+		m.notify(m.GetState())
+	}
+
+	m.wg.Add(1)
+	go m.watchExpiry(quitChan)
+
+	if state := m.GetState(); state.Active {
+		m.logger.Info(ComponentMaintenance, "Maintenance mode is active until %s, publishing and alerts are paused",
+			state.ExpiresAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Stop stops the expiry check, an active window stays active across the restart
+func (m *Manager) Stop() error {
+	m.mu.Lock()
+	if m.quitChan == nil {
+		m.mu.Unlock()
+		return nil
+	}
+	close(m.quitChan)
+	m.quitChan = nil
+	m.mu.Unlock()
+
+	m.wg.Wait()
+	return nil
+}
+
+// GetState returns the current maintenance state
+func (m *Manager) GetState() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.current()
+}
+
+// Active reports whether maintenance mode is on
+func (m *Manager) Active() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state.Active
+}
+
+// GetHistory returns the finished maintenance windows, newest last
+func (m *Manager) GetHistory() []Window {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	history := make([]Window, len(m.history))
+	copy(history, m.history)
+	return history
+}
+
+// WindowsBetween returns the maintenance windows overlapping from-to, including the running one
+func (m *Manager) WindowsBetween(from, to time.Time) []Window {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	windows := []Window{}
+	for _, window := range m.history {
+		if window.StartedAt.Before(to) && window.EndedAt.After(from) {
+			windows = append(windows, window)
+		}
+	}
+
+	if m.state.Active && m.state.StartedAt.Before(to) {
+		now := time.Now().Round(0)
+		windows = append(windows, Window{
+			StartedAt:   *m.state.StartedAt,
+			EndedAt:     now,
+			DurationSec: now.Sub(*m.state.StartedAt).Seconds(),
+			Reason:      m.state.Reason,
+			Actor:       m.state.Actor,
+			Source:      m.state.Source,
+			EndedBy:     EndedActive,
+		})
+	}
+	return windows
+}
+
+// Enable switches maintenance mode on for the requested duration. A running window is
+// extended from now and keeps its start time.
+func (m *Manager) Enable(req Request, source string) (State, error) {
+	duration, err := m.duration(req.DurationMin)
+	if err != nil {
+		return State{}, err
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if len(req.Reason) > maxReasonLength {
+		return State{}, fmt.Errorf("reason is limited to %d characters", maxReasonLength)
+	}
+
+	now := time.Now().Round(0)
+	expiresAt := now.Add(duration)
+
+	m.mu.Lock()
+	extended := m.state.Active
+	next := m.state
+	if !extended {
+		next = State{Active: true, StartedAt: &now, Source: source}
+	}
+	next.ExpiresAt = &expiresAt
+	if req.Reason != "" || !extended {
+		next.Reason = req.Reason
+	}
+	if req.Actor != "" || !extended {
+		next.Actor = strings.TrimSpace(req.Actor)
+	}
+
+	if err := saveJSON(m.db, StateKey, next); err != nil {
+		m.mu.Unlock()
+		return State{}, fmt.Errorf("failed to save maintenance state: %w", err)
+	}
+	m.state = next
+	state := m.current()
+	m.mu.Unlock()
+
+	if extended {
+		m.logger.Info(ComponentMaintenance, "Maintenance mode extended until %s by %s (%s)",
+			expiresAt.Format(time.RFC3339), actorName(state.Actor), source)
+		m.notify(state)
+		return state, nil
+	}
+
+	m.logger.Event(logger.LevelInfo, ComponentMaintenance, logger.EventMaintenanceStarted,
+		logger.F("actor", actorName(state.Actor)),
+		logger.F("source", source),
+		logger.F("until", expiresAt.Format(time.RFC3339)),
+		logger.F("reason", reasonText(state.Reason)))
+	m.notify(state)
+	return state, nil
+}
+
+// Disable switches maintenance mode off before it expires
+func (m *Manager) Disable(actor, source string) (State, error) {
+	m.mu.Lock()
+	if !m.state.Active {
+		state := m.current()
+		m.mu.Unlock()
+		return state, nil
+	}
+	m.mu.Unlock()
+
+	if err := m.finish(time.Now().Round(0), EndedManual); err != nil {
+		return State{}, err
+	}
+
+	m.logger.Info(ComponentMaintenance, "Maintenance mode switched off by %s (%s)", actorName(strings.TrimSpace(actor)), source)
+	return m.GetState(), nil
+}
+
+// duration validates the requested duration in minutes against the configured limits
+func (m *Manager) duration(minutes int) (time.Duration, error) {
+	if minutes < 0 {
+		return 0, errors.New("duration must not be negative")
+	}
+	if minutes == 0 {
+		minutes = m.cfg.Maintenance.DefaultDurationMin
+	}
+	if limit := m.cfg.Maintenance.MaxDurationMin; limit > 0 && minutes > limit {
+		return 0, fmt.Errorf("duration is limited to %d minutes", limit)
+	}
+	return time.Duration(minutes) * time.Minute, nil
+}
+
+// watchExpiry ends maintenance mode once its expiry time has passed
+func (m *Manager) watchExpiry(quitChan chan struct{}) {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(expiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.expire()
+		case <-quitChan:
+			return
+		}
+	}
+}
+
+// expire ends an active window whose expiry has passed, true if it did
+func (m *Manager) expire() bool {
+	m.mu.Lock()
+	expired := m.state.Active && m.state.ExpiresAt != nil && !time.Now().Before(*m.state.ExpiresAt)
+	var endedAt time.Time
+	if expired {
+		endedAt = *m.state.ExpiresAt
+	}
+	m.mu.Unlock()
+
+	if !expired {
+		return false
+	}
+
+	if err := m.finish(endedAt, EndedExpired); err != nil {
+		m.logger.Error(ComponentMaintenance, "Failed to end expired maintenance mode: %v", err)
+		return false
+	}
+	return true
+}
+
+// finish records the running window in the history and switches maintenance mode off
+func (m *Manager) finish(endedAt time.Time, endedBy string) error {
+	m.mu.Lock()
+	if !m.state.Active {
+		m.mu.Unlock()
+		return nil
+	}
+
+	window := Window{
+		StartedAt:   *m.state.StartedAt,
+		EndedAt:     endedAt,
+		DurationSec: endedAt.Sub(*m.state.StartedAt).Seconds(),
+		Reason:      m.state.Reason,
+		Actor:       m.state.Actor,
+		Source:      m.state.Source,
+		EndedBy:     endedBy,
+	}
+	history := append(append([]Window(nil), m.history...), window)
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+
+	// Riwayat disimpan lebih dulu, state tidak boleh hilang tanpa window tercatat
+	if err := saveJSON(m.db, HistoryKey, history); err != nil {
+		m.mu.Unlock()
+		return fmt.Errorf("failed to save maintenance history: %w", err)
+	}
+	if err := saveJSON(m.db, StateKey, State{}); err != nil {
+		m.mu.Unlock()
+		return fmt.Errorf("failed to save maintenance state: %w", err)
+	}
+	m.history = history
+	m.state = State{}
+	m.mu.Unlock()
+
+	m.logger.Event(logger.LevelInfo, ComponentMaintenance, logger.EventMaintenanceEnded,
+		logger.F("from", window.StartedAt.Format(time.RFC3339)),
+		logger.F("to", window.EndedAt.Format(time.RFC3339)),
+		logger.F("duration", time.Duration(window.DurationSec*float64(time.Second)).Truncate(time.Second)),
+		logger.F("ended_by", endedBy))
+	m.notify(State{})
+	return nil
+}
+
+// current returns the state with the remaining time, the caller holds mu
+func (m *Manager) current() State {
+	state := m.state
+	if state.Active && state.ExpiresAt != nil {
+		state.RemainingSec = max(time.Until(*state.ExpiresAt).Seconds(), 0)
+	}
+	return state
+}
+
+func (m *Manager) notify(state State) {
+	m.mu.Lock()
+	listeners := make([]func(State), len(m.listeners))
+	copy(listeners, m.listeners)
+	m.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(state)
+	}
+}
+
+func actorName(actor string) string {
+	if actor == "" {
+		return "unknown"
+	}
+	return actor
+}
+
+func reasonText(reason string) string {
+	if reason == "" {
+		return "no reason given"
+	}
+	return reason
+}
+
+func saveJSON(db *gorm.DB, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return saveSetting(db, key, string(data))
+}
+
+func getSetting(db *gorm.DB, key string) string {
+	var setting models.Setting
+	if err := db.Where("key = ?", key).First(&setting).Error; err != nil {
+		return ""
+	}
+	return setting.Value
+}
+
+func saveSetting(db *gorm.DB, key, value string) error {
+	var setting models.Setting
+	result := db.Where("key = ?", key).First(&setting)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return db.Create(&models.Setting{Key: key, Value: value}).Error
+	} else if result.Error != nil {
+		return result.Error
+	}
+
+	setting.Value = value
+	return db.Save(&setting).Error
+}
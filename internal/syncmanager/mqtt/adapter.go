@@ -11,6 +11,9 @@ func NewLoggerAdapter(sender *Sender) *LoggerAdapter {
 }
 
 func (a *LoggerAdapter) Publish(topic string, payload interface{}) error {
+	if a.sender.suppressAlert(topic) {
+		return nil
+	}
 	_, err := a.sender.SendData(topic, payload)
 	return err
 }
@@ -0,0 +1,145 @@
+package mqtt
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+// Reconnect budget: at most connectBudgetAttempts attempts per connectBudgetWindow, after
+// which the client waits connectCooldown before trying again
+const (
+	connectBudgetAttempts  = 20
+	connectBudgetWindow    = 15 * time.Minute
+	connectCooldown        = 5 * time.Minute
+	stableConnectionPeriod = 2 * time.Minute // Backoff is only reset after staying connected this long
+	dnsLookupTimeout       = 5 * time.Second
+	flapHistoryWindow      = time.Hour
+)
+
+// Reconnect states
+const (
+	ReconnectConnected  = "connected"
+	ReconnectConnecting = "connecting"
+	ReconnectWaiting    = "waiting"
+	ReconnectCooldown   = "cooldown"
+	ReconnectStopped    = "stopped"
+)
+
+// ReconnectState describes the reconnect backoff of the client, for diagnosing flapping links
+type ReconnectState struct {
+	State           string     `json:"state"`
+	Attempt         int        `json:"attempt"`
+	CurrentBackoff  string     `json:"current_backoff"`
+	NextAttemptAt   *time.Time `json:"next_attempt_at,omitempty"`
+	BudgetUsed      int        `json:"budget_used"`
+	BudgetLimit     int        `json:"budget_limit"`
+	BudgetWindow    string     `json:"budget_window"`
+	LastError       string     `json:"last_error,omitempty"`
+	LastErrorAt     *time.Time `json:"last_error_at,omitempty"`
+	ResolvedAddrs   []string   `json:"resolved_addrs,omitempty"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+	LastConnectedAt *time.Time `json:"last_connected_at,omitempty"`
+	LastLostAt      *time.Time `json:"last_lost_at,omitempty"`
+	FlapsLastHour   int        `json:"flaps_last_hour"`
+}
+
+// reconnectTracker holds the backoff bookkeeping of the client, guarded by the client mutex
+type reconnectTracker struct {
+	attemptTimes  []time.Time
+	nextAttemptAt time.Time
+	cooldown      bool
+	lastError     string
+	lastErrorAt   time.Time
+	resolvedAddrs []string
+	resolvedAt    time.Time
+	connectedAt   time.Time
+	lostAt        time.Time
+	flaps         []time.Time
+}
+
+// recordAttempt adds an attempt to the budget and reports whether the budget allows it
+func (r *reconnectTracker) recordAttempt(now time.Time) bool {
+	r.pruneAttempts(now)
+	if len(r.attemptTimes) >= connectBudgetAttempts {
+		return false
+	}
+	r.attemptTimes = append(r.attemptTimes, now)
+	return true
+}
+
+func (r *reconnectTracker) pruneAttempts(now time.Time) {
+	cutoff := now.Add(-connectBudgetWindow)
+	kept := r.attemptTimes[:0]
+	for _, t := range r.attemptTimes {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	r.attemptTimes = kept
+}
+
+func (r *reconnectTracker) recordError(err error, now time.Time) {
+	r.lastError = err.Error()
+	r.lastErrorAt = now
+}
+
+// recordLost remembers a lost connection and returns true if it counts as a flap
+func (r *reconnectTracker) recordLost(now time.Time) bool {
+	r.lostAt = now
+
+	cutoff := now.Add(-flapHistoryWindow)
+	kept := r.flaps[:0]
+	for _, t := range r.flaps {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	r.flaps = kept
+
+	if r.connectedAt.IsZero() || now.Sub(r.connectedAt) >= stableConnectionPeriod {
+		return false
+	}
+	r.flaps = append(r.flaps, now)
+	return true
+}
+
+func (r *reconnectTracker) reset() {
+	r.attemptTimes = nil
+	r.nextAttemptAt = time.Time{}
+	r.cooldown = false
+}
+
+// nextBackoff returns base with ±retryJitter applied
+func nextBackoff(base time.Duration) time.Duration {
+	jitter := 1.0 + (rand.Float64()*2-1)*retryJitter
+	return time.Duration(float64(base) * jitter)
+}
+
+// growBackoff multiplies the backoff by retryFactor up to maxRetryDelay
+func growBackoff(current time.Duration) time.Duration {
+	return time.Duration(math.Min(float64(current)*retryFactor, float64(maxRetryDelay)))
+}
+
+// resolveBroker looks up the broker host again before each attempt so that a changed
+// DNS record or a broken resolver shows up before the connect timeout
+func resolveBroker(host string) ([]string, error) {
+	if net.ParseIP(strings.Trim(host, "[]")) != nil {
+		return []string{host}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dnsLookupTimeout)
+	defer cancel()
+
+	return net.DefaultResolver.LookupHost(ctx, host)
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
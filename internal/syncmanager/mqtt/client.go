@@ -2,16 +2,18 @@ package mqtt
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"jarvist/internal/common/bandwidth"
+	"jarvist/internal/common/redact"
+	"jarvist/internal/common/residency"
 	"jarvist/internal/syncmanager/config"
 	"jarvist/pkg/logger"
 	"jarvist/pkg/utils"
-	"math"
+	"strings"
 	"sync"
 	"time"
 
-	"math/rand"
-
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
@@ -22,6 +24,7 @@ const (
 	retryFactor        = 2.0
 	stabilizationDelay = 3 * time.Second
 	retryJitter        = 0.2 // 20% jitter
+	publishTimeout     = 30 * time.Second
 )
 
 type Client struct {
@@ -34,11 +37,21 @@ type Client struct {
 	currentBackoff  time.Duration
 	connectAttempt  int
 	connectTimer    *time.Timer
+	connecting      bool
+	reconnect       reconnectTracker
+	failover        brokerFailover
+	failbackStop    chan struct{}
 	cleanDisconnect bool
+	localOnly       bool
 	sentCache       map[string]bool
 	sentCacheTimes  map[string]time.Time
 	cacheMutex      sync.Mutex
 	cacheTimeout    time.Duration
+	payloadLog      *PayloadLogger
+	metrics         *PublishMetrics
+	session         SessionSettings
+	credentials     Credentials
+	commands        map[string]func(payload []byte)
 }
 
 // NewClient creates a new MQTT client
@@ -56,6 +69,14 @@ func NewClient(cfg *config.Config, logger *logger.Logger) (*Client, error) {
 		sentCacheTimes:  make(map[string]time.Time),
 		cacheMutex:      sync.Mutex{},
 		cacheTimeout:    30 * time.Second, // Pesan disimpan di cache selama 30 detik
+		metrics:         NewPublishMetrics(),
+		session:         SessionSettings{CleanSession: true},
+		credentials:     Credentials{Username: cfg.MQTT.Username, Password: cfg.MQTT.Password},
+		commands:        make(map[string]func(payload []byte)),
+	}
+
+	if cfg.BaseConfig != nil {
+		client.payloadLog = NewPayloadLogger(cfg.BaseConfig.LogDir)
 	}
 
 	// Mulai goroutine untuk membersihkan cache secara berkala
@@ -103,6 +124,13 @@ func (c *Client) Connect() error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	// Local-only mode never connects to the broker
+	if c.localOnly {
+		return residency.ErrLocalOnly
+	}
+
+	c.startFailbackWatch()
+
 	// If already trying to connect, don't try again
 	if c.connectTimer != nil {
 		return nil
@@ -119,18 +147,56 @@ func (c *Client) Connect() error {
 
 // connectWithBackoff handles the actual connection with retry logic
 func (c *Client) connectWithBackoff() error {
+	now := time.Now()
+
+	// A finished cooldown grants a fresh attempt budget
+	if c.reconnect.cooldown {
+		c.reconnect.reset()
+	}
+	if !c.reconnect.recordAttempt(now) {
+		c.logger.Event(logger.LevelWarn, ComponentMQTT, logger.EventMQTTReconnectCooldown,
+			logger.F("attempts", connectBudgetAttempts),
+			logger.F("window", connectBudgetWindow),
+			logger.F("cooldown", connectCooldown))
+		c.reconnect.cooldown = true
+		c.scheduleAttempt(connectCooldown)
+		return fmt.Errorf("reconnect budget exhausted")
+	}
+
+	broker := c.activeBroker()
+
+	// Resolve the broker again so a moved broker or broken DNS is detected before connecting
+	addrs, err := resolveBroker(broker.Host)
+	if err != nil {
+		c.connectAttempt++
+		err = fmt.Errorf("failed to resolve broker %s: %w", broker.Host, err)
+		c.reconnect.recordError(err, now)
+		c.logger.Error(ComponentMQTT, "%v (attempt %d)", err, c.connectAttempt)
+		c.brokerUnreachable(now)
+		c.scheduleReconnect()
+		return err
+	}
+	if len(c.reconnect.resolvedAddrs) > 0 && strings.Join(addrs, ",") != strings.Join(c.reconnect.resolvedAddrs, ",") {
+		c.logger.Info(ComponentMQTT, "Broker %s now resolves to %v (was %v)", broker.Host, addrs, c.reconnect.resolvedAddrs)
+	}
+	c.reconnect.resolvedAddrs = addrs
+	c.reconnect.resolvedAt = now
+
 	// Create client options
 	opts := mqtt.NewClientOptions()
 
 	// Set broker address
-	brokerURL := fmt.Sprintf("tcp://%s:%d", c.cfg.MQTT.Broker, c.cfg.MQTT.Port)
+	brokerURL := "tcp://" + broker.Address()
 	if c.cfg.MQTT.EnableTLS {
-		brokerURL = fmt.Sprintf("ssl://%s:%d", c.cfg.MQTT.Broker, c.cfg.MQTT.Port)
+		brokerURL = "ssl://" + broker.Address()
 	}
 	opts.AddBroker(brokerURL)
 
-	// Generate a unique client ID if reusing the same one
-	if c.connectAttempt > 0 {
+	// A persistent session is bound to a stable client ID, otherwise generate a unique
+	// client ID if reusing the same one
+	if c.session.Persistent() && c.session.ClientID != "" {
+		c.cfg.MQTT.ClientID = c.session.ClientID
+	} else if c.connectAttempt > 0 {
 		c.cfg.MQTT.ClientID = fmt.Sprintf("jarvist-%d", time.Now().Unix()%10000)
 	}
 
@@ -138,21 +204,24 @@ func (c *Client) connectWithBackoff() error {
 	opts.SetClientID(c.cfg.MQTT.ClientID)
 
 	// Set credentials if provided
-	if c.cfg.MQTT.Username != "" {
-		opts.SetUsername(c.cfg.MQTT.Username)
-		opts.SetPassword(c.cfg.MQTT.Password)
+	if c.credentials.Username != "" {
+		opts.SetUsername(c.credentials.Username)
+		opts.SetPassword(c.credentials.Password)
 	}
 
 	// Set connection parameters
 	opts.SetKeepAlive(time.Duration(c.cfg.MQTT.Keepalive) * time.Second)
-	opts.SetCleanSession(true)   // Use clean session to avoid session conflicts
+	if err := applySession(opts, c.session); err != nil {
+		c.logger.Warning(ComponentMQTT, "Falling back to a clean session: %v", err)
+		opts.SetCleanSession(true)
+	}
 	opts.SetAutoReconnect(false) // We'll handle reconnections ourselves
 	opts.SetConnectTimeout(30 * time.Second)
 	opts.SetOrderMatters(false) // Don't block on message acks
 
 	// Log lebih detail tentang konfigurasi MQTT
-	c.logger.Info(ComponentMQTT, "MQTT Configuration - QoS: %d, ClientID: %s",
-		c.cfg.MQTT.QoS, c.cfg.MQTT.ClientID)
+	c.logger.Info(ComponentMQTT, "MQTT Configuration - QoS: %d, ClientID: %s, persistent session: %v",
+		c.cfg.MQTT.QoS, c.cfg.MQTT.ClientID, c.session.Persistent())
 
 	// Set TLS if enabled
 	if c.cfg.MQTT.EnableTLS && c.cfg.MQTT.CACertPath != "" {
@@ -172,12 +241,20 @@ func (c *Client) connectWithBackoff() error {
 
 	// Connect
 	c.logger.Info(ComponentMQTT, "Connecting to MQTT broker at %s", brokerURL)
+	c.connecting = true
 	token := c.client.Connect()
 
 	// Wait for connection attempt to complete
 	c.connectAttempt++
-	if token.Wait() && token.Error() != nil {
-		c.logger.Error(ComponentMQTT, "Failed to connect to MQTT broker (attempt %d): %v", c.connectAttempt, token.Error())
+	token.Wait()
+	c.connecting = false
+	if token.Error() != nil {
+		c.logger.Event(logger.LevelError, ComponentMQTT, logger.EventMQTTConnectFailed,
+			logger.F("broker", broker.Address()),
+			logger.F("attempt", c.connectAttempt),
+			logger.F("error", token.Error().Error()))
+		c.reconnect.recordError(token.Error(), time.Now())
+		c.brokerUnreachable(time.Now())
 
 		// Schedule retry with backoff
 		c.scheduleReconnect()
@@ -193,30 +270,134 @@ func (c *Client) scheduleReconnect() {
 		c.logger.Info(ComponentMQTT, "Clean disconnect requested, not scheduling reconnection")
 		return
 	}
+	if c.localOnly {
+		return
+	}
 
 	// Calculate backoff with jitter
-	jitter := 1.0 + (rand.Float64()*2-1)*retryJitter
-	backoff := time.Duration(float64(c.currentBackoff) * jitter)
+	backoff := nextBackoff(c.currentBackoff)
 
 	// Log reconnection plan
 	c.logger.Info(ComponentMQTT, "Scheduling reconnection attempt %d in %.2f seconds", c.connectAttempt+1, backoff.Seconds())
 
-	// Schedule reconnection
-	c.connectTimer = time.AfterFunc(backoff, func() {
+	c.scheduleAttempt(backoff)
+
+	// Increase backoff for next attempt (with max limit)
+	c.currentBackoff = growBackoff(c.currentBackoff)
+}
+
+// scheduleAttempt runs a connection attempt after delay
+func (c *Client) scheduleAttempt(delay time.Duration) {
+	if c.connectTimer != nil {
+		c.connectTimer.Stop()
+	}
+
+	c.reconnect.nextAttemptAt = time.Now().Add(delay)
+	c.connectTimer = time.AfterFunc(delay, func() {
 		c.mutex.Lock()
 		c.connectTimer = nil
+		c.reconnect.nextAttemptAt = time.Time{}
+		localOnly := c.localOnly
 		c.mutex.Unlock()
 
-		if !c.cleanDisconnect {
+		if !c.cleanDisconnect && !localOnly {
 			c.connectWithBackoff()
 		}
 	})
+}
 
-	// Increase backoff for next attempt (with max limit)
-	c.currentBackoff = time.Duration(math.Min(
-		float64(c.currentBackoff)*retryFactor,
-		float64(maxRetryDelay),
-	))
+// ForceReconnect drops the current connection and reconnects through the normal backoff,
+// used when the broker stopped responding without a disconnect callback
+func (c *Client) ForceReconnect(reason string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.cleanDisconnect || c.localOnly || c.connectTimer != nil || c.connecting {
+		return
+	}
+
+	c.logger.Warning(ComponentMQTT, "Forcing reconnect: %s", reason)
+
+	if c.client != nil && c.client.IsConnected() {
+		c.client.Disconnect(250)
+	}
+	c.connected = false
+	c.reconnect.recordError(errors.New(reason), time.Now())
+	c.connectionLost(time.Now())
+	c.brokerUnreachable(time.Now())
+	c.scheduleReconnect()
+}
+
+// EnsureConnecting starts a connection attempt if the client is neither connected nor
+// already waiting for one, e.g. after the first attempt failed to schedule a retry
+func (c *Client) EnsureConnecting() {
+	c.mutex.Lock()
+	idle := !c.cleanDisconnect && !c.localOnly && c.connectTimer == nil && !c.connecting &&
+		!(c.client != nil && c.client.IsConnected())
+	c.mutex.Unlock()
+
+	if idle {
+		c.Connect()
+	}
+}
+
+// connectionLost updates the flap history, the backoff is only reset when the
+// connection was stable so a flapping link keeps backing off
+func (c *Client) connectionLost(now time.Time) {
+	if c.reconnect.recordLost(now) {
+		c.logger.Event(logger.LevelWarn, ComponentMQTT, logger.EventMQTTConnectionFlapping,
+			logger.F("uptime", now.Sub(c.reconnect.connectedAt).Truncate(time.Second)),
+			logger.F("flaps", len(c.reconnect.flaps)))
+		return
+	}
+
+	c.currentBackoff = initialRetryDelay
+	c.connectAttempt = 0
+	c.reconnect.reset()
+}
+
+// ReconnectState returns the current backoff state
+func (c *Client) ReconnectState() ReconnectState {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	c.reconnect.pruneAttempts(now)
+
+	state := ReconnectState{
+		Attempt:         c.connectAttempt,
+		CurrentBackoff:  c.currentBackoff.String(),
+		NextAttemptAt:   timePtr(c.reconnect.nextAttemptAt),
+		BudgetUsed:      len(c.reconnect.attemptTimes),
+		BudgetLimit:     connectBudgetAttempts,
+		BudgetWindow:    connectBudgetWindow.String(),
+		LastError:       c.reconnect.lastError,
+		LastErrorAt:     timePtr(c.reconnect.lastErrorAt),
+		ResolvedAddrs:   c.reconnect.resolvedAddrs,
+		ResolvedAt:      timePtr(c.reconnect.resolvedAt),
+		LastConnectedAt: timePtr(c.reconnect.connectedAt),
+		LastLostAt:      timePtr(c.reconnect.lostAt),
+	}
+	for _, t := range c.reconnect.flaps {
+		if now.Sub(t) < flapHistoryWindow {
+			state.FlapsLastHour++
+		}
+	}
+
+	switch {
+	case c.connected && c.client != nil && c.client.IsConnected():
+		state.State = ReconnectConnected
+	case c.connecting:
+		state.State = ReconnectConnecting
+	case c.reconnect.cooldown && c.connectTimer != nil:
+		state.State = ReconnectCooldown
+	case c.connectTimer != nil:
+		state.State = ReconnectWaiting
+	default:
+		state.State = ReconnectStopped
+	}
+
+	return state
 }
 
 // Disconnect from the MQTT broker
@@ -226,6 +407,7 @@ func (c *Client) Disconnect() {
 
 	// Mark this as a clean disconnect to avoid reconnection
 	c.cleanDisconnect = true
+	c.stopFailbackWatch()
 
 	// Cancel any pending reconnect timers
 	if c.connectTimer != nil {
@@ -242,6 +424,35 @@ func (c *Client) Disconnect() {
 	// Reset connection state for clean reconnect later
 	c.currentBackoff = initialRetryDelay
 	c.connectAttempt = 0
+	c.reconnect.reset()
+}
+
+// SetLocalOnly switches local-only mode. Turning it on disconnects from the broker and
+// stops reconnecting, after turning it off the sender connects again.
+func (c *Client) SetLocalOnly(localOnly bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.localOnly == localOnly {
+		return
+	}
+	c.localOnly = localOnly
+	if !localOnly {
+		return
+	}
+
+	if c.connectTimer != nil {
+		c.connectTimer.Stop()
+		c.connectTimer = nil
+	}
+	if c.client != nil && c.client.IsConnected() {
+		c.client.Disconnect(250)
+		c.logger.Info(ComponentMQTT, "Disconnected from MQTT broker, local-only mode is on")
+	}
+	c.connected = false
+	c.currentBackoff = initialRetryDelay
+	c.connectAttempt = 0
+	c.reconnect.reset()
 }
 
 // IsConnected returns whether the client is connected
@@ -288,8 +499,12 @@ func (c *Client) Publish(topic string, payload []byte) error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	if c.localOnly {
+		return residency.ErrLocalOnly
+	}
 	if !c.connected || c.client == nil || !c.client.IsConnected() {
-		return fmt.Errorf("not connected to MQTT broker")
+		c.metrics.Record(topic, ErrNotConnected)
+		return ErrNotConnected
 	}
 
 	// Ekstrak ID pesan untuk pemeriksaan duplikat
@@ -309,14 +524,55 @@ func (c *Client) Publish(topic string, payload []byte) error {
 			topic, c.cfg.MQTT.QoS)
 	}
 
+	// Perangkat uji dipindah ke namespace staging dan payload-nya ditandai
+	topic = c.topicFor(topic)
+	payload = c.tagPayload(payload)
+	// Kredensial, URL RTSP dan data pribadi tidak boleh keluar dari perangkat
+	payload = redact.JSON(payload)
+
 	// Publish pesan
 	token := c.client.Publish(topic, c.cfg.MQTT.QoS, false, payload)
-	token.Wait()
+	err := waitToken(token)
+	c.payloadLog.Record(topic, payload, err)
+	c.metrics.Record(topic, err)
 
-	if token.Error() != nil {
-		return token.Error()
+	if err != nil {
+		return err
 	}
 
+	bandwidth.RecordMQTTPublish(topic, payload)
+	c.lastActivity = time.Now()
+	return nil
+}
+
+// PublishRetained publishes a message the broker keeps as the last value of the topic, so
+// a subscriber gets it right away instead of waiting for the next publish
+func (c *Client) PublishRetained(topic string, payload []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.localOnly {
+		return residency.ErrLocalOnly
+	}
+	if !c.connected || c.client == nil || !c.client.IsConnected() {
+		c.metrics.Record(topic, ErrNotConnected)
+		return ErrNotConnected
+	}
+
+	topic = c.topicFor(topic)
+	payload = c.tagPayload(payload)
+	payload = redact.JSON(payload)
+
+	token := c.client.Publish(topic, c.cfg.MQTT.QoS, true, payload)
+	err := waitToken(token)
+	c.payloadLog.Record(topic, payload, err)
+	c.metrics.Record(topic, err)
+
+	if err != nil {
+		return err
+	}
+
+	bandwidth.RecordMQTTPublish(topic, payload)
 	c.lastActivity = time.Now()
 	return nil
 }
@@ -326,26 +582,84 @@ func (c *Client) PublishHeartbeat(heartbeatTopic string, payload []byte) error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	if c.localOnly {
+		return residency.ErrLocalOnly
+	}
 	if !c.connected || c.client == nil || !c.client.IsConnected() {
-		return fmt.Errorf("not connected to MQTT broker")
+		c.metrics.Record(heartbeatTopic, ErrNotConnected)
+		return ErrNotConnected
 	}
 
 	// Debug logging untuk heartbeat
 	c.logger.Debug(ComponentMQTT, "Publishing heartbeat to topic %s with QoS 0", heartbeatTopic)
 
+	heartbeatTopic = c.topicFor(heartbeatTopic)
+	payload = c.tagPayload(payload)
+	payload = redact.JSON(payload)
+
 	// Publish with QoS 0 for heartbeat (no persistence needed)
 	token := c.client.Publish(heartbeatTopic, 0, false, payload)
-	token.Wait()
+	err := waitToken(token)
+	c.payloadLog.Record(heartbeatTopic, payload, err)
+	c.metrics.Record(heartbeatTopic, err)
 
-	if token.Error() != nil {
-		return token.Error()
+	if err != nil {
+		return err
 	}
 
+	bandwidth.RecordMQTTPublish(heartbeatTopic, payload)
+
 	// Update last activity time
 	c.lastActivity = time.Now()
 	return nil
 }
 
+// waitToken waits for the broker to acknowledge a publish, bounded by publishTimeout
+func waitToken(token mqtt.Token) error {
+	if !token.WaitTimeout(publishTimeout) {
+		return ErrPublishTimeout
+	}
+	return token.Error()
+}
+
+// SetSession changes the session settings used by the next connection
+func (c *Client) SetSession(session SessionSettings) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.session = session
+}
+
+// Session returns the session settings of the client
+func (c *Client) Session() SessionSettings {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.session
+}
+
+// SetCredentials changes the broker login used by the next connection
+func (c *Client) SetCredentials(creds Credentials) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.credentials = creds
+}
+
+// Credentials returns the broker login of the client
+func (c *Client) Credentials() Credentials {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.credentials
+}
+
+// Metrics returns the per-topic publish counters
+func (c *Client) Metrics() *PublishMetrics {
+	return c.metrics
+}
+
+// PayloadLog returns the sanitized payload logger, nil if no log directory is configured
+func (c *Client) PayloadLog() *PayloadLogger {
+	return c.payloadLog
+}
+
 // GetLastActivity returns the time of last activity
 func (c *Client) GetLastActivity() time.Time {
 	c.mutex.Lock()
@@ -366,36 +680,88 @@ func (c *Client) onConnect(client mqtt.Client) {
 	defer c.mutex.Unlock()
 
 	c.logger.Info(ComponentMQTT, "Connected to MQTT broker - stabilizing connection...")
+	c.reconnect.connectedAt = time.Now()
+	c.failover.unreachableSince = time.Time{}
+	// An expired session was discarded by this connection, later reconnects resume it
+	c.session.ForceClean = false
 
 	// Add a stabilization delay to ensure connection is stable
 	time.AfterFunc(stabilizationDelay, func() {
 		c.mutex.Lock()
 		defer c.mutex.Unlock()
 
+		// Local-only mode was switched on while connecting
+		if c.localOnly {
+			client.Disconnect(250)
+			return
+		}
 		if client.IsConnected() {
 			c.connected = true
 			c.lastActivity = time.Now()
-			c.currentBackoff = initialRetryDelay // Reset backoff on successful connection
-			c.logger.Info(ComponentMQTT, "MQTT connection stabilized")
+			c.reconnect.nextAttemptAt = time.Time{}
+			c.logger.Event(logger.LevelInfo, ComponentMQTT, logger.EventMQTTConnected, logger.F("broker", c.activeBroker().Address()))
+			go c.subscribeCommands(client)
 		}
 	})
 }
 
+// HandleCommand registers the handler of a command published to <topic>/command/<name>.
+// Commands are subscribed on every connect, handlers run in their own goroutine.
+func (c *Client) HandleCommand(name string, handler func(payload []byte)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.commands[name] = handler
+}
+
+// subscribeCommands subscribes the registered commands, a clean session drops them on reconnect
+func (c *Client) subscribeCommands(client mqtt.Client) {
+	c.mutex.Lock()
+	commands := make(map[string]func(payload []byte), len(c.commands))
+	for name, handler := range c.commands {
+		commands[name] = handler
+	}
+	c.mutex.Unlock()
+
+	for name, handler := range commands {
+		topic := c.topicFor(fmt.Sprintf("%s/command/%s", c.cfg.MQTT.Topic, name))
+		callback := func(_ mqtt.Client, msg mqtt.Message) {
+			// Pesan retained akan terulang di setiap koneksi, hanya perintah baru yang dijalankan
+			if msg.Retained() {
+				c.logger.Warning(ComponentMQTT, "Ignoring retained command on %s", msg.Topic())
+				return
+			}
+			c.logger.Info(ComponentMQTT, "Received command on %s", msg.Topic())
+			go handler(msg.Payload())
+		}
+
+		token := client.Subscribe(topic, 1, callback)
+		if !token.WaitTimeout(publishTimeout) {
+			c.logger.Warning(ComponentMQTT, "Timed out subscribing to %s", topic)
+		} else if err := token.Error(); err != nil {
+			c.logger.Warning(ComponentMQTT, "Failed to subscribe to %s: %v", topic, err)
+		}
+	}
+}
+
 // onDisconnect is called when disconnected from the broker
 func (c *Client) onDisconnect(client mqtt.Client, err error) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
 	c.connected = false
-	disconnectTime := time.Now().Format(time.RFC3339)
 
 	if c.cleanDisconnect {
 		c.logger.Info(ComponentMQTT, "Clean disconnect from MQTT broker")
 		return
 	}
 
-	c.logger.Warning(ComponentMQTT, "Disconnected from MQTT broker at %s : %v", disconnectTime, err)
-	c.logger.Warning(ComponentMQTT, "Data will be stored in local database")
+	c.logger.Event(logger.LevelWarn, ComponentMQTT, logger.EventMQTTDisconnected, logger.F("error", fmt.Sprint(err)))
+
+	if err != nil {
+		c.reconnect.recordError(err, time.Now())
+	}
+	c.connectionLost(time.Now())
+	c.brokerUnreachable(time.Now())
 
 	// Schedule reconnection with backoff
 	c.scheduleReconnect()
@@ -411,7 +777,7 @@ func (c *Client) MeasurePing() time.Duration {
 
 	start := time.Now()
 
-	pingTopic := fmt.Sprintf("%s/ping", c.cfg.MQTT.Topic)
+	pingTopic := c.topicFor(fmt.Sprintf("%s/ping", c.cfg.MQTT.Topic))
 	payload := []byte(fmt.Sprintf(`{"timestamp":"%s"}`, time.Now().Format(time.RFC3339)))
 
 	pingDone := make(chan bool, 1)
@@ -422,7 +788,7 @@ func (c *Client) MeasurePing() time.Duration {
 		pingDone <- true
 	}
 
-	responseTopic := fmt.Sprintf("%s/pong", c.cfg.MQTT.Topic)
+	responseTopic := c.topicFor(fmt.Sprintf("%s/pong", c.cfg.MQTT.Topic))
 	if token := c.client.Subscribe(responseTopic, 0, messageHandler); token.Wait() && token.Error() != nil {
 		return 0
 	}
@@ -431,6 +797,7 @@ func (c *Client) MeasurePing() time.Duration {
 	if token := c.client.Publish(pingTopic, 0, false, payload); token.Wait() && token.Error() != nil {
 		return 0
 	}
+	bandwidth.RecordMQTTPublish(pingTopic, payload)
 
 	select {
 	case <-pingDone:
@@ -0,0 +1,248 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/syncmanager/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Setting keys selecting where the broker username and password are read from, re-read
+// with the upload policy so rotated secrets are picked up without a restart
+const (
+	CredentialsSourceKey = "mqtt_credentials_source"
+	CredentialsRefKey    = "mqtt_credentials_ref"
+)
+
+// Credential sources
+const (
+	CredentialsConfig  = "config"  // Username and password of the config file and sync settings
+	CredentialsEnv     = "env"     // Environment variables <ref>_USERNAME and <ref>_PASSWORD
+	CredentialsWinCred = "wincred" // Generic credential <ref> of the Windows Credential Manager
+	CredentialsFile    = "file"    // Mounted secrets file or directory <ref>
+)
+
+// Default references of the sources
+const (
+	defaultCredentialsEnv     = "JARVIST_MQTT"
+	defaultCredentialsWinCred = "jarvist/mqtt"
+)
+
+// CredentialSources lists the sources the credentials can be read from
+var CredentialSources = []string{CredentialsConfig, CredentialsEnv, CredentialsWinCred, CredentialsFile}
+
+// Credentials is the broker login used by the next connection
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// CredentialsState describes the credentials in use without revealing the password
+type CredentialsState struct {
+	Source   string     `json:"source"`
+	Ref      string     `json:"ref,omitempty"`
+	Username string     `json:"username,omitempty"`
+	LoadedAt *time.Time `json:"loaded_at,omitempty"`
+	Error    string     `json:"error,omitempty"` // Last failed read, the previous credentials stay in use
+}
+
+// LoadCredentialSource reads the selected source and its reference, unknown sources fall back
+// to the config file
+func LoadCredentialSource(db *gorm.DB) (source, ref string) {
+	source = strings.ToLower(strings.TrimSpace(getSetting(db, CredentialsSourceKey)))
+	if ValidateCredentialSource(source) != nil {
+		source = CredentialsConfig
+	}
+	if source == "" {
+		source = CredentialsConfig
+	}
+
+	ref = strings.TrimSpace(getSetting(db, CredentialsRefKey))
+	if ref == "" {
+		switch source {
+		case CredentialsEnv:
+			ref = defaultCredentialsEnv
+		case CredentialsWinCred:
+			ref = defaultCredentialsWinCred
+		}
+	}
+	return source, ref
+}
+
+// ValidateCredentialSource checks a credentials source setting, empty selects the config file
+func ValidateCredentialSource(source string) error {
+	if source == "" {
+		return nil
+	}
+	for _, known := range CredentialSources {
+		if source == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown credentials source %q, expected one of %s", source, strings.Join(CredentialSources, ", "))
+}
+
+// ReadCredentials reads the broker login from a source
+func ReadCredentials(cfg *config.Config, source, ref string) (Credentials, error) {
+	switch source {
+	case CredentialsEnv:
+		return envCredentials(ref)
+	case CredentialsWinCred:
+		return readWindowsCredential(ref)
+	case CredentialsFile:
+		return fileCredentials(ref)
+	default:
+		return Credentials{Username: cfg.MQTT.Username, Password: cfg.MQTT.Password}, nil
+	}
+}
+
+// envCredentials reads <prefix>_USERNAME and <prefix>_PASSWORD. The environment of the service
+// is fixed when it starts, rotating these needs a restart of the service.
+func envCredentials(prefix string) (Credentials, error) {
+	username, ok := os.LookupEnv(prefix + "_USERNAME")
+	if !ok {
+		return Credentials{}, fmt.Errorf("environment variable %s_USERNAME is not set", prefix)
+	}
+	return Credentials{Username: username, Password: os.Getenv(prefix + "_PASSWORD")}, nil
+}
+
+// fileCredentials reads a mounted secret. A directory holds one file per value named username
+// and password, a file holds a JSON object or username=... and password=... lines.
+func fileCredentials(path string) (Credentials, error) {
+	if path == "" {
+		return Credentials{}, errors.New("no credentials file configured")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to read credentials: %w", err)
+	}
+	if info.IsDir() {
+		username, err := os.ReadFile(filepath.Join(path, "username"))
+		if err != nil {
+			return Credentials{}, fmt.Errorf("failed to read credentials: %w", err)
+		}
+		password, err := os.ReadFile(filepath.Join(path, "password"))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return Credentials{}, fmt.Errorf("failed to read credentials: %w", err)
+		}
+		return Credentials{
+			Username: strings.TrimRight(string(username), "\r\n"),
+			Password: strings.TrimRight(string(password), "\r\n"),
+		}, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to read credentials: %w", err)
+	}
+	return parseCredentials(data)
+}
+
+func parseCredentials(data []byte) (Credentials, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var creds Credentials
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var values struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}
+		if err := json.Unmarshal(trimmed, &values); err != nil {
+			return Credentials{}, fmt.Errorf("invalid credentials file: %w", err)
+		}
+		creds = Credentials{Username: values.Username, Password: values.Password}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			// Format .env juga diterima, misalnya MQTT_USERNAME=...
+			switch strings.TrimPrefix(strings.ToLower(strings.TrimSpace(key)), "mqtt_") {
+			case "username":
+				creds.Username = strings.Trim(strings.TrimSpace(value), `"'`)
+			case "password":
+				creds.Password = strings.Trim(strings.TrimSpace(value), `"'`)
+			}
+		}
+	}
+
+	if creds.Username == "" {
+		return Credentials{}, errors.New("credentials file has no username")
+	}
+	return creds, nil
+}
+
+// reloadCredentials reads the credentials again and reconnects with them when they changed.
+// A failed read keeps the credentials in use, so a secret being rewritten is retried with the
+// next policy check instead of dropping the connection.
+func (t *Sender) reloadCredentials() {
+	source, ref := LoadCredentialSource(t.db)
+	creds, err := ReadCredentials(t.cfg, source, ref)
+
+	t.credentialsMutex.Lock()
+	previous := t.credentialsState
+	state := CredentialsState{Source: source, Ref: ref, Username: previous.Username, LoadedAt: previous.LoadedAt}
+	if err != nil {
+		state.Error = err.Error()
+		t.credentialsState = state
+		t.credentialsMutex.Unlock()
+		if previous.LoadedAt == nil {
+			// Jangan diam-diam memakai login dari config jika sumber lain dipilih
+			t.client.SetCredentials(Credentials{})
+		}
+		if previous.Error != state.Error || previous.Source != source || previous.Ref != ref {
+			t.logger.Warning(ComponentSender, "Failed to read MQTT credentials from %s: %v", source, err)
+		}
+		return
+	}
+
+	now := time.Now()
+	state.Username = creds.Username
+	state.LoadedAt = &now
+	changed := creds != t.client.Credentials()
+	if !changed && previous.LoadedAt != nil {
+		state.LoadedAt = previous.LoadedAt
+	}
+	t.credentialsState = state
+	t.credentialsMutex.Unlock()
+
+	if !changed {
+		return
+	}
+	t.client.SetCredentials(creds)
+
+	t.mutex.Lock()
+	running := t.running && !t.shutdown
+	t.mutex.Unlock()
+	if !running {
+		return
+	}
+
+	// Saat tidak terhubung, percobaan berikutnya sudah memakai login baru
+	t.logger.Info(ComponentSender, "MQTT credentials from %s changed, reconnecting", source)
+	if t.client.IsConnected() {
+		t.client.ForceReconnect("credentials rotated")
+	}
+}
+
+// GetCredentials returns where the broker login comes from and when it was last read
+func (t *Sender) GetCredentials() CredentialsState {
+	t.credentialsMutex.Lock()
+	defer t.credentialsMutex.Unlock()
+	return t.credentialsState
+}
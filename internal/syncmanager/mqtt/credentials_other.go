@@ -0,0 +1,10 @@
+//go:build !windows
+
+package mqtt
+
+import "errors"
+
+// readWindowsCredential is only implemented on Windows
+func readWindowsCredential(target string) (Credentials, error) {
+	return Credentials{}, errors.New("the Windows Credential Manager is only available on Windows")
+}
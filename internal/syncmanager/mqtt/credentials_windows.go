@@ -0,0 +1,80 @@
+package mqtt
+
+import (
+	"fmt"
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	advapi32      = windows.NewLazySystemDLL("advapi32.dll")
+	procCredReadW = advapi32.NewProc("CredReadW")
+	procCredFree  = advapi32.NewProc("CredFree")
+)
+
+const credTypeGeneric = 1
+
+// credential mirrors CREDENTIALW
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// readWindowsCredential reads a generic credential of the Windows Credential Manager. It is
+// read from the vault of the account the service runs as, e.g. created with
+// cmdkey /generic:<target> /user:<username> /pass:<password>.
+func readWindowsCredential(target string) (Credentials, error) {
+	if target == "" {
+		return Credentials{}, fmt.Errorf("no credential target configured")
+	}
+	name, err := windows.UTF16PtrFromString(target)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	var cred *credential
+	ret, _, callErr := procCredReadW.Call(uintptr(unsafe.Pointer(name)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if ret == 0 {
+		return Credentials{}, fmt.Errorf("failed to read credential %q: %w", target, callErr)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	creds := Credentials{Username: windows.UTF16PtrToString(cred.UserName)}
+	if cred.CredentialBlobSize > 0 && cred.CredentialBlob != nil {
+		blob := unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)
+		creds.Password = credentialBlob(blob)
+	}
+	if creds.Username == "" {
+		return Credentials{}, fmt.Errorf("credential %q has no username", target)
+	}
+	return creds, nil
+}
+
+// credentialBlob decodes the secret, cmdkey and the control panel store it as UTF-16 while
+// other tools write UTF-8
+func credentialBlob(blob []byte) string {
+	if len(blob)%2 == 0 {
+		for i := 1; i < len(blob); i += 2 {
+			if blob[i] == 0 {
+				units := make([]uint16, len(blob)/2)
+				for j := range units {
+					units[j] = uint16(blob[2*j]) | uint16(blob[2*j+1])<<8
+				}
+				return string(utf16.Decode(units))
+			}
+		}
+	}
+	return string(blob)
+}
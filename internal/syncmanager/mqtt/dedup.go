@@ -0,0 +1,84 @@
+package mqtt
+
+import (
+	"errors"
+	"jarvist/internal/common/models"
+	"sync/atomic"
+)
+
+// errSendingDuplicate is returned while another worker sends a message with the same
+// idempotency key, the message is queued again and skipped once the other one is sent
+var errSendingDuplicate = errors.New("a message with the same content is being sent")
+
+// skipDuplicate closes msg without sending it when a message with the same idempotency key
+// was sent already, so a reconnect or a file processed again never reaches the backend
+// twice. It reports whether msg was skipped.
+func (t *Sender) skipDuplicate(msg models.PendingMessage) bool {
+	originalID, err := t.messageService.FindSentDuplicate(msg)
+	if err != nil {
+		t.logger.Warning(ComponentWorker, "Failed to check message ID %d for duplicates: %v", msg.ID, err)
+		return false
+	}
+	if originalID == 0 {
+		return false
+	}
+
+	if err := t.messageService.MarkDuplicate(msg.ID, originalID); err != nil {
+		t.logger.Warning(ComponentWorker, "%v", err)
+		return false
+	}
+	atomic.AddUint64(&t.duplicatesSkipped, 1)
+	return true
+}
+
+// claimKey reserves the idempotency key of msg until releaseKey, false when another
+// message with the same key is being sent. Messages without a key are always claimed.
+func (t *Sender) claimKey(msg models.PendingMessage) bool {
+	if msg.IdempotencyKey == "" {
+		return true
+	}
+
+	t.sendingMutex.Lock()
+	defer t.sendingMutex.Unlock()
+
+	if id, ok := t.sending[msg.IdempotencyKey]; ok && id != msg.ID {
+		return false
+	}
+	t.sending[msg.IdempotencyKey] = msg.ID
+	return true
+}
+
+// releaseKey frees the key claimed for msg, after msg was marked sent
+func (t *Sender) releaseKey(msg models.PendingMessage) {
+	if msg.IdempotencyKey == "" {
+		return
+	}
+
+	t.sendingMutex.Lock()
+	defer t.sendingMutex.Unlock()
+
+	if t.sending[msg.IdempotencyKey] == msg.ID {
+		delete(t.sending, msg.IdempotencyKey)
+	}
+}
+
+// sendOnce publishes msg and marks it sent while holding its idempotency key. A message
+// whose data was already sent is skipped instead.
+func (t *Sender) sendOnce(msg models.PendingMessage) (skipped bool, err error) {
+	if !t.claimKey(msg) {
+		return false, errSendingDuplicate
+	}
+	defer t.releaseKey(msg)
+
+	if t.skipDuplicate(msg) {
+		return true, nil
+	}
+
+	if err := t.publishMessage(msg); err != nil {
+		return false, err
+	}
+	if err := t.messageService.MarkMessageSent(msg.ID); err != nil {
+		t.logger.Error(ComponentWorker, "Failed to mark message ID %d as sent: %v", msg.ID, err)
+	}
+	return false, nil
+}
@@ -0,0 +1,223 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"jarvist/internal/common/models"
+	"sync"
+	"time"
+)
+
+// Drain states
+const (
+	DrainRunning   = "running"
+	DrainCompleted = "completed"
+	DrainCancelled = "cancelled"
+)
+
+var ErrDrainRunning = errors.New("queue drain already running")
+
+type drainOutcome int
+
+const (
+	drainSent drainOutcome = iota
+	drainDeferred
+	drainSkipped
+)
+
+// drainFailure is a message the drain failed to publish, retried after the drain
+type drainFailure struct {
+	msg models.PendingMessage
+	err error
+}
+
+// DrainProgress reports a drain of the in-memory queues
+type DrainProgress struct {
+	State      string     `json:"state"`
+	Total      int        `json:"total"`
+	Sent       int        `json:"sent"`
+	Deferred   int        `json:"deferred"`
+	Skipped    int        `json:"skipped"` // Already sent by a worker or a duplicate of a sent message
+	Failed     int        `json:"failed"`
+	Remaining  int        `json:"remaining"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+}
+
+// QueueSnapshot describes the in-memory queues and the messages waiting in the database
+type QueueSnapshot struct {
+	Connected       bool           `json:"connected"`
+	ChannelLen      int            `json:"channel_queue_len"`
+	ChannelCapacity int            `json:"channel_capacity"`
+	BackingLen      int            `json:"backing_queue_len"`
+	TotalQueued     int            `json:"total_queued"`
+	PendingInDB     int64          `json:"pending_messages"`
+	Drain           *DrainProgress `json:"drain,omitempty"`
+	Time            time.Time      `json:"time"`
+}
+
+// drainState holds the progress of the current or last drain
+type drainState struct {
+	mu       sync.Mutex
+	progress *DrainProgress
+}
+
+// GetQueueSnapshot returns the queue lengths and the progress of the last drain
+func (t *Sender) GetQueueSnapshot() QueueSnapshot {
+	t.queueMutex.Lock()
+	backingLen := len(t.pendingQueue)
+	t.queueMutex.Unlock()
+
+	snapshot := QueueSnapshot{
+		Connected:       t.transportConnected(),
+		ChannelLen:      len(t.messageQueue),
+		ChannelCapacity: cap(t.messageQueue),
+		BackingLen:      backingLen,
+		TotalQueued:     len(t.messageQueue) + backingLen,
+		Drain:           t.drainProgress(),
+		Time:            time.Now(),
+	}
+
+	if count, err := t.messageService.CountPendingMessages(); err == nil {
+		snapshot.PendingInDB = count
+	} else {
+		t.logger.Warning(ComponentSender, "Failed to count pending messages: %v", err)
+	}
+
+	return snapshot
+}
+
+// DrainQueues publishes the messages of the in-memory queues directly until they are empty
+// or ctx is done. Only messages queued when the drain starts are counted, messages that
+// fail are queued again once the drain ends.
+func (t *Sender) DrainQueues(ctx context.Context) (DrainProgress, error) {
+	if !t.transportConnected() {
+		return DrainProgress{}, ErrNotConnected
+	}
+
+	t.drain.mu.Lock()
+	if t.drain.progress != nil && t.drain.progress.State == DrainRunning {
+		t.drain.mu.Unlock()
+		return DrainProgress{}, ErrDrainRunning
+	}
+
+	t.queueMutex.Lock()
+	total := len(t.messageQueue) + len(t.pendingQueue)
+	t.queueMutex.Unlock()
+
+	progress := &DrainProgress{
+		State:     DrainRunning,
+		Total:     total,
+		Remaining: total,
+		StartedAt: time.Now(),
+	}
+	t.drain.progress = progress
+	t.drain.mu.Unlock()
+
+	t.logger.Info(ComponentSender, "Draining %d messages from in-memory queues", total)
+
+	var failed []drainFailure
+	state := DrainCompleted
+
+	for handled := 0; handled < total; handled++ {
+		if ctx.Err() != nil {
+			state = DrainCancelled
+			break
+		}
+
+		msg, ok := t.nextQueued()
+		if !ok {
+			break
+		}
+
+		outcome, err := t.drainMessage(msg)
+
+		t.drain.mu.Lock()
+		progress.Remaining--
+		switch {
+		case err != nil:
+			progress.Failed++
+			progress.LastError = err.Error()
+		case outcome == drainSent:
+			progress.Sent++
+		case outcome == drainDeferred:
+			progress.Deferred++
+		default:
+			progress.Skipped++
+		}
+		t.drain.mu.Unlock()
+
+		if err != nil {
+			failed = append(failed, drainFailure{msg: msg, err: err})
+		}
+	}
+
+	for _, failure := range failed {
+		t.retryLater(failure.msg, failure.err)
+	}
+
+	t.drain.mu.Lock()
+	finished := time.Now()
+	progress.State = state
+	progress.FinishedAt = &finished
+	result := *progress
+	t.drain.mu.Unlock()
+
+	t.logger.Info(ComponentSender, "Queue drain %s: %d sent, %d deferred, %d skipped, %d failed of %d",
+		state, result.Sent, result.Deferred, result.Skipped, result.Failed, result.Total)
+
+	return result, nil
+}
+
+// nextQueued takes the next message from the channel queue, then from the backing queue
+func (t *Sender) nextQueued() (models.PendingMessage, bool) {
+	select {
+	case msg := <-t.messageQueue:
+		return msg, true
+	default:
+	}
+
+	t.queueMutex.Lock()
+	defer t.queueMutex.Unlock()
+
+	if len(t.pendingQueue) == 0 {
+		return models.PendingMessage{}, false
+	}
+	msg := t.pendingQueue[0]
+	t.pendingQueue = t.pendingQueue[1:]
+	return msg, true
+}
+
+// drainMessage publishes a queued message unless it or its data was sent already or uploads
+// are paused
+func (t *Sender) drainMessage(msg models.PendingMessage) (drainOutcome, error) {
+	var existing models.PendingMessage
+	if err := t.db.Where("id = ?", msg.ID).First(&existing).Error; err == nil && existing.Sent {
+		return drainSkipped, nil
+	}
+
+	if t.shouldDefer(msg.Topic) {
+		if err := t.messageService.MarkDeferred(msg.ID); err != nil {
+			return drainDeferred, err
+		}
+		return drainDeferred, nil
+	}
+
+	skipped, err := t.sendOnce(msg)
+	if skipped {
+		return drainSkipped, nil
+	}
+	return drainSent, err
+}
+
+func (t *Sender) drainProgress() *DrainProgress {
+	t.drain.mu.Lock()
+	defer t.drain.mu.Unlock()
+
+	if t.drain.progress == nil {
+		return nil
+	}
+	progress := *t.drain.progress
+	return &progress
+}
@@ -0,0 +1,175 @@
+package mqtt
+
+import (
+	"jarvist/internal/syncmanager/config"
+	"net"
+	"time"
+)
+
+// Failback settings
+const (
+	failbackCheckInterval = 30 * time.Second
+	brokerProbeTimeout    = 5 * time.Second
+)
+
+// BrokerState is the broker the client uses from the failover list
+type BrokerState struct {
+	Active           string     `json:"active"`
+	Index            int        `json:"index"` // 0 is the primary broker
+	Brokers          []string   `json:"brokers"`
+	FailoverAfter    string     `json:"failover_after"`
+	UnreachableSince *time.Time `json:"unreachable_since,omitempty"`
+	SwitchedAt       *time.Time `json:"switched_at,omitempty"`
+	SwitchReason     string     `json:"switch_reason,omitempty"`
+}
+
+// brokerFailover tracks the broker in use, guarded by the client mutex
+type brokerFailover struct {
+	index            int
+	unreachableSince time.Time
+	switchedAt       time.Time
+	switchReason     string
+}
+
+// activeBroker returns the broker the next connection attempt uses, called with the mutex held
+func (c *Client) activeBroker() config.BrokerEndpoint {
+	endpoints := c.cfg.BrokerEndpoints()
+	if len(endpoints) == 0 {
+		return config.BrokerEndpoint{Host: c.cfg.MQTT.Broker, Port: c.cfg.MQTT.Port}
+	}
+	if c.failover.index >= len(endpoints) {
+		c.failover.index = 0
+	}
+	return endpoints[c.failover.index]
+}
+
+// brokerUnreachable counts a failed connection to the active broker. Once it has been
+// unreachable for the failover delay the next broker of the list is used, after the last one
+// the list starts over at the primary. Called with the mutex held.
+func (c *Client) brokerUnreachable(now time.Time) {
+	if c.failover.unreachableSince.IsZero() {
+		c.failover.unreachableSince = now
+		return
+	}
+
+	endpoints := c.cfg.BrokerEndpoints()
+	if len(endpoints) < 2 || now.Sub(c.failover.unreachableSince) < c.cfg.FailoverAfter() {
+		return
+	}
+
+	from := c.activeBroker()
+	next := (c.failover.index + 1) % len(endpoints)
+	reason := "unreachable for " + now.Sub(c.failover.unreachableSince).Truncate(time.Second).String()
+	c.logger.Warning(ComponentMQTT, "Broker %s %s, failing over to %s", from.Address(), reason, endpoints[next].Address())
+	c.switchBroker(next, now, reason)
+}
+
+// switchBroker makes the broker at index active, the next broker starts with a fresh backoff.
+// Called with the mutex held.
+func (c *Client) switchBroker(index int, now time.Time, reason string) {
+	c.failover.index = index
+	c.failover.unreachableSince = time.Time{}
+	c.failover.switchedAt = now
+	c.failover.switchReason = reason
+	c.currentBackoff = initialRetryDelay
+	c.reconnect.resolvedAddrs = nil
+}
+
+// startFailbackWatch starts the failback watcher of a connection, called with the mutex held
+func (c *Client) startFailbackWatch() {
+	if c.failbackStop != nil {
+		return
+	}
+	c.failbackStop = make(chan struct{})
+	go c.watchFailback(c.failbackStop)
+}
+
+// stopFailbackWatch stops the failback watcher, called with the mutex held
+func (c *Client) stopFailbackWatch() {
+	if c.failbackStop != nil {
+		close(c.failbackStop)
+		c.failbackStop = nil
+	}
+}
+
+// watchFailback probes the preferred brokers while the client is connected to a failover
+// broker and switches back to the first one that accepts connections again
+func (c *Client) watchFailback(stop <-chan struct{}) {
+	ticker := time.NewTicker(failbackCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		c.mutex.Lock()
+		index := c.failover.index
+		idle := c.localOnly || index == 0 || !c.connected || c.connectTimer != nil || c.connecting
+		var preferred []config.BrokerEndpoint
+		if !idle {
+			// Daftar broker bisa memendek setelah pengaturan diubah
+			endpoints := c.cfg.BrokerEndpoints()
+			preferred = endpoints[:min(index, len(endpoints))]
+		}
+		c.mutex.Unlock()
+
+		for i, broker := range preferred {
+			if !probeBroker(broker) {
+				continue
+			}
+			c.failBack(index, i, broker)
+			break
+		}
+	}
+}
+
+// failBack reconnects to the preferred broker at index if the client still uses the broker
+// at from
+func (c *Client) failBack(from, index int, broker config.BrokerEndpoint) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.failover.index != from || c.cleanDisconnect || c.localOnly || c.connectTimer != nil || c.connecting {
+		return
+	}
+
+	c.logger.Info(ComponentMQTT, "Broker %s is reachable again, failing back", broker.Address())
+	c.switchBroker(index, time.Now(), "failback")
+	if c.client != nil && c.client.IsConnected() {
+		c.client.Disconnect(250)
+	}
+	c.connected = false
+	c.scheduleAttempt(0)
+}
+
+// probeBroker reports whether the broker accepts TCP connections
+func probeBroker(broker config.BrokerEndpoint) bool {
+	conn, err := net.DialTimeout("tcp", broker.Address(), brokerProbeTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// BrokerState returns the active broker and the failover list
+func (c *Client) BrokerState() BrokerState {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	state := BrokerState{
+		Active:           c.activeBroker().Address(),
+		Index:            c.failover.index,
+		FailoverAfter:    c.cfg.FailoverAfter().String(),
+		UnreachableSince: timePtr(c.failover.unreachableSince),
+		SwitchedAt:       timePtr(c.failover.switchedAt),
+		SwitchReason:     c.failover.switchReason,
+	}
+	for _, broker := range c.cfg.BrokerEndpoints() {
+		state.Brokers = append(state.Brokers, broker.Address())
+	}
+	return state
+}
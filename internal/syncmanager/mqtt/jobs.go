@@ -0,0 +1,65 @@
+package mqtt
+
+import (
+	"context"
+	"jarvist/internal/syncmanager/scheduler"
+	"time"
+)
+
+// StatusSummaryJob is the name of the status summary job in the scheduler
+const StatusSummaryJob = "mqtt_status_summary"
+
+// RegisterJobs adds the periodic status summary to the scheduler
+func (t *Sender) RegisterJobs(jobs *scheduler.Scheduler) error {
+	return jobs.Add(scheduler.Job{
+		Name:      StatusSummaryJob,
+		Schedule:  "* * * * *",
+		Singleton: true,
+		Timeout:   30 * time.Second,
+		Run:       t.statusSummary,
+	})
+}
+
+// statusSummary logs the connection and queue state and checks old pending messages
+func (t *Sender) statusSummary(ctx context.Context) error {
+	if t.client.IsConnected() {
+		t.markSessionSeen()
+	}
+
+	t.mutex.Lock()
+	active := t.running && !t.shutdown
+	t.mutex.Unlock()
+
+	if !active {
+		return scheduler.ErrSkipped
+	}
+
+	pendingCount, err := t.messageService.CountPendingMessages()
+	if err != nil {
+		t.logger.Warning(ComponentMonitor, "Failed to count pending messages: %v", err)
+		pendingCount = 0
+	}
+
+	t.queueMutex.Lock()
+	pendingQueueLen := len(t.pendingQueue)
+	t.queueMutex.Unlock()
+
+	t.logger.Info(ComponentMonitor, "Status update: Connected=%v, Running=%v, DB pending=%d, Queue size=%d/%d, Backing queue=%d",
+		t.client.IsConnected(),
+		active,
+		pendingCount,
+		len(t.messageQueue), cap(t.messageQueue),
+		pendingQueueLen)
+
+	if pendingCount > 0 && t.transportConnected() {
+		needsCheck, err := t.messageService.HasOldPendingMessages(5 * time.Minute)
+		if err != nil {
+			return err
+		}
+		if needsCheck {
+			t.logger.Info(ComponentMonitor, "Found old pending messages, triggering check")
+			go t.checkPendingMessages()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package mqtt
+
+import (
+	"jarvist/internal/common/licensestate"
+	"jarvist/pkg/logger"
+	"time"
+)
+
+// refreshLicense re-reads the license degradation stored by the desktop app. While the
+// license is expired past its grace period every stored message is deferred, the counter
+// data stays in the local database and is sent once the license is renewed.
+func (t *Sender) refreshLicense() licensestate.State {
+	state := licensestate.Get(t.db)
+
+	t.pauseMutex.Lock()
+	previous := t.license
+	t.license = state
+	t.pauseMutex.Unlock()
+
+	if state.Degraded == previous.Degraded {
+		return state
+	}
+
+	if state.Degraded {
+		expiredAt := "unknown"
+		if state.ExpiredAt != nil {
+			expiredAt = state.ExpiredAt.Format("2006-01-02")
+		}
+		t.logger.Event(logger.LevelWarn, ComponentPolicy, logger.EventLicenseDegraded,
+			logger.F("reason", state.Reason), logger.F("expired_at", expiredAt))
+	} else {
+		duration := "unknown"
+		if previous.Since != nil {
+			duration = time.Since(*previous.Since).Round(time.Minute).String()
+		}
+		t.logger.Event(logger.LevelInfo, ComponentPolicy, logger.EventLicenseRestored, logger.F("duration", duration))
+	}
+
+	if t.client.IsConnected() {
+		go t.sendHeartbeat()
+	}
+	return state
+}
+
+// getLicense returns the license degradation the sender follows
+func (t *Sender) getLicense() licensestate.State {
+	t.pauseMutex.Lock()
+	defer t.pauseMutex.Unlock()
+	return t.license
+}
@@ -0,0 +1,62 @@
+package mqtt
+
+import (
+	"jarvist/internal/syncmanager/maintmode"
+	"strings"
+)
+
+// SetMaintenance pauses or resumes publishing for maintenance mode. While it is active every
+// stored message is deferred, only heartbeats are sent so the backend sees the device is in
+// maintenance. Deferred messages are released when it ends.
+func (t *Sender) SetMaintenance(state maintmode.State) {
+	t.pauseMutex.Lock()
+	wasActive := t.maintenance.Active
+	t.maintenance = state
+	degraded := t.license.Degraded
+	t.pauseMutex.Unlock()
+
+	if state.Active == wasActive {
+		return
+	}
+
+	if state.Active {
+		t.logger.Info(ComponentPolicy, "Publishing paused for maintenance mode")
+	} else if degraded {
+		t.logger.Info(ComponentPolicy, "Maintenance mode ended, deferred messages are kept until the license is renewed")
+	} else {
+		// Pesan non-kritis ditunda lagi oleh pending check jika upload masih dijeda
+		released, err := t.messageService.ReleaseDeferred()
+		if err != nil {
+			t.logger.Warning(ComponentPolicy, "Failed to release deferred messages: %v", err)
+		} else if !t.shutdown {
+			t.logger.Info(ComponentPolicy, "Maintenance mode ended, sending %d deferred messages", released)
+			go t.checkPendingMessages()
+		}
+	}
+
+	if t.client.IsConnected() {
+		go t.sendHeartbeat()
+	}
+}
+
+// HandleCommand registers the handler of an MQTT command published to <topic>/command/<name>
+func (t *Sender) HandleCommand(name string, handler func(payload []byte)) {
+	t.client.HandleCommand(name, handler)
+}
+
+// getMaintenance returns the maintenance state the sender follows
+func (t *Sender) getMaintenance() maintmode.State {
+	t.pauseMutex.Lock()
+	defer t.pauseMutex.Unlock()
+	return t.maintenance
+}
+
+// suppressAlert reports whether a log forwarded over MQTT is dropped as an alert. Warnings
+// and errors during maintenance are expected and stay in the local logs only.
+func (t *Sender) suppressAlert(topic string) bool {
+	if !t.getMaintenance().Active {
+		return false
+	}
+	level := topic[strings.LastIndex(topic, "/")+1:]
+	return level == "warn" || level == "error" || level == "fatal"
+}
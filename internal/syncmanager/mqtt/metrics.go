@@ -0,0 +1,170 @@
+package mqtt
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Publish failure causes
+const (
+	CauseTimeout        = "timeout"
+	CauseNotConnected   = "not_connected"
+	CauseBrokerRejected = "broker_rejected"
+)
+
+var (
+	ErrNotConnected   = errors.New("not connected to MQTT broker")
+	ErrPublishTimeout = errors.New("publish timed out waiting for broker")
+)
+
+// maxTopicPrefixLevels bounds the number of metric buckets for deep topic trees
+const maxTopicPrefixLevels = 3
+
+// TopicMetrics holds the publish counters of one topic prefix
+type TopicMetrics struct {
+	Prefix        string            `json:"prefix"`
+	Attempts      uint64            `json:"attempts"`
+	Successes     uint64            `json:"successes"`
+	Retries       uint64            `json:"retries"`
+	Failures      uint64            `json:"failures"`
+	FailureCauses map[string]uint64 `json:"failure_causes"`
+	LastError     string            `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time        `json:"last_error_at,omitempty"`
+	LastSuccessAt *time.Time        `json:"last_success_at,omitempty"`
+}
+
+// PublishMetrics counts publish attempts, successes, retries and failure causes per topic prefix
+type PublishMetrics struct {
+	mu     sync.Mutex
+	since  time.Time
+	topics map[string]*TopicMetrics
+}
+
+// NewPublishMetrics creates an empty metrics set
+func NewPublishMetrics() *PublishMetrics {
+	return &PublishMetrics{
+		since:  time.Now(),
+		topics: make(map[string]*TopicMetrics),
+	}
+}
+
+// Record counts one publish attempt and its result
+func (m *PublishMetrics) Record(topic string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := m.entryLocked(topic)
+	entry.Attempts++
+
+	now := time.Now()
+	if err == nil {
+		entry.Successes++
+		entry.LastSuccessAt = &now
+		return
+	}
+
+	entry.Failures++
+	entry.FailureCauses[ClassifyPublishError(err)]++
+	entry.LastError = err.Error()
+	entry.LastErrorAt = &now
+}
+
+// RecordRetry counts a message that is queued again after a failed publish
+func (m *PublishMetrics) RecordRetry(topic string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entryLocked(topic).Retries++
+}
+
+// Snapshot returns a copy of the counters sorted by prefix
+func (m *PublishMetrics) Snapshot() []TopicMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make([]TopicMetrics, 0, len(m.topics))
+	for _, entry := range m.topics {
+		copied := *entry
+		copied.FailureCauses = make(map[string]uint64, len(entry.FailureCauses))
+		for cause, count := range entry.FailureCauses {
+			copied.FailureCauses[cause] = count
+		}
+		snapshot = append(snapshot, copied)
+	}
+
+	sort.Slice(snapshot, func(i, j int) bool {
+		return snapshot[i].Prefix < snapshot[j].Prefix
+	})
+	return snapshot
+}
+
+// Since returns when counting started
+func (m *PublishMetrics) Since() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.since
+}
+
+// Reset clears all counters
+func (m *PublishMetrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.since = time.Now()
+	m.topics = make(map[string]*TopicMetrics)
+}
+
+func (m *PublishMetrics) entryLocked(topic string) *TopicMetrics {
+	prefix := TopicPrefix(topic)
+	entry, ok := m.topics[prefix]
+	if !ok {
+		entry = &TopicMetrics{
+			Prefix:        prefix,
+			FailureCauses: make(map[string]uint64),
+		}
+		m.topics[prefix] = entry
+	}
+	return entry
+}
+
+// ClassifyPublishError maps a publish error to a failure cause
+func ClassifyPublishError(err error) string {
+	switch {
+	case errors.Is(err, ErrPublishTimeout):
+		return CauseTimeout
+	case errors.Is(err, ErrNotConnected), errors.Is(err, mqtt.ErrNotConnected):
+		return CauseNotConnected
+	default:
+		return CauseBrokerRejected
+	}
+}
+
+// TopicPrefix groups a topic into its metric bucket: trailing numeric levels such as
+// date folders and IDs are dropped and at most three levels are kept
+func TopicPrefix(topic string) string {
+	levels := strings.Split(strings.Trim(topic, "/"), "/")
+	for len(levels) > 1 && isNumericLevel(levels[len(levels)-1]) {
+		levels = levels[:len(levels)-1]
+	}
+	if len(levels) > maxTopicPrefixLevels {
+		levels = levels[:maxTopicPrefixLevels]
+	}
+	return strings.Join(levels, "/")
+}
+
+func isNumericLevel(level string) bool {
+	if level == "" {
+		return false
+	}
+	for _, c := range level {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
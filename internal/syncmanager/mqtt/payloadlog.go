@@ -0,0 +1,276 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	PayloadLogFileName = "mqtt_payloads.log"
+
+	DefaultPayloadLogDuration = time.Hour
+	MaxPayloadLogDuration     = 24 * time.Hour
+	DefaultPayloadLogMaxBytes = 4 * 1024 // per payload
+	payloadLogMaxFileSize     = 5 * 1024 * 1024
+	payloadLogMaxBackups      = 3
+
+	redactedValue = "[REDACTED]"
+)
+
+// Field names containing one of these are redacted before a payload is written
+var secretFieldMarkers = []string{"password", "passwd", "secret", "token", "key", "license", "authorization", "credential"}
+
+// PayloadLogStatus describes the payload logging mode
+type PayloadLogStatus struct {
+	Enabled         bool       `json:"enabled"`
+	Path            string     `json:"path"`
+	TopicFilter     string     `json:"topic_filter,omitempty"`
+	MaxPayloadBytes int        `json:"max_payload_bytes"`
+	Logged          int64      `json:"logged"`
+	EnabledAt       *time.Time `json:"enabled_at,omitempty"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+}
+
+// PayloadLogger writes sanitized copies of outgoing payloads to a separate rotating file.
+// It is off by default and switches itself off after the requested duration.
+type PayloadLogger struct {
+	mu              sync.Mutex
+	path            string
+	file            *os.File
+	size            int64
+	enabled         bool
+	topicFilter     string
+	maxPayloadBytes int
+	logged          int64
+	enabledAt       time.Time
+	expiresAt       time.Time
+}
+
+// NewPayloadLogger creates a disabled payload logger writing to logDir
+func NewPayloadLogger(logDir string) *PayloadLogger {
+	return &PayloadLogger{
+		path:            filepath.Join(logDir, PayloadLogFileName),
+		maxPayloadBytes: DefaultPayloadLogMaxBytes,
+	}
+}
+
+// Enable starts logging payloads of topics containing topicFilter (all topics if empty)
+// for the given duration, payloads are cut at maxPayloadBytes
+func (p *PayloadLogger) Enable(duration time.Duration, topicFilter string, maxPayloadBytes int) error {
+	if duration <= 0 {
+		duration = DefaultPayloadLogDuration
+	}
+	if duration > MaxPayloadLogDuration {
+		return fmt.Errorf("duration is limited to %v", MaxPayloadLogDuration)
+	}
+	if maxPayloadBytes <= 0 {
+		maxPayloadBytes = DefaultPayloadLogMaxBytes
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.openLocked(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	p.enabled = true
+	p.topicFilter = topicFilter
+	p.maxPayloadBytes = maxPayloadBytes
+	p.logged = 0
+	p.enabledAt = now
+	p.expiresAt = now.Add(duration)
+	return nil
+}
+
+// Disable stops payload logging and closes the file
+func (p *PayloadLogger) Disable() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.disableLocked()
+}
+
+// Status returns the current payload logging mode
+func (p *PayloadLogger) Status() PayloadLogStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.checkExpiryLocked()
+
+	status := PayloadLogStatus{
+		Enabled:         p.enabled,
+		Path:            p.path,
+		TopicFilter:     p.topicFilter,
+		MaxPayloadBytes: p.maxPayloadBytes,
+		Logged:          p.logged,
+	}
+	if p.enabled {
+		enabledAt, expiresAt := p.enabledAt, p.expiresAt
+		status.EnabledAt = &enabledAt
+		status.ExpiresAt = &expiresAt
+	}
+	return status
+}
+
+// Record writes a sanitized copy of a published payload, result is nil on success
+func (p *PayloadLogger) Record(topic string, payload []byte, result error) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.checkExpiryLocked()
+	if !p.enabled || p.file == nil {
+		return
+	}
+	if p.topicFilter != "" && !strings.Contains(topic, p.topicFilter) {
+		return
+	}
+
+	status := "ok"
+	if result != nil {
+		status = "error: " + result.Error()
+	}
+
+	body := sanitizePayload(payload)
+	truncated := ""
+	if len(body) > p.maxPayloadBytes {
+		truncated = fmt.Sprintf(" (truncated, %d bytes)", len(body))
+		body = body[:p.maxPayloadBytes]
+	}
+
+	line := fmt.Sprintf("%s topic=%s size=%d status=%s%s payload=%s\n",
+		time.Now().Format(time.RFC3339), topic, len(payload), status, truncated, body)
+
+	if p.size+int64(len(line)) > payloadLogMaxFileSize {
+		if err := p.rotateLocked(); err != nil {
+			p.disableLocked()
+			return
+		}
+	}
+
+	n, err := p.file.WriteString(line)
+	p.size += int64(n)
+	if err != nil {
+		p.disableLocked()
+		return
+	}
+	p.logged++
+}
+
+func (p *PayloadLogger) checkExpiryLocked() {
+	if p.enabled && time.Now().After(p.expiresAt) {
+		p.disableLocked()
+	}
+}
+
+func (p *PayloadLogger) disableLocked() {
+	p.enabled = false
+	if p.file != nil {
+		p.file.Close()
+		p.file = nil
+	}
+}
+
+func (p *PayloadLogger) openLocked() error {
+	if p.file != nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p.path), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(p.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open payload log: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat payload log: %w", err)
+	}
+
+	p.file = file
+	p.size = info.Size()
+	return nil
+}
+
+// rotateLocked renames the file to a timestamped backup and keeps the newest backups
+func (p *PayloadLogger) rotateLocked() error {
+	if p.file != nil {
+		p.file.Close()
+		p.file = nil
+	}
+
+	backup := fmt.Sprintf("%s.%s", p.path, time.Now().Format("20060102-150405"))
+	if err := os.Rename(p.path, backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if backups, err := filepath.Glob(p.path + ".*"); err == nil && len(backups) > payloadLogMaxBackups {
+		sort.Strings(backups)
+		for _, old := range backups[:len(backups)-payloadLogMaxBackups] {
+			os.Remove(old)
+		}
+	}
+
+	return p.openLocked()
+}
+
+// sanitizePayload redacts secret fields of JSON payloads. Non-JSON payloads are only
+// described by their size since they cannot be inspected safely.
+func sanitizePayload(payload []byte) string {
+	var value interface{}
+	if err := json.Unmarshal(payload, &value); err != nil {
+		return fmt.Sprintf("<non-JSON payload, %d bytes>", len(payload))
+	}
+
+	sanitized, err := json.Marshal(redactSecrets(value))
+	if err != nil {
+		return fmt.Sprintf("<unserializable payload, %d bytes>", len(payload))
+	}
+	return string(sanitized)
+}
+
+func redactSecrets(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if isSecretField(key) {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactSecrets(child)
+			}
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactSecrets(child)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func isSecretField(name string) bool {
+	name = strings.ToLower(name)
+	for _, marker := range secretFieldMarkers {
+		if strings.Contains(name, marker) {
+			return true
+		}
+	}
+	return false
+}
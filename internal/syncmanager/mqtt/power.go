@@ -0,0 +1,35 @@
+package mqtt
+
+import "jarvist/internal/syncmanager/power"
+
+// Suspend disconnects cleanly before the machine sleeps, messages are stored locally and
+// no reconnect is attempted while asleep
+func (t *Sender) Suspend() {
+	t.mutex.Lock()
+	running := t.running
+	t.mutex.Unlock()
+
+	if running {
+		t.client.Disconnect()
+	}
+}
+
+// Resume reconnects after sleep. A connection that still looks alive is dropped, the
+// broker has usually closed it while the machine slept.
+func (t *Sender) Resume(window power.SleepWindow) {
+	t.mutex.Lock()
+	running := t.running
+	t.mutex.Unlock()
+
+	if !running {
+		return
+	}
+
+	if t.client.IsConnected() {
+		t.client.ForceReconnect("resumed from sleep")
+	} else if err := t.client.Connect(); err != nil {
+		t.logger.Warning(ComponentMQTT, "Failed to reconnect after resume: %v", err)
+	}
+
+	go t.checkPendingMessages()
+}
@@ -0,0 +1,21 @@
+package mqtt
+
+import "jarvist/internal/common/redact"
+
+// reloadRedaction applies the redaction rules of the config and the redaction_rules
+// setting. Invalid stored rules keep the rules in use and are logged once.
+func (t *Sender) reloadRedaction() {
+	err := redact.Load(t.cfg.Redaction, t.db)
+	if err == nil {
+		if t.redactionError != "" {
+			t.logger.Info(ComponentPolicy, "Redaction rules loaded")
+		}
+		t.redactionError = ""
+		return
+	}
+
+	if err.Error() != t.redactionError {
+		t.logger.Warning(ComponentPolicy, "Failed to load redaction rules, keeping the rules in use: %v", err)
+	}
+	t.redactionError = err.Error()
+}
@@ -0,0 +1,298 @@
+package mqtt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"jarvist/internal/common/identity"
+	"jarvist/internal/common/models"
+	"jarvist/pkg/logger"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Site registration, a new site announces itself to the backend and waits for an answer
+// before publishing data, so the backend never receives counts of a site it does not know
+const (
+	RegistrationKey = "site_registration"
+	// RegistrationCommand is the command the backend answers on, <topic>/command/register
+	RegistrationCommand = "register"
+	registrationTopic   = "/register"
+
+	// registrationRetry is how long the sender waits for an answer before registering again
+	registrationRetry = 2 * time.Minute
+)
+
+// Registration statuses
+const (
+	RegistrationPending  = "pending"  // Not sent yet for the current configuration
+	RegistrationWaiting  = "waiting"  // Sent, waiting for the answer of the backend
+	RegistrationAccepted = "accepted" // Accepted by the backend, data is published
+	RegistrationRejected = "rejected" // Rejected by the backend, data stays local until the configuration changes
+	RegistrationSkipped  = "skipped"  // Support let the site publish without an answer
+	RegistrationLegacy   = "legacy"   // The site published before registration existed
+)
+
+// RegistrationState is the registration of the site for its current configuration
+type RegistrationState struct {
+	Status      string          `json:"status"`
+	Fingerprint string          `json:"fingerprint"` // Broker and identity the registration is for
+	RequestID   string          `json:"request_id,omitempty"`
+	Attempts    int             `json:"attempts"`
+	SentAt      *time.Time      `json:"sent_at,omitempty"`
+	AnsweredAt  *time.Time      `json:"answered_at,omitempty"`
+	Reason      string          `json:"reason,omitempty"`
+	Config      json.RawMessage `json:"config,omitempty"` // Configuration sent by the backend with its answer
+}
+
+// Registered reports whether data may be published
+func (r RegistrationState) Registered() bool {
+	switch r.Status {
+	case RegistrationAccepted, RegistrationSkipped, RegistrationLegacy:
+		return true
+	}
+	return false
+}
+
+// registrationAnswer is the payload of the register command sent by the backend
+type registrationAnswer struct {
+	RequestID string          `json:"request_id"`
+	Status    string          `json:"status"` // accepted or rejected
+	Reason    string          `json:"reason"`
+	Config    json.RawMessage `json:"config"`
+}
+
+// loadRegistration reads the saved registration. A site without one that already published
+// data is registered as legacy, it was set up before registration existed.
+func loadRegistration(db *gorm.DB, log *logger.Logger) RegistrationState {
+	var state RegistrationState
+	if value := getSetting(db, RegistrationKey); value != "" {
+		if err := json.Unmarshal([]byte(value), &state); err == nil {
+			return state
+		}
+		log.Warning(ComponentSender, "Ignoring invalid site registration, registering again")
+		return RegistrationState{Status: RegistrationPending}
+	}
+
+	var sent int64
+	db.Model(&models.PendingMessage{}).Where("sent = ?", true).Count(&sent)
+	if sent == 0 {
+		db.Model(&models.DataRecord{}).Where("sync_status = ?", true).Count(&sent)
+	}
+	if sent > 0 {
+		return RegistrationState{Status: RegistrationLegacy}
+	}
+	return RegistrationState{Status: RegistrationPending}
+}
+
+// GetRegistration returns the registration of the site
+func (t *Sender) GetRegistration() RegistrationState {
+	t.pauseMutex.Lock()
+	defer t.pauseMutex.Unlock()
+	return t.registration
+}
+
+// RetryRegistration registers the site again right away, for example after the backend
+// rejected it and the site was set up on the backend
+func (t *Sender) RetryRegistration() (RegistrationState, error) {
+	t.registrationMutex.Lock()
+	defer t.registrationMutex.Unlock()
+
+	state := t.GetRegistration()
+	state.Status = RegistrationPending
+	state.Reason = ""
+	if err := t.setRegistration(state); err != nil {
+		return RegistrationState{}, err
+	}
+
+	if !t.client.IsConnected() {
+		return state, nil
+	}
+	return t.sendRegistration()
+}
+
+// SkipRegistration lets the site publish without an answer of the backend, for backends
+// that do not handle registrations
+func (t *Sender) SkipRegistration(actor string) (RegistrationState, error) {
+	t.registrationMutex.Lock()
+	state := t.GetRegistration()
+	now := time.Now()
+	state.Status = RegistrationSkipped
+	state.AnsweredAt = &now
+	state.Reason = "skipped by " + actor
+	err := t.setRegistration(state)
+	t.registrationMutex.Unlock()
+	if err != nil {
+		return RegistrationState{}, err
+	}
+
+	t.logger.Warning(ComponentSender, "Site registration skipped by %s, publishing without an answer of the backend", actor)
+	t.RefreshUploadPolicy()
+	return state, nil
+}
+
+// ensureRegistered sends the registration when the site is not registered for its current
+// configuration, or the last one was not answered in time
+func (t *Sender) ensureRegistered() {
+	t.registrationMutex.Lock()
+	defer t.registrationMutex.Unlock()
+
+	state := t.GetRegistration()
+	fingerprint := t.registrationFingerprint()
+
+	switch {
+	case state.Fingerprint == "" && state.Status == RegistrationLegacy:
+		// Site lama cukup dicatat konfigurasinya, tidak perlu registrasi
+		state.Fingerprint = fingerprint
+		if err := t.setRegistration(state); err != nil {
+			t.logger.Warning(ComponentSender, "Failed to save site registration: %v", err)
+		}
+		return
+	case state.Fingerprint != fingerprint:
+		if state.Registered() {
+			t.logger.Info(ComponentSender, "Broker or identity changed, registering the site again before publishing data")
+		}
+		state = RegistrationState{Status: RegistrationPending, Fingerprint: fingerprint}
+		if err := t.setRegistration(state); err != nil {
+			t.logger.Warning(ComponentSender, "Failed to save site registration: %v", err)
+		}
+	case state.Registered(), state.Status == RegistrationRejected:
+		return
+	case state.Status == RegistrationWaiting && state.SentAt != nil && time.Since(*state.SentAt) < registrationRetry:
+		return
+	}
+
+	if !t.client.IsConnected() {
+		return
+	}
+	if _, err := t.sendRegistration(); err != nil {
+		t.logger.Warning(ComponentSender, "Failed to send site registration: %v", err)
+	}
+}
+
+// sendRegistration publishes the site metadata, cameras and version. Callers hold the
+// registration mutex.
+func (t *Sender) sendRegistration() (RegistrationState, error) {
+	state := t.GetRegistration()
+	state.Fingerprint = t.registrationFingerprint()
+	state.RequestID = fmt.Sprintf("reg_%d", time.Now().UnixNano())
+
+	site := identity.Get(t.db)
+	var cameras []struct {
+		ID         uint   `json:"id"`
+		UUID       string `json:"uuid"`
+		Name       string `json:"name"`
+		LocationID string `json:"location_id"`
+	}
+	if err := t.db.Model(&models.Camera{}).Where("deleted_at IS NULL").Order("id").Find(&cameras).Error; err != nil {
+		return RegistrationState{}, fmt.Errorf("failed to read cameras: %w", err)
+	}
+
+	version := ""
+	if t.cfg.BaseConfig != nil {
+		version = t.cfg.BaseConfig.BuildInfo.ProductVersion
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"type":           "registration",
+		"request_id":     state.RequestID,
+		"client_id":      t.cfg.MQTT.ClientID,
+		"timestamp":      time.Now().Format(time.RFC3339),
+		"version":        version,
+		"environment":    t.client.Environment(),
+		"site":           site,
+		"site_name":      getSetting(t.db, "site_name"),
+		"cameras":        cameras,
+		"response_topic": t.client.topicFor(t.cfg.MQTT.Topic + "/command/" + RegistrationCommand),
+	})
+	if err != nil {
+		return RegistrationState{}, fmt.Errorf("failed to serialize registration: %w", err)
+	}
+
+	if err := t.client.Publish(t.cfg.MQTT.Topic+registrationTopic, payload); err != nil {
+		return RegistrationState{}, err
+	}
+
+	now := time.Now()
+	state.Status = RegistrationWaiting
+	state.Attempts++
+	state.SentAt = &now
+	if err := t.setRegistration(state); err != nil {
+		return RegistrationState{}, err
+	}
+
+	t.logger.Info(ComponentSender, "Site registration %s sent with %d cameras, data is published once the backend answers",
+		state.RequestID, len(cameras))
+	return state, nil
+}
+
+// HandleRegistrationAnswer applies the answer of the backend to a registration
+func (t *Sender) HandleRegistrationAnswer(payload []byte) {
+	var answer registrationAnswer
+	if err := json.Unmarshal(payload, &answer); err != nil {
+		t.logger.Warning(ComponentSender, "Ignoring invalid registration answer: %v", err)
+		return
+	}
+	if answer.Status != RegistrationAccepted && answer.Status != RegistrationRejected {
+		t.logger.Warning(ComponentSender, "Ignoring registration answer with status %q, expected %s or %s",
+			answer.Status, RegistrationAccepted, RegistrationRejected)
+		return
+	}
+
+	t.registrationMutex.Lock()
+	state := t.GetRegistration()
+	if answer.RequestID != "" && answer.RequestID != state.RequestID {
+		t.registrationMutex.Unlock()
+		t.logger.Debug(ComponentSender, "Ignoring answer to earlier registration %s", answer.RequestID)
+		return
+	}
+
+	now := time.Now()
+	state.Status = answer.Status
+	state.AnsweredAt = &now
+	state.Reason = answer.Reason
+	if len(answer.Config) > 0 && string(answer.Config) != "null" {
+		state.Config = answer.Config
+	}
+	err := t.setRegistration(state)
+	t.registrationMutex.Unlock()
+	if err != nil {
+		t.logger.Warning(ComponentSender, "Failed to save site registration: %v", err)
+	}
+
+	if state.Status == RegistrationRejected {
+		t.logger.Event(logger.LevelWarn, ComponentSender, logger.EventSiteRegistrationRejected, logger.F("reason", answer.Reason))
+		return
+	}
+
+	t.logger.Event(logger.LevelInfo, ComponentSender, logger.EventSiteRegistered, logger.F("request", state.RequestID))
+	t.RefreshUploadPolicy()
+}
+
+// setRegistration stores the registration and makes the sender follow it
+func (t *Sender) setRegistration(state RegistrationState) error {
+	value, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := saveSetting(t.db, RegistrationKey, string(value)); err != nil {
+		return err
+	}
+
+	t.pauseMutex.Lock()
+	t.registration = state
+	t.pauseMutex.Unlock()
+	return nil
+}
+
+// registrationFingerprint identifies the configuration a registration is for, a site that
+// moves to another broker or identity registers again
+func (t *Sender) registrationFingerprint() string {
+	site := identity.Get(t.db)
+	key := fmt.Sprintf("%s:%d|%s|%s|%s|%s", t.cfg.MQTT.Broker, t.cfg.MQTT.Port, t.cfg.MQTT.Topic,
+		site.TenantID, site.ClientID, site.SiteID)
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
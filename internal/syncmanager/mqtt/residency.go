@@ -0,0 +1,42 @@
+package mqtt
+
+import (
+	"jarvist/internal/common/residency"
+	"jarvist/pkg/logger"
+)
+
+// refreshResidency re-reads the data residency mode switched in the desktop app. In
+// local-only mode the client stays disconnected and new data is not queued at all, so it
+// is not sent later either. Messages queued before the switch wait until cloud mode is
+// back.
+func (t *Sender) refreshResidency() residency.State {
+	state := residency.Refresh(t.db)
+
+	t.pauseMutex.Lock()
+	previous := t.residency
+	t.residency = state
+	t.pauseMutex.Unlock()
+
+	t.client.SetLocalOnly(state.LocalOnly())
+	if state.LocalOnly() == previous.LocalOnly() {
+		return state
+	}
+
+	actor := state.ChangedBy
+	if actor == "" {
+		actor = "unknown"
+	}
+	if state.LocalOnly() {
+		t.logger.Event(logger.LevelWarn, ComponentPolicy, logger.EventLocalOnlyEnabled, logger.F("actor", actor))
+	} else {
+		t.logger.Event(logger.LevelInfo, ComponentPolicy, logger.EventLocalOnlyDisabled, logger.F("actor", actor))
+	}
+	return state
+}
+
+// getResidency returns the data residency mode the sender follows
+func (t *Sender) getResidency() residency.State {
+	t.pauseMutex.Lock()
+	defer t.pauseMutex.Unlock()
+	return t.residency
+}
@@ -0,0 +1,183 @@
+package mqtt
+
+import (
+	"fmt"
+	"jarvist/internal/common/models"
+	"math"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Setting keys of the retry policy for failed publishes, re-read with the upload policy
+const (
+	RetryMaxKey       = "sender_max_retries"
+	RetryBaseDelayKey = "sender_retry_base_ms"
+	RetryMaxDelayKey  = "sender_retry_max_ms"
+)
+
+// Limits for the retry policy
+const (
+	MaxRetriesLimit   = 1000
+	MinRetryDelayMs   = 100
+	MaxRetryDelayMs   = 24 * 60 * 60 * 1000
+	defaultMaxRetries = 10
+)
+
+// RetryPolicy decides when a message that failed to publish is tried again and when it is
+// moved to the dead letter table. Failures while the transport is down are not counted.
+type RetryPolicy struct {
+	MaxRetries  int `json:"max_retries"`   // Failed publishes before the message is dead, 0 retries forever
+	BaseDelayMs int `json:"base_delay_ms"` // Wait after the first failure, doubled after each further one
+	MaxDelayMs  int `json:"max_delay_ms"`  // Longest wait between two attempts
+}
+
+// DefaultRetryPolicy returns the built-in retry policy
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:  defaultMaxRetries,
+		BaseDelayMs: 5000,
+		MaxDelayMs:  15 * 60 * 1000,
+	}
+}
+
+// Validate checks the retry policy against the limits
+func (p RetryPolicy) Validate() error {
+	switch {
+	case p.MaxRetries < 0 || p.MaxRetries > MaxRetriesLimit:
+		return fmt.Errorf("max_retries must be between 0 and %d", MaxRetriesLimit)
+	case p.BaseDelayMs < MinRetryDelayMs || p.BaseDelayMs > MaxRetryDelayMs:
+		return fmt.Errorf("base_delay_ms must be between %d and %d", MinRetryDelayMs, MaxRetryDelayMs)
+	case p.MaxDelayMs < MinRetryDelayMs || p.MaxDelayMs > MaxRetryDelayMs:
+		return fmt.Errorf("max_delay_ms must be between %d and %d", MinRetryDelayMs, MaxRetryDelayMs)
+	}
+	return nil
+}
+
+// Delay returns the wait before the next attempt after attempts failed publishes, growing
+// exponentially up to MaxDelayMs with jitter so failed messages do not retry together. A
+// MaxDelayMs below BaseDelayMs keeps the delay at BaseDelayMs.
+func (p RetryPolicy) Delay(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := float64(p.BaseDelayMs) * math.Pow(2, float64(attempts-1))
+	delay = math.Min(delay, math.Max(float64(p.MaxDelayMs), float64(p.BaseDelayMs)))
+	return nextBackoff(time.Duration(delay) * time.Millisecond)
+}
+
+// LoadRetryPolicy applies the stored overrides to the defaults, invalid overrides are ignored
+func LoadRetryPolicy(db *gorm.DB) RetryPolicy {
+	defaults := DefaultRetryPolicy()
+	if db == nil {
+		return defaults
+	}
+
+	policy := defaults
+	override := func(key string, target *int) {
+		if value, err := strconv.Atoi(getSetting(db, key)); err == nil {
+			*target = value
+		}
+	}
+	override(RetryMaxKey, &policy.MaxRetries)
+	override(RetryBaseDelayKey, &policy.BaseDelayMs)
+	override(RetryMaxDelayKey, &policy.MaxDelayMs)
+
+	if policy.Validate() != nil {
+		return defaults
+	}
+	return policy
+}
+
+// GetRetryPolicy returns the retry policy in use
+func (t *Sender) GetRetryPolicy() RetryPolicy {
+	return t.getRetryPolicy()
+}
+
+// getRetryPolicy returns the retry policy of the current cycle
+func (t *Sender) getRetryPolicy() RetryPolicy {
+	t.tuningMutex.Lock()
+	defer t.tuningMutex.Unlock()
+	return t.retryPolicy
+}
+
+// reloadRetryPolicy picks up retry settings changed in the settings table
+func (t *Sender) reloadRetryPolicy() {
+	policy := LoadRetryPolicy(t.db)
+
+	t.tuningMutex.Lock()
+	changed := policy != t.retryPolicy
+	t.retryPolicy = policy
+	t.tuningMutex.Unlock()
+
+	if changed {
+		t.logger.Info(ComponentSender, "Retry policy applied: %d retries, backoff from %dms to %dms",
+			policy.MaxRetries, policy.BaseDelayMs, policy.MaxDelayMs)
+	}
+}
+
+// retryLater handles a message that failed to publish. While the transport is down the
+// failure is not the message's fault, it is queued again and waits for the connection.
+// Otherwise the failure is counted and the message is loaded again by the pending message
+// check once its backoff has passed, or moved to the dead letter table when it failed too
+// often.
+func (t *Sender) retryLater(msg models.PendingMessage, cause error) {
+	if t.shutdown {
+		return
+	}
+	t.client.Metrics().RecordRetry(msg.Topic)
+
+	if !t.transportConnected() {
+		t.enqueueMessage(msg)
+		return
+	}
+
+	policy := t.getRetryPolicy()
+	result, err := t.messageService.RecordFailure(msg.ID, cause, policy.MaxRetries, policy.Delay)
+	if err != nil {
+		t.logger.Warning(ComponentWorker, "Failed to record the failure of message ID %d, queued again: %v", msg.ID, err)
+		t.enqueueMessage(msg)
+		return
+	}
+
+	if result.DeadLetter != nil {
+		t.logger.Error(ComponentWorker, "Message ID %d failed %d times, moved to dead letter %d: %v",
+			msg.ID, result.Attempts, result.DeadLetter.ID, cause)
+		return
+	}
+
+	delay := time.Until(result.NextAttemptAt)
+	t.logger.Warning(ComponentWorker, "Message ID %d failed %d times, next attempt in %s",
+		msg.ID, result.Attempts, delay.Round(time.Second))
+	time.AfterFunc(delay, t.checkPendingMessages)
+}
+
+// RequeueDeadLetter moves a dead letter back to the pending messages and sends it
+func (t *Sender) RequeueDeadLetter(id uint, actor string) (uint, error) {
+	messageID, err := t.messageService.RequeueDeadLetter(id)
+	if err != nil {
+		return 0, err
+	}
+
+	t.logger.Info(ComponentSender, "Dead letter %d requeued by %s as message ID %d", id, actor, messageID)
+	var msg models.PendingMessage
+	if err := t.db.Select("topic").First(&msg, messageID).Error; err == nil {
+		t.Dispatch(messageID, msg.Topic)
+	}
+	return messageID, nil
+}
+
+// RequeueDeadLetters moves all dead letters back to the pending messages
+func (t *Sender) RequeueDeadLetters(actor string) (int, error) {
+	count, err := t.messageService.RequeueDeadLetters()
+	if err != nil {
+		return 0, err
+	}
+
+	if count > 0 {
+		t.logger.Info(ComponentSender, "%d dead letters requeued by %s", count, actor)
+		go t.checkPendingMessages()
+	}
+	return count, nil
+}
@@ -5,10 +5,19 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"jarvist/internal/common/bandwidth"
+	"jarvist/internal/common/identity"
+	"jarvist/internal/common/licensestate"
 	"jarvist/internal/common/models"
+	"jarvist/internal/common/residency"
 	"jarvist/internal/syncmanager/config"
+	"jarvist/internal/syncmanager/httpupload"
+	"jarvist/internal/syncmanager/maintmode"
+	"jarvist/internal/syncmanager/network"
 	"jarvist/internal/syncmanager/services/message"
 	"jarvist/internal/syncmanager/services/stats"
+	"jarvist/internal/syncmanager/tracing"
+	"jarvist/internal/syncmanager/uptime"
 	"jarvist/pkg/logger"
 	"strings"
 	"sync"
@@ -25,6 +34,7 @@ const (
 	ComponentWorker    = "msg-worker"
 	ComponentMonitor   = "conn-monitor"
 	ComponentHeartbeat = "heartbeat"
+	ComponentPolicy    = "upload-policy"
 )
 
 // Constants for connection management
@@ -32,6 +42,7 @@ const (
 	ConnectionTimeout   = 10 // seconds
 	ConnectionCheckFreq = 2  // seconds
 	HeartbeatInterval   = 3  // seconds
+	PolicyCheckFreq     = 30 // seconds
 )
 
 type Sender struct {
@@ -54,11 +65,35 @@ type Sender struct {
 	processingMutex   sync.Mutex
 	checkingPending   bool
 	pendingMutex      sync.Mutex
+	parentCtx         context.Context
 	ctx               context.Context
 	cancel            context.CancelFunc
 	messageService    *message.MessageService
 	statsService      *stats.StatsService
 	workerSemaphore   chan struct{}
+	tuning            Tuning
+	retryPolicy       RetryPolicy
+	tuningMutex       sync.Mutex
+	workerStops       []chan struct{}
+	networkMonitor    *network.Monitor
+	pauseState        bandwidth.PauseState
+	maintenance       maintmode.State
+	license           licensestate.State
+	residency         residency.State
+	registration      RegistrationState
+	registrationMutex sync.Mutex
+	credentialsState  CredentialsState
+	credentialsMutex  sync.Mutex
+	pauseMutex        sync.Mutex
+	drain             drainState
+	redactionError    string          // Last error loading the redaction rules, logged once
+	sending           map[string]uint // Idempotency keys being sent, to the message ID
+	sendingMutex      sync.Mutex
+	duplicatesSkipped uint64
+	uploader          *httpupload.Uploader
+	transport         string // Transport of the stored messages, empty until started
+	transportError    string // Last invalid transport settings, logged once
+	transportMutex    sync.Mutex
 }
 
 // NewSender creates a new MQTT sender
@@ -69,6 +104,8 @@ func NewSender(ctx context.Context, cfg *config.Config, db *gorm.DB, messageServ
 		return nil, fmt.Errorf("failed to create MQTT client: %v", err)
 	}
 
+	tuning := LoadTuning(db, DefaultTuning(cfg))
+
 	ctxWithCancel, cancel := context.WithCancel(ctx)
 	// Create sender
 	t := &Sender{
@@ -78,24 +115,44 @@ func NewSender(ctx context.Context, cfg *config.Config, db *gorm.DB, messageServ
 		logger:          logger,
 		running:         false,
 		shutdown:        false,
-		messageQueue:    make(chan models.PendingMessage, 1000), // Large buffer for better performance
-		pendingQueue:    make([]models.PendingMessage, 0),       // Initially empty backing queue
+		messageQueue:    make(chan models.PendingMessage, tuning.QueueCapacity), // Large buffer for better performance
+		pendingQueue:    make([]models.PendingMessage, 0),                       // Initially empty backing queue
 		quitChan:        make(chan struct{}),
 		mutex:           sync.Mutex{},
 		queueMutex:      sync.Mutex{},
 		startTime:       time.Now(),
 		checkingPending: false,
 		pendingMutex:    sync.Mutex{},
+		parentCtx:       ctx,
 		ctx:             ctxWithCancel,
 		cancel:          cancel,
 		messageService:  messageService,
 		statsService:    statsService,
-		workerSemaphore: make(chan struct{}, 5),
+		workerSemaphore: make(chan struct{}, maxSenderWorkers),
+		tuning:          tuning,
+		retryPolicy:     LoadRetryPolicy(db),
+		registration:    loadRegistration(db, logger),
+		uploader:        httpupload.New(cfg.MQTT.ClientID, logger),
+		sending:         make(map[string]uint),
 	}
 
+	client.SetSession(LoadSessionSettings(db, t.sessionDataDir()))
+	client.HandleCommand(RegistrationCommand, t.HandleRegistrationAnswer)
+	t.reloadCredentials()
+
 	return t, nil
 }
 
+// PayloadLog returns the sanitized payload logger of the MQTT client
+func (t *Sender) PayloadLog() *PayloadLogger {
+	return t.client.PayloadLog()
+}
+
+// SetNetworkMonitor attaches the connectivity monitor used to annotate stored messages
+func (t *Sender) SetNetworkMonitor(monitor *network.Monitor) {
+	t.networkMonitor = monitor
+}
+
 // Start starts the sender service
 func (t *Sender) Start() error {
 	t.mutex.Lock()
@@ -110,22 +167,44 @@ func (t *Sender) Start() error {
 	t.running = true
 	t.shutdown = false
 
+	// Stop menutup quitChan dan membatalkan context, buat ulang saat di-restart
+	select {
+	case <-t.quitChan:
+		t.quitChan = make(chan struct{})
+	default:
+	}
+	if t.ctx.Err() != nil {
+		t.ctx, t.cancel = context.WithCancel(t.parentCtx)
+	}
+
 	// Reset processing status for any messages that were being processed when the system stopped
 	if err := t.messageService.ResetProcessingStatus(); err != nil {
 		t.logger.Warning(ComponentSender, "Failed to reset processing status: %v", err)
 	}
 
-	// Connect to MQTT broker
-	if err := t.client.Connect(); err != nil {
+	t.RefreshUploadPolicy()
+	t.reloadRedaction()
+	t.reloadTransport()
+
+	if t.cfg.MQTT.Staging {
+		t.logger.Warning(ComponentSender, "Staging mode is on, publishing under %s%s and tagging payloads as %s",
+			t.cfg.MQTT.Topic, StagingSuffix, EnvironmentStaging)
+	}
+
+	// Connect to MQTT broker, local-only mode stays offline
+	if t.getResidency().LocalOnly() {
+		t.logger.Warning(ComponentSender, "Local-only mode is on, not connecting to the MQTT broker")
+	} else if err := t.client.Connect(); err != nil {
 		t.logger.Warning(ComponentMQTT, "Failed to connect to MQTT broker: %v", err)
 	}
 
 	// Start worker goroutines
+	t.scaleWorkers(t.getTuning().Workers)
 	t.wg.Add(4)
-	go t.messageWorker()
 	go t.connectionMonitor()
 	go t.heartbeatWorker()
 	go t.pendingQueueWorker()
+	go t.policyWorker()
 
 	// Check for pending messages after startup
 	go t.checkPendingMessages()
@@ -134,6 +213,11 @@ func (t *Sender) Start() error {
 	return nil
 }
 
+// Name returns the component name used in startup logs
+func (t *Sender) Name() string {
+	return "MQTT sender"
+}
+
 // Stop stops the sender service
 func (t *Sender) Stop() error {
 	t.mutex.Lock()
@@ -173,11 +257,15 @@ func (t *Sender) Stop() error {
 
 	totalPending := int(pendingCount) + pendingQueueLen + len(t.messageQueue)
 
-	if totalPending > 0 && t.client.IsConnected() {
+	if totalPending > 0 && t.transportConnected() {
 		t.logger.Info(ComponentSender, "Attempting to send %d pending messages before shutdown", totalPending)
 
 		// Make sure we process our in-memory queue
-		t.drainQueues()
+		drainCtx, drainCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if _, err := t.DrainQueues(drainCtx); err != nil {
+			t.logger.Warning(ComponentSender, "Failed to drain queues during shutdown: %v", err)
+		}
+		drainCancel()
 
 		// And check database
 		t.checkPendingMessages()
@@ -189,6 +277,12 @@ func (t *Sender) Stop() error {
 	// Disconnect MQTT client
 	t.client.Disconnect()
 
+	// Batch HTTPS yang masih terbuka dikirim sebelum berhenti, Start memilih transport lagi
+	t.uploader.Disconnect()
+	t.transportMutex.Lock()
+	t.transport = ""
+	t.transportMutex.Unlock()
+
 	// Wait for workers to finish with timeout
 	done := make(chan struct{})
 	go func() {
@@ -204,79 +298,97 @@ func (t *Sender) Stop() error {
 		t.logger.Warning(ComponentSender, "Timed out waiting for workers to finish")
 	}
 
+	// Workers have exited, let the next Start spawn a fresh pool
+	t.tuningMutex.Lock()
+	t.workerStops = nil
+	t.tuningMutex.Unlock()
+
 	t.logger.Info(ComponentSender, "MQTT sender service stopped")
 	return nil
 }
 
-// drainQueues attempts to drain in-memory queues during shutdown
-func (t *Sender) drainQueues() {
-	t.logger.Info(ComponentSender, "Draining in-memory queues")
+// HealthCheck reports whether the sender is running. A broker that is not reachable is
+// not an error, messages are stored locally until the connection is back.
+func (t *Sender) HealthCheck() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
 
-	// First drain the channel-based queue
-	timeout := time.After(10 * time.Second)
-	drained := 0
+	if !t.running {
+		return errors.New("MQTT sender is not running")
+	}
+	if t.workerCount() == 0 {
+		return errors.New("MQTT sender has no message workers")
+	}
+	return nil
+}
 
-	// Loop until queue is empty or timeout
-drainLoop:
-	for {
-		select {
-		case msg, ok := <-t.messageQueue:
-			if !ok {
-				break drainLoop
-			}
+// SendData sends data to the MQTT broker
+func (t *Sender) SendData(topic string, data interface{}) (uint, error) {
+	if topic == "" {
+		topic = t.cfg.MQTT.Topic
+	}
 
-			if t.client.IsConnected() {
-				if err := t.client.Publish(msg.Topic, []byte(msg.Payload)); err == nil {
-					t.messageService.MarkMessageSent(msg.ID)
-					drained++
-				}
-			}
-		case <-timeout:
-			t.logger.Warning(ComponentSender, "Queue drain timed out")
-			break drainLoop
-		default:
-			// Channel is empty
-			break drainLoop
-		}
+	messageID, err := t.StoreData(t.db, topic, data)
+	if err != nil {
+		return 0, err
 	}
 
-	// Then drain the slice-based pendingQueue
-	t.queueMutex.Lock()
-	pendingQueueCopy := t.pendingQueue
-	t.pendingQueue = nil // Clear the queue
-	t.queueMutex.Unlock()
+	t.Dispatch(messageID, topic)
+	return messageID, nil
+}
 
-	for _, msg := range pendingQueueCopy {
-		if t.client.IsConnected() {
-			if err := t.client.Publish(msg.Topic, []byte(msg.Payload)); err == nil {
-				t.messageService.MarkMessageSent(msg.ID)
-				drained++
-			}
-		}
+// PublishNow publishes data right away and waits for the broker, without storing it or
+// following the upload pause. Only meant for one-off checks such as the installation
+// verification, which publishes to a test topic.
+func (t *Sender) PublishNow(topic string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to serialize payload: %w", err)
 	}
-
-	t.logger.Info(ComponentSender, "Drained %d messages from in-memory queues", drained)
+	return t.client.Publish(topic, payload)
 }
 
-// SendData sends data to the MQTT broker
-func (t *Sender) SendData(topic string, data interface{}) (uint, error) {
+// StoreData stores a message using the given transaction without queueing it.
+// Call Dispatch with the returned ID once the transaction is committed. In local-only
+// mode nothing is stored and the ID is 0, the data stays in the local records only.
+func (t *Sender) StoreData(tx *gorm.DB, topic string, data interface{}) (uint, error) {
 	if t.shutdown {
 		return 0, errors.New("sender is shutting down")
 	}
+	if t.getResidency().LocalOnly() {
+		return 0, nil
+	}
 
-	startTime := time.Now()
-
-	// Use provided topic or default if empty
-	if topic == "" {
-		topic = t.cfg.MQTT.Topic
+	networkState := network.StateUnknown
+	if t.networkMonitor != nil {
+		networkState = t.networkMonitor.State()
 	}
 
-	// Store message in database
-	messageID, err := t.messageService.StoreMessage(topic, data, t.client.IsConnected())
+	messageID, err := t.messageService.StoreMessageTx(tx, topic, data, t.client.IsConnected(), networkState)
 	if err != nil {
 		return 0, fmt.Errorf("failed to store message: %v", err)
 	}
 
+	return messageID, nil
+}
+
+// Dispatch queues a stored message for sending. Messages that are never dispatched are
+// picked up by the pending message check.
+func (t *Sender) Dispatch(messageID uint, topic string) {
+	// Tidak ada pesan yang disimpan dalam mode local-only
+	if messageID == 0 {
+		return
+	}
+	startTime := time.Now()
+
+	// Non-critical messages stay queued in the database while uploads are paused
+	if t.shouldDefer(topic) {
+		if err := t.messageService.MarkDeferred(messageID); err != nil {
+			t.logger.Warning(ComponentSender, "Failed to defer message ID %d: %v", messageID, err)
+		}
+		return
+	}
+
 	// Immediately mark it as processing and get it for sending
 	message, err := t.messageService.GetAndMarkProcessing(messageID)
 	if err != nil {
@@ -292,8 +404,6 @@ func (t *Sender) SendData(topic string, data interface{}) (uint, error) {
 	t.processingTime += elapsed
 	atomic.AddUint64(&t.messagesProcessed, 1)
 	t.processingMutex.Unlock()
-
-	return messageID, nil
 }
 
 // enqueueMessage adds a message to the send queue with unlimited capacity
@@ -372,8 +482,8 @@ func (t *Sender) pendingQueueWorker() {
 	}
 }
 
-// messageWorker processes the message queue
-func (t *Sender) messageWorker() {
+// messageWorker processes the message queue until the sender stops or the worker is scaled down
+func (t *Sender) messageWorker(stop chan struct{}) {
 	defer t.wg.Done()
 	t.logger.Info(ComponentWorker, "Message worker started")
 
@@ -382,6 +492,9 @@ func (t *Sender) messageWorker() {
 		case <-t.ctx.Done():
 			t.logger.Info(ComponentWorker, "Message worker stopping due to context cancellation")
 			return
+		case <-stop:
+			t.logger.Info(ComponentWorker, "Message worker stopping, worker pool scaled down")
+			return
 		case msg := <-t.messageQueue:
 			if t.shutdown {
 				return
@@ -399,18 +512,27 @@ func (t *Sender) messageWorker() {
 				continue
 			}
 
-			if t.client.IsConnected() {
-				if err := t.client.Publish(msg.Topic, []byte(msg.Payload)); err != nil {
-					t.logger.Error(ComponentWorker, "Failed to publish message ID %d: %v", msg.ID, err)
+			if t.shouldDefer(msg.Topic) {
+				if err := t.messageService.MarkDeferred(msg.ID); err != nil {
+					t.logger.Warning(ComponentWorker, "Failed to defer message ID %d: %v", msg.ID, err)
+				}
+				<-t.workerSemaphore
+				continue
+			}
+
+			if t.transportConnected() {
+				skipped, err := t.sendOnce(msg)
+				switch {
+				case errors.Is(err, errSendingDuplicate):
+					// Pesan dengan isi yang sama sedang dikirim worker lain, dicek lagi nanti
 					if !t.shutdown {
 						t.enqueueMessage(msg)
 					}
-				} else {
-					if err := t.messageService.MarkMessageSent(msg.ID); err != nil {
-						t.logger.Error(ComponentWorker, "Failed to mark message as sent: %v", err)
-					} else {
-						t.logger.Info(ComponentWorker, "Message ID %d sent successfully", msg.ID)
-					}
+				case err != nil:
+					t.logger.Error(ComponentWorker, "Failed to publish message ID %d: %v", msg.ID, err)
+					t.retryLater(msg, err)
+				case !skipped:
+					t.logger.Info(ComponentWorker, "Message ID %d sent successfully", msg.ID)
 				}
 			} else {
 				if !t.shutdown {
@@ -419,25 +541,49 @@ func (t *Sender) messageWorker() {
 			}
 
 			<-t.workerSemaphore
-			time.Sleep(50 * time.Millisecond)
+			time.Sleep(t.getTuning().PublishDelay())
 		}
 	}
 }
 
+// publishMessage publishes a queued message. The publish span joins the trace of the file
+// the message carries when it was queued by this run of the service.
+func (t *Sender) publishMessage(msg models.PendingMessage) error {
+	transport := t.getTransport()
+	system := httpupload.TransportMQTT
+	if transport != Transport(t.client) {
+		system = httpupload.TransportHTTPS
+	}
+
+	key := tracing.MessageKey(msg.ID)
+	_, span := tracing.StartKind(tracing.Recall(context.Background(), key), tracing.KindProducer, system+".publish",
+		tracing.String("messaging.system", system),
+		tracing.String("messaging.destination.name", msg.Topic),
+		tracing.Int("messaging.message.id", int64(msg.ID)),
+		tracing.Int("messaging.message.body.size", int64(len(msg.Payload))))
+	defer span.End()
+
+	if err := t.deliver(transport, msg.Topic, []byte(msg.Payload)); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	tracing.Forget(key)
+	return nil
+}
+
 // connectionMonitor monitors the connection status
 func (t *Sender) connectionMonitor() {
 	defer t.wg.Done()
 	t.logger.Info(ComponentMonitor, "Connection monitor started")
 
 	healthCheckTicker := time.NewTicker(ConnectionCheckFreq * time.Second)
-	statusLogTicker := time.NewTicker(60 * time.Second)  // Log status every minute
 	queueCheckTicker := time.NewTicker(10 * time.Second) // Check queue status regularly
 	defer healthCheckTicker.Stop()
-	defer statusLogTicker.Stop()
 	defer queueCheckTicker.Stop()
 
 	consecutiveFails := 0
 	wasConnected := false // Track connection transitions
+	wasUploading := false // Track HTTPS upload transitions
 
 	for {
 		select {
@@ -447,6 +593,8 @@ func (t *Sender) connectionMonitor() {
 
 			// Detect connection established
 			if isConnected && !wasConnected {
+				t.markSessionSeen()
+				go t.ensureRegistered()
 				t.logger.Info(ComponentMonitor, "Connection established - checking pending messages")
 				// Trigger check for pending messages on connection restore
 				go t.checkPendingMessages()
@@ -467,11 +615,11 @@ func (t *Sender) connectionMonitor() {
 						t.logger.Warning(ComponentMonitor, "No activity for %f seconds (timeout: %d)", elapsed, ConnectionTimeout)
 						t.logger.Warning(ComponentMonitor, "Manual detection: broker may be down without triggering disconnect callback")
 
-						// Force manual disconnect
-						t.client.Disconnect()
+						// Reconnect through the client backoff instead of a clean disconnect
+						t.client.ForceReconnect("no activity from broker")
 					}
 				}
-			} else if t.running && !t.shutdown {
+			} else if t.running && !t.shutdown && !t.getResidency().LocalOnly() {
 				// Increment failure counter
 				consecutiveFails++
 
@@ -481,47 +629,19 @@ func (t *Sender) connectionMonitor() {
 					t.logger.Info(ComponentMonitor, "Connection check failed (attempt %d) - reconnection being handled by client", consecutiveFails)
 				}
 
-				// After 10 consecutive failures, attempt to "reset" the connection
-				if consecutiveFails == 10 {
-					t.logger.Warning(ComponentMonitor, "10 consecutive connection failures - forcing client reconnect")
-					t.client.Disconnect() // Clean disconnect first
-					time.Sleep(1 * time.Second)
-					t.client.Connect() // Attempt fresh reconnect
-				}
+				// The client backs off on its own, only restart it if no attempt is pending
+				t.client.EnsureConnecting()
 			}
 
 			// Update connection state
 			wasConnected = isConnected
 
-		case <-statusLogTicker.C:
-			if t.running && !t.shutdown {
-				pendingCount, err := t.messageService.CountPendingMessages()
-				if err != nil {
-					t.logger.Warning(ComponentMonitor, "Failed to count pending messages: %v", err)
-					pendingCount = 0
-				}
-
-				t.queueMutex.Lock()
-				pendingQueueLen := len(t.pendingQueue)
-				t.queueMutex.Unlock()
-
-				t.logger.Info(ComponentMonitor, "Status update: Connected=%v, Running=%v, DB pending=%d, Queue size=%d/%d, Backing queue=%d",
-					t.client.IsConnected(),
-					t.running,
-					pendingCount,
-					len(t.messageQueue), cap(t.messageQueue),
-					pendingQueueLen)
-
-				if pendingCount > 0 && t.client.IsConnected() {
-					needsCheck, err := t.messageService.HasOldPendingMessages(5 * time.Minute)
-					if err != nil {
-						t.logger.Warning(ComponentMonitor, "Failed to check for old pending messages: %v", err)
-					} else if needsCheck {
-						t.logger.Info(ComponentMonitor, "Found old pending messages, triggering check")
-						go t.checkPendingMessages()
-					}
-				}
+			// The HTTPS upload comes back after its backoff, stored messages are sent again
+			uploading := t.getTransport() != Transport(t.client) && t.transportConnected()
+			if uploading && !wasUploading && t.running && !t.shutdown {
+				go t.checkPendingMessages()
 			}
+			wasUploading = uploading
 
 		case <-queueCheckTicker.C:
 			// Check queue sizes periodically
@@ -571,10 +691,24 @@ func (t *Sender) heartbeatWorker() {
 func (t *Sender) sendHeartbeat() {
 	// Generate heartbeat data
 	heartbeatData := map[string]interface{}{
-		"type":      "heartbeat",
-		"client_id": t.cfg.MQTT.ClientID,
-		"timestamp": time.Now().Format(time.RFC3339),
-		"random_id": fmt.Sprintf("hb_%d", time.Now().UnixNano()%10000),
+		"type":           "heartbeat",
+		"client_id":      t.cfg.MQTT.ClientID,
+		"timestamp":      time.Now().Format(time.RFC3339),
+		"random_id":      fmt.Sprintf("hb_%d", time.Now().UnixNano()%10000),
+		"started_at":     uptime.StartedAt().Format(time.RFC3339),
+		"uptime_seconds": int64(uptime.Uptime().Seconds()),
+	}
+	if tags := identity.GetTags(t.db); len(tags) > 0 {
+		heartbeatData["tags"] = tags
+	}
+	if maintenance := t.getMaintenance(); maintenance.Active {
+		heartbeatData["maintenance"] = maintenance
+	}
+	if license := t.getLicense(); license.Degraded {
+		heartbeatData["license_degraded"] = license
+	}
+	if registration := t.GetRegistration(); !registration.Registered() {
+		heartbeatData["registration"] = registration.Status
 	}
 
 	payload, err := json.Marshal(heartbeatData)
@@ -592,6 +726,100 @@ func (t *Sender) sendHeartbeat() {
 	}
 }
 
+// RefreshUploadPolicy re-reads the upload pause settings and releases deferred
+// messages when uploads are resumed
+func (t *Sender) RefreshUploadPolicy() bandwidth.PauseState {
+	state := bandwidth.GetPauseState(t.db)
+	license := t.refreshLicense()
+	localOnly := t.refreshResidency().LocalOnly()
+
+	t.pauseMutex.Lock()
+	wasPaused := t.pauseState.Paused
+	t.pauseState = state
+	maintenance := t.maintenance.Active
+	registered := t.registration.Registered()
+	t.pauseMutex.Unlock()
+
+	if state.Paused && !wasPaused {
+		t.logger.Info(ComponentPolicy, "Non-critical uploads paused (reason: %s)", state.Reason)
+	}
+
+	// Selama maintenance, lisensi kedaluwarsa, mode local-only atau site belum terdaftar semua
+	// pesan tetap ditunda, dilepas saat kondisi itu berakhir
+	if !state.Paused && !maintenance && !license.Degraded && !localOnly && registered {
+		released, err := t.messageService.ReleaseDeferred()
+		if err != nil {
+			t.logger.Warning(ComponentPolicy, "Failed to release deferred messages: %v", err)
+		} else if released > 0 && !t.shutdown {
+			t.logger.Info(ComponentPolicy, "Non-critical uploads resumed, sending %d deferred messages", released)
+			go t.checkPendingMessages()
+		}
+	}
+
+	return state
+}
+
+// UpdateUploadPolicy changes the pause mode, metered flag or daily cap; nil values are left unchanged
+func (t *Sender) UpdateUploadPolicy(mode *string, metered *bool, dailyCapMB *int) (bandwidth.PauseState, error) {
+	if mode != nil {
+		if err := bandwidth.SetPauseMode(t.db, *mode); err != nil {
+			return bandwidth.PauseState{}, err
+		}
+	}
+	if metered != nil {
+		if err := bandwidth.SetMetered(t.db, *metered); err != nil {
+			return bandwidth.PauseState{}, fmt.Errorf("failed to save metered flag: %w", err)
+		}
+	}
+	if dailyCapMB != nil {
+		if err := bandwidth.SetDailyCap(t.db, *dailyCapMB); err != nil {
+			return bandwidth.PauseState{}, err
+		}
+	}
+
+	return t.RefreshUploadPolicy(), nil
+}
+
+// GetUploadPauseState returns the cached upload pause state
+func (t *Sender) GetUploadPauseState() bandwidth.PauseState {
+	t.pauseMutex.Lock()
+	defer t.pauseMutex.Unlock()
+	return t.pauseState
+}
+
+func (t *Sender) shouldDefer(topic string) bool {
+	t.pauseMutex.Lock()
+	defer t.pauseMutex.Unlock()
+
+	if t.maintenance.Active || t.license.Degraded || t.residency.LocalOnly() || !t.registration.Registered() {
+		return true
+	}
+	return bandwidth.IsNonCritical(topic) && t.pauseState.Paused
+}
+
+// policyWorker periodically re-evaluates the metered/daily cap settings
+func (t *Sender) policyWorker() {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(PolicyCheckFreq * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.RefreshUploadPolicy()
+			t.reloadTuning()
+			t.reloadRetryPolicy()
+			t.reloadRedaction()
+			t.reloadCredentials()
+			t.reloadTransport()
+			t.ensureRegistered()
+		case <-t.quitChan:
+			return
+		}
+	}
+}
+
 // checkPendingMessages checks for and sends pending messages
 func (t *Sender) checkPendingMessages() {
 	// Use a mutex to ensure only one instance runs at a time
@@ -610,19 +838,19 @@ func (t *Sender) checkPendingMessages() {
 		t.pendingMutex.Unlock()
 	}()
 
-	if t.shutdown {
+	if t.shutdown || t.getResidency().LocalOnly() {
 		return
 	}
 
 	// Try to connect if not connected
-	if !t.client.IsConnected() {
+	if t.getTransport() == Transport(t.client) && !t.client.IsConnected() {
 		t.logger.Debug(ComponentWorker, "Not connected when checking pending, trying to connect")
 		t.client.Connect()
 		time.Sleep(1 * time.Second)
 	}
 
-	// Get total pending count
-	pendingTotal, err := t.messageService.CountPendingMessages()
+	// Get total pending count, excluding messages deferred by the upload pause
+	pendingTotal, err := t.messageService.CountSendableMessages()
 	if err != nil {
 		t.logger.Error(ComponentWorker, "Failed to count pending messages: %v", err)
 		return
@@ -637,10 +865,11 @@ func (t *Sender) checkPendingMessages() {
 
 	// Process in batches
 	processed := 0
-	batchSize := 20
+	tuning := t.getTuning()
+	batchSize := tuning.BatchSize
 
 	for processed < int(pendingTotal) && !t.shutdown {
-		if !t.client.IsConnected() {
+		if !t.transportConnected() {
 			t.logger.Warning(ComponentWorker, "Lost connection while processing pending messages")
 			break
 		}
@@ -682,7 +911,7 @@ func (t *Sender) checkPendingMessages() {
 		}
 
 		// Brief pause between batches
-		time.Sleep(500 * time.Millisecond)
+		time.Sleep(tuning.BatchPause())
 	}
 
 	t.logger.Info(ComponentWorker, "Processed %d of %d pending messages", processed, pendingTotal)
@@ -742,11 +971,14 @@ func (t *Sender) GetStatus() map[string]interface{} {
 		"connected":           t.client.IsConnected(),
 		"broker":              t.cfg.MQTT.Broker,
 		"port":                t.cfg.MQTT.Port,
+		"broker_failover":     t.client.BrokerState(),
 		"client_id":           t.cfg.MQTT.ClientID,
 		"last_active":         t.client.GetLastActivity().Format(time.RFC3339),
-		"uptime":              time.Since(t.startTime).Truncate(time.Second).String(),
-		"uptime_seconds":      int(time.Since(t.startTime).Seconds()),
+		"uptime":              uptime.Uptime().Truncate(time.Second).String(),
+		"uptime_seconds":      int(uptime.Uptime().Seconds()),
+		"started_at":          uptime.StartedAt().Format(time.RFC3339),
 		"messages_processed":  processed,
+		"duplicates_skipped":  atomic.LoadUint64(&t.duplicatesSkipped),
 		"processing_rate":     fmt.Sprintf("%.2f msg/s", rate),
 		"avg_processing_time": formatDuration(avgTime),
 		"avg_processing_ms":   avgTime.Milliseconds(),
@@ -755,6 +987,24 @@ func (t *Sender) GetStatus() map[string]interface{} {
 		"channel_capacity":    cap(t.messageQueue),
 		"backing_queue_len":   pendingQueueLen,
 		"total_queued":        len(t.messageQueue) + pendingQueueLen,
+		"upload_pause":        t.GetUploadPauseState(),
+		"maintenance":         t.getMaintenance(),
+		"environment":         t.client.Environment(),
+		"license":             t.getLicense(),
+		"data_residency":      t.getResidency(),
+		"registration":        t.GetRegistration(),
+		"credentials":         t.GetCredentials(),
+		"publish_metrics":     t.GetPublishMetrics(),
+		"reconnect":           t.client.ReconnectState(),
+		"transport":           t.GetTransportStatus(),
+		"session":             t.client.Session(),
+		"tuning":              t.getTuning(),
+		"retry_policy":        t.getRetryPolicy(),
+		"workers":             t.workerCount(),
+	}
+
+	if deadLetters, err := t.messageService.CountDeadLetters(); err == nil {
+		status["dead_letters"] = deadLetters
 	}
 
 	dbStats, err := t.statsService.GetDatabaseStats()
@@ -767,6 +1017,173 @@ func (t *Sender) GetStatus() map[string]interface{} {
 	return status
 }
 
+// GetTuning returns the sender tuning in use
+func (t *Sender) GetTuning() Tuning {
+	return t.getTuning()
+}
+
+// UpdateTuning stores new tuning overrides and applies them right away. The queue
+// capacity only changes on the next restart.
+func (t *Sender) UpdateTuning(tuning Tuning) (Tuning, error) {
+	if err := SaveTuning(t.db, tuning); err != nil {
+		return t.getTuning(), err
+	}
+	t.applyTuning(tuning)
+	return t.getTuning(), nil
+}
+
+// reloadTuning picks up tuning overrides changed in the settings table
+func (t *Sender) reloadTuning() {
+	tuning := LoadTuning(t.db, DefaultTuning(t.cfg))
+	if tuning != t.getTuning() {
+		t.applyTuning(tuning)
+	}
+}
+
+func (t *Sender) applyTuning(tuning Tuning) {
+	t.tuningMutex.Lock()
+	previous := t.tuning
+	t.tuning = tuning
+	t.tuningMutex.Unlock()
+
+	t.logger.Info(ComponentSender, "Sender tuning applied: %d workers, %dms publish delay, batch %d every %dms",
+		tuning.Workers, tuning.PublishDelayMs, tuning.BatchSize, tuning.BatchPauseMs)
+	if tuning.QueueCapacity != cap(t.messageQueue) && tuning.QueueCapacity != previous.QueueCapacity {
+		t.logger.Info(ComponentSender, "Queue capacity %d takes effect after restart", tuning.QueueCapacity)
+	}
+
+	t.mutex.Lock()
+	running := t.running && !t.shutdown
+	t.mutex.Unlock()
+
+	if running {
+		t.scaleWorkers(tuning.Workers)
+	}
+}
+
+// scaleWorkers starts or stops message workers until n are running
+func (t *Sender) scaleWorkers(n int) {
+	t.tuningMutex.Lock()
+	defer t.tuningMutex.Unlock()
+
+	for len(t.workerStops) < n {
+		stop := make(chan struct{})
+		t.workerStops = append(t.workerStops, stop)
+		t.wg.Add(1)
+		go t.messageWorker(stop)
+	}
+	for len(t.workerStops) > n {
+		last := len(t.workerStops) - 1
+		close(t.workerStops[last])
+		t.workerStops = t.workerStops[:last]
+	}
+}
+
+func (t *Sender) workerCount() int {
+	t.tuningMutex.Lock()
+	defer t.tuningMutex.Unlock()
+	return len(t.workerStops)
+}
+
+func (t *Sender) getTuning() Tuning {
+	t.tuningMutex.Lock()
+	defer t.tuningMutex.Unlock()
+	return t.tuning
+}
+
+// GetSession returns the MQTT session settings in use
+func (t *Sender) GetSession() SessionSettings {
+	return t.client.Session()
+}
+
+// UpdateSession changes the clean session flag or session expiry, nil values are left
+// unchanged. The client reconnects so the broker applies the new session mode.
+func (t *Sender) UpdateSession(cleanSession *bool, expirySecs *int) (SessionSettings, error) {
+	current := t.client.Session()
+
+	clean := current.CleanSession
+	if cleanSession != nil {
+		clean = *cleanSession
+	}
+	expiry := current.ExpirySecs
+	if expirySecs != nil {
+		expiry = *expirySecs
+	}
+
+	if err := SaveSessionSettings(t.db, clean, expiry); err != nil {
+		return current, err
+	}
+
+	session := LoadSessionSettings(t.db, t.sessionDataDir())
+	// The stored session is in use right now, expiry only applies after downtime
+	session.ForceClean = false
+	t.client.SetSession(session)
+
+	t.logger.Info(ComponentSender, "MQTT session updated (clean: %v, expiry: %ds)", session.CleanSession, session.ExpirySecs)
+
+	t.mutex.Lock()
+	running := t.running && !t.shutdown
+	t.mutex.Unlock()
+
+	if running && (session.CleanSession != current.CleanSession || session.ClientID != current.ClientID) {
+		t.client.Disconnect()
+		if err := t.client.Connect(); err != nil {
+			t.logger.Warning(ComponentSender, "Reconnect after session change failed: %v", err)
+		}
+	}
+
+	return session, nil
+}
+
+// markSessionSeen records the time a persistent session was last in use
+func (t *Sender) markSessionSeen() {
+	if !t.client.Session().Persistent() {
+		return
+	}
+	if err := MarkSessionSeen(t.db); err != nil {
+		t.logger.Debug(ComponentSender, "Failed to record session time: %v", err)
+	}
+}
+
+func (t *Sender) sessionDataDir() string {
+	if t.cfg.BaseConfig == nil {
+		return ""
+	}
+	return t.cfg.BaseConfig.DataDir
+}
+
+// GetPublishMetrics returns publish attempts, successes, retries and failure causes per topic prefix
+func (t *Sender) GetPublishMetrics() map[string]interface{} {
+	metrics := t.client.Metrics()
+	return map[string]interface{}{
+		"since":  metrics.Since().Format(time.RFC3339),
+		"topics": metrics.Snapshot(),
+	}
+}
+
+// PublishMetricsSnapshot returns the publish counters per topic prefix
+func (t *Sender) PublishMetricsSnapshot() []TopicMetrics {
+	return t.client.Metrics().Snapshot()
+}
+
+// LastPublishedAt returns the last successful publish since the metrics were reset, zero
+// if nothing was published
+func (t *Sender) LastPublishedAt() time.Time {
+	var last time.Time
+	for _, topic := range t.client.Metrics().Snapshot() {
+		if topic.LastSuccessAt != nil && topic.LastSuccessAt.After(last) {
+			last = *topic.LastSuccessAt
+		}
+	}
+	return last
+}
+
+// ResetPublishMetrics clears the publish counters
+func (t *Sender) ResetPublishMetrics() {
+	t.client.Metrics().Reset()
+	t.logger.Info(ComponentSender, "Publish metrics reset")
+}
+
 // Refresh forces a reconnect and pending message check
 func (t *Sender) Refresh() error {
 	t.mutex.Lock()
@@ -0,0 +1,137 @@
+package mqtt
+
+import (
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"gorm.io/gorm"
+)
+
+// Setting keys for MQTT session persistence
+const (
+	CleanSessionKey  = "mqtt_clean_session"
+	SessionExpiryKey = "mqtt_session_expiry"
+	SessionClientKey = "mqtt_client_id"
+	SessionSeenKey   = "mqtt_session_seen_at"
+)
+
+// SessionStoreDir is the directory below the data directory holding in-flight messages
+const SessionStoreDir = "mqtt-session"
+
+const maxSessionExpiry = 7 * 24 * 60 * 60 // seconds
+
+// SessionSettings controls whether the broker keeps the client session between connections
+type SessionSettings struct {
+	CleanSession bool   `json:"clean_session"`
+	ExpirySecs   int    `json:"session_expiry_seconds"` // 0 keeps the session until the next clean connect
+	ClientID     string `json:"client_id"`
+	StoreDir     string `json:"store_dir,omitempty"`
+	// ForceClean is set when the stored session is older than the expiry, the next
+	// connection then starts clean once
+	ForceClean bool `json:"force_clean"`
+}
+
+// Persistent reports whether the session and in-flight messages survive reconnects and restarts
+func (s SessionSettings) Persistent() bool {
+	return !s.CleanSession && !s.ForceClean
+}
+
+// LoadSessionSettings reads the session settings, clean sessions are the default. A stable
+// client ID is created on first use since the broker identifies sessions by client ID.
+func LoadSessionSettings(db *gorm.DB, dataDir string) SessionSettings {
+	settings := SessionSettings{CleanSession: true}
+	if db == nil {
+		return settings
+	}
+
+	if clean, err := strconv.ParseBool(getSetting(db, CleanSessionKey)); err == nil {
+		settings.CleanSession = clean
+	}
+	settings.ExpirySecs, _ = strconv.Atoi(getSetting(db, SessionExpiryKey))
+
+	if settings.CleanSession {
+		return settings
+	}
+
+	settings.ClientID = getSetting(db, SessionClientKey)
+	if settings.ClientID == "" {
+		settings.ClientID = fmt.Sprintf("jarvist-%08x", rand.Uint32())
+		if err := saveSetting(db, SessionClientKey, settings.ClientID); err != nil {
+			settings.ClientID = ""
+		}
+	}
+
+	if dataDir != "" {
+		settings.StoreDir = filepath.Join(dataDir, SessionStoreDir)
+	}
+
+	if settings.ExpirySecs > 0 {
+		if seen, err := time.Parse(time.RFC3339, getSetting(db, SessionSeenKey)); err == nil &&
+			time.Since(seen) > time.Duration(settings.ExpirySecs)*time.Second {
+			settings.ForceClean = true
+		}
+	}
+
+	return settings
+}
+
+// SaveSessionSettings stores the clean session flag and session expiry
+func SaveSessionSettings(db *gorm.DB, cleanSession bool, expirySecs int) error {
+	if expirySecs < 0 || expirySecs > maxSessionExpiry {
+		return fmt.Errorf("session expiry must be between 0 and %d seconds", maxSessionExpiry)
+	}
+
+	if err := saveSetting(db, CleanSessionKey, strconv.FormatBool(cleanSession)); err != nil {
+		return err
+	}
+	return saveSetting(db, SessionExpiryKey, strconv.Itoa(expirySecs))
+}
+
+// MarkSessionSeen records that the broker session was in use, for the expiry check on restart
+func MarkSessionSeen(db *gorm.DB) error {
+	return saveSetting(db, SessionSeenKey, time.Now().Format(time.RFC3339))
+}
+
+// applySession configures the connection options for the session settings
+func applySession(opts *mqtt.ClientOptions, session SessionSettings) error {
+	opts.SetCleanSession(!session.Persistent())
+	if !session.Persistent() || session.StoreDir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(session.StoreDir, 0755); err != nil {
+		return fmt.Errorf("failed to create session store: %w", err)
+	}
+	opts.SetStore(mqtt.NewFileStore(session.StoreDir))
+	opts.SetResumeSubs(true)
+	return nil
+}
+
+func getSetting(db *gorm.DB, key string) string {
+	var setting models.Setting
+	if err := db.Where("key = ?", key).First(&setting).Error; err != nil {
+		return ""
+	}
+	return setting.Value
+}
+
+func saveSetting(db *gorm.DB, key, value string) error {
+	var setting models.Setting
+	result := db.Where("key = ?", key).First(&setting)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return db.Create(&models.Setting{Key: key, Value: value}).Error
+	} else if result.Error != nil {
+		return result.Error
+	}
+
+	setting.Value = value
+	return db.Save(&setting).Error
+}
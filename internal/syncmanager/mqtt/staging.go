@@ -0,0 +1,58 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Staging namespace, test devices publish under <first level>-staging so their data never
+// reaches the production analytics while the full pipeline is exercised
+const (
+	StagingSuffix      = "-staging"
+	EnvironmentStaging = "staging"
+)
+
+// Environment returns the environment the client publishes to
+func (c *Client) Environment() string {
+	if c.cfg.MQTT.Staging {
+		return EnvironmentStaging
+	}
+	return "production"
+}
+
+// topicFor moves a topic into the staging namespace when staging is on, the suffix is added
+// to the first topic level so data, heartbeats, logs and commands all move together
+func (c *Client) topicFor(topic string) string {
+	if !c.cfg.MQTT.Staging {
+		return topic
+	}
+
+	first, rest, found := strings.Cut(topic, "/")
+	if strings.HasSuffix(first, StagingSuffix) {
+		return topic
+	}
+	if !found {
+		return first + StagingSuffix
+	}
+	return first + StagingSuffix + "/" + rest
+}
+
+// tagPayload adds "environment":"staging" to JSON object payloads when staging is on. The
+// other fields are kept as raw JSON so numbers and nested values are not reformatted.
+func (c *Client) tagPayload(payload []byte) []byte {
+	if !c.cfg.MQTT.Staging {
+		return payload
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil || fields == nil {
+		return payload
+	}
+	fields["environment"] = json.RawMessage(`"` + EnvironmentStaging + `"`)
+
+	tagged, err := json.Marshal(fields)
+	if err != nil {
+		return payload
+	}
+	return tagged
+}
@@ -0,0 +1,66 @@
+package mqtt
+
+import (
+	"jarvist/internal/common/licensestate"
+	"jarvist/internal/common/residency"
+)
+
+// StatusTopic is appended to the base topic for the fleet status document
+const StatusTopic = "/status"
+
+// StatusSnapshot is the sender state reported in the fleet status document
+type StatusSnapshot struct {
+	Connected    bool
+	Environment  string
+	UploadPaused bool
+	Maintenance  bool
+	License      licensestate.State
+	Residency    residency.State
+	Registration string
+	// Pending counts the stored messages not sent yet, Deferred the part held back by the
+	// upload pause, maintenance or license and InMemory the messages in the send queues
+	Pending  int64
+	Deferred int64
+	InMemory int
+}
+
+// StatusSnapshot returns the cached sender state and the queue depths
+func (t *Sender) StatusSnapshot() (StatusSnapshot, error) {
+	t.queueMutex.Lock()
+	inMemory := len(t.pendingQueue) + len(t.messageQueue)
+	t.queueMutex.Unlock()
+
+	snapshot := StatusSnapshot{
+		Connected:    t.client.IsConnected(),
+		Environment:  t.client.Environment(),
+		UploadPaused: t.GetUploadPauseState().Paused,
+		Maintenance:  t.getMaintenance().Active,
+		License:      t.getLicense(),
+		Residency:    t.getResidency(),
+		Registration: t.GetRegistration().Status,
+		InMemory:     inMemory,
+	}
+
+	pending, err := t.messageService.CountPendingMessages()
+	if err != nil {
+		return snapshot, err
+	}
+	sendable, err := t.messageService.CountSendableMessages()
+	if err != nil {
+		return snapshot, err
+	}
+	snapshot.Pending = pending
+	snapshot.Deferred = pending - sendable
+	return snapshot, nil
+}
+
+// PublishStatus publishes the fleet status document, retained so a dashboard sees the
+// last document of every device as soon as it subscribes
+func (t *Sender) PublishStatus(payload []byte) error {
+	return t.client.PublishRetained(t.cfg.MQTT.Topic+StatusTopic, payload)
+}
+
+// Connected reports whether the broker connection is up
+func (t *Sender) Connected() bool {
+	return t.client.IsConnected()
+}
@@ -0,0 +1,125 @@
+package mqtt
+
+import (
+	"jarvist/internal/common/redact"
+	"jarvist/internal/syncmanager/httpupload"
+)
+
+// Transport delivers the stored messages of the sender. MQTT is the default, sites that
+// block MQTT outbound upload them over HTTPS instead. The pending queue, retries and
+// deferral are the same for every transport. Heartbeats, the status document, the site
+// registration and commands always use the MQTT connection.
+type Transport interface {
+	Name() string
+	Connect() error
+	Disconnect()
+	IsConnected() bool
+	Publish(topic string, payload []byte) error
+}
+
+// TransportStatus describes the transport of the stored messages
+type TransportStatus struct {
+	Transport string             `json:"transport"`
+	HTTPS     *httpupload.Status `json:"https,omitempty"`
+	Error     string             `json:"error,omitempty"` // Invalid settings keep the transport in use
+}
+
+// Name returns the transport name used in logs
+func (c *Client) Name() string {
+	return "MQTT"
+}
+
+// getTransport returns the transport the stored messages are sent with
+func (t *Sender) getTransport() Transport {
+	t.transportMutex.Lock()
+	defer t.transportMutex.Unlock()
+
+	if t.transport == httpupload.TransportHTTPS {
+		return t.uploader
+	}
+	return t.client
+}
+
+// transportConnected reports whether stored messages can be sent now
+func (t *Sender) transportConnected() bool {
+	return t.getTransport().IsConnected()
+}
+
+// reloadTransport applies the transport settings, switching between MQTT and HTTPS.
+// Invalid settings keep the transport in use and are logged once.
+func (t *Sender) reloadTransport() {
+	settings, err := httpupload.LoadSettings(t.db)
+
+	t.transportMutex.Lock()
+	if err != nil {
+		if err.Error() != t.transportError {
+			t.logger.Warning(ComponentPolicy, "Invalid transport settings, keeping %s: %v", t.transport, err)
+		}
+		t.transportError = err.Error()
+		t.transportMutex.Unlock()
+		return
+	}
+	t.transportError = ""
+	previous := t.transport
+	t.transport = settings.Transport
+	t.transportMutex.Unlock()
+
+	t.uploader.Configure(settings)
+	if previous == settings.Transport {
+		return
+	}
+
+	switch settings.Transport {
+	case httpupload.TransportHTTPS:
+		t.logger.Warning(ComponentSender, "Uploading stored messages over HTTPS to %s, heartbeats, status and commands still use MQTT",
+			settings.URL)
+		if !t.GetRegistration().Registered() {
+			t.logger.Warning(ComponentSender, "Site registration needs MQTT, skip it on sites that only reach the HTTPS endpoint")
+		}
+		if err := t.uploader.Connect(); err != nil {
+			t.logger.Warning(ComponentSender, "Failed to start HTTPS upload: %v", err)
+		}
+	default:
+		if previous != "" {
+			t.logger.Info(ComponentSender, "Sending stored messages over MQTT again")
+			t.uploader.Disconnect()
+		}
+	}
+
+	// Pesan yang tertunda dikirim lagi lewat transport yang baru
+	if previous != "" && t.running && !t.shutdown {
+		go t.checkPendingMessages()
+	}
+}
+
+// deliver publishes a stored message over the transport. The MQTT client moves staging
+// topics, redacts and counts its publishes itself, other transports get the same here.
+func (t *Sender) deliver(transport Transport, topic string, payload []byte) error {
+	if transport == Transport(t.client) {
+		return t.client.Publish(topic, payload)
+	}
+
+	topic = t.client.topicFor(topic)
+	payload = redact.JSON(t.client.tagPayload(payload))
+
+	err := transport.Publish(topic, payload)
+	t.client.PayloadLog().Record(topic, payload, err)
+	t.client.Metrics().Record(topic, err)
+	return err
+}
+
+// GetTransportStatus returns the transport of the stored messages
+func (t *Sender) GetTransportStatus() TransportStatus {
+	t.transportMutex.Lock()
+	status := TransportStatus{Transport: t.transport, Error: t.transportError}
+	t.transportMutex.Unlock()
+
+	if status.Transport == "" {
+		status.Transport = httpupload.TransportMQTT
+	}
+	if status.Transport == httpupload.TransportHTTPS {
+		uploadStatus := t.uploader.GetStatus()
+		status.HTTPS = &uploadStatus
+	}
+	return status
+}
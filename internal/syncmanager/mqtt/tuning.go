@@ -0,0 +1,142 @@
+package mqtt
+
+import (
+	"fmt"
+	"jarvist/internal/syncmanager/config"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Setting keys overriding the sender tuning from config, re-read with the upload policy
+const (
+	TuningWorkersKey       = "sender_workers"
+	TuningPublishDelayKey  = "sender_publish_delay_ms"
+	TuningBatchSizeKey     = "sender_batch_size"
+	TuningBatchPauseKey    = "sender_batch_pause_ms"
+	TuningQueueCapacityKey = "sender_queue_capacity"
+)
+
+// Limits for the sender tuning
+const (
+	maxSenderWorkers     = 10
+	maxPublishDelayMs    = 5000
+	maxBatchSize         = 500
+	maxBatchPauseMs      = 10000
+	minQueueCapacity     = 100
+	maxQueueCapacity     = 100000
+	defaultSenderWorkers = 1
+)
+
+// Tuning controls sender concurrency and pacing
+type Tuning struct {
+	Workers        int `json:"workers"`          // Message workers publishing in parallel
+	PublishDelayMs int `json:"publish_delay_ms"` // Pause of a worker after each publish
+	BatchSize      int `json:"batch_size"`       // Pending messages loaded from the database per batch
+	BatchPauseMs   int `json:"batch_pause_ms"`   // Pause between pending message batches
+	QueueCapacity  int `json:"queue_capacity"`   // In-memory queue size, applied on restart
+}
+
+// DefaultTuning returns the tuning from the service config, falling back to the built-in values
+func DefaultTuning(cfg *config.Config) Tuning {
+	tuning := Tuning{
+		Workers:        defaultSenderWorkers,
+		PublishDelayMs: 50,
+		BatchSize:      20,
+		BatchPauseMs:   500,
+		QueueCapacity:  1000,
+	}
+	if cfg == nil {
+		return tuning
+	}
+
+	if cfg.Advanced.MaxQueueWorkers > 0 {
+		tuning.Workers = cfg.Advanced.MaxQueueWorkers
+	}
+	if cfg.Sender.PublishDelayMs > 0 {
+		tuning.PublishDelayMs = cfg.Sender.PublishDelayMs
+	}
+	if cfg.Sender.BatchSize > 0 {
+		tuning.BatchSize = cfg.Sender.BatchSize
+	}
+	if cfg.Sender.BatchPauseMs > 0 {
+		tuning.BatchPauseMs = cfg.Sender.BatchPauseMs
+	}
+	if cfg.Sender.QueueCapacity > 0 {
+		tuning.QueueCapacity = cfg.Sender.QueueCapacity
+	}
+	return tuning
+}
+
+// Validate checks the tuning against the limits
+func (t Tuning) Validate() error {
+	switch {
+	case t.Workers < 1 || t.Workers > maxSenderWorkers:
+		return fmt.Errorf("workers must be between 1 and %d", maxSenderWorkers)
+	case t.PublishDelayMs < 0 || t.PublishDelayMs > maxPublishDelayMs:
+		return fmt.Errorf("publish_delay_ms must be between 0 and %d", maxPublishDelayMs)
+	case t.BatchSize < 1 || t.BatchSize > maxBatchSize:
+		return fmt.Errorf("batch_size must be between 1 and %d", maxBatchSize)
+	case t.BatchPauseMs < 0 || t.BatchPauseMs > maxBatchPauseMs:
+		return fmt.Errorf("batch_pause_ms must be between 0 and %d", maxBatchPauseMs)
+	case t.QueueCapacity < minQueueCapacity || t.QueueCapacity > maxQueueCapacity:
+		return fmt.Errorf("queue_capacity must be between %d and %d", minQueueCapacity, maxQueueCapacity)
+	}
+	return nil
+}
+
+// PublishDelay returns the pause after each publish
+func (t Tuning) PublishDelay() time.Duration {
+	return time.Duration(t.PublishDelayMs) * time.Millisecond
+}
+
+// BatchPause returns the pause between pending message batches
+func (t Tuning) BatchPause() time.Duration {
+	return time.Duration(t.BatchPauseMs) * time.Millisecond
+}
+
+// LoadTuning applies the stored overrides to defaults, invalid overrides are ignored
+func LoadTuning(db *gorm.DB, defaults Tuning) Tuning {
+	if db == nil {
+		return defaults
+	}
+
+	tuning := defaults
+	override := func(key string, target *int) {
+		if value, err := strconv.Atoi(getSetting(db, key)); err == nil {
+			*target = value
+		}
+	}
+	override(TuningWorkersKey, &tuning.Workers)
+	override(TuningPublishDelayKey, &tuning.PublishDelayMs)
+	override(TuningBatchSizeKey, &tuning.BatchSize)
+	override(TuningBatchPauseKey, &tuning.BatchPauseMs)
+	override(TuningQueueCapacityKey, &tuning.QueueCapacity)
+
+	if tuning.Validate() != nil {
+		return defaults
+	}
+	return tuning
+}
+
+// SaveTuning validates and stores the tuning overrides
+func SaveTuning(db *gorm.DB, tuning Tuning) error {
+	if err := tuning.Validate(); err != nil {
+		return err
+	}
+
+	values := map[string]int{
+		TuningWorkersKey:       tuning.Workers,
+		TuningPublishDelayKey:  tuning.PublishDelayMs,
+		TuningBatchSizeKey:     tuning.BatchSize,
+		TuningBatchPauseKey:    tuning.BatchPauseMs,
+		TuningQueueCapacityKey: tuning.QueueCapacity,
+	}
+	for key, value := range values {
+		if err := saveSetting(db, key, strconv.Itoa(value)); err != nil {
+			return fmt.Errorf("failed to save %s: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+package network
+
+import (
+	"context"
+	"jarvist/internal/syncmanager/config"
+	"jarvist/internal/syncmanager/power"
+	"jarvist/pkg/logger"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const ComponentNetwork = "network"
+
+// Connectivity states, from worst to best
+const (
+	StateUnknown       = "unknown"
+	StateOffline       = "offline"
+	StateLANOnly       = "lan_only"
+	StateCaptivePortal = "captive_portal"
+	StateInternet      = "internet"
+)
+
+const (
+	CheckInterval = 30 * time.Second
+	probeTimeout  = 5 * time.Second
+	maxHistory    = 50
+
+	// probeURL returns an empty 204 response; anything else means a captive portal intercepted it
+	probeURL  = "http://connectivitycheck.gstatic.com/generate_204"
+	probeHost = "connectivitycheck.gstatic.com"
+)
+
+// Status is the latest connectivity snapshot
+type Status struct {
+	State           string    `json:"state"`
+	LANUp           bool      `json:"lan_up"`
+	DNSOk           bool      `json:"dns_ok"`
+	HTTPOk          bool      `json:"http_ok"`
+	BrokerReachable bool      `json:"broker_reachable"`
+	LastChecked     time.Time `json:"last_checked"`
+	StateSince      time.Time `json:"state_since"`
+}
+
+// Transition records a change of connectivity state
+type Transition struct {
+	From            string    `json:"from"`
+	To              string    `json:"to"`
+	BrokerReachable bool      `json:"broker_reachable"`
+	At              time.Time `json:"at"`
+}
+
+// Monitor periodically probes LAN, DNS, HTTP and broker reachability
+type Monitor struct {
+	cfg    *config.Config
+	logger *logger.Logger
+	client *http.Client
+
+	mu       sync.RWMutex
+	status   Status
+	history  []Transition
+	quitChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewMonitor creates a connectivity monitor for the configured broker
+func NewMonitor(cfg *config.Config, logger *logger.Logger) *Monitor {
+	return &Monitor{
+		cfg:    cfg,
+		logger: logger,
+		client: &http.Client{
+			Timeout: probeTimeout,
+			// Captive portals answer with a redirect to their login page
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		status: Status{
+			State:      StateUnknown,
+			StateSince: time.Now(),
+		},
+	}
+}
+
+// Name returns the component name used in startup logs
+func (m *Monitor) Name() string {
+	return "Network monitor"
+}
+
+// Start runs an initial check and starts the periodic watcher
+func (m *Monitor) Start() error {
+	m.mu.Lock()
+	if m.quitChan != nil {
+		m.mu.Unlock()
+		return nil
+	}
+	m.quitChan = make(chan struct{})
+	quitChan := m.quitChan
+	m.mu.Unlock()
+
+	m.Check()
+
+	m.wg.Add(1)
+	go m.watch(quitChan)
+
+	m.logger.Info(ComponentNetwork, "Network monitor started")
+	return nil
+}
+
+// Stop stops the periodic watcher
+func (m *Monitor) Stop() error {
+	m.mu.Lock()
+	if m.quitChan == nil {
+		m.mu.Unlock()
+		return nil
+	}
+	close(m.quitChan)
+	m.quitChan = nil
+	m.mu.Unlock()
+
+	m.wg.Wait()
+	m.logger.Info(ComponentNetwork, "Network monitor stopped")
+	return nil
+}
+
+// State returns the current connectivity state
+func (m *Monitor) State() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status.State
+}
+
+// GetStatus returns the latest connectivity snapshot
+func (m *Monitor) GetStatus() Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status
+}
+
+// GetHistory returns the recorded state transitions, newest last
+func (m *Monitor) GetHistory() []Transition {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	history := make([]Transition, len(m.history))
+	copy(history, m.history)
+	return history
+}
+
+// Check probes connectivity immediately and updates the state
+func (m *Monitor) Check() Status {
+	lanUp := HasLANInterface()
+
+	dnsOk, httpOk, captive := false, false, false
+	if lanUp {
+		dnsOk = m.probeDNS()
+		if dnsOk {
+			httpOk, captive = m.probeHTTP()
+		}
+	}
+
+	brokerReachable := m.probeBroker()
+
+	state := StateOffline
+	switch {
+	case httpOk:
+		state = StateInternet
+	case captive:
+		state = StateCaptivePortal
+	case lanUp:
+		state = StateLANOnly
+	}
+
+	now := time.Now()
+
+	m.mu.Lock()
+	previous := m.status
+	m.status = Status{
+		State:           state,
+		LANUp:           lanUp,
+		DNSOk:           dnsOk,
+		HTTPOk:          httpOk,
+		BrokerReachable: brokerReachable,
+		LastChecked:     now,
+		StateSince:      previous.StateSince,
+	}
+
+	changed := previous.State != state || previous.BrokerReachable != brokerReachable
+	if changed {
+		m.status.StateSince = now
+		m.history = append(m.history, Transition{
+			From:            previous.State,
+			To:              state,
+			BrokerReachable: brokerReachable,
+			At:              now,
+		})
+		if len(m.history) > maxHistory {
+			m.history = m.history[len(m.history)-maxHistory:]
+		}
+	}
+	status := m.status
+	m.mu.Unlock()
+
+	if changed {
+		m.logger.Info(ComponentNetwork, "Connectivity changed: %s -> %s (broker reachable: %v)",
+			previous.State, state, brokerReachable)
+	}
+
+	return status
+}
+
+func (m *Monitor) watch(quitChan chan struct{}) {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.Check()
+		case <-quitChan:
+			return
+		}
+	}
+}
+
+func (m *Monitor) probeDNS() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, probeHost)
+	return err == nil && len(addrs) > 0
+}
+
+// probeHTTP returns (internet, captivePortal)
+func (m *Monitor) probeHTTP() (bool, bool) {
+	resp, err := m.client.Get(probeURL)
+	if err != nil {
+		m.logger.Debug(ComponentNetwork, "HTTP probe failed: %v", err)
+		return false, false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return true, false
+	}
+	return false, true
+}
+
+// probeBroker reports whether the primary broker or one of the failover brokers is reachable
+func (m *Monitor) probeBroker() bool {
+	for _, broker := range m.cfg.BrokerEndpoints() {
+		conn, err := net.DialTimeout("tcp", broker.Address(), probeTimeout)
+		if err == nil {
+			conn.Close()
+			return true
+		}
+	}
+	return false
+}
+
+// HasLANInterface reports whether a network interface other than loopback is up with an address
+func HasLANInterface() bool {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return false
+	}
+
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err == nil && len(addrs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Suspend is called before the machine sleeps, the state is checked again on resume
+func (m *Monitor) Suspend() {}
+
+// Resume checks connectivity right away instead of waiting for the next interval
+func (m *Monitor) Resume(window power.SleepWindow) {
+	go m.Check()
+}
@@ -0,0 +1,264 @@
+// Package power detects system sleep and resume, so background workers can pause before
+// the machine sleeps and reconnect and rescan after it wakes up.
+package power
+
+import (
+	"jarvist/pkg/logger"
+	"sync"
+	"time"
+)
+
+const ComponentPower = "power"
+
+// Power states
+const (
+	StateRunning   = "running"
+	StateSuspended = "suspended"
+)
+
+// Sources of a detected sleep window
+const (
+	SourceOS    = "os"    // Suspend/resume notification dari Windows
+	SourceClock = "clock" // Lompatan jam dinding tanpa notifikasi
+)
+
+const (
+	// clockInterval is how often the wall clock is compared, a gap of more than
+	// clockInterval+clockTolerance between two ticks counts as sleep
+	clockInterval  = 5 * time.Second
+	clockTolerance = 30 * time.Second
+
+	maxHistory = 50
+)
+
+// SleepWindow is a period in which the machine was asleep and no data was collected
+type SleepWindow struct {
+	SuspendedAt time.Time `json:"suspended_at"`
+	ResumedAt   time.Time `json:"resumed_at"`
+	DurationSec float64   `json:"duration_sec"`
+	Source      string    `json:"source"`
+}
+
+// Listener is notified about sleep and resume. Suspend is called before the machine
+// sleeps when the OS reports it, Resume after every detected sleep window.
+type Listener interface {
+	Suspend()
+	Resume(window SleepWindow)
+}
+
+// Status is the current power state
+type Status struct {
+	State          string       `json:"state"`
+	OSNotification bool         `json:"os_notification"` // Notifikasi OS terdaftar
+	LastSleep      *SleepWindow `json:"last_sleep,omitempty"`
+	SleepCount     int          `json:"sleep_count"`
+}
+
+// Monitor watches for suspend and resume through the OS and a wall clock check
+type Monitor struct {
+	logger *logger.Logger
+
+	mu           sync.Mutex
+	listeners    []Listener
+	state        string
+	suspendedAt  time.Time
+	lastResume   time.Time
+	history      []SleepWindow
+	sleepCount   int
+	osRegistered bool
+	unregister   func()
+	quitChan     chan struct{}
+	wg           sync.WaitGroup
+}
+
+// NewMonitor creates a power monitor
+func NewMonitor(logger *logger.Logger) *Monitor {
+	return &Monitor{
+		logger: logger,
+		state:  StateRunning,
+	}
+}
+
+// Name returns the component name used in startup logs
+func (m *Monitor) Name() string {
+	return "Power monitor"
+}
+
+// Subscribe adds a listener, listeners are called in the order they subscribed
+func (m *Monitor) Subscribe(listener Listener) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, listener)
+}
+
+// Start registers for OS power notifications and starts the clock check
+func (m *Monitor) Start() error {
+	m.mu.Lock()
+	if m.quitChan != nil {
+		m.mu.Unlock()
+		return nil
+	}
+	m.quitChan = make(chan struct{})
+	quitChan := m.quitChan
+	m.mu.Unlock()
+
+	unregister, err := registerNotifications(m.handleSuspend, m.handleResume)
+	m.mu.Lock()
+	m.unregister = unregister
+	m.osRegistered = err == nil && unregister != nil
+	m.mu.Unlock()
+	if err != nil {
+		m.logger.Warning(ComponentPower, "OS power notifications not available, using clock check only: %v", err)
+	}
+
+	m.wg.Add(1)
+	go m.watchClock(quitChan)
+
+	m.logger.Info(ComponentPower, "Power monitor started")
+	return nil
+}
+
+// Stop unregisters the notifications and stops the clock check
+func (m *Monitor) Stop() error {
+	m.mu.Lock()
+	if m.quitChan == nil {
+		m.mu.Unlock()
+		return nil
+	}
+	close(m.quitChan)
+	m.quitChan = nil
+	unregister := m.unregister
+	m.unregister = nil
+	m.osRegistered = false
+	m.mu.Unlock()
+
+	if unregister != nil {
+		unregister()
+	}
+	m.wg.Wait()
+
+	m.logger.Info(ComponentPower, "Power monitor stopped")
+	return nil
+}
+
+// GetStatus returns the current power state
+func (m *Monitor) GetStatus() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := Status{
+		State:          m.state,
+		OSNotification: m.osRegistered,
+		SleepCount:     m.sleepCount,
+	}
+	if len(m.history) > 0 {
+		last := m.history[len(m.history)-1]
+		status.LastSleep = &last
+	}
+	return status
+}
+
+// GetHistory returns the recorded sleep windows, newest last
+func (m *Monitor) GetHistory() []SleepWindow {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	history := make([]SleepWindow, len(m.history))
+	copy(history, m.history)
+	return history
+}
+
+// handleSuspend is called by the OS before the machine sleeps
+func (m *Monitor) handleSuspend() {
+	m.mu.Lock()
+	if m.state == StateSuspended {
+		m.mu.Unlock()
+		return
+	}
+	m.state = StateSuspended
+	// Tanpa monotonic clock agar durasi sleep dihitung dari jam dinding
+	m.suspendedAt = time.Now().Round(0)
+	listeners := append([]Listener(nil), m.listeners...)
+	m.mu.Unlock()
+
+	m.logger.Event(logger.LevelInfo, ComponentPower, logger.EventPowerSuspended)
+
+	// Windows memberi waktu terbatas sebelum sleep, listener harus cepat
+	for _, listener := range listeners {
+		listener.Suspend()
+	}
+}
+
+// handleResume is called by the OS after the machine woke up
+func (m *Monitor) handleResume() {
+	m.mu.Lock()
+	if m.state != StateSuspended {
+		m.mu.Unlock()
+		return
+	}
+	window := SleepWindow{SuspendedAt: m.suspendedAt, ResumedAt: time.Now().Round(0), Source: SourceOS}
+	m.mu.Unlock()
+
+	m.resumed(window)
+}
+
+// watchClock detects sleep the OS did not report by comparing the wall clock between ticks
+func (m *Monitor) watchClock(quitChan chan struct{}) {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(clockInterval)
+	defer ticker.Stop()
+
+	// Round(0) membuang monotonic clock, yang tidak selalu berjalan selama sleep
+	last := time.Now().Round(0)
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now().Round(0)
+			gap := now.Sub(last)
+			previous := last
+			last = now
+
+			if gap <= clockInterval+clockTolerance {
+				continue
+			}
+
+			m.mu.Lock()
+			handled := m.state == StateSuspended || now.Sub(m.lastResume) < clockInterval+clockTolerance
+			m.mu.Unlock()
+			if handled {
+				continue
+			}
+
+			m.resumed(SleepWindow{SuspendedAt: previous, ResumedAt: now, Source: SourceClock})
+		case <-quitChan:
+			return
+		}
+	}
+}
+
+// resumed records a sleep window and notifies the listeners
+func (m *Monitor) resumed(window SleepWindow) {
+	window.DurationSec = window.ResumedAt.Sub(window.SuspendedAt).Seconds()
+
+	m.mu.Lock()
+	m.state = StateRunning
+	m.lastResume = window.ResumedAt
+	m.sleepCount++
+	m.history = append(m.history, window)
+	if len(m.history) > maxHistory {
+		m.history = m.history[len(m.history)-maxHistory:]
+	}
+	listeners := append([]Listener(nil), m.listeners...)
+	m.mu.Unlock()
+
+	m.logger.Event(logger.LevelWarn, ComponentPower, logger.EventPowerResumed,
+		logger.F("from", window.SuspendedAt.Format(time.RFC3339)),
+		logger.F("to", window.ResumedAt.Format(time.RFC3339)),
+		logger.F("duration", time.Duration(window.DurationSec*float64(time.Second)).Truncate(time.Second)),
+		logger.F("source", window.Source))
+
+	for _, listener := range listeners {
+		listener.Resume(window)
+	}
+}
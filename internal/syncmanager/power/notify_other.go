@@ -0,0 +1,10 @@
+//go:build !windows
+
+package power
+
+import "errors"
+
+// registerNotifications is only implemented on Windows, other systems rely on the clock check
+func registerNotifications(onSuspend, onResume func()) (func(), error) {
+	return nil, errors.New("not supported on this platform")
+}
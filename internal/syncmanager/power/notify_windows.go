@@ -0,0 +1,79 @@
+//go:build windows
+
+package power
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	deviceNotifyCallback = 2
+
+	pbtAPMSuspend         = 0x4
+	pbtAPMResumeSuspend   = 0x7
+	pbtAPMResumeAutomatic = 0x12
+)
+
+var (
+	powrprof                                     = syscall.NewLazyDLL("powrprof.dll")
+	procPowerRegisterSuspendResumeNotification   = powrprof.NewProc("PowerRegisterSuspendResumeNotification")
+	procPowerUnregisterSuspendResumeNotification = powrprof.NewProc("PowerUnregisterSuspendResumeNotification")
+)
+
+// deviceNotifySubscribeParameters is DEVICE_NOTIFY_SUBSCRIBE_PARAMETERS
+type deviceNotifySubscribeParameters struct {
+	callback uintptr
+	context  uintptr
+}
+
+// Callback dibuat sekali, syscall.NewCallback punya batas jumlah callback per proses
+var (
+	callback        uintptr
+	callbackSuspend func()
+	callbackResume  func()
+
+	subscribeParams deviceNotifySubscribeParameters
+)
+
+// registerNotifications subscribes to suspend and resume through a callback, this works
+// in a service without a window
+func registerNotifications(onSuspend, onResume func()) (func(), error) {
+	if err := procPowerRegisterSuspendResumeNotification.Find(); err != nil {
+		return nil, err
+	}
+
+	callbackSuspend, callbackResume = onSuspend, onResume
+	if callback == 0 {
+		callback = syscall.NewCallback(func(context, eventType, setting uintptr) uintptr {
+			switch eventType {
+			case pbtAPMSuspend:
+				if callbackSuspend != nil {
+					callbackSuspend()
+				}
+			case pbtAPMResumeSuspend, pbtAPMResumeAutomatic:
+				if callbackResume != nil {
+					go callbackResume()
+				}
+			}
+			return 0
+		})
+	}
+
+	subscribeParams = deviceNotifySubscribeParameters{callback: callback}
+	var handle uintptr
+	ret, _, _ := procPowerRegisterSuspendResumeNotification.Call(
+		deviceNotifyCallback,
+		uintptr(unsafe.Pointer(&subscribeParams)),
+		uintptr(unsafe.Pointer(&handle)),
+	)
+	if ret != 0 {
+		return nil, fmt.Errorf("PowerRegisterSuspendResumeNotification failed: %w", syscall.Errno(ret))
+	}
+
+	return func() {
+		procPowerUnregisterSuspendResumeNotification.Call(handle)
+		callbackSuspend, callbackResume = nil, nil
+	}, nil
+}
@@ -0,0 +1,245 @@
+package preflight
+
+import (
+	"fmt"
+	"jarvist/internal/syncmanager/apiport"
+	"jarvist/internal/syncmanager/config"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+
+	brokerDialTimeout = 5 * time.Second
+)
+
+// minSaneTime is used to detect a reset RTC/CMOS clock
+var minSaneTime = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// CheckResult is the outcome of a single preflight check
+type CheckResult struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Message  string `json:"message"`
+	Hint     string `json:"hint,omitempty"`
+	Critical bool   `json:"critical"`
+	Duration string `json:"duration"`
+}
+
+// Report aggregates all preflight checks
+type Report struct {
+	Passed    bool          `json:"passed"`
+	Checks    []CheckResult `json:"checks"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+// Failures returns only the critical checks that failed
+func (r Report) Failures() []CheckResult {
+	var failures []CheckResult
+	for _, check := range r.Checks {
+		if check.Status == StatusFail && check.Critical {
+			failures = append(failures, check)
+		}
+	}
+	return failures
+}
+
+type check struct {
+	name     string
+	critical bool
+	fn       func(cfg *config.Config) (status, message, hint string)
+}
+
+var checks = []check{
+	{"config", true, checkConfig},
+	{"directories", true, checkDirectories},
+	{"database", true, checkDatabase},
+	{"clock", true, checkClock},
+	{"broker", false, checkBroker},
+	{"api_port", false, checkAPIPort},
+	{"ffmpeg", false, checkFFmpeg},
+}
+
+// Run executes all preflight checks. Critical failures set Passed to false.
+func Run(cfg *config.Config) Report {
+	report := Report{
+		Passed:    true,
+		Timestamp: time.Now(),
+	}
+
+	for _, c := range checks {
+		start := time.Now()
+		status, message, hint := c.fn(cfg)
+
+		result := CheckResult{
+			Name:     c.name,
+			Status:   status,
+			Message:  message,
+			Hint:     hint,
+			Critical: c.critical,
+			Duration: time.Since(start).Round(time.Millisecond).String(),
+		}
+
+		if status == StatusFail && c.critical {
+			report.Passed = false
+		}
+
+		report.Checks = append(report.Checks, result)
+	}
+
+	return report
+}
+
+func checkConfig(cfg *config.Config) (string, string, string) {
+	if cfg.BaseConfig == nil {
+		return StatusFail, "base config not loaded", "Reinstall the application or check config.json in the data directory"
+	}
+	if cfg.MQTT.Broker == "" || cfg.MQTT.Port <= 0 {
+		return StatusFail, "MQTT broker address is not configured", "Set mqtt.broker and mqtt.port in the configuration"
+	}
+	if cfg.MQTT.QoS > 2 {
+		return StatusFail, fmt.Sprintf("invalid MQTT QoS %d", cfg.MQTT.QoS), "QoS must be 0, 1 or 2"
+	}
+	if cfg.API.Enabled && (cfg.API.Port <= 0 || cfg.API.Port > 65535) {
+		return StatusFail, fmt.Sprintf("invalid API port %d", cfg.API.Port), "Set api.port to a value between 1 and 65535"
+	}
+	if cfg.MQTT.EncryptData && cfg.Advanced.FernetKey == "" {
+		return StatusFail, "data encryption enabled but fernet key is empty", "Set advanced.fernetKey or disable mqtt.encrypt_data"
+	}
+	if cfg.Sync.Interval <= 0 {
+		return StatusFail, fmt.Sprintf("invalid sync interval %d", cfg.Sync.Interval), "Set sync_interval to a positive number of seconds"
+	}
+	if cfg.MQTT.EnableTLS && cfg.MQTT.CACertPath != "" {
+		if _, err := os.Stat(cfg.MQTT.CACertPath); err != nil {
+			return StatusFail, fmt.Sprintf("CA certificate not found: %s", cfg.MQTT.CACertPath), "Install the broker CA certificate or disable TLS"
+		}
+	}
+	return StatusOK, "configuration is valid", ""
+}
+
+// checkAPIPort reports when another process holds the API port, the port of this
+// service itself counts as free
+func checkAPIPort(cfg *config.Config) (string, string, string) {
+	if !cfg.API.Enabled || cfg.API.Port <= 0 {
+		return StatusOK, "API is disabled", ""
+	}
+
+	free, owner := apiport.Check(cfg.API.Port)
+	if free {
+		return StatusOK, fmt.Sprintf("API port %d is available", cfg.API.Port), ""
+	}
+
+	holder := "an unknown process"
+	if owner != nil {
+		holder = owner.String()
+	}
+
+	if cfg.API.PortFallback {
+		from, to := apiport.FallbackRange(cfg, cfg.API.Port)
+		return StatusWarn, fmt.Sprintf("API port %d is in use by %s, a port in %d-%d will be used", cfg.API.Port, holder, from, to),
+			"Stop the other process or change api.port to keep a fixed port"
+	}
+	return StatusFail, fmt.Sprintf("API port %d is in use by %s", cfg.API.Port, holder),
+		"Stop the other process, change api.port or enable api.port_fallback"
+}
+
+func checkDirectories(cfg *config.Config) (string, string, string) {
+	if cfg.BaseConfig == nil {
+		return StatusFail, "base config not loaded", ""
+	}
+
+	dirs := map[string]string{
+		"log":           cfg.BaseConfig.LogDir,
+		"data":          cfg.BaseConfig.DataDir,
+		"temp":          cfg.BaseConfig.TempDir,
+		"services data": cfg.BaseConfig.ServicesDataDir,
+	}
+
+	for name, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return StatusFail, fmt.Sprintf("cannot create %s directory %s: %v", name, dir, err), "Run the service with an account that can write to the installation directory"
+		}
+		if err := probeWrite(dir); err != nil {
+			return StatusFail, fmt.Sprintf("%s directory %s is not writable: %v", name, dir, err), "Check folder permissions and free disk space"
+		}
+	}
+
+	return StatusOK, "all directories are writable", ""
+}
+
+func checkDatabase(cfg *config.Config) (string, string, string) {
+	if cfg.BaseConfig == nil || cfg.BaseConfig.DatabasePath == "" {
+		return StatusFail, "database path is not configured", "Set DATABASE_PATH or reinstall the application"
+	}
+
+	dbPath := cfg.BaseConfig.DatabasePath
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return StatusFail, fmt.Sprintf("cannot create database directory: %v", err), "Check permissions of the data directory"
+	}
+
+	file, err := os.OpenFile(dbPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return StatusFail, fmt.Sprintf("database %s is not writable: %v", dbPath, err), "Make sure no other program locks the database and the file is not read-only"
+	}
+	file.Close()
+
+	return StatusOK, fmt.Sprintf("database %s is writable", dbPath), ""
+}
+
+func checkClock(cfg *config.Config) (string, string, string) {
+	now := time.Now()
+	if now.Before(minSaneTime) {
+		return StatusFail, fmt.Sprintf("system clock is set to %s", now.Format(time.RFC3339)), "Fix the system date/time (check the CMOS battery) so data timestamps are correct"
+	}
+	return StatusOK, fmt.Sprintf("system clock: %s", now.Format(time.RFC3339)), ""
+}
+
+func checkBroker(cfg *config.Config) (string, string, string) {
+	addr := net.JoinHostPort(cfg.MQTT.Broker, fmt.Sprintf("%d", cfg.MQTT.Port))
+	conn, err := net.DialTimeout("tcp", addr, brokerDialTimeout)
+	if err == nil {
+		conn.Close()
+		return StatusOK, fmt.Sprintf("broker %s reachable", addr), ""
+	}
+
+	// Broker cadangan dipakai selama broker utama tidak terjangkau
+	for _, broker := range cfg.BrokerEndpoints() {
+		if broker.Address() == addr {
+			continue
+		}
+		if conn, dialErr := net.DialTimeout("tcp", broker.Address(), brokerDialTimeout); dialErr == nil {
+			conn.Close()
+			return StatusWarn, fmt.Sprintf("broker %s unreachable, failover broker %s reachable", addr, broker.Address()),
+				"Data is sent through the failover broker; check the primary broker"
+		}
+	}
+	return StatusWarn, fmt.Sprintf("broker %s unreachable: %v", addr, err), "Data will be queued locally; check the internet connection and firewall"
+}
+
+func checkFFmpeg(cfg *config.Config) (string, string, string) {
+	if cfg.BaseConfig == nil {
+		return StatusWarn, "base config not loaded", ""
+	}
+
+	ffmpegPath := filepath.Join(cfg.BaseConfig.BinDir, "ffmpeg", "ffmpeg.exe")
+	if _, err := os.Stat(ffmpegPath); err != nil {
+		return StatusWarn, fmt.Sprintf("ffmpeg not found at %s", ffmpegPath), "Camera checks in the desktop app will not work until ffmpeg is reinstalled"
+	}
+	return StatusOK, fmt.Sprintf("ffmpeg found at %s", ffmpegPath), ""
+}
+
+func probeWrite(dir string) error {
+	probe := filepath.Join(dir, fmt.Sprintf(".preflight_%d", time.Now().UnixNano()))
+	if err := os.WriteFile(probe, []byte("ok"), 0644); err != nil {
+		return err
+	}
+	return os.Remove(probe)
+}
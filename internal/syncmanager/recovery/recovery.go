@@ -0,0 +1,463 @@
+// Package recovery detects a start after an unclean shutdown and reports what it meant for
+// the data. A run marker is written on start, refreshed while running and removed on a
+// clean stop, finding it on the next start means the previous run crashed or lost power.
+// The report lists the in-doubt files and messages, the last successful publish, the result
+// of the database integrity check and the actions the startup took. It is kept in the
+// database for the UI and published over MQTT.
+package recovery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"jarvist/internal/syncmanager/config"
+	syncService "jarvist/internal/syncmanager/sync"
+	"jarvist/pkg/logger"
+	"jarvist/pkg/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const ComponentRecovery = "recovery"
+
+// Topic is appended to the base topic of the device
+const Topic = "/recovery"
+
+// markerFileName is kept in the data folder of the service
+const markerFileName = "run_marker.json"
+
+const (
+	// aliveInterval is how often the marker records that the service is alive
+	aliveInterval = time.Minute
+	// maxListedFiles limits the in-doubt files named in a report
+	maxListedFiles = 50
+	// maxIntegrityErrors limits the problems returned by the integrity check
+	maxIntegrityErrors = 20
+)
+
+var ErrNotFound = errors.New("recovery report not found")
+
+// Sender publishes the report and knows the last successful publish
+type Sender interface {
+	SendData(topic string, data interface{}) (uint, error)
+	LastPublishedAt() time.Time
+}
+
+// marker is the run marker of the service
+type marker struct {
+	PID           int        `json:"pid"`
+	StartedAt     time.Time  `json:"started_at"`
+	LastAlive     time.Time  `json:"last_alive"`
+	LastPublished *time.Time `json:"last_published,omitempty"`
+}
+
+// Detail holds the lists of a report
+type Detail struct {
+	Files   []string                     `json:"files"` // In-doubt files, at most maxListedFiles
+	Journal *syncService.JournalRecovery `json:"journal,omitempty"`
+	Actions []string                     `json:"actions"`
+}
+
+// Report is a recovery report with its detail
+type Report struct {
+	models.RecoveryReport
+	Detail
+}
+
+// Recovery writes the run marker and builds the report after an unclean shutdown
+type Recovery struct {
+	cfg    *config.Config
+	db     *gorm.DB
+	sender Sender
+	logger *logger.Logger
+
+	mu       sync.Mutex
+	running  bool
+	started  time.Time
+	pending  *Report // Waiting for the journal recovery
+	quitChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// New creates the recovery component
+func New(cfg *config.Config, db *gorm.DB, sender Sender, logger *logger.Logger) *Recovery {
+	return &Recovery{
+		cfg:    cfg,
+		db:     db,
+		sender: sender,
+		logger: logger,
+	}
+}
+
+// Name returns the component name
+func (r *Recovery) Name() string {
+	return "recovery"
+}
+
+// Start inspects the state left by an unclean shutdown and writes the run marker. It runs
+// before the sender and the synchronizer change that state.
+func (r *Recovery) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.running {
+		return nil
+	}
+
+	previous, found, err := r.readMarker()
+	if err != nil {
+		r.logger.Warning(ComponentRecovery, "Unreadable run marker, treating the last shutdown as unclean: %v", err)
+		found = true
+	}
+	if found {
+		report := r.inspect(previous)
+		if err := r.db.Create(&report.RecoveryReport).Error; err != nil {
+			r.logger.Error(ComponentRecovery, "Failed to save recovery report: %v", err)
+		}
+		r.pending = &report
+		r.logger.Warning(ComponentRecovery, "Last shutdown was not clean, %d files and %d messages were in-doubt",
+			report.UnfinishedFiles, report.InDoubtMessages)
+	}
+
+	r.started = time.Now()
+	if err := r.writeMarker(); err != nil {
+		return fmt.Errorf("failed to write run marker: %w", err)
+	}
+
+	r.quitChan = make(chan struct{})
+	r.running = true
+	r.wg.Add(1)
+	go r.aliveWorker(r.quitChan)
+	return nil
+}
+
+// Stop removes the run marker, the next start is then clean
+func (r *Recovery) Stop() error {
+	r.mu.Lock()
+	if !r.running {
+		r.mu.Unlock()
+		return nil
+	}
+	r.running = false
+	close(r.quitChan)
+	r.mu.Unlock()
+
+	r.wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Dihentikan sebelum pemulihan journal selesai, laporan tetap disimpan apa adanya
+	if r.pending != nil {
+		r.completeLocked(nil)
+	}
+
+	if err := os.Remove(r.markerPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove run marker: %w", err)
+	}
+	return nil
+}
+
+// JournalRecovered completes the report with the result of the journal recovery, set as
+// the recovery listener of the synchronizer
+func (r *Recovery) JournalRecovered(result syncService.JournalRecovery) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pending != nil {
+		r.completeLocked(&result)
+	}
+}
+
+// inspect collects the state left by the previous run
+func (r *Recovery) inspect(previous marker) Report {
+	report := Report{}
+	report.DetectedAt = time.Now()
+	report.PreviousStart = previous.StartedAt
+	if !previous.LastAlive.IsZero() {
+		lastAlive := previous.LastAlive
+		report.LastAlive = &lastAlive
+	}
+	report.LastPublished = previous.LastPublished
+	if report.LastPublished == nil {
+		report.LastPublished = r.lastSentMessage()
+	}
+
+	var files []string
+	if err := r.db.Model(&models.SyncJournal{}).
+		Where("state = ?", syncService.JournalIntent).
+		Order("updated_at").
+		Pluck("filename", &files).Error; err != nil {
+		r.logger.Error(ComponentRecovery, "Failed to read in-doubt files: %v", err)
+	}
+	report.UnfinishedFiles = len(files)
+	if len(files) > maxListedFiles {
+		files = files[:maxListedFiles]
+	}
+	report.Files = files
+
+	var inDoubt int64
+	if err := r.db.Model(&models.PendingMessage{}).
+		Where("sent = ? AND JSON_EXTRACT(extra_info, '$.processing') = true", false).
+		Count(&inDoubt).Error; err != nil {
+		r.logger.Error(ComponentRecovery, "Failed to count in-doubt messages: %v", err)
+	}
+	report.InDoubtMessages = int(inDoubt)
+
+	if err := r.db.Model(&models.PendingMessage{}).Where("sent = ?", false).Count(&report.PendingMessages).Error; err != nil {
+		r.logger.Error(ComponentRecovery, "Failed to count pending messages: %v", err)
+	}
+
+	report.IntegrityOK, report.IntegrityResult = checkIntegrity(r.db)
+	return report
+}
+
+// completeLocked records the actions of the startup, saves the report and publishes it,
+// r.mu must be held. journal is nil when the journal recovery did not finish.
+func (r *Recovery) completeLocked(journal *syncService.JournalRecovery) {
+	report := r.pending
+	r.pending = nil
+
+	report.Journal = journal
+	report.Actions, report.DataAffected = actions(report.RecoveryReport, journal)
+	report.Completed = journal != nil
+
+	if detail, err := json.Marshal(report.Detail); err == nil {
+		report.RecoveryReport.Detail = string(detail)
+	}
+
+	payload := map[string]interface{}{
+		"type":   "recovery_report",
+		"report": report,
+	}
+	// Dalam mode local-only tidak ada pesan yang disimpan, ID-nya 0
+	if messageID, err := r.sender.SendData(r.cfg.MQTT.Topic+Topic, payload); err != nil {
+		r.logger.Warning(ComponentRecovery, "Failed to publish recovery report: %v", err)
+	} else {
+		report.Published = messageID != 0
+	}
+
+	if report.ID != 0 {
+		if err := r.db.Save(&report.RecoveryReport).Error; err != nil {
+			r.logger.Error(ComponentRecovery, "Failed to save recovery report: %v", err)
+		}
+	}
+
+	level := logger.LevelWarn
+	if report.DataAffected {
+		level = logger.LevelError
+	}
+	lastAlive := "unknown"
+	if report.LastAlive != nil {
+		lastAlive = report.LastAlive.Format(time.RFC3339)
+	}
+	r.logger.Event(level, ComponentRecovery, logger.EventUncleanShutdown,
+		logger.F("last_alive", lastAlive), logger.F("data_affected", report.DataAffected))
+}
+
+// actions describes what the startup did about the in-doubt state and whether data may
+// have been affected
+func actions(report models.RecoveryReport, journal *syncService.JournalRecovery) ([]string, bool) {
+	var result []string
+	affected := false
+
+	if report.IntegrityOK {
+		result = append(result, "Database integrity check passed")
+	} else {
+		affected = true
+		result = append(result, "Database integrity check failed, back up the database and contact support: "+report.IntegrityResult)
+	}
+
+	if report.InDoubtMessages > 0 {
+		result = append(result, fmt.Sprintf("%d messages being sent at the shutdown were queued again, they may arrive twice", report.InDoubtMessages))
+	}
+
+	switch {
+	case journal == nil:
+		if report.UnfinishedFiles > 0 {
+			affected = true
+			result = append(result, fmt.Sprintf("Stopped before the %d in-doubt files were recovered, they are retried on the next start", report.UnfinishedFiles))
+		}
+	case journal.Error != "":
+		affected = true
+		result = append(result, "Sync journal could not be read, in-doubt files were not recovered: "+journal.Error)
+	default:
+		outcomes := []struct {
+			outcome  string
+			text     string
+			affected bool
+		}{
+			{syncService.RecoveryReprocess, "%d files were not processed yet and are processed again", false},
+			{syncService.RecoveryEnqueued, "%d files already had their message stored", false},
+			{syncService.RecoveryResent, "%d files had their message sent again from the file", false},
+			{syncService.RecoveryCleared, "%d unreadable files were handed to the integrity scanner", false},
+			{syncService.RecoveryLost, "%d files are gone and their data could not be sent again", true},
+			{syncService.RecoveryFailed, "%d files are still in-doubt and are retried on the next start", true},
+		}
+		for _, o := range outcomes {
+			if count := journal.Outcomes[o.outcome]; count > 0 {
+				result = append(result, fmt.Sprintf(o.text, count))
+				affected = affected || o.affected
+			}
+		}
+		if len(journal.Lost) > 0 {
+			result = append(result, "Lost files: "+strings.Join(journal.Lost, ", "))
+		}
+	}
+
+	if len(result) == 1 && report.IntegrityOK && report.UnfinishedFiles == 0 {
+		result = append(result, "No data was in-doubt, nothing had to be recovered")
+	}
+	return result, affected
+}
+
+// checkIntegrity runs the SQLite integrity check, the result is "ok" when the database is
+// sound and the problems found otherwise
+func checkIntegrity(db *gorm.DB) (bool, string) {
+	var rows []string
+	if err := db.Raw(fmt.Sprintf("PRAGMA integrity_check(%d)", maxIntegrityErrors)).Scan(&rows).Error; err != nil {
+		return false, "integrity check failed to run: " + err.Error()
+	}
+	if len(rows) == 1 && rows[0] == "ok" {
+		return true, "ok"
+	}
+	return false, strings.Join(rows, "; ")
+}
+
+// lastSentMessage approximates the last publish by the newest sent message, for a marker
+// written before the publish time was recorded
+func (r *Recovery) lastSentMessage() *time.Time {
+	var message models.PendingMessage
+	if err := r.db.Where("sent = ?", true).Order("timestamp DESC").First(&message).Error; err != nil {
+		return nil
+	}
+	return &message.Timestamp
+}
+
+// aliveWorker refreshes the marker so the report shows when the previous run was last alive
+func (r *Recovery) aliveWorker(quit chan struct{}) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(aliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.mu.Lock()
+			err := r.writeMarker()
+			r.mu.Unlock()
+			if err != nil {
+				r.logger.Warning(ComponentRecovery, "Failed to refresh run marker: %v", err)
+			}
+		case <-quit:
+			return
+		}
+	}
+}
+
+// writeMarker records the run, r.mu must be held
+func (r *Recovery) writeMarker() error {
+	current := marker{
+		PID:       os.Getpid(),
+		StartedAt: r.started,
+		LastAlive: time.Now(),
+	}
+	if published := r.sender.LastPublishedAt(); !published.IsZero() {
+		current.LastPublished = &published
+	} else if previous, found, err := r.readMarker(); err == nil && found && previous.StartedAt.Equal(r.started) {
+		current.LastPublished = previous.LastPublished
+	}
+
+	data, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.markerPath()), 0755); err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(r.markerPath(), data, 0644)
+}
+
+func (r *Recovery) readMarker() (marker, bool, error) {
+	var current marker
+	data, err := os.ReadFile(r.markerPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return current, false, nil
+	} else if err != nil {
+		return current, false, err
+	}
+	if err := json.Unmarshal(data, &current); err != nil {
+		return current, false, err
+	}
+	return current, true, nil
+}
+
+func (r *Recovery) markerPath() string {
+	return filepath.Join(r.cfg.BaseConfig.DataDir, markerFileName)
+}
+
+// List returns the newest reports first
+func (r *Recovery) List(limit int) ([]Report, error) {
+	var rows []models.RecoveryReport
+	if err := r.db.Order("id DESC").Limit(limit).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	reports := make([]Report, 0, len(rows))
+	for _, row := range rows {
+		reports = append(reports, toReport(row))
+	}
+	return reports, nil
+}
+
+// Unacknowledged returns the newest report the operator has not acknowledged, nil if none
+func (r *Recovery) Unacknowledged() (*Report, error) {
+	var row models.RecoveryReport
+	err := r.db.Where("acknowledged_at IS NULL").Order("id DESC").First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	report := toReport(row)
+	return &report, nil
+}
+
+// Acknowledge records that the operator has seen a report
+func (r *Recovery) Acknowledge(id uint, actor string) error {
+	result := r.db.Model(&models.RecoveryReport{}).
+		Where("id = ? AND acknowledged_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"acknowledged_at": time.Now(),
+			"acknowledged_by": actor,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		r.db.Model(&models.RecoveryReport{}).Where("id = ?", id).Count(&count)
+		if count == 0 {
+			return ErrNotFound
+		}
+	}
+	return nil
+}
+
+func toReport(row models.RecoveryReport) Report {
+	report := Report{RecoveryReport: row}
+	if row.Detail != "" {
+		json.Unmarshal([]byte(row.Detail), &report.Detail)
+	}
+	if report.Files == nil {
+		report.Files = []string{}
+	}
+	return report
+}
@@ -0,0 +1,174 @@
+package recovery
+
+import (
+	"encoding/json"
+	"errors"
+	baseConfig "jarvist/internal/common/config"
+	"jarvist/internal/common/models"
+	"jarvist/internal/syncmanager/config"
+	syncService "jarvist/internal/syncmanager/sync"
+	"jarvist/internal/testutil"
+	"jarvist/pkg/logger"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type fakeSender struct {
+	mu        sync.Mutex
+	published []interface{}
+}
+
+func (f *fakeSender) SendData(topic string, data interface{}) (uint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.published = append(f.published, data)
+	return uint(len(f.published)), nil
+}
+
+func (f *fakeSender) LastPublishedAt() time.Time {
+	return time.Time{}
+}
+
+func (f *fakeSender) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.published)
+}
+
+func newTestRecovery(t *testing.T) (*Recovery, *gorm.DB, *fakeSender) {
+	t.Helper()
+
+	dir := t.TempDir()
+	db := testutil.OpenDB(t, &models.PendingMessage{}, &models.SyncJournal{}, &models.RecoveryReport{})
+
+	cfg := &config.Config{BaseConfig: &baseConfig.Config{DataDir: filepath.Join(dir, "data")}}
+	cfg.MQTT.Topic = "jarvist/site"
+
+	sender := &fakeSender{}
+	return New(cfg, db, sender, logger.NewLogger()), db, sender
+}
+
+// writeCrashMarker leaves the marker of a run that did not stop
+func writeCrashMarker(t *testing.T, r *Recovery) {
+	t.Helper()
+
+	data, _ := json.Marshal(marker{
+		PID:       1234,
+		StartedAt: time.Now().Add(-time.Hour),
+		LastAlive: time.Now().Add(-5 * time.Minute),
+	})
+	if err := os.MkdirAll(filepath.Dir(r.markerPath()), 0755); err != nil {
+		t.Fatalf("create data folder: %v", err)
+	}
+	if err := os.WriteFile(r.markerPath(), data, 0644); err != nil {
+		t.Fatalf("write marker: %v", err)
+	}
+}
+
+func TestCleanStartWritesNoReport(t *testing.T) {
+	r, _, sender := newTestRecovery(t)
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if _, err := os.Stat(r.markerPath()); err != nil {
+		t.Fatalf("marker not written: %v", err)
+	}
+	if err := r.Stop(); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	if _, err := os.Stat(r.markerPath()); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("marker not removed on a clean stop: %v", err)
+	}
+
+	reports, err := r.List(10)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(reports) != 0 || sender.count() != 0 {
+		t.Fatalf("clean start reported %d reports, %d published", len(reports), sender.count())
+	}
+}
+
+func TestUncleanShutdownReport(t *testing.T) {
+	r, db, sender := newTestRecovery(t)
+	writeCrashMarker(t, r)
+
+	db.Create(&models.SyncJournal{Filename: filepath.Join("20250101", "a.json"), DateFolder: "20250101", State: syncService.JournalIntent})
+	db.Create(&models.SyncJournal{Filename: filepath.Join("20250101", "b.json"), DateFolder: "20250101", State: syncService.JournalConfirmed})
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer r.Stop()
+
+	report, err := r.Unacknowledged()
+	if err != nil || report == nil {
+		t.Fatalf("no pending report after an unclean shutdown: %v", err)
+	}
+	if report.UnfinishedFiles != 1 || report.Completed {
+		t.Fatalf("report = %+v, want 1 unfinished file and not completed", report.RecoveryReport)
+	}
+	if !report.IntegrityOK || report.IntegrityResult != "ok" {
+		t.Fatalf("integrity = %v %q, want ok", report.IntegrityOK, report.IntegrityResult)
+	}
+	if report.LastAlive == nil {
+		t.Fatal("last alive time of the previous run not recorded")
+	}
+
+	r.JournalRecovered(syncService.JournalRecovery{
+		Total:    1,
+		Outcomes: map[string]int{syncService.RecoveryLost: 1},
+		Lost:     []string{filepath.Join("20250101", "a.json")},
+	})
+
+	report, err = r.Unacknowledged()
+	if err != nil || report == nil {
+		t.Fatalf("report not found after the journal recovery: %v", err)
+	}
+	if !report.Completed || !report.DataAffected || !report.Published {
+		t.Fatalf("report = %+v, want completed, published and data affected", report.RecoveryReport)
+	}
+	if len(report.Files) != 1 || len(report.Actions) == 0 || report.Journal == nil {
+		t.Fatalf("detail = %+v, want the in-doubt file, actions and journal result", report.Detail)
+	}
+	if sender.count() != 1 {
+		t.Fatalf("published %d reports, want 1", sender.count())
+	}
+}
+
+func TestAcknowledge(t *testing.T) {
+	r, _, _ := newTestRecovery(t)
+	writeCrashMarker(t, r)
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if err := r.Stop(); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	report, err := r.Unacknowledged()
+	if err != nil || report == nil {
+		t.Fatalf("no report: %v", err)
+	}
+	// Dihentikan sebelum pemulihan journal, laporan tetap disimpan
+	if report.Completed {
+		t.Fatal("report completed without a journal recovery")
+	}
+
+	if err := r.Acknowledge(report.ID, "operator"); err != nil {
+		t.Fatalf("acknowledge: %v", err)
+	}
+	if report, _ := r.Unacknowledged(); report != nil {
+		t.Fatalf("report still unacknowledged: %+v", report.RecoveryReport)
+	}
+	if err := r.Acknowledge(report.ID+100, "operator"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("acknowledge unknown report = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,623 @@
+// Package scheduler runs the periodic jobs of the sync manager on cron schedules with
+// jitter, locks against overlapping runs and a history of recent runs.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"jarvist/pkg/logger"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const ComponentScheduler = "scheduler"
+
+const (
+	// historySize is the number of runs kept per job
+	historySize = 20
+
+	// stopTimeout is how long Stop waits for running jobs after cancelling them
+	stopTimeout = 10 * time.Second
+
+	// lockRetryDelay postpones a scheduled run whose lock is held, instead of skipping it
+	lockRetryDelay = 30 * time.Second
+)
+
+// Shared locks, jobs holding the same lock never run at the same time
+const (
+	LockDataFiles = "data_files" // Jobs that move or delete data files
+	LockDatabase  = "database"   // Jobs that rewrite large parts of the database
+)
+
+// Run triggers
+const (
+	TriggerSchedule = "schedule"
+	TriggerStart    = "start"
+	TriggerManual   = "manual"
+)
+
+// Run states
+const (
+	StatusRunning = "running"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+var (
+	ErrUnknownJob     = errors.New("unknown job")
+	ErrUnknownRun     = errors.New("unknown job run")
+	ErrDuplicateJob   = errors.New("job already registered")
+	ErrNotRunning     = errors.New("scheduler is not running")
+	ErrAlreadyRunning = errors.New("job is already running")
+	ErrLocked         = errors.New("lock is held by another job")
+
+	// ErrSkipped is returned by a job that had nothing to do, like a stopped service
+	ErrSkipped = errors.New("job skipped")
+)
+
+// Job is a periodic task, or a maintenance task that only runs on demand when it has no
+// schedule
+type Job struct {
+	Name        string
+	Description string
+	Schedule    string        // Cron expression, descriptor or "@every <duration>", empty for on demand
+	Jitter      time.Duration // Random delay added to each scheduled run
+	Singleton   bool          // Skip a run while the previous run is still going
+	Locks       []string      // Skip a run while another job holds one of these locks
+	RunOnStart  bool          // Run once when the scheduler starts
+	Timeout     time.Duration // Deadline of the run context, 0 for none
+	Run         func(ctx context.Context) error
+}
+
+// Run is one run of a job
+type Run struct {
+	ID         string    `json:"id"`
+	Job        string    `json:"job"`
+	Trigger    string    `json:"trigger"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	Result     any       `json:"result,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// JobInfo describes a registered job
+type JobInfo struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Schedule    string     `json:"schedule,omitempty"`
+	OnDemand    bool       `json:"on_demand"`
+	JitterSec   float64    `json:"jitter_sec"`
+	Singleton   bool       `json:"singleton"`
+	Locks       []string   `json:"locks,omitempty"`
+	Running     bool       `json:"running"`
+	NextRun     *time.Time `json:"next_run,omitempty"`
+	LastRun     *Run       `json:"last_run,omitempty"`
+	Runs        int        `json:"runs"`
+	Failures    int        `json:"failures"`
+	Skipped     int        `json:"skipped"`
+	History     []Run      `json:"history"`
+}
+
+type entry struct {
+	job      Job
+	schedule Schedule
+	base     time.Time // Next activation without jitter
+	next     time.Time
+	running  int
+	history  []Run
+	runs     int
+	failures int
+	skipped  int
+}
+
+// Scheduler runs registered jobs on their schedules
+type Scheduler struct {
+	logger *logger.Logger
+	rand   *rand.Rand
+
+	mu      sync.Mutex
+	entries []*entry
+	locks   map[string]string // Lock name to the job holding it
+	seq     int
+	running bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wake    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// New creates a scheduler without jobs
+func New(logger *logger.Logger) *Scheduler {
+	return &Scheduler{
+		logger: logger,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		locks:  make(map[string]string),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Name returns the component name used in startup logs
+func (s *Scheduler) Name() string {
+	return "Job scheduler"
+}
+
+// Add registers a job, jobs added while the scheduler runs are scheduled right away
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" || job.Run == nil {
+		return fmt.Errorf("job needs a name and a run function")
+	}
+	var schedule Schedule
+	if job.Schedule != "" {
+		var err error
+		if schedule, err = Parse(job.Schedule); err != nil {
+			return fmt.Errorf("job %s: %w", job.Name, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.find(job.Name) != nil {
+		return fmt.Errorf("%w: %s", ErrDuplicateJob, job.Name)
+	}
+
+	e := &entry{job: job, schedule: schedule}
+	s.entries = append(s.entries, e)
+
+	if s.running {
+		s.scheduleNext(e, time.Now())
+		s.notify()
+	}
+	return nil
+}
+
+// Reschedule changes the schedule of a job, an empty schedule only runs it on demand
+func (s *Scheduler) Reschedule(name, expr string) error {
+	var schedule Schedule
+	if expr != "" {
+		var err error
+		if schedule, err = Parse(expr); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.find(name)
+	if e == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownJob, name)
+	}
+	e.job.Schedule = expr
+	e.schedule = schedule
+	e.base = time.Time{}
+
+	if s.running {
+		s.scheduleNext(e, time.Now())
+		s.notify()
+	}
+	s.logger.Info(ComponentScheduler, "Job %s rescheduled to %s", name, expr)
+	return nil
+}
+
+// Start schedules all jobs and runs the ones marked RunOnStart
+func (s *Scheduler) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return nil
+	}
+
+	s.running = true
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	now := time.Now()
+	for _, e := range s.entries {
+		e.base = time.Time{}
+		s.scheduleNext(e, now)
+		if e.job.RunOnStart {
+			if _, err := s.dispatch(e, TriggerStart); errors.Is(err, ErrLocked) {
+				e.next = now.Add(lockRetryDelay)
+			}
+		}
+	}
+
+	s.wg.Add(1)
+	go s.loop(s.ctx)
+
+	s.logger.Info(ComponentScheduler, "Job scheduler started with %d jobs", len(s.entries))
+	return nil
+}
+
+// Stop cancels running jobs and waits for them to return
+func (s *Scheduler) Stop() error {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return nil
+	}
+	s.running = false
+	s.cancel()
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(stopTimeout):
+		s.logger.Warning(ComponentScheduler, "Timed out waiting for running jobs to stop")
+	}
+
+	s.logger.Info(ComponentScheduler, "Job scheduler stopped")
+	return nil
+}
+
+// HealthCheck reports whether the scheduler is running
+func (s *Scheduler) HealthCheck() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return errors.New("job scheduler is not running")
+	}
+	return nil
+}
+
+// RunNow starts a job outside its schedule and returns the run, which can be polled with
+// GetRun. It fails when the job may not run now, like a singleton job that is still running
+// or a job whose lock is held.
+func (s *Scheduler) RunNow(name string) (Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return Run{}, ErrNotRunning
+	}
+	e := s.find(name)
+	if e == nil {
+		return Run{}, fmt.Errorf("%w: %s", ErrUnknownJob, name)
+	}
+	return s.dispatch(e, TriggerManual)
+}
+
+// GetRun returns a run from the history of its job
+func (s *Scheduler) GetRun(id string) (Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.entries {
+		for _, run := range e.history {
+			if run.ID == id {
+				return run, nil
+			}
+		}
+	}
+	return Run{}, fmt.Errorf("%w: %s", ErrUnknownRun, id)
+}
+
+// NextRun returns the next scheduled run of a job, the zero time if it has none
+func (s *Scheduler) NextRun(name string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e := s.find(name); e != nil && s.running {
+		return e.next
+	}
+	return time.Time{}
+}
+
+// List returns all jobs in registration order
+func (s *Scheduler) List() []JobInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	infos := make([]JobInfo, 0, len(s.entries))
+	for _, e := range s.entries {
+		infos = append(infos, s.info(e))
+	}
+	return infos
+}
+
+// Get returns one job
+func (s *Scheduler) Get(name string) (JobInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.find(name)
+	if e == nil {
+		return JobInfo{}, fmt.Errorf("%w: %s", ErrUnknownJob, name)
+	}
+	return s.info(e), nil
+}
+
+func (s *Scheduler) info(e *entry) JobInfo {
+	info := JobInfo{
+		Name:        e.job.Name,
+		Description: e.job.Description,
+		Schedule:    e.job.Schedule,
+		OnDemand:    e.schedule == nil,
+		JitterSec:   e.job.Jitter.Seconds(),
+		Singleton:   e.job.Singleton,
+		Locks:       e.job.Locks,
+		Running:     e.running > 0,
+		Runs:        e.runs,
+		Failures:    e.failures,
+		Skipped:     e.skipped,
+		History:     make([]Run, len(e.history)),
+	}
+	if s.running && !e.next.IsZero() {
+		next := e.next
+		info.NextRun = &next
+	}
+
+	// Terbaru di depan
+	for i, run := range e.history {
+		info.History[len(e.history)-1-i] = run
+	}
+	if len(info.History) > 0 {
+		last := info.History[0]
+		info.LastRun = &last
+	}
+	return info
+}
+
+func (s *Scheduler) find(name string) *entry {
+	for _, e := range s.entries {
+		if e.job.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// notify wakes the loop to pick up a changed schedule
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// scheduleNext sets the next activation of a job. Activations missed while the system was
+// asleep are not caught up, the job runs once and continues from now.
+func (s *Scheduler) scheduleNext(e *entry, now time.Time) {
+	if e.schedule == nil {
+		e.base, e.next = time.Time{}, time.Time{}
+		return
+	}
+
+	base := time.Time{}
+	if !e.base.IsZero() {
+		base = e.schedule.Next(e.base)
+	}
+	if base.IsZero() || !base.After(now) {
+		base = e.schedule.Next(now)
+	}
+
+	e.base = base
+	e.next = base
+	if !base.IsZero() && e.job.Jitter > 0 {
+		e.next = base.Add(time.Duration(s.rand.Int63n(int64(e.job.Jitter))))
+	}
+}
+
+// loop sleeps until the next activation and dispatches the jobs that are due
+func (s *Scheduler) loop(ctx context.Context) {
+	defer s.wg.Done()
+
+	for {
+		s.mu.Lock()
+		var next time.Time
+		for _, e := range s.entries {
+			if !e.next.IsZero() && (next.IsZero() || e.next.Before(next)) {
+				next = e.next
+			}
+		}
+		s.mu.Unlock()
+
+		// Tanpa jadwal, tunggu sampai ada job baru
+		wait := time.Hour
+		if !next.IsZero() {
+			wait = time.Until(next)
+		}
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-timer.C:
+		case <-s.wake:
+			timer.Stop()
+			continue
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		now := time.Now()
+		s.mu.Lock()
+		for _, e := range s.entries {
+			if e.next.IsZero() || e.next.After(now) {
+				continue
+			}
+			// Job lain memegang lock, coba lagi sebentar lagi tanpa menggeser jadwal
+			if _, err := s.dispatch(e, TriggerSchedule); errors.Is(err, ErrLocked) {
+				e.next = now.Add(lockRetryDelay)
+				continue
+			}
+			s.scheduleNext(e, now)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// dispatch starts a run of e unless its singleton or a lock is held. A scheduled or start
+// run that finds a lock held is not recorded, the caller postpones it. The caller holds s.mu.
+func (s *Scheduler) dispatch(e *entry, trigger string) (Run, error) {
+	var reason error
+	if e.job.Singleton && e.running > 0 {
+		reason = ErrAlreadyRunning
+	} else if lock, holder := s.heldLock(e); lock != "" {
+		reason = fmt.Errorf("%w %s (%s)", ErrLocked, lock, holder)
+		if trigger != TriggerManual {
+			s.logger.Debug(ComponentScheduler, "Job %s postponed: %v", e.job.Name, reason)
+			return Run{}, reason
+		}
+	}
+
+	s.seq++
+	run := Run{
+		ID:        fmt.Sprintf("%s-%d", e.job.Name, s.seq),
+		Job:       e.job.Name,
+		Trigger:   trigger,
+		StartedAt: time.Now(),
+	}
+
+	if reason != nil {
+		run.Status = StatusSkipped
+		run.Error = reason.Error()
+		s.record(e, run)
+		s.logger.Info(ComponentScheduler, "Job %s skipped: %v", e.job.Name, reason)
+		return run, reason
+	}
+
+	e.running++
+	for _, lock := range e.job.Locks {
+		s.locks[lock] = e.job.Name
+	}
+
+	// Run yang sedang berjalan sudah masuk history agar bisa di-poll
+	run.Status = StatusRunning
+	s.record(e, run)
+
+	s.wg.Add(1)
+	go s.execute(s.ctx, e, run)
+	return run, nil
+}
+
+// heldLock returns the first lock of e held by another job. The caller holds s.mu.
+func (s *Scheduler) heldLock(e *entry) (string, string) {
+	for _, lock := range e.job.Locks {
+		if holder, held := s.locks[lock]; held {
+			return lock, holder
+		}
+	}
+	return "", ""
+}
+
+// resultKey carries the result holder of a run in its context
+type resultKey struct{}
+
+type resultHolder struct {
+	mu    sync.Mutex
+	value any
+}
+
+// SetResult stores a summary of the run, like the number of processed files, that is
+// returned with the run when it is polled
+func SetResult(ctx context.Context, value any) {
+	if holder, ok := ctx.Value(resultKey{}).(*resultHolder); ok {
+		holder.mu.Lock()
+		holder.value = value
+		holder.mu.Unlock()
+	}
+}
+
+// execute runs a job and records the result
+func (s *Scheduler) execute(ctx context.Context, e *entry, run Run) {
+	defer s.wg.Done()
+
+	if e.job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.job.Timeout)
+		defer cancel()
+	}
+	holder := &resultHolder{}
+	ctx = context.WithValue(ctx, resultKey{}, holder)
+
+	s.logger.Debug(ComponentScheduler, "Running job %s (%s)", e.job.Name, run.Trigger)
+
+	err := s.call(ctx, e.job)
+	duration := time.Since(run.StartedAt)
+	run.DurationMs = duration.Milliseconds()
+
+	holder.mu.Lock()
+	run.Result = holder.value
+	holder.mu.Unlock()
+
+	switch {
+	case errors.Is(err, ErrSkipped):
+		run.Status = StatusSkipped
+		run.Error = err.Error()
+	case err != nil:
+		run.Status = StatusFailed
+		run.Error = err.Error()
+		s.logger.Event(logger.LevelError, ComponentScheduler, logger.EventJobFailed,
+			logger.F("job", e.job.Name), logger.F("duration", duration.Round(time.Millisecond)), logger.F("error", err))
+	default:
+		run.Status = StatusSuccess
+	}
+
+	s.mu.Lock()
+	e.running--
+	for _, lock := range e.job.Locks {
+		if s.locks[lock] == e.job.Name && e.running == 0 {
+			delete(s.locks, lock)
+		}
+	}
+	s.finish(e, run)
+	s.mu.Unlock()
+}
+
+// call runs the job function and turns a panic into an error
+func (s *Scheduler) call(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+// record appends a run to the history of e. The caller holds s.mu.
+func (s *Scheduler) record(e *entry, run Run) {
+	if run.Status == StatusSkipped {
+		e.skipped++
+	}
+	e.runs++
+
+	e.history = append(e.history, run)
+	if len(e.history) > historySize {
+		e.history = e.history[len(e.history)-historySize:]
+	}
+}
+
+// finish replaces the running entry of a run with its result. The caller holds s.mu.
+func (s *Scheduler) finish(e *entry, run Run) {
+	switch run.Status {
+	case StatusFailed:
+		e.failures++
+	case StatusSkipped:
+		e.skipped++
+	}
+
+	for i := range e.history {
+		if e.history[i].ID == run.ID {
+			e.history[i] = run
+			return
+		}
+	}
+
+	// Sudah tergeser dari history oleh run lain
+	e.history = append(e.history, run)
+	if len(e.history) > historySize {
+		e.history = e.history[len(e.history)-historySize:]
+	}
+}
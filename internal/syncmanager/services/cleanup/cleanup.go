@@ -1,8 +1,12 @@
 package cleanup
 
 import (
+	"context"
 	"fmt"
+	"jarvist/internal/common/licensestate"
 	"jarvist/internal/common/models"
+	"jarvist/internal/syncmanager/config"
+	"jarvist/internal/syncmanager/scheduler"
 	"jarvist/internal/syncmanager/services/log"
 	"jarvist/pkg/logger"
 	"os"
@@ -16,19 +20,24 @@ import (
 // Config holds configuration for the cleanup service
 type Config struct {
 	// General
-	Enabled  bool          // Whether cleanup is enabled
-	Interval time.Duration // How often to run cleanup
+	Enabled  bool   // Whether cleanup is enabled
+	Schedule string // Cron schedule of the cleanup job
 
 	// Retention periods (in days)
 	LogRetention           int // How many days of logs to keep
 	MessageRetention       int // How many days of messages to keep
 	ProcessedFileRetention int // How many days of processed files to keep
 	SyncedFolderRetention  int // How many days of synced folders to keep if not fully synced
+	DataRecordRetention    int // How many days of cached data records to keep
 
 	// Limits
 	MaxLogFiles        int // Maximum number of log files to keep
 	MaxPendingMessages int // Maximum number of pending messages to keep
 
+	// Size quotas (in MB, 0 disables), oldest files are deleted first when exceeded
+	LogQuotaMB      int // Maximum size of the log files
+	DataFileQuotaMB int // Maximum size of the data files, only sent files are deleted
+
 	// Paths
 	DataDirectory string // Base directory for data files
 }
@@ -37,26 +46,52 @@ type Config struct {
 func DefaultConfig() *Config {
 	return &Config{
 		Enabled:                true,
-		Interval:               24 * time.Hour, // Daily cleanup
-		LogRetention:           7,              // 7 days
-		MessageRetention:       7,              // 7 days
-		ProcessedFileRetention: 7,              // 7 days
-		SyncedFolderRetention:  7,              // 7 days
-		MaxLogFiles:            10,             // 10 log files
-		MaxPendingMessages:     10000,          // 10,000 pending messages
-		DataDirectory:          "./data",       // Default data directory
+		Schedule:               "0 2 * * *", // Daily at 02:00
+		LogRetention:           7,           // 7 days
+		MessageRetention:       7,           // 7 days
+		ProcessedFileRetention: 7,           // 7 days
+		SyncedFolderRetention:  7,           // 7 days
+		DataRecordRetention:    90,          // 90 days
+		MaxLogFiles:            10,          // 10 log files
+		MaxPendingMessages:     10000,       // 10,000 pending messages
+		LogQuotaMB:             500,         // 500 MB of log files
+		DataFileQuotaMB:        10240,       // 10 GB of data files
+		DataDirectory:          "./data",    // Default data directory
+	}
+}
+
+// ApplyRetention overrides the retention periods with the sync settings changed from the
+// desktop app and reports whether any of them changed
+func (c *Config) ApplyRetention(settings config.SyncSettings) bool {
+	changed := false
+	override := func(days int, target *int) {
+		if days > 0 && days != *target {
+			*target = days
+			changed = true
+		}
 	}
+	override(settings.LogRetentionDays, &c.LogRetention)
+	override(settings.MessageRetentionDays, &c.MessageRetention)
+	override(settings.DataRecordRetentionDays, &c.DataRecordRetention)
+	return changed
 }
 
+// JobName is the name of the cleanup job in the scheduler
+const JobName = "cleanup"
+
+// cleanupJitter spreads the cleanup of many devices over the schedule
+const cleanupJitter = 10 * time.Minute
+
 // CleanupService handles automatic cleanup of old data
 type CleanupService struct {
 	db          *gorm.DB
 	logger      *logger.Logger
 	logService  *log.LogService
 	config      *Config
+	jobs        *scheduler.Scheduler
 	running     bool
-	stopCh      chan struct{}
 	lastCleanup time.Time
+	lastReport  *Report
 	mu          sync.Mutex
 }
 
@@ -76,12 +111,35 @@ func NewCleanupService(
 		logger:      logger,
 		logService:  logService,
 		config:      config,
-		stopCh:      make(chan struct{}),
 		lastCleanup: time.Time{},
 	}
 }
 
-// Start begins the periodic cleanup process
+// Name returns the component name used in startup logs
+func (s *CleanupService) Name() string {
+	return "Cleanup service"
+}
+
+// RegisterJobs adds the cleanup job to the scheduler. It also runs when the scheduler
+// starts, like the cleanup on startup before.
+func (s *CleanupService) RegisterJobs(jobs *scheduler.Scheduler) error {
+	s.mu.Lock()
+	s.jobs = jobs
+	schedule := s.config.Schedule
+	s.mu.Unlock()
+
+	return jobs.Add(scheduler.Job{
+		Name:       JobName,
+		Schedule:   schedule,
+		Jitter:     cleanupJitter,
+		Singleton:  true,
+		Locks:      []string{scheduler.LockDataFiles, scheduler.LockDatabase},
+		RunOnStart: true,
+		Run:        s.runJob,
+	})
+}
+
+// Start enables the scheduled cleanup. A restarted service cleans up right away.
 func (s *CleanupService) Start() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -97,13 +155,16 @@ func (s *CleanupService) Start() error {
 
 	s.running = true
 
-	go s.runCleanupLoop()
+	// Saat startup scheduler belum jalan, job dijalankan oleh RunOnStart
+	if s.jobs != nil {
+		s.jobs.RunNow(JobName)
+	}
 
-	s.logger.Info("cleanup", "Cleanup service started (interval: %v)", s.config.Interval)
+	s.logger.Info("cleanup", "Cleanup service started (schedule: %s)", s.config.Schedule)
 	return nil
 }
 
-// Stop halts the cleanup process
+// Stop disables the scheduled cleanup
 func (s *CleanupService) Stop() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -112,29 +173,34 @@ func (s *CleanupService) Stop() error {
 		return nil // Not running
 	}
 
-	close(s.stopCh)
 	s.running = false
 
 	s.logger.Info("cleanup", "Cleanup service stopped")
 	return nil
 }
 
-// runCleanupLoop runs the cleanup loop until stopped
-func (s *CleanupService) runCleanupLoop() {
-	ticker := time.NewTicker(s.config.Interval)
-	defer ticker.Stop()
+// HealthCheck reports whether the cleanup loop is running, a disabled service is healthy
+func (s *CleanupService) HealthCheck() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// Run cleanup immediately on start
-	s.runCleanup()
+	if s.config.Enabled && !s.running {
+		return fmt.Errorf("cleanup service is not running")
+	}
+	return nil
+}
 
-	for {
-		select {
-		case <-ticker.C:
-			s.runCleanup()
-		case <-s.stopCh:
-			return
-		}
+// runJob is the scheduled cleanup, it is skipped while the service is stopped
+func (s *CleanupService) runJob(ctx context.Context) error {
+	s.mu.Lock()
+	running := s.running
+	s.mu.Unlock()
+
+	if !running {
+		return scheduler.ErrSkipped
 	}
+	s.runCleanup()
+	return nil
 }
 
 // runCleanup executes all cleanup operations
@@ -142,110 +208,189 @@ func (s *CleanupService) runCleanup() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	start := time.Now()
 	s.logger.Info("cleanup", "Starting data cleanup process")
 
-	// Track statistics
-	stats := map[string]interface{}{
-		"start_time": start.Format(time.RFC3339),
+	report := s.collect(false)
+	duration := time.Duration(report.DurationMs) * time.Millisecond
+
+	s.lastCleanup = time.Now()
+	s.lastReport = &report
+
+	s.logger.Event(logger.LevelInfo, "cleanup", logger.EventCleanupCompleted, logger.F("duration", duration))
+
+	// Log cleanup summary to database
+	if s.logService != nil {
+		counts := make(map[string]CategoryReport, len(report.Categories))
+		for _, category := range report.Categories {
+			counts[category.Category] = category
+		}
+		summary := fmt.Sprintf(
+			"Cleanup summary: removed %d logs, %d messages, %d processed files (%d actual files), %d folders, %d data records, freed %d bytes",
+			counts[CategoryLogs].Records, counts[CategoryMessages].Records, counts[CategoryProcessedFiles].Records,
+			counts[CategoryProcessedFiles].Files, counts[CategorySyncedFolders].Records, counts[CategoryDataRecords].Records,
+			report.TotalBytes,
+		)
+		s.logService.LogMessage("INFO", "cleanup", summary)
 	}
+}
+
+// Preview reports per category what the next cleanup would remove without deleting anything
+func (s *CleanupService) Preview() Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.collect(true)
+}
+
+// collect runs every category, in a dry run only the counts and sizes are gathered
+func (s *CleanupService) collect(dryRun bool) Report {
+	report := Report{DryRun: dryRun, StartedAt: time.Now()}
+	removed := make(map[string]bool)
 
-	// Cleanup logs in database and files
-	logCount, err := s.cleanupLogs()
+	logs, err := s.cleanupLogs(dryRun, removed)
 	if err != nil {
 		s.logger.Error("cleanup", "Error cleaning up logs: %v", err)
 	}
-	stats["logs_deleted"] = logCount
+	report.add(logs, err)
 
-	// Cleanup pending messages
-	msgCount, err := s.cleanupMessages()
+	messages, err := s.cleanupMessages(dryRun)
 	if err != nil {
 		s.logger.Error("cleanup", "Error cleaning up messages: %v", err)
 	}
-	stats["messages_deleted"] = msgCount
+	report.add(messages, err)
 
-	// Cleanup processed files
-	fileCount, filesRemoved, err := s.cleanupProcessedFiles()
+	files, err := s.cleanupProcessedFiles(dryRun, removed)
 	if err != nil {
 		s.logger.Error("cleanup", "Error cleaning up processed files: %v", err)
 	}
-	stats["processed_files_deleted"] = fileCount
-	stats["physical_files_removed"] = filesRemoved
+	report.add(files, err)
 
-	// Cleanup synced folders
-	folderCount, err := s.cleanupSyncedFolders()
+	folders, err := s.cleanupSyncedFolders(dryRun)
 	if err != nil {
 		s.logger.Error("cleanup", "Error cleaning up synced folders: %v", err)
 	}
-	stats["folders_deleted"] = folderCount
-
-	// Record completion and duration
-	duration := time.Since(start)
-	stats["duration"] = duration.String()
-	stats["duration_ms"] = duration.Milliseconds()
-
-	s.lastCleanup = time.Now()
-	stats["completed_at"] = s.lastCleanup.Format(time.RFC3339)
+	report.add(folders, err)
 
-	s.logger.Info("cleanup", "Data cleanup completed in %v", duration)
+	records, err := s.cleanupDataRecords(dryRun)
+	if err != nil {
+		s.logger.Error("cleanup", "Error cleaning up data records: %v", err)
+	}
+	report.add(records, err)
 
-	// Log cleanup summary to database
-	if s.logService != nil {
-		summary := fmt.Sprintf(
-			"Cleanup summary: removed %d logs, %d messages, %d processed files (%d actual files), %d folders",
-			logCount, msgCount, fileCount, filesRemoved, folderCount,
-		)
-		s.logService.LogMessage("INFO", "cleanup", summary)
+	// Size quotas only count what is left after the age-based cleanup
+	for _, quota := range s.enforceQuotas(dryRun, removed) {
+		report.addQuota(quota)
 	}
+
+	report.DurationMs = time.Since(report.StartedAt).Milliseconds()
+	return report
 }
 
 // cleanupLogs deletes old logs in database and files
-func (s *CleanupService) cleanupLogs() (int64, error) {
+func (s *CleanupService) cleanupLogs(dryRun bool, removed map[string]bool) (CategoryReport, error) {
+	report := CategoryReport{Category: CategoryLogs}
 	if s.config.LogRetention <= 0 {
-		return 0, nil // Log retention disabled
+		return report, nil // Log retention disabled
 	}
 
 	cutoffTime := time.Now().AddDate(0, 0, -s.config.LogRetention)
-	s.logger.Info("cleanup", "Cleaning up logs older than %s", cutoffTime.Format("2006-01-02"))
+	if !dryRun {
+		s.logger.Info("cleanup", "Cleaning up logs older than %s", cutoffTime.Format("2006-01-02"))
+	}
+
+	count, bytes, err := measureRows(
+		s.db.Model(&models.LogEntry{}).Where("timestamp < ?", cutoffTime),
+		"LENGTH(message) + LENGTH(component) + LENGTH(level)",
+	)
+	if err != nil {
+		return report, fmt.Errorf("failed to measure old logs: %w", err)
+	}
+	report.Records = count
+	report.RecordBytes = bytes
+
+	// Log files are only removed through the log service
+	if s.logService != nil {
+		oldFiles, err := s.logService.OldLogFiles(s.config.LogRetention)
+		if err != nil {
+			s.logger.Warning("cleanup", "Failed to list old log files: %v", err)
+		}
+		for _, file := range oldFiles {
+			removed[file.Path] = true
+			report.Files++
+			report.FileBytes += file.Size
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
 
 	// Use the log service if available, otherwise do a direct DB delete
 	if s.logService != nil {
 		// Also cleans up log files if configured
-		return s.logService.DeleteOldLogs(s.config.LogRetention)
+		deleted, err := s.logService.DeleteOldLogs(s.config.LogRetention)
+		report.Records = deleted
+		return report, err
 	}
 
 	// Direct DB delete
 	result := s.db.Where("timestamp < ?", cutoffTime).Delete(&models.LogEntry{})
 	if result.Error != nil {
-		return 0, fmt.Errorf("failed to delete old logs: %w", result.Error)
+		return report, fmt.Errorf("failed to delete old logs: %w", result.Error)
 	}
+	report.Records = result.RowsAffected
 
-	return result.RowsAffected, nil
+	return report, nil
 }
 
 // cleanupMessages deletes old messages
-func (s *CleanupService) cleanupMessages() (int64, error) {
+func (s *CleanupService) cleanupMessages(dryRun bool) (CategoryReport, error) {
+	report := CategoryReport{Category: CategoryMessages}
 	if s.config.MessageRetention <= 0 {
-		return 0, nil // Message retention disabled
+		return report, nil // Message retention disabled
 	}
 
+	const messageBytes = "LENGTH(payload) + LENGTH(topic) + COALESCE(LENGTH(extra_info), 0)"
+
 	cutoffTime := time.Now().AddDate(0, 0, -s.config.MessageRetention)
-	s.logger.Info("cleanup", "Cleaning up messages older than %s", cutoffTime.Format("2006-01-02"))
+	if !dryRun {
+		s.logger.Info("cleanup", "Cleaning up messages older than %s", cutoffTime.Format("2006-01-02"))
+	}
+
+	count, bytes, err := measureRows(
+		s.db.Model(&models.PendingMessage{}).Where("sent = ? AND timestamp < ?", true, cutoffTime),
+		messageBytes,
+	)
+	if err != nil {
+		return report, fmt.Errorf("failed to measure sent messages: %w", err)
+	}
+	report.Records = count
+	report.RecordBytes = bytes
 
-	sentResult := s.db.Where("sent = ? AND timestamp < ?", true, cutoffTime).Delete(&models.PendingMessage{})
-	if sentResult.Error != nil {
-		return 0, fmt.Errorf("failed to delete sent messages: %w", sentResult.Error)
+	if !dryRun {
+		sentResult := s.db.Where("sent = ? AND timestamp < ?", true, cutoffTime).Delete(&models.PendingMessage{})
+		if sentResult.Error != nil {
+			return report, fmt.Errorf("failed to delete sent messages: %w", sentResult.Error)
+		}
+		report.Records = sentResult.RowsAffected
 	}
 
-	// Second, check if we need to limit the number of pending messages
-	if s.config.MaxPendingMessages > 0 {
+	// Second, check if we need to limit the number of pending messages. Messages deferred
+	// while the license is expired are kept, they are sent once it is renewed.
+	if s.config.MaxPendingMessages > 0 && licensestate.Get(s.db).Degraded {
+		if !dryRun {
+			s.logger.Info("cleanup", "License expired, keeping all pending messages until it is renewed")
+		}
+	} else if s.config.MaxPendingMessages > 0 {
 		var pendingCount int64
 		if err := s.db.Model(&models.PendingMessage{}).Where("sent = ?", false).Count(&pendingCount).Error; err != nil {
 			s.logger.Error("cleanup", "Error counting pending messages: %v", err)
 		} else if pendingCount > int64(s.config.MaxPendingMessages) {
 			// We have too many pending messages, clean up the oldest ones
 			excessCount := pendingCount - int64(s.config.MaxPendingMessages)
-			s.logger.Info("cleanup", "Found %d pending messages, removing oldest %d", pendingCount, excessCount)
+			if !dryRun {
+				s.logger.Info("cleanup", "Found %d pending messages, removing oldest %d", pendingCount, excessCount)
+			}
 
 			// Get IDs of the oldest pending messages to delete
 			var oldestIDs []uint
@@ -256,78 +401,171 @@ func (s *CleanupService) cleanupMessages() (int64, error) {
 				Pluck("id", &oldestIDs).Error; err != nil {
 				s.logger.Error("cleanup", "Error identifying oldest messages: %v", err)
 			} else if len(oldestIDs) > 0 {
-				// Delete the oldest messages
-				if err := s.db.Delete(&models.PendingMessage{}, oldestIDs).Error; err != nil {
+				_, excessBytes, err := measureRows(
+					s.db.Model(&models.PendingMessage{}).Where("id IN ?", oldestIDs),
+					messageBytes,
+				)
+				if err != nil {
+					s.logger.Warning("cleanup", "Error measuring oldest messages: %v", err)
+				}
+
+				if dryRun {
+					report.Records += int64(len(oldestIDs))
+					report.RecordBytes += excessBytes
+				} else if err := s.db.Delete(&models.PendingMessage{}, oldestIDs).Error; err != nil {
+					// Delete the oldest messages
 					s.logger.Error("cleanup", "Error deleting oldest messages: %v", err)
 				} else {
 					s.logger.Info("cleanup", "Deleted %d oldest pending messages", len(oldestIDs))
-					sentResult.RowsAffected += int64(len(oldestIDs))
+					report.Records += int64(len(oldestIDs))
+					report.RecordBytes += excessBytes
 				}
 			}
 		}
 	}
 
-	return sentResult.RowsAffected, nil
+	return report, nil
 }
 
 // cleanupProcessedFiles deletes old processed files and their physical files
-func (s *CleanupService) cleanupProcessedFiles() (int64, int, error) {
+func (s *CleanupService) cleanupProcessedFiles(dryRun bool, removed map[string]bool) (CategoryReport, error) {
+	report := CategoryReport{Category: CategoryProcessedFiles}
 	if s.config.ProcessedFileRetention <= 0 {
-		return 0, 0, nil // Processed file retention disabled
+		return report, nil // Processed file retention disabled
 	}
 
 	cutoffTime := time.Now().AddDate(0, 0, -s.config.ProcessedFileRetention)
-	s.logger.Info("cleanup", "Cleaning up processed files older than %s", cutoffTime.Format("2006-01-02"))
+	if !dryRun {
+		s.logger.Info("cleanup", "Cleaning up processed files older than %s", cutoffTime.Format("2006-01-02"))
+	}
 
 	// First, get the list of files to delete so we can remove the physical files
 	var filesToDelete []models.ProcessedFile
 	if err := s.db.Where("processed_at < ?", cutoffTime).Find(&filesToDelete).Error; err != nil {
-		return 0, 0, fmt.Errorf("failed to query processed files: %w", err)
+		return report, fmt.Errorf("failed to query processed files: %w", err)
 	}
 
-	// Delete the database records
-	result := s.db.Where("processed_at < ?", cutoffTime).Delete(&models.ProcessedFile{})
-	if result.Error != nil {
-		return 0, 0, fmt.Errorf("failed to delete processed files: %w", result.Error)
+	report.Records = int64(len(filesToDelete))
+	for _, file := range filesToDelete {
+		report.RecordBytes += int64(len(file.Filename) + len(file.DateFolder) + len(file.DataJSON))
+	}
+
+	if !dryRun {
+		// Delete the database records
+		result := s.db.Where("processed_at < ?", cutoffTime).Delete(&models.ProcessedFile{})
+		if result.Error != nil {
+			return report, fmt.Errorf("failed to delete processed files: %w", result.Error)
+		}
+		report.Records = result.RowsAffected
 	}
 
 	// Delete the physical files
-	filesRemoved := 0
 	for _, file := range filesToDelete {
 		// Build the file path
 		filePath := filepath.Join(s.config.DataDirectory, file.Filename)
 
-		// Check if the file exists
-		if _, err := os.Stat(filePath); err == nil {
-			// File exists, delete it
-			if err := os.Remove(filePath); err != nil {
-				s.logger.Error("cleanup", "Failed to delete file %s: %v", filePath, err)
-			} else {
-				filesRemoved++
+		// Check if the file exists, either plain or compressed while it was waiting
+		for _, path := range []string{filePath, filePath + ".zst"} {
+			size, ok := fileSize(path)
+			if !ok {
+				continue
+			}
+
+			if !dryRun {
+				// File exists, delete it
+				if err := os.Remove(path); err != nil {
+					s.logger.Error("cleanup", "Failed to delete file %s: %v", path, err)
+					continue
+				}
 			}
+			removed[path] = true
+			report.Files++
+			report.FileBytes += size
 		}
 	}
 
-	s.logger.Info("cleanup", "Deleted %d database entries and %d physical files", result.RowsAffected, filesRemoved)
-	return result.RowsAffected, filesRemoved, nil
+	if !dryRun {
+		s.logger.Info("cleanup", "Deleted %d database entries and %d physical files (%d bytes)", report.Records, report.Files, report.FileBytes)
+	}
+	return report, nil
 }
 
 // cleanupSyncedFolders deletes old synced folders that are not fully synced
-func (s *CleanupService) cleanupSyncedFolders() (int64, error) {
+func (s *CleanupService) cleanupSyncedFolders(dryRun bool) (CategoryReport, error) {
+	report := CategoryReport{Category: CategorySyncedFolders}
 	if s.config.SyncedFolderRetention <= 0 {
-		return 0, nil // Synced folder retention disabled
+		return report, nil // Synced folder retention disabled
 	}
 
 	cutoffTime := time.Now().AddDate(0, 0, -s.config.SyncedFolderRetention)
-	s.logger.Info("cleanup", "Cleaning up not-fully-synced folders older than %s", cutoffTime.Format("2006-01-02"))
+	if !dryRun {
+		s.logger.Info("cleanup", "Cleaning up not-fully-synced folders older than %s", cutoffTime.Format("2006-01-02"))
+	}
 
 	// Only delete folders that are not fully synced and haven't been checked recently
+	count, bytes, err := measureRows(
+		s.db.Model(&models.SyncedFolder{}).Where("fully_synced = ? AND last_checked < ?", false, cutoffTime),
+		"LENGTH(folder_name)",
+	)
+	if err != nil {
+		return report, fmt.Errorf("failed to measure synced folders: %w", err)
+	}
+	report.Records = count
+	report.RecordBytes = bytes
+
+	if dryRun {
+		return report, nil
+	}
+
 	result := s.db.Where("fully_synced = ? AND last_checked < ?", false, cutoffTime).Delete(&models.SyncedFolder{})
 	if result.Error != nil {
-		return 0, fmt.Errorf("failed to delete synced folders: %w", result.Error)
+		return report, fmt.Errorf("failed to delete synced folders: %w", result.Error)
+	}
+	report.Records = result.RowsAffected
+
+	return report, nil
+}
+
+// cleanupDataRecords deletes cached data records older than the retention
+func (s *CleanupService) cleanupDataRecords(dryRun bool) (CategoryReport, error) {
+	report := CategoryReport{Category: CategoryDataRecords}
+	if s.config.DataRecordRetention <= 0 {
+		return report, nil // Data record retention disabled
+	}
+
+	cutoffTime := time.Now().AddDate(0, 0, -s.config.DataRecordRetention)
+	if !dryRun {
+		s.logger.Info("cleanup", "Cleaning up data records older than %s", cutoffTime.Format("2006-01-02"))
+	}
+
+	count, bytes, err := measureRows(
+		s.db.Model(&models.DataRecord{}).Where("stored_at < ?", cutoffTime),
+		"LENGTH(filename) + LENGTH(date_folder) + LENGTH(entry_id) + LENGTH(device_id) + LENGTH(device_timestamp) + LENGTH(start_time)",
+	)
+	if err != nil {
+		return report, fmt.Errorf("failed to measure data records: %w", err)
+	}
+	report.Records = count
+	report.RecordBytes = bytes
+
+	if dryRun {
+		return report, nil
+	}
+
+	result := s.db.Where("stored_at < ?", cutoffTime).Delete(&models.DataRecord{})
+	if result.Error != nil {
+		return report, fmt.Errorf("failed to delete data records: %w", result.Error)
 	}
+	report.Records = result.RowsAffected
+
+	return report, nil
+}
 
-	return result.RowsAffected, nil
+// GetConfig returns a copy of the cleanup configuration
+func (s *CleanupService) GetConfig() Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.config
 }
 
 // GetStatus returns the current status of the cleanup service
@@ -338,64 +576,80 @@ func (s *CleanupService) GetStatus() map[string]interface{} {
 	status := map[string]interface{}{
 		"enabled":  s.config.Enabled,
 		"running":  s.running,
-		"interval": s.config.Interval.String(),
+		"schedule": s.config.Schedule,
 		"retention": map[string]interface{}{
 			"logs":            s.config.LogRetention,
 			"messages":        s.config.MessageRetention,
 			"processed_files": s.config.ProcessedFileRetention,
 			"synced_folders":  s.config.SyncedFolderRetention,
+			"data_records":    s.config.DataRecordRetention,
 		},
 		"limits": map[string]interface{}{
 			"max_log_files":        s.config.MaxLogFiles,
 			"max_pending_messages": s.config.MaxPendingMessages,
 		},
+		"quotas": map[string]interface{}{
+			"log_quota_mb":       s.config.LogQuotaMB,
+			"data_file_quota_mb": s.config.DataFileQuotaMB,
+		},
+		"data_directory": s.config.DataDirectory,
 	}
 
 	if !s.lastCleanup.IsZero() {
 		status["last_cleanup"] = s.lastCleanup.Format(time.RFC3339)
-		status["next_cleanup"] = s.lastCleanup.Add(s.config.Interval).Format(time.RFC3339)
+	}
+	if s.jobs != nil && s.running {
+		if next := s.jobs.NextRun(JobName); !next.IsZero() {
+			status["next_cleanup"] = next.Format(time.RFC3339)
+		}
+	}
+	if s.lastReport != nil {
+		status["last_report"] = *s.lastReport
 	}
 
 	return status
 }
 
-// ForceCleanup triggers an immediate cleanup
+// ForceCleanup triggers an immediate cleanup through the scheduler, so it does not overlap
+// a scheduled cleanup or another job on the data files
 func (s *CleanupService) ForceCleanup() error {
 	s.logger.Info("cleanup", "Forced cleanup triggered manually")
-	go s.runCleanup()
-	return nil
+
+	s.mu.Lock()
+	jobs := s.jobs
+	s.mu.Unlock()
+
+	if jobs == nil {
+		go s.runCleanup()
+		return nil
+	}
+	_, err := jobs.RunNow(JobName)
+	return err
 }
 
 // UpdateConfig updates the cleanup configuration
 func (s *CleanupService) UpdateConfig(newConfig *Config) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if newConfig.Interval < time.Minute {
-		return fmt.Errorf("cleanup interval must be at least 1 minute")
+	if _, err := scheduler.Parse(newConfig.Schedule); err != nil {
+		return fmt.Errorf("invalid cleanup schedule: %w", err)
 	}
 
+	s.mu.Lock()
 	s.logger.Info("cleanup", "Updating cleanup configuration")
 
-	// Store the old interval to check if we need to restart the ticker
-	oldInterval := s.config.Interval
-	oldEnabled := s.config.Enabled
-
-	// Update the configuration
+	oldSchedule := s.config.Schedule
 	s.config = newConfig
+	jobs := s.jobs
+	s.mu.Unlock()
 
-	// If the service was running and the interval changed, restart it
-	if s.running && (oldInterval != newConfig.Interval || oldEnabled != newConfig.Enabled) {
-		s.Stop()
-		if newConfig.Enabled {
-			s.Start()
+	if jobs != nil && oldSchedule != newConfig.Schedule {
+		if err := jobs.Reschedule(JobName, newConfig.Schedule); err != nil {
+			return err
 		}
 	}
 
-	// If the service was not running and is now enabled, start it
-	if !s.running && newConfig.Enabled {
-		s.Start()
+	// Start dan Stop mengunci mutex sendiri
+	if newConfig.Enabled {
+		return s.Start()
 	}
-
-	return nil
+	return s.Stop()
 }
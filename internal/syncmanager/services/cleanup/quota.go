@@ -0,0 +1,228 @@
+package cleanup
+
+import (
+	"fmt"
+	"jarvist/internal/common/models"
+	"jarvist/internal/syncmanager/datafile"
+	"jarvist/pkg/logger"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Quota categories
+const (
+	QuotaLogs      = "logs"
+	QuotaDataFiles = "data_files"
+)
+
+const bytesPerMB = 1024 * 1024
+
+// QuotaReport describes the enforcement of a size quota. Files that may not be deleted yet,
+// like data files that are not sent, count as protected.
+type QuotaReport struct {
+	Category       string `json:"category"`
+	LimitBytes     int64  `json:"limit_bytes"`
+	UsedBytes      int64  `json:"used_bytes"`
+	ReclaimedBytes int64  `json:"reclaimed_bytes"`
+	FilesDeleted   int    `json:"files_deleted"`
+	ProtectedBytes int64  `json:"protected_bytes"`
+	Exceeded       bool   `json:"exceeded"`
+	Error          string `json:"error,omitempty"`
+}
+
+// quotaFile is a file counted against a quota
+type quotaFile struct {
+	path      string
+	size      int64
+	sortKey   string
+	modTime   time.Time
+	deletable bool
+}
+
+// enforceQuotas deletes the oldest files of each category over its quota. Files already
+// removed by the age-based cleanup are not counted.
+func (s *CleanupService) enforceQuotas(dryRun bool, removed map[string]bool) []QuotaReport {
+	var reports []QuotaReport
+
+	if s.config.LogQuotaMB > 0 && s.logService != nil {
+		files, err := s.logQuotaFiles(removed)
+		reports = append(reports, s.enforceQuota(QuotaLogs, int64(s.config.LogQuotaMB)*bytesPerMB, files, err, dryRun))
+	}
+
+	if s.config.DataFileQuotaMB > 0 {
+		files, err := s.dataQuotaFiles(removed)
+		reports = append(reports, s.enforceQuota(QuotaDataFiles, int64(s.config.DataFileQuotaMB)*bytesPerMB, files, err, dryRun))
+	}
+
+	return reports
+}
+
+func (s *CleanupService) enforceQuota(category string, limit int64, files []quotaFile, listErr error, dryRun bool) QuotaReport {
+	report := QuotaReport{Category: category, LimitBytes: limit}
+	if listErr != nil {
+		report.Error = listErr.Error()
+		s.logger.Error("cleanup", "Failed to list files for %s quota: %v", category, listErr)
+		return report
+	}
+
+	for _, file := range files {
+		report.UsedBytes += file.size
+	}
+	if report.UsedBytes <= limit {
+		return report
+	}
+
+	// Oldest first
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].sortKey != files[j].sortKey {
+			return files[i].sortKey < files[j].sortKey
+		}
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	used := report.UsedBytes
+	for _, file := range files {
+		if used <= limit {
+			break
+		}
+		if !file.deletable {
+			report.ProtectedBytes += file.size
+			continue
+		}
+
+		if !dryRun {
+			if err := os.Remove(file.path); err != nil {
+				s.logger.Warning("cleanup", "Failed to delete %s for %s quota: %v", file.path, category, err)
+				continue
+			}
+		}
+		used -= file.size
+		report.ReclaimedBytes += file.size
+		report.FilesDeleted++
+	}
+	report.Exceeded = used > limit
+
+	if dryRun {
+		return report
+	}
+
+	if report.FilesDeleted > 0 {
+		s.logger.Event(logger.LevelWarn, "cleanup", logger.EventCleanupQuotaEnforced,
+			logger.F("category", category), logger.F("used", report.UsedBytes), logger.F("limit", limit),
+			logger.F("files", report.FilesDeleted), logger.F("reclaimed", report.ReclaimedBytes))
+	}
+	if report.Exceeded {
+		s.logger.Event(logger.LevelWarn, "cleanup", logger.EventCleanupQuotaExceeded,
+			logger.F("category", category), logger.F("used", used), logger.F("limit", limit),
+			logger.F("protected", report.ProtectedBytes))
+	}
+
+	return report
+}
+
+// logQuotaFiles returns the log files, the newest file is kept because it is still written
+func (s *CleanupService) logQuotaFiles(removed map[string]bool) ([]quotaFile, error) {
+	logFiles, err := s.logService.LogFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var newest string
+	var newestTime time.Time
+	for _, file := range logFiles {
+		if file.ModTime.After(newestTime) {
+			newest, newestTime = file.Path, file.ModTime
+		}
+	}
+
+	var files []quotaFile
+	for _, file := range logFiles {
+		if removed[file.Path] {
+			continue
+		}
+		files = append(files, quotaFile{
+			path:      file.Path,
+			size:      file.Size,
+			modTime:   file.ModTime,
+			deletable: file.Path != newest,
+		})
+	}
+	return files, nil
+}
+
+// dataQuotaFiles returns the data files of all date folders. Only files that are processed
+// and whose message was sent may be deleted, anything else would lose data.
+func (s *CleanupService) dataQuotaFiles(removed map[string]bool) ([]quotaFile, error) {
+	entries, err := os.ReadDir(s.config.DataDirectory)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// File yang masih di journal belum terkonfirmasi terkirim
+	var unconfirmed []string
+	if err := s.db.Model(&models.SyncJournal{}).Where("state <> ?", "confirmed").Pluck("filename", &unconfirmed).Error; err != nil {
+		return nil, fmt.Errorf("failed to read sync journal: %w", err)
+	}
+	pending := make(map[string]bool, len(unconfirmed))
+	for _, filename := range unconfirmed {
+		pending[filename] = true
+	}
+
+	var files []quotaFile
+	for _, entry := range entries {
+		if !entry.IsDir() || !isDateFolder(entry.Name()) {
+			continue
+		}
+		folderName := entry.Name()
+
+		var processed []string
+		if err := s.db.Model(&models.ProcessedFile{}).Where("date_folder = ?", folderName).Pluck("filename", &processed).Error; err != nil {
+			return nil, fmt.Errorf("failed to read processed files of %s: %w", folderName, err)
+		}
+		done := make(map[string]bool, len(processed))
+		for _, filename := range processed {
+			done[filename] = true
+		}
+
+		folderEntries, err := os.ReadDir(filepath.Join(s.config.DataDirectory, folderName))
+		if err != nil {
+			s.logger.Warning("cleanup", "Failed to read folder %s: %v", folderName, err)
+			continue
+		}
+
+		for _, fileEntry := range folderEntries {
+			if fileEntry.IsDir() || !datafile.IsDataFile(fileEntry.Name()) {
+				continue
+			}
+			path := filepath.Join(s.config.DataDirectory, folderName, fileEntry.Name())
+			if removed[path] {
+				continue
+			}
+			info, err := fileEntry.Info()
+			if err != nil {
+				continue
+			}
+
+			relPath := filepath.Join(folderName, datafile.LogicalName(fileEntry.Name()))
+			files = append(files, quotaFile{
+				path:      path,
+				size:      info.Size(),
+				sortKey:   folderName,
+				modTime:   info.ModTime(),
+				deletable: done[relPath] && !pending[relPath],
+			})
+		}
+	}
+	return files, nil
+}
+
+// isDateFolder reports whether name is a YYYYMMDD folder
+func isDateFolder(name string) bool {
+	_, err := time.Parse("20060102", name)
+	return err == nil && len(name) == 8
+}
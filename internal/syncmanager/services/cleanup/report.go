@@ -0,0 +1,79 @@
+package cleanup
+
+import (
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Cleanup categories
+const (
+	CategoryLogs           = "logs"
+	CategoryMessages       = "messages"
+	CategoryProcessedFiles = "processed_files"
+	CategorySyncedFolders  = "synced_folders"
+	CategoryDataRecords    = "data_records"
+)
+
+// CategoryReport describes what a cleanup removes, or would remove in a dry run, from one
+// category. RecordBytes estimates the row data from the column lengths, SQLite only returns
+// that space to the disk after a VACUUM.
+type CategoryReport struct {
+	Category    string `json:"category"`
+	Records     int64  `json:"records"`
+	Files       int    `json:"files"`
+	FileBytes   int64  `json:"file_bytes"`
+	RecordBytes int64  `json:"record_bytes"`
+	Bytes       int64  `json:"bytes"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Report is the result of a cleanup run or preview
+type Report struct {
+	DryRun       bool             `json:"dry_run"`
+	Categories   []CategoryReport `json:"categories"`
+	Quotas       []QuotaReport    `json:"quotas"`
+	TotalRecords int64            `json:"total_records"`
+	TotalFiles   int              `json:"total_files"`
+	TotalBytes   int64            `json:"total_bytes"`
+	StartedAt    time.Time        `json:"started_at"`
+	DurationMs   int64            `json:"duration_ms"`
+}
+
+func (r *Report) add(category CategoryReport, err error) {
+	if err != nil {
+		category.Error = err.Error()
+	}
+	category.Bytes = category.FileBytes + category.RecordBytes
+
+	r.Categories = append(r.Categories, category)
+	r.TotalRecords += category.Records
+	r.TotalFiles += category.Files
+	r.TotalBytes += category.Bytes
+}
+
+func (r *Report) addQuota(quota QuotaReport) {
+	r.Quotas = append(r.Quotas, quota)
+	r.TotalFiles += quota.FilesDeleted
+	r.TotalBytes += quota.ReclaimedBytes
+}
+
+// measureRows counts the rows of query and sums the length of the given columns
+func measureRows(query *gorm.DB, lengthExpr string) (int64, int64, error) {
+	var result struct {
+		Count int64
+		Bytes int64
+	}
+	err := query.Select("COUNT(*) AS count, COALESCE(SUM(" + lengthExpr + "), 0) AS bytes").Scan(&result).Error
+	return result.Count, result.Bytes, err
+}
+
+// fileSize returns the size of a file, ok is false if it does not exist
+func fileSize(path string) (int64, bool) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return 0, false
+	}
+	return info.Size(), true
+}
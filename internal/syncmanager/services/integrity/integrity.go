@@ -0,0 +1,449 @@
+package integrity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"jarvist/internal/syncmanager/datafile"
+	"jarvist/internal/syncmanager/scheduler"
+	"jarvist/pkg/logger"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Quarantine reasons
+const (
+	ReasonEmpty         = "empty"
+	ReasonTruncated     = "truncated"
+	ReasonUndecryptable = "undecryptable"
+	ReasonCorrupt       = "corrupt"
+)
+
+const (
+	QuarantineDirName = "quarantine"
+	CommandsDirName   = "commands"
+)
+
+// Config holds configuration for the integrity scanner
+type Config struct {
+	Enabled         bool          // Whether scanning is enabled
+	Schedule        string        // Cron schedule of the scan job
+	MinFileAge      time.Duration // Skip files younger than this, they may still be written
+	RequestReexport bool          // Write a re-export command for the counter after quarantining
+	DataDirectory   string        // Base directory for data files
+	FernetKey       string        // Key used to verify files
+}
+
+// DefaultConfig returns the default integrity scanner configuration
+func DefaultConfig() *Config {
+	return &Config{
+		Enabled:         true,
+		Schedule:        "*/30 * * * *",
+		MinFileAge:      5 * time.Minute,
+		RequestReexport: false,
+		DataDirectory:   "./data",
+	}
+}
+
+// QuarantineRecord is written next to each quarantined file as <file>.reason.json
+type QuarantineRecord struct {
+	File         string    `json:"file"`
+	DateFolder   string    `json:"date_folder"`
+	Reason       string    `json:"reason"`
+	Detail       string    `json:"detail"`
+	Size         int64     `json:"size"`
+	OriginalPath string    `json:"original_path"`
+	DetectedAt   time.Time `json:"detected_at"`
+}
+
+// JobName is the name of the scan job in the scheduler
+const JobName = "integrity_scan"
+
+// IntegrityService periodically checks unprocessed data files and quarantines broken ones
+type IntegrityService struct {
+	db          *gorm.DB
+	logger      *logger.Logger
+	config      *Config
+	jobs        *scheduler.Scheduler
+	running     bool
+	scanning    bool
+	lastScan    time.Time
+	lastScanned int
+	counts      map[string]int
+	mu          sync.Mutex
+}
+
+// NewIntegrityService creates a new integrity scanner
+func NewIntegrityService(db *gorm.DB, logger *logger.Logger, config *Config) *IntegrityService {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	return &IntegrityService{
+		db:     db,
+		logger: logger,
+		config: config,
+		counts: make(map[string]int),
+	}
+}
+
+// Name returns the component name used in startup logs
+func (s *IntegrityService) Name() string {
+	return "Integrity scanner"
+}
+
+// RegisterJobs adds the scan job to the scheduler, it also runs when the scheduler starts
+func (s *IntegrityService) RegisterJobs(jobs *scheduler.Scheduler) error {
+	s.mu.Lock()
+	s.jobs = jobs
+	schedule := s.config.Schedule
+	s.mu.Unlock()
+
+	return jobs.Add(scheduler.Job{
+		Name:       JobName,
+		Schedule:   schedule,
+		Jitter:     time.Minute,
+		Singleton:  true,
+		Locks:      []string{scheduler.LockDataFiles},
+		RunOnStart: true,
+		Run:        s.runJob,
+	})
+}
+
+// Start enables the scheduled scan
+func (s *IntegrityService) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return nil
+	}
+
+	if !s.config.Enabled {
+		s.logger.Info("integrity", "Integrity scanner is disabled")
+		return nil
+	}
+
+	s.running = true
+	s.loadCounts()
+
+	s.logger.Info("integrity", "Integrity scanner started (schedule: %s)", s.config.Schedule)
+	return nil
+}
+
+// Stop disables the scheduled scan
+func (s *IntegrityService) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return nil
+	}
+
+	s.running = false
+
+	s.logger.Info("integrity", "Integrity scanner stopped")
+	return nil
+}
+
+// runJob is the scheduled scan, it is skipped while the scanner is stopped
+func (s *IntegrityService) runJob(ctx context.Context) error {
+	s.mu.Lock()
+	running := s.running
+	s.mu.Unlock()
+
+	if !running {
+		return scheduler.ErrSkipped
+	}
+	s.Scan()
+	return nil
+}
+
+// Scan checks all unprocessed data files once and returns the number quarantined
+func (s *IntegrityService) Scan() int {
+	s.mu.Lock()
+	if s.scanning {
+		s.mu.Unlock()
+		return 0
+	}
+	s.scanning = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.scanning = false
+		s.mu.Unlock()
+	}()
+
+	processedFiles := make(map[string]bool)
+	var files []models.ProcessedFile
+	if err := s.db.Select("filename").Find(&files).Error; err != nil {
+		s.logger.Error("integrity", "Failed to query processed files: %v", err)
+		return 0
+	}
+	for _, file := range files {
+		processedFiles[file.Filename] = true
+	}
+
+	folders, err := dateFolders(s.config.DataDirectory)
+	if err != nil {
+		s.logger.Error("integrity", "Failed to list data directory: %v", err)
+		return 0
+	}
+
+	cutoff := time.Now().Add(-s.config.MinFileAge)
+	scanned, quarantined := 0, 0
+
+	for _, folder := range folders {
+		folderName := filepath.Base(folder)
+
+		entries, err := os.ReadDir(folder)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || !datafile.IsDataFile(entry.Name()) {
+				continue
+			}
+			if processedFiles[filepath.Join(folderName, datafile.LogicalName(entry.Name()))] {
+				continue
+			}
+
+			info, err := entry.Info()
+			if err != nil || info.ModTime().After(cutoff) {
+				continue
+			}
+
+			scanned++
+			filePath := filepath.Join(folder, entry.Name())
+
+			reason, detail := s.checkFile(filePath, info.Size())
+			if reason == "" {
+				continue
+			}
+
+			if err := s.quarantine(filePath, folderName, reason, detail, info.Size()); err != nil {
+				s.logger.Error("integrity", "Failed to quarantine %s: %v", filePath, err)
+				continue
+			}
+			quarantined++
+		}
+	}
+
+	s.mu.Lock()
+	s.lastScan = time.Now()
+	s.lastScanned = scanned
+	s.mu.Unlock()
+
+	if quarantined > 0 {
+		s.logger.Warning("integrity", "Integrity scan quarantined %d of %d files", quarantined, scanned)
+	} else {
+		s.logger.Debug("integrity", "Integrity scan checked %d files, all valid", scanned)
+	}
+
+	return quarantined
+}
+
+// checkFile returns an empty reason if the file decodes correctly
+func (s *IntegrityService) checkFile(filePath string, size int64) (string, string) {
+	if size == 0 {
+		return ReasonEmpty, "zero-byte file"
+	}
+
+	_, err := datafile.ReadAndDecode(filePath, s.config.FernetKey)
+	switch {
+	case err == nil:
+		return "", ""
+	case errors.Is(err, datafile.ErrEmpty):
+		return ReasonEmpty, err.Error()
+	case errors.Is(err, datafile.ErrTruncated):
+		return ReasonTruncated, err.Error()
+	case errors.Is(err, datafile.ErrUndecryptable):
+		return ReasonUndecryptable, err.Error()
+	case errors.Is(err, datafile.ErrCorrupt):
+		return ReasonCorrupt, err.Error()
+	default:
+		// I/O errors (e.g. file locked by the counter) are retried on the next scan
+		s.logger.Debug("integrity", "Skipping %s: %v", filePath, err)
+		return "", ""
+	}
+}
+
+// quarantine moves a broken file to quarantine/<date folder>/ with a reason file
+func (s *IntegrityService) quarantine(filePath, folderName, reason, detail string, size int64) error {
+	quarantineDir := filepath.Join(s.config.DataDirectory, QuarantineDirName, folderName)
+	if err := os.MkdirAll(quarantineDir, 0755); err != nil {
+		return fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+
+	fileName := filepath.Base(filePath)
+	target := filepath.Join(quarantineDir, fileName)
+	if err := os.Rename(filePath, target); err != nil {
+		return fmt.Errorf("failed to move file: %w", err)
+	}
+
+	record := QuarantineRecord{
+		File:         datafile.LogicalName(fileName),
+		DateFolder:   folderName,
+		Reason:       reason,
+		Detail:       detail,
+		Size:         size,
+		OriginalPath: filePath,
+		DetectedAt:   time.Now(),
+	}
+
+	if err := writeJSON(target+".reason.json", record); err != nil {
+		s.logger.Warning("integrity", "Failed to write reason file for %s: %v", fileName, err)
+	}
+
+	s.mu.Lock()
+	s.counts[reason]++
+	s.mu.Unlock()
+
+	s.logger.Event(logger.LevelWarn, "integrity", logger.EventIntegrityQuarantined,
+		logger.F("folder", folderName), logger.F("filename", fileName), logger.F("reason", reason), logger.F("detail", detail))
+
+	if s.config.RequestReexport {
+		if err := s.requestReexport(record); err != nil {
+			s.logger.Warning("integrity", "Failed to request re-export of %s: %v", fileName, err)
+		}
+	}
+
+	return nil
+}
+
+// requestReexport drops a command file that the counter picks up to export the file again
+func (s *IntegrityService) requestReexport(record QuarantineRecord) error {
+	commandsDir := filepath.Join(s.config.DataDirectory, CommandsDirName)
+	if err := os.MkdirAll(commandsDir, 0755); err != nil {
+		return err
+	}
+
+	command := map[string]interface{}{
+		"command":     "reexport",
+		"date_folder": record.DateFolder,
+		"file":        record.File,
+		"reason":      record.Reason,
+		"requested":   time.Now().Format(time.RFC3339),
+	}
+
+	name := fmt.Sprintf("reexport_%d.json", time.Now().UnixNano())
+	return writeJSON(filepath.Join(commandsDir, name), command)
+}
+
+// loadCounts rebuilds the per-reason counts from existing reason files
+func (s *IntegrityService) loadCounts() {
+	pattern := filepath.Join(s.config.DataDirectory, QuarantineDirName, "*", "*.reason.json")
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return
+	}
+
+	counts := make(map[string]int)
+	for _, match := range matches {
+		data, err := os.ReadFile(match)
+		if err != nil {
+			continue
+		}
+		var record QuarantineRecord
+		if err := json.Unmarshal(data, &record); err == nil {
+			counts[record.Reason]++
+		}
+	}
+	s.counts = counts
+}
+
+// GetCounts returns the number of quarantined files per reason
+func (s *IntegrityService) GetCounts() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[string]int, len(s.counts))
+	for reason, count := range s.counts {
+		counts[reason] = count
+	}
+	return counts
+}
+
+// GetStatus returns the current status of the integrity scanner
+func (s *IntegrityService) GetStatus() map[string]interface{} {
+	counts := s.GetCounts()
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := map[string]interface{}{
+		"enabled":          s.config.Enabled,
+		"running":          s.running,
+		"schedule":         s.config.Schedule,
+		"request_reexport": s.config.RequestReexport,
+		"quarantined":      total,
+		"by_reason":        counts,
+		"last_scanned":     s.lastScanned,
+	}
+
+	if !s.lastScan.IsZero() {
+		status["last_scan"] = s.lastScan.Format(time.RFC3339)
+	}
+	if s.jobs != nil && s.running {
+		if next := s.jobs.NextRun(JobName); !next.IsZero() {
+			status["next_scan"] = next.Format(time.RFC3339)
+		}
+	}
+
+	return status
+}
+
+// ForceScan triggers an immediate scan through the scheduler, so it does not overlap a
+// cleanup of the data files
+func (s *IntegrityService) ForceScan() error {
+	s.logger.Info("integrity", "Forced integrity scan triggered manually")
+
+	s.mu.Lock()
+	jobs := s.jobs
+	s.mu.Unlock()
+
+	if jobs == nil {
+		go s.Scan()
+		return nil
+	}
+	_, err := jobs.RunNow(JobName)
+	return err
+}
+
+func dateFolders(dataDir string) ([]string, error) {
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var folders []string
+	for _, entry := range entries {
+		if !entry.IsDir() || len(entry.Name()) != 8 {
+			continue
+		}
+		if _, err := time.Parse("20060102", entry.Name()); err == nil {
+			folders = append(folders, filepath.Join(dataDir, entry.Name()))
+		}
+	}
+	return folders, nil
+}
+
+func writeJSON(path string, data interface{}) error {
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0644)
+}
@@ -135,14 +135,33 @@ func (s *LogService) DeleteOldLogs(daysToKeep int) (int64, error) {
 
 // cleanupLogFiles removes old log files beyond maxLogFiles or older than daysToKeep
 func (s *LogService) cleanupLogFiles(daysToKeep int) error {
+	filesToDelete, err := s.OldLogFiles(daysToKeep)
+	if err != nil {
+		return err
+	}
+
+	// Delete the files
+	for _, file := range filesToDelete {
+		if err := os.Remove(file.Path); err != nil {
+			// Log but continue with other files
+			fmt.Printf("Error deleting log file %s: %v\n", file.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// OldLogFiles returns the log files older than daysToKeep or beyond maxLogFiles, the
+// files cleanupLogFiles would remove
+func (s *LogService) OldLogFiles(daysToKeep int) ([]LogFileInfo, error) {
 	if s.logDir == "" {
-		return nil // No log directory specified
+		return nil, nil // No log directory specified
 	}
 
 	// Get all log files
 	files, err := s.findLogFiles()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Sort by modification time (newest first)
@@ -153,44 +172,16 @@ func (s *LogService) cleanupLogFiles(daysToKeep int) error {
 	// Cutoff time for old files
 	cutoffTime := time.Now().AddDate(0, 0, -daysToKeep)
 
-	// Keep track of how many files to delete
-	var filesToDelete []string
-
-	// First identify files to delete based on age
-	for _, file := range files {
-		if file.ModTime.Before(cutoffTime) {
-			filesToDelete = append(filesToDelete, file.Path)
-		}
-	}
-
-	// Then identify files to delete based on count limit
-	// (but only if we have more than the limit)
-	if len(files) > s.maxLogFiles {
-		for i := s.maxLogFiles; i < len(files); i++ {
-			// Check if this file isn't already marked for deletion
-			alreadyMarked := false
-			for _, path := range filesToDelete {
-				if path == files[i].Path {
-					alreadyMarked = true
-					break
-				}
-			}
-
-			if !alreadyMarked {
-				filesToDelete = append(filesToDelete, files[i].Path)
-			}
-		}
-	}
-
-	// Delete the files
-	for _, path := range filesToDelete {
-		if err := os.Remove(path); err != nil {
-			// Log but continue with other files
-			fmt.Printf("Error deleting log file %s: %v\n", path, err)
+	var filesToDelete []LogFileInfo
+	for i, file := range files {
+		// Files are deleted by age, and beyond the count limit (but only if we have more
+		// than the limit)
+		if file.ModTime.Before(cutoffTime) || (len(files) > s.maxLogFiles && i >= s.maxLogFiles) {
+			filesToDelete = append(filesToDelete, file)
 		}
 	}
 
-	return nil
+	return filesToDelete, nil
 }
 
 // LogFileInfo represents information about a log file
@@ -200,6 +191,14 @@ type LogFileInfo struct {
 	Size    int64
 }
 
+// LogFiles returns all log files in the log directory
+func (s *LogService) LogFiles() ([]LogFileInfo, error) {
+	if s.logDir == "" {
+		return nil, nil
+	}
+	return s.findLogFiles()
+}
+
 // findLogFiles finds all log files in the log directory
 func (s *LogService) findLogFiles() ([]LogFileInfo, error) {
 	var files []LogFileInfo
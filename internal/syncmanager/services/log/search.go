@@ -0,0 +1,265 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"jarvist/internal/common/redact"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// searchBatch is the number of rows read per query while scanning
+	searchBatch = 500
+	// maxSearchScan bounds the rows one search request reads, the cursor continues from there
+	maxSearchScan = 20000
+	// maxHistogramScan bounds the rows counted into the buckets of one request
+	maxHistogramScan = 200000
+	// maxBuckets bounds the buckets of one histogram
+	maxBuckets = 1000
+	// maxPatternLength bounds the length of a search text or pattern
+	maxPatternLength = 256
+)
+
+// SearchQuery filters the log entries. Text is matched against the redacted message, as
+// a case-insensitive substring or as a regular expression.
+type SearchQuery struct {
+	Text       string
+	Regex      bool
+	Levels     []string
+	Components []string
+	Start      time.Time
+	End        time.Time
+	// Cursor continues a previous search, it is the next_cursor of that result
+	Cursor string
+	Limit  int
+}
+
+// SearchResult is one page of matching log entries, newest first
+type SearchResult struct {
+	Logs []models.LogEntry
+	// NextCursor is empty when there are no older entries
+	NextCursor string
+	// Scanned is the number of entries read, a page can be short when the scan limit is hit
+	Scanned int
+}
+
+// Bucket is the number of matching entries in one time slot
+type Bucket struct {
+	Start   time.Time        `json:"start"`
+	Count   int64            `json:"count"`
+	ByLevel map[string]int64 `json:"by_level"`
+}
+
+// Histogram is the number of matching entries per time slot between Start and End
+type Histogram struct {
+	Size    string    `json:"size"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Buckets []Bucket  `json:"buckets"`
+	// Truncated is set when the range held more entries than one request counts
+	Truncated bool `json:"truncated"`
+}
+
+// matcher returns the message test of a query, nil matches every entry
+func (q SearchQuery) matcher() (func(string) bool, error) {
+	if q.Text == "" {
+		return nil, nil
+	}
+	if len(q.Text) > maxPatternLength {
+		return nil, fmt.Errorf("search text is longer than %d characters", maxPatternLength)
+	}
+
+	if q.Regex {
+		pattern, err := regexp.Compile(q.Text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %w", err)
+		}
+		return pattern.MatchString, nil
+	}
+
+	text := strings.ToLower(q.Text)
+	return func(message string) bool {
+		return strings.Contains(strings.ToLower(message), text)
+	}, nil
+}
+
+// ParseCursor reads the id a search continues before
+func ParseCursor(cursor string) (uint, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(cursor, 36, 64)
+	if err != nil || id == 0 {
+		return 0, errors.New("invalid cursor")
+	}
+	return uint(id), nil
+}
+
+func formatCursor(id uint) string {
+	return strconv.FormatUint(uint64(id), 36)
+}
+
+// filtered returns the log query of the level, component and time filters
+func (s *LogService) filtered(q SearchQuery) *gorm.DB {
+	query := s.db.Model(&models.LogEntry{})
+	if len(q.Levels) > 0 {
+		query = query.Where("level IN ?", q.Levels)
+	}
+	if len(q.Components) > 0 {
+		query = query.Where("component IN ?", q.Components)
+	}
+	if !q.Start.IsZero() {
+		query = query.Where("timestamp >= ?", q.Start)
+	}
+	if !q.End.IsZero() {
+		query = query.Where("timestamp <= ?", q.End)
+	}
+	return query
+}
+
+// Search returns the newest entries matching the query before its cursor. Messages are
+// redacted before they are matched, so a search cannot probe for masked values.
+func (s *LogService) Search(q SearchQuery) (SearchResult, error) {
+	match, err := q.matcher()
+	if err != nil {
+		return SearchResult{}, err
+	}
+	before, err := ParseCursor(q.Cursor)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	if q.Limit <= 0 || q.Limit > searchBatch {
+		q.Limit = 100
+	}
+
+	result := SearchResult{Logs: []models.LogEntry{}}
+	for result.Scanned < maxSearchScan {
+		var batch []models.LogEntry
+		query := s.filtered(q)
+		if before > 0 {
+			query = query.Where("id < ?", before)
+		}
+		if err := query.Order("id DESC").Limit(searchBatch).Find(&batch).Error; err != nil {
+			return result, fmt.Errorf("failed to search logs: %w", err)
+		}
+
+		for _, entry := range batch {
+			result.Scanned++
+			before = entry.ID
+			entry.Message = redact.String(entry.Message)
+			if match != nil && !match(entry.Message) {
+				continue
+			}
+			result.Logs = append(result.Logs, entry)
+			if len(result.Logs) == q.Limit {
+				result.NextCursor = formatCursor(entry.ID)
+				return result, nil
+			}
+		}
+		if len(batch) < searchBatch {
+			return result, nil
+		}
+	}
+
+	// Batas scan tercapai, pencarian dilanjutkan dari baris terakhir yang dibaca
+	result.NextCursor = formatCursor(before)
+	return result, nil
+}
+
+// ParseBucketSize reads a bucket size like 30s, 5m, 1h or 1d
+func ParseBucketSize(value string) (time.Duration, error) {
+	var size time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		size = time.Duration(n) * 24 * time.Hour
+	} else {
+		size, err = time.ParseDuration(value)
+	}
+	if err != nil || size < time.Second {
+		return 0, fmt.Errorf("invalid bucket size %q, use for example 30s, 5m, 1h or 1d", value)
+	}
+	return size, nil
+}
+
+// Histogram counts the entries matching the query per bucket. The range defaults to the
+// last 24 hours.
+func (s *LogService) Histogram(q SearchQuery, size time.Duration) (Histogram, error) {
+	match, err := q.matcher()
+	if err != nil {
+		return Histogram{}, err
+	}
+	if q.End.IsZero() {
+		q.End = time.Now()
+	}
+	if q.Start.IsZero() {
+		q.Start = q.End.Add(-24 * time.Hour)
+	}
+	if !q.Start.Before(q.End) {
+		return Histogram{}, errors.New("start_time must be before end_time")
+	}
+
+	// Bucket pertama dimulai di kelipatan ukuran bucket agar batasnya stabil antar request
+	start := q.Start.Truncate(size)
+	count := int(q.End.Sub(start)/size) + 1
+	if count > maxBuckets {
+		return Histogram{}, fmt.Errorf("%d buckets requested, at most %d, use a larger bucket size", count, maxBuckets)
+	}
+
+	histogram := Histogram{
+		Size:    size.String(),
+		Start:   start,
+		End:     q.End,
+		Buckets: make([]Bucket, count),
+	}
+	for i := range histogram.Buckets {
+		histogram.Buckets[i] = Bucket{Start: start.Add(time.Duration(i) * size), ByLevel: map[string]int64{}}
+	}
+
+	columns := []string{"id", "timestamp", "level"}
+	if match != nil {
+		columns = append(columns, "message")
+	}
+
+	var before uint
+	scanned := 0
+	for {
+		var batch []models.LogEntry
+		query := s.filtered(q).Select(columns)
+		if before > 0 {
+			query = query.Where("id < ?", before)
+		}
+		if err := query.Order("id DESC").Limit(searchBatch).Find(&batch).Error; err != nil {
+			return histogram, fmt.Errorf("failed to count logs: %w", err)
+		}
+
+		for _, entry := range batch {
+			before = entry.ID
+			if match != nil && !match(redact.String(entry.Message)) {
+				continue
+			}
+			index := int(entry.Timestamp.Sub(start) / size)
+			if index < 0 || index >= count {
+				continue
+			}
+			histogram.Buckets[index].Count++
+			histogram.Buckets[index].ByLevel[entry.Level]++
+		}
+
+		scanned += len(batch)
+		if len(batch) < searchBatch {
+			return histogram, nil
+		}
+		if scanned >= maxHistogramScan {
+			histogram.Truncated = true
+			return histogram, nil
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// Package maintenance holds on-demand maintenance jobs of the sync manager database.
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"jarvist/internal/common/models"
+	"jarvist/internal/syncmanager/scheduler"
+	"jarvist/pkg/logger"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Maintenance job names
+const (
+	JobVacuumDatabase  = "vacuum_db"
+	JobCompactDatabase = "compact_db"
+)
+
+// compactJitter spreads the compaction of many devices over the off-peak hour
+const compactJitter = 30 * time.Minute
+
+// SQLite auto_vacuum modes
+const (
+	autoVacuumNone        = 0
+	autoVacuumIncremental = 2
+)
+
+// Compaction modes
+const (
+	CompactSkipped     = "skipped"
+	CompactIncremental = "incremental" // Free pages returned with incremental_vacuum
+	CompactRebuild     = "rebuild"     // File rebuilt with VACUUM to switch to incremental auto_vacuum
+)
+
+// Config holds the schedule and thresholds of the database compaction
+type Config struct {
+	CompactSchedule       string // Cron schedule of the compaction, off-peak
+	CompactMinFreeMB      int    // Skip the compaction with less free space than this
+	CompactMinFreePercent int    // Skip the compaction when free pages are a smaller share of the file
+	CompactMaxBacklog     int64  // Skip the compaction while more messages than this wait to be sent
+}
+
+// DefaultConfig returns the default maintenance configuration
+func DefaultConfig() *Config {
+	return &Config{
+		CompactSchedule:       "30 3 * * *", // Daily at 03:30, after the cleanup
+		CompactMinFreeMB:      16,
+		CompactMinFreePercent: 10,
+		CompactMaxBacklog:     1000,
+	}
+}
+
+// VacuumResult is the result of a database vacuum
+type VacuumResult struct {
+	SizeBefore int64 `json:"size_before"`
+	SizeAfter  int64 `json:"size_after"`
+	Reclaimed  int64 `json:"reclaimed"`
+}
+
+// CompactionResult is the result of a database compaction
+type CompactionResult struct {
+	Mode            string `json:"mode"`
+	Reason          string `json:"reason,omitempty"`
+	AutoVacuum      string `json:"auto_vacuum"`
+	SizeBefore      int64  `json:"size_before"`
+	SizeAfter       int64  `json:"size_after"`
+	FreeBytesBefore int64  `json:"free_bytes_before"`
+	Reclaimed       int64  `json:"reclaimed"`
+	PendingMessages int64  `json:"pending_messages"`
+	SentMessages    int64  `json:"sent_messages"`
+}
+
+// MaintenanceService runs database maintenance, the vacuum on demand and the compaction
+// off-peak
+type MaintenanceService struct {
+	db     *gorm.DB
+	logger *logger.Logger
+	config *Config
+}
+
+// NewMaintenanceService creates a new maintenance service
+func NewMaintenanceService(db *gorm.DB, logger *logger.Logger, config *Config) *MaintenanceService {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return &MaintenanceService{db: db, logger: logger, config: config}
+}
+
+// RegisterJobs adds the maintenance jobs to the scheduler. The vacuum only runs on demand,
+// the compaction on its off-peak schedule.
+func (s *MaintenanceService) RegisterJobs(jobs *scheduler.Scheduler) error {
+	if err := jobs.Add(scheduler.Job{
+		Name:        JobVacuumDatabase,
+		Description: "Rebuild the database file to return the space of deleted rows to the disk",
+		Singleton:   true,
+		Locks:       []string{scheduler.LockDatabase},
+		Timeout:     30 * time.Minute,
+		Run:         s.vacuum,
+	}); err != nil {
+		return err
+	}
+
+	return jobs.Add(scheduler.Job{
+		Name:        JobCompactDatabase,
+		Description: "Return the free pages left after drained backlogs to the disk and keep incremental auto_vacuum enabled",
+		Schedule:    s.config.CompactSchedule,
+		Jitter:      compactJitter,
+		Singleton:   true,
+		Locks:       []string{scheduler.LockDatabase},
+		Timeout:     30 * time.Minute,
+		Run:         s.compact,
+	})
+}
+
+// vacuum rebuilds the database file. Writers wait on the busy timeout while it runs.
+func (s *MaintenanceService) vacuum(ctx context.Context) error {
+	before, err := s.databaseSize(s.db.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("maintenance", "Vacuuming database (%d bytes)", before)
+	start := time.Now()
+
+	if err := s.db.WithContext(ctx).Exec("VACUUM").Error; err != nil {
+		return fmt.Errorf("vacuum failed: %w", err)
+	}
+	// WAL ikut dikosongkan agar ukuran file langsung turun
+	if err := s.db.WithContext(ctx).Exec("PRAGMA wal_checkpoint(TRUNCATE)").Error; err != nil {
+		s.logger.Warning("maintenance", "Failed to checkpoint WAL after vacuum: %v", err)
+	}
+
+	after, err := s.databaseSize(s.db.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	result := VacuumResult{SizeBefore: before, SizeAfter: after, Reclaimed: before - after}
+	scheduler.SetResult(ctx, result)
+
+	s.logger.Info("maintenance", "Database vacuumed in %v, reclaimed %d bytes", time.Since(start).Round(time.Millisecond), result.Reclaimed)
+	return nil
+}
+
+// compact returns the free pages of the database file to the disk. A database without
+// incremental auto_vacuum is rebuilt once to enable it, later runs only release the free
+// pages. The compaction is skipped while a backlog is still being sent or when there is
+// little to reclaim.
+func (s *MaintenanceService) compact(ctx context.Context) error {
+	result := CompactionResult{}
+
+	db := s.db.WithContext(ctx)
+	db.Model(&models.PendingMessage{}).Where("sent = ?", false).Count(&result.PendingMessages)
+	db.Model(&models.PendingMessage{}).Where("sent = ?", true).Count(&result.SentMessages)
+
+	// auto_vacuum hanya berlaku setelah VACUUM di koneksi yang sama, jadi satu koneksi dipakai
+	err := db.Connection(func(conn *gorm.DB) error {
+		var err error
+		if result.SizeBefore, err = s.databaseSize(conn); err != nil {
+			return err
+		}
+		if result.FreeBytesBefore, err = s.freeBytes(conn); err != nil {
+			return err
+		}
+
+		var autoVacuum int
+		if err := conn.Raw("PRAGMA auto_vacuum").Scan(&autoVacuum).Error; err != nil {
+			return fmt.Errorf("failed to read auto_vacuum: %w", err)
+		}
+		result.AutoVacuum = autoVacuumName(autoVacuum)
+
+		minFree := int64(s.config.CompactMinFreeMB) << 20
+		switch {
+		case s.config.CompactMaxBacklog > 0 && result.PendingMessages > s.config.CompactMaxBacklog:
+			result.Mode, result.Reason = CompactSkipped, "backlog not drained"
+			return nil
+		case result.FreeBytesBefore < minFree ||
+			result.FreeBytesBefore*100 < result.SizeBefore*int64(s.config.CompactMinFreePercent):
+			result.Mode, result.Reason = CompactSkipped, "little free space"
+			return nil
+		}
+
+		s.logger.Info("maintenance", "Compacting database (%d bytes, %d free, auto_vacuum %s)",
+			result.SizeBefore, result.FreeBytesBefore, result.AutoVacuum)
+
+		if autoVacuum != autoVacuumIncremental {
+			if err := conn.Exec("PRAGMA auto_vacuum = INCREMENTAL").Error; err != nil {
+				return fmt.Errorf("failed to set auto_vacuum: %w", err)
+			}
+			if err := conn.Exec("VACUUM").Error; err != nil {
+				return fmt.Errorf("vacuum failed: %w", err)
+			}
+			result.Mode = CompactRebuild
+			result.AutoVacuum = autoVacuumName(autoVacuumIncremental)
+		} else {
+			// incremental_vacuum membebaskan satu halaman per step, hasilnya harus dibaca habis
+			rows, err := conn.Raw("PRAGMA incremental_vacuum").Rows()
+			if err != nil {
+				return fmt.Errorf("incremental vacuum failed: %w", err)
+			}
+			for rows.Next() {
+			}
+			if err := rows.Close(); err != nil {
+				return fmt.Errorf("incremental vacuum failed: %w", err)
+			}
+			result.Mode = CompactIncremental
+		}
+
+		if err := conn.Exec("PRAGMA wal_checkpoint(TRUNCATE)").Error; err != nil {
+			s.logger.Warning("maintenance", "Failed to checkpoint WAL after compaction: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if result.Mode == CompactSkipped {
+		result.SizeAfter = result.SizeBefore
+		scheduler.SetResult(ctx, result)
+		s.logger.Info("maintenance", "Database compaction skipped: %s", result.Reason)
+		return nil
+	}
+
+	if result.SizeAfter, err = s.databaseSize(db); err != nil {
+		return err
+	}
+	result.Reclaimed = result.SizeBefore - result.SizeAfter
+	scheduler.SetResult(ctx, result)
+
+	s.logger.Info("maintenance", "Database compacted (%s), reclaimed %d bytes", result.Mode, result.Reclaimed)
+	return nil
+}
+
+// freeBytes returns the size of the free pages of the database file
+func (s *MaintenanceService) freeBytes(conn *gorm.DB) (int64, error) {
+	var freePages, pageSize int64
+	if err := conn.Raw("PRAGMA freelist_count").Scan(&freePages).Error; err != nil {
+		return 0, fmt.Errorf("failed to read free page count: %w", err)
+	}
+	if err := conn.Raw("PRAGMA page_size").Scan(&pageSize).Error; err != nil {
+		return 0, fmt.Errorf("failed to read page size: %w", err)
+	}
+	return freePages * pageSize, nil
+}
+
+func autoVacuumName(mode int) string {
+	switch mode {
+	case autoVacuumNone:
+		return "none"
+	case autoVacuumIncremental:
+		return "incremental"
+	default:
+		return "full"
+	}
+}
+
+// databaseSize returns the size of the main database file from its page count
+func (s *MaintenanceService) databaseSize(db *gorm.DB) (int64, error) {
+	var pageCount, pageSize int64
+	if err := db.Raw("PRAGMA page_count").Scan(&pageCount).Error; err != nil {
+		return 0, fmt.Errorf("failed to read page count: %w", err)
+	}
+	if err := db.Raw("PRAGMA page_size").Scan(&pageSize).Error; err != nil {
+		return 0, fmt.Errorf("failed to read page size: %w", err)
+	}
+	return pageCount * pageSize, nil
+}
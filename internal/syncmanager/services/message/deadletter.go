@@ -0,0 +1,182 @@
+package message
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/database"
+	"jarvist/internal/common/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxLastErrorLength bounds the publish error kept with a message
+const maxLastErrorLength = 1000
+
+// ErrDeadLetterNotFound is returned for an unknown dead letter ID
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// FailureResult is the outcome of a failed publish, DeadLetter is set when the message was
+// moved to the dead letter table
+type FailureResult struct {
+	Attempts      int
+	NextAttemptAt time.Time
+	DeadLetter    *models.DeadLetterMessage
+}
+
+// RecordFailure counts a failed publish of a pending message. After maxRetries failures the
+// message is moved to the dead letter table, 0 keeps it pending forever. Otherwise it is not
+// loaded again before delay(attempts) has passed.
+func (s *MessageService) RecordFailure(id uint, cause error, maxRetries int, delay func(attempts int) time.Duration) (FailureResult, error) {
+	lastError := cause.Error()
+	if len(lastError) > maxLastErrorLength {
+		lastError = lastError[:maxLastErrorLength]
+	}
+
+	var result FailureResult
+	err := RetryOnLocked(func() error {
+		result = FailureResult{}
+		return s.db.Transaction(func(tx *gorm.DB) error {
+			var message models.PendingMessage
+			if err := tx.Where("id = ? AND sent = ?", id, false).First(&message).Error; err != nil {
+				return err
+			}
+			result.Attempts = message.RetryCount + 1
+			now := time.Now()
+
+			if maxRetries > 0 && result.Attempts >= maxRetries {
+				deadLetter := models.DeadLetterMessage{
+					MessageID:      message.ID,
+					Topic:          message.Topic,
+					Payload:        message.Payload,
+					IdempotencyKey: message.IdempotencyKey,
+					Attempts:       result.Attempts,
+					LastError:      lastError,
+					StoredAt:       message.Timestamp,
+					FailedAt:       now,
+				}
+				if err := tx.Create(&deadLetter).Error; err != nil {
+					return err
+				}
+				result.DeadLetter = &deadLetter
+				return tx.Delete(&models.PendingMessage{}, message.ID).Error
+			}
+
+			result.NextAttemptAt = now.Add(delay(result.Attempts))
+			return tx.Model(&models.PendingMessage{}).
+				Where("id = ?", message.ID).
+				Updates(map[string]interface{}{
+					"retry_count":     result.Attempts,
+					"last_error":      lastError,
+					"next_attempt_at": result.NextAttemptAt,
+					"extra_info":      gorm.Expr("JSON_SET(extra_info, '$.processing', json('false'))"),
+				}).Error
+		})
+	})
+	if err != nil {
+		return FailureResult{}, fmt.Errorf("failed to record failure of message %d: %w", id, err)
+	}
+
+	if result.DeadLetter != nil {
+		s.logger.Warning(database.ComponentMessages, "Moved message ID %d to dead letter %d after %d failed attempts",
+			id, result.DeadLetter.ID, result.Attempts)
+	}
+	return result, nil
+}
+
+// ListDeadLetters returns dead letters, the most recent first, and their total count
+func (s *MessageService) ListDeadLetters(limit, offset int) ([]models.DeadLetterMessage, int64, error) {
+	var total int64
+	if err := s.db.Model(&models.DeadLetterMessage{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count dead letters: %w", err)
+	}
+
+	deadLetters := []models.DeadLetterMessage{}
+	if err := s.db.Order("failed_at DESC, id DESC").Limit(limit).Offset(offset).Find(&deadLetters).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	return deadLetters, total, nil
+}
+
+// CountDeadLetters counts the messages in the dead letter table
+func (s *MessageService) CountDeadLetters() (int64, error) {
+	var count int64
+	if err := s.db.Model(&models.DeadLetterMessage{}).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count dead letters: %w", err)
+	}
+	return count, nil
+}
+
+// RequeueDeadLetter stores a dead letter as a new pending message with a fresh retry count
+// and removes it from the dead letter table. It returns the ID of the new message.
+func (s *MessageService) RequeueDeadLetter(id uint) (uint, error) {
+	var messageID uint
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var deadLetter models.DeadLetterMessage
+		if err := tx.First(&deadLetter, id).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrDeadLetterNotFound
+		} else if err != nil {
+			return err
+		}
+
+		var err error
+		messageID, err = requeue(tx, deadLetter)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue dead letter %d: %w", id, err)
+	}
+	return messageID, nil
+}
+
+// RequeueDeadLetters requeues all dead letters like RequeueDeadLetter and returns how many
+func (s *MessageService) RequeueDeadLetters() (int, error) {
+	var deadLetters []models.DeadLetterMessage
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Order("id").Find(&deadLetters).Error; err != nil {
+			return err
+		}
+		for _, deadLetter := range deadLetters {
+			if _, err := requeue(tx, deadLetter); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue dead letters: %w", err)
+	}
+	return len(deadLetters), nil
+}
+
+func requeue(tx *gorm.DB, deadLetter models.DeadLetterMessage) (uint, error) {
+	extraInfo, err := json.Marshal(map[string]interface{}{
+		"stored_at":     time.Now().Format(time.RFC3339),
+		"processing":    false,
+		"requeued_from": deadLetter.ID,
+		"dead_attempts": deadLetter.Attempts,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	key := deadLetter.IdempotencyKey
+	if key == "" {
+		key = IdempotencyKey(deadLetter.Topic, deadLetter.Payload)
+	}
+	message := models.PendingMessage{
+		Topic:          deadLetter.Topic,
+		Payload:        deadLetter.Payload,
+		Timestamp:      time.Now(),
+		ExtraInfo:      string(extraInfo),
+		IdempotencyKey: key,
+	}
+	if err := tx.Create(&message).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Delete(&models.DeadLetterMessage{}, deadLetter.ID).Error; err != nil {
+		return 0, err
+	}
+	return message.ID, nil
+}
@@ -0,0 +1,99 @@
+package message
+
+import (
+	"errors"
+	"jarvist/internal/common/models"
+	"jarvist/pkg/logger"
+	"testing"
+	"time"
+)
+
+func TestRecordFailureMovesToDeadLetter(t *testing.T) {
+	db := openTestDB(t)
+	service := NewMessageService(db, logger.NewLogger())
+	delay := func(attempts int) time.Duration { return time.Duration(attempts) * time.Hour }
+
+	id, err := service.StoreMessage("jarvist/site/20250101", `{"data":{"id":"entry-1"}}`, true, "online")
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+
+	result, err := service.RecordFailure(id, errors.New("payload rejected"), 2, delay)
+	if err != nil {
+		t.Fatalf("record failure: %v", err)
+	}
+	if result.Attempts != 1 || result.DeadLetter != nil || time.Until(result.NextAttemptAt) < 59*time.Minute {
+		t.Fatalf("first failure = %+v, want a retry in an hour", result)
+	}
+	if count, _ := service.CountSendableMessages(); count != 0 {
+		t.Fatal("message waiting for its backoff counted as sendable")
+	}
+	if messages, _ := service.GetPendingMessages(10); len(messages) != 0 {
+		t.Fatal("message loaded before its backoff passed")
+	}
+
+	db.Model(&models.PendingMessage{}).Where("id = ?", id).Update("next_attempt_at", time.Now().Add(-time.Second))
+	if messages, _ := service.GetPendingMessages(10); len(messages) != 1 || messages[0].LastError != "payload rejected" {
+		t.Fatalf("loaded %d messages after the backoff, want the failed one", len(messages))
+	}
+
+	result, err = service.RecordFailure(id, errors.New("payload rejected"), 2, delay)
+	if err != nil {
+		t.Fatalf("record failure: %v", err)
+	}
+	if result.DeadLetter == nil || result.DeadLetter.Attempts != 2 || result.DeadLetter.MessageID != id {
+		t.Fatalf("second failure = %+v, want the message dead", result)
+	}
+	if count, _ := service.CountPendingMessages(); count != 0 {
+		t.Fatalf("%d messages pending, want the dead one moved", count)
+	}
+
+	deadLetters, total, err := service.ListDeadLetters(10, 0)
+	if err != nil || total != 1 || len(deadLetters) != 1 || deadLetters[0].LastError != "payload rejected" {
+		t.Fatalf("dead letters = %+v, %d, %v", deadLetters, total, err)
+	}
+}
+
+func TestRequeueDeadLetter(t *testing.T) {
+	db := openTestDB(t)
+	service := NewMessageService(db, logger.NewLogger())
+
+	noDelay := func(int) time.Duration { return 0 }
+	for _, payload := range []string{`{"data":{"id":"entry-1"}}`, `{"data":{"id":"entry-2"}}`} {
+		id, err := service.StoreMessage("jarvist/site/20250101", payload, true, "online")
+		if err != nil {
+			t.Fatalf("store: %v", err)
+		}
+		if _, err := service.RecordFailure(id, errors.New("timeout"), 1, noDelay); err != nil {
+			t.Fatalf("record failure: %v", err)
+		}
+	}
+
+	deadLetters, _, _ := service.ListDeadLetters(10, 0)
+	if len(deadLetters) != 2 {
+		t.Fatalf("%d dead letters, want 2", len(deadLetters))
+	}
+
+	messageID, err := service.RequeueDeadLetter(deadLetters[0].ID)
+	if err != nil {
+		t.Fatalf("requeue: %v", err)
+	}
+	var requeued models.PendingMessage
+	db.First(&requeued, messageID)
+	if requeued.Sent || requeued.RetryCount != 0 || requeued.NextAttemptAt != nil || requeued.IdempotencyKey == "" {
+		t.Fatalf("requeued message = %+v, want a fresh pending message", requeued)
+	}
+	if _, err := service.RequeueDeadLetter(deadLetters[0].ID); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Fatalf("requeue twice = %v, want ErrDeadLetterNotFound", err)
+	}
+
+	if count, err := service.RequeueDeadLetters(); err != nil || count != 1 {
+		t.Fatalf("requeue all = %d, %v, want 1", count, err)
+	}
+	if count, _ := service.CountDeadLetters(); count != 0 {
+		t.Fatalf("%d dead letters left", count)
+	}
+	if count, _ := service.CountSendableMessages(); count != 2 {
+		t.Fatalf("%d messages sendable, want both requeued", count)
+	}
+}
@@ -0,0 +1,78 @@
+package message
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/database"
+	"jarvist/internal/common/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// IdempotencyKey identifies the content of a message, two messages with the same key
+// carry the same data and only the first is sent. Data messages are keyed by the ID of
+// their entry, so a file processed again after a crash gets the same key although its
+// processed_at changed. A replay is keyed by its replay ID as well, it is sent again on
+// purpose. Other messages are keyed by topic and payload.
+func IdempotencyKey(topic, payload string) string {
+	var fields struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+		Replay struct {
+			ID string `json:"id"`
+		} `json:"replay"`
+	}
+
+	content := "payload\x00" + payload
+	if json.Unmarshal([]byte(payload), &fields) == nil && fields.Data.ID != "" {
+		content = "entry\x00" + fields.Data.ID + "\x00" + fields.Replay.ID
+	}
+
+	sum := sha256.Sum256([]byte(topic + "\x00" + content))
+	return hex.EncodeToString(sum[:])
+}
+
+// FindSentDuplicate returns the ID of another message with the same idempotency key that
+// was already sent, 0 if there is none. Messages stored before keys existed have no key
+// and are never duplicates.
+func (s *MessageService) FindSentDuplicate(message models.PendingMessage) (uint, error) {
+	if message.IdempotencyKey == "" {
+		return 0, nil
+	}
+
+	var original models.PendingMessage
+	err := s.db.Select("id").
+		Where("idempotency_key = ? AND sent = ? AND id <> ?", message.IdempotencyKey, true, message.ID).
+		Order("id").
+		First(&original).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to check for duplicates: %w", err)
+	}
+	return original.ID, nil
+}
+
+// MarkDuplicate closes a message without sending it, its data was sent with originalID
+func (s *MessageService) MarkDuplicate(id, originalID uint) error {
+	err := RetryOnLocked(func() error {
+		return s.db.Model(&models.PendingMessage{}).
+			Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"sent": true,
+				"extra_info": gorm.Expr("JSON_SET(extra_info, '$.duplicate_of', ?, '$.skipped_at', ?, '$.processing', json('false'))",
+					originalID, time.Now().Format(time.RFC3339)),
+			}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark message %d as duplicate: %w", id, err)
+	}
+
+	s.logger.Info(database.ComponentMessages, "Skipped message ID %d, duplicate of sent message ID %d", id, originalID)
+	return nil
+}
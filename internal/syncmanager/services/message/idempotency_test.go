@@ -0,0 +1,85 @@
+package message
+
+import (
+	"jarvist/internal/common/models"
+	"jarvist/internal/testutil"
+	"jarvist/pkg/logger"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	return testutil.OpenDB(t, &models.PendingMessage{}, &models.DeadLetterMessage{})
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	const topic = "jarvist/site/20250101"
+	first := IdempotencyKey(topic, `{"processed_at":"2025-01-01T10:00:00Z","data":{"id":"entry-1","in_count":3}}`)
+	again := IdempotencyKey(topic, `{"processed_at":"2025-01-01T10:05:00Z","data":{"id":"entry-1","in_count":3}}`)
+	if first != again {
+		t.Fatal("the same entry processed twice got different keys")
+	}
+
+	if IdempotencyKey(topic, `{"data":{"id":"entry-2"}}`) == first {
+		t.Fatal("different entries got the same key")
+	}
+	if IdempotencyKey("jarvist/other/20250101", `{"data":{"id":"entry-1"}}`) == first {
+		t.Fatal("the same entry on another topic got the same key")
+	}
+	if IdempotencyKey(topic, `{"data":{"id":"entry-1"},"replay":{"id":"replay_1"}}`) == first {
+		t.Fatal("a replay got the key of the original message")
+	}
+
+	milestone := IdempotencyKey(topic, `{"type":"milestone","total":100}`)
+	if milestone != IdempotencyKey(topic, `{"type":"milestone","total":100}`) ||
+		milestone == IdempotencyKey(topic, `{"type":"milestone","total":200}`) {
+		t.Fatal("messages without an entry are not keyed by their payload")
+	}
+}
+
+func TestFindSentDuplicate(t *testing.T) {
+	db := openTestDB(t)
+	service := NewMessageService(db, logger.NewLogger())
+
+	payload := map[string]interface{}{"data": map[string]interface{}{"id": "entry-1"}}
+	firstID, err := service.StoreMessage("jarvist/site/20250101", payload, true, "online")
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	secondID, err := service.StoreMessage("jarvist/site/20250101", payload, true, "online")
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+
+	var second models.PendingMessage
+	db.First(&second, secondID)
+	if originalID, err := service.FindSentDuplicate(second); err != nil || originalID != 0 {
+		t.Fatalf("duplicate of an unsent message = %d, %v, want none", originalID, err)
+	}
+
+	if err := service.MarkMessageSent(firstID); err != nil {
+		t.Fatalf("mark sent: %v", err)
+	}
+	originalID, err := service.FindSentDuplicate(second)
+	if err != nil || originalID != firstID {
+		t.Fatalf("duplicate = %d, %v, want %d", originalID, err, firstID)
+	}
+
+	if err := service.MarkDuplicate(secondID, firstID); err != nil {
+		t.Fatalf("mark duplicate: %v", err)
+	}
+	db.First(&second, secondID)
+	if !second.Sent {
+		t.Fatal("duplicate is still pending")
+	}
+	if count, _ := service.CountPendingMessages(); count != 0 {
+		t.Fatalf("%d messages pending, want 0", count)
+	}
+
+	legacy := models.PendingMessage{Topic: second.Topic, Payload: second.Payload, ExtraInfo: "{}"}
+	db.Create(&legacy)
+	if originalID, _ := service.FindSentDuplicate(legacy); originalID != 0 {
+		t.Fatal("message without a key was taken for a duplicate")
+	}
+}
@@ -26,10 +26,17 @@ func NewMessageService(db *gorm.DB, logger *logger.Logger) *MessageService {
 	}
 }
 
-func (s *MessageService) StoreMessage(topic string, payload interface{}, connected bool) (uint, error) {
+func (s *MessageService) StoreMessage(topic string, payload interface{}, connected bool, networkState string) (uint, error) {
+	return s.StoreMessageTx(s.db, topic, payload, connected, networkState)
+}
+
+// StoreMessageTx stores a message using the given transaction, so it can be committed
+// together with other records
+func (s *MessageService) StoreMessageTx(tx *gorm.DB, topic string, payload interface{}, connected bool, networkState string) (uint, error) {
 	extraInfo, err := json.Marshal(map[string]interface{}{
 		"stored_at":         time.Now().Format(time.RFC3339),
 		"connection_status": connected,
+		"network_state":     networkState,
 		"processing":        false, // Add processing flag to track message state
 	})
 	if err != nil {
@@ -57,9 +64,10 @@ func (s *MessageService) StoreMessage(topic string, payload interface{}, connect
 		Sent:            false,
 		ConnectionState: connected,
 		ExtraInfo:       string(extraInfo),
+		IdempotencyKey:  IdempotencyKey(topic, encodedPayload),
 	}
 
-	result := s.db.Create(&message)
+	result := tx.Create(&message)
 	if result.Error != nil {
 		return 0, fmt.Errorf("failed to insert message: %w", result.Error)
 	}
@@ -112,6 +120,8 @@ func (s *MessageService) GetPendingMessages(limit int) ([]models.PendingMessage,
 	var messages []models.PendingMessage
 
 	result := s.db.Where("sent = ? AND (JSON_EXTRACT(extra_info, '$.processing') IS NULL OR JSON_EXTRACT(extra_info, '$.processing') = false)", false).
+		Where("JSON_EXTRACT(extra_info, '$.deferred') IS NULL OR JSON_EXTRACT(extra_info, '$.deferred') = false").
+		Where("next_attempt_at IS NULL OR next_attempt_at <= ?", time.Now()).
 		Order("id").
 		Limit(limit).
 		Find(&messages)
@@ -291,6 +301,47 @@ func (s *MessageService) CountPendingMessages() (int64, error) {
 	return count, nil
 }
 
+// CountSendableMessages counts pending messages that are not deferred by the upload pause or
+// waiting for their next attempt after a failed publish
+func (s *MessageService) CountSendableMessages() (int64, error) {
+	var count int64
+
+	result := s.db.Model(&models.PendingMessage{}).
+		Where("sent = ?", false).
+		Where("JSON_EXTRACT(extra_info, '$.deferred') IS NULL OR JSON_EXTRACT(extra_info, '$.deferred') = false").
+		Where("next_attempt_at IS NULL OR next_attempt_at <= ?", time.Now()).
+		Count(&count)
+
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to count sendable messages: %w", result.Error)
+	}
+
+	return count, nil
+}
+
+// MarkDeferred keeps a message in the queue but excludes it from sending until released
+func (s *MessageService) MarkDeferred(id uint) error {
+	return s.db.Model(&models.PendingMessage{}).
+		Where("id = ?", id).
+		Update("extra_info", gorm.Expr("JSON_SET(extra_info, '$.deferred', json('true'), '$.processing', json('false'))")).Error
+}
+
+// ReleaseDeferred makes all deferred messages sendable again
+func (s *MessageService) ReleaseDeferred() (int64, error) {
+	result := s.db.Model(&models.PendingMessage{}).
+		Where("sent = ? AND JSON_EXTRACT(extra_info, '$.deferred') = true", false).
+		Update("extra_info", gorm.Expr("JSON_SET(extra_info, '$.deferred', json('false'))"))
+
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to release deferred messages: %w", result.Error)
+	}
+
+	if result.RowsAffected > 0 {
+		s.logger.Info(database.ComponentMessages, "Released %d deferred messages", result.RowsAffected)
+	}
+	return result.RowsAffected, nil
+}
+
 func (s *MessageService) HasOldPendingMessages(age time.Duration) (bool, error) {
 	var count int64
 	cutoffTime := time.Now().Add(-age)
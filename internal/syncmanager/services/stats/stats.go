@@ -2,6 +2,7 @@ package stats
 
 import (
 	"fmt"
+	"jarvist/internal/common/bandwidth"
 	"jarvist/internal/common/models"
 	"jarvist/internal/syncmanager/services/log"
 
@@ -76,3 +77,22 @@ func (s *StatsService) GetTopicStats() (map[string]interface{}, error) {
 
 	return result, nil
 }
+
+// GetBandwidthUsage returns daily bytes per destination and the totals over the last N days
+func (s *StatsService) GetBandwidthUsage(days int) (map[string]interface{}, error) {
+	daily, err := bandwidth.GetUsage(s.db, days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bandwidth usage: %w", err)
+	}
+
+	totals, err := bandwidth.GetTotals(s.db, days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bandwidth totals: %w", err)
+	}
+
+	return map[string]interface{}{
+		"days":   days,
+		"daily":  daily,
+		"totals": totals,
+	}, nil
+}
@@ -0,0 +1,258 @@
+package settingschema
+
+import (
+	"errors"
+	"fmt"
+	"jarvist/internal/common/bandwidth"
+	"jarvist/internal/common/camerahealth"
+	"jarvist/internal/common/ffmpeg"
+	"jarvist/internal/common/identity"
+	"jarvist/internal/common/licensestate"
+	"jarvist/internal/common/redact"
+	"jarvist/internal/common/residency"
+	"jarvist/internal/common/snapshot"
+	"jarvist/internal/syncmanager/config"
+	"jarvist/internal/syncmanager/fleetstatus"
+	"jarvist/internal/syncmanager/httpupload"
+	"jarvist/internal/syncmanager/maintmode"
+	"jarvist/internal/syncmanager/mqtt"
+	"jarvist/internal/syncmanager/sync"
+	"jarvist/internal/syncmanager/tracing"
+	"jarvist/internal/syncmanager/verify"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Value types of the settings
+const (
+	TypeString = "string"
+	TypeInt    = "int"
+	TypeBool   = "bool"
+	TypeJSON   = "json"
+)
+
+// When a changed setting is picked up
+const (
+	AppliesNow          = "immediately"
+	AppliesSyncApply    = "after POST /api/settings/sync/apply"
+	AppliesPolicy       = "within 30 seconds"
+	AppliesSenderStart  = "on the next restart of the mqtt_sender component"
+	AppliesDesktop      = "on the next start of the desktop app"
+	AppliesDesktopCheck = "within 30 seconds while the desktop app runs"
+)
+
+// Field describes one setting the API knows about
+type Field struct {
+	Key         string   `json:"key"`
+	Type        string   `json:"type"`
+	Group       string   `json:"group"`
+	Description string   `json:"description"`
+	Secret      bool     `json:"secret,omitempty"`
+	ReadOnly    bool     `json:"read_only,omitempty"`
+	Options     []string `json:"options,omitempty"`
+	Applies     string   `json:"applies,omitempty"`
+
+	// check validates a value already parsed as Type, nil accepts any value of the type
+	check func(value string) error
+}
+
+// fields is the settings schema. Keys written by the services themselves are read-only,
+// editing them remotely would only be overwritten or leave the state inconsistent.
+var fields = []Field{
+	// Identity, validated together with the rest of the identity
+	{Key: identity.TenantIDKey, Type: TypeString, Group: "identity", Description: "Tenant the device reports as", Applies: AppliesNow},
+	{Key: identity.ClientIDKey, Type: TypeInt, Group: "identity", Description: "Client the device reports as", Applies: AppliesNow},
+	{Key: identity.SiteIDKey, Type: TypeInt, Group: "identity", Description: "Site the device reports as", Applies: AppliesNow},
+	{Key: identity.SiteCodeKey, Type: TypeString, Group: "identity", Description: "Short code of the site", Applies: AppliesNow},
+	{Key: identity.TopicPrefixKey, Type: TypeString, Group: "identity", Description: "First level of every data topic", Applies: AppliesNow},
+	{Key: identity.DeviceTagsKey, Type: TypeJSON, Group: "identity", Description: "Fleet tags sent with every message, a JSON object of strings", Applies: AppliesNow},
+	{Key: "site_name", Type: TypeString, Group: "identity", Description: "Name of the site shown in the desktop app", Applies: AppliesDesktop},
+
+	// Sync settings, the same limits as PUT /api/settings/sync
+	{Key: config.SyncIntervalKey, Type: TypeInt, Group: "sync", Description: "Seconds between sync runs, 0 keeps the config", Applies: AppliesSyncApply,
+		check: syncCheck(func(s *config.SyncSettings, n int) { s.SyncIntervalSec = n })},
+	{Key: config.LogRetentionKey, Type: TypeInt, Group: "sync", Description: "Days logs are kept, 0 keeps the config", Applies: AppliesSyncApply,
+		check: syncCheck(func(s *config.SyncSettings, n int) { s.LogRetentionDays = n })},
+	{Key: config.MessageRetentionKey, Type: TypeInt, Group: "sync", Description: "Days sent messages are kept, 0 keeps the config", Applies: AppliesSyncApply,
+		check: syncCheck(func(s *config.SyncSettings, n int) { s.MessageRetentionDays = n })},
+	{Key: config.DataRecordRetentionKey, Type: TypeInt, Group: "sync", Description: "Days cached data records are kept, 0 keeps the config", Applies: AppliesSyncApply,
+		check: syncCheck(func(s *config.SyncSettings, n int) { s.DataRecordRetentionDays = n })},
+	{Key: config.BrokerKey, Type: TypeString, Group: "sync", Description: "MQTT broker host, empty keeps the config", Applies: AppliesSyncApply,
+		check: func(value string) error { return config.SyncSettings{Broker: value}.Validate() }},
+	{Key: config.BrokerPortKey, Type: TypeInt, Group: "sync", Description: "MQTT broker port, 0 keeps the config", Applies: AppliesSyncApply,
+		check: syncCheck(func(s *config.SyncSettings, n int) { s.Port = n })},
+	{Key: config.BrokerUsernameKey, Type: TypeString, Group: "sync", Description: "MQTT username, empty keeps the config", Applies: AppliesSyncApply},
+	{Key: config.BrokerPasswordKey, Type: TypeString, Group: "sync", Description: "MQTT password", Secret: true, Applies: AppliesSyncApply},
+	{Key: mqtt.CredentialsSourceKey, Type: TypeString, Group: "sync", Description: "Where the MQTT username and password are read from", Applies: AppliesPolicy,
+		Options: mqtt.CredentialSources},
+	{Key: mqtt.CredentialsRefKey, Type: TypeString, Group: "sync", Description: "Environment variable prefix, credential target or secrets file of the credentials source", Applies: AppliesPolicy},
+	{Key: config.BrokerTLSKey, Type: TypeBool, Group: "sync", Description: "Connect to the broker over TLS", Applies: AppliesSyncApply},
+	{Key: config.StagingKey, Type: TypeBool, Group: "sync", Description: "Publish to the -staging topic namespace", Applies: AppliesSyncApply},
+	{Key: config.FailoverBrokersKey, Type: TypeJSON, Group: "sync", Description: "Brokers tried when the MQTT broker is unreachable, a JSON array of host, port and priority", Applies: AppliesSyncApply,
+		check: func(value string) error {
+			_, err := config.ParseBrokers(value)
+			return err
+		}},
+	{Key: config.FailoverAfterKey, Type: TypeInt, Group: "sync", Description: "Seconds a broker may be unreachable before the next one is tried, 0 keeps the config", Applies: AppliesSyncApply,
+		check: syncCheck(func(s *config.SyncSettings, n int) { s.FailoverAfterSec = n })},
+	{Key: sync.MilestoneStepKey, Type: TypeInt, Group: "sync", Description: "Visitors between lifetime milestone notifications", Applies: AppliesNow,
+		check: func(value string) error { return intRange(value, sync.MinMilestoneStep, 100000000) }},
+	{Key: sync.ValidationRulesKey, Type: TypeJSON, Group: "sync", Description: "Rules that quarantine anomalous counts instead of publishing them", Applies: AppliesNow,
+		check: func(value string) error {
+			_, err := sync.ParseValidationRules(value)
+			return err
+		}},
+	{Key: redact.RulesKey, Type: TypeJSON, Group: "sync", Description: "Extra rules masking data in MQTT payloads and exported logs, a JSON array of rules", Applies: AppliesPolicy,
+		check: func(value string) error {
+			_, err := redact.ParseRules(value)
+			return err
+		}},
+
+	// Sender tuning, the same limits as PUT /api/mqtt/tuning
+	{Key: mqtt.TuningWorkersKey, Type: TypeInt, Group: "sender", Description: "Message workers publishing in parallel", Applies: AppliesPolicy,
+		check: tuningCheck(func(t *mqtt.Tuning, n int) { t.Workers = n })},
+	{Key: mqtt.TuningPublishDelayKey, Type: TypeInt, Group: "sender", Description: "Pause of a worker after each publish in milliseconds", Applies: AppliesPolicy,
+		check: tuningCheck(func(t *mqtt.Tuning, n int) { t.PublishDelayMs = n })},
+	{Key: mqtt.TuningBatchSizeKey, Type: TypeInt, Group: "sender", Description: "Pending messages loaded per batch", Applies: AppliesPolicy,
+		check: tuningCheck(func(t *mqtt.Tuning, n int) { t.BatchSize = n })},
+	{Key: mqtt.TuningBatchPauseKey, Type: TypeInt, Group: "sender", Description: "Pause between pending message batches in milliseconds", Applies: AppliesPolicy,
+		check: tuningCheck(func(t *mqtt.Tuning, n int) { t.BatchPauseMs = n })},
+	{Key: mqtt.TuningQueueCapacityKey, Type: TypeInt, Group: "sender", Description: "In-memory queue size", Applies: AppliesSenderStart,
+		check: tuningCheck(func(t *mqtt.Tuning, n int) { t.QueueCapacity = n })},
+	{Key: mqtt.RetryMaxKey, Type: TypeInt, Group: "sender", Description: "Failed publishes before a message moves to the dead letters, 0 retries forever", Applies: AppliesPolicy,
+		check: func(value string) error { return intRange(value, 0, mqtt.MaxRetriesLimit) }},
+	{Key: mqtt.RetryBaseDelayKey, Type: TypeInt, Group: "sender", Description: "Milliseconds before the first retry of a failed message, doubled for each further retry", Applies: AppliesPolicy,
+		check: func(value string) error { return intRange(value, mqtt.MinRetryDelayMs, mqtt.MaxRetryDelayMs) }},
+	{Key: mqtt.RetryMaxDelayKey, Type: TypeInt, Group: "sender", Description: "Longest wait in milliseconds between two retries of a failed message", Applies: AppliesPolicy,
+		check: func(value string) error { return intRange(value, mqtt.MinRetryDelayMs, mqtt.MaxRetryDelayMs) }},
+	{Key: mqtt.CleanSessionKey, Type: TypeBool, Group: "sender", Description: "Start every broker connection with a clean session", Applies: AppliesSenderStart},
+	{Key: mqtt.SessionExpiryKey, Type: TypeInt, Group: "sender", Description: "Seconds the broker keeps the session, 0 until the next clean connect", Applies: AppliesSenderStart,
+		check: func(value string) error { return intRange(value, 0, 7*24*60*60) }},
+	{Key: mqtt.SessionClientKey, Type: TypeString, Group: "sender", Description: "Client ID of the persistent broker session", ReadOnly: true},
+	{Key: mqtt.SessionSeenKey, Type: TypeString, Group: "sender", Description: "Last time the persistent broker session was used", ReadOnly: true},
+	{Key: fleetstatus.IntervalKey, Type: TypeInt, Group: "sender", Description: "Seconds between the status documents published to <topic>/status", Applies: AppliesNow,
+		check: func(value string) error {
+			return intRange(value, fleetstatus.MinIntervalSec, fleetstatus.MaxIntervalSec)
+		}},
+
+	// Transport of the stored messages, HTTPS for sites that block MQTT outbound
+	{Key: httpupload.TransportKey, Type: TypeString, Group: "transport", Description: "Send stored messages over mqtt or upload them over https", Applies: AppliesPolicy,
+		Options: httpupload.Transports},
+	{Key: httpupload.URLKey, Type: TypeString, Group: "transport", Description: "REST endpoint the message batches are posted to, must be https://", Applies: AppliesPolicy,
+		check: httpupload.ValidateURL},
+	{Key: httpupload.TokenKey, Type: TypeString, Group: "transport", Description: "Bearer token sent to the upload endpoint", Secret: true, Applies: AppliesPolicy},
+	{Key: httpupload.BatchSizeKey, Type: TypeInt, Group: "transport", Description: "Messages posted together at most", Applies: AppliesPolicy,
+		check: func(value string) error { return intRange(value, 1, httpupload.MaxBatchSize) }},
+	{Key: httpupload.BatchWaitKey, Type: TypeInt, Group: "transport", Description: "Milliseconds a batch waits for more messages before it is posted", Applies: AppliesPolicy,
+		check: func(value string) error { return intRange(value, 0, httpupload.MaxBatchWaitMs) }},
+
+	// Bandwidth
+	{Key: bandwidth.MeteredKey, Type: TypeBool, Group: "bandwidth", Description: "Hold back non-critical uploads on a metered connection", Applies: AppliesPolicy},
+	{Key: bandwidth.DailyCapKey, Type: TypeInt, Group: "bandwidth", Description: "Daily upload cap in MB, 0 for no cap", Applies: AppliesPolicy,
+		check: func(value string) error { return intRange(value, 0, 1<<20) }},
+	{Key: bandwidth.PauseModeKey, Type: TypeString, Group: "bandwidth", Description: "Manual override of the upload pause", Applies: AppliesPolicy,
+		Options: []string{bandwidth.PauseModeAuto, bandwidth.PauseModePause, bandwidth.PauseModeResume}},
+
+	// Tracing of the pipeline and the API to an OpenTelemetry collector
+	{Key: tracing.EnabledKey, Type: TypeBool, Group: "tracing", Description: "Export spans of the file pipeline and API requests", Applies: AppliesPolicy},
+	{Key: tracing.EndpointKey, Type: TypeString, Group: "tracing", Description: "OTLP/HTTP collector URL, e.g. http://collector:4318", Applies: AppliesPolicy,
+		check: tracing.ValidateEndpoint},
+	{Key: tracing.SamplePercentKey, Type: TypeInt, Group: "tracing", Description: "Percentage of traces exported", Applies: AppliesPolicy,
+		check: func(value string) error { return intRange(value, 0, 100) }},
+	{Key: tracing.HeadersKey, Type: TypeJSON, Group: "tracing", Description: "Headers sent to the collector, a JSON object of strings", Secret: true, Applies: AppliesPolicy,
+		check: func(value string) error {
+			_, err := tracing.ParseHeaders(value)
+			return err
+		}},
+
+	// Desktop app
+	{Key: "default_timezone", Type: TypeString, Group: "desktop", Description: "Time zone of the site", Applies: AppliesDesktop,
+		check: func(value string) error {
+			if _, err := time.LoadLocation(value); err != nil {
+				return fmt.Errorf("unknown time zone %q", value)
+			}
+			return nil
+		}},
+	{Key: "camera_sync_interval", Type: TypeInt, Group: "desktop", Description: "Seconds between camera syncs", Applies: AppliesDesktop,
+		check: func(value string) error { return intRange(value, 10, 3600) }},
+	{Key: "log_level", Type: TypeString, Group: "desktop", Description: "Log level of the desktop app", Applies: AppliesDesktop,
+		Options: []string{"trace", "debug", "info", "warn", "error"}},
+	{Key: "auth_idle_timeout", Type: TypeInt, Group: "desktop", Description: "Seconds before the desktop app locks itself", Applies: AppliesDesktop,
+		check: func(value string) error { return intRange(value, 30, 24*60*60) }},
+	{Key: "auth_pin_hash", Type: TypeString, Group: "desktop", Description: "Hash of the desktop app PIN, changed in the desktop app", Secret: true, ReadOnly: true},
+	{Key: "kiosk_enabled", Type: TypeBool, Group: "desktop", Description: "Start the desktop app as a full screen kiosk", Applies: AppliesDesktop},
+	{Key: "kiosk_monitor", Type: TypeString, Group: "desktop", Description: "Monitor of the kiosk window, empty for the primary", Applies: AppliesDesktop},
+	{Key: "telemetry_enabled", Type: TypeBool, Group: "desktop", Description: "Send anonymous usage telemetry, switched in the desktop app", ReadOnly: true},
+	{Key: camerahealth.IntervalKey, Type: TypeInt, Group: "desktop", Description: "Minutes between camera health reports over MQTT, 0 disables them", Applies: AppliesDesktopCheck,
+		check: func(value string) error { return intRange(value, 0, 24*60) }},
+	{Key: snapshot.PolicyKey, Type: TypeJSON, Group: "desktop", Description: "Snapshot policy of the camera alerts", Applies: AppliesDesktop},
+	{Key: ffmpeg.ProbeOptionsKey, Type: TypeJSON, Group: "desktop", Description: "RTSP probe options of all cameras", Applies: AppliesNow},
+
+	// State kept by the services
+	{Key: maintmode.StateKey, Type: TypeJSON, Group: "state", Description: "Maintenance mode, changed with /api/maintenance", ReadOnly: true},
+	{Key: maintmode.HistoryKey, Type: TypeJSON, Group: "state", Description: "Maintenance mode history", ReadOnly: true},
+	{Key: licensestate.Key, Type: TypeJSON, Group: "state", Description: "License degradation written by the desktop app", ReadOnly: true},
+	{Key: residency.Key, Type: TypeJSON, Group: "state", Description: "Data residency mode, switched in the desktop app with the admin PIN", ReadOnly: true},
+	{Key: verify.ReportKey, Type: TypeJSON, Group: "state", Description: "Last installation verification, changed with /api/verify", ReadOnly: true},
+}
+
+// secretWords mark settings missing from the schema as secret by their key
+var secretWords = []string{"password", "secret", "token", "pin", "credential", "private"}
+
+// Lookup returns the schema of a setting
+func Lookup(key string) (Field, bool) {
+	for _, field := range fields {
+		if field.Key == key {
+			return field, true
+		}
+	}
+	return Field{}, false
+}
+
+// Fields returns the settings schema
+func Fields() []Field {
+	result := make([]Field, len(fields))
+	copy(result, fields)
+	return result
+}
+
+// IsSecret reports whether the value of a setting is masked
+func IsSecret(key string) bool {
+	if field, ok := Lookup(key); ok {
+		return field.Secret
+	}
+	lower := strings.ToLower(key)
+	for _, word := range secretWords {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func syncCheck(set func(*config.SyncSettings, int)) func(string) error {
+	return func(value string) error {
+		n, _ := strconv.Atoi(value)
+		var settings config.SyncSettings
+		set(&settings, n)
+		return settings.Validate()
+	}
+}
+
+func tuningCheck(set func(*mqtt.Tuning, int)) func(string) error {
+	return func(value string) error {
+		n, _ := strconv.Atoi(value)
+		tuning := mqtt.DefaultTuning(nil)
+		set(&tuning, n)
+		return tuning.Validate()
+	}
+}
+
+func intRange(value string, min, max int) error {
+	n, _ := strconv.Atoi(value)
+	if n < min || n > max {
+		return errors.New("must be between " + strconv.Itoa(min) + " and " + strconv.Itoa(max))
+	}
+	return nil
+}
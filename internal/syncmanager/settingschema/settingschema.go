@@ -0,0 +1,337 @@
+// Package settingschema lets remote support inspect and edit the settings table through the
+// sync API, for example to fix a wrong site_id without remoting into the desktop app. Every
+// value is checked against the schema, secrets are masked and each change is audited.
+package settingschema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/identity"
+	"jarvist/internal/common/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AuditAction is the action name used for setting changes in the audit table
+const AuditAction = "settings.update"
+
+// Mask replaces the value of secret settings. Sending it back unchanged keeps the secret.
+const Mask = "********"
+
+// Setting is a stored setting with its schema, secret values are masked
+type Setting struct {
+	Field
+	Value string `json:"value"`
+	Set   bool   `json:"set"`   // A row exists in the settings table
+	Known bool   `json:"known"` // The key is part of the schema, unknown keys are read-only
+}
+
+// Change is one applied setting change, secret values are masked
+type Change struct {
+	Key     string `json:"key"`
+	Old     string `json:"old"`
+	New     string `json:"new"`
+	Applies string `json:"applies,omitempty"`
+}
+
+// List returns every known setting and every stored setting missing from the schema
+func List(db *gorm.DB) ([]Setting, error) {
+	var rows []models.Setting
+	if err := db.Order("key").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	stored := make(map[string]string, len(rows))
+	for _, row := range rows {
+		stored[row.Key] = row.Value
+	}
+
+	settings := make([]Setting, 0, len(fields)+len(rows))
+	for _, field := range fields {
+		value, ok := stored[field.Key]
+		settings = append(settings, Setting{Field: field, Value: maskValue(field.Key, value), Set: ok, Known: true})
+		delete(stored, field.Key)
+	}
+
+	for _, row := range rows {
+		if _, ok := stored[row.Key]; !ok {
+			continue
+		}
+		field := Field{Key: row.Key, Type: TypeString, Group: "other", Secret: IsSecret(row.Key), ReadOnly: true}
+		settings = append(settings, Setting{Field: field, Value: maskValue(row.Key, row.Value), Set: true})
+	}
+	return settings, nil
+}
+
+// Update validates the values against the schema and stores the changed ones with an audit
+// entry in one transaction. Values are JSON scalars, JSON settings also take objects and
+// arrays. All problems are returned together and nothing is stored if there is any.
+func Update(db *gorm.DB, values map[string]interface{}, actor, source string) ([]Change, error) {
+	var problems []string
+	next := make(map[string]string, len(values))
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field, ok := Lookup(key)
+		switch {
+		case !ok:
+			problems = append(problems, key+" is not a known setting")
+			continue
+		case field.ReadOnly:
+			problems = append(problems, key+" is read-only")
+			continue
+		}
+
+		value, err := normalize(field, values[key])
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+		// Nilai yang masih tersamar berarti rahasia tidak diubah
+		if field.Secret && value == Mask {
+			continue
+		}
+		next[key] = value
+	}
+
+	current := make(map[string]string, len(next))
+	for key := range next {
+		current[key] = getSetting(db, key)
+	}
+
+	identityChanges, identityProblems := checkIdentity(db, next)
+	problems = append(problems, identityProblems...)
+	if len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "; "))
+	}
+
+	var changes []Change
+	for _, key := range keys {
+		value, ok := next[key]
+		if !ok || value == current[key] {
+			continue
+		}
+		field, _ := Lookup(key)
+		changes = append(changes, Change{
+			Key:     key,
+			Old:     maskValue(key, current[key]),
+			New:     maskValue(key, value),
+			Applies: field.Applies,
+		})
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	detail, err := json.Marshal(changes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, change := range changes {
+			if err := saveSetting(tx, change.Key, next[change.Key]); err != nil {
+				return fmt.Errorf("failed to save %s: %w", change.Key, err)
+			}
+		}
+
+		if len(identityChanges) > 0 {
+			// Dicatat juga di audit identitas agar /api/identity/audit tetap lengkap
+			identityDetail, err := json.Marshal(identityChanges)
+			if err != nil {
+				return err
+			}
+			if err := tx.Create(&models.AuditEntry{
+				Timestamp: time.Now(),
+				Action:    identity.AuditAction,
+				Actor:     actor,
+				Source:    source,
+				Detail:    string(identityDetail),
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&models.AuditEntry{
+			Timestamp: time.Now(),
+			Action:    AuditAction,
+			Actor:     actor,
+			Source:    source,
+			Detail:    string(detail),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// GetAudit returns the latest setting changes, newest first
+func GetAudit(db *gorm.DB, limit int) ([]models.AuditEntry, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	var entries []models.AuditEntry
+	err := db.Where("action = ?", AuditAction).Order("id DESC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+// normalize turns a JSON value into the stored string of the field type and checks it
+func normalize(field Field, raw interface{}) (string, error) {
+	var value string
+	switch v := raw.(type) {
+	case nil:
+		value = ""
+	case string:
+		value = strings.TrimSpace(v)
+	case bool:
+		value = strconv.FormatBool(v)
+	case float64:
+		value = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		if field.Type != TypeJSON {
+			return "", fmt.Errorf("must be of type %s", field.Type)
+		}
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		value = string(encoded)
+	}
+
+	if field.Secret && value == Mask {
+		return value, nil
+	}
+
+	switch field.Type {
+	case TypeInt:
+		if value == "" {
+			value = "0"
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return "", errors.New("must be a whole number")
+		}
+		value = strconv.Itoa(n)
+	case TypeBool:
+		if value == "" {
+			value = "false"
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", errors.New("must be true or false")
+		}
+		value = strconv.FormatBool(b)
+	case TypeJSON:
+		if value != "" && !json.Valid([]byte(value)) {
+			return "", errors.New("must be valid JSON")
+		}
+	}
+
+	if len(field.Options) > 0 && value != "" {
+		valid := false
+		for _, option := range field.Options {
+			if value == option {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return "", fmt.Errorf("must be one of %s", strings.Join(field.Options, ", "))
+		}
+	}
+
+	if field.check != nil {
+		if err := field.check(value); err != nil {
+			return "", err
+		}
+	}
+	return value, nil
+}
+
+// checkIdentity validates the identity with the changed identity settings applied, the same
+// rules as PUT /api/identity. Returns the identity changes for the identity audit.
+func checkIdentity(db *gorm.DB, next map[string]string) ([]identity.Change, []string) {
+	current := identity.Get(db)
+	id := current
+	changed := false
+
+	set := func(key string, target *string) {
+		if value, ok := next[key]; ok {
+			*target = value
+			changed = true
+		}
+	}
+	set(identity.TenantIDKey, &id.TenantID)
+	set(identity.ClientIDKey, &id.ClientID)
+	set(identity.SiteIDKey, &id.SiteID)
+	set(identity.SiteCodeKey, &id.SiteCode)
+	set(identity.TopicPrefixKey, &id.TopicPrefix)
+
+	if value, ok := next[identity.DeviceTagsKey]; ok {
+		tags := map[string]string{}
+		if value != "" {
+			if err := json.Unmarshal([]byte(value), &tags); err != nil {
+				return nil, []string{identity.DeviceTagsKey + " must be a JSON object of strings"}
+			}
+		}
+		id.Tags = tags
+		changed = true
+	}
+	if !changed {
+		return nil, nil
+	}
+
+	id = id.Normalize()
+	if problems := id.Validate(); len(problems) > 0 {
+		return nil, problems
+	}
+
+	// Simpan nilai yang sudah dinormalisasi, sama seperti PUT /api/identity
+	for _, change := range current.Diff(id) {
+		if _, ok := next[change.Field]; ok {
+			next[change.Field] = change.New
+		}
+	}
+	return current.Diff(id), nil
+}
+
+func maskValue(key, value string) string {
+	if value != "" && IsSecret(key) {
+		return Mask
+	}
+	return value
+}
+
+func getSetting(db *gorm.DB, key string) string {
+	var setting models.Setting
+	if err := db.Where("key = ?", key).First(&setting).Error; err != nil {
+		return ""
+	}
+	return setting.Value
+}
+
+func saveSetting(db *gorm.DB, key, value string) error {
+	var setting models.Setting
+	result := db.Where("key = ?", key).First(&setting)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return db.Create(&models.Setting{Key: key, Value: value}).Error
+	} else if result.Error != nil {
+		return result.Error
+	}
+
+	setting.Value = value
+	return db.Save(&setting).Error
+}
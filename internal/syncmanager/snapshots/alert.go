@@ -0,0 +1,75 @@
+package snapshots
+
+import (
+	"errors"
+	"jarvist/internal/common/camerahealth"
+	"time"
+)
+
+// AlertTopic is appended to the MQTT base topic for camera alerts
+const AlertTopic = "/alerts/camera"
+
+// Camera statuses reported by the desktop app
+const (
+	StatusOffline = camerahealth.StatusOffline
+	StatusOnline  = camerahealth.StatusOnline
+)
+
+// CameraAlert is a camera status change detected by the connection checks of the desktop app
+type CameraAlert struct {
+	CameraUUID string     `json:"camera_uuid"`
+	CameraID   uint       `json:"camera_id,omitempty"`
+	CameraName string     `json:"camera_name,omitempty"`
+	Status     string     `json:"status"`
+	Message    string     `json:"message,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	LastOnline *time.Time `json:"last_online,omitempty"`
+}
+
+// Validate checks the required fields of an alert
+func (a CameraAlert) Validate() error {
+	if a.CameraUUID == "" {
+		return errors.New("camera_uuid is required")
+	}
+	if a.Status != StatusOffline && a.Status != StatusOnline {
+		return errors.New("status must be offline or online")
+	}
+	return nil
+}
+
+// Payload builds the MQTT payload of an alert. Offline alerts carry the last known frame
+// when the policy allows it, otherwise the reason it was left out.
+func (s *Store) Payload(alert CameraAlert) map[string]interface{} {
+	payload := map[string]interface{}{
+		"type":        "camera_" + alert.Status,
+		"camera_uuid": alert.CameraUUID,
+		"status":      alert.Status,
+		"timestamp":   time.Now().Format(time.RFC3339),
+	}
+	if alert.CameraID != 0 {
+		payload["camera_id"] = alert.CameraID
+	}
+	if alert.CameraName != "" {
+		payload["camera_name"] = alert.CameraName
+	}
+	if alert.Message != "" {
+		payload["message"] = alert.Message
+	}
+	if alert.Error != "" {
+		payload["error"] = alert.Error
+	}
+	if alert.LastOnline != nil {
+		payload["last_online"] = alert.LastOnline.Format(time.RFC3339)
+	}
+
+	if alert.Status != StatusOffline {
+		return payload
+	}
+
+	if attachment, omitted := s.Attachment(alert.CameraUUID); attachment != nil {
+		payload["snapshot"] = attachment
+	} else if omitted != OmittedDisabled {
+		payload["snapshot_omitted"] = omitted
+	}
+	return payload
+}
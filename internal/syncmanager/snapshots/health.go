@@ -0,0 +1,33 @@
+package snapshots
+
+import (
+	"jarvist/internal/common/camerahealth"
+	"time"
+)
+
+// HealthTopic is appended to the MQTT base topic for the scheduled camera health reports
+const HealthTopic = "/reports/camera-health"
+
+// HealthPayload builds the MQTT payload of a health report with the camera counts per status
+func HealthPayload(report camerahealth.Report) map[string]interface{} {
+	counts := map[string]int{camerahealth.StatusOnline: 0, camerahealth.StatusOffline: 0, camerahealth.StatusUnknown: 0}
+	for _, camera := range report.Cameras {
+		counts[camera.Status]++
+	}
+
+	cameras := report.Cameras
+	if cameras == nil {
+		cameras = []camerahealth.Camera{}
+	}
+	return map[string]interface{}{
+		"type":             "camera_health",
+		"timestamp":        time.Now().Format(time.RFC3339),
+		"generated_at":     report.GeneratedAt.Format(time.RFC3339),
+		"interval_minutes": report.IntervalMinutes,
+		"total":            len(report.Cameras),
+		"online":           counts[camerahealth.StatusOnline],
+		"offline":          counts[camerahealth.StatusOffline],
+		"unknown":          counts[camerahealth.StatusUnknown],
+		"cameras":          cameras,
+	}
+}
@@ -0,0 +1,158 @@
+// Package snapshots reads the last known camera frames for MQTT payload enrichment. The
+// thumbnails are captured by the desktop app, reads go through a short in-memory cache so
+// a burst of alerts does not load the same image from the database again.
+package snapshots
+
+import (
+	"encoding/base64"
+	"jarvist/internal/common/bandwidth"
+	"jarvist/internal/common/models"
+	"jarvist/internal/common/snapshot"
+	"jarvist/pkg/logger"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ComponentSnapshots = "snapshots"
+
+	// cacheTTL is how long a thumbnail and the policy are served from memory
+	cacheTTL = time.Minute
+)
+
+// Reasons a thumbnail is left out of a payload
+const (
+	OmittedDisabled = "disabled"
+	OmittedExcluded = "excluded"
+	OmittedMissing  = "missing"
+	OmittedStale    = "stale"
+	OmittedTooLarge = "too_large"
+	OmittedMetered  = "metered"
+	OmittedError    = "error"
+)
+
+// Attachment is the last known frame of a camera as attached to a payload. In inline mode
+// Data holds the base64 JPEG, in url mode only URL is set.
+type Attachment struct {
+	Mode        string    `json:"mode"`
+	ContentType string    `json:"content_type,omitempty"`
+	Data        string    `json:"data,omitempty"`
+	URL         string    `json:"url,omitempty"`
+	Bytes       int       `json:"bytes,omitempty"`
+	CapturedAt  time.Time `json:"captured_at"`
+	AgeSec      int64     `json:"age_sec"`
+}
+
+type cached struct {
+	snapshot *models.CameraSnapshot // nil when the camera has no thumbnail
+	loadedAt time.Time
+}
+
+// Store reads camera thumbnails through a cache
+type Store struct {
+	db     *gorm.DB
+	logger *logger.Logger
+
+	mu       sync.Mutex
+	policy   snapshot.Policy
+	policyAt time.Time
+	cache    map[string]cached
+}
+
+// New creates a snapshot store
+func New(db *gorm.DB, logger *logger.Logger) *Store {
+	return &Store{
+		db:     db,
+		logger: logger,
+		cache:  make(map[string]cached),
+	}
+}
+
+// Attachment returns the last known frame of a camera for a payload, or the reason it is
+// left out. The size, age, exclusion and metered checks of the policy are applied here.
+func (s *Store) Attachment(cameraUUID string) (*Attachment, string) {
+	s.mu.Lock()
+	policy := s.currentPolicy()
+	s.mu.Unlock()
+
+	switch {
+	case !policy.Enabled:
+		return nil, OmittedDisabled
+	case !policy.Allows(cameraUUID):
+		return nil, OmittedExcluded
+	}
+
+	latest, err := s.load(cameraUUID)
+	if err != nil {
+		s.logger.Warning(ComponentSnapshots, "Failed to read snapshot of camera %s: %v", cameraUUID, err)
+		return nil, OmittedError
+	}
+	if latest == nil {
+		return nil, OmittedMissing
+	}
+
+	age := time.Since(latest.CapturedAt)
+	if age > time.Duration(policy.MaxAgeMin)*time.Minute {
+		return nil, OmittedStale
+	}
+
+	attachment := &Attachment{
+		Mode:       policy.Mode,
+		CapturedAt: latest.CapturedAt,
+		AgeSec:     int64(age.Seconds()),
+	}
+	if policy.Mode == snapshot.ModeURL {
+		attachment.URL = policy.URL(cameraUUID)
+		return attachment, ""
+	}
+
+	if latest.Size > policy.MaxBytes {
+		return nil, OmittedTooLarge
+	}
+	if policy.SkipOnMetered && bandwidth.GetPauseState(s.db).Paused {
+		return nil, OmittedMetered
+	}
+
+	attachment.ContentType = latest.ContentType
+	attachment.Data = base64.StdEncoding.EncodeToString(latest.Data)
+	attachment.Bytes = latest.Size
+	return attachment, ""
+}
+
+// load returns the thumbnail from the cache or reads it from the database
+func (s *Store) load(cameraUUID string) (*models.CameraSnapshot, error) {
+	s.mu.Lock()
+	entry, ok := s.cache[cameraUUID]
+	s.mu.Unlock()
+	if ok && time.Since(entry.loadedAt) < cacheTTL {
+		return entry.snapshot, nil
+	}
+
+	latest, err := snapshot.Latest(s.db, cameraUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	// Entri kedaluwarsa dibuang agar cache tidak tumbuh untuk kamera yang sudah dihapus
+	for uuid, old := range s.cache {
+		if time.Since(old.loadedAt) >= cacheTTL {
+			delete(s.cache, uuid)
+		}
+	}
+	s.cache[cameraUUID] = cached{snapshot: latest, loadedAt: time.Now()}
+	s.mu.Unlock()
+
+	return latest, nil
+}
+
+// currentPolicy must be called with mu held
+func (s *Store) currentPolicy() snapshot.Policy {
+	if time.Since(s.policyAt) >= cacheTTL {
+		s.policy = snapshot.LoadPolicy(s.db)
+		s.policyAt = time.Now()
+	}
+	return s.policy
+}
@@ -0,0 +1,176 @@
+// Package startup holds back the components of the sync manager on boot until the network
+// and the configured paths are ready, or the max wait is reached.
+package startup
+
+import (
+	"context"
+	"fmt"
+	baseConfig "jarvist/internal/common/config"
+	"jarvist/internal/syncmanager/network"
+	"jarvist/pkg/logger"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ComponentStartup = "startup"
+
+const (
+	checkInterval    = 2 * time.Second
+	progressInterval = 15 * time.Second
+	probeTimeout     = 3 * time.Second
+)
+
+var (
+	mu      sync.Mutex
+	current *baseConfig.StartupState
+)
+
+// Get returns the progress of the startup conditions of this process, nil before Wait ran
+func Get() *baseConfig.StartupState {
+	mu.Lock()
+	defer mu.Unlock()
+	if current == nil {
+		return nil
+	}
+	state := *current
+	return &state
+}
+
+// Wait delays the start and waits until the conditions are met, the max wait passed or ctx
+// is done. Progress is logged and recorded for the API and the desktop app. brokerHost is
+// resolved for the network condition when no host is configured.
+func Wait(ctx context.Context, conditions baseConfig.StartupConditions, brokerHost string, log *logger.Logger) baseConfig.StartupState {
+	now := time.Now()
+	state := baseConfig.StartupState{
+		Phase:     baseConfig.StartupReady,
+		StartedAt: now,
+		Deadline:  now.Add(time.Duration(conditions.DelaySec+conditions.MaxWaitSec) * time.Second),
+		PID:       os.Getpid(),
+	}
+	if !conditions.Enabled() {
+		return finish(state, baseConfig.StartupReady, log)
+	}
+
+	if conditions.DelaySec > 0 {
+		log.Info(ComponentStartup, "Delaying start by %ds", conditions.DelaySec)
+		state.Phase = baseConfig.StartupDelaying
+		record(state, log)
+
+		select {
+		case <-time.After(time.Duration(conditions.DelaySec) * time.Second):
+		case <-ctx.Done():
+			return finish(state, baseConfig.StartupTimedOut, log)
+		}
+	}
+
+	state.Phase = baseConfig.StartupWaiting
+	state.Pending = pending(conditions, brokerHost)
+	if len(state.Pending) == 0 {
+		return finish(state, baseConfig.StartupReady, log)
+	}
+
+	log.Info(ComponentStartup, "Waiting up to %ds for %s before starting", conditions.MaxWaitSec, strings.Join(state.Pending, ", "))
+	record(state, log)
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	lastProgress := time.Now()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return finish(state, baseConfig.StartupTimedOut, log)
+		}
+
+		waiting := pending(conditions, brokerHost)
+		if len(waiting) == 0 {
+			state.Pending = nil
+			log.Info(ComponentStartup, "Startup conditions met after %s", time.Since(state.StartedAt).Truncate(time.Second))
+			return finish(state, baseConfig.StartupReady, log)
+		}
+
+		if time.Now().After(state.Deadline) {
+			state.Pending = waiting
+			log.Event(logger.LevelWarn, ComponentStartup, logger.EventStartupWaitTimeout,
+				logger.F("pending", strings.Join(waiting, ", ")),
+				logger.F("waited", time.Since(state.StartedAt).Truncate(time.Second)))
+			return finish(state, baseConfig.StartupTimedOut, log)
+		}
+
+		if strings.Join(waiting, "|") != strings.Join(state.Pending, "|") || time.Since(lastProgress) >= progressInterval {
+			state.Pending = waiting
+			lastProgress = time.Now()
+			log.Info(ComponentStartup, "Still waiting for %s (%s left)", strings.Join(waiting, ", "),
+				time.Until(state.Deadline).Truncate(time.Second))
+			record(state, log)
+		}
+	}
+}
+
+func finish(state baseConfig.StartupState, phase string, log *logger.Logger) baseConfig.StartupState {
+	now := time.Now()
+	state.Phase = phase
+	state.FinishedAt = &now
+	record(state, log)
+	return state
+}
+
+// record keeps the state for the API and writes it for the desktop app, which cannot ask
+// the API while the service is still starting
+func record(state baseConfig.StartupState, log *logger.Logger) {
+	mu.Lock()
+	current = &state
+	mu.Unlock()
+
+	if err := baseConfig.SaveStartupState(state); err != nil {
+		log.Debug(ComponentStartup, "Failed to record startup state: %v", err)
+	}
+}
+
+// pending returns the conditions that are not met yet
+func pending(conditions baseConfig.StartupConditions, brokerHost string) []string {
+	var waiting []string
+	if conditions.WaitNetwork && !networkReady(conditions.NetworkHost, brokerHost) {
+		target := conditions.NetworkHost
+		if target == "" {
+			target = brokerHost
+		}
+		waiting = append(waiting, fmt.Sprintf("network (%s)", target))
+	}
+	for _, path := range conditions.WaitPaths {
+		if _, err := os.Stat(path); err != nil {
+			waiting = append(waiting, "path "+path)
+		}
+	}
+	return waiting
+}
+
+// networkReady requires a LAN address and either a connection to the configured host or,
+// without one, a resolvable broker. The broker itself may be down, that is not a reason
+// to hold back the service.
+func networkReady(host, brokerHost string) bool {
+	if !network.HasLANInterface() {
+		return false
+	}
+
+	if host != "" {
+		conn, err := net.DialTimeout("tcp", host, probeTimeout)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+
+	if brokerHost == "" || net.ParseIP(strings.Trim(brokerHost, "[]")) != nil {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	_, err := net.DefaultResolver.LookupHost(ctx, brokerHost)
+	return err == nil
+}
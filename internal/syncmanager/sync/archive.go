@@ -0,0 +1,187 @@
+package sync
+
+import (
+	"jarvist/internal/syncmanager/datafile"
+	"jarvist/pkg/logger"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const archiveCheckInterval = time.Hour
+
+// ArchiveStatus describes the archival of old date folders
+type ArchiveStatus struct {
+	ArchiveAfterDays int        `json:"archive_after_days"`
+	ArchivalFolders  int        `json:"archival_folders"`
+	ArchivedFolders  int        `json:"archived_folders"`
+	LastRunAt        *time.Time `json:"last_run_at,omitempty"`
+}
+
+// isArchivalFolder reports whether a date folder is older than ArchiveAfterDays. Archival
+// folders are not watched, scanned or compressed as waiting files, they are only processed
+// by the initial sync and on-demand resync.
+func (s *Synchronizer) isArchivalFolder(folderName string) bool {
+	days := s.config.Sync.ArchiveAfterDays
+	if days <= 0 {
+		return false
+	}
+
+	date, err := time.ParseInLocation(DateFolderPattern, folderName, time.Local)
+	if err != nil {
+		return false
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	return date.Before(today.AddDate(0, 0, -days))
+}
+
+// findActiveDateFolders returns the date folders that are not archival
+func (s *Synchronizer) findActiveDateFolders() ([]string, error) {
+	dateFolders, err := s.findDateFolders()
+	if err != nil {
+		return nil, err
+	}
+
+	active := dateFolders[:0]
+	for _, folder := range dateFolders {
+		if !s.isArchivalFolder(filepath.Base(folder)) {
+			active = append(active, folder)
+		}
+	}
+	return active, nil
+}
+
+// archiveWorker periodically archives date folders that became archival
+func (s *Synchronizer) archiveWorker() {
+	if s.config.Sync.ArchiveAfterDays <= 0 {
+		return
+	}
+
+	s.archiveOldFolders()
+
+	ticker := time.NewTicker(archiveCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.archiveOldFolders()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// archiveOldFolders stops watching archival folders and compresses their remaining plain
+// files in place, the cleanup service and replay read compressed files as well
+func (s *Synchronizer) archiveOldFolders() {
+	dateFolders, err := s.findDateFolders()
+	if err != nil {
+		s.logger.Error(ComponentSynchronizer, "Failed to find date folders for archival: %v", err)
+		return
+	}
+
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	if err != nil {
+		s.logger.Error(ComponentSynchronizer, "Failed to create zstd encoder: %v", err)
+		return
+	}
+	defer encoder.Close()
+
+	archival := 0
+	for _, folder := range dateFolders {
+		folderName := filepath.Base(folder)
+		if !s.isArchivalFolder(folderName) {
+			continue
+		}
+		archival++
+
+		s.archiveMutex.Lock()
+		done := s.archivedFolders[folderName]
+		s.archiveMutex.Unlock()
+		if done {
+			continue
+		}
+
+		if s.watcher != nil {
+			// Error berarti folder memang sudah tidak di-watch
+			s.watcher.Remove(folder)
+		}
+		s.resetScanCursor(folderName)
+
+		compressed, savedBytes, ok := s.compressFolder(encoder, folder)
+		if compressed > 0 {
+			s.logger.Event(logger.LevelInfo, ComponentSynchronizer, logger.EventSyncFolderArchived,
+				logger.F("folder", folderName), logger.F("files", compressed), logger.F("bytes", savedBytes))
+		}
+		if !ok {
+			continue
+		}
+
+		s.archiveMutex.Lock()
+		s.archivedFolders[folderName] = true
+		s.archiveMutex.Unlock()
+	}
+
+	s.archiveMutex.Lock()
+	s.archivalCount = archival
+	s.lastArchiveRun = time.Now()
+	for folderName := range s.archivedFolders {
+		if _, err := os.Stat(filepath.Join(s.config.BaseConfig.ServicesDataDir, folderName)); os.IsNotExist(err) {
+			delete(s.archivedFolders, folderName)
+		}
+	}
+	s.archiveMutex.Unlock()
+}
+
+// compressFolder compresses all plain data files of a folder, ok is false if any failed
+func (s *Synchronizer) compressFolder(encoder *zstd.Encoder, folder string) (int, int64, bool) {
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		s.logger.Warning(ComponentSynchronizer, "Failed to read archival folder %s: %v", folder, err)
+		return 0, 0, false
+	}
+
+	compressed := 0
+	var savedBytes int64
+	ok := true
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), datafile.Suffix) {
+			continue
+		}
+
+		saved, err := compressFile(encoder, filepath.Join(folder, entry.Name()))
+		if err != nil {
+			s.logger.Warning(ComponentSynchronizer, "Failed to compress %s: %v", entry.Name(), err)
+			ok = false
+			continue
+		}
+		compressed++
+		savedBytes += saved
+	}
+
+	return compressed, savedBytes, ok
+}
+
+// GetArchiveStatus returns the archival state of the date folders
+func (s *Synchronizer) GetArchiveStatus() ArchiveStatus {
+	s.archiveMutex.Lock()
+	defer s.archiveMutex.Unlock()
+
+	status := ArchiveStatus{
+		ArchiveAfterDays: s.config.Sync.ArchiveAfterDays,
+		ArchivalFolders:  s.archivalCount,
+		ArchivedFolders:  len(s.archivedFolders),
+	}
+	if !s.lastArchiveRun.IsZero() {
+		lastRun := s.lastArchiveRun
+		status.LastRunAt = &lastRun
+	}
+	return status
+}
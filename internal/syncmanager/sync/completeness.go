@@ -0,0 +1,290 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"jarvist/internal/common/models"
+	"jarvist/internal/syncmanager/datafile"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// maxCompletenessDays limits the range of a completeness report
+const maxCompletenessDays = 31
+
+// HourCompleteness counts the files of one camera in one hour of device time
+type HourCompleteness struct {
+	Hour      int `json:"hour"`
+	Produced  int `json:"produced"`
+	Processed int `json:"processed"`
+	Published int `json:"published"`
+}
+
+// CompletenessGap is a run of hours in which files were produced but not all published
+type CompletenessGap struct {
+	From        string `json:"from"` // HH:00
+	To          string `json:"to"`   // HH:00, exclusive
+	Produced    int    `json:"produced"`
+	Unprocessed int    `json:"unprocessed"`
+	Unpublished int    `json:"unpublished"`
+}
+
+// CameraCompleteness describes one camera on one day
+type CameraCompleteness struct {
+	DateFolder string             `json:"date_folder"`
+	CCTVID     int                `json:"cctv_id"`
+	Produced   int                `json:"produced"`
+	Processed  int                `json:"processed"`
+	Published  int                `json:"published"`
+	Complete   bool               `json:"complete"`
+	Hours      []HourCompleteness `json:"hours"`
+	Gaps       []CompletenessGap  `json:"gaps,omitempty"`
+}
+
+// CompletenessReport compares produced, processed and published files per camera and day
+type CompletenessReport struct {
+	From       string               `json:"from"`
+	To         string               `json:"to"`
+	Cameras    []CameraCompleteness `json:"cameras"`
+	Unreadable int                  `json:"unreadable"` // Unprocessed files whose camera could not be read
+	Time       time.Time            `json:"time"`
+}
+
+// completenessFile is one data file of a date folder, on disk or only known from the database
+type completenessFile struct {
+	cctvID    int
+	hour      int
+	processed bool
+	published bool
+}
+
+// GetCompleteness reports per camera and day how many files were produced, processed and
+// published. A file is published once its journal entry is confirmed or pruned. Unprocessed
+// files are decrypted to find their camera.
+func (s *Synchronizer) GetCompleteness(query DataQuery) (CompletenessReport, error) {
+	today := time.Now().Format(DateFolderPattern)
+	if query.From == "" {
+		query.From = today
+	}
+	if query.To == "" {
+		query.To = query.From
+	}
+
+	from, err := time.ParseInLocation(DateFolderPattern, query.From, time.Local)
+	if err != nil {
+		return CompletenessReport{}, fmt.Errorf("invalid from date: %w", err)
+	}
+	to, err := time.ParseInLocation(DateFolderPattern, query.To, time.Local)
+	if err != nil {
+		return CompletenessReport{}, fmt.Errorf("invalid to date: %w", err)
+	}
+	if to.Before(from) {
+		return CompletenessReport{}, fmt.Errorf("to date is before from date")
+	}
+	if to.Sub(from) >= maxCompletenessDays*24*time.Hour {
+		return CompletenessReport{}, fmt.Errorf("range is limited to %d days", maxCompletenessDays)
+	}
+
+	report := CompletenessReport{
+		From:    query.From,
+		To:      query.To,
+		Cameras: []CameraCompleteness{},
+		Time:    time.Now(),
+	}
+
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		folderName := day.Format(DateFolderPattern)
+
+		files, unreadable, err := s.completenessFiles(folderName)
+		if err != nil {
+			return CompletenessReport{}, err
+		}
+		report.Unreadable += unreadable
+
+		report.Cameras = append(report.Cameras, cameraCompleteness(folderName, files, query.CCTVID)...)
+	}
+
+	return report, nil
+}
+
+// completenessFiles collects the files of a date folder from disk, processed records,
+// cached data records and the journal
+func (s *Synchronizer) completenessFiles(folderName string) (map[string]*completenessFile, int, error) {
+	files := make(map[string]*completenessFile)
+
+	var processed []models.ProcessedFile
+	if err := s.db.Where("date_folder = ?", folderName).Find(&processed).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to read processed files: %w", err)
+	}
+
+	var records []models.DataRecord
+	if err := s.db.Select("filename", "cctv_id", "device_timestamp_utc").
+		Where("date_folder = ?", folderName).Find(&records).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to read data records: %w", err)
+	}
+	cached := make(map[string]models.DataRecord, len(records))
+	for _, record := range records {
+		cached[record.Filename] = record
+	}
+
+	var unconfirmed []string
+	if err := s.db.Model(&models.SyncJournal{}).
+		Where("date_folder = ? AND state <> ?", folderName, JournalConfirmed).
+		Pluck("filename", &unconfirmed).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to read sync journal: %w", err)
+	}
+	pending := make(map[string]bool, len(unconfirmed))
+	for _, filename := range unconfirmed {
+		pending[filename] = true
+	}
+
+	for _, file := range processed {
+		entry := &completenessFile{
+			cctvID:    -1,
+			hour:      file.ProcessedAt.Local().Hour(),
+			processed: true,
+			published: !pending[file.Filename],
+		}
+		if record, ok := cached[file.Filename]; ok {
+			entry.cctvID = record.CCTVID
+			if record.DeviceTimestampUTC > 0 {
+				entry.hour = deviceHour(record.DeviceTimestampUTC)
+			}
+		} else {
+			var summary struct {
+				CCTVID int `json:"cctv_id"`
+			}
+			if json.Unmarshal([]byte(file.DataJSON), &summary) == nil {
+				entry.cctvID = summary.CCTVID
+			}
+		}
+		files[file.Filename] = entry
+	}
+
+	// Folder yang sudah dihapus cleanup hanya dikenal dari database
+	folderPath := filepath.Join(s.config.BaseConfig.ServicesDataDir, folderName)
+	if info, err := os.Stat(folderPath); err != nil || !info.IsDir() {
+		return files, 0, nil
+	}
+
+	entries, err := s.dataFileEntries(folderPath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	unreadable := 0
+	for _, dirEntry := range entries {
+		relPath := filepath.Join(folderName, datafile.LogicalName(dirEntry.Name()))
+		if _, known := files[relPath]; known {
+			continue
+		}
+
+		entry := &completenessFile{cctvID: -1}
+		if info, err := dirEntry.Info(); err == nil {
+			entry.hour = info.ModTime().Hour()
+		}
+
+		data, err := decryptAndReadBSON(filepath.Join(folderPath, dirEntry.Name()), s.config.Advanced.FernetKey)
+		if err != nil {
+			unreadable++
+			continue
+		}
+		if dataEntry, err := s.mapToDataEntry(data); err == nil {
+			entry.cctvID = dataEntry.CCTVID
+			if dataEntry.DeviceTimestampUTC > 0 {
+				entry.hour = deviceHour(dataEntry.DeviceTimestampUTC)
+			}
+		}
+		files[relPath] = entry
+	}
+
+	return files, unreadable, nil
+}
+
+// cameraCompleteness groups the files of a day per camera and finds the gaps
+func cameraCompleteness(folderName string, files map[string]*completenessFile, cctvID int) []CameraCompleteness {
+	cameras := make(map[int]*CameraCompleteness)
+	for _, file := range files {
+		if cctvID > 0 && file.cctvID != cctvID {
+			continue
+		}
+
+		camera, ok := cameras[file.cctvID]
+		if !ok {
+			camera = &CameraCompleteness{
+				DateFolder: folderName,
+				CCTVID:     file.cctvID,
+				Hours:      make([]HourCompleteness, 24),
+			}
+			for hour := range camera.Hours {
+				camera.Hours[hour].Hour = hour
+			}
+			cameras[file.cctvID] = camera
+		}
+
+		hour := &camera.Hours[file.hour]
+		hour.Produced++
+		camera.Produced++
+		if file.processed {
+			hour.Processed++
+			camera.Processed++
+		}
+		if file.published {
+			hour.Published++
+			camera.Published++
+		}
+	}
+
+	result := make([]CameraCompleteness, 0, len(cameras))
+	for _, camera := range cameras {
+		camera.Gaps = completenessGaps(camera.Hours)
+		camera.Complete = camera.Published == camera.Produced
+
+		// Only hours with data are returned
+		hours := camera.Hours[:0]
+		for _, hour := range camera.Hours {
+			if hour.Produced > 0 {
+				hours = append(hours, hour)
+			}
+		}
+		camera.Hours = hours
+
+		result = append(result, *camera)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CCTVID < result[j].CCTVID
+	})
+	return result
+}
+
+// completenessGaps merges consecutive hours with unpublished files into gaps
+func completenessGaps(hours []HourCompleteness) []CompletenessGap {
+	var gaps []CompletenessGap
+	var current *CompletenessGap
+
+	for _, hour := range hours {
+		if hour.Produced == 0 || hour.Published >= hour.Produced {
+			current = nil
+			continue
+		}
+
+		if current == nil {
+			gaps = append(gaps, CompletenessGap{From: fmt.Sprintf("%02d:00", hour.Hour)})
+			current = &gaps[len(gaps)-1]
+		}
+		current.To = fmt.Sprintf("%02d:00", hour.Hour+1)
+		current.Produced += hour.Produced
+		current.Unprocessed += hour.Produced - hour.Processed
+		current.Unpublished += hour.Processed - hour.Published
+	}
+
+	return gaps
+}
+
+// deviceHour returns the local hour of a device UTC timestamp in seconds
+func deviceHour(timestampUTC float64) int {
+	return time.Unix(int64(timestampUTC), 0).Local().Hour()
+}
@@ -0,0 +1,135 @@
+package sync
+
+import (
+	"fmt"
+	"jarvist/internal/syncmanager/datafile"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const compressCheckInterval = time.Hour
+
+// compressionWorker periodically compresses unprocessed files that have been waiting too long
+func (s *Synchronizer) compressionWorker() {
+	if s.config.Sync.CompressAfterHours <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(compressCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.compressStaleFiles()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// compressStaleFiles compresses .json.bson files older than CompressAfterHours that
+// have not been processed yet. Processed files are left for the cleanup service.
+func (s *Synchronizer) compressStaleFiles() {
+	cutoff := time.Now().Add(-time.Duration(s.config.Sync.CompressAfterHours) * time.Hour)
+
+	dateFolders, err := s.findActiveDateFolders()
+	if err != nil {
+		s.logger.Error(ComponentSynchronizer, "Failed to find date folders for compression: %v", err)
+		return
+	}
+
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	if err != nil {
+		s.logger.Error(ComponentSynchronizer, "Failed to create zstd encoder: %v", err)
+		return
+	}
+	defer encoder.Close()
+
+	compressed := 0
+	var savedBytes int64
+
+	for _, folder := range dateFolders {
+		folderName := filepath.Base(folder)
+
+		entries, err := os.ReadDir(folder)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), datafile.Suffix) {
+				continue
+			}
+			processed, err := s.processed.IsProcessed(folderName, filepath.Join(folderName, entry.Name()))
+			if err != nil {
+				s.logger.Error(ComponentSynchronizer, "Failed to query processed files for compression: %v", err)
+				return
+			}
+			if processed {
+				continue
+			}
+
+			info, err := entry.Info()
+			if err != nil || info.ModTime().After(cutoff) {
+				continue
+			}
+
+			saved, err := compressFile(encoder, filepath.Join(folder, entry.Name()))
+			if err != nil {
+				s.logger.Warning(ComponentSynchronizer, "Failed to compress %s: %v", entry.Name(), err)
+				continue
+			}
+			compressed++
+			savedBytes += saved
+		}
+	}
+
+	if compressed > 0 {
+		s.logger.Info(ComponentSynchronizer, "Compressed %d waiting files, saved %d bytes", compressed, savedBytes)
+	}
+}
+
+// compressFile writes path.zst and removes the original once the compressed copy is on disk.
+// Returns the number of bytes saved.
+func compressFile(encoder *zstd.Encoder, path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	compressed := encoder.EncodeAll(data, nil)
+
+	tmpPath := path + datafile.CompressedSuffix + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := file.Write(compressed); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return 0, err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return 0, err
+	}
+	file.Close()
+
+	if err := os.Rename(tmpPath, path+datafile.CompressedSuffix); err != nil {
+		os.Remove(tmpPath)
+		return 0, err
+	}
+
+	if err := os.Remove(path); err != nil {
+		return 0, fmt.Errorf("compressed copy written but original not removed: %w", err)
+	}
+
+	return int64(len(data) - len(compressed)), nil
+}
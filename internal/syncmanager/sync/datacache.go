@@ -0,0 +1,154 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultDataQueryLimit = 100
+	MaxDataQueryLimit     = 1000
+)
+
+// DataQuery filters cached data records, dates are date folders (YYYYMMDD)
+type DataQuery struct {
+	From   string
+	To     string
+	CCTVID int
+	Limit  int
+	Offset int
+}
+
+// DataSummary is the total count of one camera on one day
+type DataSummary struct {
+	DateFolder string `json:"date_folder"`
+	CCTVID     int    `json:"cctv_id"`
+	Records    int64  `json:"records"`
+	InCount    int64  `json:"in_count"`
+	OutCount   int64  `json:"out_count"`
+	Imported   int64  `json:"imported"` // Records imported from a legacy CSV export
+}
+
+// dataRecord builds the cache row for a data entry
+func dataRecord(filename, dateFolder string, entry DataEntry) models.DataRecord {
+	return models.DataRecord{
+		Filename:           filename,
+		DateFolder:         dateFolder,
+		EntryID:            entry.ID,
+		CCTVID:             entry.CCTVID,
+		DeviceID:           entry.DeviceID,
+		DeviceTimestamp:    entry.DeviceTimestamp,
+		DeviceTimestampUTC: entry.DeviceTimestampUTC,
+		InCount:            entry.InCount,
+		OutCount:           entry.OutCount,
+		StartTime:          entry.StartTime,
+		SyncStatus:         entry.SyncStatus,
+		StoredAt:           time.Now(),
+	}
+}
+
+// recordEntry converts a cache row back to the entry that was sent
+func recordEntry(record models.DataRecord) DataEntry {
+	return DataEntry{
+		ID:                 record.EntryID,
+		CCTVID:             record.CCTVID,
+		DeviceID:           record.DeviceID,
+		DeviceTimestamp:    record.DeviceTimestamp,
+		DeviceTimestampUTC: record.DeviceTimestampUTC,
+		InCount:            record.InCount,
+		OutCount:           record.OutCount,
+		StartTime:          record.StartTime,
+		SyncStatus:         record.SyncStatus,
+	}
+}
+
+// storeDataRecord inserts the record, replacing the row of a file that is processed again,
+// and adds its counts to the lifetime totals
+func storeDataRecord(tx *gorm.DB, record models.DataRecord) error {
+	var previous *models.DataRecord
+	var existing models.DataRecord
+	if tx.Where("filename = ?", record.Filename).Limit(1).Find(&existing).RowsAffected > 0 {
+		previous = &existing
+	}
+
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "filename"}},
+		UpdateAll: true,
+	}).Create(&record).Error; err != nil {
+		return err
+	}
+	return addLifetime(tx, record, previous)
+}
+
+// cachedEntry returns the cached entry of a file, false if it is not cached
+func (s *Synchronizer) cachedEntry(filename string) (DataEntry, bool) {
+	var record models.DataRecord
+	if err := s.db.Where("filename = ?", filename).First(&record).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warning(ComponentSynchronizer, "Failed to read cached data for %s: %v", filename, err)
+		}
+		return DataEntry{}, false
+	}
+	return recordEntry(record), true
+}
+
+// QueryDataRecords returns cached records matching the query, newest device time first,
+// together with the total number of matches
+func (s *Synchronizer) QueryDataRecords(query DataQuery) ([]models.DataRecord, int64, error) {
+	if query.Limit <= 0 {
+		query.Limit = DefaultDataQueryLimit
+	}
+	if query.Limit > MaxDataQueryLimit {
+		query.Limit = MaxDataQueryLimit
+	}
+
+	db := s.filterDataRecords(query)
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count data records: %w", err)
+	}
+
+	var records []models.DataRecord
+	if err := db.Order("device_timestamp_utc DESC, id DESC").
+		Limit(query.Limit).
+		Offset(query.Offset).
+		Find(&records).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to query data records: %w", err)
+	}
+
+	return records, total, nil
+}
+
+// GetDataSummary returns in/out totals per day and camera for the query range
+func (s *Synchronizer) GetDataSummary(query DataQuery) ([]DataSummary, error) {
+	var summary []DataSummary
+	if err := s.filterDataRecords(query).
+		Select("date_folder, cctv_id, COUNT(*) as records, SUM(in_count) as in_count, SUM(out_count) as out_count, " +
+			"SUM(CASE WHEN imported THEN 1 ELSE 0 END) as imported").
+		Group("date_folder, cctv_id").
+		Order("date_folder, cctv_id").
+		Scan(&summary).Error; err != nil {
+		return nil, fmt.Errorf("failed to summarize data records: %w", err)
+	}
+	return summary, nil
+}
+
+func (s *Synchronizer) filterDataRecords(query DataQuery) *gorm.DB {
+	db := s.db.Model(&models.DataRecord{})
+	if query.From != "" {
+		db = db.Where("date_folder >= ?", query.From)
+	}
+	if query.To != "" {
+		db = db.Where("date_folder <= ?", query.To)
+	}
+	if query.CCTVID > 0 {
+		db = db.Where("cctv_id = ?", query.CCTVID)
+	}
+	return db
+}
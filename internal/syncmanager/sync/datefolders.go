@@ -0,0 +1,119 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// dateFolderCheckInterval is how often the folders of today and tomorrow are made ready
+var dateFolderCheckInterval = 5 * time.Minute
+
+// DateFolderStatus describes the date folders prepared ahead of midnight
+type DateFolderStatus struct {
+	Expected  []string   `json:"expected"`
+	Watched   []string   `json:"watched"`
+	LastCheck *time.Time `json:"last_check,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// expectedDateFolders returns the folder names of today and tomorrow in the site time zone
+// and in the time zone of the machine. The counting service may name folders by either, so
+// a file written right after midnight lands in a folder that is already watched.
+func expectedDateFolders(now time.Time, locations ...*time.Location) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, location := range locations {
+		if location == nil {
+			continue
+		}
+		today := startOfDay(now, location)
+		for _, day := range []time.Time{today, today.AddDate(0, 0, 1)} {
+			name := day.Format(DateFolderPattern)
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// dateFolderWorker keeps the folders of today and tomorrow created and watched
+func (s *Synchronizer) dateFolderWorker() {
+	s.prepareDateFolders(time.Now())
+
+	ticker := time.NewTicker(dateFolderCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.prepareDateFolders(time.Now())
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// prepareDateFolders creates the expected date folders that do not exist yet and adds them
+// to the watcher
+func (s *Synchronizer) prepareDateFolders(now time.Time) {
+	location, err := s.siteLocation("")
+	if err != nil {
+		s.logger.Warning(ComponentSynchronizer, "Site time zone not usable for date folders: %v", err)
+		location = nil
+	}
+	expected := expectedDateFolders(now, location, time.Local)
+
+	var watched []string
+	var lastError string
+	for _, name := range expected {
+		folderPath := filepath.Join(s.config.BaseConfig.ServicesDataDir, name)
+		if _, err := os.Stat(folderPath); os.IsNotExist(err) {
+			if err := os.MkdirAll(folderPath, 0755); err != nil {
+				s.logger.Warning(ComponentSynchronizer, "Failed to create date folder %s ahead of time: %v", name, err)
+				lastError = err.Error()
+				continue
+			}
+			s.logger.Info(ComponentSynchronizer, "Created date folder %s ahead of time", name)
+		}
+
+		if s.watchFolder(folderPath) {
+			watched = append(watched, name)
+		}
+	}
+
+	s.dateFolderMutex.Lock()
+	s.dateFolders = DateFolderStatus{
+		Expected:  expected,
+		Watched:   watched,
+		LastCheck: &now,
+		LastError: lastError,
+	}
+	s.dateFolderMutex.Unlock()
+}
+
+// watchFolder adds a folder to the watcher while watching is active
+func (s *Synchronizer) watchFolder(folderPath string) bool {
+	s.watchMutex.Lock()
+	defer s.watchMutex.Unlock()
+
+	if !s.watchActive || s.watcher == nil {
+		return false
+	}
+	if err := s.watcher.Add(folderPath); err != nil {
+		s.logger.Error(ComponentSynchronizer, "Failed to watch date folder %s: %v", folderPath, err)
+		return false
+	}
+	return true
+}
+
+// dateFolderStatus returns the last preparation of the date folders
+func (s *Synchronizer) dateFolderStatus() DateFolderStatus {
+	s.dateFolderMutex.Lock()
+	defer s.dateFolderMutex.Unlock()
+	return s.dateFolders
+}
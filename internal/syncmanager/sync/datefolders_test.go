@@ -0,0 +1,64 @@
+package sync
+
+import (
+	baseConfig "jarvist/internal/common/config"
+	"jarvist/internal/common/models"
+	"jarvist/internal/syncmanager/config"
+	"jarvist/pkg/logger"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpectedDateFolders(t *testing.T) {
+	jakarta := time.FixedZone("WIB", 7*60*60)
+	utc := time.UTC
+
+	// 23:30 UTC is already the next day in Jakarta
+	now := time.Date(2025, 1, 31, 23, 30, 0, 0, utc)
+	got := expectedDateFolders(now, jakarta, utc)
+	want := []string{"20250131", "20250201", "20250202"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected folders = %v, want %v", got, want)
+	}
+
+	got = expectedDateFolders(now, nil, utc)
+	if !reflect.DeepEqual(got, []string{"20250131", "20250201"}) {
+		t.Errorf("expected folders without site zone = %v", got)
+	}
+}
+
+func TestPrepareDateFoldersCreatesFolders(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&models.Setting{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	db.Create(&models.Setting{Key: SiteTimezoneKey, Value: "UTC"})
+
+	dataDir := filepath.Join(t.TempDir(), "data")
+	cfg := &config.Config{BaseConfig: &baseConfig.Config{ServicesDataDir: dataDir}}
+	s := &Synchronizer{config: cfg, db: db, logger: logger.NewLogger()}
+
+	now := time.Now()
+	s.prepareDateFolders(now)
+
+	status := s.dateFolderStatus()
+	if len(status.Expected) < 2 || status.LastError != "" || status.LastCheck == nil {
+		t.Fatalf("status = %+v", status)
+	}
+	for _, name := range status.Expected {
+		if info, err := os.Stat(filepath.Join(dataDir, name)); err != nil || !info.IsDir() {
+			t.Errorf("folder %s not created: %v", name, err)
+		}
+	}
+	tomorrow := startOfDay(now, time.UTC).AddDate(0, 0, 1).Format(DateFolderPattern)
+	if _, err := os.Stat(filepath.Join(dataDir, tomorrow)); err != nil {
+		t.Errorf("folder of tomorrow %s not created: %v", tomorrow, err)
+	}
+	// Tanpa watcher aktif folder hanya dibuat
+	if len(status.Watched) != 0 {
+		t.Errorf("watched = %v while watching is inactive", status.Watched)
+	}
+}
@@ -0,0 +1,231 @@
+package sync
+
+import (
+	"jarvist/internal/common/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultFolderStatsDays is how many date folders the statistics cover by default
+	DefaultFolderStatsDays = 30
+	// MaxFolderStatsDays limits the folders of one statistics request
+	MaxFolderStatsDays = 366
+
+	// folderTrendWindow is how many folders are averaged on each side of the trend
+	folderTrendWindow = 7
+	// folderSlowdownPercent is the increase of the time per file reported as a slowdown
+	folderSlowdownPercent = 20
+)
+
+// fileOutcome is how processing a file ended
+type fileOutcome int
+
+const (
+	outcomeQueued fileOutcome = iota
+	outcomeQuarantined
+	outcomeFailed
+)
+
+// folderTally collects the statistics of a folder until they are written
+type folderTally struct {
+	files       int64
+	entries     int64
+	quarantined int64
+	errors      int64
+	duration    time.Duration
+	maxDuration time.Duration
+	first       time.Time
+	last        time.Time
+}
+
+// FolderStats are the statistics of one date folder
+type FolderStats struct {
+	models.FolderStat
+	AvgFileMs float64 `json:"avg_file_ms"`
+	ErrorRate float64 `json:"error_rate"` // Failed attempts per attempted file
+}
+
+// FolderTrend compares the time per file of the latest folders with the folders before
+type FolderTrend struct {
+	Window            int     `json:"window"`
+	RecentAvgFileMs   float64 `json:"recent_avg_file_ms"`
+	PreviousAvgFileMs float64 `json:"previous_avg_file_ms"`
+	ChangePercent     float64 `json:"change_percent"`
+	Slowing           bool    `json:"slowing"`
+}
+
+// FolderStatsReport are the statistics of the latest date folders, newest first
+type FolderStatsReport struct {
+	Folders []FolderStats `json:"folders"`
+	Trend   FolderTrend   `json:"trend"`
+}
+
+// recordFileStat adds one processed file to the tally of its folder
+func (s *Synchronizer) recordFileStat(folderName string, started time.Time, outcome fileOutcome) {
+	now := time.Now()
+	elapsed := now.Sub(started)
+
+	s.statsMutex.Lock()
+	defer s.statsMutex.Unlock()
+
+	if s.folderTallies == nil {
+		s.folderTallies = make(map[string]*folderTally)
+	}
+	tally := s.folderTallies[folderName]
+	if tally == nil {
+		tally = &folderTally{first: started}
+		s.folderTallies[folderName] = tally
+	}
+
+	tally.files++
+	switch outcome {
+	case outcomeQueued:
+		tally.entries++
+	case outcomeQuarantined:
+		tally.quarantined++
+	case outcomeFailed:
+		tally.errors++
+	}
+	tally.duration += elapsed
+	if elapsed > tally.maxDuration {
+		tally.maxDuration = elapsed
+	}
+	tally.last = now
+
+	s.processing.observe(elapsed, outcome)
+}
+
+// flushFolderStats writes the tally of a folder at the end of its pass
+func (s *Synchronizer) flushFolderStats(folderName string) {
+	s.statsMutex.Lock()
+	tally := s.folderTallies[folderName]
+	delete(s.folderTallies, folderName)
+	s.statsMutex.Unlock()
+
+	if tally == nil {
+		return
+	}
+	if err := addFolderStat(s.db, folderName, tally); err != nil {
+		s.logger.Warning(ComponentSynchronizer, "Failed to save statistics of folder %s: %v", folderName, err)
+	}
+}
+
+// flushAllFolderStats writes every tally, files found by the watcher are counted this way
+func (s *Synchronizer) flushAllFolderStats() {
+	s.statsMutex.Lock()
+	folders := make([]string, 0, len(s.folderTallies))
+	for folderName := range s.folderTallies {
+		folders = append(folders, folderName)
+	}
+	s.statsMutex.Unlock()
+
+	for _, folderName := range folders {
+		s.flushFolderStats(folderName)
+	}
+}
+
+// addFolderStat adds a tally to the stored statistics of a folder
+func addFolderStat(db *gorm.DB, folderName string, tally *folderTally) error {
+	durationMs := tally.duration.Milliseconds()
+	maxDurationMs := tally.maxDuration.Milliseconds()
+
+	stat := models.FolderStat{
+		DateFolder:       folderName,
+		Files:            tally.files,
+		Entries:          tally.entries,
+		Quarantined:      tally.quarantined,
+		Errors:           tally.errors,
+		DurationMs:       durationMs,
+		MaxDurationMs:    maxDurationMs,
+		Passes:           1,
+		FirstProcessedAt: tally.first,
+		LastProcessedAt:  tally.last,
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "date_folder"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"files":             gorm.Expr("files + ?", tally.files),
+			"entries":           gorm.Expr("entries + ?", tally.entries),
+			"quarantined":       gorm.Expr("quarantined + ?", tally.quarantined),
+			"errors":            gorm.Expr("errors + ?", tally.errors),
+			"duration_ms":       gorm.Expr("duration_ms + ?", durationMs),
+			"max_duration_ms":   gorm.Expr("MAX(max_duration_ms, ?)", maxDurationMs),
+			"passes":            gorm.Expr("passes + 1"),
+			"last_processed_at": tally.last,
+		}),
+	}).Create(&stat).Error
+}
+
+// GetFolderStats returns the statistics of the latest date folders and how the time per file
+// changes between them
+func (s *Synchronizer) GetFolderStats(days int) (FolderStatsReport, error) {
+	if days <= 0 {
+		days = DefaultFolderStatsDays
+	}
+	if days > MaxFolderStatsDays {
+		days = MaxFolderStatsDays
+	}
+
+	var stats []models.FolderStat
+	if err := s.db.Order("date_folder DESC").Limit(days).Find(&stats).Error; err != nil {
+		return FolderStatsReport{}, err
+	}
+
+	report := FolderStatsReport{Folders: make([]FolderStats, 0, len(stats))}
+	for _, stat := range stats {
+		folder := FolderStats{FolderStat: stat}
+		if stat.Files > 0 {
+			folder.AvgFileMs = float64(stat.DurationMs) / float64(stat.Files)
+			folder.ErrorRate = float64(stat.Errors) / float64(stat.Files)
+		}
+		report.Folders = append(report.Folders, folder)
+	}
+	report.Trend = folderTrend(report.Folders)
+	return report, nil
+}
+
+// folderTrend compares the average time per file of the newest folders with the same number
+// of folders before them. Folders without files are left out.
+func folderTrend(folders []FolderStats) FolderTrend {
+	var active []FolderStats
+	for _, folder := range folders {
+		if folder.Files > 0 {
+			active = append(active, folder)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].DateFolder > active[j].DateFolder })
+
+	window := folderTrendWindow
+	if len(active) < 2*window {
+		window = len(active) / 2
+	}
+	trend := FolderTrend{Window: window}
+	if window == 0 {
+		return trend
+	}
+
+	trend.RecentAvgFileMs = avgFileMs(active[:window])
+	trend.PreviousAvgFileMs = avgFileMs(active[window : 2*window])
+	if trend.PreviousAvgFileMs > 0 {
+		trend.ChangePercent = (trend.RecentAvgFileMs - trend.PreviousAvgFileMs) / trend.PreviousAvgFileMs * 100
+		trend.Slowing = trend.ChangePercent >= folderSlowdownPercent
+	}
+	return trend
+}
+
+// avgFileMs is the time per file over several folders
+func avgFileMs(folders []FolderStats) float64 {
+	var files, durationMs int64
+	for _, folder := range folders {
+		files += folder.Files
+		durationMs += folder.DurationMs
+	}
+	if files == 0 {
+		return 0
+	}
+	return float64(durationMs) / float64(files)
+}
@@ -0,0 +1,131 @@
+package sync
+
+import (
+	"fmt"
+	"jarvist/internal/common/models"
+	"jarvist/pkg/logger"
+	"testing"
+	"time"
+)
+
+func newStatsSynchronizer(t *testing.T) *Synchronizer {
+	t.Helper()
+
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&models.FolderStat{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return &Synchronizer{db: db, logger: logger.NewLogger()}
+}
+
+func TestFolderStatsAccumulateAcrossPasses(t *testing.T) {
+	s := newStatsSynchronizer(t)
+
+	started := time.Now().Add(-40 * time.Millisecond)
+	s.recordFileStat("20250101", started, outcomeQueued)
+	s.recordFileStat("20250101", started, outcomeQuarantined)
+	s.recordFileStat("20250101", started, outcomeFailed)
+	s.flushFolderStats("20250101")
+
+	s.recordFileStat("20250101", time.Now().Add(-90*time.Millisecond), outcomeQueued)
+	s.recordFileStat("20250102", started, outcomeQueued)
+	s.flushAllFolderStats()
+
+	var stat models.FolderStat
+	if err := s.db.First(&stat, "date_folder = ?", "20250101").Error; err != nil {
+		t.Fatalf("read statistics: %v", err)
+	}
+	if stat.Files != 4 || stat.Entries != 2 || stat.Quarantined != 1 || stat.Errors != 1 || stat.Passes != 2 {
+		t.Errorf("statistics = %+v", stat)
+	}
+	if stat.DurationMs < 4*40 || stat.MaxDurationMs < 90 {
+		t.Errorf("durations = %d total, %d max", stat.DurationMs, stat.MaxDurationMs)
+	}
+	if stat.FirstProcessedAt.IsZero() || stat.LastProcessedAt.Before(stat.FirstProcessedAt) {
+		t.Errorf("processed between %v and %v", stat.FirstProcessedAt, stat.LastProcessedAt)
+	}
+
+	// Flushing without new files writes nothing
+	s.flushFolderStats("20250101")
+	s.db.First(&stat, "date_folder = ?", "20250101")
+	if stat.Passes != 2 {
+		t.Errorf("passes = %d after an empty flush", stat.Passes)
+	}
+}
+
+func TestFolderStatsTrend(t *testing.T) {
+	s := newStatsSynchronizer(t)
+
+	// Two weeks at 10 ms per file, then a week at 15 ms per file
+	for day := 1; day <= 21; day++ {
+		perFile := int64(10)
+		if day > 14 {
+			perFile = 15
+		}
+		s.db.Create(&models.FolderStat{
+			DateFolder: fmt.Sprintf("202501%02d", day),
+			Files:      100,
+			Entries:    100,
+			DurationMs: 100 * perFile,
+			Passes:     1,
+		})
+	}
+
+	report, err := s.GetFolderStats(0)
+	if err != nil {
+		t.Fatalf("folder statistics: %v", err)
+	}
+	if len(report.Folders) != 21 || report.Folders[0].DateFolder != "20250121" {
+		t.Fatalf("folders = %d, newest %s", len(report.Folders), report.Folders[0].DateFolder)
+	}
+	if report.Folders[0].AvgFileMs != 15 {
+		t.Errorf("newest folder takes %v ms per file", report.Folders[0].AvgFileMs)
+	}
+
+	trend := report.Trend
+	if trend.Window != folderTrendWindow || trend.RecentAvgFileMs != 15 || trend.PreviousAvgFileMs != 10 {
+		t.Errorf("trend = %+v", trend)
+	}
+	if trend.ChangePercent != 50 || !trend.Slowing {
+		t.Errorf("slowdown not reported: %+v", trend)
+	}
+
+	// With three folders only one is compared on each side
+	report, _ = s.GetFolderStats(3)
+	if report.Trend.Window != 1 || report.Trend.Slowing {
+		t.Errorf("trend of three folders = %+v", report.Trend)
+	}
+}
+
+func TestProcessingMetricsHistogram(t *testing.T) {
+	s := newStatsSynchronizer(t)
+
+	s.recordFileStat("20250101", time.Now().Add(-20*time.Millisecond), outcomeQueued)
+	s.recordFileStat("20250101", time.Now().Add(-2*time.Second), outcomeFailed)
+
+	metrics := s.GetProcessingMetrics()
+	if metrics.Count != 2 || metrics.Files["queued"] != 1 || metrics.Files["failed"] != 1 || metrics.Files["quarantined"] != 0 {
+		t.Fatalf("metrics = %+v", metrics)
+	}
+	for i, bound := range FileLatencyBuckets {
+		want := uint64(0)
+		switch {
+		case bound >= 2:
+			want = 2
+		case bound >= 0.05:
+			want = 1
+		}
+		if metrics.BucketCounts[i] != want {
+			t.Errorf("bucket le=%g counts %d, want %d", bound, metrics.BucketCounts[i], want)
+		}
+	}
+	if metrics.LatencySeconds < 2 {
+		t.Errorf("latency sum %gs, want at least 2s", metrics.LatencySeconds)
+	}
+
+	// Flushing the folder statistics keeps the counters
+	s.flushAllFolderStats()
+	if s.GetProcessingMetrics().Count != 2 {
+		t.Fatal("counters reset by the folder flush")
+	}
+}
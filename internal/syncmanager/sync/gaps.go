@@ -0,0 +1,428 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	baseConfig "jarvist/internal/common/config"
+	"jarvist/internal/common/models"
+	"jarvist/internal/syncmanager/scheduler"
+	"jarvist/pkg/logger"
+	"math"
+	"sort"
+	"time"
+)
+
+// JobDetectDataGaps compares the data files of each camera with the counter cadence
+const JobDetectDataGaps = "detect_data_gaps"
+
+const (
+	// gapLookback is the period checked by the scheduled detection
+	gapLookback = 24 * time.Hour
+	// gapTolerance is how many save intervals may pass without a file before it is a gap,
+	// the counter writes late under load
+	gapTolerance = 3
+
+	// backfillAckTimeout is how long the counter has to pick up a backfill request
+	backfillAckTimeout = 10 * time.Minute
+	// backfillFillTimeout is how long the re-exported files may take to close the gap
+	backfillFillTimeout = 30 * time.Minute
+)
+
+// Backfill states of a gap
+const (
+	BackfillRequested    = "requested"    // Menunggu counter mengambil permintaan
+	BackfillAcknowledged = "acknowledged" // Counter sudah mengambil, menunggu file
+	BackfillUnavailable  = "unavailable"  // Counter mengambil permintaan tetapi gap tetap ada
+	BackfillUnanswered   = "unanswered"   // Permintaan tidak diambil counter
+)
+
+var (
+	ErrGapNotFound     = errors.New("data gap not found")
+	ErrGapOpen         = errors.New("gap is still open, the counter has no data to re-export")
+	ErrGapOutOfCache   = errors.New("gap is older than the counter cache")
+	ErrBackfillPending = errors.New("a backfill of this gap is already in progress")
+)
+
+// DataGap is a period in which a camera produced fewer files than its save interval expects
+type DataGap struct {
+	ID          string       `json:"id"`
+	CCTVID      int          `json:"cctv_id"`
+	From        time.Time    `json:"from"` // Last file before the gap, or the start of the checked period
+	To          time.Time    `json:"to"`   // First file after the gap, or the end of the checked period
+	DurationSec float64      `json:"duration_sec"`
+	IntervalSec float64      `json:"interval_sec"`
+	Missing     int          `json:"missing"`             // Files expected in the gap
+	Open        bool         `json:"open"`                // No file since the gap started
+	Explained   string       `json:"explained,omitempty"` // Known reason like maintenance or sleep
+	DetectedAt  time.Time    `json:"detected_at"`
+	Backfill    *GapBackfill `json:"backfill,omitempty"`
+}
+
+// GapBackfill tracks the request to re-export the data of a gap
+type GapBackfill struct {
+	RequestID      string     `json:"request_id"`
+	State          string     `json:"state"`
+	RequestedAt    time.Time  `json:"requested_at"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+}
+
+// CameraCadence is the expected and actual data of one camera in a gap report
+type CameraCadence struct {
+	CCTVID      int        `json:"cctv_id"`
+	IntervalSec float64    `json:"interval_sec"`
+	Files       int        `json:"files"`
+	Expected    int        `json:"expected"`
+	LastFileAt  *time.Time `json:"last_file_at,omitempty"`
+}
+
+// GapReport lists the data gaps of every camera in a period
+type GapReport struct {
+	From    time.Time       `json:"from"`
+	To      time.Time       `json:"to"`
+	Cameras []CameraCadence `json:"cameras"`
+	Gaps    []DataGap       `json:"gaps"`
+	Time    time.Time       `json:"time"`
+}
+
+// GapStatus is the result of the scheduled detection, shown in the sync status
+type GapStatus struct {
+	LastRunAt        *time.Time `json:"last_run_at,omitempty"`
+	OpenGaps         int        `json:"open_gaps"`
+	UnexplainedGaps  int        `json:"unexplained_gaps"`
+	PendingBackfills int        `json:"pending_backfills"`
+	Gaps             []DataGap  `json:"gaps,omitempty"`
+}
+
+// SetGapExplainer sets the function that tells why a gap is expected, for example a
+// maintenance window or system sleep. Explained gaps are not alerted or backfilled.
+func (s *Synchronizer) SetGapExplainer(explain func(from, to time.Time) string) {
+	s.gapMutex.Lock()
+	defer s.gapMutex.Unlock()
+	s.explainGap = explain
+}
+
+// DetectGaps compares the data files of each camera in from-to with the save interval of
+// the counter. Files that were not processed yet are not counted, the trailing gap of a
+// camera is left out while the synchronizer still has files queued.
+func (s *Synchronizer) DetectGaps(from, to time.Time) (GapReport, error) {
+	if !to.After(from) {
+		return GapReport{}, fmt.Errorf("to is not after from")
+	}
+
+	cadence := s.config.BaseConfig.LoadCounterCadence()
+
+	var rows []struct {
+		CCTVID             int
+		DeviceTimestampUTC float64
+	}
+	if err := s.db.Model(&models.DataRecord{}).
+		Select("cctv_id", "device_timestamp_utc").
+		Where("device_timestamp_utc >= ? AND device_timestamp_utc < ?", float64(from.Unix()), float64(to.Unix())).
+		Order("cctv_id, device_timestamp_utc").
+		Scan(&rows).Error; err != nil {
+		return GapReport{}, fmt.Errorf("failed to read data records: %w", err)
+	}
+
+	files := make(map[int][]time.Time)
+	for _, camera := range cadence.Cameras {
+		files[camera] = nil
+	}
+	for _, row := range rows {
+		files[row.CCTVID] = append(files[row.CCTVID], unixTime(row.DeviceTimestampUTC))
+	}
+
+	cameras := make([]int, 0, len(files))
+	for camera := range files {
+		cameras = append(cameras, camera)
+	}
+	sort.Ints(cameras)
+
+	s.gapMutex.Lock()
+	explain := s.explainGap
+	s.gapMutex.Unlock()
+
+	trailing := len(s.pendingFiles) == 0
+	report := GapReport{From: from, To: to, Cameras: []CameraCadence{}, Gaps: []DataGap{}, Time: time.Now()}
+
+	for _, camera := range cameras {
+		interval := cadence.Interval(camera)
+		timestamps := files[camera]
+
+		summary := CameraCadence{
+			CCTVID:      camera,
+			IntervalSec: interval.Seconds(),
+			Files:       len(timestamps),
+			Expected:    int(to.Sub(from) / interval),
+		}
+
+		previous, err := s.lastFileBefore(camera, from)
+		if err != nil {
+			return GapReport{}, err
+		}
+
+		for _, timestamp := range timestamps {
+			if previous != nil && timestamp.Sub(*previous) > gapTolerance*interval {
+				report.Gaps = append(report.Gaps, newGap(camera, *previous, timestamp, interval, false))
+			}
+			previous = &timestamp
+		}
+
+		switch {
+		case previous == nil:
+			// Kamera belum pernah mengirim data, ID tetap agar tidak dilaporkan ulang
+			if trailing {
+				gap := newGap(camera, from, to, interval, true)
+				gap.ID = fmt.Sprintf("%d-nodata", camera)
+				report.Gaps = append(report.Gaps, gap)
+			}
+		case to.Sub(*previous) > gapTolerance*interval:
+			if trailing {
+				report.Gaps = append(report.Gaps, newGap(camera, *previous, to, interval, true))
+			}
+		}
+		if len(timestamps) > 0 {
+			summary.LastFileAt = &timestamps[len(timestamps)-1]
+		}
+
+		report.Cameras = append(report.Cameras, summary)
+	}
+
+	if explain != nil {
+		for i := range report.Gaps {
+			report.Gaps[i].Explained = explain(report.Gaps[i].From, report.Gaps[i].To)
+		}
+	}
+
+	return report, nil
+}
+
+// GetGapStatus returns the gaps found by the last scheduled detection
+func (s *Synchronizer) GetGapStatus() GapStatus {
+	s.gapMutex.Lock()
+	defer s.gapMutex.Unlock()
+
+	status := GapStatus{Gaps: make([]DataGap, 0, len(s.gaps))}
+	if !s.lastGapCheck.IsZero() {
+		lastRun := s.lastGapCheck
+		status.LastRunAt = &lastRun
+	}
+
+	for _, gap := range s.gaps {
+		status.Gaps = append(status.Gaps, *gap)
+		if gap.Open {
+			status.OpenGaps++
+		}
+		if gap.Explained == "" {
+			status.UnexplainedGaps++
+		}
+		if gap.Backfill != nil && (gap.Backfill.State == BackfillRequested || gap.Backfill.State == BackfillAcknowledged) {
+			status.PendingBackfills++
+		}
+	}
+
+	sort.Slice(status.Gaps, func(i, j int) bool {
+		if !status.Gaps[i].From.Equal(status.Gaps[j].From) {
+			return status.Gaps[i].From.Before(status.Gaps[j].From)
+		}
+		return status.Gaps[i].CCTVID < status.Gaps[j].CCTVID
+	})
+	return status
+}
+
+// gapSummary returns the gap counts without the gaps for the sync status
+func (s *Synchronizer) gapSummary() GapStatus {
+	status := s.GetGapStatus()
+	status.Gaps = nil
+	return status
+}
+
+// RequestBackfill asks the counter to re-export the data of a detected gap from its cache
+func (s *Synchronizer) RequestBackfill(gapID string) (DataGap, error) {
+	s.gapMutex.Lock()
+	defer s.gapMutex.Unlock()
+
+	gap, ok := s.gaps[gapID]
+	if !ok {
+		return DataGap{}, ErrGapNotFound
+	}
+	if err := s.requestBackfill(gap, "requested from the API"); err != nil {
+		return DataGap{}, err
+	}
+	return *gap, nil
+}
+
+// detectDataGaps is the scheduled detection. New gaps are alerted, gaps the counter still
+// has in its cache are backfilled and earlier requests are followed up.
+func (s *Synchronizer) detectDataGaps(ctx context.Context) error {
+	now := time.Now()
+	report, err := s.DetectGaps(now.Add(-gapLookback), now)
+	if err != nil {
+		return err
+	}
+
+	s.gapMutex.Lock()
+	defer s.gapMutex.Unlock()
+
+	found := make(map[string]bool, len(report.Gaps))
+	detected := 0
+	for _, gap := range report.Gaps {
+		found[gap.ID] = true
+
+		if tracked, ok := s.gaps[gap.ID]; ok {
+			gap.DetectedAt = tracked.DetectedAt
+			gap.Backfill = tracked.Backfill
+			*tracked = gap
+			continue
+		}
+
+		gap.DetectedAt = now
+		s.gaps[gap.ID] = &gap
+		detected++
+
+		if gap.Explained == "" {
+			s.logger.Event(logger.LevelWarn, ComponentSynchronizer, logger.EventDataGapDetected,
+				logger.F("cctv_id", gap.CCTVID),
+				logger.F("from", gap.From.Format(time.RFC3339)),
+				logger.F("to", gap.To.Format(time.RFC3339)),
+				logger.F("missing", gap.Missing),
+				logger.F("interval", time.Duration(gap.IntervalSec*float64(time.Second))))
+		}
+	}
+
+	for id, gap := range s.gaps {
+		if found[id] {
+			s.followBackfill(gap, now)
+			continue
+		}
+
+		// Gap tidak terdeteksi lagi: terisi oleh backfill atau sudah di luar periode
+		if gap.Backfill != nil && gap.Backfill.State != BackfillUnanswered {
+			s.logger.Info(ComponentSynchronizer, "Data gap of camera %d from %s to %s was filled by backfill",
+				gap.CCTVID, gap.From.Format(time.RFC3339), gap.To.Format(time.RFC3339))
+		}
+		delete(s.gaps, id)
+	}
+
+	for _, gap := range s.gaps {
+		if gap.Backfill == nil && gap.Explained == "" && !gap.Open && s.inCounterCache(gap) {
+			if err := s.requestBackfill(gap, "gap detected"); err != nil {
+				s.logger.Warning(ComponentSynchronizer, "Failed to request backfill of camera %d: %v", gap.CCTVID, err)
+			}
+		}
+	}
+
+	s.lastGapCheck = now
+	scheduler.SetResult(ctx, map[string]int{"gaps": len(s.gaps), "detected": detected})
+	return nil
+}
+
+// requestBackfill writes the backfill request of a gap, the caller holds gapMutex
+func (s *Synchronizer) requestBackfill(gap *DataGap, reason string) error {
+	if gap.Open {
+		return ErrGapOpen
+	}
+	if gap.Backfill != nil && (gap.Backfill.State == BackfillRequested || gap.Backfill.State == BackfillAcknowledged) {
+		return ErrBackfillPending
+	}
+	if !s.inCounterCache(gap) {
+		return ErrGapOutOfCache
+	}
+
+	request := baseConfig.BackfillRequest{
+		ID:          fmt.Sprintf("%d-%d", gap.CCTVID, time.Now().UnixNano()),
+		CCTVID:      gap.CCTVID,
+		From:        gap.From,
+		To:          gap.To,
+		Reason:      reason,
+		RequestedAt: time.Now(),
+	}
+	if err := s.config.BaseConfig.WriteBackfillRequest(request); err != nil {
+		return fmt.Errorf("failed to write backfill request: %w", err)
+	}
+
+	gap.Backfill = &GapBackfill{RequestID: request.ID, State: BackfillRequested, RequestedAt: request.RequestedAt}
+	s.logger.Event(logger.LevelInfo, ComponentSynchronizer, logger.EventDataGapBackfill,
+		logger.F("cctv_id", gap.CCTVID),
+		logger.F("from", gap.From.Format(time.RFC3339)),
+		logger.F("to", gap.To.Format(time.RFC3339)),
+		logger.F("request", request.ID))
+	return nil
+}
+
+// followBackfill moves a backfill to its next state, the caller holds gapMutex
+func (s *Synchronizer) followBackfill(gap *DataGap, now time.Time) {
+	backfill := gap.Backfill
+	if backfill == nil {
+		return
+	}
+
+	switch backfill.State {
+	case BackfillRequested:
+		if !s.config.BaseConfig.BackfillRequestPending(backfill.RequestID) {
+			backfill.State = BackfillAcknowledged
+			backfill.AcknowledgedAt = &now
+		} else if now.Sub(backfill.RequestedAt) > backfillAckTimeout {
+			if err := s.config.BaseConfig.RemoveBackfillRequest(backfill.RequestID); err != nil {
+				s.logger.Warning(ComponentSynchronizer, "Failed to withdraw backfill request %s: %v", backfill.RequestID, err)
+			}
+			backfill.State = BackfillUnanswered
+			backfill.ResolvedAt = &now
+			s.logger.Warning(ComponentSynchronizer, "Counter did not pick up backfill request %s of camera %d within %v",
+				backfill.RequestID, gap.CCTVID, backfillAckTimeout)
+		}
+	case BackfillAcknowledged:
+		if now.Sub(*backfill.AcknowledgedAt) > backfillFillTimeout {
+			backfill.State = BackfillUnavailable
+			backfill.ResolvedAt = &now
+			s.logger.Warning(ComponentSynchronizer, "Backfill of camera %d from %s to %s did not fill the gap, the counter cache has no data for it",
+				gap.CCTVID, gap.From.Format(time.RFC3339), gap.To.Format(time.RFC3339))
+		}
+	}
+}
+
+// inCounterCache reports whether the counter still keeps the data of a gap
+func (s *Synchronizer) inCounterCache(gap *DataGap) bool {
+	hours := s.config.Sync.CounterCacheHours
+	return hours > 0 && gap.From.After(time.Now().Add(-time.Duration(hours)*time.Hour))
+}
+
+// lastFileBefore returns the time of the last file of a camera before t, nil if there is none
+func (s *Synchronizer) lastFileBefore(cctvID int, t time.Time) (*time.Time, error) {
+	var last *float64
+	if err := s.db.Model(&models.DataRecord{}).
+		Select("MAX(device_timestamp_utc)").
+		Where("cctv_id = ? AND device_timestamp_utc < ?", cctvID, float64(t.Unix())).
+		Scan(&last).Error; err != nil {
+		return nil, fmt.Errorf("failed to read data records: %w", err)
+	}
+	if last == nil || *last <= 0 {
+		return nil, nil
+	}
+	timestamp := unixTime(*last)
+	return &timestamp, nil
+}
+
+func newGap(cctvID int, from, to time.Time, interval time.Duration, open bool) DataGap {
+	duration := to.Sub(from)
+	missing := int(duration/interval) - 1
+	if open {
+		missing = int(duration / interval)
+	}
+	return DataGap{
+		ID:          fmt.Sprintf("%d-%d", cctvID, from.Unix()),
+		CCTVID:      cctvID,
+		From:        from,
+		To:          to,
+		DurationSec: duration.Seconds(),
+		IntervalSec: interval.Seconds(),
+		Missing:     max(missing, 0),
+		Open:        open,
+	}
+}
+
+func unixTime(seconds float64) time.Time {
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*float64(time.Second)))
+}
@@ -0,0 +1,34 @@
+package sync
+
+import (
+	"jarvist/internal/common/identity"
+	"jarvist/internal/common/models"
+)
+
+// GetIdentity returns the identity used in data payloads and topics
+func (s *Synchronizer) GetIdentity() identity.Identity {
+	return identity.Get(s.db)
+}
+
+// UpdateIdentity validates and saves a new identity, records an audit entry and applies the
+// topic prefix to summary and heartbeat topics. Files processed afterwards use the new values.
+func (s *Synchronizer) UpdateIdentity(next identity.Identity, actor string) ([]identity.Change, error) {
+	changes, err := identity.Save(s.db, next, actor, "sync_api")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, change := range changes {
+		s.logger.Info(ComponentSynchronizer, "Identity %s changed from %q to %q by %s", change.Field, change.Old, change.New, actor)
+		if change.Field == identity.TopicPrefixKey {
+			s.config.MQTT.Topic = change.New
+		}
+	}
+
+	return changes, nil
+}
+
+// GetIdentityAudit returns the latest identity changes, newest first
+func (s *Synchronizer) GetIdentityAudit(limit int) ([]models.AuditEntry, error) {
+	return identity.GetAudit(s.db, limit)
+}
@@ -0,0 +1,532 @@
+package sync
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"jarvist/internal/common/models"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ImportAuditAction is the action name used for count imports in the audit table
+const ImportAuditAction = "data.import"
+
+const (
+	// maxImportErrors is how many row problems are listed, the rest are only counted
+	maxImportErrors = 100
+	// importBatchSize is the number of records inserted per statement
+	importBatchSize = 500
+	// importDeviceID is the device ID of imported rows without one
+	importDeviceID = "import"
+)
+
+var ErrImportNotFound = errors.New("import not found")
+
+// Column names accepted in the CSV header, exports of the older systems use different names
+var importColumns = map[string][]string{
+	"timestamp": {"timestamp", "datetime", "date_time", "device_timestamp", "start_time", "waktu"},
+	"date":      {"date", "tanggal"},
+	"time":      {"time", "hour", "jam"},
+	"cctv_id":   {"cctv_id", "cctv", "camera_id", "camera", "kamera"},
+	"in_count":  {"in_count", "in", "count_in", "enter", "masuk"},
+	"out_count": {"out_count", "out", "count_out", "exit", "keluar"},
+	"device_id": {"device_id", "device"},
+}
+
+// Timestamp formats of the older systems, dates are day first like the local exports
+var (
+	importTimestampFormats = []string{
+		"2006-01-02 15:04:05",
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02T15:04",
+		"2006/01/02 15:04:05",
+		"2006/01/02 15:04",
+		"02/01/2006 15:04:05",
+		"02/01/2006 15:04",
+		"02-01-2006 15:04:05",
+		"02-01-2006 15:04",
+	}
+	importDateFormats = []string{"2006-01-02", "2006/01/02", "02/01/2006", "02-01-2006"}
+	importTimeFormats = []string{"15:04:05", "15:04", "15"}
+)
+
+// ImportOptions controls an import of historical counts
+type ImportOptions struct {
+	Source   string // Name of the CSV file, kept in the audit entry
+	Actor    string // User recorded in the audit entry
+	Timezone string // Time zone of the timestamps without offset, empty for the local zone
+	DryRun   bool   // Only validate, nothing is stored
+}
+
+// ImportError is a problem with one line of the CSV
+type ImportError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// ImportReport is the result of an import. Nothing is stored when there are errors.
+type ImportReport struct {
+	ID          string        `json:"id,omitempty"`
+	Source      string        `json:"source,omitempty"`
+	DryRun      bool          `json:"dry_run"`
+	Rows        int           `json:"rows"`
+	Imported    int           `json:"imported"`
+	Replaced    int           `json:"replaced"`     // Rows of an earlier import of the same data
+	SkippedLive int           `json:"skipped_live"` // Rows of cameras and days the device already counted
+	Cameras     []int         `json:"cameras"`
+	From        string        `json:"from,omitempty"`
+	To          string        `json:"to,omitempty"`
+	ErrorCount  int           `json:"error_count"`
+	Errors      []ImportError `json:"errors,omitempty"`
+}
+
+// ImportSummary describes one stored import
+type ImportSummary struct {
+	ID         string    `json:"id"`
+	Records    int64     `json:"records"`
+	Cameras    int64     `json:"cameras"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	ImportedAt time.Time `json:"imported_at"`
+}
+
+// ImportCounts validates historical counts from a legacy CSV export and stores them as
+// imported data records, so the local history continues before the device was installed.
+// Rows of a camera on a day the device already counted are skipped to avoid double counts.
+// Imported records are never published or replayed.
+func (s *Synchronizer) ImportCounts(r io.Reader, opts ImportOptions) (ImportReport, error) {
+	report := ImportReport{Source: opts.Source, DryRun: opts.DryRun, Cameras: []int{}}
+
+	location := time.Local
+	if opts.Timezone != "" {
+		loc, err := time.LoadLocation(opts.Timezone)
+		if err != nil {
+			return report, fmt.Errorf("unknown time zone %q", opts.Timezone)
+		}
+		location = loc
+	}
+
+	reader, err := importReader(r)
+	if err != nil {
+		return report, err
+	}
+	header, err := reader.Read()
+	if err != nil {
+		return report, fmt.Errorf("failed to read the CSV header: %w", err)
+	}
+	columns, err := importHeader(header)
+	if err != nil {
+		return report, err
+	}
+
+	now := time.Now()
+	addError := func(line int, format string, args ...interface{}) {
+		report.ErrorCount++
+		if len(report.Errors) < maxImportErrors {
+			report.Errors = append(report.Errors, ImportError{Line: line, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+
+	var records []models.DataRecord
+	seen := make(map[string]int)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				addError(parseErr.Line, "%v", parseErr.Err)
+				continue
+			}
+			return report, fmt.Errorf("failed to read the CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if isEmptyRow(row) {
+			continue
+		}
+		report.Rows++
+
+		record, problem := importRecord(row, columns, location, now)
+		if problem != "" {
+			addError(line, "%s", problem)
+			continue
+		}
+		if first, ok := seen[record.Filename]; ok {
+			addError(line, "duplicate of line %d, same camera and timestamp", first)
+			continue
+		}
+		seen[record.Filename] = line
+		records = append(records, record)
+	}
+
+	if report.Rows == 0 {
+		return report, errors.New("the CSV has no data rows")
+	}
+	if report.ErrorCount > 0 {
+		return report, nil
+	}
+
+	records, err = s.skipLiveDays(records, &report)
+	if err != nil {
+		return report, err
+	}
+	if err := s.countReplaced(records, &report); err != nil {
+		return report, err
+	}
+	report.Imported = len(records)
+	summarizeImport(records, &report)
+
+	if opts.DryRun || len(records) == 0 {
+		return report, nil
+	}
+
+	report.ID = fmt.Sprintf("import_%d", now.UnixNano())
+	for i := range records {
+		records[i].ImportID = report.ID
+		records[i].StoredAt = now
+	}
+
+	detail, err := json.Marshal(map[string]interface{}{
+		"id":      report.ID,
+		"source":  report.Source,
+		"records": report.Imported,
+		"from":    report.From,
+		"to":      report.To,
+	})
+	if err != nil {
+		return report, err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "filename"}},
+			UpdateAll: true,
+		}).CreateInBatches(&records, importBatchSize).Error; err != nil {
+			return fmt.Errorf("failed to store imported counts: %w", err)
+		}
+		return tx.Create(&models.AuditEntry{
+			Timestamp: now,
+			Action:    ImportAuditAction,
+			Actor:     opts.Actor,
+			Source:    "sync_api",
+			Detail:    string(detail),
+		}).Error
+	})
+	if err != nil {
+		return report, err
+	}
+
+	s.logger.Info(ComponentSynchronizer, "Imported %d historical counts from %s as %s (%s to %s, %d skipped on counted days)",
+		report.Imported, report.Source, report.ID, report.From, report.To, report.SkippedLive)
+	return report, nil
+}
+
+// ListImports returns the stored imports, newest first
+func (s *Synchronizer) ListImports() ([]ImportSummary, error) {
+	// SQLite mengembalikan MIN(stored_at) sebagai teks, jadi diparse manual
+	var rows []struct {
+		ID         string
+		Records    int64
+		Cameras    int64
+		From       string
+		To         string
+		ImportedAt string
+	}
+	if err := s.db.Model(&models.DataRecord{}).
+		Select("import_id as id, COUNT(*) as records, COUNT(DISTINCT cctv_id) as cameras, "+
+			"MIN(date_folder) as \"from\", MAX(date_folder) as \"to\", MIN(stored_at) as imported_at").
+		Where("imported = ?", true).
+		Group("import_id").
+		Order("imported_at DESC").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to list imports: %w", err)
+	}
+
+	imports := make([]ImportSummary, 0, len(rows))
+	for _, row := range rows {
+		importedAt, _ := time.Parse("2006-01-02 15:04:05.999999999-07:00", row.ImportedAt)
+		imports = append(imports, ImportSummary{
+			ID:         row.ID,
+			Records:    row.Records,
+			Cameras:    row.Cameras,
+			From:       row.From,
+			To:         row.To,
+			ImportedAt: importedAt,
+		})
+	}
+	return imports, nil
+}
+
+// DeleteImport removes the records of an import, to undo an import of the wrong file
+func (s *Synchronizer) DeleteImport(id string) (int64, error) {
+	result := s.db.Where("imported = ? AND import_id = ?", true, id).Delete(&models.DataRecord{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete import: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return 0, ErrImportNotFound
+	}
+
+	s.logger.Info(ComponentSynchronizer, "Deleted %d historical counts of import %s", result.RowsAffected, id)
+	return result.RowsAffected, nil
+}
+
+// importReader strips a byte order mark and detects the delimiter, spreadsheets with a
+// comma decimal separator export with semicolons
+func importReader(r io.Reader) (*csv.Reader, error) {
+	buffered := bufio.NewReader(r)
+	if bom, err := buffered.Peek(3); err == nil && string(bom) == "\xef\xbb\xbf" {
+		buffered.Discard(3)
+	}
+
+	first, err := buffered.Peek(4096)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, fmt.Errorf("failed to read the CSV: %w", err)
+	}
+	headerLine := string(first)
+	if i := strings.IndexByte(headerLine, '\n'); i >= 0 {
+		headerLine = headerLine[:i]
+	}
+
+	reader := csv.NewReader(buffered)
+	if strings.Count(headerLine, ";") > strings.Count(headerLine, ",") {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	return reader, nil
+}
+
+// importHeader maps the known columns to their index
+func importHeader(header []string) (map[string]int, error) {
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		name = strings.ReplaceAll(name, " ", "_")
+		for column, aliases := range importColumns {
+			if _, ok := columns[column]; ok {
+				continue
+			}
+			for _, alias := range aliases {
+				if name == alias {
+					columns[column] = i
+				}
+			}
+		}
+	}
+
+	var missing []string
+	_, hasTimestamp := columns["timestamp"]
+	if _, hasDate := columns["date"]; !hasTimestamp && !hasDate {
+		missing = append(missing, "timestamp (or date and time)")
+	}
+	for _, column := range []string{"cctv_id", "in_count", "out_count"} {
+		if _, ok := columns[column]; !ok {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("the CSV header is missing %s", strings.Join(missing, ", "))
+	}
+	return columns, nil
+}
+
+// importRecord builds the data record of one row, the problem is empty if the row is valid
+func importRecord(row []string, columns map[string]int, location *time.Location, now time.Time) (models.DataRecord, string) {
+	field := func(column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	timestamp, err := importTimestamp(field("timestamp"), field("date"), field("time"), location)
+	if err != nil {
+		return models.DataRecord{}, err.Error()
+	}
+	if timestamp.After(now) {
+		return models.DataRecord{}, fmt.Sprintf("timestamp %s is in the future", timestamp.Format(time.RFC3339))
+	}
+	if timestamp.Year() < 2000 {
+		return models.DataRecord{}, fmt.Sprintf("timestamp %s is before 2000", timestamp.Format(time.RFC3339))
+	}
+
+	cctvID, err := importNumber(field("cctv_id"))
+	if err != nil || cctvID <= 0 {
+		return models.DataRecord{}, fmt.Sprintf("cctv_id %q must be a positive number", field("cctv_id"))
+	}
+	inCount, err := importNumber(field("in_count"))
+	if err != nil || inCount < 0 {
+		return models.DataRecord{}, fmt.Sprintf("in_count %q must be zero or a positive number", field("in_count"))
+	}
+	outCount, err := importNumber(field("out_count"))
+	if err != nil || outCount < 0 {
+		return models.DataRecord{}, fmt.Sprintf("out_count %q must be zero or a positive number", field("out_count"))
+	}
+
+	deviceID := field("device_id")
+	if deviceID == "" {
+		deviceID = importDeviceID
+	}
+
+	local := timestamp.Format("2006-01-02 15:04:05")
+	filename := fmt.Sprintf("imported_%d_%d", cctvID, timestamp.Unix())
+	return models.DataRecord{
+		Filename:           filename,
+		DateFolder:         timestamp.Format(DateFolderPattern),
+		EntryID:            filename,
+		CCTVID:             cctvID,
+		DeviceID:           deviceID,
+		DeviceTimestamp:    local,
+		DeviceTimestampUTC: float64(timestamp.Unix()),
+		InCount:            inCount,
+		OutCount:           outCount,
+		StartTime:          local,
+		Imported:           true,
+	}, ""
+}
+
+func importTimestamp(timestamp, date, clock string, location *time.Location) (time.Time, error) {
+	if timestamp != "" {
+		if t, err := time.Parse(time.RFC3339, timestamp); err == nil {
+			return t.In(location), nil
+		}
+		for _, format := range importTimestampFormats {
+			if t, err := time.ParseInLocation(format, timestamp, location); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("timestamp %q is not a known date and time format", timestamp)
+	}
+
+	var day time.Time
+	var err error
+	for _, format := range importDateFormats {
+		if day, err = time.ParseInLocation(format, date, location); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date %q is not a known date format", date)
+	}
+	if clock == "" {
+		return day, nil
+	}
+	for _, format := range importTimeFormats {
+		if t, err := time.Parse(format, clock); err == nil {
+			return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), t.Second(), 0, location), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("time %q is not a known time format", clock)
+}
+
+// importNumber accepts whole numbers written as decimals by spreadsheets ("12.0")
+func importNumber(value string) (int, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f != math.Trunc(f) {
+		return 0, fmt.Errorf("not a whole number")
+	}
+	return int(f), nil
+}
+
+func isEmptyRow(row []string) bool {
+	for _, value := range row {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// skipLiveDays drops the rows of cameras and days the device counted itself
+func (s *Synchronizer) skipLiveDays(records []models.DataRecord, report *ImportReport) ([]models.DataRecord, error) {
+	if len(records) == 0 {
+		return records, nil
+	}
+
+	from, to := records[0].DateFolder, records[0].DateFolder
+	for _, record := range records {
+		if record.DateFolder < from {
+			from = record.DateFolder
+		}
+		if record.DateFolder > to {
+			to = record.DateFolder
+		}
+	}
+
+	var live []struct {
+		CCTVID     int
+		DateFolder string
+	}
+	if err := s.db.Model(&models.DataRecord{}).
+		Select("DISTINCT cctv_id, date_folder").
+		Where("imported = ? AND date_folder >= ? AND date_folder <= ?", false, from, to).
+		Scan(&live).Error; err != nil {
+		return nil, fmt.Errorf("failed to read counted days: %w", err)
+	}
+	counted := make(map[string]bool, len(live))
+	for _, day := range live {
+		counted[fmt.Sprintf("%d/%s", day.CCTVID, day.DateFolder)] = true
+	}
+
+	kept := records[:0]
+	for _, record := range records {
+		if counted[fmt.Sprintf("%d/%s", record.CCTVID, record.DateFolder)] {
+			report.SkippedLive++
+			continue
+		}
+		kept = append(kept, record)
+	}
+	return kept, nil
+}
+
+// countReplaced counts the rows an earlier import of the same data already stored
+func (s *Synchronizer) countReplaced(records []models.DataRecord, report *ImportReport) error {
+	for start := 0; start < len(records); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(records) {
+			end = len(records)
+		}
+		filenames := make([]string, 0, end-start)
+		for _, record := range records[start:end] {
+			filenames = append(filenames, record.Filename)
+		}
+
+		var count int64
+		if err := s.db.Model(&models.DataRecord{}).Where("filename IN ?", filenames).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to read earlier imports: %w", err)
+		}
+		report.Replaced += int(count)
+	}
+	return nil
+}
+
+func summarizeImport(records []models.DataRecord, report *ImportReport) {
+	cameras := make(map[int]bool)
+	for _, record := range records {
+		cameras[record.CCTVID] = true
+		if report.From == "" || record.DateFolder < report.From {
+			report.From = record.DateFolder
+		}
+		if record.DateFolder > report.To {
+			report.To = record.DateFolder
+		}
+	}
+	for camera := range cameras {
+		report.Cameras = append(report.Cameras, camera)
+	}
+	sort.Ints(report.Cameras)
+}
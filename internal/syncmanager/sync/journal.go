@@ -0,0 +1,257 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"jarvist/internal/syncmanager/datafile"
+	"jarvist/pkg/logger"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Journal states, in the order a file moves through them
+const (
+	JournalIntent    = "intent"    // About to mark the file processed and enqueue its message
+	JournalPublished = "published" // Processed record and message committed together
+	JournalConfirmed = "confirmed" // Message sent to the broker
+)
+
+// Outcomes of the recovery of an in-doubt file
+const (
+	RecoveryReprocess = "reprocess" // Not marked processed, the scan processes it again
+	RecoveryEnqueued  = "enqueued"  // Its message was already stored
+	RecoveryResent    = "resent"    // Its message was sent again from the file
+	RecoveryCleared   = "cleared"   // Unreadable, processed state cleared for the scanners
+	RecoveryLost      = "lost"      // File is gone, its data could not be sent again
+	RecoveryFailed    = "failed"    // Still in-doubt, retried on the next start
+)
+
+// JournalRecovery is the result of the journal recovery on startup
+type JournalRecovery struct {
+	Total    int            `json:"total"`
+	Outcomes map[string]int `json:"outcomes"`
+	Lost     []string       `json:"lost,omitempty"`  // Files whose data could not be sent again
+	Error    string         `json:"error,omitempty"` // Set when the journal could not be read
+}
+
+// Recovered returns the number of files no longer in-doubt
+func (r JournalRecovery) Recovered() int {
+	return r.Total - r.Outcomes[RecoveryFailed]
+}
+
+const (
+	journalConfirmInterval = 5 * time.Minute
+	journalRetention       = 24 * time.Hour
+)
+
+// journalIntent records that a file is about to be marked processed
+func (s *Synchronizer) journalIntent(filename, dateFolder string) error {
+	entry := models.SyncJournal{
+		Filename:   filename,
+		DateFolder: dateFolder,
+		State:      JournalIntent,
+	}
+
+	return s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "filename"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"state":      JournalIntent,
+			"message_id": 0,
+			"updated_at": time.Now(),
+		}),
+	}).Create(&entry).Error
+}
+
+// journalPublished records the pending message that carries the file's data
+func (s *Synchronizer) journalPublished(filename string, messageID uint) {
+	err := s.db.Model(&models.SyncJournal{}).
+		Where("filename = ?", filename).
+		Updates(map[string]interface{}{
+			"state":      JournalPublished,
+			"message_id": messageID,
+			"updated_at": time.Now(),
+		}).Error
+	if err != nil {
+		// Recovery finds the message by filename, so this is not fatal
+		s.logger.Warning(ComponentSynchronizer, "Failed to journal published state for %s: %v", filename, err)
+	}
+}
+
+// recoverJournal resolves files left in-doubt by a crash. It runs once on startup before
+// the initial sync so that resolved files are not picked up again by the scan.
+//
+//   - intent without a processed record: nothing was enqueued, the entry is dropped and
+//     the file is processed again by the normal scan
+//   - intent with a processed record: the message is looked up by filename, and re-sent
+//     from the file if it was never stored
+//   - published: promoted to confirmed once the message is sent
+//
+// The result is passed to the recovery listener, also when nothing was in-doubt.
+func (s *Synchronizer) recoverJournal() {
+	result := JournalRecovery{Outcomes: make(map[string]int)}
+
+	var entries []models.SyncJournal
+	if err := s.db.Where("state = ?", JournalIntent).Find(&entries).Error; err != nil {
+		s.logger.Error(ComponentSynchronizer, "Failed to read sync journal: %v", err)
+		result.Error = err.Error()
+	}
+
+	result.Total = len(entries)
+	for _, entry := range entries {
+		outcome := s.recoverEntry(entry)
+		result.Outcomes[outcome]++
+		if outcome == RecoveryLost {
+			result.Lost = append(result.Lost, entry.Filename)
+		}
+	}
+
+	if len(entries) > 0 {
+		s.logger.Event(logger.LevelInfo, ComponentSynchronizer, logger.EventSyncJournalRecovered,
+			logger.F("recovered", result.Recovered()), logger.F("total", len(entries)))
+	}
+
+	s.confirmJournal()
+
+	if s.recoveryListener != nil {
+		s.recoveryListener(result)
+	}
+}
+
+// SetRecoveryListener receives the result of the journal recovery on startup, set it
+// before Start
+func (s *Synchronizer) SetRecoveryListener(listener func(JournalRecovery)) {
+	s.recoveryListener = listener
+}
+
+// recoverEntry resolves a single intent entry and returns the outcome
+func (s *Synchronizer) recoverEntry(entry models.SyncJournal) string {
+	var processed models.ProcessedFile
+	err := s.db.Where("filename = ?", entry.Filename).First(&processed).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logger.Info(ComponentSynchronizer, "Recovery: %s was not marked processed, will be processed again", entry.Filename)
+		s.db.Delete(&entry)
+		return RecoveryReprocess
+	} else if err != nil {
+		s.logger.Error(ComponentSynchronizer, "Recovery: failed to check %s: %v", entry.Filename, err)
+		return RecoveryFailed
+	}
+
+	// Pesan dicari dari isi payload, topic bisa berubah bila identitas site diganti sejak crash
+	var message models.PendingMessage
+	err = s.db.Where("JSON_EXTRACT(payload, '$.filename') = ? AND JSON_EXTRACT(payload, '$.date_folder') = ?", entry.Filename, entry.DateFolder).
+		Order("id DESC").
+		First(&message).Error
+	if err == nil {
+		s.logger.Info(ComponentSynchronizer, "Recovery: %s already enqueued as message %d", entry.Filename, message.ID)
+		s.journalPublished(entry.Filename, message.ID)
+		return RecoveryEnqueued
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logger.Error(ComponentSynchronizer, "Recovery: failed to look up message for %s: %v", entry.Filename, err)
+		return RecoveryFailed
+	}
+
+	filePath := s.locateDataFile(entry.Filename)
+	if filePath == "" {
+		s.logger.Event(logger.LevelError, ComponentSynchronizer, logger.EventSyncDataLost, logger.F("filename", entry.Filename))
+		s.db.Delete(&entry)
+		return RecoveryLost
+	}
+
+	data, err := decryptAndReadBSON(filePath, s.config.Advanced.FernetKey)
+	if err != nil {
+		// Hapus status processed supaya file ditangani lagi oleh scan dan integrity scanner
+		s.logger.Warning(ComponentSynchronizer, "Recovery: cannot read %s, clearing processed state: %v", entry.Filename, err)
+		s.db.Delete(&processed)
+		s.processed.Remove(processed.DateFolder, processed.Filename)
+		s.db.Delete(&entry)
+		return RecoveryCleared
+	}
+
+	messageID, err := s.sendDecryptedData(entry.Filename, entry.DateFolder, data)
+	if err != nil {
+		s.logger.Error(ComponentSynchronizer, "Recovery: failed to re-send %s: %v", entry.Filename, err)
+		return RecoveryFailed
+	}
+
+	s.logger.Info(ComponentSynchronizer, "Recovery: re-sent %s as message %d", entry.Filename, messageID)
+	s.journalPublished(entry.Filename, messageID)
+	return RecoveryResent
+}
+
+// confirmJournal promotes published entries whose message was sent and prunes old confirmed entries
+func (s *Synchronizer) confirmJournal() {
+	// Pesan yang sudah dihapus oleh cleanup dianggap selesai
+	result := s.db.Model(&models.SyncJournal{}).
+		Where("state = ?", JournalPublished).
+		Where("message_id NOT IN (?)", s.db.Model(&models.PendingMessage{}).Select("id").Where("sent = ?", false)).
+		Updates(map[string]interface{}{
+			"state":      JournalConfirmed,
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		s.logger.Error(ComponentSynchronizer, "Failed to confirm journal entries: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		s.logger.Debug(ComponentSynchronizer, "Confirmed %d journal entries", result.RowsAffected)
+	}
+
+	s.db.Where("state IN ? AND updated_at < ?", []string{JournalConfirmed, JournalQuarantined}, time.Now().Add(-journalRetention)).
+		Delete(&models.SyncJournal{})
+}
+
+// journalWorker periodically confirms sent messages
+func (s *Synchronizer) journalWorker() {
+	ticker := time.NewTicker(journalConfirmInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.confirmJournal()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// GetJournalCounts returns the number of journal entries per state
+func (s *Synchronizer) GetJournalCounts() (map[string]int64, error) {
+	var rows []struct {
+		State string
+		Count int64
+	}
+	if err := s.db.Model(&models.SyncJournal{}).
+		Select("state, COUNT(*) as count").
+		Group("state").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to count journal entries: %w", err)
+	}
+
+	counts := map[string]int64{
+		JournalIntent:      0,
+		JournalPublished:   0,
+		JournalConfirmed:   0,
+		JournalQuarantined: 0,
+	}
+	for _, row := range rows {
+		counts[row.State] = row.Count
+	}
+	return counts, nil
+}
+
+// locateDataFile returns the plain or compressed path of a logical filename, or "" if neither exists
+func (s *Synchronizer) locateDataFile(filename string) string {
+	filePath := filepath.Join(s.config.BaseConfig.ServicesDataDir, filename)
+	for _, candidate := range []string{filePath, filePath + datafile.CompressedSuffix} {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
@@ -0,0 +1,239 @@
+package sync
+
+import (
+	"jarvist/internal/common/identity"
+	"jarvist/internal/common/models"
+	"jarvist/pkg/logger"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// MilestoneStepKey is the setting holding the number of visitors between milestones
+	MilestoneStepKey = "lifetime_milestone_step"
+	// MilestoneTopic is appended to the MQTT base topic for milestone notifications
+	MilestoneTopic = "/alerts/milestone"
+
+	DefaultMilestoneStep = 100000
+	MinMilestoneStep     = 1000
+
+	// maxListedMilestones is how many of the latest milestones are returned with the totals
+	maxListedMilestones = 20
+)
+
+// LifetimeTotals are the in/out counts since install, for the site and per camera. Counts
+// imported from an earlier system are not included.
+type LifetimeTotals struct {
+	InTotal       int64                     `json:"in_total"`
+	OutTotal      int64                     `json:"out_total"`
+	Records       int64                     `json:"records"`
+	Since         *time.Time                `json:"since,omitempty"`
+	Cameras       []models.LifetimeCounter  `json:"cameras"`
+	MilestoneStep int64                     `json:"milestone_step"`
+	NextMilestone int64                     `json:"next_milestone"`
+	Milestones    []models.CounterMilestone `json:"milestones"` // Newest first
+}
+
+// GetLifetimeTotals returns the counts since install and the latest milestones
+func (s *Synchronizer) GetLifetimeTotals() (LifetimeTotals, error) {
+	totals := LifetimeTotals{
+		Cameras:       []models.LifetimeCounter{},
+		Milestones:    []models.CounterMilestone{},
+		MilestoneStep: s.milestoneStep(),
+	}
+
+	if err := s.db.Order("cctv_id").Find(&totals.Cameras).Error; err != nil {
+		return LifetimeTotals{}, err
+	}
+	for _, camera := range totals.Cameras {
+		totals.InTotal += camera.InTotal
+		totals.OutTotal += camera.OutTotal
+		totals.Records += camera.Records
+		if totals.Since == nil || camera.FirstAt.Before(*totals.Since) {
+			since := camera.FirstAt
+			totals.Since = &since
+		}
+	}
+	totals.NextMilestone = (totals.InTotal/totals.MilestoneStep + 1) * totals.MilestoneStep
+
+	if err := s.db.Order("visitors DESC").Limit(maxListedMilestones).Find(&totals.Milestones).Error; err != nil {
+		return LifetimeTotals{}, err
+	}
+	return totals, nil
+}
+
+// addLifetime adds the counts of a stored record to the lifetime totals. A file processed
+// again replaces its earlier counts, so those are taken off first.
+func addLifetime(tx *gorm.DB, record models.DataRecord, previous *models.DataRecord) error {
+	if previous != nil && !previous.Imported {
+		if err := bumpLifetime(tx, previous.CCTVID, -int64(previous.InCount), -int64(previous.OutCount), -1); err != nil {
+			return err
+		}
+	}
+	return bumpLifetime(tx, record.CCTVID, int64(record.InCount), int64(record.OutCount), 1)
+}
+
+func bumpLifetime(tx *gorm.DB, cctvID int, in, out, records int64) error {
+	now := time.Now()
+	counter := models.LifetimeCounter{
+		CCTVID:    cctvID,
+		InTotal:   in,
+		OutTotal:  out,
+		Records:   records,
+		FirstAt:   now,
+		UpdatedAt: now,
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "cctv_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"in_total":   gorm.Expr("in_total + ?", in),
+			"out_total":  gorm.Expr("out_total + ?", out),
+			"records":    gorm.Expr("records + ?", records),
+			"updated_at": now,
+		}),
+	}).Create(&counter).Error
+}
+
+// seedLifetime fills the lifetime counters from the cached data records the first time, for
+// sites that counted before the counters existed. The milestone already passed is recorded
+// as baseline so it is not announced as new.
+func (s *Synchronizer) seedLifetime() {
+	var existing int64
+	if err := s.db.Model(&models.LifetimeCounter{}).Count(&existing).Error; err != nil || existing > 0 {
+		return
+	}
+
+	var rows []struct {
+		CctvID   int
+		InTotal  int64
+		OutTotal int64
+		Records  int64
+		FirstAt  float64
+	}
+	err := s.db.Model(&models.DataRecord{}).
+		Select("cctv_id, SUM(in_count) AS in_total, SUM(out_count) AS out_total, COUNT(*) AS records, "+
+			"MIN(device_timestamp_utc) AS first_at").
+		Where("imported = ?", false).
+		Group("cctv_id").
+		Scan(&rows).Error
+	if err != nil {
+		s.logger.Warning(ComponentSynchronizer, "Failed to read data records for the lifetime counters: %v", err)
+		return
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	now := time.Now()
+	var total int64
+	counters := make([]models.LifetimeCounter, 0, len(rows))
+	for _, row := range rows {
+		firstAt := now
+		if row.FirstAt > 0 {
+			firstAt = unixTime(row.FirstAt)
+		}
+		counters = append(counters, models.LifetimeCounter{
+			CCTVID:    row.CctvID,
+			InTotal:   row.InTotal,
+			OutTotal:  row.OutTotal,
+			Records:   row.Records,
+			FirstAt:   firstAt,
+			UpdatedAt: now,
+		})
+		total += row.InTotal
+	}
+
+	step := s.milestoneStep()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&counters).Error; err != nil {
+			return err
+		}
+		if reached := total / step * step; reached > 0 {
+			return tx.Create(&models.CounterMilestone{Visitors: reached, InTotal: total, ReachedAt: now, Baseline: true}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Warning(ComponentSynchronizer, "Failed to seed the lifetime counters: %v", err)
+		return
+	}
+
+	s.logger.Info(ComponentSynchronizer, "Lifetime counters seeded from %d cameras with %d visitors", len(counters), total)
+}
+
+// checkMilestone records and announces a milestone the site total passed. When several were
+// passed at once, e.g. by a backfill, only the highest one is announced.
+func (s *Synchronizer) checkMilestone() {
+	s.milestoneMutex.Lock()
+	defer s.milestoneMutex.Unlock()
+
+	var totals struct {
+		InTotal  int64
+		OutTotal int64
+	}
+	if err := s.db.Model(&models.LifetimeCounter{}).
+		Select("COALESCE(SUM(in_total), 0) AS in_total, COALESCE(SUM(out_total), 0) AS out_total").
+		Scan(&totals).Error; err != nil {
+		return
+	}
+
+	step := s.milestoneStep()
+	reached := totals.InTotal / step * step
+	if reached <= 0 {
+		return
+	}
+
+	var last int64
+	if err := s.db.Model(&models.CounterMilestone{}).Select("COALESCE(MAX(visitors), 0)").Scan(&last).Error; err != nil || reached <= last {
+		return
+	}
+
+	milestone := models.CounterMilestone{Visitors: reached, InTotal: totals.InTotal, ReachedAt: time.Now()}
+	if err := s.db.Create(&milestone).Error; err != nil {
+		s.logger.Warning(ComponentSynchronizer, "Failed to record milestone of %d visitors: %v", reached, err)
+		return
+	}
+
+	s.logger.Event(logger.LevelInfo, ComponentSynchronizer, logger.EventVisitorMilestone,
+		logger.F("visitors", reached), logger.F("total", totals.InTotal))
+
+	if s.mqttSender == nil {
+		return
+	}
+
+	id := identity.Get(s.db)
+	payload := map[string]interface{}{
+		"type":       "visitor_milestone",
+		"visitors":   reached,
+		"in_total":   totals.InTotal,
+		"out_total":  totals.OutTotal,
+		"step":       step,
+		"tenant_id":  id.TenantID,
+		"client_id":  id.ClientID,
+		"site_id":    id.SiteID,
+		"reached_at": milestone.ReachedAt.Format(time.RFC3339),
+	}
+	if len(id.Tags) > 0 {
+		payload["tags"] = id.Tags
+	}
+
+	messageID, err := s.mqttSender.SendData(s.config.MQTT.Topic+MilestoneTopic, payload)
+	if err != nil {
+		s.logger.Warning(ComponentSynchronizer, "Failed to queue milestone notification: %v", err)
+		return
+	}
+	s.db.Model(&milestone).Update("message_id", messageID)
+}
+
+// milestoneStep returns the number of visitors between milestones
+func (s *Synchronizer) milestoneStep() int64 {
+	value, _ := s.GetSetting(MilestoneStepKey)
+	step, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || step < MinMilestoneStep {
+		return DefaultMilestoneStep
+	}
+	return step
+}
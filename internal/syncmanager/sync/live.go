@@ -0,0 +1,40 @@
+package sync
+
+import (
+	"jarvist/internal/common/models"
+	"time"
+)
+
+// LiveStatus is the small sync status the desktop app polls for its live view, cheap enough
+// to be read every few seconds
+type LiveStatus struct {
+	PendingFiles  int        `json:"pending_files"`
+	InSyncProcess bool       `json:"in_sync_process"`
+	WatcherActive bool       `json:"watcher_active"`
+	LastFolder    string     `json:"last_folder,omitempty"`
+	LastFile      string     `json:"last_file,omitempty"`
+	LastSyncedAt  *time.Time `json:"last_synced_at,omitempty"`
+}
+
+// GetLiveStatus returns the files waiting for processing and the last processed file
+func (s *Synchronizer) GetLiveStatus() LiveStatus {
+	s.mu.Lock()
+	status := LiveStatus{
+		PendingFiles:  len(s.pendingFiles),
+		InSyncProcess: s.inSyncProcess,
+	}
+	s.mu.Unlock()
+
+	s.watchMutex.Lock()
+	status.WatcherActive = s.watchActive
+	s.watchMutex.Unlock()
+
+	var last models.ProcessedFile
+	result := s.db.Select("filename", "date_folder", "processed_at").Order("id DESC").Limit(1).Find(&last)
+	if result.Error == nil && result.RowsAffected > 0 {
+		status.LastFolder = last.DateFolder
+		status.LastFile = last.Filename
+		status.LastSyncedAt = &last.ProcessedAt
+	}
+	return status
+}
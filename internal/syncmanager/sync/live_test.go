@@ -0,0 +1,33 @@
+package sync
+
+import (
+	"jarvist/internal/common/models"
+	"jarvist/pkg/logger"
+	"testing"
+	"time"
+)
+
+func TestLiveStatusReportsLastProcessedFile(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&models.ProcessedFile{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	s := &Synchronizer{db: db, logger: logger.NewLogger(), pendingFiles: make(chan string, 10)}
+
+	if status := s.GetLiveStatus(); status.LastSyncedAt != nil || status.PendingFiles != 0 {
+		t.Fatalf("status without files = %+v", status)
+	}
+
+	processedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	db.Create(&models.ProcessedFile{Filename: "a.json", DateFolder: "20250101", ProcessedAt: processedAt.Add(-time.Hour)})
+	db.Create(&models.ProcessedFile{Filename: "b.json", DateFolder: "20250102", ProcessedAt: processedAt})
+	s.pendingFiles <- "c.json"
+
+	status := s.GetLiveStatus()
+	if status.PendingFiles != 1 || status.LastFolder != "20250102" || status.LastFile != "b.json" {
+		t.Fatalf("status = %+v", status)
+	}
+	if status.LastSyncedAt == nil || !status.LastSyncedAt.Equal(processedAt) {
+		t.Fatalf("last synced at %v, want %v", status.LastSyncedAt, processedAt)
+	}
+}
@@ -0,0 +1,129 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"jarvist/internal/syncmanager/scheduler"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Maintenance job names
+const (
+	JobRebuildProcessedCache = "rebuild_processed_cache"
+	JobRescanDataDir         = "rescan_data_dir"
+)
+
+// RescanResult is the result of a full rescan of the data directory
+type RescanResult struct {
+	Folders   int `json:"folders"`
+	Processed int `json:"processed"`
+}
+
+// RegisterJobs adds the jobs of the synchronizer to the scheduler. The maintenance jobs only
+// run on demand, the data gap detection runs every 10 minutes.
+func (s *Synchronizer) RegisterJobs(jobs *scheduler.Scheduler) error {
+	if err := jobs.Add(scheduler.Job{
+		Name:        JobDetectDataGaps,
+		Description: "Find cameras with fewer data files than the counter save interval expects and request backfills",
+		Schedule:    "*/10 * * * *",
+		Jitter:      time.Minute,
+		Singleton:   true,
+		Run:         s.detectDataGaps,
+	}); err != nil {
+		return err
+	}
+
+	if err := jobs.Add(scheduler.Job{
+		Name:        JobRebuildProcessedCache,
+		Description: "Reload the processed file cache from the database",
+		Singleton:   true,
+		Run:         s.rebuildProcessedCache,
+	}); err != nil {
+		return err
+	}
+
+	return jobs.Add(scheduler.Job{
+		Name:        JobRescanDataDir,
+		Description: "Scan every date folder, archival ones included, for files that were not processed",
+		Singleton:   true,
+		Locks:       []string{scheduler.LockDataFiles},
+		Run:         s.rescanDataDir,
+	})
+}
+
+// rebuildProcessedCache drops the processed file cache and loads the most recent folders
+func (s *Synchronizer) rebuildProcessedCache(ctx context.Context) error {
+	dateFolders, err := s.findActiveDateFolders()
+	if err != nil {
+		return err
+	}
+
+	folders := make([]string, 0, len(dateFolders))
+	for _, folder := range dateFolders {
+		folders = append(folders, filepath.Base(folder))
+	}
+	// Folder terbaru dimuat terakhir agar berada di depan LRU
+	sort.Strings(folders)
+	if len(folders) > processedCacheFolders {
+		folders = folders[len(folders)-processedCacheFolders:]
+	}
+
+	if err := s.processed.Rebuild(folders); err != nil {
+		return err
+	}
+
+	stats := s.processed.Stats()
+	scheduler.SetResult(ctx, stats)
+	s.logger.Info(ComponentSynchronizer, "Processed file cache rebuilt with %d folders and %d files", stats.Folders, stats.Files)
+	return nil
+}
+
+// rescanDataDir processes every unprocessed file of all date folders, like the initial
+// sync but including archival folders
+func (s *Synchronizer) rescanDataDir(ctx context.Context) error {
+	if !s.isRunning() {
+		return errors.New("synchronizer is not running")
+	}
+
+	s.mu.Lock()
+	if s.inSyncProcess {
+		s.mu.Unlock()
+		return errors.New("a scan is already in progress")
+	}
+	if s.suspended {
+		s.mu.Unlock()
+		return errors.New("system is suspending")
+	}
+	s.inSyncProcess = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.inSyncProcess = false
+		s.mu.Unlock()
+	}()
+
+	dateFolders, err := s.findDateFolders()
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info(ComponentSynchronizer, "Rescanning %d date folders", len(dateFolders))
+	start := time.Now()
+
+	result := RescanResult{}
+	for _, folder := range dateFolders {
+		if err := ctx.Err(); err != nil {
+			scheduler.SetResult(ctx, result)
+			return err
+		}
+		result.Processed += s.processFolderFiles(folder, filepath.Base(folder))
+		result.Folders++
+	}
+
+	scheduler.SetResult(ctx, result)
+	s.logger.Info(ComponentSynchronizer, "Rescan of %d folders processed %d files in %v", result.Folders, result.Processed, time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,66 @@
+package sync
+
+import "time"
+
+// FileLatencyBuckets are the upper bounds in seconds of the file processing latency histogram
+var FileLatencyBuckets = []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// outcomeNames label the processed files by how processing ended
+var outcomeNames = map[fileOutcome]string{
+	outcomeQueued:      "queued",
+	outcomeQuarantined: "quarantined",
+	outcomeFailed:      "failed",
+}
+
+// ProcessingMetrics counts the files processed since the service started, for the metrics
+// exporter. Unlike the folder statistics they are never reset.
+type ProcessingMetrics struct {
+	// Files counts the processed files by outcome: queued, quarantined or failed
+	Files map[string]uint64 `json:"files"`
+	// BucketCounts are the cumulative file counts per bound of FileLatencyBuckets
+	BucketCounts   []uint64 `json:"bucket_counts"`
+	LatencySeconds float64  `json:"latency_seconds"`
+	Count          uint64   `json:"count"`
+}
+
+// processingCounters are kept under statsMutex
+type processingCounters struct {
+	files   map[fileOutcome]uint64
+	buckets []uint64
+	sum     time.Duration
+	count   uint64
+}
+
+func (p *processingCounters) observe(elapsed time.Duration, outcome fileOutcome) {
+	if p.files == nil {
+		p.files = make(map[fileOutcome]uint64, len(outcomeNames))
+		p.buckets = make([]uint64, len(FileLatencyBuckets))
+	}
+	p.files[outcome]++
+	seconds := elapsed.Seconds()
+	for i, bound := range FileLatencyBuckets {
+		if seconds <= bound {
+			p.buckets[i]++
+		}
+	}
+	p.sum += elapsed
+	p.count++
+}
+
+// GetProcessingMetrics returns the file counters and latency histogram since the start
+func (s *Synchronizer) GetProcessingMetrics() ProcessingMetrics {
+	s.statsMutex.Lock()
+	defer s.statsMutex.Unlock()
+
+	metrics := ProcessingMetrics{
+		Files:          make(map[string]uint64, len(outcomeNames)),
+		BucketCounts:   make([]uint64, len(FileLatencyBuckets)),
+		LatencySeconds: s.processing.sum.Seconds(),
+		Count:          s.processing.count,
+	}
+	copy(metrics.BucketCounts, s.processing.buckets)
+	for outcome, name := range outcomeNames {
+		metrics.Files[name] = s.processing.files[outcome]
+	}
+	return metrics
+}
@@ -0,0 +1,47 @@
+package sync
+
+import (
+	"jarvist/internal/syncmanager/power"
+	"time"
+)
+
+// Suspend stops the file watcher and pauses scans before the machine sleeps
+func (s *Synchronizer) Suspend() {
+	s.mu.Lock()
+	s.suspended = true
+	s.mu.Unlock()
+
+	s.stopWatching()
+}
+
+// Resume restarts the file watcher and runs a full scan, files written while the watcher
+// was stopped or whose events were lost during sleep are picked up
+func (s *Synchronizer) Resume(window power.SleepWindow) {
+	s.mu.Lock()
+	s.suspended = false
+	s.mu.Unlock()
+
+	if !s.isRunning() {
+		return
+	}
+
+	go func() {
+		s.watchMutex.Lock()
+		hasWatcher := s.watcher != nil && !s.watcherClosed
+		s.watchMutex.Unlock()
+
+		if hasWatcher {
+			s.stopWatching()
+			s.startWatching()
+		}
+
+		// Scan berikutnya menjadi full scan
+		s.cursorMutex.Lock()
+		s.lastFullScan = time.Time{}
+		s.cursorMutex.Unlock()
+
+		s.logger.Info(ComponentSynchronizer, "Rescanning after %s of sleep",
+			time.Duration(window.DurationSec*float64(time.Second)).Truncate(time.Second))
+		s.scanForNewFiles()
+	}()
+}
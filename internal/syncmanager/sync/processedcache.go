@@ -0,0 +1,175 @@
+package sync
+
+import (
+	"container/list"
+	"jarvist/internal/common/models"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Processed file lookups are cached per date folder. Scans and watcher event storms only
+// touch a few recent folders, so a small LRU avoids loading the whole processed_files table.
+const (
+	processedCacheFolders = 31
+	processedCacheTTL     = 10 * time.Minute // Picks up records removed by cleanup or integrity checks
+)
+
+// ProcessedCacheStats describes the processed file lookup cache
+type ProcessedCacheStats struct {
+	Folders  int    `json:"folders"`
+	Files    int    `json:"files"`
+	Capacity int    `json:"capacity"`
+	Hits     uint64 `json:"hits"`
+	Misses   uint64 `json:"misses"`
+	Loads    uint64 `json:"loads"`
+}
+
+type processedFolder struct {
+	folder   string
+	files    map[string]struct{}
+	loadedAt time.Time
+}
+
+// processedCache is an LRU of date folders holding the processed filenames of each folder
+type processedCache struct {
+	db       *gorm.DB
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // Front is most recently used
+	folders  map[string]*list.Element
+	hits     uint64
+	misses   uint64
+	loads    uint64
+}
+
+func newProcessedCache(db *gorm.DB, capacity int) *processedCache {
+	return &processedCache{
+		db:       db,
+		capacity: capacity,
+		order:    list.New(),
+		folders:  make(map[string]*list.Element),
+	}
+}
+
+// IsProcessed reports whether filename (relative to the data directory) of the date folder
+// has been processed, the folder is loaded with a single query on first use
+func (c *processedCache) IsProcessed(folder, filename string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, err := c.folderLocked(folder)
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := entry.files[filename]; ok {
+		c.hits++
+		return true, nil
+	}
+	c.misses++
+	return false, nil
+}
+
+// Add marks a file as processed if its folder is cached
+func (c *processedCache) Add(folder, filename string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.folders[folder]; ok {
+		elem.Value.(*processedFolder).files[filename] = struct{}{}
+	}
+}
+
+// Remove forgets a processed file, used when its record is deleted
+func (c *processedCache) Remove(folder, filename string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.folders[folder]; ok {
+		delete(elem.Value.(*processedFolder).files, filename)
+	}
+}
+
+// InvalidateFolder drops a folder so that it is reloaded on the next lookup
+func (c *processedCache) InvalidateFolder(folder string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.folders[folder]; ok {
+		c.order.Remove(elem)
+		delete(c.folders, folder)
+	}
+}
+
+// Rebuild drops all folders and loads the given ones again from the database
+func (c *processedCache) Rebuild(folders []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.folders = make(map[string]*list.Element)
+
+	for _, folder := range folders {
+		if _, err := c.folderLocked(folder); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats returns the cache size and hit counters
+func (c *processedCache) Stats() ProcessedCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := ProcessedCacheStats{
+		Folders:  len(c.folders),
+		Capacity: c.capacity,
+		Hits:     c.hits,
+		Misses:   c.misses,
+		Loads:    c.loads,
+	}
+	for _, elem := range c.folders {
+		stats.Files += len(elem.Value.(*processedFolder).files)
+	}
+	return stats
+}
+
+func (c *processedCache) folderLocked(folder string) (*processedFolder, error) {
+	if elem, ok := c.folders[folder]; ok {
+		entry := elem.Value.(*processedFolder)
+		if time.Since(entry.loadedAt) < processedCacheTTL {
+			c.order.MoveToFront(elem)
+			return entry, nil
+		}
+		c.order.Remove(elem)
+		delete(c.folders, folder)
+	}
+
+	var filenames []string
+	if err := c.db.Model(&models.ProcessedFile{}).
+		Where("date_folder = ?", folder).
+		Pluck("filename", &filenames).Error; err != nil {
+		return nil, err
+	}
+	c.loads++
+
+	entry := &processedFolder{
+		folder:   folder,
+		files:    make(map[string]struct{}, len(filenames)),
+		loadedAt: time.Now(),
+	}
+	for _, filename := range filenames {
+		entry.files[filename] = struct{}{}
+	}
+
+	c.folders[folder] = c.order.PushFront(entry)
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.folders, oldest.Value.(*processedFolder).folder)
+	}
+	return entry, nil
+}
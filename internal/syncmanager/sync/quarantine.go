@@ -0,0 +1,270 @@
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// QuarantineAuditAction is the action name used for quarantine reviews in the audit table
+const QuarantineAuditAction = "data.quarantine"
+
+// Quarantine statuses
+const (
+	QuarantinePending   = "quarantined"
+	QuarantineReleased  = "released"
+	QuarantineDiscarded = "discarded"
+)
+
+// JournalQuarantined marks a file whose entry was quarantined instead of published
+const JournalQuarantined = "quarantined"
+
+var (
+	ErrQuarantineNotFound = errors.New("quarantined entry not found")
+	ErrQuarantineReviewed = errors.New("entry was already reviewed")
+
+	// errQuarantined is returned by recordAndEnqueue for a file that was quarantined
+	errQuarantined = errors.New("entry quarantined")
+)
+
+// QuarantineItem is a quarantined entry with its data and the violated rules
+type QuarantineItem struct {
+	ID            uint        `json:"id"`
+	Filename      string      `json:"filename"`
+	DateFolder    string      `json:"date_folder"`
+	CCTVID        int         `json:"cctv_id"`
+	Data          DataEntry   `json:"data"`
+	Violations    []Violation `json:"violations"`
+	Status        string      `json:"status"`
+	QuarantinedAt time.Time   `json:"quarantined_at"`
+	ReviewedAt    *time.Time  `json:"reviewed_at,omitempty"`
+	ReviewedBy    string      `json:"reviewed_by,omitempty"`
+	MessageID     uint        `json:"message_id,omitempty"`
+}
+
+// quarantineEntry marks the file processed and keeps its entry for review instead of
+// caching and publishing it, all in one transaction like a published file
+func (s *Synchronizer) quarantineEntry(file models.ProcessedFile, entry DataEntry, violations []Violation) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	reasons, err := json.Marshal(violations)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&file).Error; err != nil {
+			return fmt.Errorf("failed to mark file as processed: %w", err)
+		}
+
+		quarantined := models.QuarantinedEntry{
+			Filename:      file.Filename,
+			DateFolder:    file.DateFolder,
+			CCTVID:        entry.CCTVID,
+			Entry:         string(data),
+			Reasons:       string(reasons),
+			Status:        QuarantinePending,
+			QuarantinedAt: time.Now(),
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "filename"}},
+			UpdateAll: true,
+		}).Create(&quarantined).Error; err != nil {
+			return fmt.Errorf("failed to quarantine entry: %w", err)
+		}
+
+		return setJournalState(tx, file.Filename, file.DateFolder, JournalQuarantined, 0)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.processed.Add(file.DateFolder, file.Filename)
+	s.logger.Warning(ComponentSynchronizer, "Quarantined data of %s (camera %d): %s",
+		file.Filename, entry.CCTVID, violations[0].Message)
+	return nil
+}
+
+// ListQuarantine returns quarantined entries with the given status, newest first, and the
+// number of entries per status. An empty status lists entries waiting for review.
+func (s *Synchronizer) ListQuarantine(status string, limit int) ([]QuarantineItem, map[string]int64, error) {
+	if status == "" {
+		status = QuarantinePending
+	}
+	if limit <= 0 || limit > MaxDataQueryLimit {
+		limit = DefaultDataQueryLimit
+	}
+
+	var rows []models.QuarantinedEntry
+	if err := s.db.Where("status = ?", status).Order("id DESC").Limit(limit).Find(&rows).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to read quarantined entries: %w", err)
+	}
+
+	items := make([]QuarantineItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, quarantineItem(row))
+	}
+
+	var counts []struct {
+		Status string
+		Count  int64
+	}
+	if err := s.db.Model(&models.QuarantinedEntry{}).Select("status, COUNT(*) AS count").Group("status").Scan(&counts).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to count quarantined entries: %w", err)
+	}
+	totals := map[string]int64{QuarantinePending: 0, QuarantineReleased: 0, QuarantineDiscarded: 0}
+	for _, count := range counts {
+		totals[count.Status] = count.Count
+	}
+
+	return items, totals, nil
+}
+
+// ReleaseQuarantined publishes a quarantined entry the operator accepted, its data is cached
+// and queued exactly like a file that passed validation
+func (s *Synchronizer) ReleaseQuarantined(id uint, actor string) (QuarantineItem, error) {
+	if s.mqttSender == nil {
+		return QuarantineItem{}, fmt.Errorf("MQTT sender not initialized")
+	}
+
+	row, err := s.pendingQuarantine(id)
+	if err != nil {
+		return QuarantineItem{}, err
+	}
+
+	var entry DataEntry
+	if err := json.Unmarshal([]byte(row.Entry), &entry); err != nil {
+		return QuarantineItem{}, fmt.Errorf("invalid quarantined entry: %w", err)
+	}
+	topic, payload := s.entryMessage(row.Filename, row.DateFolder, entry)
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := storeDataRecord(tx, dataRecord(row.Filename, row.DateFolder, entry)); err != nil {
+			return fmt.Errorf("failed to cache data: %w", err)
+		}
+
+		messageID, err := s.mqttSender.StoreData(tx, topic, payload)
+		if err != nil {
+			return err
+		}
+		if err := setJournalState(tx, row.Filename, row.DateFolder, JournalPublished, messageID); err != nil {
+			return err
+		}
+
+		row.MessageID = messageID
+		return reviewQuarantine(tx, &row, QuarantineReleased, actor)
+	})
+	if err != nil {
+		return QuarantineItem{}, err
+	}
+
+	s.mqttSender.Dispatch(row.MessageID, topic)
+	s.checkMilestone()
+	s.logger.Info(ComponentSynchronizer, "Released quarantined data of %s as message %d (by %s)", row.Filename, row.MessageID, actor)
+	return quarantineItem(row), nil
+}
+
+// DiscardQuarantined drops a quarantined entry for good, it stays listed for reference
+func (s *Synchronizer) DiscardQuarantined(id uint, actor string) (QuarantineItem, error) {
+	row, err := s.pendingQuarantine(id)
+	if err != nil {
+		return QuarantineItem{}, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		return reviewQuarantine(tx, &row, QuarantineDiscarded, actor)
+	})
+	if err != nil {
+		return QuarantineItem{}, err
+	}
+
+	s.logger.Info(ComponentSynchronizer, "Discarded quarantined data of %s (by %s)", row.Filename, actor)
+	return quarantineItem(row), nil
+}
+
+func (s *Synchronizer) pendingQuarantine(id uint) (models.QuarantinedEntry, error) {
+	var row models.QuarantinedEntry
+	if err := s.db.First(&row, id).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return row, ErrQuarantineNotFound
+	} else if err != nil {
+		return row, err
+	}
+	if row.Status != QuarantinePending {
+		return row, fmt.Errorf("%w: %s", ErrQuarantineReviewed, row.Status)
+	}
+	return row, nil
+}
+
+// reviewQuarantine stores the decision of the operator with an audit entry
+func reviewQuarantine(tx *gorm.DB, row *models.QuarantinedEntry, status, actor string) error {
+	now := time.Now()
+	row.Status = status
+	row.ReviewedAt = &now
+	row.ReviewedBy = actor
+	if err := tx.Save(row).Error; err != nil {
+		return err
+	}
+
+	detail, err := json.Marshal(map[string]interface{}{
+		"id":       row.ID,
+		"filename": row.Filename,
+		"cctv_id":  row.CCTVID,
+		"decision": status,
+		"reasons":  json.RawMessage(row.Reasons),
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.AuditEntry{
+		Timestamp: now,
+		Action:    QuarantineAuditAction,
+		Actor:     actor,
+		Source:    "sync_api",
+		Detail:    string(detail),
+	}).Error
+}
+
+// setJournalState writes the journal entry of a file inside tx
+func setJournalState(tx *gorm.DB, filename, dateFolder, state string, messageID uint) error {
+	entry := models.SyncJournal{
+		Filename:   filename,
+		DateFolder: dateFolder,
+		State:      state,
+		MessageID:  messageID,
+	}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "filename"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"state":      state,
+			"message_id": messageID,
+			"updated_at": time.Now(),
+		}),
+	}).Create(&entry).Error; err != nil {
+		return fmt.Errorf("failed to write journal entry: %w", err)
+	}
+	return nil
+}
+
+func quarantineItem(row models.QuarantinedEntry) QuarantineItem {
+	item := QuarantineItem{
+		ID:            row.ID,
+		Filename:      row.Filename,
+		DateFolder:    row.DateFolder,
+		CCTVID:        row.CCTVID,
+		Status:        row.Status,
+		QuarantinedAt: row.QuarantinedAt,
+		ReviewedAt:    row.ReviewedAt,
+		ReviewedBy:    row.ReviewedBy,
+		MessageID:     row.MessageID,
+	}
+	json.Unmarshal([]byte(row.Entry), &item.Data)
+	json.Unmarshal([]byte(row.Reasons), &item.Violations)
+	return item
+}
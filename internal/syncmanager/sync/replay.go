@@ -0,0 +1,247 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"sort"
+	"time"
+)
+
+// Replay states
+const (
+	ReplayRunning   = "running"
+	ReplayCompleted = "completed"
+	ReplayCancelled = "cancelled"
+	ReplayFailed    = "failed"
+)
+
+const (
+	DefaultReplayRate = 5  // messages per second
+	MaxReplayRate     = 50 // messages per second
+	MaxReplayDays     = 31
+)
+
+// ReplayStatus describes the current or last replay
+type ReplayStatus struct {
+	ID            string     `json:"id"`
+	State         string     `json:"state"`
+	From          string     `json:"from"`
+	To            string     `json:"to"`
+	RatePerSecond int        `json:"rate_per_second"`
+	Total         int        `json:"total"`
+	Sent          int        `json:"sent"`
+	Missing       int        `json:"missing"`
+	Failed        int        `json:"failed"`
+	LastError     string     `json:"last_error,omitempty"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+}
+
+// StartReplay republishes the data of all processed files in the date range (inclusive),
+// throttled to rate messages per second. Only one replay runs at a time.
+func (s *Synchronizer) StartReplay(from, to time.Time, rate int) (ReplayStatus, error) {
+	if s.mqttSender == nil {
+		return ReplayStatus{}, errors.New("MQTT sender not initialized")
+	}
+	if to.Before(from) {
+		return ReplayStatus{}, errors.New("end date is before start date")
+	}
+	if to.Sub(from) > MaxReplayDays*24*time.Hour {
+		return ReplayStatus{}, fmt.Errorf("date range is limited to %d days", MaxReplayDays)
+	}
+	if rate <= 0 {
+		rate = DefaultReplayRate
+	}
+	if rate > MaxReplayRate {
+		rate = MaxReplayRate
+	}
+
+	s.replayMutex.Lock()
+	defer s.replayMutex.Unlock()
+
+	if s.replay != nil && s.replay.State == ReplayRunning {
+		return *s.replay, errors.New("a replay is already running")
+	}
+
+	fromFolder := from.Format(DateFolderPattern)
+	toFolder := to.Format(DateFolderPattern)
+
+	files, err := s.replayCandidates(fromFolder, toFolder)
+	if err != nil {
+		return ReplayStatus{}, err
+	}
+
+	now := time.Now()
+	s.replay = &ReplayStatus{
+		ID:            fmt.Sprintf("replay-%d", now.UnixNano()),
+		State:         ReplayRunning,
+		From:          fromFolder,
+		To:            toFolder,
+		RatePerSecond: rate,
+		Total:         len(files),
+		StartedAt:     now,
+	}
+	s.replayCancel = make(chan struct{})
+
+	s.logger.Info(ComponentSynchronizer, "Starting replay %s of %d files from %s to %s at %d msg/s",
+		s.replay.ID, len(files), fromFolder, toFolder, rate)
+
+	go s.runReplay(s.replay.ID, files, rate, s.replayCancel)
+
+	return *s.replay, nil
+}
+
+// replayCandidates returns the files to replay in a date folder range. Cached data outlives
+// the processed file records, so both are included. Imported history was never sent by this
+// device and is left out.
+func (s *Synchronizer) replayCandidates(fromFolder, toFolder string) ([]models.ProcessedFile, error) {
+	var files []models.ProcessedFile
+	if err := s.db.Where("date_folder >= ? AND date_folder <= ?", fromFolder, toFolder).
+		Order("date_folder, filename").
+		Find(&files).Error; err != nil {
+		return nil, fmt.Errorf("failed to query processed files: %w", err)
+	}
+
+	var records []models.DataRecord
+	if err := s.db.Select("filename, date_folder, stored_at").
+		Where("date_folder >= ? AND date_folder <= ? AND imported = ?", fromFolder, toFolder, false).
+		Where("filename NOT IN (?)", s.db.Model(&models.ProcessedFile{}).Select("filename")).
+		Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to query cached data: %w", err)
+	}
+
+	for _, record := range records {
+		files = append(files, models.ProcessedFile{
+			Filename:    record.Filename,
+			DateFolder:  record.DateFolder,
+			ProcessedAt: record.StoredAt,
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].DateFolder != files[j].DateFolder {
+			return files[i].DateFolder < files[j].DateFolder
+		}
+		return files[i].Filename < files[j].Filename
+	})
+
+	return files, nil
+}
+
+// GetReplayStatus returns the current or last replay, nil if none has run
+func (s *Synchronizer) GetReplayStatus() *ReplayStatus {
+	s.replayMutex.Lock()
+	defer s.replayMutex.Unlock()
+
+	if s.replay == nil {
+		return nil
+	}
+	status := *s.replay
+	return &status
+}
+
+// CancelReplay stops the running replay, messages already queued are still sent
+func (s *Synchronizer) CancelReplay() error {
+	s.replayMutex.Lock()
+	defer s.replayMutex.Unlock()
+
+	if s.replay == nil || s.replay.State != ReplayRunning {
+		return errors.New("no replay is running")
+	}
+
+	close(s.replayCancel)
+	return nil
+}
+
+func (s *Synchronizer) runReplay(replayID string, files []models.ProcessedFile, rate int, cancel chan struct{}) {
+	ticker := time.NewTicker(time.Second / time.Duration(rate))
+	defer ticker.Stop()
+
+	state := ReplayCompleted
+
+loop:
+	for _, file := range files {
+		select {
+		case <-cancel:
+			state = ReplayCancelled
+			break loop
+		case <-s.stopCh:
+			state = ReplayCancelled
+			break loop
+		case <-ticker.C:
+		}
+
+		err := s.replayFile(replayID, file)
+
+		s.replayMutex.Lock()
+		switch {
+		case err == nil:
+			s.replay.Sent++
+		case errors.Is(err, errReplayMissing):
+			s.replay.Missing++
+		default:
+			s.replay.Failed++
+			s.replay.LastError = err.Error()
+		}
+		s.replayMutex.Unlock()
+	}
+
+	s.replayMutex.Lock()
+	finished := time.Now()
+	if state == ReplayCompleted && s.replay.Sent == 0 && s.replay.Failed > 0 {
+		state = ReplayFailed
+	}
+	s.replay.State = state
+	s.replay.FinishedAt = &finished
+	status := *s.replay
+	s.replayMutex.Unlock()
+
+	s.logger.Info(ComponentSynchronizer, "Replay %s %s: %d sent, %d missing, %d failed of %d",
+		replayID, state, status.Sent, status.Missing, status.Failed, status.Total)
+}
+
+var errReplayMissing = errors.New("data file no longer available")
+
+// replayFile rebuilds the payload of a processed file and queues it marked as a replay.
+// The cached data is used when available, otherwise the file is read again.
+func (s *Synchronizer) replayFile(replayID string, file models.ProcessedFile) error {
+	topic, payload, err := s.replayMessage(file)
+	if err != nil {
+		return err
+	}
+
+	payload["processed_at"] = file.ProcessedAt.Format(time.RFC3339)
+	payload["replay"] = map[string]interface{}{
+		"id":          replayID,
+		"replayed_at": time.Now().Format(time.RFC3339),
+	}
+
+	if _, err := s.mqttSender.SendData(topic, payload); err != nil {
+		return fmt.Errorf("%s: %w", file.Filename, err)
+	}
+	return nil
+}
+
+func (s *Synchronizer) replayMessage(file models.ProcessedFile) (string, map[string]interface{}, error) {
+	if entry, ok := s.cachedEntry(file.Filename); ok {
+		topic, payload := s.entryMessage(file.Filename, file.DateFolder, entry)
+		return topic, payload, nil
+	}
+
+	filePath := s.locateDataFile(file.Filename)
+	if filePath == "" {
+		return "", nil, errReplayMissing
+	}
+
+	data, err := decryptAndReadBSON(filePath, s.config.Advanced.FernetKey)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", file.Filename, err)
+	}
+
+	topic, payload, err := s.dataMessage(file.Filename, file.DateFolder, data)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", file.Filename, err)
+	}
+	return topic, payload, nil
+}
@@ -0,0 +1,169 @@
+package sync
+
+import (
+	"jarvist/internal/syncmanager/datafile"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// fullScanInterval is how often the periodic scan ignores the cursors and reads every folder
+const fullScanInterval = time.Hour
+
+// scanCursor is the high-water mark of a date folder: files up to modTime/name have been
+// handled and the folder is skipped while its own modification time is unchanged
+type scanCursor struct {
+	dirModTime time.Time
+	modTime    time.Time
+	name       string
+}
+
+// covers reports whether a file is at or below the cursor
+func (c scanCursor) covers(modTime time.Time, name string) bool {
+	if modTime.Equal(c.modTime) {
+		return name <= c.name
+	}
+	return modTime.Before(c.modTime)
+}
+
+// ScanCursorStatus describes the incremental scan state
+type ScanCursorStatus struct {
+	TrackedFolders int        `json:"tracked_folders"`
+	LastFullScan   *time.Time `json:"last_full_scan,omitempty"`
+	NextFullScan   *time.Time `json:"next_full_scan,omitempty"`
+}
+
+// startScan prepares the cursors for a periodic scan: cursors of folders that no longer
+// exist are dropped, and all cursors are cleared when a full scan is due. Returns true
+// for a full scan.
+func (s *Synchronizer) startScan(dateFolders []string) bool {
+	s.cursorMutex.Lock()
+	defer s.cursorMutex.Unlock()
+
+	if time.Since(s.lastFullScan) >= fullScanInterval {
+		s.scanCursors = make(map[string]scanCursor)
+		s.lastFullScan = time.Now()
+		return true
+	}
+
+	existing := make(map[string]bool, len(dateFolders))
+	for _, folder := range dateFolders {
+		existing[filepath.Base(folder)] = true
+	}
+	for folder := range s.scanCursors {
+		if !existing[folder] {
+			delete(s.scanCursors, folder)
+		}
+	}
+	return false
+}
+
+// processFolderSince processes the files of a folder that are newer than its cursor and
+// moves the cursor forward. The cursor stops before the first file that failed so it is
+// tried again on the next scan.
+func (s *Synchronizer) processFolderSince(folderPath, folderName string) int {
+	dirInfo, err := os.Stat(folderPath)
+	if err != nil {
+		s.logger.Error(ComponentSynchronizer, "Failed to stat directory %s: %v", folderName, err)
+		return 0
+	}
+
+	s.cursorMutex.Lock()
+	cursor, tracked := s.scanCursors[folderName]
+	s.cursorMutex.Unlock()
+
+	if tracked && dirInfo.ModTime().Equal(cursor.dirModTime) {
+		return 0
+	}
+
+	entries, err := s.dataFileEntries(folderPath)
+	if err != nil {
+		s.logger.Error(ComponentSynchronizer, "Failed to read directory %s: %v", folderName, err)
+		return 0
+	}
+
+	type candidate struct {
+		name    string
+		modTime time.Time
+	}
+	var candidates []candidate
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if tracked && cursor.covers(info.ModTime(), entry.Name()) {
+			continue
+		}
+		candidates = append(candidates, candidate{name: entry.Name(), modTime: info.ModTime()})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].modTime.Equal(candidates[j].modTime) {
+			return candidates[i].name < candidates[j].name
+		}
+		return candidates[i].modTime.Before(candidates[j].modTime)
+	})
+
+	processedCount := 0
+	advance := true
+
+	for _, file := range candidates {
+		relPath := filepath.Join(folderName, datafile.LogicalName(file.name))
+
+		processed, err := s.processed.IsProcessed(folderName, relPath)
+		if err != nil {
+			s.logger.Error(ComponentSynchronizer, "Database error checking file %s: %v", relPath, err)
+			advance = false
+			continue
+		}
+
+		if !processed {
+			filePath := filepath.Join(folderPath, file.name)
+			if err := s.processFile(filePath, relPath, folderName); err != nil {
+				s.logger.Error(ComponentSynchronizer, "Error processing file %s: %v", filePath, err)
+				advance = false
+				continue
+			}
+			processedCount++
+		}
+
+		if advance {
+			cursor.modTime = file.modTime
+			cursor.name = file.name
+		}
+	}
+
+	// Keep the old folder time after a failure so the folder is read again next scan
+	if advance {
+		cursor.dirModTime = dirInfo.ModTime()
+	}
+
+	s.cursorMutex.Lock()
+	s.scanCursors[folderName] = cursor
+	s.cursorMutex.Unlock()
+
+	s.flushFolderStats(folderName)
+	return processedCount
+}
+
+// resetScanCursor makes the next scan read the whole folder again
+func (s *Synchronizer) resetScanCursor(folderName string) {
+	s.cursorMutex.Lock()
+	defer s.cursorMutex.Unlock()
+	delete(s.scanCursors, folderName)
+}
+
+func (s *Synchronizer) scanCursorStatus() ScanCursorStatus {
+	s.cursorMutex.Lock()
+	defer s.cursorMutex.Unlock()
+
+	status := ScanCursorStatus{TrackedFolders: len(s.scanCursors)}
+	if !s.lastFullScan.IsZero() {
+		last, next := s.lastFullScan, s.lastFullScan.Add(fullScanInterval)
+		status.LastFullScan = &last
+		status.NextFullScan = &next
+	}
+	return status
+}
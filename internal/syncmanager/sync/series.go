@@ -0,0 +1,201 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"time"
+)
+
+// Bucket sizes of a data series
+const (
+	BucketAuto = "auto"
+	BucketHour = "hour"
+	BucketDay  = "day"
+	BucketWeek = "week"
+)
+
+const (
+	// SiteTimezoneKey is the setting holding the time zone of the site
+	SiteTimezoneKey = "default_timezone"
+
+	// Ranges up to these lengths are shown per hour and per day, longer ranges per week
+	autoHourlyRange = 3 * 24 * time.Hour
+	autoDailyRange  = 92 * 24 * time.Hour
+
+	// maxSeriesBuckets keeps a series small enough to chart
+	maxSeriesBuckets = 2000
+	// seriesSlot is the size of the pre-aggregated slots read from the database, every time
+	// zone offset is a multiple of it so slots never cross a bucket boundary
+	seriesSlot = 15 * 60
+)
+
+// SeriesQuery selects a data series. Only the dates of From and To are used, they are days
+// in the site time zone and To is included.
+type SeriesQuery struct {
+	From     time.Time
+	To       time.Time
+	CCTVID   int
+	Bucket   string // auto, hour, day or week, empty is auto
+	Timezone string // Empty uses the time zone of the site
+}
+
+// SeriesBucket holds the in/out totals from Start until the start of the next bucket
+type SeriesBucket struct {
+	Start    time.Time `json:"start"`
+	Records  int64     `json:"records"`
+	InCount  int64     `json:"in_count"`
+	OutCount int64     `json:"out_count"`
+}
+
+// DataSeries is a downsampled series of the in/out counts. Every bucket of the range is
+// listed, empty ones with zero counts, so series of different cameras line up.
+type DataSeries struct {
+	Bucket   string         `json:"bucket"`
+	Timezone string         `json:"timezone"`
+	From     time.Time      `json:"from"`
+	To       time.Time      `json:"to"` // Exclusive
+	CCTVID   int            `json:"cctv_id,omitempty"`
+	Buckets  []SeriesBucket `json:"buckets"`
+	InCount  int64          `json:"in_count"`
+	OutCount int64          `json:"out_count"`
+}
+
+// AutoBucket returns the bucket size used for a range when none is given
+func AutoBucket(span time.Duration) string {
+	switch {
+	case span <= autoHourlyRange:
+		return BucketHour
+	case span <= autoDailyRange:
+		return BucketDay
+	default:
+		return BucketWeek
+	}
+}
+
+// GetDataSeries sums the cached data records into hourly, daily or weekly buckets. Buckets
+// start on the hour, at midnight and on Monday midnight in the site time zone, so daylight
+// saving changes give 23 or 25 hour days instead of shifted boundaries.
+func (s *Synchronizer) GetDataSeries(query SeriesQuery) (DataSeries, error) {
+	location, err := s.siteLocation(query.Timezone)
+	if err != nil {
+		return DataSeries{}, err
+	}
+
+	from := time.Date(query.From.Year(), query.From.Month(), query.From.Day(), 0, 0, 0, 0, location)
+	to := time.Date(query.To.Year(), query.To.Month(), query.To.Day(), 0, 0, 0, 0, location).AddDate(0, 0, 1)
+	if !to.After(from) {
+		return DataSeries{}, errors.New("to is before from")
+	}
+
+	bucket := query.Bucket
+	if bucket == "" || bucket == BucketAuto {
+		bucket = AutoBucket(to.Sub(from))
+	}
+	if bucket != BucketHour && bucket != BucketDay && bucket != BucketWeek {
+		return DataSeries{}, fmt.Errorf("unknown bucket %q, expected %s, %s, %s or %s", bucket, BucketAuto, BucketHour, BucketDay, BucketWeek)
+	}
+	if bucket == BucketWeek {
+		from = bucketStart(from, bucket)
+		if start := bucketStart(to, bucket); !start.Equal(to) {
+			to = nextBucket(start, bucket)
+		}
+	}
+
+	series := DataSeries{
+		Bucket:   bucket,
+		Timezone: location.String(),
+		From:     from,
+		To:       to,
+		CCTVID:   query.CCTVID,
+		Buckets:  []SeriesBucket{},
+	}
+	index := make(map[int64]int)
+	for start := from; start.Before(to); start = nextBucket(start, bucket) {
+		if len(series.Buckets) == maxSeriesBuckets {
+			return DataSeries{}, fmt.Errorf("the range has more than %d %s buckets, choose a larger bucket", maxSeriesBuckets, bucket)
+		}
+		index[start.Unix()] = len(series.Buckets)
+		series.Buckets = append(series.Buckets, SeriesBucket{Start: start})
+	}
+
+	// Dijumlahkan dulu per 15 menit di database agar baris yang dibaca tetap sedikit
+	var slots []struct {
+		Slot     int64
+		Records  int64
+		InCount  int64
+		OutCount int64
+	}
+	db := s.db.Model(&models.DataRecord{}).
+		Select(fmt.Sprintf("CAST(device_timestamp_utc / %d AS INTEGER) AS slot, COUNT(*) AS records, "+
+			"SUM(in_count) AS in_count, SUM(out_count) AS out_count", seriesSlot)).
+		Where("device_timestamp_utc >= ? AND device_timestamp_utc < ?", float64(from.Unix()), float64(to.Unix()))
+	if query.CCTVID > 0 {
+		db = db.Where("cctv_id = ?", query.CCTVID)
+	}
+	if err := db.Group("slot").Order("slot").Scan(&slots).Error; err != nil {
+		return DataSeries{}, fmt.Errorf("failed to read data records: %w", err)
+	}
+
+	for _, slot := range slots {
+		start := bucketStart(time.Unix(slot.Slot*seriesSlot, 0).In(location), bucket)
+		i, ok := index[start.Unix()]
+		if !ok {
+			continue
+		}
+		series.Buckets[i].Records += slot.Records
+		series.Buckets[i].InCount += slot.InCount
+		series.Buckets[i].OutCount += slot.OutCount
+		series.InCount += slot.InCount
+		series.OutCount += slot.OutCount
+	}
+
+	return series, nil
+}
+
+// siteLocation returns the named time zone, or the time zone of the site when name is empty
+func (s *Synchronizer) siteLocation(name string) (*time.Location, error) {
+	if name == "" {
+		name, _ = s.GetSetting(SiteTimezoneKey)
+	}
+	if name == "" {
+		return time.Local, nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return location, nil
+}
+
+// bucketStart returns the start of the bucket holding t, in the location of t
+func bucketStart(t time.Time, bucket string) time.Time {
+	switch bucket {
+	case BucketHour:
+		// Dipotong dari waktu absolut, jam yang terulang saat DST berakhir tetap dua bucket
+		return t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	case BucketWeek:
+		day := startOfDay(t, t.Location())
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	default:
+		return startOfDay(t, t.Location())
+	}
+}
+
+// nextBucket returns the start of the bucket after the one starting at start
+func nextBucket(start time.Time, bucket string) time.Time {
+	switch bucket {
+	case BucketHour:
+		return bucketStart(start.Add(time.Hour), bucket)
+	case BucketWeek:
+		return start.AddDate(0, 0, 7)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+func startOfDay(t time.Time, location *time.Location) time.Time {
+	t = t.In(location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
+}
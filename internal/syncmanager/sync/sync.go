@@ -5,9 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"jarvist/internal/common/identity"
 	"jarvist/internal/common/models"
 	"jarvist/internal/syncmanager/config"
+	"jarvist/internal/syncmanager/datafile"
 	"jarvist/internal/syncmanager/mqtt"
+	"jarvist/internal/syncmanager/tracing"
+	"jarvist/internal/syncmanager/uptime"
 	"jarvist/pkg/logger"
 	"os"
 	"path/filepath"
@@ -16,15 +20,16 @@ import (
 	"sync"
 	"time"
 
-	"github.com/fernet/fernet-go"
 	"github.com/fsnotify/fsnotify"
-	"gopkg.in/mgo.v2/bson"
 	"gorm.io/gorm"
 )
 
 const (
 	ComponentSynchronizer = "synchronizer"
 	DateFolderPattern     = "20060102"
+
+	// workerStopTimeout is how long Stop waits for the workers to exit
+	workerStopTimeout = 10 * time.Second
 )
 
 // Synchronizer handles file synchronization using a file watcher approach
@@ -36,14 +41,58 @@ type Synchronizer struct {
 	mu            sync.Mutex
 	db            *gorm.DB
 	mqttSender    *mqtt.Sender
+	workers       sync.WaitGroup
 
 	// Watcher related fields
-	watcher      *fsnotify.Watcher
-	watchCtx     context.Context
-	watchCancel  context.CancelFunc
-	watchMutex   sync.Mutex
-	watchActive  bool
-	pendingFiles chan string
+	watcher       *fsnotify.Watcher
+	watcherClosed bool
+	watchCtx      context.Context
+	watchCancel   context.CancelFunc
+	watchMutex    sync.Mutex
+	watchActive   bool
+	pendingFiles  chan string
+
+	// Replay of already-sent data
+	replayMutex  sync.Mutex
+	replay       *ReplayStatus
+	replayCancel chan struct{}
+
+	processed *processedCache
+
+	// Per-folder cursors of the periodic scan
+	cursorMutex  sync.Mutex
+	scanCursors  map[string]scanCursor
+	lastFullScan time.Time
+
+	// Archival of old date folders
+	archiveMutex    sync.Mutex
+	archivedFolders map[string]bool
+	archivalCount   int
+	lastArchiveRun  time.Time
+
+	// Scans are paused while the machine sleeps
+	suspended bool
+
+	// Data gaps found by the scheduled detection, by gap ID
+	gapMutex     sync.Mutex
+	gaps         map[string]*DataGap
+	lastGapCheck time.Time
+	explainGap   func(from, to time.Time) string
+
+	// Milestones of the lifetime visitor count
+	milestoneMutex sync.Mutex
+
+	// Processing statistics per date folder, written at the end of each folder pass
+	statsMutex    sync.Mutex
+	folderTallies map[string]*folderTally
+	processing    processingCounters
+
+	// Folders of today and tomorrow, created and watched before files arrive
+	dateFolderMutex sync.Mutex
+	dateFolders     DateFolderStatus
+
+	// Receives the result of the journal recovery on startup
+	recoveryListener func(JournalRecovery)
 }
 
 type DataEntry struct {
@@ -79,6 +128,16 @@ func NewSynchronizer(config *config.Config, logger *logger.Logger, db *gorm.DB,
 		watchCancel:  watchCancel,
 		watchActive:  false,
 		pendingFiles: make(chan string, 1000), // Buffer for pending files
+		processed:    newProcessedCache(db, processedCacheFolders),
+		scanCursors:  make(map[string]scanCursor),
+
+		archivedFolders: make(map[string]bool),
+		gaps:            make(map[string]*DataGap),
+	}
+
+	// Summary and heartbeat topics follow the identity topic prefix once it has been set
+	if prefix := identity.Get(db).TopicPrefix; prefix != identity.DefaultTopicPrefix {
+		config.MQTT.Topic = prefix
 	}
 
 	// If watcher creation failed, we'll set up a recovery mechanism
@@ -145,6 +204,24 @@ func (s *Synchronizer) Start() error {
 	}
 	s.mu.Unlock()
 
+	// Sites that counted before the lifetime counters existed start from their cached data
+	s.seedLifetime()
+
+	// Stop closes the watcher, create a new one when restarting
+	s.watchMutex.Lock()
+	if s.watcherClosed {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			s.logger.Error(ComponentSynchronizer, "Failed to create file watcher: %v", err)
+			s.watcher = nil
+			go s.recoverWatcher()
+		} else {
+			s.watcher = watcher
+		}
+		s.watcherClosed = false
+	}
+	s.watchMutex.Unlock()
+
 	// Initial sync to catch files created before the watcher was started
 	go func() {
 		// Create the data directory if it doesn't exist
@@ -156,6 +233,9 @@ func (s *Synchronizer) Start() error {
 			}
 		}
 
+		// Resolve files left in-doubt by a crash before scanning for new ones
+		s.recoverJournal()
+
 		s.SyncData()
 
 		// Check if watcher exists
@@ -172,13 +252,25 @@ func (s *Synchronizer) Start() error {
 	}()
 
 	// Start processing workers
-	go s.processPendingFiles()
+	s.goWorker(s.processPendingFiles)
+
+	// Compress backlog files that have been waiting too long
+	s.goWorker(s.compressionWorker)
+
+	// Stop watching and compress date folders that became archival
+	s.goWorker(s.archiveWorker)
+
+	// Confirm journal entries once their messages are sent
+	s.goWorker(s.journalWorker)
+
+	// Create and watch the next date folder before midnight
+	s.goWorker(s.dateFolderWorker)
 
 	// Periodic folder scan to catch any missed files
 	interval := time.Duration(s.config.Sync.Interval) * time.Second
 	ticker := time.NewTicker(interval)
 
-	go func() {
+	s.goWorker(func() {
 		defer ticker.Stop()
 
 		for {
@@ -190,11 +282,20 @@ func (s *Synchronizer) Start() error {
 				return
 			}
 		}
-	}()
+	})
 
 	return nil
 }
 
+// goWorker runs fn in a goroutine that Stop waits for
+func (s *Synchronizer) goWorker(fn func()) {
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		fn()
+	}()
+}
+
 // isChanClosed checks if a channel is closed
 func isChanClosed(ch chan struct{}) bool {
 	select {
@@ -210,22 +311,51 @@ func (s *Synchronizer) Stop() error {
 	s.logger.Info(ComponentSynchronizer, "Stopping synchronizer...")
 
 	// Signal all goroutines to stop
+	s.mu.Lock()
+	if isChanClosed(s.stopCh) {
+		s.mu.Unlock()
+		return nil
+	}
 	close(s.stopCh)
+	s.mu.Unlock()
 
 	// Stop the watcher
 	s.stopWatching()
 
 	// Close the watcher
+	s.watchMutex.Lock()
 	if s.watcher != nil {
 		s.watcher.Close()
+		s.watcherClosed = true
 	}
+	s.watchMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
 
-	time.Sleep(1 * time.Second)
+	select {
+	case <-done:
+	case <-time.After(workerStopTimeout):
+		s.logger.Warning(ComponentSynchronizer, "Timed out waiting for workers to stop")
+	}
+	s.flushAllFolderStats()
 
 	s.logger.Info(ComponentSynchronizer, "Synchronizer stopped")
 	return nil
 }
 
+// HealthCheck reports whether the synchronizer workers are running. The file watcher is
+// started after the initial sync, until then the periodic scan picks up new files.
+func (s *Synchronizer) HealthCheck() error {
+	if !s.isRunning() {
+		return errors.New("synchronizer is not running")
+	}
+	return nil
+}
+
 // startWatching begins watching for file changes
 func (s *Synchronizer) startWatching() {
 	s.watchMutex.Lock()
@@ -251,12 +381,12 @@ func (s *Synchronizer) startWatching() {
 		return
 	}
 
-	// Find and watch all date folders
-	dateFolders, err := s.findDateFolders()
+	// Find and watch all active date folders, archival folders are left unwatched
+	dateFolders, err := s.findActiveDateFolders()
 	if err != nil {
 		s.logger.Error(ComponentSynchronizer, "Failed to find date folders: %v", err)
 	} else {
-		s.logger.Info(ComponentSynchronizer, "Found %d existing date folders", len(dateFolders))
+		s.logger.Info(ComponentSynchronizer, "Found %d active date folders", len(dateFolders))
 		for _, folder := range dateFolders {
 			if err := s.watcher.Add(folder); err != nil {
 				s.logger.Error(ComponentSynchronizer, "Failed to watch folder %s: %v", folder, err)
@@ -307,8 +437,8 @@ func (s *Synchronizer) watchdogMonitor() {
 				}
 			}
 
-			// Find all date folders and ensure they're being watched
-			dateFolders, err := s.findDateFolders()
+			// Find all active date folders and ensure they're being watched
+			dateFolders, err := s.findActiveDateFolders()
 			if err != nil {
 				s.logger.Error(ComponentSynchronizer, "Watchdog: Failed to find date folders: %v", err)
 				continue
@@ -408,7 +538,9 @@ func (s *Synchronizer) handleCreateEvent(event fsnotify.Event) {
 		dirName := filepath.Base(event.Name)
 		if len(dirName) == 8 && isNumeric(dirName) {
 			_, err := time.Parse(DateFolderPattern, dirName)
-			if err == nil {
+			if err == nil && s.isArchivalFolder(dirName) {
+				s.logger.Info(ComponentSynchronizer, "New folder %s is archival, not watching it, use resync to process it", event.Name)
+			} else if err == nil {
 				s.logger.Info(ComponentSynchronizer, "Adding new date folder to watch: %s", event.Name)
 				if err := s.watcher.Add(event.Name); err != nil {
 					s.logger.Error(ComponentSynchronizer, "Failed to watch new folder %s: %v", event.Name, err)
@@ -416,20 +548,8 @@ func (s *Synchronizer) handleCreateEvent(event fsnotify.Event) {
 					// Schedule a scan of the new folder to process any existing files
 					go func(folderPath string) {
 						s.logger.Info(ComponentSynchronizer, "Scanning new folder: %s", folderPath)
-						processedFiles := make(map[string]bool)
 						folderName := filepath.Base(folderPath)
-
-						var files []models.ProcessedFile
-						if err := s.db.Find(&files).Error; err != nil {
-							s.logger.Error(ComponentSynchronizer, "Failed to query processed files: %v", err)
-							return
-						}
-
-						for _, file := range files {
-							processedFiles[file.Filename] = true
-						}
-
-						fileCount := s.processFolderFiles(folderPath, folderName, processedFiles)
+						fileCount := s.processFolderFiles(folderPath, folderName)
 						s.logger.Info(ComponentSynchronizer, "Processed %d files from new folder %s", fileCount, folderName)
 					}(event.Name)
 				}
@@ -439,6 +559,7 @@ func (s *Synchronizer) handleCreateEvent(event fsnotify.Event) {
 		// If it's a file with the right extension, process it
 		if strings.HasSuffix(event.Name, ".json.bson") {
 			s.logger.Info(ComponentSynchronizer, "New file detected: %s", event.Name)
+			traceDetected(event.Name, "create")
 
 			// Queue the file for processing
 			select {
@@ -491,6 +612,7 @@ func (s *Synchronizer) handleWriteEvent(event fsnotify.Event) {
 	}
 
 	s.logger.Debug(ComponentSynchronizer, "File modification detected: %s", event.Name)
+	traceDetected(event.Name, "write")
 	select {
 	case s.pendingFiles <- event.Name:
 	default:
@@ -498,6 +620,15 @@ func (s *Synchronizer) handleWriteEvent(event fsnotify.Event) {
 	}
 }
 
+// traceDetected records the detection of a file, processing it later continues the trace so
+// the time spent waiting in the queue shows between both spans
+func traceDetected(path, op string) {
+	ctx, span := tracing.Start(context.Background(), "file.detect",
+		tracing.String("file.path", path), tracing.String("fs.event", op))
+	tracing.Remember(tracing.FileKey(path), ctx)
+	span.End()
+}
+
 // processPendingFiles handles the queue of files to be processed
 func (s *Synchronizer) processPendingFiles() {
 	for {
@@ -519,15 +650,13 @@ func (s *Synchronizer) processPendingFiles() {
 				continue
 			}
 
-			var count int64
-			if err := s.db.Model(&models.ProcessedFile{}).
-				Where("filename = ? AND date_folder = ?", relPath, dirPath).
-				Count(&count).Error; err != nil {
+			processed, err := s.processed.IsProcessed(dirPath, relPath)
+			if err != nil {
 				s.logger.Error(ComponentSynchronizer, "Database error checking file %s: %v", relPath, err)
 				continue
 			}
 
-			if count > 0 {
+			if processed {
 				s.logger.Debug(ComponentSynchronizer, "File %s already processed, skipping", relPath)
 				continue
 			}
@@ -543,16 +672,11 @@ func (s *Synchronizer) processPendingFiles() {
 func (s *Synchronizer) SyncData() {
 	s.logger.Info(ComponentSynchronizer, "Starting initial data synchronization")
 
-	processedFiles := make(map[string]bool)
-
-	var files []models.ProcessedFile
-	if err := s.db.Find(&files).Error; err != nil {
+	var processedCount int64
+	if err := s.db.Model(&models.ProcessedFile{}).Count(&processedCount).Error; err != nil {
 		s.logger.Error(ComponentSynchronizer, "Failed to query processed files: %v", err)
 	} else {
-		for _, file := range files {
-			processedFiles[file.Filename] = true
-		}
-		s.logger.Info(ComponentSynchronizer, "Found %d already processed files", len(processedFiles))
+		s.logger.Info(ComponentSynchronizer, "Found %d already processed files", processedCount)
 	}
 
 	dateFolders, err := s.findDateFolders()
@@ -565,7 +689,7 @@ func (s *Synchronizer) SyncData() {
 
 	for _, folder := range dateFolders {
 		folderName := filepath.Base(folder)
-		s.processFolderFiles(folder, folderName, processedFiles)
+		s.processFolderFiles(folder, folderName)
 	}
 
 	s.logger.Info(ComponentSynchronizer, "Initial synchronization completed")
@@ -579,6 +703,12 @@ func (s *Synchronizer) scanForNewFiles() {
 		return
 	}
 
+	if s.suspended {
+		s.mu.Unlock()
+		s.logger.Debug(ComponentSynchronizer, "System is suspending, skipping scan")
+		return
+	}
+
 	s.inSyncProcess = true
 	s.mu.Unlock()
 
@@ -590,30 +720,24 @@ func (s *Synchronizer) scanForNewFiles() {
 
 	s.logger.Info(ComponentSynchronizer, "Scanning for new files")
 
-	processedFiles := make(map[string]bool)
-
-	var files []models.ProcessedFile
-	if err := s.db.Find(&files).Error; err != nil {
-		s.logger.Error(ComponentSynchronizer, "Failed to query processed files: %v", err)
-		return
-	}
-
-	for _, file := range files {
-		processedFiles[file.Filename] = true
-	}
-
-	dateFolders, err := s.findDateFolders()
+	dateFolders, err := s.findActiveDateFolders()
 	if err != nil {
 		s.logger.Error(ComponentSynchronizer, "Failed to find date folders: %v", err)
 		return
 	}
 
+	// A full scan now and then catches files copied in with an old modification time
+	if s.startScan(dateFolders) {
+		s.logger.Debug(ComponentSynchronizer, "Running full folder scan")
+	}
+
 	fileCount := 0
 	for _, folder := range dateFolders {
 		folderName := filepath.Base(folder)
-		count := s.processFolderFiles(folder, folderName, processedFiles)
+		count := s.processFolderSince(folder, folderName)
 		fileCount += count
 	}
+	s.flushAllFolderStats()
 
 	if fileCount > 0 {
 		s.logger.Info(ComponentSynchronizer, "Found and processed %d new files during scan", fileCount)
@@ -623,7 +747,7 @@ func (s *Synchronizer) scanForNewFiles() {
 }
 
 // processFolderFiles processes all files in a folder
-func (s *Synchronizer) processFolderFiles(folderPath, folderName string, processedFiles map[string]bool) int {
+func (s *Synchronizer) processFolderFiles(folderPath, folderName string) int {
 	dataFiles, err := s.getDataFilesInDirectory(folderPath)
 	if err != nil {
 		s.logger.Error(ComponentSynchronizer, "Failed to read directory %s: %v", folderName, err)
@@ -633,9 +757,14 @@ func (s *Synchronizer) processFolderFiles(folderPath, folderName string, process
 	processedCount := 0
 
 	for _, fileName := range dataFiles {
-		relPath := filepath.Join(folderName, fileName)
+		relPath := filepath.Join(folderName, datafile.LogicalName(fileName))
 
-		if processedFiles[relPath] {
+		processed, err := s.processed.IsProcessed(folderName, relPath)
+		if err != nil {
+			s.logger.Error(ComponentSynchronizer, "Database error checking file %s: %v", relPath, err)
+			continue
+		}
+		if processed {
 			continue
 		}
 
@@ -648,25 +777,51 @@ func (s *Synchronizer) processFolderFiles(folderPath, folderName string, process
 		processedCount++
 	}
 
+	s.flushFolderStats(folderName)
 	return processedCount
 }
 
-// getDataFilesInDirectory gets all data files in a directory
+// getDataFilesInDirectory gets all data files in a directory, including compressed ones.
+// If both the plain and compressed copy exist, only the plain file is returned.
 func (s *Synchronizer) getDataFilesInDirectory(dirPath string) ([]string, error) {
+	entries, err := s.dataFileEntries(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	dataFiles := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		dataFiles = append(dataFiles, entry.Name())
+	}
+
+	return dataFiles, nil
+}
+
+// dataFileEntries is getDataFilesInDirectory returning the directory entries
+func (s *Synchronizer) dataFileEntries(dirPath string) ([]os.DirEntry, error) {
 	files, err := os.ReadDir(dirPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read directory: %w", err)
 	}
 
-	var dataFiles []string
+	plain := make(map[string]bool)
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), datafile.Suffix) {
+			plain[file.Name()] = true
+		}
+	}
+
+	var dataFiles []os.DirEntry
 	for _, file := range files {
-		if file.IsDir() {
+		if file.IsDir() || !datafile.IsDataFile(file.Name()) {
 			continue
 		}
 
-		if strings.HasSuffix(file.Name(), ".json.bson") {
-			dataFiles = append(dataFiles, file.Name())
+		if strings.HasSuffix(file.Name(), datafile.CompressedSuffix) && plain[datafile.LogicalName(file.Name())] {
+			continue
 		}
+
+		dataFiles = append(dataFiles, file)
 	}
 
 	return dataFiles, nil
@@ -699,12 +854,9 @@ func (s *Synchronizer) findDateFolders() ([]string, error) {
 
 // processFile processes a single file
 func (s *Synchronizer) processFile(filePath, filename, folderName string) error {
-	var count int64
-	if err := s.db.Model(&models.ProcessedFile{}).
-		Where("filename = ? AND date_folder = ?", filename, folderName).
-		Count(&count).Error; err != nil {
+	if processed, err := s.processed.IsProcessed(folderName, filename); err != nil {
 		s.logger.Error(ComponentSynchronizer, "Database error checking file %s: %v", filename, err)
-	} else if count > 0 {
+	} else if processed {
 		s.logger.Debug(ComponentSynchronizer, "File %s already processed, skipping", filename)
 		return nil
 	}
@@ -714,88 +866,141 @@ func (s *Synchronizer) processFile(filePath, filename, folderName string) error
 	}
 
 	s.logger.Info(ComponentSynchronizer, "Processing file: %s", filePath)
+	started := time.Now()
+
+	// Files found by the watcher continue the trace of their detection, scanned files start one
+	fileKey := tracing.FileKey(filePath)
+	traceCtx, span := tracing.Start(tracing.Recall(context.Background(), fileKey), "file.process",
+		tracing.String("file.name", filename), tracing.String("file.date_folder", folderName))
+	tracing.Forget(fileKey)
+	defer span.End()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
 
 	resultCh := make(chan error, 1)
+	// Set before the result is sent, only read after it is received
+	quarantined := false
 
 	go func() {
+		_, decryptSpan := tracing.Start(traceCtx, "file.decrypt")
 		data, err := decryptAndReadBSON(filePath, s.config.Advanced.FernetKey)
+		decryptSpan.RecordError(err)
+		decryptSpan.End()
 		if err != nil {
 			resultCh <- fmt.Errorf("error decrypting and reading file: %w", err)
 			return
 		}
 
-		if err := s.markFileAsProcessed(filename, folderName, data); err != nil {
-			resultCh <- fmt.Errorf("error marking file as processed: %w", err)
+		_, journalSpan := tracing.Start(traceCtx, "db.journal_intent")
+		err = s.journalIntent(filename, folderName)
+		journalSpan.RecordError(err)
+		journalSpan.End()
+		if err != nil {
+			resultCh <- fmt.Errorf("error writing journal entry: %w", err)
 			return
 		}
 
-		if err := s.sendDecryptedData(filename, folderName, data); err != nil {
-			s.logger.Error(ComponentSynchronizer, "Error sending decrypted data for file %s: %v", filename, err)
+		// The processed record and its message are committed together, on failure
+		// neither exists and the file is picked up again by the next scan
+		messageID, err := s.recordAndEnqueue(traceCtx, filename, folderName, data)
+		if errors.Is(err, errQuarantined) {
+			span.SetAttributes(tracing.Bool("data.quarantined", true))
+			quarantined = true
+			resultCh <- nil
+			return
+		} else if err != nil {
+			resultCh <- fmt.Errorf("error recording file and queueing its data: %w", err)
+			return
 		}
 
+		span.SetAttributes(tracing.Int("messaging.message.id", int64(messageID)))
+		s.logger.Info(ComponentSynchronizer, "Queued data from file %s (Message ID: %d)", filename, messageID)
 		resultCh <- nil
 	}()
 
 	select {
 	case err := <-resultCh:
 		if err != nil {
+			span.RecordError(err)
+			s.recordFileStat(folderName, started, outcomeFailed)
 			s.logger.Error(ComponentSynchronizer, "Failed to process file %s: %v", filePath, err)
 			return err
 		}
+		if quarantined {
+			s.recordFileStat(folderName, started, outcomeQuarantined)
+		} else {
+			s.recordFileStat(folderName, started, outcomeQueued)
+		}
 		s.logger.Info(ComponentSynchronizer, "Successfully processed file: %s", filePath)
 		return nil
 
 	case <-ctx.Done():
+		err := fmt.Errorf("processing timeout for file %s", filePath)
+		span.RecordError(err)
+		s.recordFileStat(folderName, started, outcomeFailed)
 		s.logger.Error(ComponentSynchronizer, "Processing timeout for file %s", filePath)
-		return fmt.Errorf("processing timeout for file %s", filePath)
+		return err
 	}
 }
 
-// sendDecryptedData sends the decrypted data to MQTT
-func (s *Synchronizer) sendDecryptedData(filename, folderName string, data map[string]interface{}) error {
+// sendDecryptedData sends the decrypted data to MQTT and returns the pending message ID
+func (s *Synchronizer) sendDecryptedData(filename, folderName string, data map[string]interface{}) (uint, error) {
 	if s.mqttSender == nil {
-		return fmt.Errorf("MQTT sender not initialized")
+		return 0, fmt.Errorf("MQTT sender not initialized")
 	}
 
+	topic, payload, err := s.dataMessage(filename, folderName, data)
+	if err != nil {
+		return 0, err
+	}
+
+	s.logger.Info(ComponentSynchronizer, "Sending decrypted data from file %s to MQTT topic %s", filename, topic)
+	messageID, err := s.mqttSender.SendData(topic, payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send data to MQTT: %w", err)
+	}
+
+	s.logger.Info(ComponentSynchronizer, "Successfully queued decrypted data from file %s (Message ID: %d)", filename, messageID)
+	return messageID, nil
+}
+
+// dataMessage builds the topic and payload that carry a data file to the broker
+func (s *Synchronizer) dataMessage(filename, folderName string, data map[string]interface{}) (string, map[string]interface{}, error) {
 	dataEntry, err := s.mapToDataEntry(data)
 	if err != nil {
-		return fmt.Errorf("error converting data to DataEntry: %w", err)
+		return "", nil, fmt.Errorf("error converting data to DataEntry: %w", err)
 	}
 
-	siteId, _ := s.GetSetting("site_id")
-	tenantId, _ := s.GetSetting("tenant_id")
-	clientId, _ := s.GetSetting("client_id")
+	topic, payload := s.entryMessage(filename, folderName, dataEntry)
+	return topic, payload, nil
+}
+
+// entryMessage builds the topic and payload for an already converted entry
+func (s *Synchronizer) entryMessage(filename, folderName string, dataEntry DataEntry) (string, map[string]interface{}) {
+	id := identity.Get(s.db)
 
 	payload := map[string]interface{}{
 		"filename":     filename,
 		"date_folder":  folderName,
-		"tenant_id":    tenantId,
-		"client_id":    clientId,
-		"site_id":      siteId,
+		"tenant_id":    id.TenantID,
+		"client_id":    id.ClientID,
+		"site_id":      id.SiteID,
 		"processed_at": time.Now().Format(time.RFC3339),
 		"data":         dataEntry,
 	}
-
-	topic := fmt.Sprintf("%s/data/%s", "jarvist", folderName)
-
-	s.logger.Info(ComponentSynchronizer, "Sending decrypted data from file %s to MQTT topic %s", filename, topic)
-	messageID, err := s.mqttSender.SendData(topic, payload)
-	if err != nil {
-		return fmt.Errorf("failed to send data to MQTT: %w", err)
+	if len(id.Tags) > 0 {
+		payload["tags"] = id.Tags
 	}
 
-	s.logger.Info(ComponentSynchronizer, "Successfully queued decrypted data from file %s (Message ID: %d)", filename, messageID)
-	return nil
+	return id.DataTopic(folderName), payload
 }
 
-// markFileAsProcessed marks a file as processed in the database
-func (s *Synchronizer) markFileAsProcessed(filename, dateFolder string, data map[string]interface{}) error {
+// processedFileRecord builds the database record that marks a file as processed
+func (s *Synchronizer) processedFileRecord(filename, dateFolder string, data map[string]interface{}) (models.ProcessedFile, error) {
 	dataEntry, err := s.mapToDataEntry(data)
 	if err != nil {
-		return fmt.Errorf("error converting data to DataEntry: %w", err)
+		return models.ProcessedFile{}, fmt.Errorf("error converting data to DataEntry: %w", err)
 	}
 
 	dataJSON := fmt.Sprintf(`{"id":"%s","cctv_id":%d,"device_id":"%s","in_count":%d,"out_count":%d}`,
@@ -808,7 +1013,7 @@ func (s *Synchronizer) markFileAsProcessed(filename, dateFolder string, data map
 		ProcessedAt: time.Now(),
 	}
 
-	return s.db.Create(&processedFile).Error
+	return processedFile, nil
 }
 
 // mapToDataEntry converts a map to a DataEntry
@@ -908,13 +1113,24 @@ func (s *Synchronizer) GetStatus() map[string]interface{} {
 
 	pendingCount := len(s.pendingFiles)
 
-	return map[string]interface{}{
+	status := map[string]interface{}{
 		"running":          true,
 		"in_sync_process":  s.inSyncProcess,
 		"watcher_active":   watchStatus,
 		"pending_files":    pendingCount,
 		"last_status_time": time.Now().Format(time.RFC3339),
 	}
+
+	if counts, err := s.GetJournalCounts(); err == nil {
+		status["journal"] = counts
+	}
+	status["processed_cache"] = s.processed.Stats()
+	status["scan"] = s.scanCursorStatus()
+	status["archive"] = s.GetArchiveStatus()
+	status["data_gaps"] = s.gapSummary()
+	status["date_folders"] = s.dateFolderStatus()
+
+	return status
 }
 
 // GetSyncedFoldersDetails returns details about synced folders
@@ -968,24 +1184,16 @@ func (s *Synchronizer) ResyncFolder(folderName string) error {
 	if err != nil {
 		return fmt.Errorf("failed to clear processed files: %w", err)
 	}
+	s.db.Where("date_folder = ?", folderName).Delete(&models.SyncJournal{})
+	s.processed.InvalidateFolder(folderName)
+	s.resetScanCursor(folderName)
 
 	s.logger.Info(ComponentSynchronizer, "Folder %s marked for resyncing, removed from processed files", folderName)
 
 	// Trigger a new scan
 	go func() {
-		processedFiles := make(map[string]bool)
-		var files []models.ProcessedFile
-		if err := s.db.Find(&files).Error; err != nil {
-			s.logger.Error(ComponentSynchronizer, "Failed to query processed files: %v", err)
-			return
-		}
-
-		for _, file := range files {
-			processedFiles[file.Filename] = true
-		}
-
 		folderPath := filepath.Join(s.config.BaseConfig.ServicesDataDir, folderName)
-		count := s.processFolderFiles(folderPath, folderName, processedFiles)
+		count := s.processFolderFiles(folderPath, folderName)
 		s.logger.Info(ComponentSynchronizer, "Resynced %d files from folder %s", count, folderName)
 	}()
 
@@ -1015,8 +1223,10 @@ func (s *Synchronizer) GetFileProcessingStatus(filename string, folderName strin
 		return nil, err
 	}
 
-	var dataMap map[string]interface{}
-	if err := json.Unmarshal([]byte(processedFile.DataJSON), &dataMap); err != nil {
+	var dataMap interface{}
+	if entry, ok := s.cachedEntry(processedFile.Filename); ok {
+		dataMap = entry
+	} else if err := json.Unmarshal([]byte(processedFile.DataJSON), &dataMap); err != nil {
 		dataMap = map[string]interface{}{
 			"error": "Failed to parse data JSON",
 		}
@@ -1031,6 +1241,11 @@ func (s *Synchronizer) GetFileProcessingStatus(filename string, folderName strin
 	}, nil
 }
 
+// Name returns the component name used in startup logs
+func (s *Synchronizer) Name() string {
+	return "Synchronizer"
+}
+
 // SendSyncFolderSummary sends a summary of sync status
 func (s *Synchronizer) SendSyncFolderSummary() error {
 	if s.mqttSender == nil {
@@ -1046,6 +1261,19 @@ func (s *Synchronizer) SendSyncFolderSummary() error {
 		"timestamp":      time.Now().Format(time.RFC3339),
 		"total_folders":  len(folderDetails),
 		"synced_folders": folderDetails,
+		"publish":        s.mqttSender.GetPublishMetrics(),
+		"uptime":         uptime.Get(),
+	}
+	if lifetime, err := s.GetLifetimeTotals(); err == nil {
+		summary["lifetime"] = map[string]interface{}{
+			"in_total":  lifetime.InTotal,
+			"out_total": lifetime.OutTotal,
+			"since":     lifetime.Since,
+			"cameras":   lifetime.Cameras,
+		}
+	}
+	if tags := identity.GetTags(s.db); len(tags) > 0 {
+		summary["tags"] = tags
 	}
 
 	topic := fmt.Sprintf("%s/summary/folders", s.config.MQTT.Topic)
@@ -1071,26 +1299,5 @@ func isNumeric(s string) bool {
 
 // decryptAndReadBSON decrypts and reads a BSON file
 func decryptAndReadBSON(filePath, fernetKey string) (map[string]interface{}, error) {
-	encryptedData, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
-	}
-
-	key, err := fernet.DecodeKey(fernetKey)
-	if err != nil {
-		return nil, fmt.Errorf("invalid Fernet key: %w", err)
-	}
-
-	msg := fernet.VerifyAndDecrypt(encryptedData, 0, []*fernet.Key{key})
-	if msg == nil {
-		return nil, fmt.Errorf("failed to decrypt: invalid token or key")
-	}
-
-	var result map[string]interface{}
-	err = bson.Unmarshal(msg, &result)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal BSON: %w", err)
-	}
-
-	return result, nil
+	return datafile.ReadAndDecode(filePath, fernetKey)
 }
@@ -0,0 +1,117 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"jarvist/internal/common/models"
+	"jarvist/internal/syncmanager/tracing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// storeFunc stores the message for a file inside tx and returns its ID
+type storeFunc func(tx *gorm.DB) (uint, error)
+
+// commitProcessedFile inserts the processed file record, its pending message and the
+// published journal entry in one transaction, so a crash can never leave a file marked
+// processed without the message that carries its data.
+func commitProcessedFile(db *gorm.DB, file models.ProcessedFile, store storeFunc) (uint, error) {
+	var messageID uint
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&file).Error; err != nil {
+			return fmt.Errorf("failed to mark file as processed: %w", err)
+		}
+
+		id, err := store(tx)
+		if err != nil {
+			return fmt.Errorf("failed to store message: %w", err)
+		}
+
+		entry := models.SyncJournal{
+			Filename:   file.Filename,
+			DateFolder: file.DateFolder,
+			State:      JournalPublished,
+			MessageID:  id,
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "filename"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"state":      JournalPublished,
+				"message_id": id,
+				"updated_at": time.Now(),
+			}),
+		}).Create(&entry).Error; err != nil {
+			return fmt.Errorf("failed to write journal entry: %w", err)
+		}
+
+		messageID = id
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return messageID, nil
+}
+
+// recordAndEnqueue commits the processed file, its cached data and its message together,
+// then queues the message for sending. Entries violating the validation rules are
+// quarantined instead and errQuarantined is returned. The spans of the database writes and
+// the queueing are children of the span in ctx.
+func (s *Synchronizer) recordAndEnqueue(ctx context.Context, filename, folderName string, data map[string]interface{}) (uint, error) {
+	if s.mqttSender == nil {
+		return 0, fmt.Errorf("MQTT sender not initialized")
+	}
+
+	file, err := s.processedFileRecord(filename, folderName, data)
+	if err != nil {
+		return 0, err
+	}
+
+	entry, err := s.mapToDataEntry(data)
+	if err != nil {
+		return 0, fmt.Errorf("error converting data to DataEntry: %w", err)
+	}
+
+	// Data yang melanggar aturan validasi ditahan untuk ditinjau, tidak dikirim
+	if violations := s.GetValidationRules().Check(entry, time.Now()); len(violations) > 0 {
+		_, span := tracing.Start(ctx, "db.quarantine", tracing.String("validation.rule", violations[0].Rule))
+		err := s.quarantineEntry(file, entry, violations)
+		span.RecordError(err)
+		span.End()
+		if err != nil {
+			return 0, err
+		}
+		return 0, errQuarantined
+	}
+
+	topic, payload := s.entryMessage(filename, folderName, entry)
+
+	_, commitSpan := tracing.Start(ctx, "db.commit", tracing.Int("cctv.id", int64(entry.CCTVID)))
+	messageID, err := commitProcessedFile(s.db, file, func(tx *gorm.DB) (uint, error) {
+		if err := storeDataRecord(tx, dataRecord(filename, folderName, entry)); err != nil {
+			return 0, fmt.Errorf("failed to cache data: %w", err)
+		}
+		return s.mqttSender.StoreData(tx, topic, payload)
+	})
+	commitSpan.RecordError(err)
+	commitSpan.End()
+	if err != nil {
+		return 0, err
+	}
+
+	s.processed.Add(folderName, filename)
+
+	// The publish span of the sender continues from the queue span
+	queueCtx, queueSpan := tracing.Start(ctx, "queue.dispatch",
+		tracing.String("messaging.destination.name", topic), tracing.Int("messaging.message.id", int64(messageID)))
+	tracing.Remember(tracing.MessageKey(messageID), queueCtx)
+	s.mqttSender.Dispatch(messageID, topic)
+	queueSpan.End()
+
+	s.checkMilestone()
+	return messageID, nil
+}
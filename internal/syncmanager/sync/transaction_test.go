@@ -0,0 +1,181 @@
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"jarvist/internal/common/models"
+	"jarvist/internal/testutil"
+	"jarvist/pkg/logger"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	return testutil.OpenDB(t, &models.ProcessedFile{}, &models.PendingMessage{}, &models.SyncJournal{})
+}
+
+func testFile(name string) models.ProcessedFile {
+	return models.ProcessedFile{
+		Filename:    filepath.Join("20250101", name),
+		DateFolder:  "20250101",
+		ProcessedAt: time.Now(),
+	}
+}
+
+func storeMessage(tx *gorm.DB) (uint, error) {
+	message := models.PendingMessage{Topic: "jarvist/data/20250101", Payload: "{}"}
+	if err := tx.Create(&message).Error; err != nil {
+		return 0, err
+	}
+	return message.ID, nil
+}
+
+func assertCounts(t *testing.T, db *gorm.DB, files, messages, journal int64) {
+	t.Helper()
+
+	var count int64
+	db.Model(&models.ProcessedFile{}).Count(&count)
+	if count != files {
+		t.Errorf("processed files: got %d, want %d", count, files)
+	}
+	db.Model(&models.PendingMessage{}).Count(&count)
+	if count != messages {
+		t.Errorf("pending messages: got %d, want %d", count, messages)
+	}
+	db.Model(&models.SyncJournal{}).Count(&count)
+	if count != journal {
+		t.Errorf("journal entries: got %d, want %d", count, journal)
+	}
+}
+
+func TestCommitProcessedFile(t *testing.T) {
+	db := openTestDB(t)
+
+	messageID, err := commitProcessedFile(db, testFile("a.json.bson"), storeMessage)
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	assertCounts(t, db, 1, 1, 1)
+
+	var entry models.SyncJournal
+	if err := db.First(&entry).Error; err != nil {
+		t.Fatalf("read journal: %v", err)
+	}
+	if entry.State != JournalPublished || entry.MessageID != messageID {
+		t.Errorf("journal entry: got state %q message %d, want %q message %d", entry.State, entry.MessageID, JournalPublished, messageID)
+	}
+}
+
+func TestCommitProcessedFileStoreFails(t *testing.T) {
+	db := openTestDB(t)
+
+	_, err := commitProcessedFile(db, testFile("a.json.bson"), func(tx *gorm.DB) (uint, error) {
+		return 0, errors.New("disk full")
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	assertCounts(t, db, 0, 0, 0)
+}
+
+func TestCommitProcessedFileFailsAfterStore(t *testing.T) {
+	db := openTestDB(t)
+
+	// The message row is written, then the operation fails before the transaction ends
+	_, err := commitProcessedFile(db, testFile("a.json.bson"), func(tx *gorm.DB) (uint, error) {
+		if _, err := storeMessage(tx); err != nil {
+			return 0, err
+		}
+		return 0, errors.New("interrupted")
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	assertCounts(t, db, 0, 0, 0)
+}
+
+func TestCommitProcessedFileJournalFails(t *testing.T) {
+	db := openTestDB(t)
+
+	if err := db.Migrator().DropTable(&models.SyncJournal{}); err != nil {
+		t.Fatalf("drop journal table: %v", err)
+	}
+
+	_, err := commitProcessedFile(db, testFile("a.json.bson"), storeMessage)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	var count int64
+	db.Model(&models.ProcessedFile{}).Count(&count)
+	if count != 0 {
+		t.Errorf("processed files: got %d, want 0", count)
+	}
+	db.Model(&models.PendingMessage{}).Count(&count)
+	if count != 0 {
+		t.Errorf("pending messages: got %d, want 0", count)
+	}
+}
+
+func TestCommitProcessedFilePanic(t *testing.T) {
+	db := openTestDB(t)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic")
+			}
+		}()
+
+		commitProcessedFile(db, testFile("a.json.bson"), func(tx *gorm.DB) (uint, error) {
+			storeMessage(tx)
+			panic("crash while storing message")
+		})
+	}()
+
+	assertCounts(t, db, 0, 0, 0)
+}
+
+func TestCommitProcessedFileDuplicate(t *testing.T) {
+	db := openTestDB(t)
+
+	if _, err := commitProcessedFile(db, testFile("a.json.bson"), storeMessage); err != nil {
+		t.Fatalf("first commit: %v", err)
+	}
+
+	if _, err := commitProcessedFile(db, testFile("a.json.bson"), storeMessage); err == nil {
+		t.Fatal("expected error for duplicate file")
+	}
+
+	assertCounts(t, db, 1, 1, 1)
+}
+
+func TestRecoverEntryFindsMessageAfterTopicChange(t *testing.T) {
+	db := openTestDB(t)
+	s := &Synchronizer{db: db, logger: logger.NewLogger()}
+
+	file := testFile("a.json.bson")
+	db.Create(&file)
+	entry := models.SyncJournal{Filename: file.Filename, DateFolder: file.DateFolder, State: JournalIntent}
+	db.Create(&entry)
+
+	// Stored under the topic prefix the site had before the crash
+	payload, _ := json.Marshal(map[string]string{"filename": file.Filename, "date_folder": file.DateFolder})
+	message := models.PendingMessage{Topic: "old-prefix/data/20250101", Payload: string(payload)}
+	db.Create(&message)
+
+	if outcome := s.recoverEntry(entry); outcome != RecoveryEnqueued {
+		t.Fatalf("outcome: got %q, want %q", outcome, RecoveryEnqueued)
+	}
+
+	db.First(&entry, entry.ID)
+	if entry.State != JournalPublished || entry.MessageID != message.ID {
+		t.Errorf("journal entry: got state %q message %d, want %q message %d", entry.State, entry.MessageID, JournalPublished, message.ID)
+	}
+}
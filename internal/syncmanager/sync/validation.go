@@ -0,0 +1,157 @@
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ValidationRulesKey is the setting holding the data validation rules as JSON
+const ValidationRulesKey = "data_validation_rules"
+
+// Rules an entry can violate
+const (
+	RuleNegativeCount   = "negative_count"
+	RuleMaxCount        = "max_count"
+	RuleFutureTimestamp = "future_timestamp"
+)
+
+const (
+	maxCountLimit      = 1000000
+	maxFutureTolerance = 24 * 60
+)
+
+// ValidationRules decide which entries are quarantined instead of published
+type ValidationRules struct {
+	Enabled        bool `json:"enabled"`
+	RejectNegative bool `json:"reject_negative"`
+	// MaxCountPerEntry is the highest plausible in or out count of one entry, 0 disables the rule
+	MaxCountPerEntry int  `json:"max_count_per_entry"`
+	RejectFuture     bool `json:"reject_future"`
+	// FutureToleranceMin allows device clocks that run slightly ahead
+	FutureToleranceMin int `json:"future_tolerance_min"`
+}
+
+// Violation is a rule an entry breaks
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// DefaultValidationRules returns the rules used until they are changed
+func DefaultValidationRules() ValidationRules {
+	return ValidationRules{
+		Enabled:            true,
+		RejectNegative:     true,
+		MaxCountPerEntry:   5000,
+		RejectFuture:       true,
+		FutureToleranceMin: 10,
+	}
+}
+
+// Validate checks the limits of the rules
+func (r ValidationRules) Validate() error {
+	if r.MaxCountPerEntry < 0 || r.MaxCountPerEntry > maxCountLimit {
+		return fmt.Errorf("max_count_per_entry must be between 0 and %d", maxCountLimit)
+	}
+	if r.FutureToleranceMin < 0 || r.FutureToleranceMin > maxFutureTolerance {
+		return fmt.Errorf("future_tolerance_min must be between 0 and %d", maxFutureTolerance)
+	}
+	return nil
+}
+
+// Check returns the rules the entry violates, none when validation is off
+func (r ValidationRules) Check(entry DataEntry, now time.Time) []Violation {
+	if !r.Enabled {
+		return nil
+	}
+
+	var violations []Violation
+	counts := []struct {
+		name  string
+		value int
+	}{{"in_count", entry.InCount}, {"out_count", entry.OutCount}}
+
+	for _, count := range counts {
+		if r.RejectNegative && count.value < 0 {
+			violations = append(violations, Violation{
+				Rule:    RuleNegativeCount,
+				Message: fmt.Sprintf("%s is negative (%d)", count.name, count.value),
+			})
+		}
+		if r.MaxCountPerEntry > 0 && count.value > r.MaxCountPerEntry {
+			violations = append(violations, Violation{
+				Rule:    RuleMaxCount,
+				Message: fmt.Sprintf("%s %d is above the plausible maximum of %d", count.name, count.value, r.MaxCountPerEntry),
+			})
+		}
+	}
+
+	if r.RejectFuture && entry.DeviceTimestampUTC > 0 {
+		timestamp := unixTime(entry.DeviceTimestampUTC)
+		if ahead := timestamp.Sub(now); ahead > time.Duration(r.FutureToleranceMin)*time.Minute {
+			violations = append(violations, Violation{
+				Rule:    RuleFutureTimestamp,
+				Message: fmt.Sprintf("timestamp %s is %s in the future", timestamp.Format(time.RFC3339), ahead.Round(time.Second)),
+			})
+		}
+	}
+
+	return violations
+}
+
+// ParseValidationRules reads rules stored as JSON, missing fields keep their default
+func ParseValidationRules(value string) (ValidationRules, error) {
+	rules := DefaultValidationRules()
+	if value == "" {
+		return rules, nil
+	}
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return DefaultValidationRules(), fmt.Errorf("invalid validation rules: %w", err)
+	}
+	return rules, rules.Validate()
+}
+
+// GetValidationRules returns the data validation rules in use
+func (s *Synchronizer) GetValidationRules() ValidationRules {
+	value, _ := s.GetSetting(ValidationRulesKey)
+	rules, err := ParseValidationRules(value)
+	if err != nil {
+		s.logger.Warning(ComponentSynchronizer, "Using default validation rules: %v", err)
+		return DefaultValidationRules()
+	}
+	return rules
+}
+
+// UpdateValidationRules stores the data validation rules, they apply to the next file
+func (s *Synchronizer) UpdateValidationRules(rules ValidationRules) error {
+	if err := rules.Validate(); err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+
+	var setting models.Setting
+	result := s.db.Where("key = ?", ValidationRulesKey).First(&setting)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		err = s.db.Create(&models.Setting{Key: ValidationRulesKey, Value: string(value)}).Error
+	} else if result.Error != nil {
+		return result.Error
+	} else {
+		setting.Value = string(value)
+		err = s.db.Save(&setting).Error
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save validation rules: %w", err)
+	}
+
+	s.logger.Info(ComponentSynchronizer, "Data validation rules updated: %s", value)
+	return nil
+}
@@ -0,0 +1,15 @@
+package sync
+
+import "path/filepath"
+
+// VerifyFile decrypts a data file and builds the topic and payload it would be published
+// with, without recording it as processed or queueing it. Used by the installation
+// verification to check a synthetic file flows through the same path as counter data.
+func (s *Synchronizer) VerifyFile(filePath, folderName string) (string, map[string]interface{}, error) {
+	data, err := decryptAndReadBSON(filePath, s.config.Advanced.FernetKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return s.dataMessage(filepath.Base(filePath), folderName, data)
+}
@@ -0,0 +1,531 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"jarvist/internal/common/bandwidth"
+	"jarvist/internal/common/identity"
+	"jarvist/internal/common/models"
+	"jarvist/internal/syncmanager/config"
+	"jarvist/pkg/logger"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const ComponentTracing = "tracing"
+
+// Setting keys of the tracing, re-read every 30 seconds so it can be switched on while
+// investigating without a restart
+const (
+	EnabledKey       = "tracing_enabled"
+	EndpointKey      = "tracing_endpoint"
+	SamplePercentKey = "tracing_sample_percent"
+	HeadersKey       = "tracing_headers"
+)
+
+const (
+	DefaultSamplePercent = 100
+
+	serviceName = "jarvist-syncmanager"
+	scopeName   = "jarvist/syncmanager"
+	tracesPath  = "/v1/traces"
+
+	reloadInterval = 30 * time.Second
+	flushInterval  = 5 * time.Second
+	exportTimeout  = 10 * time.Second
+	queueSize      = 2048
+	maxBatch       = 256
+)
+
+// Settings select whether and where spans are exported
+type Settings struct {
+	Enabled       bool              `json:"enabled"`
+	Endpoint      string            `json:"endpoint"`
+	SamplePercent int               `json:"sample_percent"`
+	Headers       map[string]string `json:"-"` // Often hold an API key, only their names are shown
+}
+
+// Status describes the tracing and the spans exported since the start
+type Status struct {
+	Enabled       bool       `json:"enabled"`
+	Endpoint      string     `json:"endpoint,omitempty"`
+	SamplePercent int        `json:"sample_percent"`
+	Headers       []string   `json:"headers,omitempty"`
+	Queued        int        `json:"queued"`
+	Exported      uint64     `json:"exported"`
+	Dropped       uint64     `json:"dropped"` // Queue full or tracing switched off before export
+	Failed        uint64     `json:"failed"`  // Rejected by or not delivered to the collector
+	LastExportAt  *time.Time `json:"last_export_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	SettingsError string     `json:"settings_error,omitempty"` // Invalid settings keep the export off
+}
+
+// ValidateEndpoint checks the collector URL, empty disables the export
+func ValidateEndpoint(value string) error {
+	if value == "" {
+		return nil
+	}
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid collector URL: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return errors.New("collector URL must start with http:// or https://")
+	}
+	if parsed.Host == "" {
+		return errors.New("collector URL has no host")
+	}
+	return nil
+}
+
+// ParseHeaders reads the extra export headers, a JSON object of strings
+func ParseHeaders(value string) (map[string]string, error) {
+	headers := map[string]string{}
+	if value == "" {
+		return headers, nil
+	}
+	if err := json.Unmarshal([]byte(value), &headers); err != nil {
+		return nil, fmt.Errorf("tracing headers must be a JSON object of strings: %w", err)
+	}
+	return headers, nil
+}
+
+// LoadSettings reads the tracing settings, invalid values disable the export
+func LoadSettings(db *gorm.DB) (Settings, error) {
+	settings := Settings{
+		Enabled:       getSetting(db, EnabledKey) == "true",
+		Endpoint:      getSetting(db, EndpointKey),
+		SamplePercent: DefaultSamplePercent,
+	}
+
+	if value := getSetting(db, SamplePercentKey); value != "" {
+		percent, err := strconv.Atoi(value)
+		if err != nil || percent < 0 || percent > 100 {
+			return Settings{}, fmt.Errorf("%s must be between 0 and 100", SamplePercentKey)
+		}
+		settings.SamplePercent = percent
+	}
+	if err := ValidateEndpoint(settings.Endpoint); err != nil {
+		return Settings{}, err
+	}
+
+	headers, err := ParseHeaders(getSetting(db, HeadersKey))
+	if err != nil {
+		return Settings{}, err
+	}
+	settings.Headers = headers
+	return settings, nil
+}
+
+// Tracer exports the spans of the pipeline and the API to an OTLP/HTTP collector
+type Tracer struct {
+	cfg    *config.Config
+	db     *gorm.DB
+	logger *logger.Logger
+	client *http.Client
+
+	mutex        sync.RWMutex
+	settings     Settings
+	resource     []Attribute
+	running      bool
+	lastExportAt *time.Time
+	lastError    string
+	invalid      string
+
+	queue    chan *Span
+	exported uint64
+	dropped  uint64
+	failed   uint64
+
+	quitChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// active is the tracer new spans are exported with, spans are no-ops while it is nil
+var active atomic.Pointer[Tracer]
+
+func current() *Tracer {
+	return active.Load()
+}
+
+// New creates the tracer, it exports nothing until started and enabled
+func New(cfg *config.Config, db *gorm.DB, logger *logger.Logger) *Tracer {
+	t := &Tracer{
+		cfg:    cfg,
+		db:     db,
+		logger: logger,
+		client: &http.Client{
+			Timeout:   exportTimeout,
+			Transport: bandwidth.NewTransport(bandwidth.DestinationTracing),
+		},
+		queue: make(chan *Span, queueSize),
+	}
+	active.Store(t)
+	return t
+}
+
+// Name returns the component name used in startup logs
+func (t *Tracer) Name() string {
+	return "Tracing"
+}
+
+// Start reads the settings and starts the exporter
+func (t *Tracer) Start() error {
+	t.mutex.Lock()
+	if t.running {
+		t.mutex.Unlock()
+		return nil
+	}
+	t.running = true
+	t.quitChan = make(chan struct{})
+	quitChan := t.quitChan
+	t.mutex.Unlock()
+
+	t.reload()
+
+	t.wg.Add(2)
+	go t.exportWorker(quitChan)
+	go t.reloadWorker(quitChan)
+	return nil
+}
+
+// Stop exports the spans still queued and stops the exporter
+func (t *Tracer) Stop() error {
+	t.mutex.Lock()
+	if !t.running {
+		t.mutex.Unlock()
+		return nil
+	}
+	t.running = false
+	close(t.quitChan)
+	t.mutex.Unlock()
+
+	t.wg.Wait()
+	return nil
+}
+
+// Enabled reports whether new spans are recorded
+func (t *Tracer) Enabled() bool {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.running && t.settings.Enabled && t.settings.Endpoint != ""
+}
+
+// GetStatus returns the settings in use and the export counters
+func (t *Tracer) GetStatus() Status {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	status := Status{
+		Enabled:       t.running && t.settings.Enabled && t.settings.Endpoint != "",
+		Endpoint:      t.settings.Endpoint,
+		SamplePercent: t.settings.SamplePercent,
+		Queued:        len(t.queue),
+		Exported:      atomic.LoadUint64(&t.exported),
+		Dropped:       atomic.LoadUint64(&t.dropped),
+		Failed:        atomic.LoadUint64(&t.failed),
+		LastExportAt:  t.lastExportAt,
+		LastError:     t.lastError,
+		SettingsError: t.invalid,
+	}
+	for name := range t.settings.Headers {
+		status.Headers = append(status.Headers, name)
+	}
+	sort.Strings(status.Headers)
+	return status
+}
+
+// Refresh re-reads the settings now instead of with the next check
+func (t *Tracer) Refresh() Status {
+	t.reload()
+	return t.GetStatus()
+}
+
+func (t *Tracer) sample() bool {
+	t.mutex.RLock()
+	percent := t.settings.SamplePercent
+	t.mutex.RUnlock()
+
+	switch {
+	case percent >= 100:
+		return true
+	case percent <= 0:
+		return false
+	default:
+		return rand.Intn(100) < percent
+	}
+}
+
+// export queues an ended span, it is dropped when the queue is full so tracing never slows
+// down the pipeline
+func (t *Tracer) export(span *Span) {
+	select {
+	case t.queue <- span:
+	default:
+		atomic.AddUint64(&t.dropped, 1)
+	}
+}
+
+func (t *Tracer) reloadWorker(quitChan chan struct{}) {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-quitChan:
+			return
+		case <-ticker.C:
+			t.reload()
+		}
+	}
+}
+
+// reload applies changed settings. Invalid settings switch the export off until fixed.
+func (t *Tracer) reload() {
+	settings, err := LoadSettings(t.db)
+	invalid := ""
+	if err != nil {
+		invalid = err.Error()
+		settings = Settings{SamplePercent: DefaultSamplePercent}
+	}
+
+	hostname, _ := os.Hostname()
+	resource := []Attribute{
+		String("service.name", serviceName),
+		String("host.name", hostname),
+	}
+	if t.cfg != nil && t.cfg.BaseConfig != nil {
+		resource = append(resource, String("service.version", t.cfg.BaseConfig.BuildInfo.FileVersion))
+	}
+	if id := identity.Get(t.db); id.SiteID != "" {
+		resource = append(resource, String("site_id", id.SiteID), String("tenant_id", id.TenantID))
+	}
+
+	t.mutex.Lock()
+	previous := t.settings
+	previousInvalid := t.invalid
+	t.settings = settings
+	t.resource = resource
+	t.invalid = invalid
+	t.mutex.Unlock()
+
+	if invalid != "" && invalid != previousInvalid {
+		t.logger.Warning(ComponentTracing, "Tracing disabled, invalid settings: %s", invalid)
+	}
+
+	if previous.Enabled == settings.Enabled && previous.Endpoint == settings.Endpoint &&
+		previous.SamplePercent == settings.SamplePercent {
+		return
+	}
+	if settings.Enabled && settings.Endpoint != "" {
+		t.logger.Info(ComponentTracing, "Tracing enabled, exporting %d%% of traces to %s", settings.SamplePercent, settings.Endpoint)
+	} else if previous.Enabled {
+		t.logger.Info(ComponentTracing, "Tracing disabled")
+	}
+}
+
+func (t *Tracer) exportWorker(quitChan chan struct{}) {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, maxBatch)
+	flush := func() {
+		if len(batch) > 0 {
+			t.send(batch)
+			batch = batch[:0]
+		}
+	}
+
+	for {
+		select {
+		case span := <-t.queue:
+			batch = append(batch, span)
+			if len(batch) == maxBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-quitChan:
+			// Span yang masih antre dikirim sekali lagi sebelum berhenti
+			for {
+				select {
+				case span := <-t.queue:
+					batch = append(batch, span)
+					if len(batch) == maxBatch {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send posts a batch to the collector, a failed batch is counted and dropped
+func (t *Tracer) send(batch []*Span) {
+	t.mutex.RLock()
+	settings := t.settings
+	resource := t.resource
+	t.mutex.RUnlock()
+
+	if !settings.Enabled || settings.Endpoint == "" {
+		atomic.AddUint64(&t.dropped, uint64(len(batch)))
+		return
+	}
+
+	err := t.post(settings, encodeSpans(resource, batch))
+	now := time.Now()
+
+	t.mutex.Lock()
+	previousError := t.lastError
+	if err != nil {
+		t.lastError = err.Error()
+	} else {
+		t.lastError = ""
+		t.lastExportAt = &now
+	}
+	t.mutex.Unlock()
+
+	if err != nil {
+		atomic.AddUint64(&t.failed, uint64(len(batch)))
+		if previousError != err.Error() {
+			t.logger.Warning(ComponentTracing, "Failed to export %d spans: %v", len(batch), err)
+		}
+		return
+	}
+
+	atomic.AddUint64(&t.exported, uint64(len(batch)))
+	if previousError != "" {
+		t.logger.Info(ComponentTracing, "Span export to %s recovered", settings.Endpoint)
+	}
+}
+
+func (t *Tracer) post(settings Settings, payload map[string]interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tracesURL(settings.Endpoint), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range settings.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("collector returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// tracesURL returns the OTLP/HTTP traces URL of a collector given with or without the path
+func tracesURL(endpoint string) string {
+	endpoint = strings.TrimRight(endpoint, "/")
+	if strings.HasSuffix(endpoint, tracesPath) {
+		return endpoint
+	}
+	return endpoint + tracesPath
+}
+
+// encodeSpans returns the OTLP/JSON export request of a batch
+func encodeSpans(resource []Attribute, batch []*Span) map[string]interface{} {
+	spans := make([]map[string]interface{}, 0, len(batch))
+	for _, span := range batch {
+		span.mutex.Lock()
+		encoded := map[string]interface{}{
+			"traceId":           hex.EncodeToString(span.context.TraceID[:]),
+			"spanId":            hex.EncodeToString(span.context.SpanID[:]),
+			"name":              span.name,
+			"kind":              span.kind,
+			"startTimeUnixNano": strconv.FormatInt(span.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(span.end.UnixNano(), 10),
+			"attributes":        encodeAttributes(span.attrs),
+		}
+		if span.parent != (SpanID{}) {
+			encoded["parentSpanId"] = hex.EncodeToString(span.parent[:])
+		}
+		if span.errMsg != "" {
+			encoded["status"] = map[string]interface{}{"code": 2, "message": span.errMsg}
+		}
+		span.mutex.Unlock()
+		spans = append(spans, encoded)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{"attributes": encodeAttributes(resource)},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": scopeName},
+						"spans": spans,
+					},
+				},
+			},
+		},
+	}
+}
+
+func encodeAttributes(attrs []Attribute) []interface{} {
+	encoded := make([]interface{}, 0, len(attrs))
+	for _, attr := range attrs {
+		var value map[string]interface{}
+		switch v := attr.Value.(type) {
+		case string:
+			value = map[string]interface{}{"stringValue": v}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		encoded = append(encoded, map[string]interface{}{"key": attr.Key, "value": value})
+	}
+	return encoded
+}
+
+func getSetting(db *gorm.DB, key string) string {
+	var setting models.Setting
+	if err := db.Where("key = ?", key).First(&setting).Error; err != nil {
+		return ""
+	}
+	return strings.TrimSpace(setting.Value)
+}
@@ -0,0 +1,235 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"jarvist/internal/common/models"
+	"jarvist/internal/testutil"
+	"jarvist/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+type exportedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Kind         int    `json:"kind"`
+	Status       *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+}
+
+// collector records the spans posted to it
+type collector struct {
+	mutex   sync.Mutex
+	spans   []exportedSpan
+	headers http.Header
+	paths   []string
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []exportedSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.headers = r.Header.Clone()
+	c.paths = append(c.paths, r.URL.Path)
+	for _, resource := range request.ResourceSpans {
+		for _, scope := range resource.ScopeSpans {
+			c.spans = append(c.spans, scope.Spans...)
+		}
+	}
+}
+
+func openTestDB(t *testing.T, settings map[string]string) *gorm.DB {
+	t.Helper()
+
+	db := testutil.OpenDB(t, &models.Setting{})
+	for key, value := range settings {
+		if err := db.Create(&models.Setting{Key: key, Value: value}).Error; err != nil {
+			t.Fatalf("save setting: %v", err)
+		}
+	}
+	return db
+}
+
+func startTracer(t *testing.T, settings map[string]string) *Tracer {
+	t.Helper()
+
+	tracer := New(nil, openTestDB(t, settings), logger.NewLogger())
+	if err := tracer.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(func() {
+		tracer.Stop()
+		active.Store(nil)
+	})
+	return tracer
+}
+
+func TestSpansAreExportedAsOneTrace(t *testing.T) {
+	server := &collector{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	tracer := startTracer(t, map[string]string{
+		EnabledKey:  "true",
+		EndpointKey: ts.URL,
+		HeadersKey:  `{"X-Api-Key":"secret"}`,
+	})
+
+	ctx, root := Start(context.Background(), "file.process")
+	_, child := Start(ctx, "file.decrypt")
+	child.RecordError(errors.New("bad token"))
+	child.End()
+
+	// Pekerjaan asinkron melanjutkan trace yang sama lewat Remember dan Recall
+	Remember(MessageKey(7), ctx)
+	_, publish := StartKind(Recall(context.Background(), MessageKey(7)), KindProducer, "mqtt.publish")
+	publish.End()
+	root.End()
+
+	if err := tracer.Stop(); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if len(server.spans) != 3 {
+		t.Fatalf("exported %d spans, want 3", len(server.spans))
+	}
+	if server.paths[0] != tracesPath {
+		t.Errorf("posted to %s, want %s", server.paths[0], tracesPath)
+	}
+	if server.headers.Get("X-Api-Key") != "secret" {
+		t.Errorf("configured header was not sent")
+	}
+
+	byName := map[string]exportedSpan{}
+	for _, span := range server.spans {
+		byName[span.Name] = span
+		if span.TraceID != server.spans[0].TraceID {
+			t.Errorf("span %s is in trace %s, want %s", span.Name, span.TraceID, server.spans[0].TraceID)
+		}
+	}
+
+	rootSpan := byName["file.process"]
+	if rootSpan.ParentSpanID != "" {
+		t.Errorf("root span has parent %s", rootSpan.ParentSpanID)
+	}
+	for _, name := range []string{"file.decrypt", "mqtt.publish"} {
+		if byName[name].ParentSpanID != rootSpan.SpanID {
+			t.Errorf("%s has parent %s, want %s", name, byName[name].ParentSpanID, rootSpan.SpanID)
+		}
+	}
+	if status := byName["file.decrypt"].Status; status == nil || status.Code != 2 || status.Message != "bad token" {
+		t.Errorf("error status not exported: %+v", status)
+	}
+	if byName["mqtt.publish"].Kind != KindProducer {
+		t.Errorf("publish kind = %d, want %d", byName["mqtt.publish"].Kind, KindProducer)
+	}
+
+	status := tracer.GetStatus()
+	if status.Exported != 3 || status.Failed != 0 || status.LastError != "" {
+		t.Errorf("status = %+v", status)
+	}
+	if len(status.Headers) != 1 || status.Headers[0] != "X-Api-Key" {
+		t.Errorf("header names = %v", status.Headers)
+	}
+}
+
+func TestDisabledTracingRecordsNothing(t *testing.T) {
+	startTracer(t, map[string]string{EndpointKey: "http://127.0.0.1:4318"})
+
+	ctx, span := Start(context.Background(), "file.process")
+	if span != nil {
+		t.Fatalf("span recorded while tracing is disabled")
+	}
+	if SpanContextFrom(ctx).IsValid() {
+		t.Errorf("context carries a span while tracing is disabled")
+	}
+
+	// Semua method aman dipanggil pada span nil
+	span.SetAttributes(String("file.name", "a.json.bson"))
+	span.RecordError(errors.New("ignored"))
+	span.End()
+}
+
+func TestUnsampledTraceHasNoSpans(t *testing.T) {
+	startTracer(t, map[string]string{
+		EnabledKey:       "true",
+		EndpointKey:      "http://127.0.0.1:4318",
+		SamplePercentKey: "0",
+	})
+
+	ctx, root := Start(context.Background(), "file.process")
+	if root != nil {
+		t.Fatalf("root span recorded with 0%% sampling")
+	}
+	if _, child := Start(ctx, "file.decrypt"); child != nil {
+		t.Errorf("child of an unsampled trace was recorded")
+	}
+}
+
+func TestInvalidSettingsDisableExport(t *testing.T) {
+	tracer := startTracer(t, map[string]string{
+		EnabledKey:  "true",
+		EndpointKey: "collector:4318",
+	})
+
+	status := tracer.GetStatus()
+	if status.Enabled || status.SettingsError == "" {
+		t.Errorf("status = %+v, want disabled with a settings error", status)
+	}
+}
+
+func TestTraceparent(t *testing.T) {
+	header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := ParseTraceparent(header)
+	if !ok || !sc.Sampled {
+		t.Fatalf("ParseTraceparent(%q) = %+v, %v", header, sc, ok)
+	}
+	if got := sc.Traceparent(); got != header {
+		t.Errorf("Traceparent() = %q, want %q", got, header)
+	}
+
+	for _, invalid := range []string{"", "00-xyz-00f067aa0ba902b7-01", "00-00000000000000000000000000000000-0000000000000000-01"} {
+		if _, ok := ParseTraceparent(invalid); ok {
+			t.Errorf("ParseTraceparent(%q) accepted", invalid)
+		}
+	}
+}
+
+func TestTracesURL(t *testing.T) {
+	cases := map[string]string{
+		"http://collector:4318":            "http://collector:4318/v1/traces",
+		"http://collector:4318/":           "http://collector:4318/v1/traces",
+		"https://otel.example/v1/traces":   "https://otel.example/v1/traces",
+		"https://otel.example/tenant-a/":   "https://otel.example/tenant-a/v1/traces",
+		"https://otel.example/v1/traces//": "https://otel.example/v1/traces",
+	}
+	for endpoint, want := range cases {
+		if got := tracesURL(endpoint); got != want {
+			t.Errorf("tracesURL(%q) = %q, want %q", endpoint, got, want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Span kinds, the values of the OTLP protocol
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindProducer = 4
+)
+
+// TraceID identifies a trace, all spans of one file or request share it
+type TraceID [16]byte
+
+// SpanID identifies a span within its trace
+type SpanID [8]byte
+
+// SpanContext is the part of a span its children and linked work refer to
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether the context refers to a span
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Traceparent formats the context as a W3C traceparent header
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), flags)
+}
+
+// ParseTraceparent reads a W3C traceparent header, so API requests continue the trace of
+// the caller
+func ParseTraceparent(header string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.IsValid()
+}
+
+// Attribute is a key and value recorded on a span
+type Attribute struct {
+	Key   string
+	Value interface{} // string, int64, float64 or bool
+}
+
+// String returns a string attribute
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int returns an integer attribute
+func Int(key string, value int64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Bool returns a boolean attribute
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Span is a timed operation. A nil span is valid and does nothing, it is returned while
+// tracing is off or the trace is not sampled.
+type Span struct {
+	tracer  *Tracer
+	name    string
+	kind    int
+	context SpanContext
+	parent  SpanID
+	start   time.Time
+
+	mutex  sync.Mutex
+	end    time.Time
+	attrs  []Attribute
+	errMsg string
+	ended  bool
+}
+
+// SetName renames the span, e.g. once the route of a request is known
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	s.name = name
+	s.mutex.Unlock()
+}
+
+// SetAttributes records attributes on the span
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	s.attrs = append(s.attrs, attrs...)
+	s.mutex.Unlock()
+}
+
+// RecordError marks the span as failed, a nil error is ignored
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mutex.Lock()
+	s.errMsg = err.Error()
+	s.mutex.Unlock()
+}
+
+// End finishes the span and hands it to the exporter, later calls do nothing
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mutex.Unlock()
+
+	s.tracer.export(s)
+}
+
+// Context returns the span context, invalid for a nil span
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+type contextKey struct{}
+
+// ContextWithSpanContext returns ctx carrying sc as the parent of new spans
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, contextKey{}, sc)
+}
+
+// SpanContextFrom returns the span context carried by ctx
+func SpanContextFrom(ctx context.Context) SpanContext {
+	if ctx == nil {
+		return SpanContext{}
+	}
+	sc, _ := ctx.Value(contextKey{}).(SpanContext)
+	return sc
+}
+
+// Start begins an internal span as child of the span in ctx
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return StartKind(ctx, KindInternal, name, attrs...)
+}
+
+// StartKind begins a span of the given kind as child of the span in ctx. A trace is sampled
+// once at its root, children follow that decision so traces are never partial.
+func StartKind(ctx context.Context, kind int, name string, attrs ...Attribute) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	tracer := current()
+	if tracer == nil || !tracer.Enabled() {
+		return ctx, nil
+	}
+
+	parent := SpanContextFrom(ctx)
+	sc := SpanContext{TraceID: parent.TraceID, Sampled: parent.Sampled}
+	if !parent.IsValid() {
+		sc.TraceID = newTraceID()
+		sc.Sampled = tracer.sample()
+	}
+	sc.SpanID = newSpanID()
+
+	ctx = ContextWithSpanContext(ctx, sc)
+	if !sc.Sampled {
+		return ctx, nil
+	}
+
+	return ctx, &Span{
+		tracer:  tracer,
+		name:    name,
+		kind:    kind,
+		context: sc,
+		parent:  parent.SpanID,
+		start:   time.Now(),
+		attrs:   attrs,
+	}
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	rand.Read(id[:])
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	rand.Read(id[:])
+	return id
+}
+
+// maxRemembered bounds the span contexts kept for work that continues asynchronously
+const maxRemembered = 10000
+
+// links keeps the span context of work handed to another goroutine, e.g. a message waiting
+// in the send queue, so the span of the later step joins the same trace
+var links = struct {
+	sync.Mutex
+	contexts map[string]link
+	order    []link
+	next     uint64
+}{contexts: make(map[string]link)}
+
+type link struct {
+	key     string
+	seq     uint64
+	context SpanContext
+}
+
+// Remember stores the span context of ctx under key, the oldest entries are dropped when full
+func Remember(key string, ctx context.Context) {
+	sc := SpanContextFrom(ctx)
+	if !sc.IsValid() {
+		return
+	}
+
+	links.Lock()
+	defer links.Unlock()
+	links.next++
+	entry := link{key: key, seq: links.next, context: sc}
+	links.contexts[key] = entry
+	links.order = append(links.order, entry)
+
+	// Entri yang sudah dilupakan atau ditimpa tetap ada di order sampai gilirannya dibuang
+	for len(links.order) > maxRemembered {
+		oldest := links.order[0]
+		links.order = links.order[1:]
+		if latest, ok := links.contexts[oldest.key]; ok && latest.seq == oldest.seq {
+			delete(links.contexts, oldest.key)
+		}
+	}
+}
+
+// Recall returns a context carrying the span context remembered under key, or ctx when
+// there is none
+func Recall(ctx context.Context, key string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	links.Lock()
+	entry, ok := links.contexts[key]
+	links.Unlock()
+	if !ok {
+		return ctx
+	}
+	return ContextWithSpanContext(ctx, entry.context)
+}
+
+// Forget drops the span context remembered under key once its work is done
+func Forget(key string) {
+	links.Lock()
+	delete(links.contexts, key)
+	links.Unlock()
+}
+
+// MessageKey is the key the trace of a queued MQTT message is remembered under
+func MessageKey(messageID uint) string {
+	return fmt.Sprintf("message:%d", messageID)
+}
+
+// FileKey is the key the trace of a detected file is remembered under until it is processed
+func FileKey(path string) string {
+	return "file:" + strings.ToLower(path)
+}
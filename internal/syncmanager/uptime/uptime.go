@@ -0,0 +1,94 @@
+// Package uptime records when the sync manager process and its components started, so
+// the API status, heartbeats and summaries report the same uptime.
+package uptime
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	mu         sync.Mutex
+	processAt  = time.Now()
+	serviceAt  time.Time
+	components = make(map[string]time.Time)
+)
+
+// ComponentInfo describes the start of one component
+type ComponentInfo struct {
+	Name          string    `json:"name"`
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+}
+
+// Info describes the uptime of the service
+type Info struct {
+	StartedAt        time.Time       `json:"started_at"`
+	ProcessStartedAt time.Time       `json:"process_started_at"`
+	Uptime           string          `json:"uptime"`
+	UptimeSeconds    int64           `json:"uptime_seconds"`
+	Components       []ComponentInfo `json:"components,omitempty"`
+}
+
+// MarkServiceStarted records that all components are running. Restarting the service
+// inside the same process (Windows service stop/start) moves the start time.
+func MarkServiceStarted() {
+	mu.Lock()
+	defer mu.Unlock()
+	serviceAt = time.Now()
+}
+
+// MarkComponentStarted records the start of a component
+func MarkComponentStarted(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	components[name] = time.Now()
+}
+
+// StartedAt returns when the service started, the process start until MarkServiceStarted
+func StartedAt() time.Time {
+	mu.Lock()
+	defer mu.Unlock()
+	return startedAtLocked()
+}
+
+// Uptime returns the time since the service started
+func Uptime() time.Duration {
+	return time.Since(StartedAt())
+}
+
+// Get returns the service and component start times
+func Get() Info {
+	mu.Lock()
+	defer mu.Unlock()
+
+	now := time.Now()
+	started := startedAtLocked()
+	uptime := now.Sub(started)
+
+	info := Info{
+		StartedAt:        started,
+		ProcessStartedAt: processAt,
+		Uptime:           uptime.Truncate(time.Second).String(),
+		UptimeSeconds:    int64(uptime.Seconds()),
+	}
+	for name, at := range components {
+		info.Components = append(info.Components, ComponentInfo{
+			Name:          name,
+			StartedAt:     at,
+			UptimeSeconds: int64(now.Sub(at).Seconds()),
+		})
+	}
+	sort.Slice(info.Components, func(i, j int) bool {
+		return info.Components[i].Name < info.Components[j].Name
+	})
+	return info
+}
+
+func startedAtLocked() time.Time {
+	if serviceAt.IsZero() {
+		return processAt
+	}
+	return serviceAt
+}
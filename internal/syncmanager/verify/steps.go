@@ -0,0 +1,225 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"jarvist/internal/common/ffmpeg"
+	"jarvist/internal/common/models"
+	"jarvist/internal/syncmanager/datafile"
+	"jarvist/internal/syncmanager/sync"
+	"os"
+	"path/filepath"
+	gosync "sync"
+	"time"
+)
+
+// probeWorkers limits the cameras probed at the same time
+const probeWorkers = 4
+
+// CameraResult is the probe of one camera
+type CameraResult struct {
+	ID      uint   `json:"id"`
+	UUID    string `json:"uuid"`
+	Name    string `json:"name"`
+	Online  bool   `json:"online"`
+	Message string `json:"message"`
+	Error   string `json:"error,omitempty"`
+}
+
+// checkCameras probes every camera with the same options as the desktop app
+func (v *Verifier) checkCameras(ctx context.Context, r *run) Step {
+	var cameras []models.Camera
+	if err := v.db.Where("deleted_at IS NULL").Order("id").Find(&cameras).Error; err != nil {
+		return Step{Status: StatusFail, Message: "Failed to read cameras: " + err.Error()}
+	}
+	if len(cameras) == 0 {
+		return Step{
+			Status:  StatusFail,
+			Message: "No cameras configured",
+			Hint:    "Add the cameras of this site in the desktop app",
+		}
+	}
+
+	if ffmpeg.GetFFmpegPath() == "" && v.cfg.BaseConfig != nil {
+		if err := ffmpeg.SetupFFmpeg(v.cfg.BaseConfig, v.logger); err != nil {
+			return Step{
+				Status:  StatusFail,
+				Message: "FFmpeg not found: " + err.Error(),
+				Hint:    "Reinstall the application, cameras cannot be probed without FFmpeg",
+			}
+		}
+	}
+
+	global := v.globalProbeOptions()
+	results := make([]CameraResult, len(cameras))
+	jobs := make(chan int)
+	var wg gosync.WaitGroup
+
+	for w := 0; w < probeWorkers && w < len(cameras); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = v.probeCamera(ctx, cameras[i], global)
+			}
+		}()
+	}
+	for i := range cameras {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	offline := 0
+	for _, result := range results {
+		if !result.Online {
+			offline++
+		}
+	}
+
+	step := Step{Details: results}
+	switch {
+	case offline == 0:
+		step.Status = StatusPass
+		step.Message = fmt.Sprintf("All %d cameras are reachable", len(cameras))
+	default:
+		step.Status = StatusFail
+		step.Message = fmt.Sprintf("%d of %d cameras are not reachable", offline, len(cameras))
+		step.Hint = "Check the network, address and credentials of the cameras listed as offline"
+	}
+	return step
+}
+
+func (v *Verifier) probeCamera(ctx context.Context, camera models.Camera, global ffmpeg.RTSPOptions) CameraResult {
+	result := CameraResult{ID: camera.ID, UUID: camera.UUID, Name: camera.Name}
+
+	var override ffmpeg.RTSPOptions
+	if camera.ProbeOptions != "" {
+		json.Unmarshal([]byte(camera.ProbeOptions), &override)
+	}
+
+	rtspConfig := ffmpeg.RTSPConfig{
+		Schema:   camera.Schema,
+		Host:     camera.Host,
+		Port:     camera.Port,
+		Path:     camera.Path,
+		Username: camera.Username,
+		Password: camera.Password,
+	}
+
+	var response ffmpeg.ResponseJSON
+	responseStr := ffmpeg.CheckRTSPConnectionWithConfigContext(ctx, rtspConfig, global.Merge(override))
+	if err := json.Unmarshal([]byte(responseStr), &response); err != nil {
+		result.Message = "Invalid probe response"
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Online = response.Success
+	result.Message = response.Message
+	result.Error = response.Error
+	return result
+}
+
+// globalProbeOptions reads the probe options saved in the desktop app
+func (v *Verifier) globalProbeOptions() ffmpeg.RTSPOptions {
+	var options ffmpeg.RTSPOptions
+
+	var setting models.Setting
+	if err := v.db.Where("key = ?", ffmpeg.ProbeOptionsKey).First(&setting).Error; err != nil || setting.Value == "" {
+		return options
+	}
+	if json.Unmarshal([]byte(setting.Value), &options) != nil {
+		return ffmpeg.RTSPOptions{}
+	}
+	return options
+}
+
+// checkDataFile writes a synthetic counter file outside the watched data directory and
+// decrypts it through the same path as the real files
+func (v *Verifier) checkDataFile(ctx context.Context, r *run) Step {
+	if v.cfg.BaseConfig == nil {
+		return Step{Status: StatusFail, Message: "Base config not loaded"}
+	}
+
+	now := time.Now()
+	doc := map[string]interface{}{
+		"id":                   r.report.ID,
+		"cctv_id":              0,
+		"device_id":            "verify",
+		"device_timestamp":     now.Format("2006-01-02 15:04:05"),
+		"device_timestamp_utc": float64(now.Unix()),
+		"start_time":           now.Format("2006-01-02 15:04:05"),
+		"in_count":             1,
+		"out_count":            1,
+	}
+
+	token, err := datafile.Encode(doc, v.cfg.Advanced.FernetKey)
+	if err != nil {
+		return Step{
+			Status:  StatusFail,
+			Message: "Failed to encrypt the synthetic data: " + err.Error(),
+			Hint:    "The Fernet key of the sync service is invalid",
+		}
+	}
+
+	// Ditulis di luar direktori data agar synchronizer tidak mengirimnya sebagai data asli
+	dir := filepath.Join(v.cfg.BaseConfig.DataDir, "verify")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Step{Status: StatusFail, Message: "Failed to create " + dir + ": " + err.Error()}
+	}
+	path := filepath.Join(dir, r.report.ID+datafile.Suffix)
+	if err := os.WriteFile(path, token, 0644); err != nil {
+		return Step{Status: StatusFail, Message: "Failed to write the synthetic data file: " + err.Error()}
+	}
+	defer os.Remove(path)
+
+	topic, payload, err := v.synchronizer.VerifyFile(path, now.Format(sync.DateFolderPattern))
+	if err != nil {
+		return Step{
+			Status:  StatusFail,
+			Message: "Failed to decrypt the synthetic data file: " + err.Error(),
+			Hint:    "The Fernet key of the sync service does not match the data files",
+		}
+	}
+
+	r.topic = topic
+	r.payload = payload
+	return Step{
+		Status:  StatusPass,
+		Message: fmt.Sprintf("Synthetic data file written and decrypted, it would be published to %s", topic),
+		Details: payload,
+	}
+}
+
+// checkPublish publishes the decrypted synthetic data to the test topic and waits for the
+// broker to acknowledge it
+func (v *Verifier) checkPublish(ctx context.Context, r *run) Step {
+	if r.payload == nil {
+		return Step{Status: StatusSkip, Message: "Skipped, the synthetic data file was not decrypted"}
+	}
+
+	topic := v.cfg.MQTT.Topic + TestTopic
+	message := map[string]interface{}{
+		"type":            "install_verification",
+		"verification_id": r.report.ID,
+		"synthetic":       true,
+		"data_topic":      r.topic,
+		"payload":         r.payload,
+		"timestamp":       time.Now().Format(time.RFC3339),
+	}
+
+	if err := v.sender.PublishNow(topic, message); err != nil {
+		return Step{
+			Status:  StatusFail,
+			Message: fmt.Sprintf("Failed to publish to %s: %v", topic, err),
+			Hint:    "Check the internet connection and the MQTT broker settings",
+		}
+	}
+
+	return Step{
+		Status:  StatusPass,
+		Message: "Synthetic data acknowledged by the broker on " + topic,
+	}
+}
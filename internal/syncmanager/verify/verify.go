@@ -0,0 +1,292 @@
+// Package verify runs the installation verification, a scripted smoke test the installer
+// runs before leaving the site. It probes every camera, writes a synthetic data file and
+// checks it flows through decrypt and publish to a test topic, checks the service
+// components and produces a pass or fail report the installer signs off.
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/identity"
+	"jarvist/internal/common/licensestate"
+	"jarvist/internal/common/models"
+	"jarvist/internal/syncmanager/components"
+	"jarvist/internal/syncmanager/config"
+	"jarvist/internal/syncmanager/maintmode"
+	"jarvist/internal/syncmanager/mqtt"
+	"jarvist/internal/syncmanager/sync"
+	"jarvist/pkg/logger"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ComponentVerify = "verify"
+
+	// ReportKey is the setting holding the last report, kept so the sign-off survives a restart
+	ReportKey = "install_verification"
+
+	// TestTopic is appended to the MQTT base topic for the synthetic message
+	TestTopic = "/verify"
+)
+
+// Step statuses
+const (
+	StatusPass = "pass"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+	StatusSkip = "skip"
+)
+
+var (
+	ErrRunning   = errors.New("an installation verification is already running")
+	ErrNoReport  = errors.New("no installation verification has been run yet")
+	ErrNotPassed = errors.New("the last installation verification did not pass, fix the failures and run it again")
+)
+
+// Step is the outcome of one part of the verification
+type Step struct {
+	Name     string      `json:"name"`
+	Status   string      `json:"status"`
+	Message  string      `json:"message"`
+	Hint     string      `json:"hint,omitempty"`
+	Details  interface{} `json:"details,omitempty"`
+	Duration string      `json:"duration"`
+}
+
+// SignOff records the installer who accepted a passed report
+type SignOff struct {
+	Installer string    `json:"installer"`
+	Notes     string    `json:"notes,omitempty"`
+	SignedAt  time.Time `json:"signed_at"`
+}
+
+// Report is the result of a verification run
+type Report struct {
+	ID         string            `json:"id"`
+	Passed     bool              `json:"passed"`
+	Steps      []Step            `json:"steps"`
+	Identity   identity.Identity `json:"identity"`
+	Version    string            `json:"version,omitempty"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	SignOff    *SignOff          `json:"sign_off,omitempty"`
+}
+
+// Verifier runs the installation verification
+type Verifier struct {
+	cfg          *config.Config
+	db           *gorm.DB
+	synchronizer *sync.Synchronizer
+	sender       *mqtt.Sender
+	components   *components.Registry
+	maintenance  *maintmode.Manager
+	logger       *logger.Logger
+
+	mu      gosync.Mutex
+	running bool
+}
+
+// New creates a verifier
+func New(cfg *config.Config, db *gorm.DB, synchronizer *sync.Synchronizer, sender *mqtt.Sender,
+	registry *components.Registry, maintenance *maintmode.Manager, logger *logger.Logger) *Verifier {
+	return &Verifier{
+		cfg:          cfg,
+		db:           db,
+		synchronizer: synchronizer,
+		sender:       sender,
+		components:   registry,
+		maintenance:  maintenance,
+		logger:       logger,
+	}
+}
+
+// Run executes all steps and stores the report. A failed step fails the report, warnings
+// are shown to the installer but do not block the sign-off.
+func (v *Verifier) Run(ctx context.Context) (Report, error) {
+	v.mu.Lock()
+	if v.running {
+		v.mu.Unlock()
+		return Report{}, ErrRunning
+	}
+	v.running = true
+	v.mu.Unlock()
+
+	defer func() {
+		v.mu.Lock()
+		v.running = false
+		v.mu.Unlock()
+	}()
+
+	report := Report{
+		ID:        fmt.Sprintf("verify_%d", time.Now().Unix()),
+		Passed:    true,
+		Identity:  identity.Get(v.db),
+		StartedAt: time.Now(),
+	}
+	if v.cfg.BaseConfig != nil {
+		report.Version = v.cfg.BaseConfig.BuildInfo.ProductVersion
+	}
+
+	v.logger.Info(ComponentVerify, "Installation verification %s started", report.ID)
+
+	steps := []struct {
+		name string
+		fn   func(ctx context.Context, r *run) Step
+	}{
+		{"service", v.checkService},
+		{"cameras", v.checkCameras},
+		{"data_file", v.checkDataFile},
+		{"publish", v.checkPublish},
+	}
+
+	r := &run{report: &report}
+	for _, s := range steps {
+		start := time.Now()
+		step := s.fn(ctx, r)
+		step.Name = s.name
+		step.Duration = time.Since(start).Round(time.Millisecond).String()
+
+		if step.Status == StatusFail {
+			report.Passed = false
+		}
+		report.Steps = append(report.Steps, step)
+	}
+	report.FinishedAt = time.Now()
+
+	if err := v.save(report); err != nil {
+		v.logger.Warning(ComponentVerify, "Failed to store installation verification report: %v", err)
+	}
+
+	if report.Passed {
+		v.logger.Info(ComponentVerify, "Installation verification %s passed", report.ID)
+	} else {
+		var failed []string
+		for _, step := range report.Steps {
+			if step.Status == StatusFail {
+				failed = append(failed, step.Name)
+			}
+		}
+		v.logger.Warning(ComponentVerify, "Installation verification %s failed: %s", report.ID, strings.Join(failed, ", "))
+	}
+	return report, nil
+}
+
+// Last returns the stored report of the last run
+func (v *Verifier) Last() (Report, error) {
+	var setting models.Setting
+	if err := v.db.Where("key = ?", ReportKey).First(&setting).Error; err != nil || setting.Value == "" {
+		return Report{}, ErrNoReport
+	}
+
+	var report Report
+	if err := json.Unmarshal([]byte(setting.Value), &report); err != nil {
+		return Report{}, fmt.Errorf("stored report is invalid: %w", err)
+	}
+	return report, nil
+}
+
+// Sign records the installer accepting the last report, which must have passed
+func (v *Verifier) Sign(installer, notes string) (Report, error) {
+	installer = strings.TrimSpace(installer)
+	if installer == "" {
+		return Report{}, errors.New("installer name is required")
+	}
+
+	report, err := v.Last()
+	if err != nil {
+		return Report{}, err
+	}
+	if !report.Passed {
+		return Report{}, ErrNotPassed
+	}
+
+	report.SignOff = &SignOff{
+		Installer: installer,
+		Notes:     strings.TrimSpace(notes),
+		SignedAt:  time.Now(),
+	}
+	if err := v.save(report); err != nil {
+		return Report{}, err
+	}
+
+	v.logger.Info(ComponentVerify, "Installation verification %s signed off by %s", report.ID, installer)
+	return report, nil
+}
+
+func (v *Verifier) save(report Report) error {
+	value, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	var setting models.Setting
+	result := v.db.Where("key = ?", ReportKey).First(&setting)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return v.db.Create(&models.Setting{Key: ReportKey, Value: string(value)}).Error
+	} else if result.Error != nil {
+		return result.Error
+	}
+
+	setting.Value = string(value)
+	return v.db.Save(&setting).Error
+}
+
+// run carries the state between the steps of one verification
+type run struct {
+	report *Report
+
+	// Topic and payload built from the synthetic data file, published by the publish step
+	topic   string
+	payload map[string]interface{}
+}
+
+// checkService checks the components are healthy and nothing holds back publishing
+func (v *Verifier) checkService(ctx context.Context, r *run) Step {
+	var unhealthy []string
+	infos := v.components.List()
+	for _, info := range infos {
+		if !info.Healthy {
+			unhealthy = append(unhealthy, fmt.Sprintf("%s (%s)", info.Key, info.HealthError))
+		}
+	}
+	if len(unhealthy) > 0 {
+		return Step{
+			Status:  StatusFail,
+			Message: "Unhealthy components: " + strings.Join(unhealthy, ", "),
+			Hint:    "Check the logs of the sync service and restart the failing component",
+			Details: infos,
+		}
+	}
+
+	var held []string
+	if v.maintenance.GetState().Active {
+		held = append(held, "maintenance mode is on")
+	}
+	if licensestate.Get(v.db).Degraded {
+		held = append(held, "the license has expired")
+	}
+	if state := v.sender.GetUploadPauseState(); state.Paused {
+		held = append(held, "non-critical uploads are paused ("+state.Reason+")")
+	}
+	if len(held) > 0 {
+		return Step{
+			Status:  StatusWarn,
+			Message: fmt.Sprintf("All %d components are healthy, but %s", len(infos), strings.Join(held, ", ")),
+			Hint:    "Data is kept locally and sent once this is resolved",
+			Details: infos,
+		}
+	}
+
+	return Step{
+		Status:  StatusPass,
+		Message: fmt.Sprintf("All %d components are healthy", len(infos)),
+		Details: infos,
+	}
+}
@@ -0,0 +1,62 @@
+//go:build !windows
+
+package watchdog
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// systemdNotifier speaks the sd_notify protocol over NOTIFY_SOCKET
+type systemdNotifier struct {
+	socket  string
+	timeout time.Duration
+}
+
+// newNotifier reads NOTIFY_SOCKET and WATCHDOG_USEC set by systemd for Type=notify units
+// with WatchdogSec
+func newNotifier() notifier {
+	n := &systemdNotifier{socket: os.Getenv("NOTIFY_SOCKET")}
+
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return n
+	}
+	if usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64); err == nil && usec > 0 {
+		n.timeout = time.Duration(usec) * time.Microsecond
+	}
+	return n
+}
+
+func (n *systemdNotifier) notify(state string) error {
+	if n.socket == "" {
+		return nil
+	}
+
+	addr := &net.UnixAddr{Name: n.socket, Net: "unixgram"}
+	// Socket abstrak ditulis dengan @ di depan
+	if addr.Name[0] == '@' {
+		addr.Name = "\x00" + addr.Name[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+func (n *systemdNotifier) watchdogEnabled() bool {
+	return n.socket != "" && n.timeout > 0
+}
+
+func (n *systemdNotifier) watchdogTimeout() time.Duration {
+	if !n.watchdogEnabled() {
+		return 0
+	}
+	return n.timeout
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package watchdog
+
+import "time"
+
+// windowsNotifier does nothing, the Windows service manager has no watchdog. A hung
+// process is restarted through the service failure actions after the watchdog exits.
+type windowsNotifier struct{}
+
+func newNotifier() notifier {
+	return windowsNotifier{}
+}
+
+func (windowsNotifier) notify(state string) error { return nil }
+
+func (windowsNotifier) watchdogEnabled() bool { return false }
+
+func (windowsNotifier) watchdogTimeout() time.Duration { return 0 }
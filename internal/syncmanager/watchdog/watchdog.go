@@ -0,0 +1,327 @@
+// Package watchdog notifies the OS service manager while the sync manager is healthy. Under
+// systemd with WatchdogSec the watchdog is only petted while all health checks pass. On
+// Windows, where the service manager has no watchdog, the process exits after staying
+// unhealthy too long so the service failure actions restart it.
+package watchdog
+
+import (
+	"errors"
+	"fmt"
+	"jarvist/internal/syncmanager/interfaces"
+	"jarvist/internal/syncmanager/power"
+	"jarvist/pkg/logger"
+	"os"
+	"sync"
+	"time"
+)
+
+const ComponentWatchdog = "watchdog"
+
+const (
+	// checkTimeout limits a health check, a check that does not return counts as failed
+	checkTimeout = 10 * time.Second
+
+	// exitCode is returned when the process exits because it stayed unhealthy
+	exitCode = 3
+)
+
+// Config configures the watchdog
+type Config struct {
+	Interval       time.Duration // Health check interval, systemd WatchdogSec/2 is used when shorter
+	FailureTimeout time.Duration // Exit after being unhealthy this long, 0 to never exit
+}
+
+// notifier reports the service state to the OS service manager
+type notifier interface {
+	notify(state string) error
+	watchdogEnabled() bool
+	watchdogTimeout() time.Duration
+}
+
+// Check is a named health check
+type Check struct {
+	Name    string
+	Checker interfaces.HealthChecker
+}
+
+// CheckStatus is the result of the last run of a health check
+type CheckStatus struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Status describes the watchdog
+type Status struct {
+	Running        bool          `json:"running"`
+	Systemd        bool          `json:"systemd"` // systemd watchdog aktif
+	IntervalSec    float64       `json:"interval_sec"`
+	FailureSec     float64       `json:"failure_timeout_sec"`
+	Healthy        bool          `json:"healthy"`
+	LastHealthy    time.Time     `json:"last_healthy"`
+	UnhealthyForMs int64         `json:"unhealthy_for_ms"`
+	Checks         []CheckStatus `json:"checks"`
+}
+
+// Watchdog runs the health checks and notifies the OS service manager
+type Watchdog struct {
+	cfg      Config
+	logger   *logger.Logger
+	checks   []Check
+	notifier notifier
+	exit     func(code int)
+
+	mu          sync.Mutex
+	running     bool
+	healthy     bool
+	lastHealthy time.Time
+	results     []CheckStatus
+	inFlight    map[string]bool
+	quitChan    chan struct{}
+	wg          sync.WaitGroup
+}
+
+// New creates a watchdog for the given health checks
+func New(cfg Config, logger *logger.Logger, checks []Check) *Watchdog {
+	return &Watchdog{
+		cfg:      cfg,
+		logger:   logger,
+		checks:   checks,
+		notifier: newNotifier(),
+		exit:     os.Exit,
+		inFlight: make(map[string]bool),
+	}
+}
+
+// ChecksFor returns a health check for every component that implements HealthChecker
+func ChecksFor(components []interfaces.ServiceComponent) []Check {
+	var checks []Check
+	for i, component := range components {
+		checker, ok := component.(interfaces.HealthChecker)
+		if !ok {
+			continue
+		}
+		name := fmt.Sprintf("Component %d", i+1)
+		if named, ok := component.(interface{ Name() string }); ok {
+			name = named.Name()
+		}
+		checks = append(checks, Check{Name: name, Checker: checker})
+	}
+	return checks
+}
+
+// Name returns the component name used in startup logs
+func (w *Watchdog) Name() string {
+	return "Watchdog"
+}
+
+// Start tells the service manager the service is ready and starts the health checks
+func (w *Watchdog) Start() error {
+	w.mu.Lock()
+	if w.running {
+		w.mu.Unlock()
+		return nil
+	}
+	w.running = true
+	w.healthy = true
+	w.lastHealthy = time.Now()
+	w.quitChan = make(chan struct{})
+	quitChan := w.quitChan
+	w.mu.Unlock()
+
+	if err := w.notifier.notify("READY=1"); err != nil {
+		w.logger.Warning(ComponentWatchdog, "Failed to notify service manager: %v", err)
+	}
+
+	interval := w.interval()
+	w.wg.Add(1)
+	go w.run(quitChan, interval)
+
+	if w.notifier.watchdogEnabled() {
+		w.logger.Info(ComponentWatchdog, "Watchdog started, notifying systemd every %s", interval)
+	} else {
+		w.logger.Info(ComponentWatchdog, "Watchdog started, checking health every %s", interval)
+	}
+	return nil
+}
+
+// Stop stops the health checks, the service manager is told the service is stopping so
+// a slow shutdown is not treated as a hang
+func (w *Watchdog) Stop() error {
+	w.mu.Lock()
+	if !w.running {
+		w.mu.Unlock()
+		return nil
+	}
+	w.running = false
+	close(w.quitChan)
+	w.mu.Unlock()
+
+	w.notifier.notify("STOPPING=1")
+	w.wg.Wait()
+
+	w.logger.Info(ComponentWatchdog, "Watchdog stopped")
+	return nil
+}
+
+// GetStatus returns the result of the last health check run
+func (w *Watchdog) GetStatus() Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	status := Status{
+		Running:     w.running,
+		Systemd:     w.notifier.watchdogEnabled(),
+		IntervalSec: w.interval().Seconds(),
+		FailureSec:  w.cfg.FailureTimeout.Seconds(),
+		Healthy:     w.healthy,
+		LastHealthy: w.lastHealthy,
+		Checks:      append([]CheckStatus(nil), w.results...),
+	}
+	if !w.healthy {
+		status.UnhealthyForMs = time.Since(w.lastHealthy).Milliseconds()
+	}
+	return status
+}
+
+// Suspend is called before the machine sleeps
+func (w *Watchdog) Suspend() {}
+
+// Resume restarts the failure timeout, time asleep does not count as unhealthy
+func (w *Watchdog) Resume(window power.SleepWindow) {
+	w.mu.Lock()
+	w.lastHealthy = time.Now()
+	w.mu.Unlock()
+}
+
+// interval returns the check interval, at most half of the systemd watchdog timeout
+func (w *Watchdog) interval() time.Duration {
+	interval := w.cfg.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	if timeout := w.notifier.watchdogTimeout(); timeout > 0 && timeout/2 < interval {
+		interval = timeout / 2
+	}
+	return interval
+}
+
+func (w *Watchdog) run(quitChan chan struct{}, interval time.Duration) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.tick()
+		case <-quitChan:
+			return
+		}
+	}
+}
+
+// tick runs the health checks and pets the OS watchdog when all pass
+func (w *Watchdog) tick() {
+	results := w.runChecks()
+
+	var failed *CheckStatus
+	for i := range results {
+		if !results[i].Healthy {
+			failed = &results[i]
+			break
+		}
+	}
+
+	now := time.Now()
+	w.mu.Lock()
+	wasHealthy := w.healthy
+	w.healthy = failed == nil
+	w.results = results
+	if failed == nil {
+		w.lastHealthy = now
+	}
+	unhealthyFor := now.Sub(w.lastHealthy)
+	w.mu.Unlock()
+
+	if failed == nil {
+		if !wasHealthy {
+			w.logger.Info(ComponentWatchdog, "Health checks pass again after %s", unhealthyFor.Truncate(time.Second))
+		}
+		if err := w.notifier.notify("WATCHDOG=1"); err != nil {
+			w.logger.Warning(ComponentWatchdog, "Failed to notify watchdog: %v", err)
+		}
+		return
+	}
+
+	if wasHealthy {
+		w.logger.Event(logger.LevelWarn, ComponentWatchdog, logger.EventWatchdogUnhealthy,
+			logger.F("component", failed.Name), logger.F("error", failed.Error))
+	}
+
+	if w.cfg.FailureTimeout > 0 && unhealthyFor >= w.cfg.FailureTimeout {
+		w.logger.Event(logger.LevelError, ComponentWatchdog, logger.EventWatchdogRestart,
+			logger.F("component", failed.Name), logger.F("duration", unhealthyFor.Truncate(time.Second)))
+
+		// systemd bisa diminta restart langsung, di Windows proses keluar dan failure action restart service
+		if w.notifier.watchdogEnabled() {
+			if err := w.notifier.notify("WATCHDOG=trigger"); err == nil {
+				return
+			}
+		}
+		w.exit(exitCode)
+	}
+}
+
+// runChecks runs all checks in parallel with a timeout. A check still running from a
+// previous tick is not started again and counts as failed.
+func (w *Watchdog) runChecks() []CheckStatus {
+	results := make([]CheckStatus, len(w.checks))
+
+	var wg sync.WaitGroup
+	for i, check := range w.checks {
+		results[i] = CheckStatus{Name: check.Name}
+
+		w.mu.Lock()
+		busy := w.inFlight[check.Name]
+		if !busy {
+			w.inFlight[check.Name] = true
+		}
+		w.mu.Unlock()
+
+		if busy {
+			results[i].Error = "previous health check still running"
+			continue
+		}
+
+		done := make(chan error, 1)
+		go func(check Check) {
+			err := check.Checker.HealthCheck()
+			w.mu.Lock()
+			delete(w.inFlight, check.Name)
+			w.mu.Unlock()
+			done <- err
+		}(check)
+
+		wg.Add(1)
+		go func(result *CheckStatus) {
+			defer wg.Done()
+
+			var err error
+			select {
+			case err = <-done:
+			case <-time.After(checkTimeout):
+				err = errors.New("health check timed out")
+			}
+
+			result.Healthy = err == nil
+			if err != nil {
+				result.Error = err.Error()
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+
+	return results
+}
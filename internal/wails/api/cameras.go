@@ -0,0 +1,122 @@
+package api
+
+import (
+	"jarvist/internal/common/models"
+	"jarvist/internal/wails/services/camera"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (s *Server) getCameras(c *fiber.Ctx) error {
+	cameras, err := s.services.Camera.GetCamerasWithStatus()
+	if err != nil {
+		return serviceError(err, fiber.StatusInternalServerError, "failed to list cameras")
+	}
+	return c.JSON(cameras)
+}
+
+func (s *Server) getCamera(c *fiber.Ctx) error {
+	id, err := paramID(c)
+	if err != nil {
+		return err
+	}
+	camera, err := s.services.Camera.GetCameraByID(id)
+	if err != nil {
+		return serviceError(err, fiber.StatusInternalServerError, "failed to get camera")
+	}
+	status, _ := s.services.Camera.GetConnectionStatus(camera.UUID)
+	return c.JSON(fiber.Map{
+		"camera": camera,
+		"status": status,
+	})
+}
+
+func (s *Server) createCamera(c *fiber.Ctx) error {
+	var input models.CameraInput
+	if err := c.BodyParser(&input); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	camera, err := s.services.Camera.CreateCamera(input)
+	if err != nil {
+		return serviceError(err, fiber.StatusBadRequest, "failed to create camera")
+	}
+	return c.Status(fiber.StatusCreated).JSON(camera)
+}
+
+func (s *Server) updateCamera(c *fiber.Ctx) error {
+	id, err := paramID(c)
+	if err != nil {
+		return err
+	}
+	var input models.CameraInput
+	if err := c.BodyParser(&input); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	camera, err := s.services.Camera.UpdateCamera(id, input)
+	if err != nil {
+		return serviceError(err, fiber.StatusBadRequest, "failed to update camera")
+	}
+	return c.JSON(camera)
+}
+
+func (s *Server) deleteCamera(c *fiber.Ctx) error {
+	id, err := paramID(c)
+	if err != nil {
+		return err
+	}
+	if err := s.services.Camera.DeleteCamera(id); err != nil {
+		return serviceError(err, fiber.StatusInternalServerError, "failed to delete camera")
+	}
+	return c.JSON(fiber.Map{"deleted": id})
+}
+
+// checkCamera probes the camera now instead of waiting for the background check
+func (s *Server) checkCamera(c *fiber.Ctx) error {
+	id, err := paramID(c)
+	if err != nil {
+		return err
+	}
+	status, err := s.services.Camera.CheckCameraConnectionNow(c.UserContext(), id)
+	if err != nil {
+		return serviceError(err, fiber.StatusBadGateway, "failed to check camera")
+	}
+	return c.JSON(status)
+}
+
+// exportCameras answers the cameras as ?format=csv or json (default), passwords are only
+// included with ?passwords=true
+func (s *Server) exportCameras(c *fiber.Ctx) error {
+	format := strings.ToLower(c.Query("format", camera.CameraFormatJSON))
+	data, err := s.services.Camera.ExportCameras(format, c.QueryBool("passwords"))
+	if err != nil {
+		return serviceError(err, fiber.StatusBadRequest, "failed to export cameras")
+	}
+	if format == camera.CameraFormatCSV {
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	} else {
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	}
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="cameras.`+format+`"`)
+	return c.SendString(data)
+}
+
+// importCameras creates the cameras of the CSV or JSON request body, ?format=csv for CSV.
+// With ?dry_run=true the body is only validated. A body with errors is answered with 422 and
+// the report of the rows.
+func (s *Server) importCameras(c *fiber.Ctx) error {
+	format := c.Query("format", camera.CameraFormatJSON)
+	dryRun := c.QueryBool("dry_run")
+	report, err := s.services.Camera.ImportCamerasData(c.Body(), format, dryRun)
+	if err != nil {
+		return serviceError(err, fiber.StatusBadRequest, "failed to import cameras")
+	}
+	switch {
+	case report.ErrorCount > 0:
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(report)
+	case dryRun:
+		return c.JSON(report)
+	default:
+		return c.Status(fiber.StatusCreated).JSON(report)
+	}
+}
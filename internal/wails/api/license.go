@@ -0,0 +1,95 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// getLicense returns the license status without the license and API keys
+func (s *Server) getLicense(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"licensed":    s.services.License.IsLicensed(),
+		"status":      s.services.License.GetLicenseStatus(),
+		"degradation": s.services.License.GetDegradation(),
+	})
+}
+
+func (s *Server) registerLicense(c *fiber.Ctx) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+	var req struct {
+		LicenseKey string `json:"license_key"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	req.LicenseKey = strings.TrimSpace(req.LicenseKey)
+	if req.LicenseKey == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "license_key is required")
+	}
+
+	result := s.services.License.RegisterLicense(req.LicenseKey)
+	if !result.Success {
+		return fiber.NewError(fiber.StatusBadRequest, result.Message)
+	}
+	return c.JSON(result)
+}
+
+func (s *Server) deactivateLicense(c *fiber.Ctx) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+	result := s.services.License.DeactivateLicense()
+	if !result.Success {
+		return fiber.NewError(fiber.StatusBadRequest, result.Message)
+	}
+	return c.JSON(result)
+}
+
+// createActivationRequest returns the signed activation request of this machine, for sites
+// where the license server cannot be reached
+func (s *Server) createActivationRequest(c *fiber.Ctx) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+	var req struct {
+		LicenseKey string `json:"license_key"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+
+	data, err := s.services.License.CreateActivationRequest(req.LicenseKey)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="activation-request.json"`)
+	return c.SendString(data)
+}
+
+// importActivation activates the license from the activation file in the request body
+func (s *Server) importActivation(c *fiber.Ctx) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+	result := s.services.License.ImportActivationData(string(c.Body()))
+	if !result.Success {
+		return fiber.NewError(fiber.StatusBadRequest, result.Message)
+	}
+	return c.JSON(result)
+}
+
+// requireUnlocked refuses license changes while the app is locked with the admin PIN, the
+// API token alone cannot deactivate the device
+func (s *Server) requireUnlocked() error {
+	if s.services.Guard == nil {
+		return nil
+	}
+	if err := s.services.Guard.RequireUnlocked(); err != nil {
+		return serviceError(err, fiber.StatusForbidden, "license change refused")
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"jarvist/internal/wails/services/processmanager"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// processStatus is the state of one managed process
+type processStatus struct {
+	ProcessId string `json:"process_id"`
+	Running   bool   `json:"running"`
+	Status    string `json:"status"`
+}
+
+// processIds are the processes the API may start and stop, the counter processes and the
+// sync manager. Other batch files in the services folder are not reachable.
+func (s *Server) processIds() []string {
+	ids := s.services.Processes.CounterProcessIds()
+	return append(ids, processmanager.SyncManagerProcess)
+}
+
+func (s *Server) getProcesses(c *fiber.Ctx) error {
+	ids := s.processIds()
+	statuses := make([]processStatus, 0, len(ids))
+	for _, id := range ids {
+		statuses = append(statuses, processStatus{
+			ProcessId: id,
+			Running:   s.services.Processes.IsProcessRunning(id),
+			Status:    s.services.Processes.GetDetailedProcessStatus(id),
+		})
+	}
+	return c.JSON(statuses)
+}
+
+// requireProcessControl refuses process control with 423 when the guard does. StopProcess
+// and RestartProcess only report a bool, so the refusal is checked before calling them.
+func (s *Server) requireProcessControl() error {
+	if s.services.ProcessGuard == nil {
+		return nil
+	}
+	if err := s.services.ProcessGuard.RequireUnlocked(); err != nil {
+		return serviceError(err, fiber.StatusForbidden, "process control refused")
+	}
+	return nil
+}
+
+// processParam reads a known process id from the path
+func (s *Server) processParam(c *fiber.Ctx) (string, error) {
+	id := c.Params("id")
+	if !slices.Contains(s.processIds(), id) {
+		return "", fiber.NewError(fiber.StatusNotFound, "unknown process "+id)
+	}
+	return id, nil
+}
+
+func (s *Server) startProcess(c *fiber.Ctx) error {
+	id, err := s.processParam(c)
+	if err != nil {
+		return err
+	}
+	if err := s.requireProcessControl(); err != nil {
+		return err
+	}
+	if err := s.services.Processes.RunBatFile(id); err != nil {
+		return serviceError(err, fiber.StatusInternalServerError, "failed to start "+id)
+	}
+	return c.JSON(fiber.Map{"started": id})
+}
+
+func (s *Server) stopProcess(c *fiber.Ctx) error {
+	id, err := s.processParam(c)
+	if err != nil {
+		return err
+	}
+	if err := s.requireProcessControl(); err != nil {
+		return err
+	}
+	if !s.services.Processes.StopProcess(id) {
+		return fiber.NewError(fiber.StatusConflict, id+" is not running")
+	}
+	return c.JSON(fiber.Map{"stopped": id})
+}
+
+func (s *Server) restartProcess(c *fiber.Ctx) error {
+	id, err := s.processParam(c)
+	if err != nil {
+		return err
+	}
+	if err := s.requireProcessControl(); err != nil {
+		return err
+	}
+	if !s.services.Processes.RestartProcess(id) {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to restart "+id)
+	}
+	return c.JSON(fiber.Map{"restarted": id})
+}
+
+func (s *Server) getInstances(c *fiber.Ctx) error {
+	return c.JSON(s.services.Processes.GetCounterInstanceStatuses())
+}
+
+// saveInstance creates or replaces a counter instance
+func (s *Server) saveInstance(c *fiber.Ctx) error {
+	if err := s.requireProcessControl(); err != nil {
+		return err
+	}
+	var instance processmanager.CounterInstance
+	if err := c.BodyParser(&instance); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	if err := s.services.Processes.SaveCounterInstance(instance); err != nil {
+		return serviceError(err, fiber.StatusBadRequest, "failed to save instance")
+	}
+	return c.JSON(instance)
+}
+
+func (s *Server) deleteInstance(c *fiber.Ctx) error {
+	if err := s.requireProcessControl(); err != nil {
+		return err
+	}
+	name := c.Params("name")
+	if err := s.services.Processes.DeleteCounterInstance(name); err != nil {
+		return serviceError(err, fiber.StatusBadRequest, "failed to delete instance")
+	}
+	return c.JSON(fiber.Map{"deleted": name})
+}
+
+func (s *Server) startInstance(c *fiber.Ctx) error {
+	if err := s.requireProcessControl(); err != nil {
+		return err
+	}
+	name := c.Params("name")
+	if err := s.services.Processes.StartCounterInstance(name); err != nil {
+		return serviceError(err, fiber.StatusBadRequest, "failed to start instance")
+	}
+	return c.JSON(fiber.Map{"started": name})
+}
+
+func (s *Server) stopInstance(c *fiber.Ctx) error {
+	if err := s.requireProcessControl(); err != nil {
+		return err
+	}
+	name := c.Params("name")
+	if err := s.services.Processes.StopCounterInstance(name); err != nil {
+		return serviceError(err, fiber.StatusConflict, "failed to stop instance")
+	}
+	return c.JSON(fiber.Map{"stopped": name})
+}
@@ -0,0 +1,198 @@
+// Package api exposes the desktop services over a local REST API so scripts and external
+// tooling can automate the desktop app without driving the UI. The API listens on localhost
+// only and every request except /api/health needs the API token.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/config"
+	"jarvist/internal/wails/services/auth"
+	"jarvist/internal/wails/services/camera"
+	"jarvist/internal/wails/services/kiosk"
+	licenseservice "jarvist/internal/wails/services/license"
+	"jarvist/internal/wails/services/processmanager"
+	"jarvist/internal/wails/services/setting"
+	"jarvist/pkg/logger"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+	"gorm.io/gorm"
+)
+
+// HeaderToken carries the API token, a Bearer authorization header works as well
+const HeaderToken = "X-API-Token"
+
+// Services are the desktop services mounted by the API
+type Services struct {
+	Camera    *camera.CameraService
+	Settings  *setting.SettingsService
+	License   *licenseservice.LicenseService
+	Processes *processmanager.ProcessManagerService
+	// Guard refuses license changes while the app is locked, nil allows them
+	Guard auth.Guard
+	// ProcessGuard refuses process and instance control in kiosk mode or while the app is
+	// locked, nil allows it
+	ProcessGuard auth.Guard
+}
+
+// Server is the local REST API of the desktop app
+type Server struct {
+	app      *fiber.App
+	cfg      *config.Config
+	logger   *logger.ContextLogger
+	services Services
+	token    string
+}
+
+// New creates the API server, Start makes it listen
+func New(cfg *config.Config, logger *logger.ContextLogger, services Services) *Server {
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+		ErrorHandler: func(c *fiber.Ctx, err error) error {
+			code := fiber.StatusInternalServerError
+
+			if e, ok := err.(*fiber.Error); ok {
+				code = e.Code
+			}
+
+			c.Set("Content-Type", "application/json")
+			return c.Status(code).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		},
+	})
+
+	s := &Server{
+		app:      app,
+		cfg:      cfg,
+		logger:   logger,
+		services: services,
+	}
+
+	app.Use(recover.New())
+	s.setupRoutes()
+	return s
+}
+
+func (s *Server) setupRoutes() {
+	api := s.app.Group("/api")
+	api.Get("/health", s.getHealth)
+	api.Use(s.authMiddleware)
+
+	cameras := api.Group("/cameras")
+	cameras.Get("/", s.getCameras)
+	cameras.Post("/", s.createCamera)
+	cameras.Get("/export", s.exportCameras)
+	cameras.Post("/import", s.importCameras)
+	cameras.Get("/:id", s.getCamera)
+	cameras.Put("/:id", s.updateCamera)
+	cameras.Delete("/:id", s.deleteCamera)
+	cameras.Post("/:id/check", s.checkCamera)
+
+	settings := api.Group("/settings")
+	settings.Get("/", s.getSettings)
+	settings.Put("/", s.saveSettings)
+	settings.Get("/:key", s.getSetting)
+	settings.Put("/:key", s.saveSetting)
+	settings.Delete("/:key", s.deleteSetting)
+
+	license := api.Group("/license")
+	license.Get("/", s.getLicense)
+	license.Post("/", s.registerLicense)
+	license.Delete("/", s.deactivateLicense)
+	license.Post("/activation-request", s.createActivationRequest)
+	license.Post("/activation", s.importActivation)
+
+	processes := api.Group("/processes")
+	processes.Get("/", s.getProcesses)
+	processes.Post("/:id/start", s.startProcess)
+	processes.Post("/:id/stop", s.stopProcess)
+	processes.Post("/:id/restart", s.restartProcess)
+
+	instances := api.Group("/instances")
+	instances.Get("/", s.getInstances)
+	instances.Put("/", s.saveInstance)
+	instances.Delete("/:name", s.deleteInstance)
+	instances.Post("/:name/start", s.startInstance)
+	instances.Post("/:name/stop", s.stopInstance)
+}
+
+// Start listens on localhost at the configured port. The token is read or generated first
+// and written to the token file for local scripts.
+func (s *Server) Start() error {
+	token, err := loadToken(s.cfg)
+	if err != nil {
+		return fmt.Errorf("failed to prepare API token: %w", err)
+	}
+	s.token = token
+
+	address := net.JoinHostPort("127.0.0.1", strconv.Itoa(s.cfg.DesktopApiPort))
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on desktop API port %d: %w", s.cfg.DesktopApiPort, err)
+	}
+
+	go func() {
+		if err := s.app.Listener(listener); err != nil {
+			s.logger.Error("Desktop API stopped unexpectedly: %v", err)
+		}
+	}()
+
+	s.logger.Info("Desktop API listening on http://%s/api, token in %s", address, TokenFile(s.cfg))
+	return nil
+}
+
+// Stop shuts the API down, waiting a moment for running requests
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return s.app.ShutdownWithContext(ctx)
+}
+
+// authMiddleware checks the API token of a request
+func (s *Server) authMiddleware(c *fiber.Ctx) error {
+	token := c.Get(HeaderToken)
+	if token == "" {
+		token, _ = strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	}
+	if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+		return fiber.NewError(fiber.StatusUnauthorized, "missing or invalid API token")
+	}
+	return c.Next()
+}
+
+func (s *Server) getHealth(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"status":  "ok",
+		"version": s.cfg.AppVersion,
+	})
+}
+
+// serviceError maps the error of a service call to a response. A locked app answers 423,
+// like the UI asking for the admin PIN first.
+func serviceError(err error, status int, action string) error {
+	switch {
+	case errors.Is(err, auth.ErrLocked), errors.Is(err, kiosk.ErrKioskMode):
+		return fiber.NewError(fiber.StatusLocked, err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return fiber.NewError(fiber.StatusNotFound, action+": not found")
+	default:
+		return fiber.NewError(status, action+": "+err.Error())
+	}
+}
+
+// paramID reads a numeric id from the path
+func paramID(c *fiber.Ctx) (uint, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil || id == 0 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "invalid id")
+	}
+	return uint(id), nil
+}
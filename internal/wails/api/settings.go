@@ -0,0 +1,101 @@
+package api
+
+import (
+	"jarvist/internal/common/residency"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// protectedSettingPrefix marks the settings of the admin PIN. They are neither shown nor
+// changed over the API, the PIN is only managed from the UI.
+const protectedSettingPrefix = "auth_"
+
+func isProtectedSetting(key string) bool {
+	return strings.HasPrefix(key, protectedSettingPrefix)
+}
+
+// isReadOnlySetting reports settings that are shown but never changed over the API. The data
+// residency mode only switches with the admin PIN and an audit entry, through the UI.
+func isReadOnlySetting(key string) bool {
+	return isProtectedSetting(key) || key == residency.Key
+}
+
+func (s *Server) getSettings(c *fiber.Ctx) error {
+	settings, err := s.services.Settings.GetAllSettings()
+	if err != nil {
+		return serviceError(err, fiber.StatusInternalServerError, "failed to get settings")
+	}
+	for key := range settings {
+		if isProtectedSetting(key) {
+			delete(settings, key)
+		}
+	}
+	return c.JSON(settings)
+}
+
+func (s *Server) getSetting(c *fiber.Ctx) error {
+	key := c.Params("key")
+	if isProtectedSetting(key) {
+		return fiber.NewError(fiber.StatusForbidden, "setting "+key+" is not available over the API")
+	}
+	value, err := s.services.Settings.GetSetting(key)
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	return c.JSON(fiber.Map{"key": key, "value": value})
+}
+
+// saveSettings saves several settings at once, a JSON object of strings
+func (s *Server) saveSettings(c *fiber.Ctx) error {
+	var settings map[string]string
+	if err := c.BodyParser(&settings); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	if len(settings) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "no settings provided")
+	}
+	for key := range settings {
+		if key == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "setting key is required")
+		}
+		if isReadOnlySetting(key) {
+			return fiber.NewError(fiber.StatusForbidden, "setting "+key+" cannot be changed over the API")
+		}
+	}
+	if err := s.services.Settings.SaveSettings(settings); err != nil {
+		return serviceError(err, fiber.StatusInternalServerError, "failed to save settings")
+	}
+	return c.JSON(fiber.Map{"saved": len(settings)})
+}
+
+func (s *Server) saveSetting(c *fiber.Ctx) error {
+	key := c.Params("key")
+	if isReadOnlySetting(key) {
+		return fiber.NewError(fiber.StatusForbidden, "setting "+key+" cannot be changed over the API")
+	}
+	var req struct {
+		Value *string `json:"value"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	if req.Value == nil {
+		return fiber.NewError(fiber.StatusBadRequest, "value is required")
+	}
+	if err := s.services.Settings.SaveSetting(key, *req.Value); err != nil {
+		return serviceError(err, fiber.StatusInternalServerError, "failed to save setting")
+	}
+	return c.JSON(fiber.Map{"key": key, "value": *req.Value})
+}
+
+func (s *Server) deleteSetting(c *fiber.Ctx) error {
+	key := c.Params("key")
+	if isReadOnlySetting(key) {
+		return fiber.NewError(fiber.StatusForbidden, "setting "+key+" cannot be changed over the API")
+	}
+	if err := s.services.Settings.DeleteSetting(key); err != nil {
+		return serviceError(err, fiber.StatusInternalServerError, "failed to delete setting")
+	}
+	return c.JSON(fiber.Map{"deleted": key})
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"jarvist/internal/common/config"
+	"jarvist/pkg/utils"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tokenFileName is kept in the data folder, readable by the user running the app
+const tokenFileName = "desktop_api_token"
+
+// TokenFile returns the file local scripts read the API token from
+func TokenFile(cfg *config.Config) string {
+	return filepath.Join(cfg.DataDir, tokenFileName)
+}
+
+// loadToken returns the configured token, else the token of the token file, generating it
+// on first use so it stays the same across restarts
+func loadToken(cfg *config.Config) (string, error) {
+	if cfg.DesktopApiToken != "" {
+		return cfg.DesktopApiToken, nil
+	}
+
+	data, err := os.ReadFile(TokenFile(cfg))
+	if err == nil {
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(random)
+
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		return "", err
+	}
+	if err := utils.WriteFileAtomic(TokenFile(cfg), []byte(token), 0600); err != nil {
+		return "", err
+	}
+	return token, nil
+}
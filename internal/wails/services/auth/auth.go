@@ -0,0 +1,339 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"jarvist/pkg/logger"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	pinHashKey     = "auth_pin_hash"
+	idleTimeoutKey = "auth_idle_timeout"
+
+	defaultIdleTimeout = 5 * time.Minute
+	minPINLength       = 4
+	maxFailedAttempts  = 5
+	failedLockout      = 30 * time.Second
+)
+
+var (
+	// ErrLocked is returned by guarded methods while the app is locked
+	ErrLocked = errors.New("application is locked, unlock with admin PIN first")
+	// ErrInvalidPIN is returned when the supplied PIN does not match
+	ErrInvalidPIN = errors.New("invalid PIN")
+	// ErrNoPIN is returned by actions that need the PIN entered while none is configured
+	ErrNoPIN = errors.New("set an admin PIN first")
+)
+
+// Guard is implemented by AuthService and checked by sensitive bound methods
+type Guard interface {
+	RequireUnlocked() error
+}
+
+// Verifier is implemented by AuthService for actions that need the PIN entered again,
+// even while the app is unlocked
+type Verifier interface {
+	VerifyPIN(pin string) error
+}
+
+type AuthService struct {
+	db     *gorm.DB
+	logger *logger.ContextLogger
+	app    *application.App
+
+	mu             sync.Mutex
+	unlocked       bool
+	lastActivity   time.Time
+	idleTimeout    time.Duration
+	failedAttempts int
+	blockedUntil   time.Time
+	stopChan       chan struct{}
+}
+
+func New(db *gorm.DB, logger *logger.ContextLogger) *AuthService {
+	return &AuthService{
+		db:          db,
+		logger:      logger.WithComponent("auth"),
+		idleTimeout: defaultIdleTimeout,
+	}
+}
+
+func (s *AuthService) OnStartup(ctx context.Context, options application.ServiceOptions) error {
+	if value, err := s.getSetting(idleTimeoutKey); err == nil {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			s.idleTimeout = time.Duration(seconds) * time.Second
+		}
+	}
+
+	s.stopChan = make(chan struct{})
+	go s.idleWatcher(s.stopChan)
+	return nil
+}
+
+func (s *AuthService) OnShutdown() error {
+	if s.stopChan != nil {
+		close(s.stopChan)
+		s.stopChan = nil
+	}
+	return nil
+}
+
+func (s *AuthService) InitService(app *application.App) {
+	s.app = app
+}
+
+// HasPIN returns true if an admin PIN has been configured
+func (s *AuthService) HasPIN() bool {
+	_, err := s.getSetting(pinHashKey)
+	return err == nil
+}
+
+// IsLocked returns true if sensitive actions are currently blocked
+func (s *AuthService) IsLocked() bool {
+	if !s.HasPIN() {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.unlocked
+}
+
+// RequireUnlocked returns ErrLocked when a PIN is set and the app is locked
+func (s *AuthService) RequireUnlocked() error {
+	if s.IsLocked() {
+		return ErrLocked
+	}
+
+	s.Touch()
+	return nil
+}
+
+// Unlock verifies the PIN and unlocks sensitive actions until idle timeout
+func (s *AuthService) Unlock(pin string) error {
+	hash, err := s.getSetting(pinHashKey)
+	if err != nil {
+		return nil
+	}
+
+	if err := s.checkPIN(hash, pin); err != nil {
+		s.logger.Warn("Failed unlock attempt")
+		return err
+	}
+
+	s.mu.Lock()
+	s.unlocked = true
+	s.lastActivity = time.Now()
+	s.mu.Unlock()
+
+	s.logger.Info("Application unlocked")
+	s.emit("auth:unlocked")
+	return nil
+}
+
+// VerifyPIN checks the PIN without changing the lock state. Unlike Unlock it fails when no
+// PIN is configured, failed attempts count towards the same lockout.
+func (s *AuthService) VerifyPIN(pin string) error {
+	hash, err := s.getSetting(pinHashKey)
+	if err != nil {
+		return ErrNoPIN
+	}
+
+	if err := s.checkPIN(hash, pin); err != nil {
+		s.logger.Warn("Failed PIN verification")
+		return err
+	}
+
+	s.Touch()
+	return nil
+}
+
+// checkPIN compares the PIN with the stored hash, blocking attempts for a while after
+// too many failures
+func (s *AuthService) checkPIN(hash, pin string) error {
+	s.mu.Lock()
+	if time.Now().Before(s.blockedUntil) {
+		remaining := time.Until(s.blockedUntil).Round(time.Second)
+		s.mu.Unlock()
+		return fmt.Errorf("too many failed attempts, try again in %s", remaining)
+	}
+	s.mu.Unlock()
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(pin)); err != nil {
+		s.mu.Lock()
+		s.failedAttempts++
+		if s.failedAttempts >= maxFailedAttempts {
+			s.blockedUntil = time.Now().Add(failedLockout)
+			s.failedAttempts = 0
+		}
+		s.mu.Unlock()
+		return ErrInvalidPIN
+	}
+
+	s.mu.Lock()
+	s.failedAttempts = 0
+	s.mu.Unlock()
+	return nil
+}
+
+// Lock blocks sensitive actions until the PIN is entered again
+func (s *AuthService) Lock() {
+	s.mu.Lock()
+	wasUnlocked := s.unlocked
+	s.unlocked = false
+	s.mu.Unlock()
+
+	if wasUnlocked {
+		s.logger.Info("Application locked")
+	}
+	s.emit("auth:locked")
+}
+
+// Touch resets the idle auto-lock timer; the UI calls this on user activity
+func (s *AuthService) Touch() {
+	s.mu.Lock()
+	s.lastActivity = time.Now()
+	s.mu.Unlock()
+}
+
+// SetPIN sets or changes the admin PIN. currentPIN is required when a PIN already exists.
+func (s *AuthService) SetPIN(currentPIN, newPIN string) error {
+	if len(newPIN) < minPINLength {
+		return fmt.Errorf("PIN must be at least %d characters", minPINLength)
+	}
+
+	if hash, err := s.getSetting(pinHashKey); err == nil {
+		if err := s.checkPIN(hash, currentPIN); err != nil {
+			s.logger.Warn("Failed PIN change attempt")
+			return err
+		}
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPIN), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash PIN: %w", err)
+	}
+
+	if err := s.saveSetting(pinHashKey, string(hash)); err != nil {
+		return fmt.Errorf("failed to save PIN: %w", err)
+	}
+
+	s.mu.Lock()
+	s.unlocked = true
+	s.lastActivity = time.Now()
+	s.mu.Unlock()
+
+	s.logger.Info("Admin PIN updated")
+	return nil
+}
+
+// RemovePIN disables the lock entirely after verifying the current PIN
+func (s *AuthService) RemovePIN(currentPIN string) error {
+	hash, err := s.getSetting(pinHashKey)
+	if err != nil {
+		return nil
+	}
+
+	if err := s.checkPIN(hash, currentPIN); err != nil {
+		s.logger.Warn("Failed PIN removal attempt")
+		return err
+	}
+
+	if err := s.db.Where("key = ?", pinHashKey).Delete(&models.Setting{}).Error; err != nil {
+		return fmt.Errorf("failed to remove PIN: %w", err)
+	}
+
+	s.logger.Info("Admin PIN removed")
+	s.emit("auth:unlocked")
+	return nil
+}
+
+// SetIdleTimeout changes the auto-lock timeout in seconds
+func (s *AuthService) SetIdleTimeout(seconds int) error {
+	if err := s.RequireUnlocked(); err != nil {
+		return err
+	}
+	if seconds < 30 {
+		return fmt.Errorf("idle timeout must be at least 30 seconds")
+	}
+
+	if err := s.saveSetting(idleTimeoutKey, strconv.Itoa(seconds)); err != nil {
+		return fmt.Errorf("failed to save idle timeout: %w", err)
+	}
+
+	s.mu.Lock()
+	s.idleTimeout = time.Duration(seconds) * time.Second
+	s.mu.Unlock()
+	return nil
+}
+
+// GetLockStatus returns the current lock state for the UI
+func (s *AuthService) GetLockStatus() map[string]interface{} {
+	s.mu.Lock()
+	idleTimeout := s.idleTimeout
+	s.mu.Unlock()
+
+	return map[string]interface{}{
+		"has_pin":      s.HasPIN(),
+		"locked":       s.IsLocked(),
+		"idle_timeout": int(idleTimeout.Seconds()),
+	}
+}
+
+func (s *AuthService) idleWatcher(stopChan chan struct{}) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			expired := s.unlocked && time.Since(s.lastActivity) > s.idleTimeout
+			s.mu.Unlock()
+
+			if expired && s.HasPIN() {
+				s.logger.Info("Auto-locking after idle timeout")
+				s.Lock()
+			}
+		case <-stopChan:
+			return
+		}
+	}
+}
+
+func (s *AuthService) emit(event string) {
+	if s.app != nil {
+		s.app.EmitEvent(event, s.GetLockStatus())
+	}
+}
+
+func (s *AuthService) getSetting(key string) (string, error) {
+	var setting models.Setting
+	if err := s.db.Where("key = ?", key).First(&setting).Error; err != nil {
+		return "", err
+	}
+	return setting.Value, nil
+}
+
+func (s *AuthService) saveSetting(key, value string) error {
+	var setting models.Setting
+	result := s.db.Where("key = ?", key).First(&setting)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return s.db.Create(&models.Setting{Key: key, Value: value}).Error
+	} else if result.Error != nil {
+		return result.Error
+	}
+
+	setting.Value = value
+	return s.db.Save(&setting).Error
+}
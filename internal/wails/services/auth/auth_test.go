@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"errors"
+	"jarvist/internal/common/models"
+	"jarvist/internal/testutil"
+	"jarvist/pkg/logger"
+	"testing"
+)
+
+func TestSetPINLocksOutAfterFailedAttempts(t *testing.T) {
+	s := New(testutil.OpenDB(t, &models.Setting{}), logger.NewLogger().WithComponent("test"))
+	if err := s.SetPIN("", "1234"); err != nil {
+		t.Fatalf("set PIN: %v", err)
+	}
+
+	for i := 0; i < maxFailedAttempts; i++ {
+		if err := s.SetPIN("0000", "5678"); !errors.Is(err, ErrInvalidPIN) {
+			t.Fatalf("attempt %d: got %v, want %v", i+1, err, ErrInvalidPIN)
+		}
+	}
+
+	// The correct PIN is refused as well until the lockout has passed
+	if err := s.SetPIN("1234", "5678"); err == nil || errors.Is(err, ErrInvalidPIN) {
+		t.Fatalf("set PIN during lockout: got %v, want lockout error", err)
+	}
+	if err := s.RemovePIN("1234"); err == nil || errors.Is(err, ErrInvalidPIN) {
+		t.Fatalf("remove PIN during lockout: got %v, want lockout error", err)
+	}
+	if !s.HasPIN() {
+		t.Error("PIN removed during lockout")
+	}
+}
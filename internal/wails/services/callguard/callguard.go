@@ -0,0 +1,141 @@
+// Package callguard runs long bound methods with a timeout, panic recovery and slow call
+// logging, so a hanging probe cannot keep a frontend promise waiting forever.
+package callguard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/wails/services/usage"
+	"jarvist/pkg/logger"
+	"runtime/debug"
+	"time"
+)
+
+// Error codes returned to the frontend
+const (
+	CodeTimeout   = "timeout"
+	CodeCancelled = "cancelled"
+	CodePanic     = "panic"
+)
+
+// DefaultSlowThreshold is how long a call may take before it is logged as slow
+const DefaultSlowThreshold = 5 * time.Second
+
+// CallError is the structured error of a guarded call. Wails passes only the error text
+// to the frontend, so Error returns the JSON encoding.
+type CallError struct {
+	Method  string `json:"method"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *CallError) Error() string {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Sprintf("%s: %s", e.Method, e.Message)
+	}
+	return string(data)
+}
+
+// Guard runs calls of one service
+type Guard struct {
+	logger        *logger.ContextLogger
+	slowThreshold time.Duration
+}
+
+// New creates a guard logging to logger
+func New(logger *logger.ContextLogger) *Guard {
+	return &Guard{logger: logger, slowThreshold: DefaultSlowThreshold}
+}
+
+// WithSlowThreshold returns a copy of the guard logging calls slower than threshold, zero
+// disables slow call logging for calls like downloads that are expected to take long
+func (g *Guard) WithSlowThreshold(threshold time.Duration) *Guard {
+	clone := *g
+	clone.slowThreshold = threshold
+	return &clone
+}
+
+// Call runs fn with a context that is cancelled after timeout. A panic in fn or a call that
+// does not return in time ends with a CallError, fn keeps running in the background until
+// it notices the cancelled context.
+func Call[T any](g *Guard, ctx context.Context, method string, timeout time.Duration, fn func(ctx context.Context) (T, error)) (T, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type outcome struct {
+		value T
+		err   error
+	}
+	done := make(chan outcome, 1)
+	started := time.Now()
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				g.logError("Panic in %s: %v\n%s", method, r, debug.Stack())
+				usage.Crash()
+				done <- outcome{err: &CallError{Method: method, Code: CodePanic, Message: fmt.Sprint(r)}}
+			}
+		}()
+
+		value, err := fn(ctx)
+		done <- outcome{value: value, err: err}
+	}()
+
+	var result outcome
+	select {
+	case result = <-done:
+		// Error dari context yang habis dilaporkan dengan kode yang sama seperti timeout
+		if result.err != nil && ctx.Err() != nil && errors.Is(result.err, ctx.Err()) {
+			result.err = g.contextError(ctx, method, timeout)
+		}
+	case <-ctx.Done():
+		result.err = g.contextError(ctx, method, timeout)
+	}
+
+	if threshold := g.threshold(); threshold > 0 && time.Since(started) > threshold {
+		g.logWarn("Slow call %s took %s", method, time.Since(started).Round(time.Millisecond))
+	}
+	return result.value, result.err
+}
+
+// Do is Call for methods that only return an error
+func Do(g *Guard, ctx context.Context, method string, timeout time.Duration, fn func(ctx context.Context) error) error {
+	_, err := Call(g, ctx, method, timeout, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+func (g *Guard) contextError(ctx context.Context, method string, timeout time.Duration) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		g.logWarn("Call %s timed out after %s", method, timeout)
+		return &CallError{Method: method, Code: CodeTimeout, Message: fmt.Sprintf("%s timed out after %s", method, timeout)}
+	}
+	return &CallError{Method: method, Code: CodeCancelled, Message: method + " was cancelled"}
+}
+
+func (g *Guard) threshold() time.Duration {
+	if g == nil {
+		return DefaultSlowThreshold
+	}
+	return g.slowThreshold
+}
+
+func (g *Guard) logWarn(message string, args ...interface{}) {
+	if g != nil && g.logger != nil {
+		g.logger.Warn(message, args...)
+	}
+}
+
+func (g *Guard) logError(message string, args ...interface{}) {
+	if g != nil && g.logger != nil {
+		g.logger.Error(message, args...)
+	}
+}
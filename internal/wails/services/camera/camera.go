@@ -1,19 +1,22 @@
 package camera
 
 import (
-	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"jarvist/internal/common/bandwidth"
 	"jarvist/internal/common/config"
 	"jarvist/internal/common/ffmpeg"
 	"jarvist/internal/common/models"
+	"jarvist/internal/wails/services/auth"
+	"jarvist/internal/wails/services/callguard"
+	"jarvist/internal/wails/services/deps"
 	"jarvist/internal/wails/services/processmanager"
 	"jarvist/internal/wails/services/setting"
+	"jarvist/internal/wails/services/usage"
 	"jarvist/pkg/logger"
-	"net/http"
 	"os"
 	"path/filepath"
 	"slices"
@@ -40,12 +43,25 @@ type CameraService struct {
 	config             *config.Config
 	process            *processmanager.ProcessManagerService
 	logger             *logger.ContextLogger
+	guard              auth.Guard
+	exportMutex        sync.Mutex
+	syncMutex          sync.Mutex
+	calls              *callguard.Guard
+	events             deps.Emitter
+	http               deps.HTTPClient
 }
 
+// exportTimeout limits ExportCameraConfig, probe timeouts follow the probe options
+const exportTimeout = 30 * time.Second
+
 type SyncResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
 	Error   string `json:"error"`
+
+	// Set by the server on a differential sync
+	FullSyncRequired bool   `json:"full_sync_required,omitempty"`
+	Checksum         string `json:"checksum,omitempty"`
 }
 
 type CameraConnectionStatus struct {
@@ -57,6 +73,31 @@ type CameraConnectionStatus struct {
 	Error         string    `json:"error,omitempty"`
 }
 
+// CameraWithStatus is a camera with the result of its last connection check. The
+// connection fields are empty until the camera has been checked.
+type CameraWithStatus struct {
+	ID            uint            `json:"ID"`
+	UUID          string          `json:"UUID"`
+	Name          string          `json:"Name"`
+	Location      models.Location `json:"Location"`
+	Schema        string          `json:"Schema"`
+	Host          string          `json:"Host"`
+	Port          int             `json:"Port"`
+	Path          string          `json:"Path"`
+	Username      string          `json:"Username"`
+	Direction     string          `json:"Direction"`
+	Status        string          `json:"Status"`
+	CreatedAt     string          `json:"CreatedAt"`
+	IsConnected   *bool           `json:"is_connected,omitempty"`
+	LastChecked   string          `json:"last_checked,omitempty"`
+	StatusMessage string          `json:"status_message,omitempty"`
+}
+
+// CameraPayload is the JSON stored in the payload column of a camera
+type CameraPayload struct {
+	Lines []models.LineData `json:"lines"`
+}
+
 type CameraSync struct {
 	ID          uint   `json:"id"`
 	UUID        string `json:"uuid"`
@@ -74,9 +115,17 @@ type CameraSync struct {
 	Status      string `json:"status,omitempty"`
 	Payload     string `json:"payload,omitempty"`
 	CreatedAt   string `json:"created_at"`
+
+	FloorplanX *float64 `json:"floorplan_x,omitempty"`
+	FloorplanY *float64 `json:"floorplan_y,omitempty"`
 }
 
-func New(db *gorm.DB, settingService *setting.SettingsService, cfg *config.Config, logger *logger.ContextLogger, process *processmanager.ProcessManagerService) *CameraService {
+func New(db *gorm.DB, settingService *setting.SettingsService, cfg *config.Config, logger *logger.ContextLogger, process *processmanager.ProcessManagerService, opts ...deps.Option) *CameraService {
+	d := deps.Apply(opts)
+	if d.HTTP == nil {
+		d.HTTP = deps.NewHTTPClient(bandwidth.DestinationAPI)
+	}
+
 	return &CameraService{
 		DB:                 db,
 		connectionStatuses: make(map[string]CameraConnectionStatus),
@@ -87,14 +136,39 @@ func New(db *gorm.DB, settingService *setting.SettingsService, cfg *config.Confi
 		config:             cfg,
 		process:            process,
 		logger:             logger,
+		calls:              callguard.New(logger),
+		events:             d.Events,
+		http:               d.HTTP,
 	}
 }
 
 func (s *CameraService) InitService(app *application.App) {
 	s.app = app
+	if s.events == nil {
+		s.events = deps.AppEmitter{App: app}
+	}
 	s.backgroundCtx, s.backgroundCancelFn = context.WithCancel(context.Background())
 }
 
+// emit sends a frontend event, events before InitService are dropped
+func (s *CameraService) emit(name string, data any) {
+	if s.events != nil {
+		s.events.Emit(name, data)
+	}
+}
+
+// SetGuard sets the lock guard checked before camera changes
+func (s *CameraService) SetGuard(guard auth.Guard) {
+	s.guard = guard
+}
+
+func (s *CameraService) requireUnlocked() error {
+	if s.guard == nil {
+		return nil
+	}
+	return s.guard.RequireUnlocked()
+}
+
 func (s *CameraService) StartBackgroundChecking() {
 	if s.backgroundRunning {
 		return
@@ -102,6 +176,8 @@ func (s *CameraService) StartBackgroundChecking() {
 
 	s.backgroundRunning = true
 	go s.runBackgroundChecker()
+	go s.runSyncRetry()
+	go s.runHealthReporter()
 }
 
 func (s *CameraService) StopBackgroundChecking() {
@@ -137,7 +213,7 @@ func (s *CameraService) SetCheckInterval(interval time.Duration) {
 func (s *CameraService) runBackgroundChecker() {
 	s.logger.Info("Starting background camera connection checker")
 
-	s.checkAllCameraConnections()
+	s.checkAllCameraConnections(s.backgroundCtx)
 
 	ticker := time.NewTicker(s.checkInterval)
 	defer ticker.Stop()
@@ -145,7 +221,7 @@ func (s *CameraService) runBackgroundChecker() {
 	for {
 		select {
 		case <-ticker.C:
-			s.checkAllCameraConnections()
+			s.checkAllCameraConnections(s.backgroundCtx)
 		case <-s.backgroundCtx.Done():
 			s.logger.Info("Background camera connection checker stopped")
 			return
@@ -153,7 +229,8 @@ func (s *CameraService) runBackgroundChecker() {
 	}
 }
 
-func (s *CameraService) checkAllCameraConnections() {
+// checkAllCameraConnections checks every camera, cancelling ctx stops the running probes
+func (s *CameraService) checkAllCameraConnections(ctx context.Context) {
 	s.logger.Info("Running camera connection check for all cameras")
 
 	s.CleanupOldConnectionStatuses()
@@ -168,24 +245,33 @@ func (s *CameraService) checkAllCameraConnections() {
 
 	semaphore := make(chan struct{}, s.concurrencyLimit)
 
+cameraLoop:
 	for _, camera := range cameras {
-		wg.Add(1)
-
-		semaphore <- struct{}{}
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			break cameraLoop
+		}
 
+		wg.Add(1)
 		go func(cam models.Camera) {
 			defer wg.Done()
 			defer func() { <-semaphore }()
 
-			s.checkCameraConnection(&cam)
+			s.checkCameraConnection(ctx, &cam)
 		}(camera)
 	}
 
 	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
 	s.broadcastStatusUpdate()
 }
 
-func (s *CameraService) checkCameraConnection(camera *models.Camera) {
+// checkCameraConnection probes the RTSP stream of a camera and stores the result. A
+// cancelled check stores nothing and returns the context error.
+func (s *CameraService) checkCameraConnection(ctx context.Context, camera *models.Camera) error {
 	s.logger.Info("Checking connection for camera %s (ID: %d)", camera.Name, camera.ID)
 
 	rtspConfig := ffmpeg.RTSPConfig{
@@ -197,16 +283,18 @@ func (s *CameraService) checkCameraConnection(camera *models.Camera) {
 		Password: camera.Password,
 	}
 
-	options := ffmpeg.RTSPOptions{
-		TakeScreenshot: false,
-	}
+	options := s.probeOptions(camera)
 
-	responseStr := ffmpeg.CheckRTSPConnectionWithConfig(rtspConfig, options)
+	responseStr := ffmpeg.CheckRTSPConnectionWithConfigContext(ctx, rtspConfig, options)
+	if err := ctx.Err(); err != nil {
+		s.logger.Info("Connection check for camera %s cancelled", camera.Name)
+		return err
+	}
 
 	var response ffmpeg.ResponseJSON
 	if err := json.Unmarshal([]byte(responseStr), &response); err != nil {
 		s.logger.Error("Error parsing RTSP check response: %v", err)
-		return
+		return err
 	}
 
 	status := CameraConnectionStatus{
@@ -222,6 +310,7 @@ func (s *CameraService) checkCameraConnection(camera *models.Camera) {
 	}
 
 	s.statusMutex.Lock()
+	previous, checked := s.connectionStatuses[camera.UUID]
 	s.connectionStatuses[camera.UUID] = status
 	s.statusMutex.Unlock()
 
@@ -231,15 +320,26 @@ func (s *CameraService) checkCameraConnection(camera *models.Camera) {
 	}
 
 	if camera.Status != newStatus {
+		wasOnline := camera.Status == "online"
 		camera.Status = newStatus
 		if err := s.DB.Save(camera).Error; err != nil {
 			s.logger.Error("Error updating camera status: %v", err)
 		}
+
+		// Pemulihan hanya dilaporkan jika kamera sempat terdeteksi offline di sesi ini
+		if wasOnline || (checked && !previous.IsConnected) {
+			s.reportStatusChange(camera, status, previous)
+		}
+	}
+
+	if response.Success {
+		s.refreshSnapshot(ctx, camera, rtspConfig, options)
 	}
+	return nil
 }
 
 func (s *CameraService) broadcastStatusUpdate() {
-	if s.app == nil {
+	if s.events == nil {
 		return
 	}
 
@@ -251,8 +351,7 @@ func (s *CameraService) broadcastStatusUpdate() {
 		statusesCopy[k] = v
 	}
 
-	// Menggunakan EmitEvent dari app instance untuk Wails v3
-	s.app.EmitEvent("camera:status-update", statusesCopy)
+	s.emit("camera:status-update", statusesCopy)
 }
 
 func (s *CameraService) GetConnectionStatus(cameraUUID string) (CameraConnectionStatus, bool) {
@@ -275,61 +374,76 @@ func (s *CameraService) GetAllConnectionStatuses() map[string]CameraConnectionSt
 	return statusesCopy
 }
 
-func (s *CameraService) CheckCameraConnectionNow(id uint) (CameraConnectionStatus, error) {
+// CheckCameraConnectionNow probes one camera, the frontend can cancel the call to stop
+// the probe
+func (s *CameraService) CheckCameraConnectionNow(ctx context.Context, id uint) (CameraConnectionStatus, error) {
 	camera, err := s.GetCameraByID(id)
 	if err != nil {
 		return CameraConnectionStatus{}, err
 	}
 
-	s.checkCameraConnection(camera)
+	usage.Feature(usage.CameraCheck)
+	timeout := probeCallTimeout(s.probeOptions(camera))
+	return callguard.Call(s.calls, ctx, "CheckCameraConnectionNow", timeout, func(ctx context.Context) (CameraConnectionStatus, error) {
+		if err := s.checkCameraConnection(ctx, camera); err != nil {
+			return CameraConnectionStatus{}, err
+		}
 
-	status, exists := s.GetConnectionStatus(camera.UUID)
-	if !exists {
-		return CameraConnectionStatus{}, errors.New("status not found after check")
-	}
+		status, exists := s.GetConnectionStatus(camera.UUID)
+		if !exists {
+			return CameraConnectionStatus{}, errors.New("status not found after check")
+		}
 
-	return status, nil
+		return status, nil
+	})
 }
 
-func (s *CameraService) GetCamerasWithStatus() ([]map[string]interface{}, error) {
+func (s *CameraService) GetCamerasWithStatus() ([]CameraWithStatus, error) {
 	cameras, err := s.ListCamera()
 	if err != nil {
 		return nil, err
 	}
 
-	result := make([]map[string]interface{}, 0, len(cameras))
+	result := make([]CameraWithStatus, 0, len(cameras))
 
 	for _, camera := range cameras {
-		cameraMap := map[string]interface{}{
-			"ID":        camera.ID,
-			"UUID":      camera.UUID,
-			"Name":      camera.Name,
-			"Location":  camera.Location,
-			"Schema":    camera.Schema,
-			"Host":      camera.Host,
-			"Port":      camera.Port,
-			"Path":      camera.Path,
-			"Username":  camera.Username,
-			"Direction": camera.Direction,
-			"Status":    camera.Status,
-			"CreatedAt": camera.CreatedAt,
+		item := CameraWithStatus{
+			ID:        camera.ID,
+			UUID:      camera.UUID,
+			Name:      camera.Name,
+			Location:  camera.Location,
+			Schema:    camera.Schema,
+			Host:      camera.Host,
+			Port:      camera.Port,
+			Path:      camera.Path,
+			Username:  camera.Username,
+			Direction: camera.Direction,
+			Status:    camera.Status,
+			CreatedAt: camera.CreatedAt,
 		}
 
 		if status, exists := s.GetConnectionStatus(camera.UUID); exists {
-			cameraMap["is_connected"] = status.IsConnected
-			cameraMap["last_checked"] = status.LastChecked.Format(time.RFC3339)
-			if status.StatusMessage != "" {
-				cameraMap["status_message"] = status.StatusMessage
-			}
+			isConnected := status.IsConnected
+			item.IsConnected = &isConnected
+			item.LastChecked = status.LastChecked.Format(time.RFC3339)
+			item.StatusMessage = status.StatusMessage
 		}
 
-		result = append(result, cameraMap)
+		result = append(result, item)
 	}
 
 	return result, nil
 }
 
 func (s *CameraService) CreateCamera(input models.CameraInput) (*models.Camera, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return nil, err
+	}
+
+	if err := validateCameraInput(input); err != nil {
+		return nil, err
+	}
+
 	var location models.Location
 	if err := s.DB.Where("id = ?", input.Location).First(&location).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -368,16 +482,15 @@ func (s *CameraService) CreateCamera(input models.CameraInput) (*models.Camera,
 	}
 
 	if s.backgroundRunning {
-		go s.checkCameraConnection(camera)
+		go s.checkCameraConnection(context.Background(), camera)
 	}
 
+	s.warnDuplicate(camera)
+	s.suggestRebind(camera)
 	s.autoExportConfig()
 
 	s.syncCamerasAsync()
-
-	if s.process.RestartProcess("people_counter.bat") {
-		s.logger.Info("Restarting people_counter.bat")
-	}
+	usage.Feature(usage.CameraCreate)
 
 	return camera, nil
 }
@@ -400,10 +513,33 @@ func (s *CameraService) GetCameraByID(id uint) (*models.Camera, error) {
 	return &camera, nil
 }
 
+// validateCameraInput checks that an RTSP URL can be generated from the camera input
+func validateCameraInput(input models.CameraInput) error {
+	config := ffmpeg.RTSPConfig{
+		Schema:   input.Schema,
+		Host:     input.Host,
+		Port:     input.Port,
+		Path:     input.Path,
+		Username: input.Username,
+		Password: input.Password,
+	}
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("invalid camera address: %w", err)
+	}
+	return nil
+}
+
 func (s *CameraService) UpdateCamera(id uint, input models.CameraInput) (*models.Camera, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return nil, err
+	}
+
+	if err := validateCameraInput(input); err != nil {
+		return nil, err
+	}
 
 	var camera models.Camera
-	if err := s.DB.First(&camera, id).Error; err != nil {
+	if err := s.DB.Where("deleted_at IS NULL").First(&camera, id).Error; err != nil {
 		return nil, err
 	}
 
@@ -449,50 +585,79 @@ func (s *CameraService) UpdateCamera(id uint, input models.CameraInput) (*models
 	}
 
 	if s.backgroundRunning {
-		go s.checkCameraConnection(&camera)
+		go s.checkCameraConnection(context.Background(), &camera)
 	}
 
+	s.warnDuplicate(&camera)
 	s.autoExportConfig()
 
 	s.syncCamerasAsync()
-
-	// if s.process.RestartProcess("people_counter.bat") {
-	// 	s.logger.Info("Restarting people_counter.bat")
-	// }
+	usage.Feature(usage.CameraUpdate)
 
 	return &camera, nil
 }
 
+// SetCameraPlacement places a camera on the floorplan of its location. x and y are relative
+// to the image from the top left, nil removes the camera from the floorplan.
+func (s *CameraService) SetCameraPlacement(id uint, x, y *float64) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+	if (x == nil) != (y == nil) {
+		return errors.New("x and y must be set together")
+	}
+	if x != nil && (*x < 0 || *x > 1 || *y < 0 || *y > 1) {
+		return errors.New("placement must be between 0 and 1")
+	}
+
+	result := s.DB.Model(&models.Camera{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"floorplan_x": x, "floorplan_y": y})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	s.syncCamerasAsync()
+	return nil
+}
+
 func (s *CameraService) DeleteCamera(id uint) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+
 	var camera models.Camera
-	if err := s.DB.First(&camera, id).Error; err != nil {
+	if err := s.DB.Where("deleted_at IS NULL").First(&camera, id).Error; err != nil {
 		return err
 	}
 	now := time.Now().Format(time.RFC3339)
-	camera.DeletedAt = &now
 
-	if err := s.DB.Delete(&camera).Error; err != nil {
+	// Kamera hanya ditandai terhapus agar UUID dan riwayatnya bisa dipakai lagi lewat rebind
+	if err := s.DB.Model(&camera).Update("deleted_at", now).Error; err != nil {
 		return err
 	}
 
+	s.statusMutex.Lock()
+	delete(s.connectionStatuses, camera.UUID)
+	s.statusMutex.Unlock()
+
 	s.autoExportConfig()
 	s.syncCamerasAsync()
-
-	if s.process.RestartProcess("people_counter.bat") {
-		s.logger.Info("Restarting people_counter.bat")
-	}
+	usage.Feature(usage.CameraDelete)
 
 	return nil
 }
 
-func (s *CameraService) GetPayloadData(camera *models.Camera) (map[string]any, error) {
+func (s *CameraService) GetPayloadData(camera *models.Camera) (CameraPayload, error) {
+	var payloadData CameraPayload
 	if camera.Payload == "" {
-		return make(map[string]any), nil
+		return payloadData, nil
 	}
 
-	var payloadData map[string]any
 	if err := json.Unmarshal([]byte(camera.Payload), &payloadData); err != nil {
-		return nil, err
+		return CameraPayload{}, err
 	}
 
 	return payloadData, nil
@@ -509,71 +674,76 @@ func (s *CameraService) GetCameraWithLines(id uint) (*models.Camera, []models.Li
 		return camera, nil, err
 	}
 
-	var lines []models.LineData
-	if linesData, ok := payloadData["lines"]; ok {
-		linesJSON, err := json.Marshal(linesData)
-		if err != nil {
-			return camera, nil, err
-		}
-
-		if err := json.Unmarshal(linesJSON, &lines); err != nil {
-			return camera, nil, err
-		}
-	}
-
-	return camera, lines, nil
+	return camera, payloadData.Lines, nil
 }
 
 func (s *CameraService) autoExportConfig() {
 	go func() {
-		if err := s.ExportCameraConfig(); err != nil {
+		if err := s.ExportCameraConfig(context.Background()); err != nil {
 			s.logger.Error("Error auto-exporting camera config: %v", err)
 		}
 	}()
 }
 
-func (s *CameraService) CheckConnection(rtspURL string, takeScreenshot bool) string {
-	options := ffmpeg.RTSPOptions{
-		TakeScreenshot: takeScreenshot,
+func (s *CameraService) CheckConnection(ctx context.Context, rtspURL string, takeScreenshot bool) string {
+	options := s.globalProbeOptions()
+	options.TakeScreenshot = takeScreenshot
+
+	result, err := callguard.Call(s.calls, ctx, "CheckConnection", probeCallTimeout(options), func(ctx context.Context) (string, error) {
+		return ffmpeg.CheckRTSPConnectionContext(ctx, rtspURL, options), nil
+	})
+	if err != nil {
+		return probeErrorResponse(err)
 	}
-	return ffmpeg.CheckRTSPConnection(rtspURL, options)
+	return result
 }
 
-func (s *CameraService) CheckConnectionWithConfig(config ffmpeg.RTSPConfig, takeScreenshot bool) string {
-	var result string
-
-	// Add recovery for panics
-	defer func() {
-		if r := recover(); r != nil {
-			s.logger.Error("PANIC in CheckConnectionWithConfig: %v", r)
-			response := map[string]interface{}{
-				"success":   false,
-				"message":   "Internal server error",
-				"error":     fmt.Sprintf("Panic occurred: %v", r),
-				"timestamp": time.Now(),
-			}
-			jsonResponse, _ := json.Marshal(response)
-			result = string(jsonResponse)
-		}
-	}()
+func (s *CameraService) CheckConnectionWithConfig(ctx context.Context, config ffmpeg.RTSPConfig, takeScreenshot bool) string {
+	options := s.globalProbeOptions()
+	options.TakeScreenshot = takeScreenshot
+	return s.CheckConnectionWithOptions(ctx, config, options)
+}
 
-	// Verify options initialization
-	options := ffmpeg.RTSPOptions{
-		TakeScreenshot: takeScreenshot,
+// CheckConnectionWithOptions checks a camera with explicit probe options, so a strategy
+// can be tried before it is saved. Empty fields use the global options.
+func (s *CameraService) CheckConnectionWithOptions(ctx context.Context, config ffmpeg.RTSPConfig, options ffmpeg.RTSPOptions) string {
+	if err := options.Validate(); err != nil {
+		return probeErrorResponse(err)
 	}
+	options = s.globalProbeOptions().Merge(options)
 
 	// Log before calling method
 	s.logger.Info("Calling CheckRTSPConnectionWithConfig with config: %+v", config)
 
-	// Call the RTSP connection check
-	result = ffmpeg.CheckRTSPConnectionWithConfig(config, options)
-
-	// Log the result type for debugging
-	s.logger.Debug("Result type: %T", result)
+	// Call the RTSP connection check, panics and timeouts are returned as a failed check
+	result, err := callguard.Call(s.calls, ctx, "CheckConnectionWithOptions", probeCallTimeout(options), func(ctx context.Context) (string, error) {
+		return ffmpeg.CheckRTSPConnectionWithConfigContext(ctx, config, options), nil
+	})
+	if err != nil {
+		return probeErrorResponse(err)
+	}
 
 	return result
 }
 
+// probeErrorResponse returns a failed guarded probe in the response format of the ffmpeg package
+func probeErrorResponse(err error) string {
+	response := ffmpeg.ResponseJSON{
+		Success:   false,
+		Message:   "RTSP connection check failed",
+		Error:     err.Error(),
+		Timestamp: time.Now(),
+	}
+
+	var callErr *callguard.CallError
+	if errors.As(err, &callErr) {
+		response.Message = callErr.Message
+	}
+
+	jsonResponse, _ := json.Marshal(response)
+	return string(jsonResponse)
+}
+
 func (s *CameraService) GenerateRTSPURL(config ffmpeg.RTSPConfig) (string, error) {
 	return ffmpeg.GenerateRTSPURL(config)
 }
@@ -601,7 +771,14 @@ func (s *CameraService) GetImageAsBase64(imagePath string) string {
 	return "data:" + mimeType + ";base64," + base64Str
 }
 
-func (s *CameraService) ExportCameraConfig() error {
+// ExportCameraConfig writes config.camera.json from the camera table, and the camera subset
+// of every counter instance to the instance config. A counter is asked to reload only when
+// its exported content differs from the file on disk. A cancelled export leaves the file untouched.
+func (s *CameraService) ExportCameraConfig(ctx context.Context) error {
+	return callguard.Do(s.calls, ctx, "ExportCameraConfig", exportTimeout, s.exportCameraConfig)
+}
+
+func (s *CameraService) exportCameraConfig(ctx context.Context) error {
 	cameras, err := s.ListCamera()
 	if err != nil {
 		return err
@@ -610,6 +787,9 @@ func (s *CameraService) ExportCameraConfig() error {
 	configs := make([]models.Config, 0, len(cameras))
 
 	for _, camera := range cameras {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if camera.DeletedAt != nil {
 			continue
 		}
@@ -700,105 +880,31 @@ func (s *CameraService) ExportCameraConfig() error {
 		return fmt.Errorf("failed to marshal camera config to JSON: %w", err)
 	}
 
-	filePath := filepath.Join(s.config.CameraConfigPath, s.config.CameraConfigName)
-	if err := os.WriteFile(filePath, jsonData, 0644); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
-	}
-
-	s.logger.Info("Successfully exported camera config to %s", filePath)
-	return nil
-}
-
-func checkDirection(direction string, validValues []string) bool {
-	return slices.Contains(validValues, direction)
-}
-
-func (s *CameraService) syncCameras() error {
-	s.logger.Info("Starting camera synchronization to server")
-
-	cameras, err := s.ListCamera()
-	if err != nil {
-		return fmt.Errorf("failed to list cameras: %w", err)
-	}
-
-	siteId, err := s.settingService.GetSetting("site_id")
-	if err != nil {
-		return fmt.Errorf("failed to get location id: %w", err)
-	}
-
-	siteIdInt, err := strconv.Atoi(siteId)
-	if err != nil {
-		return fmt.Errorf("failed to convert site id to integer: %w", err)
-	}
-
-	var camerasSync []CameraSync
-	for _, camera := range cameras {
-		camerasSync = append(camerasSync, CameraSync{
-			ID:          camera.ID,
-			UUID:        camera.UUID,
-			Name:        camera.Name,
-			LocationID:  camera.LocationID,
-			Description: camera.Description,
-			Tags:        camera.Tags,
-			Schema:      camera.Schema,
-			Host:        camera.Host,
-			Port:        camera.Port,
-			Username:    camera.Username,
-			Password:    camera.Password,
-			Path:        camera.Path,
-			Direction:   camera.Direction,
-			Status:      camera.Status,
-			Payload:     camera.Payload,
-			CreatedAt:   camera.CreatedAt,
-		})
-	}
-
-	// Buat request body
-	requestBody := map[string]interface{}{
-		"site_id": siteIdInt,
-		"cameras": camerasSync,
-	}
-
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return fmt.Errorf("failed to marshal camera data: %w", err)
-	}
-
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/v1/app/cameras/sync", s.config.ApiUrl), bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to create HTTP request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Add("X-API-Key", s.config.ApiKey)
-
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	resp, err := client.Do(req)
+	changed, err := s.writeCameraConfig(jsonData, len(configs), ConfigSourceExport)
 	if err != nil {
-		return fmt.Errorf("failed to send sync request: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	var syncResponse SyncResponse
-	if err := json.NewDecoder(resp.Body).Decode(&syncResponse); err != nil {
-		return fmt.Errorf("failed to parse response: %w", err)
+	// Instance config bisa berubah walau config lengkap sama, misalnya setelah instance diedit
+	if err := s.exportInstanceConfigs(ctx, cameraConfig); err != nil {
+		return err
 	}
 
-	if !syncResponse.Success {
-		return fmt.Errorf("sync failed: %s", syncResponse.Error)
+	filePath := filepath.Join(s.config.CameraConfigPath, s.config.CameraConfigName)
+	if !changed {
+		s.logger.Debug("Camera config unchanged, %s not rewritten", filePath)
+		return nil
 	}
 
-	s.logger.Info("Camera synchronization completed successfully: %s", syncResponse.Message)
+	s.logger.Info("Successfully exported camera config to %s", filePath)
+	s.notifyCounter()
 	return nil
 }
 
-func (s *CameraService) syncCamerasAsync() {
-	go func() {
-		if err := s.syncCameras(); err != nil {
-			s.logger.Error("Background camera sync error: %v", err)
-		}
-	}()
+func checkDirection(direction string, validValues []string) bool {
+	return slices.Contains(validValues, direction)
 }
@@ -0,0 +1,328 @@
+package camera
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"jarvist/internal/common/residency"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// syncRequestTimeout limits a camera sync request
+const syncRequestTimeout = 30 * time.Second
+
+// errFullSyncRequired is returned by a differential sync the server could not apply
+var errFullSyncRequired = errors.New("server requested a full camera sync")
+
+// cameraDiff holds the cameras changed since the last sync the server confirmed
+type cameraDiff struct {
+	Created []CameraSync `json:"created"`
+	Updated []CameraSync `json:"updated"`
+	Deleted []string     `json:"deleted"` // UUIDs
+}
+
+func (d cameraDiff) empty() bool {
+	return len(d.Created) == 0 && len(d.Updated) == 0 && len(d.Deleted) == 0
+}
+
+// syncCameras sends the camera list to the server. Only cameras created, changed or deleted
+// since the last confirmed sync are sent; the whole list is sent on the first sync and when
+// the server cannot apply the changes to the state it holds. Nothing is sent in local-only
+// mode, the sync is queued and retried once the mode is switched back.
+func (s *CameraService) syncCameras() error {
+	if residency.LocalOnly() {
+		return residency.ErrLocalOnly
+	}
+
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+
+	s.logger.Info("Starting camera synchronization to server")
+
+	cameras, err := s.ListCamera()
+	if err != nil {
+		return fmt.Errorf("failed to list cameras: %w", err)
+	}
+
+	siteId, err := s.settingService.GetSetting("site_id")
+	if err != nil {
+		return fmt.Errorf("failed to get location id: %w", err)
+	}
+
+	siteIdInt, err := strconv.Atoi(siteId)
+	if err != nil {
+		return fmt.Errorf("failed to convert site id to integer: %w", err)
+	}
+
+	camerasSync := make([]CameraSync, 0, len(cameras))
+	hashes := make(map[string]string, len(cameras))
+	for _, camera := range cameras {
+		entry := cameraSyncEntry(camera)
+		hash, err := cameraHash(entry)
+		if err != nil {
+			return err
+		}
+		camerasSync = append(camerasSync, entry)
+		hashes[camera.UUID] = hash
+	}
+
+	var synced []models.CameraSyncState
+	if err := s.DB.Find(&synced).Error; err != nil {
+		return fmt.Errorf("failed to read camera sync state: %w", err)
+	}
+
+	if len(synced) > 0 {
+		diff := diffCameras(camerasSync, hashes, synced)
+		if diff.empty() {
+			s.logger.Debug("Cameras unchanged since last sync, nothing sent")
+			return nil
+		}
+
+		err := s.sendCameraDiff(siteIdInt, diff, hashes)
+		if err == nil {
+			return s.saveCameraSyncState(cameras, hashes)
+		}
+		if !errors.Is(err, errFullSyncRequired) {
+			return err
+		}
+		s.logger.Warn("Differential camera sync not applied, sending all cameras: %v", err)
+	}
+
+	if err := s.sendAllCameras(siteIdInt, camerasSync); err != nil {
+		return err
+	}
+	return s.saveCameraSyncState(cameras, hashes)
+}
+
+// syncCamerasAsync syncs the cameras in the background, a failed sync is queued for retry
+func (s *CameraService) syncCamerasAsync() {
+	go func() {
+		if err := s.syncCameras(); err != nil {
+			s.logger.Error("Background camera sync error: %v", err)
+			s.queueCameraSync(err)
+			return
+		}
+		s.clearCameraSyncQueue()
+	}()
+}
+
+// ResyncAllCameras forgets what the server was sent and sends the whole camera list again
+func (s *CameraService) ResyncAllCameras() error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+
+	s.syncMutex.Lock()
+	err := s.DB.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&models.CameraSyncState{}).Error
+	s.syncMutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to reset camera sync state: %w", err)
+	}
+
+	s.logger.Info("Camera sync state reset, sending all cameras")
+	if err := s.syncCameras(); err != nil {
+		s.queueCameraSync(err)
+		return err
+	}
+	s.clearCameraSyncQueue()
+	return nil
+}
+
+func (s *CameraService) sendAllCameras(siteID int, cameras []CameraSync) error {
+	requestBody := map[string]interface{}{
+		"site_id": siteID,
+		"cameras": cameras,
+	}
+
+	syncResponse, _, err := s.postCameraSync("/v1/app/cameras/sync", requestBody)
+	if err != nil {
+		return err
+	}
+	if !syncResponse.Success {
+		return fmt.Errorf("sync failed: %s", syncResponse.Error)
+	}
+
+	s.logger.Info("Camera synchronization completed successfully (%d cameras): %s", len(cameras), syncResponse.Message)
+	return nil
+}
+
+// sendCameraDiff sends the changed cameras with the checksum of the full list, so the server
+// can confirm its copy matches after applying them
+func (s *CameraService) sendCameraDiff(siteID int, diff cameraDiff, hashes map[string]string) error {
+	checksum := camerasChecksum(hashes)
+	requestBody := map[string]interface{}{
+		"site_id":  siteID,
+		"created":  diff.Created,
+		"updated":  diff.Updated,
+		"deleted":  diff.Deleted,
+		"total":    len(hashes),
+		"checksum": checksum,
+	}
+
+	syncResponse, status, err := s.postCameraSync("/v1/app/cameras/sync/diff", requestBody)
+	switch {
+	case status == http.StatusNotFound || status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented:
+		return fmt.Errorf("%w: differential sync not supported (HTTP %d)", errFullSyncRequired, status)
+	case status == http.StatusConflict:
+		return fmt.Errorf("%w: server state out of date", errFullSyncRequired)
+	case err != nil:
+		return err
+	case syncResponse.FullSyncRequired:
+		return fmt.Errorf("%w: %s", errFullSyncRequired, syncResponse.Error)
+	case !syncResponse.Success:
+		return fmt.Errorf("sync failed: %s", syncResponse.Error)
+	case syncResponse.Checksum != "" && syncResponse.Checksum != checksum:
+		return fmt.Errorf("%w: checksum mismatch", errFullSyncRequired)
+	}
+
+	s.logger.Info("Camera synchronization completed successfully (%d created, %d updated, %d deleted): %s",
+		len(diff.Created), len(diff.Updated), len(diff.Deleted), syncResponse.Message)
+	return nil
+}
+
+// postCameraSync posts a sync request and returns the response with its HTTP status
+func (s *CameraService) postCameraSync(path string, requestBody interface{}) (SyncResponse, int, error) {
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return SyncResponse{}, 0, fmt.Errorf("failed to marshal camera data: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), syncRequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.config.ApiUrl+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return SyncResponse{}, 0, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Add("X-API-Key", s.config.ApiKey)
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return SyncResponse{}, 0, fmt.Errorf("failed to send sync request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var syncResponse SyncResponse
+	if err := json.NewDecoder(resp.Body).Decode(&syncResponse); err != nil {
+		return SyncResponse{}, resp.StatusCode, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return syncResponse, resp.StatusCode, nil
+}
+
+// saveCameraSyncState remembers the hashes the server now holds
+func (s *CameraService) saveCameraSyncState(cameras []models.Camera, hashes map[string]string) error {
+	now := time.Now()
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&models.CameraSyncState{}).Error; err != nil {
+			return err
+		}
+		if len(cameras) == 0 {
+			return nil
+		}
+
+		states := make([]models.CameraSyncState, 0, len(cameras))
+		for _, camera := range cameras {
+			states = append(states, models.CameraSyncState{
+				UUID:     camera.UUID,
+				CameraID: camera.ID,
+				Hash:     hashes[camera.UUID],
+				SyncedAt: now,
+			})
+		}
+		return tx.Create(&states).Error
+	})
+	if err != nil {
+		// Sinkronisasi berikutnya akan mengirim semua kamera
+		return fmt.Errorf("failed to save camera sync state: %w", err)
+	}
+	return nil
+}
+
+func cameraSyncEntry(camera models.Camera) CameraSync {
+	return CameraSync{
+		ID:          camera.ID,
+		UUID:        camera.UUID,
+		Name:        camera.Name,
+		LocationID:  camera.LocationID,
+		Description: camera.Description,
+		Tags:        camera.Tags,
+		Schema:      camera.Schema,
+		Host:        camera.Host,
+		Port:        camera.Port,
+		Username:    camera.Username,
+		Password:    camera.Password,
+		Path:        camera.Path,
+		Direction:   camera.Direction,
+		Status:      camera.Status,
+		Payload:     camera.Payload,
+		CreatedAt:   camera.CreatedAt,
+		FloorplanX:  camera.FloorplanX,
+		FloorplanY:  camera.FloorplanY,
+	}
+}
+
+// cameraHash hashes everything the server receives of a camera
+func cameraHash(camera CameraSync) (string, error) {
+	data, err := json.Marshal(camera)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal camera data: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// camerasChecksum hashes the camera hashes ordered by UUID
+func camerasChecksum(hashes map[string]string) string {
+	uuids := make([]string, 0, len(hashes))
+	for uuid := range hashes {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+
+	var b strings.Builder
+	for _, uuid := range uuids {
+		b.WriteString(uuid)
+		b.WriteByte(':')
+		b.WriteString(hashes[uuid])
+		b.WriteByte('\n')
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+func diffCameras(cameras []CameraSync, hashes map[string]string, synced []models.CameraSyncState) cameraDiff {
+	previous := make(map[string]string, len(synced))
+	for _, state := range synced {
+		previous[state.UUID] = state.Hash
+	}
+
+	diff := cameraDiff{Created: []CameraSync{}, Updated: []CameraSync{}, Deleted: []string{}}
+	for _, camera := range cameras {
+		hash, ok := previous[camera.UUID]
+		switch {
+		case !ok:
+			diff.Created = append(diff.Created, camera)
+		case hash != hashes[camera.UUID]:
+			diff.Updated = append(diff.Updated, camera)
+		}
+	}
+	for _, state := range synced {
+		if _, ok := hashes[state.UUID]; !ok {
+			diff.Deleted = append(diff.Deleted, state.UUID)
+		}
+	}
+	return diff
+}
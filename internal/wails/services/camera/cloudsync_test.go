@@ -0,0 +1,235 @@
+package camera
+
+import (
+	"encoding/json"
+	"errors"
+	"jarvist/internal/common/config"
+	"jarvist/internal/common/models"
+	"jarvist/internal/testutil"
+	"jarvist/internal/wails/services/deps"
+	"jarvist/internal/wails/services/mocks"
+	"jarvist/internal/wails/services/setting"
+	"jarvist/pkg/logger"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newTestService(t *testing.T, client *mocks.HTTPClient, events *mocks.Emitter) *CameraService {
+	t.Helper()
+
+	db := testutil.OpenDB(t, &models.Setting{}, &models.Location{}, &models.Camera{},
+		&models.CameraSyncState{}, &models.CameraSyncOutbox{})
+
+	if err := db.Create(&models.Setting{Key: "site_id", Value: "7"}).Error; err != nil {
+		t.Fatalf("create setting: %v", err)
+	}
+
+	cfg := &config.Config{ApiUrl: "http://api.test", ApiKey: "test-key"}
+	log := logger.NewLogger().WithComponent("test")
+	settings := setting.New(db, cfg, log, nil)
+
+	return New(db, settings, cfg, log, nil, deps.WithHTTPClient(client), deps.WithEmitter(events))
+}
+
+func addCamera(t *testing.T, s *CameraService, name string) models.Camera {
+	t.Helper()
+
+	camera := models.Camera{Name: name, LocationID: "loc-1", Schema: "rtsp", Host: "10.0.0.1", Port: 554, Path: "/" + name}
+	if err := s.DB.Create(&camera).Error; err != nil {
+		t.Fatalf("create camera: %v", err)
+	}
+	return camera
+}
+
+func syncOK(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(SyncResponse{Success: true, Message: "ok"})
+}
+
+func decodeBody(t *testing.T, request mocks.Request) map[string]json.RawMessage {
+	t.Helper()
+
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(request.Body, &body); err != nil {
+		t.Fatalf("decode request body: %v", err)
+	}
+	return body
+}
+
+func countList(t *testing.T, raw json.RawMessage) int {
+	t.Helper()
+
+	var list []json.RawMessage
+	if err := json.Unmarshal(raw, &list); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	return len(list)
+}
+
+func TestSyncCamerasSendsOnlyChanges(t *testing.T) {
+	client := mocks.NewHTTPClient(syncOK)
+	s := newTestService(t, client, &mocks.Emitter{})
+
+	first := addCamera(t, s, "entrance")
+	addCamera(t, s, "exit")
+
+	if err := s.syncCameras(); err != nil {
+		t.Fatalf("first sync: %v", err)
+	}
+	requests := client.Requests()
+	if len(requests) != 1 || requests[0].Path != "/v1/app/cameras/sync" {
+		t.Fatalf("first sync: got %+v, want one full sync", requests)
+	}
+	if got := countList(t, decodeBody(t, requests[0])["cameras"]); got != 2 {
+		t.Errorf("full sync cameras: got %d, want 2", got)
+	}
+	if got := requests[0].Header.Get("X-API-Key"); got != "test-key" {
+		t.Errorf("api key: got %q", got)
+	}
+
+	// Tanpa perubahan tidak ada request
+	if err := s.syncCameras(); err != nil {
+		t.Fatalf("unchanged sync: %v", err)
+	}
+	if got := len(client.Requests()); got != 1 {
+		t.Fatalf("unchanged sync sent a request, got %d requests", got)
+	}
+
+	s.DB.Model(&first).Update("name", "main entrance")
+	addCamera(t, s, "lobby")
+	if err := s.syncCameras(); err != nil {
+		t.Fatalf("diff sync: %v", err)
+	}
+	requests = client.Requests()
+	if len(requests) != 2 || requests[1].Path != "/v1/app/cameras/sync/diff" {
+		t.Fatalf("diff sync: got %+v", requests)
+	}
+	body := decodeBody(t, requests[1])
+	if created, updated, deleted := countList(t, body["created"]), countList(t, body["updated"]), countList(t, body["deleted"]); created != 1 || updated != 1 || deleted != 0 {
+		t.Errorf("diff: got %d created, %d updated, %d deleted, want 1, 1, 0", created, updated, deleted)
+	}
+
+	s.DB.Model(&first).Update("deleted_at", time.Now().Format(time.RFC3339))
+	if err := s.syncCameras(); err != nil {
+		t.Fatalf("delete sync: %v", err)
+	}
+	requests = client.Requests()
+	var deleted []string
+	json.Unmarshal(decodeBody(t, requests[2])["deleted"], &deleted)
+	if len(deleted) != 1 || deleted[0] != first.UUID {
+		t.Errorf("deleted: got %v, want [%s]", deleted, first.UUID)
+	}
+}
+
+func TestSyncCamerasFallsBackToFullSync(t *testing.T) {
+	tests := []struct {
+		name    string
+		respond http.HandlerFunc
+	}{
+		{"not supported", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(SyncResponse{Error: "not found"})
+		}},
+		{"full sync requested", func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(SyncResponse{FullSyncRequired: true, Error: "unknown camera"})
+		}},
+		{"checksum mismatch", func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(SyncResponse{Success: true, Checksum: "stale"})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := mocks.NewHTTPClient(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/v1/app/cameras/sync/diff" {
+					tt.respond(w, r)
+					return
+				}
+				syncOK(w, r)
+			})
+			s := newTestService(t, client, &mocks.Emitter{})
+
+			addCamera(t, s, "entrance")
+			if err := s.syncCameras(); err != nil {
+				t.Fatalf("first sync: %v", err)
+			}
+			addCamera(t, s, "exit")
+			if err := s.syncCameras(); err != nil {
+				t.Fatalf("second sync: %v", err)
+			}
+
+			requests := client.Requests()
+			if len(requests) != 3 || requests[1].Path != "/v1/app/cameras/sync/diff" || requests[2].Path != "/v1/app/cameras/sync" {
+				t.Fatalf("got %d requests, want full, diff and full again", len(requests))
+			}
+			if got := countList(t, decodeBody(t, requests[2])["cameras"]); got != 2 {
+				t.Errorf("fallback cameras: got %d, want 2", got)
+			}
+		})
+	}
+}
+
+func TestFailedSyncIsQueuedAndRetried(t *testing.T) {
+	client := mocks.NewHTTPClient(syncOK)
+	client.Err = errors.New("network unreachable")
+	events := &mocks.Emitter{}
+	s := newTestService(t, client, events)
+
+	addCamera(t, s, "entrance")
+	if err := s.syncCameras(); err == nil {
+		t.Fatal("sync succeeded without a network")
+	} else {
+		s.queueCameraSync(err)
+	}
+
+	status, err := s.GetCameraSyncStatus()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if !status.Pending || status.Attempts != 1 || status.LastError == "" {
+		t.Errorf("status after failure: got %+v", status)
+	}
+	if status.NextAttemptAt == nil || !status.NextAttemptAt.After(time.Now()) {
+		t.Errorf("next attempt not scheduled: %+v", status.NextAttemptAt)
+	}
+	if got := len(events.Named("camera:sync-status")); got != 1 {
+		t.Errorf("sync status events: got %d, want 1", got)
+	}
+
+	client.Err = nil
+	status, err = s.RetryCameraSync()
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if status.Pending || status.Cameras != 1 || status.LastSyncedAt == nil {
+		t.Errorf("status after retry: got %+v", status)
+	}
+}
+
+func TestDiffCameras(t *testing.T) {
+	cameras := []CameraSync{{UUID: "a"}, {UUID: "b"}, {UUID: "c"}}
+	hashes := map[string]string{"a": "1", "b": "2-new", "c": "3"}
+	synced := []models.CameraSyncState{{UUID: "a", Hash: "1"}, {UUID: "b", Hash: "2"}, {UUID: "d", Hash: "4"}}
+
+	diff := diffCameras(cameras, hashes, synced)
+	if len(diff.Created) != 1 || diff.Created[0].UUID != "c" {
+		t.Errorf("created: got %+v", diff.Created)
+	}
+	if len(diff.Updated) != 1 || diff.Updated[0].UUID != "b" {
+		t.Errorf("updated: got %+v", diff.Updated)
+	}
+	if len(diff.Deleted) != 1 || diff.Deleted[0] != "d" {
+		t.Errorf("deleted: got %v", diff.Deleted)
+	}
+}
+
+func TestCamerasChecksumIgnoresOrder(t *testing.T) {
+	a := camerasChecksum(map[string]string{"x": "1", "y": "2"})
+	b := camerasChecksum(map[string]string{"y": "2", "x": "1"})
+	if a != b {
+		t.Errorf("checksum depends on map order: %s != %s", a, b)
+	}
+	if c := camerasChecksum(map[string]string{"x": "1", "y": "3"}); c == a {
+		t.Error("checksum unchanged after a camera changed")
+	}
+}
@@ -0,0 +1,352 @@
+package camera
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/ffmpeg"
+	"jarvist/internal/common/models"
+	"slices"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MergeAuditAction is the action name used for camera merges in the audit table
+const MergeAuditAction = "camera.merge"
+
+// DuplicateCamera is a camera reading the same stream as another camera
+type DuplicateCamera struct {
+	ID         uint   `json:"id"`
+	UUID       string `json:"uuid"`
+	Name       string `json:"name"`
+	LocationID string `json:"location_id"`
+	CreatedAt  string `json:"created_at"`
+	Lines      int    `json:"lines"`
+	Records    int64  `json:"records"` // Cached data records counted by the camera
+}
+
+// DuplicateGroup is a set of cameras with the same host, port and path
+type DuplicateGroup struct {
+	Endpoint string            `json:"endpoint"` // Without credentials
+	Cameras  []DuplicateCamera `json:"cameras"`
+}
+
+// CameraMergeResult describes what a merge moved onto the kept camera
+type CameraMergeResult struct {
+	KeptID     uint  `json:"kept_id"`
+	RemovedID  uint  `json:"removed_id"`
+	LinesAdded int   `json:"lines_added"`
+	Moved      int64 `json:"records_moved"`
+	// Records of days both cameras counted stay under the removed camera ID, moving them
+	// would count the same people twice
+	Overlapping int64    `json:"records_overlapping"`
+	Instances   []string `json:"instances,omitempty"` // Counter instances that listed the removed camera
+}
+
+// FindDuplicateCameras returns the groups of cameras reading the same stream, installers
+// sometimes add a camera twice under different names
+func (s *CameraService) FindDuplicateCameras() ([]DuplicateGroup, error) {
+	cameras, err := s.ListCamera()
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string][]models.Camera)
+	var endpoints []string
+	for _, camera := range cameras {
+		key := endpointKey(camera)
+		if key == "" {
+			continue
+		}
+		if _, ok := groups[key]; !ok {
+			endpoints = append(endpoints, key)
+		}
+		groups[key] = append(groups[key], camera)
+	}
+	sort.Strings(endpoints)
+
+	result := []DuplicateGroup{}
+	for _, endpoint := range endpoints {
+		if len(groups[endpoint]) < 2 {
+			continue
+		}
+		group := DuplicateGroup{Endpoint: endpoint}
+		for _, camera := range groups[endpoint] {
+			group.Cameras = append(group.Cameras, s.duplicateCamera(camera))
+		}
+		result = append(result, group)
+	}
+	return result, nil
+}
+
+// CheckDuplicateCamera returns the cameras reading the same stream as the input, used by the
+// camera form to warn before saving. excludeID is the camera being edited, 0 when creating.
+func (s *CameraService) CheckDuplicateCamera(input models.CameraInput, excludeID uint) ([]DuplicateCamera, error) {
+	key := ffmpeg.RTSPConfig{Schema: input.Schema, Host: input.Host, Port: input.Port, Path: input.Path}.EndpointKey()
+	if key == "" {
+		return []DuplicateCamera{}, nil
+	}
+
+	cameras, err := s.ListCamera()
+	if err != nil {
+		return nil, err
+	}
+
+	duplicates := []DuplicateCamera{}
+	for _, camera := range cameras {
+		if camera.ID != excludeID && endpointKey(camera) == key {
+			duplicates = append(duplicates, s.duplicateCamera(camera))
+		}
+	}
+	return duplicates, nil
+}
+
+// MergeCameras consolidates a duplicate camera onto the camera that is kept: its counting
+// lines are added, its data history is moved, counter instances are pointed at the kept
+// camera and the duplicate is deleted. Both cameras must read the same stream.
+func (s *CameraService) MergeCameras(keepID, duplicateID uint) (CameraMergeResult, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return CameraMergeResult{}, err
+	}
+	if keepID == duplicateID {
+		return CameraMergeResult{}, errors.New("a camera cannot be merged with itself")
+	}
+
+	var keep, duplicate models.Camera
+	if err := s.DB.Where("deleted_at IS NULL").First(&keep, keepID).Error; err != nil {
+		return CameraMergeResult{}, fmt.Errorf("camera %d not found: %w", keepID, err)
+	}
+	if err := s.DB.Where("deleted_at IS NULL").First(&duplicate, duplicateID).Error; err != nil {
+		return CameraMergeResult{}, fmt.Errorf("camera %d not found: %w", duplicateID, err)
+	}
+	if key := endpointKey(keep); key == "" || key != endpointKey(duplicate) {
+		return CameraMergeResult{}, errors.New("the cameras do not read the same stream, only duplicates can be merged")
+	}
+
+	result := CameraMergeResult{KeptID: keep.ID, RemovedID: duplicate.ID}
+
+	keepPayload, err := s.GetPayloadData(&keep)
+	if err != nil {
+		return CameraMergeResult{}, fmt.Errorf("invalid lines of camera %d: %w", keep.ID, err)
+	}
+	duplicatePayload, err := s.GetPayloadData(&duplicate)
+	if err != nil {
+		return CameraMergeResult{}, fmt.Errorf("invalid lines of camera %d: %w", duplicate.ID, err)
+	}
+	for _, line := range duplicatePayload.Lines {
+		if !slices.ContainsFunc(keepPayload.Lines, func(existing models.LineData) bool { return sameLine(existing, line) }) {
+			keepPayload.Lines = append(keepPayload.Lines, line)
+			result.LinesAdded++
+		}
+	}
+	if err := setPayloadLines(&keep, keepPayload.Lines); err != nil {
+		return CameraMergeResult{}, err
+	}
+
+	// Data yang kosong di kamera yang dipertahankan diambil dari duplikatnya
+	if keep.ProbeOptions == "" {
+		keep.ProbeOptions = duplicate.ProbeOptions
+	}
+	if keep.FloorplanX == nil {
+		keep.FloorplanX, keep.FloorplanY = duplicate.FloorplanX, duplicate.FloorplanY
+	}
+	if keep.Description == "" {
+		keep.Description = duplicate.Description
+	}
+	if keep.Tags == "" {
+		keep.Tags = duplicate.Tags
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&keep).Error; err != nil {
+			return err
+		}
+
+		counted := tx.Model(&models.DataRecord{}).Select("DISTINCT date_folder").Where("cctv_id = ?", keep.ID)
+
+		// Hanya total dari data yang dipindah, hari yang dihitung keduanya tetap di duplikat
+		var carried lifetimeCounts
+		if err := tx.Model(&models.DataRecord{}).
+			Select("COALESCE(SUM(in_count), 0) AS in_total, COALESCE(SUM(out_count), 0) AS out_total, COUNT(*) AS records").
+			Where("cctv_id = ? AND date_folder NOT IN (?) AND imported = ?", duplicate.ID, counted, false).
+			Scan(&carried).Error; err != nil {
+			return err
+		}
+		if err := moveLifetime(tx, int(duplicate.ID), int(keep.ID), &carried); err != nil {
+			return err
+		}
+
+		moved := tx.Model(&models.DataRecord{}).
+			Where("cctv_id = ? AND date_folder NOT IN (?)", duplicate.ID, counted).
+			Update("cctv_id", keep.ID)
+		if moved.Error != nil {
+			return fmt.Errorf("failed to move data history: %w", moved.Error)
+		}
+		result.Moved = moved.RowsAffected
+
+		if err := tx.Model(&models.DataRecord{}).Where("cctv_id = ?", duplicate.ID).Count(&result.Overlapping).Error; err != nil {
+			return err
+		}
+
+		var snapshots int64
+		tx.Model(&models.CameraSnapshot{}).Where("camera_uuid = ?", keep.UUID).Count(&snapshots)
+		if snapshots == 0 {
+			if err := tx.Model(&models.CameraSnapshot{}).Where("camera_uuid = ?", duplicate.UUID).
+				Update("camera_uuid", keep.UUID).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Where("camera_uuid = ?", duplicate.UUID).Delete(&models.CameraSnapshot{}).Error; err != nil {
+			return err
+		}
+
+		// Soft delete seperti DeleteCamera, baris kamera tetap ada untuk sync dan rebind
+		now := time.Now().Format(time.RFC3339)
+		if err := tx.Model(&duplicate).Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+
+		detail, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		return tx.Create(&models.AuditEntry{
+			Timestamp: time.Now(),
+			Action:    MergeAuditAction,
+			Actor:     "admin",
+			Source:    "desktop",
+			Detail:    string(detail),
+		}).Error
+	})
+	if err != nil {
+		return CameraMergeResult{}, err
+	}
+
+	result.Instances = s.reassignInstances(keep.ID, duplicate.ID)
+
+	s.statusMutex.Lock()
+	delete(s.connectionStatuses, duplicate.UUID)
+	s.statusMutex.Unlock()
+
+	s.logger.Info("Merged camera %d (%s) into %d (%s): %d lines added, %d records moved, %d overlapping records kept",
+		duplicate.ID, duplicate.Name, keep.ID, keep.Name, result.LinesAdded, result.Moved, result.Overlapping)
+
+	s.autoExportConfig()
+	s.syncCamerasAsync()
+	return result, nil
+}
+
+// warnDuplicate logs and reports to the UI that a saved camera reads the same stream as
+// another camera
+func (s *CameraService) warnDuplicate(camera *models.Camera) {
+	key := endpointKey(*camera)
+	if key == "" {
+		return
+	}
+
+	var cameras []models.Camera
+	if err := s.DB.Where("deleted_at IS NULL AND id <> ?", camera.ID).Find(&cameras).Error; err != nil {
+		return
+	}
+
+	var duplicates []DuplicateCamera
+	for _, other := range cameras {
+		if endpointKey(other) == key {
+			duplicates = append(duplicates, DuplicateCamera{ID: other.ID, UUID: other.UUID, Name: other.Name})
+		}
+	}
+	if len(duplicates) == 0 {
+		return
+	}
+
+	s.logger.Warn("Camera %d (%s) reads the same stream as %d other cameras, merge them to avoid double counting",
+		camera.ID, camera.Name, len(duplicates))
+	s.emit("camera:duplicate", map[string]interface{}{
+		"camera_id":  camera.ID,
+		"endpoint":   key,
+		"duplicates": duplicates,
+	})
+}
+
+// reassignInstances points the counter instances listing the removed camera at the kept
+// camera, unless another instance already counts it
+func (s *CameraService) reassignInstances(keepID, duplicateID uint) []string {
+	if s.process == nil {
+		return nil
+	}
+
+	instances := s.process.GetCounterInstances()
+	keptElsewhere := false
+	for _, instance := range instances {
+		if slices.Contains(instance.Cameras, keepID) && !slices.Contains(instance.Cameras, duplicateID) {
+			keptElsewhere = true
+		}
+	}
+
+	var changed []string
+	for _, instance := range instances {
+		if !slices.Contains(instance.Cameras, duplicateID) {
+			continue
+		}
+
+		cameras := make([]uint, 0, len(instance.Cameras))
+		for _, id := range instance.Cameras {
+			if id != duplicateID {
+				cameras = append(cameras, id)
+			}
+		}
+		if !keptElsewhere && !slices.Contains(cameras, keepID) {
+			cameras = append(cameras, keepID)
+		}
+		instance.Cameras = cameras
+
+		if err := s.process.SaveCounterInstance(instance); err != nil {
+			s.logger.Warn("Failed to update counter instance %s after merging camera %d: %v", instance.Name, duplicateID, err)
+			continue
+		}
+		changed = append(changed, instance.Name)
+	}
+	return changed
+}
+
+func (s *CameraService) duplicateCamera(camera models.Camera) DuplicateCamera {
+	duplicate := DuplicateCamera{
+		ID:         camera.ID,
+		UUID:       camera.UUID,
+		Name:       camera.Name,
+		LocationID: camera.LocationID,
+		CreatedAt:  camera.CreatedAt,
+	}
+	if payload, err := s.GetPayloadData(&camera); err == nil {
+		duplicate.Lines = len(payload.Lines)
+	}
+	s.DB.Model(&models.DataRecord{}).Where("cctv_id = ?", camera.ID).Count(&duplicate.Records)
+	return duplicate
+}
+
+func endpointKey(camera models.Camera) string {
+	return ffmpeg.RTSPConfig{Schema: camera.Schema, Host: camera.Host, Port: camera.Port, Path: camera.Path}.EndpointKey()
+}
+
+func sameLine(a, b models.LineData) bool {
+	return a.Start == b.Start && a.End == b.End && a.Direction == b.Direction
+}
+
+// setPayloadLines replaces the lines in the payload of a camera, other payload fields are kept
+func setPayloadLines(camera *models.Camera, lines []models.LineData) error {
+	payload := make(map[string]interface{})
+	if camera.Payload != "" {
+		if err := json.Unmarshal([]byte(camera.Payload), &payload); err != nil {
+			payload = make(map[string]interface{})
+		}
+	}
+	payload["lines"] = lines
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	camera.Payload = string(payloadJSON)
+	return nil
+}
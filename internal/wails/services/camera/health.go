@@ -0,0 +1,154 @@
+package camera
+
+import (
+	"errors"
+	"jarvist/internal/common/camerahealth"
+	"jarvist/internal/common/residency"
+	"jarvist/internal/wails/services/servicemanager"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultHealthReportMinutes = 15
+	healthReportCheck          = 30 * time.Second
+	healthReportTimeout        = 10 * time.Second
+	healthReportPath           = "/reports/camera-health"
+)
+
+// GetCameraHealthReport returns the health summary as it would be sent now
+func (s *CameraService) GetCameraHealthReport() (camerahealth.Report, error) {
+	return s.buildHealthReport()
+}
+
+// SendCameraHealthReport sends the health summary right away instead of waiting for the schedule
+func (s *CameraService) SendCameraHealthReport() error {
+	if residency.LocalOnly() {
+		return errors.New("camera health reports are not sent in local-only mode")
+	}
+	report, err := s.buildHealthReport()
+	if err != nil {
+		return err
+	}
+	return s.sendHealthReport(report)
+}
+
+// healthReportInterval reads the minutes between reports, invalid values fall back to the default
+func (s *CameraService) healthReportInterval() int {
+	if s.settingService == nil {
+		return defaultHealthReportMinutes
+	}
+	value, err := s.settingService.GetSetting(camerahealth.IntervalKey)
+	if err != nil || value == "" {
+		return defaultHealthReportMinutes
+	}
+	minutes, err := strconv.Atoi(value)
+	if err != nil || minutes < 0 {
+		s.logger.Warn("Invalid %s setting %q, using %d minutes", camerahealth.IntervalKey, value, defaultHealthReportMinutes)
+		return defaultHealthReportMinutes
+	}
+	return minutes
+}
+
+// buildHealthReport combines the cameras with the result of their last connection check.
+// Cameras not checked since the app started are reported as unknown.
+func (s *CameraService) buildHealthReport() (camerahealth.Report, error) {
+	cameras, err := s.ListCamera()
+	if err != nil {
+		return camerahealth.Report{}, err
+	}
+
+	report := camerahealth.Report{
+		GeneratedAt:     time.Now(),
+		IntervalMinutes: s.healthReportInterval(),
+		Cameras:         make([]camerahealth.Camera, 0, len(cameras)),
+	}
+
+	s.statusMutex.RLock()
+	defer s.statusMutex.RUnlock()
+
+	for _, camera := range cameras {
+		health := camerahealth.Camera{
+			CameraUUID: camera.UUID,
+			CameraID:   camera.ID,
+			CameraName: camera.Name,
+			Location:   camera.Location.Name,
+			Status:     camerahealth.StatusUnknown,
+		}
+
+		if status, ok := s.connectionStatuses[camera.UUID]; ok {
+			lastChecked := status.LastChecked
+			health.LastChecked = &lastChecked
+			health.Message = status.StatusMessage
+			health.Error = status.Error
+			if status.IsConnected {
+				health.Status = camerahealth.StatusOnline
+			} else {
+				health.Status = camerahealth.StatusOffline
+			}
+		}
+
+		switch health.Status {
+		case camerahealth.StatusOnline:
+			report.Online++
+		case camerahealth.StatusOffline:
+			report.Offline++
+		default:
+			report.Unknown++
+		}
+		report.Cameras = append(report.Cameras, health)
+	}
+	return report, nil
+}
+
+func (s *CameraService) sendHealthReport(report camerahealth.Report) error {
+	var result struct {
+		MessageID string `json:"message_id"`
+	}
+	if err := servicemanager.SyncApiRequest(s.config, http.MethodPost, healthReportPath, healthReportTimeout, report, &result); err != nil {
+		return err
+	}
+	s.logger.Info("Camera health report sent: %d online, %d offline, %d unknown",
+		report.Online, report.Offline, report.Unknown)
+	return nil
+}
+
+// runHealthReporter sends the camera health summary on the interval of the setting. A
+// changed interval is picked up on the next check.
+func (s *CameraService) runHealthReporter() {
+	ticker := time.NewTicker(healthReportCheck)
+	defer ticker.Stop()
+
+	var lastSent time.Time
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.backgroundCtx.Done():
+			return
+		}
+
+		minutes := s.healthReportInterval()
+		if minutes == 0 || residency.LocalOnly() {
+			continue
+		}
+		if time.Since(lastSent) < time.Duration(minutes)*time.Minute {
+			continue
+		}
+
+		report, err := s.buildHealthReport()
+		if err != nil {
+			s.logger.Error("Failed to build camera health report: %v", err)
+			continue
+		}
+		// Laporan pertama menunggu sampai semua kamera sempat dicek
+		if len(report.Cameras) > 0 && report.Unknown == len(report.Cameras) {
+			continue
+		}
+		if err := s.sendHealthReport(report); err != nil {
+			s.logger.Warn("Failed to send camera health report: %v", err)
+			continue
+		}
+		lastSent = time.Now()
+	}
+}
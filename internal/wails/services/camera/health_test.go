@@ -0,0 +1,54 @@
+package camera
+
+import (
+	"jarvist/internal/common/camerahealth"
+	"jarvist/internal/wails/services/mocks"
+	"testing"
+	"time"
+)
+
+func TestBuildHealthReport(t *testing.T) {
+	s := newTestService(t, mocks.NewHTTPClient(nil), &mocks.Emitter{})
+	online := addCamera(t, s, "gate")
+	offline := addCamera(t, s, "yard")
+	addCamera(t, s, "lobby")
+
+	checked := time.Now().Add(-time.Minute)
+	s.connectionStatuses[online.UUID] = CameraConnectionStatus{CameraUUID: online.UUID, IsConnected: true, LastChecked: checked}
+	s.connectionStatuses[offline.UUID] = CameraConnectionStatus{CameraUUID: offline.UUID, LastChecked: checked, Error: "connection refused"}
+
+	report, err := s.buildHealthReport()
+	if err != nil {
+		t.Fatalf("build report: %v", err)
+	}
+	if report.Online != 1 || report.Offline != 1 || report.Unknown != 1 {
+		t.Fatalf("counts = %d/%d/%d, want 1/1/1", report.Online, report.Offline, report.Unknown)
+	}
+	if report.IntervalMinutes != defaultHealthReportMinutes {
+		t.Errorf("interval = %d, want %d", report.IntervalMinutes, defaultHealthReportMinutes)
+	}
+
+	byName := map[string]camerahealth.Camera{}
+	for _, camera := range report.Cameras {
+		byName[camera.CameraName] = camera
+	}
+	if yard := byName["yard"]; yard.Status != "offline" || yard.Error != "connection refused" || yard.LastChecked == nil {
+		t.Errorf("yard = %+v, want offline with its error", yard)
+	}
+	if lobby := byName["lobby"]; lobby.Status != "unknown" || lobby.LastChecked != nil {
+		t.Errorf("lobby = %+v, want unknown without a check", lobby)
+	}
+}
+
+func TestHealthReportInterval(t *testing.T) {
+	s := newTestService(t, mocks.NewHTTPClient(nil), &mocks.Emitter{})
+
+	for value, want := range map[string]int{"0": 0, "60": 60, "-5": defaultHealthReportMinutes, "soon": defaultHealthReportMinutes} {
+		if err := s.settingService.SaveSetting(camerahealth.IntervalKey, value); err != nil {
+			t.Fatalf("save setting: %v", err)
+		}
+		if got := s.healthReportInterval(); got != want {
+			t.Errorf("interval for %q = %d, want %d", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package camera
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"jarvist/internal/wails/services/processmanager"
+	"jarvist/pkg/utils"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// maxConfigVersions is the number of config versions kept in the history
+	maxConfigVersions = 50
+
+	// maxDiffCells limits the size of the line diff table, larger changes are stored as a full replace
+	maxDiffCells = 4_000_000
+)
+
+// Sources recorded with each config version
+const (
+	ConfigSourceExport   = "export"
+	ConfigSourceRollback = "rollback"
+)
+
+// ConfigVersionSummary is a config version without its full content
+type ConfigVersionSummary struct {
+	ID        uint   `json:"id"`
+	Hash      string `json:"hash"`
+	Diff      string `json:"diff"`
+	Source    string `json:"source"`
+	Cameras   int    `json:"cameras"`
+	CreatedAt string `json:"created_at"`
+}
+
+// GetConfigHistory returns the exported config versions, newest first. The diffs hold the
+// RTSP URLs with their credentials, so the history is only shown while unlocked.
+func (s *CameraService) GetConfigHistory(limit int) ([]ConfigVersionSummary, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > maxConfigVersions {
+		limit = maxConfigVersions
+	}
+
+	var versions []models.ConfigVersion
+	if err := s.DB.Order("id DESC").Limit(limit).Find(&versions).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]ConfigVersionSummary, 0, len(versions))
+	for _, version := range versions {
+		result = append(result, ConfigVersionSummary{
+			ID:        version.ID,
+			Hash:      version.Hash,
+			Diff:      version.Diff,
+			Source:    version.Source,
+			Cameras:   version.Cameras,
+			CreatedAt: version.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	return result, nil
+}
+
+// GetConfigVersion returns the full content of one config version, only while unlocked
+func (s *CameraService) GetConfigVersion(id uint) (*models.ConfigVersion, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return nil, err
+	}
+
+	var version models.ConfigVersion
+	if err := s.DB.First(&version, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("config version not found")
+		}
+		return nil, err
+	}
+	return &version, nil
+}
+
+// RollbackConfig writes an earlier config version back to config.camera.json.
+// The camera table is not changed, so the next camera edit exports from the database again.
+func (s *CameraService) RollbackConfig(id uint) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+
+	version, err := s.GetConfigVersion(id)
+	if err != nil {
+		return err
+	}
+
+	changed, err := s.writeCameraConfig([]byte(version.Content), version.Cameras, ConfigSourceRollback)
+	if err != nil {
+		return err
+	}
+
+	if err := s.exportInstanceContent(context.Background(), []byte(version.Content)); err != nil {
+		return err
+	}
+
+	if changed {
+		s.logger.Info("Rolled back camera config to version %d", id)
+		s.notifyCounter()
+	} else {
+		s.logger.Info("Camera config already matches version %d", id)
+	}
+
+	return nil
+}
+
+// writeCameraConfig writes the config file and records a new version when the content differs
+// from what is on disk. Returns false if the file already had the same content.
+func (s *CameraService) writeCameraConfig(content []byte, cameras int, source string) (bool, error) {
+	s.exportMutex.Lock()
+	defer s.exportMutex.Unlock()
+
+	filePath := filepath.Join(s.config.CameraConfigPath, s.config.CameraConfigName)
+
+	previous, err := os.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to read current config file: %w", err)
+	}
+
+	if bytes.Equal(bytes.TrimSpace(previous), bytes.TrimSpace(content)) {
+		return false, nil
+	}
+
+	if err := utils.WriteFileAtomic(filePath, content, 0644); err != nil {
+		return false, fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	s.recordConfigVersion(string(previous), string(content), cameras, source)
+
+	return true, nil
+}
+
+// recordConfigVersion stores the new content and its diff against the previous file.
+// History failures are logged only, the config file itself is already written.
+func (s *CameraService) recordConfigVersion(previous, content string, cameras int, source string) {
+	sum := sha256.Sum256([]byte(content))
+
+	version := models.ConfigVersion{
+		Hash:    hex.EncodeToString(sum[:]),
+		Content: content,
+		Diff:    lineDiff(previous, content),
+		Source:  source,
+		Cameras: cameras,
+	}
+
+	if err := s.DB.Create(&version).Error; err != nil {
+		s.logger.Warn("Failed to record camera config version: %v", err)
+		return
+	}
+
+	// Hapus versi lama di luar batas riwayat
+	s.DB.Where("id <= ?", version.ID-maxConfigVersions).Delete(&models.ConfigVersion{})
+}
+
+// notifyCounter asks the default people counter to reload the camera config. Counter
+// instances read their own config and are notified by exportInstanceConfigs.
+func (s *CameraService) notifyCounter() {
+	if len(s.process.GetCounterInstances()) > 0 {
+		return
+	}
+	filePath := filepath.Join(s.config.CameraConfigPath, s.config.CameraConfigName)
+	s.process.RequestReload(processmanager.CounterProcess, "camera config changed", filePath)
+}
+
+// lineDiff returns the changed lines between two texts, prefixed with "-" and "+"
+func lineDiff(oldText, newText string) string {
+	var oldLines, newLines []string
+	if oldText != "" {
+		oldLines = strings.Split(strings.TrimRight(oldText, "\n"), "\n")
+	}
+	if newText != "" {
+		newLines = strings.Split(strings.TrimRight(newText, "\n"), "\n")
+	}
+
+	var diff strings.Builder
+
+	if len(oldLines)*len(newLines) > maxDiffCells {
+		for _, line := range oldLines {
+			diff.WriteString("-" + line + "\n")
+		}
+		for _, line := range newLines {
+			diff.WriteString("+" + line + "\n")
+		}
+		return diff.String()
+	}
+
+	// Tabel LCS: lcs[i][j] = panjang subsequence terpanjang dari oldLines[i:] dan newLines[j:]
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(oldLines) && j < len(newLines) {
+		switch {
+		case oldLines[i] == newLines[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff.WriteString("-" + oldLines[i] + "\n")
+			i++
+		default:
+			diff.WriteString("+" + newLines[j] + "\n")
+			j++
+		}
+	}
+	for ; i < len(oldLines); i++ {
+		diff.WriteString("-" + oldLines[i] + "\n")
+	}
+	for ; j < len(newLines); j++ {
+		diff.WriteString("+" + newLines[j] + "\n")
+	}
+
+	return diff.String()
+}
@@ -0,0 +1,94 @@
+package camera
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"jarvist/internal/common/models"
+	"jarvist/internal/wails/services/processmanager"
+	"jarvist/pkg/utils"
+	"os"
+	"slices"
+)
+
+// exportInstanceConfigs writes the camera subset of every counter instance to its own config
+// file and asks the instances whose file changed to reload. The full config stays the
+// versioned source, instance files are always derived from it.
+func (s *CameraService) exportInstanceConfigs(ctx context.Context, full models.CameraConfig) error {
+	instances := s.process.GetCounterInstances()
+	if len(instances) == 0 {
+		return nil
+	}
+
+	s.exportMutex.Lock()
+	defer s.exportMutex.Unlock()
+
+	assigned := make(map[uint]bool)
+
+	for _, instance := range instances {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		subset := full
+		subset.CONFIG = make([]models.Config, 0, len(instance.Cameras))
+		for _, config := range full.CONFIG {
+			if slices.Contains(instance.Cameras, config.ID) {
+				subset.CONFIG = append(subset.CONFIG, config)
+				assigned[config.ID] = true
+			}
+		}
+		subset.CCTV_NUMBER = len(subset.CONFIG)
+
+		if missing := len(instance.Cameras) - len(subset.CONFIG); missing > 0 {
+			s.logger.Warn("Counter instance %s lists %d cameras that are not in the camera config", instance.Name, missing)
+		}
+
+		content, err := json.MarshalIndent(subset, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal config of instance %s: %w", instance.Name, err)
+		}
+
+		filePath, err := s.process.CounterInstanceConfigFile(instance.Name)
+		if err != nil {
+			return err
+		}
+
+		previous, err := os.ReadFile(filePath)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read config of instance %s: %w", instance.Name, err)
+		}
+		if bytes.Equal(bytes.TrimSpace(previous), bytes.TrimSpace(content)) {
+			continue
+		}
+
+		if err := utils.WriteFileAtomic(filePath, content, 0644); err != nil {
+			return fmt.Errorf("failed to write config of instance %s: %w", instance.Name, err)
+		}
+
+		s.logger.Info("Exported %d cameras for counter instance %s to %s", subset.CCTV_NUMBER, instance.Name, filePath)
+		s.process.RequestReload(processmanager.InstanceProcessId(instance.Name), "camera config changed", filePath)
+	}
+
+	for _, config := range full.CONFIG {
+		if !assigned[config.ID] {
+			s.logger.Warn("Camera %d is not assigned to any counter instance and will not be counted", config.ID)
+		}
+	}
+
+	return nil
+}
+
+// exportInstanceContent derives the instance configs from the content of a config file
+func (s *CameraService) exportInstanceContent(ctx context.Context, content []byte) error {
+	if len(s.process.GetCounterInstances()) == 0 {
+		return nil
+	}
+
+	var full models.CameraConfig
+	if err := json.Unmarshal(content, &full); err != nil {
+		return fmt.Errorf("failed to parse camera config: %w", err)
+	}
+	return s.exportInstanceConfigs(ctx, full)
+}
@@ -0,0 +1,207 @@
+package camera
+
+import (
+	"errors"
+	"jarvist/internal/common/models"
+	"jarvist/internal/common/residency"
+	"math/rand"
+	"net"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Retry schedule of failed camera syncs
+const (
+	syncRetryCheck    = 15 * time.Second
+	syncRetryMinDelay = 30 * time.Second
+	syncRetryMaxDelay = 30 * time.Minute
+	syncProbeTimeout  = 5 * time.Second
+)
+
+// CameraSyncStatus tells whether the server has the latest cameras
+type CameraSyncStatus struct {
+	Pending       bool       `json:"pending"`
+	PendingSince  *time.Time `json:"pending_since,omitempty"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	LastSyncedAt  *time.Time `json:"last_synced_at,omitempty"`
+	Cameras       int64      `json:"cameras"` // Cameras the server confirmed
+}
+
+// GetCameraSyncStatus returns the pending camera sync, if any, and the last confirmed sync
+func (s *CameraService) GetCameraSyncStatus() (CameraSyncStatus, error) {
+	var status CameraSyncStatus
+
+	var pending models.CameraSyncOutbox
+	result := s.DB.Order("id").Limit(1).Find(&pending)
+	if result.Error != nil {
+		return status, result.Error
+	}
+	if result.RowsAffected > 0 {
+		status.Pending = true
+		status.PendingSince = &pending.CreatedAt
+		status.Attempts = pending.Attempts
+		status.LastError = pending.LastError
+		status.NextAttemptAt = &pending.NextAttemptAt
+	}
+
+	if err := s.DB.Model(&models.CameraSyncState{}).Count(&status.Cameras).Error; err != nil {
+		return status, err
+	}
+	var last models.CameraSyncState
+	if result := s.DB.Order("synced_at DESC").Limit(1).Find(&last); result.Error != nil {
+		return status, result.Error
+	} else if result.RowsAffected > 0 {
+		status.LastSyncedAt = &last.SyncedAt
+	}
+
+	return status, nil
+}
+
+// RetryCameraSync retries a pending camera sync now instead of waiting for the backoff
+func (s *CameraService) RetryCameraSync() (CameraSyncStatus, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return CameraSyncStatus{}, err
+	}
+
+	if err := s.syncCameras(); err != nil {
+		s.queueCameraSync(err)
+	} else {
+		s.clearCameraSyncQueue()
+	}
+	return s.GetCameraSyncStatus()
+}
+
+// queueCameraSync records a failed sync in the outbox. Repeated failures update the pending
+// entry, every sync sends the current cameras so one entry is enough.
+func (s *CameraService) queueCameraSync(cause error) {
+	now := time.Now()
+
+	var pending models.CameraSyncOutbox
+	err := s.DB.Order("id").First(&pending).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		pending = models.CameraSyncOutbox{CreatedAt: now}
+	} else if err != nil {
+		s.logger.Warn("Failed to read camera sync outbox: %v", err)
+		return
+	}
+
+	pending.Attempts++
+	pending.LastError = cause.Error()
+	pending.NextAttemptAt = now.Add(syncRetryDelay(pending.Attempts))
+	if err := s.DB.Save(&pending).Error; err != nil {
+		s.logger.Warn("Failed to queue camera sync for retry: %v", err)
+		return
+	}
+
+	s.logger.Info("Camera sync queued for retry at %s (attempt %d)", pending.NextAttemptAt.Format(time.RFC3339), pending.Attempts)
+	s.emitCameraSyncStatus()
+}
+
+// clearCameraSyncQueue removes the pending entry after a successful sync
+func (s *CameraService) clearCameraSyncQueue() {
+	result := s.DB.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&models.CameraSyncOutbox{})
+	if result.Error != nil {
+		s.logger.Warn("Failed to clear camera sync outbox: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		s.logger.Info("Pending camera sync completed")
+		s.emitCameraSyncStatus()
+	}
+}
+
+// runSyncRetry retries a pending camera sync when its backoff has passed, or right away
+// when the server becomes reachable again
+func (s *CameraService) runSyncRetry() {
+	ticker := time.NewTicker(syncRetryCheck)
+	defer ticker.Stop()
+
+	reachable := true
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.backgroundCtx.Done():
+			return
+		}
+
+		// Sinkronisasi yang tertunda menunggu sampai mode local-only dimatikan
+		if residency.LocalOnly() {
+			continue
+		}
+
+		var pending models.CameraSyncOutbox
+		if result := s.DB.Order("id").Limit(1).Find(&pending); result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		wasReachable := reachable
+		reachable = s.apiReachable()
+		if !reachable {
+			continue
+		}
+		if wasReachable && time.Now().Before(pending.NextAttemptAt) {
+			continue
+		}
+
+		if !wasReachable {
+			s.logger.Info("Server reachable again, retrying pending camera sync")
+		}
+		if err := s.syncCameras(); err != nil {
+			s.logger.Error("Camera sync retry failed: %v", err)
+			s.queueCameraSync(err)
+			continue
+		}
+		s.clearCameraSyncQueue()
+	}
+}
+
+// apiReachable checks that a connection to the API server can be opened
+func (s *CameraService) apiReachable() bool {
+	u, err := url.Parse(s.config.ApiUrl)
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(u.Hostname(), port), syncProbeTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+func (s *CameraService) emitCameraSyncStatus() {
+	if s.events == nil {
+		return
+	}
+	status, err := s.GetCameraSyncStatus()
+	if err != nil {
+		return
+	}
+	s.emit("camera:sync-status", status)
+}
+
+// syncRetryDelay doubles the delay per attempt up to syncRetryMaxDelay, with ±20% jitter
+func syncRetryDelay(attempts int) time.Duration {
+	delay := syncRetryMinDelay
+	for i := 1; i < attempts && delay < syncRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > syncRetryMaxDelay {
+		delay = syncRetryMaxDelay
+	}
+	jitter := 1.0 + (rand.Float64()*2-1)*0.2
+	return time.Duration(float64(delay) * jitter)
+}
@@ -0,0 +1,112 @@
+package camera
+
+import (
+	"encoding/json"
+	"fmt"
+	"jarvist/internal/common/ffmpeg"
+	"jarvist/internal/common/models"
+	"time"
+)
+
+// GetProbeOptions returns the RTSP probe options used for all cameras
+func (s *CameraService) GetProbeOptions() ffmpeg.RTSPOptions {
+	return s.globalProbeOptions()
+}
+
+// SaveProbeOptions saves the RTSP probe options used for all cameras
+func (s *CameraService) SaveProbeOptions(options ffmpeg.RTSPOptions) error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+	options.TakeScreenshot = false
+
+	data, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	if err := s.settingService.SaveSetting(ffmpeg.ProbeOptionsKey, string(data)); err != nil {
+		return fmt.Errorf("failed to save probe options: %w", err)
+	}
+
+	s.logger.Info("RTSP probe options updated: %s", string(data))
+	return nil
+}
+
+// GetCameraProbeOptions returns the probe options set for one camera, empty fields use
+// the global options
+func (s *CameraService) GetCameraProbeOptions(id uint) (ffmpeg.RTSPOptions, error) {
+	camera, err := s.GetCameraByID(id)
+	if err != nil {
+		return ffmpeg.RTSPOptions{}, err
+	}
+	return cameraProbeOptions(camera), nil
+}
+
+// SaveCameraProbeOptions sets the probe options of one camera, empty options fall back
+// to the global options
+func (s *CameraService) SaveCameraProbeOptions(id uint, options ffmpeg.RTSPOptions) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+	if err := options.Validate(); err != nil {
+		return err
+	}
+	options.TakeScreenshot = false
+
+	value := ""
+	if options != (ffmpeg.RTSPOptions{}) {
+		data, err := json.Marshal(options)
+		if err != nil {
+			return err
+		}
+		value = string(data)
+	}
+
+	if err := s.DB.Model(&models.Camera{}).Where("id = ?", id).Update("probe_options", value).Error; err != nil {
+		return fmt.Errorf("failed to save probe options: %w", err)
+	}
+
+	s.logger.Info("RTSP probe options of camera %d updated: %s", id, value)
+	return nil
+}
+
+// globalProbeOptions reads the global probe options, invalid JSON falls back to the defaults
+func (s *CameraService) globalProbeOptions() ffmpeg.RTSPOptions {
+	var options ffmpeg.RTSPOptions
+	if s.settingService == nil {
+		return options
+	}
+
+	value, err := s.settingService.GetSetting(ffmpeg.ProbeOptionsKey)
+	if err != nil || value == "" {
+		return options
+	}
+	if err := json.Unmarshal([]byte(value), &options); err != nil {
+		s.logger.Warn("Invalid RTSP probe options in settings, using defaults: %v", err)
+		return ffmpeg.RTSPOptions{}
+	}
+	return options
+}
+
+// probeOptions returns the options a camera is checked with
+func (s *CameraService) probeOptions(camera *models.Camera) ffmpeg.RTSPOptions {
+	return s.globalProbeOptions().Merge(cameraProbeOptions(camera))
+}
+
+func cameraProbeOptions(camera *models.Camera) ffmpeg.RTSPOptions {
+	var options ffmpeg.RTSPOptions
+	if camera.ProbeOptions != "" {
+		json.Unmarshal([]byte(camera.ProbeOptions), &options)
+	}
+	return options
+}
+
+// probeCallTimeout is the guard timeout of a probe, FFmpeg stops itself after the read
+// timeout and a screenshot runs FFmpeg a second time
+func probeCallTimeout(options ffmpeg.RTSPOptions) time.Duration {
+	timeout := time.Duration(options.Defaults().ReadTimeoutSec) * time.Second
+	if options.TakeScreenshot {
+		timeout *= 2
+	}
+	return timeout + 15*time.Second
+}
@@ -0,0 +1,304 @@
+package camera
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RebindAuditAction is the action name used for camera rebinds in the audit table
+const RebindAuditAction = "camera.rebind"
+
+// RebindCandidate is a deleted camera whose UUID and history a re-added camera can take over
+type RebindCandidate struct {
+	ID         uint   `json:"id"`
+	UUID       string `json:"uuid,omitempty"` // Empty when only data records of the camera are left
+	Name       string `json:"name,omitempty"`
+	LocationID string `json:"location_id,omitempty"`
+	DeletedAt  string `json:"deleted_at,omitempty"`
+	Records    int64  `json:"records"`
+	// SameEndpoint is set when the deleted camera read the same stream as the new camera
+	SameEndpoint bool `json:"same_endpoint"`
+	// Orphan is set for data records of a camera deleted before deletes were kept, only its
+	// history can be carried forward
+	Orphan bool `json:"orphan"`
+}
+
+// CameraRebindResult describes what a rebind carried forward
+type CameraRebindResult struct {
+	CameraID  uint     `json:"camera_id"` // ID the camera has after the rebind
+	UUID      string   `json:"uuid"`
+	RemovedID uint     `json:"removed_id,omitempty"` // ID given to the camera when it was re-added
+	Moved     int64    `json:"records_moved"`
+	Instances []string `json:"instances,omitempty"` // Counter instances that listed the re-added camera
+}
+
+// FindRebindCandidates returns the deleted cameras a newly added camera can be rebound to,
+// the ones reading the same stream first
+func (s *CameraService) FindRebindCandidates(newID uint) ([]RebindCandidate, error) {
+	var camera models.Camera
+	if err := s.DB.Where("deleted_at IS NULL").First(&camera, newID).Error; err != nil {
+		return nil, fmt.Errorf("camera %d not found: %w", newID, err)
+	}
+	key := endpointKey(camera)
+
+	var deleted []models.Camera
+	if err := s.DB.Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&deleted).Error; err != nil {
+		return nil, err
+	}
+
+	candidates := []RebindCandidate{}
+	for _, old := range deleted {
+		candidate := RebindCandidate{
+			ID:           old.ID,
+			UUID:         old.UUID,
+			Name:         old.Name,
+			LocationID:   old.LocationID,
+			SameEndpoint: key != "" && endpointKey(old) == key,
+		}
+		if old.DeletedAt != nil {
+			candidate.DeletedAt = *old.DeletedAt
+		}
+		s.DB.Model(&models.DataRecord{}).Where("cctv_id = ?", old.ID).Count(&candidate.Records)
+		candidates = append(candidates, candidate)
+	}
+
+	// Kamera yang dihapus permanen sebelumnya hanya menyisakan data record
+	var orphans []struct {
+		CctvID  uint
+		Records int64
+	}
+	err := s.DB.Model(&models.DataRecord{}).
+		Select("cctv_id, COUNT(*) AS records").
+		Where("cctv_id NOT IN (?)", s.DB.Model(&models.Camera{}).Select("id")).
+		Group("cctv_id").
+		Scan(&orphans).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, orphan := range orphans {
+		candidates = append(candidates, RebindCandidate{ID: orphan.CctvID, Records: orphan.Records, Orphan: true})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].SameEndpoint && !candidates[j].SameEndpoint
+	})
+	return candidates, nil
+}
+
+// RebindCamera gives a re-added camera the UUID and history of a deleted camera: the deleted
+// camera is restored with the settings of the new one and the new camera is removed, so the
+// camera keeps reporting under its old ID and UUID. For data records of a camera without row
+// only the records are moved onto the new camera.
+func (s *CameraService) RebindCamera(newID, deletedID uint) (CameraRebindResult, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return CameraRebindResult{}, err
+	}
+	if newID == deletedID {
+		return CameraRebindResult{}, errors.New("a camera cannot be rebound to itself")
+	}
+
+	var camera models.Camera
+	if err := s.DB.Where("deleted_at IS NULL").First(&camera, newID).Error; err != nil {
+		return CameraRebindResult{}, fmt.Errorf("camera %d not found: %w", newID, err)
+	}
+
+	var old models.Camera
+	err := s.DB.First(&old, deletedID).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return s.adoptHistory(camera, deletedID)
+	case err != nil:
+		return CameraRebindResult{}, err
+	case old.DeletedAt == nil:
+		return CameraRebindResult{}, fmt.Errorf("camera %d is not deleted, merge duplicates instead", deletedID)
+	}
+
+	result := CameraRebindResult{CameraID: old.ID, UUID: old.UUID, RemovedID: camera.ID}
+
+	// Pengaturan diambil dari kamera baru, ID dan UUID dari kamera lama
+	restored := camera
+	restored.ID = old.ID
+	restored.UUID = old.UUID
+	restored.CreatedAt = old.CreatedAt
+	restored.DeletedAt = nil
+	restored.Location = models.Location{}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&camera).Error; err != nil {
+			return err
+		}
+		if err := tx.Select("*").Omit("Location").Save(&restored).Error; err != nil {
+			return err
+		}
+
+		moved := tx.Model(&models.DataRecord{}).Where("cctv_id = ?", camera.ID).Update("cctv_id", old.ID)
+		if moved.Error != nil {
+			return fmt.Errorf("failed to move data records: %w", moved.Error)
+		}
+		result.Moved = moved.RowsAffected
+
+		if err := moveLifetime(tx, int(camera.ID), int(old.ID), nil); err != nil {
+			return err
+		}
+
+		var snapshots int64
+		tx.Model(&models.CameraSnapshot{}).Where("camera_uuid = ?", camera.UUID).Count(&snapshots)
+		if snapshots > 0 {
+			if err := tx.Where("camera_uuid = ?", old.UUID).Delete(&models.CameraSnapshot{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.CameraSnapshot{}).Where("camera_uuid = ?", camera.UUID).
+				Update("camera_uuid", old.UUID).Error; err != nil {
+				return err
+			}
+		}
+
+		return s.auditRebind(tx, result)
+	})
+	if err != nil {
+		return CameraRebindResult{}, err
+	}
+
+	result.Instances = s.reassignInstances(old.ID, camera.ID)
+
+	s.statusMutex.Lock()
+	if status, ok := s.connectionStatuses[camera.UUID]; ok {
+		s.connectionStatuses[old.UUID] = status
+		delete(s.connectionStatuses, camera.UUID)
+	}
+	s.statusMutex.Unlock()
+
+	s.logger.Info("Rebound camera %d (%s) to deleted camera %d, UUID %s kept, %d records moved",
+		camera.ID, camera.Name, old.ID, old.UUID, result.Moved)
+
+	s.autoExportConfig()
+	s.syncCamerasAsync()
+	return result, nil
+}
+
+// adoptHistory moves the data records of a camera deleted without a row onto a camera
+func (s *CameraService) adoptHistory(camera models.Camera, cctvID uint) (CameraRebindResult, error) {
+	result := CameraRebindResult{CameraID: camera.ID, UUID: camera.UUID}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := moveLifetime(tx, int(cctvID), int(camera.ID), nil); err != nil {
+			return err
+		}
+
+		counted := tx.Model(&models.DataRecord{}).Select("DISTINCT date_folder").Where("cctv_id = ?", camera.ID)
+		moved := tx.Model(&models.DataRecord{}).
+			Where("cctv_id = ? AND date_folder NOT IN (?)", cctvID, counted).
+			Update("cctv_id", camera.ID)
+		if moved.Error != nil {
+			return fmt.Errorf("failed to move data records: %w", moved.Error)
+		}
+		if moved.RowsAffected == 0 {
+			return fmt.Errorf("no data records of camera %d to carry forward", cctvID)
+		}
+		result.Moved = moved.RowsAffected
+
+		return s.auditRebind(tx, result)
+	})
+	if err != nil {
+		return CameraRebindResult{}, err
+	}
+
+	s.logger.Info("Moved %d data records of deleted camera %d to camera %d (%s)", result.Moved, cctvID, camera.ID, camera.Name)
+	return result, nil
+}
+
+// suggestRebind reports to the UI that a created camera reads the same stream as a deleted
+// camera, the installer most likely re-added it
+func (s *CameraService) suggestRebind(camera *models.Camera) {
+	key := endpointKey(*camera)
+	if key == "" {
+		return
+	}
+
+	var deleted []models.Camera
+	if err := s.DB.Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&deleted).Error; err != nil {
+		return
+	}
+
+	for _, old := range deleted {
+		if endpointKey(old) != key {
+			continue
+		}
+
+		s.logger.Info("Camera %d (%s) reads the same stream as deleted camera %d (%s), rebind it to keep its history",
+			camera.ID, camera.Name, old.ID, old.Name)
+		s.emit("camera:rebind-available", map[string]interface{}{
+			"camera_id":  camera.ID,
+			"deleted_id": old.ID,
+			"uuid":       old.UUID,
+			"name":       old.Name,
+		})
+		return
+	}
+}
+
+// lifetimeCounts are counts moved between the lifetime totals of two cameras
+type lifetimeCounts struct {
+	InTotal  int64
+	OutTotal int64
+	Records  int64
+}
+
+// moveLifetime carries lifetime totals from one camera to another together with its data
+// records, so the totals since install stay with the camera. counts nil moves all of them.
+func moveLifetime(tx *gorm.DB, from, to int, counts *lifetimeCounts) error {
+	var source models.LifetimeCounter
+	if err := tx.Where("cctv_id = ?", from).Limit(1).Find(&source).Error; err != nil || source.CCTVID == 0 {
+		return err
+	}
+	if counts == nil {
+		counts = &lifetimeCounts{InTotal: source.InTotal, OutTotal: source.OutTotal, Records: source.Records}
+	}
+
+	var target models.LifetimeCounter
+	if err := tx.Where("cctv_id = ?", to).Limit(1).Find(&target).Error; err != nil {
+		return err
+	}
+	if target.CCTVID == 0 {
+		target = models.LifetimeCounter{CCTVID: to, FirstAt: source.FirstAt}
+	}
+	if source.FirstAt.Before(target.FirstAt) {
+		target.FirstAt = source.FirstAt
+	}
+	target.InTotal += counts.InTotal
+	target.OutTotal += counts.OutTotal
+	target.Records += counts.Records
+	target.UpdatedAt = time.Now()
+	if err := tx.Save(&target).Error; err != nil {
+		return err
+	}
+
+	source.InTotal -= counts.InTotal
+	source.OutTotal -= counts.OutTotal
+	source.Records -= counts.Records
+	if source.Records <= 0 && source.InTotal == 0 && source.OutTotal == 0 {
+		return tx.Delete(&source).Error
+	}
+	source.UpdatedAt = time.Now()
+	return tx.Save(&source).Error
+}
+
+func (s *CameraService) auditRebind(tx *gorm.DB, result CameraRebindResult) error {
+	detail, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.AuditEntry{
+		Timestamp: time.Now(),
+		Action:    RebindAuditAction,
+		Actor:     "admin",
+		Source:    "desktop",
+		Detail:    string(detail),
+	}).Error
+}
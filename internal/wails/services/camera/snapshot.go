@@ -0,0 +1,107 @@
+package camera
+
+import (
+	"context"
+	"jarvist/internal/common/ffmpeg"
+	"jarvist/internal/common/models"
+	"jarvist/internal/common/snapshot"
+	"jarvist/internal/wails/services/servicemanager"
+	"net/http"
+	"time"
+)
+
+// alertTimeout limits the call to the sync service that publishes a camera alert
+const alertTimeout = 10 * time.Second
+
+// cameraAlert is a camera status change sent to the sync service, which publishes it over
+// MQTT with the last known frame
+type cameraAlert struct {
+	CameraUUID string     `json:"camera_uuid"`
+	CameraID   uint       `json:"camera_id"`
+	CameraName string     `json:"camera_name"`
+	Status     string     `json:"status"`
+	Message    string     `json:"message,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	LastOnline *time.Time `json:"last_online,omitempty"`
+}
+
+// GetSnapshotPolicy returns whether and how thumbnails are attached to camera offline alerts
+func (s *CameraService) GetSnapshotPolicy() snapshot.Policy {
+	return snapshot.LoadPolicy(s.DB)
+}
+
+// SaveSnapshotPolicy stores the snapshot policy. Disabling it or excluding cameras deletes
+// their stored thumbnails.
+func (s *CameraService) SaveSnapshotPolicy(policy snapshot.Policy) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+	if policy.ExcludedCameras == nil {
+		policy.ExcludedCameras = []string{}
+	}
+
+	if err := snapshot.SavePolicy(s.DB, policy); err != nil {
+		return err
+	}
+
+	s.logger.Info("Camera snapshot policy saved (enabled: %v, mode: %s, %d cameras excluded)",
+		policy.Enabled, policy.Mode, len(policy.ExcludedCameras))
+	return nil
+}
+
+// refreshSnapshot captures a new thumbnail of an online camera once the stored one is older
+// than the refresh period of the policy
+func (s *CameraService) refreshSnapshot(ctx context.Context, camera *models.Camera, rtspConfig ffmpeg.RTSPConfig, options ffmpeg.RTSPOptions) {
+	policy := snapshot.LoadPolicy(s.DB)
+	if !policy.Allows(camera.UUID) {
+		return
+	}
+	if time.Since(snapshot.CapturedAt(s.DB, camera.UUID)) < time.Duration(policy.RefreshMin)*time.Minute {
+		return
+	}
+
+	data, err := ffmpeg.CaptureThumbnail(ctx, rtspConfig, options, policy.Width)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Warn("Failed to capture snapshot of camera %s: %v", camera.Name, err)
+		}
+		return
+	}
+	if len(data) > policy.MaxBytes {
+		s.logger.Warn("Snapshot of camera %s is %d bytes, over the limit of %d, lower the width",
+			camera.Name, len(data), policy.MaxBytes)
+		return
+	}
+
+	if err := snapshot.Store(s.DB, camera.UUID, data, time.Now()); err != nil {
+		s.logger.Error("Failed to store snapshot of camera %s: %v", camera.Name, err)
+	}
+}
+
+// reportStatusChange sends a camera alert to the sync service in the background
+func (s *CameraService) reportStatusChange(camera *models.Camera, status, previous CameraConnectionStatus) {
+	alert := cameraAlert{
+		CameraUUID: camera.UUID,
+		CameraID:   camera.ID,
+		CameraName: camera.Name,
+		Status:     camera.Status,
+		Message:    status.StatusMessage,
+		Error:      status.Error,
+	}
+	if previous.IsConnected {
+		lastOnline := previous.LastChecked
+		alert.LastOnline = &lastOnline
+	}
+
+	go func() {
+		var result struct {
+			Snapshot bool `json:"snapshot"`
+		}
+		err := servicemanager.SyncApiRequest(s.config, http.MethodPost, "/alerts/camera", alertTimeout, alert, &result)
+		if err != nil {
+			s.logger.Warn("Failed to send %s alert of camera %s: %v", alert.Status, alert.CameraName, err)
+			return
+		}
+		s.logger.Info("Camera %s %s alert sent (snapshot attached: %v)", alert.CameraName, alert.Status, result.Snapshot)
+	}()
+}
@@ -0,0 +1,584 @@
+package camera
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"jarvist/internal/common/models"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	CameraFormatCSV  = "csv"
+	CameraFormatJSON = "json"
+
+	// ImportAuditAction is the action name used for camera imports in the audit table
+	ImportAuditAction = "camera.import"
+
+	// maxCameraImportSize bounds an import file, a camera row is well below 1 KB
+	maxCameraImportSize = 5 * 1024 * 1024
+	// maxCameraImportRows bounds the cameras created by one import
+	maxCameraImportRows = 1000
+)
+
+// cameraColumns are the columns of a CSV file, lines holds the counting lines as JSON
+var cameraColumns = []string{
+	"name", "location", "location_name", "schema", "host", "port", "path",
+	"username", "password", "direction", "description", "tags", "lines",
+}
+
+// lineDirections are the directions a camera and its counting lines can count in
+var lineDirections = []string{"ltr", "rtl", "ttb", "btt"}
+
+// CameraRecord is a camera in an import or export file. Location is the ID, remote ID or
+// name of the location, LocationName is used when Location is empty.
+type CameraRecord struct {
+	Name         string            `json:"name"`
+	Location     string            `json:"location"`
+	LocationName string            `json:"location_name,omitempty"`
+	Schema       string            `json:"schema"`
+	Host         string            `json:"host"`
+	Port         int               `json:"port"`
+	Path         string            `json:"path"`
+	Username     string            `json:"username,omitempty"`
+	Password     string            `json:"password,omitempty"`
+	Direction    string            `json:"direction,omitempty"`
+	Description  string            `json:"description,omitempty"`
+	Tags         string            `json:"tags,omitempty"`
+	Lines        []models.LineData `json:"lines"`
+}
+
+// CameraExport is the JSON file written by ExportCameras and read by ImportCameras
+type CameraExport struct {
+	ExportedAt time.Time      `json:"exported_at"`
+	Cameras    []CameraRecord `json:"cameras"`
+}
+
+// CameraImportError is a problem with one row of an import, Row counts from 1 for JSON and is
+// the line number for CSV
+type CameraImportError struct {
+	Row     int    `json:"row"`
+	Name    string `json:"name,omitempty"`
+	Message string `json:"message"`
+}
+
+// CameraImportReport is the result of a camera import, nothing is stored when it has errors
+type CameraImportReport struct {
+	Source     string              `json:"source,omitempty"`
+	Format     string              `json:"format"`
+	DryRun     bool                `json:"dry_run"`
+	Rows       int                 `json:"rows"`
+	Created    int                 `json:"created"`
+	Cameras    []uint              `json:"cameras"`
+	ErrorCount int                 `json:"error_count"`
+	Errors     []CameraImportError `json:"errors,omitempty"`
+}
+
+// importRow is a parsed row with the line or index it came from
+type importRow struct {
+	row    int
+	record CameraRecord
+	err    error
+}
+
+// ImportCameras creates the cameras of a CSV or JSON file, the format follows the file
+// extension. Every row is validated and its location resolved first; the cameras are only
+// created, in one transaction, when no row has an error. With dryRun the file is only
+// validated.
+func (s *CameraService) ImportCameras(path string, dryRun bool) (CameraImportReport, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return CameraImportReport{}, err
+	}
+
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if format != CameraFormatCSV && format != CameraFormatJSON {
+		return CameraImportReport{}, fmt.Errorf("unsupported file %s, use a .csv or .json file", filepath.Base(path))
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return CameraImportReport{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if info.Size() > maxCameraImportSize {
+		return CameraImportReport{}, fmt.Errorf("%s is larger than %d MB", filepath.Base(path), maxCameraImportSize/1024/1024)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return CameraImportReport{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return s.importCameras(data, format, filepath.Base(path), dryRun)
+}
+
+// ImportCamerasData imports cameras like ImportCameras from the content of a file, used by the
+// local API where the file is uploaded
+func (s *CameraService) ImportCamerasData(data []byte, format string, dryRun bool) (CameraImportReport, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return CameraImportReport{}, err
+	}
+	if len(data) > maxCameraImportSize {
+		return CameraImportReport{}, fmt.Errorf("import is larger than %d MB", maxCameraImportSize/1024/1024)
+	}
+	return s.importCameras(data, strings.ToLower(format), "", dryRun)
+}
+
+func (s *CameraService) importCameras(data []byte, format, source string, dryRun bool) (CameraImportReport, error) {
+	var rows []importRow
+	var err error
+	switch format {
+	case CameraFormatCSV:
+		rows, err = parseCameraCSV(data)
+	case CameraFormatJSON:
+		rows, err = parseCameraJSON(data)
+	default:
+		return CameraImportReport{}, fmt.Errorf("unsupported format %q, use csv or json", format)
+	}
+	if err != nil {
+		return CameraImportReport{}, err
+	}
+	if len(rows) == 0 {
+		return CameraImportReport{}, errors.New("the file contains no cameras")
+	}
+	if len(rows) > maxCameraImportRows {
+		return CameraImportReport{}, fmt.Errorf("the file contains %d cameras, import at most %d at once", len(rows), maxCameraImportRows)
+	}
+
+	report := CameraImportReport{Source: source, Format: format, DryRun: dryRun, Rows: len(rows), Cameras: []uint{}}
+
+	cameras, err := s.checkImport(rows, &report)
+	if err != nil {
+		return CameraImportReport{}, err
+	}
+	report.ErrorCount = len(report.Errors)
+	if report.ErrorCount > 0 || dryRun {
+		return report, nil
+	}
+
+	if err := s.storeImport(cameras, &report); err != nil {
+		return CameraImportReport{}, err
+	}
+
+	s.logger.Info("Imported %d cameras from %s", report.Created, importSource(source, format))
+	for i := range cameras {
+		if s.backgroundRunning {
+			go s.checkCameraConnection(context.Background(), &cameras[i])
+		}
+		s.suggestRebind(&cameras[i])
+	}
+	s.autoExportConfig()
+	s.syncCamerasAsync()
+	return report, nil
+}
+
+func importSource(source, format string) string {
+	if source == "" {
+		return "an uploaded " + format + " file"
+	}
+	return source
+}
+
+// checkImport validates the rows and resolves their locations, problems are added to the
+// report. It returns the cameras to create, used only when the report has no errors.
+func (s *CameraService) checkImport(rows []importRow, report *CameraImportReport) ([]models.Camera, error) {
+	var locations []models.Location
+	if err := s.DB.Where("deleted_at IS NULL").Find(&locations).Error; err != nil {
+		return nil, fmt.Errorf("failed to load locations: %w", err)
+	}
+	index := newLocationIndex(locations)
+
+	existing, err := s.ListCamera()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cameras: %w", err)
+	}
+	endpoints := make(map[string]string, len(existing))
+	for _, camera := range existing {
+		if key := endpointKey(camera); key != "" {
+			endpoints[key] = camera.Name
+		}
+	}
+	seen := make(map[string]int)
+
+	cameras := make([]models.Camera, 0, len(rows))
+	for _, row := range rows {
+		record := row.record
+		fail := func(err error) {
+			report.Errors = append(report.Errors, CameraImportError{Row: row.row, Name: record.Name, Message: err.Error()})
+		}
+
+		if row.err != nil {
+			fail(row.err)
+			continue
+		}
+		camera, err := recordCamera(record, index)
+		if err != nil {
+			fail(err)
+			continue
+		}
+
+		if key := endpointKey(camera); key != "" {
+			if name, ok := endpoints[key]; ok {
+				fail(fmt.Errorf("camera %q already reads this stream", name))
+				continue
+			}
+			if other, ok := seen[key]; ok {
+				fail(fmt.Errorf("row %d reads the same stream", other))
+				continue
+			}
+			seen[key] = row.row
+		}
+		cameras = append(cameras, camera)
+	}
+	return cameras, nil
+}
+
+// recordCamera validates a record and builds the camera it describes
+func recordCamera(record CameraRecord, index locationIndex) (models.Camera, error) {
+	record.Name = strings.TrimSpace(record.Name)
+	if record.Name == "" {
+		return models.Camera{}, errors.New("name is required")
+	}
+
+	location, err := index.resolve(record)
+	if err != nil {
+		return models.Camera{}, err
+	}
+	if strings.TrimSpace(record.Schema) == "" {
+		record.Schema = "rtsp"
+	}
+
+	input := models.CameraInput{
+		Schema:   record.Schema,
+		Host:     record.Host,
+		Port:     record.Port,
+		Path:     record.Path,
+		Username: record.Username,
+		Password: record.Password,
+	}
+	if err := validateCameraInput(input); err != nil {
+		return models.Camera{}, err
+	}
+
+	if record.Direction != "" && !slices.Contains(lineDirections, record.Direction) {
+		return models.Camera{}, fmt.Errorf("invalid direction %q, use one of %s", record.Direction, strings.Join(lineDirections, ", "))
+	}
+	lines := record.Lines
+	if lines == nil {
+		lines = []models.LineData{}
+	}
+	for i, line := range lines {
+		if line.Direction != "" && !slices.Contains(lineDirections, line.Direction) {
+			return models.Camera{}, fmt.Errorf("line %d has invalid direction %q", i+1, line.Direction)
+		}
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{"lines": lines})
+	if err != nil {
+		return models.Camera{}, err
+	}
+
+	return models.Camera{
+		Name:        record.Name,
+		LocationID:  location.ID,
+		Schema:      record.Schema,
+		Host:        record.Host,
+		Port:        record.Port,
+		Path:        record.Path,
+		Username:    record.Username,
+		Password:    record.Password,
+		Direction:   record.Direction,
+		Description: record.Description,
+		Tags:        record.Tags,
+		Status:      "offline",
+		Payload:     string(payload),
+	}, nil
+}
+
+// storeImport creates the cameras and the audit entry of the import in one transaction
+func (s *CameraService) storeImport(cameras []models.Camera, report *CameraImportReport) error {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range cameras {
+			if err := tx.Create(&cameras[i]).Error; err != nil {
+				return fmt.Errorf("failed to create camera %q: %w", cameras[i].Name, err)
+			}
+			report.Cameras = append(report.Cameras, cameras[i].ID)
+		}
+		report.Created = len(cameras)
+
+		detail, err := json.Marshal(report)
+		if err != nil {
+			return err
+		}
+		return tx.Create(&models.AuditEntry{
+			Timestamp: time.Now(),
+			Action:    ImportAuditAction,
+			Actor:     "admin",
+			Source:    "desktop",
+			Detail:    string(detail),
+		}).Error
+	})
+	if err != nil {
+		report.Cameras = []uint{}
+		report.Created = 0
+	}
+	return err
+}
+
+// locationIndex finds the location of an imported camera by ID, remote ID or name
+type locationIndex struct {
+	byID       map[string]models.Location
+	byRemoteID map[string]models.Location
+	byName     map[string][]models.Location
+}
+
+func newLocationIndex(locations []models.Location) locationIndex {
+	index := locationIndex{
+		byID:       make(map[string]models.Location, len(locations)),
+		byRemoteID: make(map[string]models.Location, len(locations)),
+		byName:     make(map[string][]models.Location, len(locations)),
+	}
+	for _, location := range locations {
+		index.byID[location.ID] = location
+		if location.RemoteID != "" {
+			index.byRemoteID[location.RemoteID] = location
+		}
+		name := strings.ToLower(strings.TrimSpace(location.Name))
+		index.byName[name] = append(index.byName[name], location)
+	}
+	return index
+}
+
+func (index locationIndex) resolve(record CameraRecord) (models.Location, error) {
+	value := strings.TrimSpace(record.Location)
+	if value == "" {
+		value = strings.TrimSpace(record.LocationName)
+	}
+	if value == "" {
+		return models.Location{}, errors.New("location is required")
+	}
+
+	if location, ok := index.byID[value]; ok {
+		return location, nil
+	}
+	if location, ok := index.byRemoteID[value]; ok {
+		return location, nil
+	}
+	switch matches := index.byName[strings.ToLower(value)]; len(matches) {
+	case 0:
+		return models.Location{}, fmt.Errorf("location %q not found", value)
+	case 1:
+		return matches[0], nil
+	default:
+		return models.Location{}, fmt.Errorf("%d locations are named %q, use the location ID", len(matches), value)
+	}
+}
+
+// parseCameraCSV reads the rows of a CSV file, the header names the columns in any order
+func parseCameraCSV(data []byte) ([]importRow, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read the CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(cameraColumns, name) {
+			return nil, fmt.Errorf("unknown column %q, use %s", name, strings.Join(cameraColumns, ", "))
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("column %q appears twice", name)
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{"name", "host"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("the %s column is required", name)
+		}
+	}
+	_, hasLocation := columns["location"]
+	_, hasLocationName := columns["location_name"]
+	if !hasLocation && !hasLocationName {
+		return nil, errors.New("the location or location_name column is required")
+	}
+
+	var rows []importRow
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if strings.TrimSpace(strings.Join(fields, "")) == "" {
+			continue
+		}
+
+		row := importRow{row: line}
+		if len(fields) != len(header) {
+			row.err = fmt.Errorf("row has %d columns, the header has %d", len(fields), len(header))
+		} else {
+			row.record, row.err = csvRecord(fields, columns)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func csvRecord(fields []string, columns map[string]int) (CameraRecord, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(fields[i])
+		}
+		return ""
+	}
+
+	record := CameraRecord{
+		Name:         field("name"),
+		Location:     field("location"),
+		LocationName: field("location_name"),
+		Schema:       field("schema"),
+		Host:         field("host"),
+		Path:         field("path"),
+		Username:     field("username"),
+		Password:     field("password"),
+		Direction:    field("direction"),
+		Description:  field("description"),
+		Tags:         field("tags"),
+	}
+
+	if port := field("port"); port != "" {
+		value, err := strconv.Atoi(port)
+		if err != nil {
+			return record, fmt.Errorf("invalid port %q", port)
+		}
+		record.Port = value
+	}
+	if lines := field("lines"); lines != "" {
+		if err := json.Unmarshal([]byte(lines), &record.Lines); err != nil {
+			return record, fmt.Errorf("invalid lines, expected a JSON list of lines: %v", err)
+		}
+	}
+	return record, nil
+}
+
+// parseCameraJSON reads the cameras of an export, a plain list of cameras is accepted as well
+func parseCameraJSON(data []byte) ([]importRow, error) {
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+
+	var records []CameraRecord
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &records); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+	} else {
+		var export CameraExport
+		if err := json.Unmarshal(data, &export); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		records = export.Cameras
+	}
+
+	rows := make([]importRow, len(records))
+	for i, record := range records {
+		rows[i] = importRow{row: i + 1, record: record}
+	}
+	return rows, nil
+}
+
+// ExportCameras returns the cameras as a CSV or JSON file that ImportCameras reads back.
+// Passwords are left out unless includePasswords, which needs an unlocked session.
+func (s *CameraService) ExportCameras(format string, includePasswords bool) (string, error) {
+	format = strings.ToLower(format)
+	if format != CameraFormatCSV && format != CameraFormatJSON {
+		return "", fmt.Errorf("unsupported format %q, use csv or json", format)
+	}
+	if includePasswords {
+		if err := s.requireUnlocked(); err != nil {
+			return "", err
+		}
+	}
+
+	cameras, err := s.ListCamera()
+	if err != nil {
+		return "", err
+	}
+
+	records := make([]CameraRecord, 0, len(cameras))
+	for _, camera := range cameras {
+		record := CameraRecord{
+			Name:         camera.Name,
+			Location:     camera.LocationID,
+			LocationName: camera.Location.Name,
+			Schema:       camera.Schema,
+			Host:         camera.Host,
+			Port:         camera.Port,
+			Path:         camera.Path,
+			Username:     camera.Username,
+			Direction:    camera.Direction,
+			Description:  camera.Description,
+			Tags:         camera.Tags,
+			Lines:        []models.LineData{},
+		}
+		if includePasswords {
+			record.Password = camera.Password
+		}
+		if payload, err := s.GetPayloadData(&camera); err == nil && payload.Lines != nil {
+			record.Lines = payload.Lines
+		}
+		records = append(records, record)
+	}
+
+	if format == CameraFormatJSON {
+		data, err := json.MarshalIndent(CameraExport{ExportedAt: time.Now(), Cameras: records}, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	return camerasCSV(records)
+}
+
+func camerasCSV(records []CameraRecord) (string, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	if err := writer.Write(cameraColumns); err != nil {
+		return "", err
+	}
+	for _, record := range records {
+		lines, err := json.Marshal(record.Lines)
+		if err != nil {
+			return "", err
+		}
+		row := []string{
+			record.Name, record.Location, record.LocationName, record.Schema, record.Host,
+			strconv.Itoa(record.Port), record.Path, record.Username, record.Password,
+			record.Direction, record.Description, record.Tags, string(lines),
+		}
+		if err := writer.Write(row); err != nil {
+			return "", err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
@@ -0,0 +1,144 @@
+package camera
+
+import (
+	"jarvist/internal/common/models"
+	"jarvist/internal/wails/services/mocks"
+	"strings"
+	"testing"
+)
+
+func newTransferService(t *testing.T) *CameraService {
+	t.Helper()
+
+	s := newTestService(t, mocks.NewHTTPClient(syncOK), &mocks.Emitter{})
+	if err := s.DB.AutoMigrate(&models.AuditEntry{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	locations := []models.Location{
+		{ID: "loc-1", Name: "Lobby", RemoteID: "42"},
+		{ID: "loc-2", Name: "Parking"},
+		{ID: "loc-3", Name: "parking"},
+	}
+	if err := s.DB.Create(&locations).Error; err != nil {
+		t.Fatalf("create locations: %v", err)
+	}
+	return s
+}
+
+func TestImportCamerasReportsRowErrors(t *testing.T) {
+	s := newTransferService(t)
+	addCamera(t, s, "existing")
+
+	csv := strings.Join([]string{
+		"Name,Location,Host,Port,Path,Lines",
+		`Entrance,lobby,10.0.0.2,554,/main,"[{""start"":{""x"":0,""y"":10},""end"":{""x"":100,""y"":10},""direction"":""ttb""}]"`,
+		"Exit,42,10.0.0.3,554,/main,",
+		"Copy,loc-1,10.0.0.1,554,/existing,",
+		"Again,loc-1,10.0.0.2,554,/main,",
+		"Garage,parking,10.0.0.4,554,/main,",
+		"Roof,nowhere,10.0.0.5,554,/main,",
+		"Gate,loc-1,10.0.0.6,abc,/main,",
+		",loc-1,10.0.0.7,554,/main,",
+	}, "\n")
+
+	report, err := s.importCameras([]byte(csv), CameraFormatCSV, "cameras.csv", false)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if report.Rows != 8 || report.ErrorCount != 6 || report.Created != 0 {
+		t.Fatalf("report = %+v, want 8 rows with 6 errors and nothing created", report)
+	}
+
+	want := map[int]string{
+		4: "already reads this stream",
+		5: "row 2 reads the same stream",
+		6: "use the location ID",
+		7: "not found",
+		8: "invalid port",
+		9: "name is required",
+	}
+	for _, problem := range report.Errors {
+		if !strings.Contains(problem.Message, want[problem.Row]) {
+			t.Errorf("row %d: %q, want %q", problem.Row, problem.Message, want[problem.Row])
+		}
+		delete(want, problem.Row)
+	}
+	if len(want) > 0 {
+		t.Fatalf("rows without the expected error: %v", want)
+	}
+
+	var count int64
+	s.DB.Model(&models.Camera{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("%d cameras stored, want only the existing one", count)
+	}
+
+	if _, err := s.importCameras([]byte("name,host,url\nA,10.0.0.9,x"), CameraFormatCSV, "", true); err == nil {
+		t.Fatal("unknown column accepted")
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	s := newTransferService(t)
+	camera := addCamera(t, s, "entrance")
+	camera.Password = "secret"
+	camera.Direction = "ltr"
+	if err := setPayloadLines(&camera, []models.LineData{{End: models.CoordLocation{X: 50, Y: 50}, Direction: "ltr"}}); err != nil {
+		t.Fatalf("set lines: %v", err)
+	}
+	s.DB.Save(&camera)
+
+	for _, format := range []string{CameraFormatCSV, CameraFormatJSON} {
+		data, err := s.ExportCameras(format, false)
+		if err != nil {
+			t.Fatalf("export %s: %v", format, err)
+		}
+		if strings.Contains(data, "secret") {
+			t.Fatalf("%s export contains the password", format)
+		}
+
+		// Kamera yang sama ditolak, stream-nya sudah dipakai
+		report, err := s.importCameras([]byte(data), format, "", true)
+		if err != nil {
+			t.Fatalf("import %s: %v", format, err)
+		}
+		if report.Rows != 1 || report.ErrorCount != 1 {
+			t.Fatalf("%s report = %+v, want the camera rejected as duplicate", format, report)
+		}
+	}
+
+	data, err := s.ExportCameras(CameraFormatJSON, true)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if err := s.DB.Delete(&models.Camera{}, camera.ID).Error; err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	report := CameraImportReport{Cameras: []uint{}}
+	rows, err := parseCameraJSON([]byte(data))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	cameras, err := s.checkImport(rows, &report)
+	if err != nil || len(report.Errors) > 0 {
+		t.Fatalf("check = %v, %+v", err, report.Errors)
+	}
+	if err := s.storeImport(cameras, &report); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+
+	imported, lines, err := s.GetCameraWithLines(report.Cameras[0])
+	if err != nil {
+		t.Fatalf("get imported camera: %v", err)
+	}
+	if imported.Password != "secret" || imported.LocationID != "loc-1" || imported.Direction != "ltr" || len(lines) != 1 {
+		t.Fatalf("imported camera = %+v with %d lines", imported, len(lines))
+	}
+
+	var audits int64
+	s.DB.Model(&models.AuditEntry{}).Where("action = ?", ImportAuditAction).Count(&audits)
+	if audits != 1 {
+		t.Fatalf("%d audit entries, want 1", audits)
+	}
+}
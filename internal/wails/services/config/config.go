@@ -3,6 +3,7 @@ package configservice
 import (
 	"jarvist/internal/common/buildinfo"
 	"jarvist/internal/common/config"
+	"jarvist/internal/wails/services/auth"
 	"jarvist/pkg/logger"
 )
 
@@ -20,9 +21,19 @@ type Config struct {
 	BuildInfo buildinfo.BuildInfo `json:"buildInfo"`
 }
 
+// DataPaths describes the relocatable directories and scheduled relocations
+type DataPaths struct {
+	Paths       map[string]string    `json:"paths"`
+	Pending     []config.PendingMove `json:"pending"`
+	LastResults []config.MoveResult  `json:"lastResults"`
+}
+
 type ConfigService struct {
 	config *config.Config
 	logger *logger.ContextLogger
+	guard  auth.Guard
+
+	restarter ComponentRestarter
 }
 
 func New(cfg *config.Config, logger *logger.ContextLogger) *ConfigService {
@@ -43,3 +54,58 @@ func (s *ConfigService) GetConfig() Config {
 		BuildInfo:       s.config.BuildInfo,
 	}
 }
+
+// SetGuard sets the lock guard checked before directories are relocated
+func (s *ConfigService) SetGuard(guard auth.Guard) {
+	s.guard = guard
+}
+
+func (s *ConfigService) requireUnlocked() error {
+	if s.guard == nil {
+		return nil
+	}
+	return s.guard.RequireUnlocked()
+}
+
+// GetDataPaths returns the data, log and services data directories
+func (s *ConfigService) GetDataPaths() (DataPaths, error) {
+	overrides, err := config.LoadPathOverrides()
+	if err != nil {
+		return DataPaths{}, err
+	}
+
+	return DataPaths{
+		Paths:       s.config.DataPaths(),
+		Pending:     overrides.Pending,
+		LastResults: overrides.LastResults,
+	}, nil
+}
+
+// PlanDataRelocation checks a new location for a directory and returns what would be moved
+func (s *ConfigService) PlanDataRelocation(kind, target string) (config.RelocationPlan, error) {
+	return s.config.PlanRelocation(kind, target)
+}
+
+// ScheduleDataRelocation moves a directory to target on the next start of the sync
+// service, the application has to be closed while the service restarts
+func (s *ConfigService) ScheduleDataRelocation(kind, target string) (config.RelocationPlan, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return config.RelocationPlan{}, err
+	}
+
+	plan, err := s.config.ScheduleRelocation(kind, target)
+	if err != nil {
+		return plan, err
+	}
+
+	s.logger.Info("Scheduled moving %s from %s to %s (%d files, %d bytes)", plan.Kind, plan.From, plan.To, plan.Files, plan.Bytes)
+	return plan, nil
+}
+
+// CancelDataRelocation removes a scheduled relocation
+func (s *ConfigService) CancelDataRelocation(kind string) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+	return config.CancelRelocation(kind)
+}
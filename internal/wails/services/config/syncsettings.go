@@ -0,0 +1,127 @@
+package configservice
+
+import (
+	"errors"
+	"fmt"
+	"jarvist/internal/wails/services/servicemanager"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// syncSettingsTimeout limits the sync service API calls of the sync settings
+const syncSettingsTimeout = 10 * time.Second
+
+// SyncSettings are the advanced settings of the sync service. Zero values keep the built-in
+// config, an empty password keeps the current broker password.
+type SyncSettings struct {
+	SyncIntervalSec         int    `json:"sync_interval_sec"`
+	BatchSize               int    `json:"batch_size"`
+	LogRetentionDays        int    `json:"log_retention_days"`
+	MessageRetentionDays    int    `json:"message_retention_days"`
+	DataRecordRetentionDays int    `json:"data_record_retention_days"`
+	Broker                  string `json:"broker"`
+	Port                    int    `json:"port"`
+	Username                string `json:"username"`
+	Password                string `json:"password,omitempty"`
+	PasswordSet             bool   `json:"password_set"`
+	EnableTLS               bool   `json:"enable_tls"`
+	// Staging publishes under the -staging topic namespace, for test devices only
+	Staging bool `json:"staging"`
+	// Pending are the components not using the saved settings yet
+	Pending []string `json:"pending"`
+}
+
+// Validate checks the settings before they are sent, the sync service checks them again
+func (s SyncSettings) Validate() error {
+	switch {
+	case s.SyncIntervalSec != 0 && (s.SyncIntervalSec < 10 || s.SyncIntervalSec > 3600):
+		return errors.New("sync interval must be between 10 and 3600 seconds")
+	case s.BatchSize < 1 || s.BatchSize > 500:
+		return errors.New("batch size must be between 1 and 500")
+	case s.LogRetentionDays < 0 || s.MessageRetentionDays < 0 || s.DataRecordRetentionDays < 0:
+		return errors.New("retention cannot be negative")
+	case strings.ContainsAny(s.Broker, " /:"):
+		return errors.New("broker must be a host name or IP address without scheme or port")
+	case s.Port < 0 || s.Port > 65535:
+		return errors.New("port must be between 1 and 65535")
+	}
+	return nil
+}
+
+// SyncSettingsResult is the outcome of applying the sync settings
+type SyncSettingsResult struct {
+	Settings  SyncSettings                      `json:"settings"`
+	Restarted []servicemanager.ComponentRestart `json:"restarted"`
+}
+
+// ComponentRestarter restarts one sync service component
+type ComponentRestarter interface {
+	RestartComponent(key string) (servicemanager.ComponentRestart, error)
+}
+
+// SetComponentRestarter sets the service manager used to restart components after the sync
+// settings are applied
+func (s *ConfigService) SetComponentRestarter(restarter ComponentRestarter) {
+	s.restarter = restarter
+}
+
+// GetSyncSettings returns the sync interval, batch size, retention and broker settings of
+// the sync service
+func (s *ConfigService) GetSyncSettings() (SyncSettings, error) {
+	var settings SyncSettings
+	err := servicemanager.SyncApiRequest(s.config, http.MethodGet, "/settings/sync", syncSettingsTimeout, nil, &settings)
+	return settings, err
+}
+
+// SaveSyncSettings validates and saves the sync settings. The batch size is used right
+// away, the other settings after ApplySyncSettings.
+func (s *ConfigService) SaveSyncSettings(settings SyncSettings) (SyncSettings, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return SyncSettings{}, err
+	}
+	if err := settings.Validate(); err != nil {
+		return SyncSettings{}, err
+	}
+
+	s.logger.Info("Saving sync settings (interval %ds, batch %d, broker %s:%d)",
+		settings.SyncIntervalSec, settings.BatchSize, settings.Broker, settings.Port)
+
+	var saved SyncSettings
+	if err := servicemanager.SyncApiRequest(s.config, http.MethodPut, "/settings/sync", syncSettingsTimeout, settings, &saved); err != nil {
+		return SyncSettings{}, err
+	}
+	return saved, nil
+}
+
+// ApplySyncSettings applies the saved sync settings and restarts the components that use
+// them, so the settings take effect without restarting the sync service
+func (s *ConfigService) ApplySyncSettings() (SyncSettingsResult, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return SyncSettingsResult{}, err
+	}
+
+	var applied struct {
+		Restart  []string     `json:"restart"`
+		Settings SyncSettings `json:"settings"`
+	}
+	if err := servicemanager.SyncApiRequest(s.config, http.MethodPost, "/settings/sync/apply", syncSettingsTimeout, nil, &applied); err != nil {
+		return SyncSettingsResult{}, err
+	}
+
+	result := SyncSettingsResult{Settings: applied.Settings}
+	if len(applied.Restart) > 0 && s.restarter == nil {
+		return result, errors.New("component restart not available, restart the sync service")
+	}
+
+	for _, component := range applied.Restart {
+		s.logger.Info("Restarting %s to apply sync settings", component)
+
+		restart, err := s.restarter.RestartComponent(component)
+		result.Restarted = append(result.Restarted, restart)
+		if err != nil {
+			return result, fmt.Errorf("sync settings applied but %w", err)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,97 @@
+// Package deps holds the outside dependencies of the Wails services behind interfaces, so a
+// service can be built with fakes in tests. Constructors take Options, every dependency
+// left unset uses the real implementation.
+package deps
+
+import (
+	"net/http"
+
+	"jarvist/internal/common/bandwidth"
+	"jarvist/pkg/hardware"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
+)
+
+// Emitter emits a frontend event
+type Emitter interface {
+	Emit(name string, data any)
+}
+
+// HTTPClient sends HTTP requests, *http.Client implements it
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Process is a started child process
+type Process interface {
+	Wait() error
+	Kill() error
+}
+
+// ProcessRunner starts child processes without a console window
+type ProcessRunner interface {
+	Start(name string, args ...string) (Process, error)
+}
+
+// Deps are the dependencies given to a constructor, nil fields are filled in by the service
+type Deps struct {
+	HTTP       HTTPClient
+	Events     Emitter
+	Runner     ProcessRunner
+	HardwareID func() (string, error)
+}
+
+// Option sets a dependency of a service
+type Option func(*Deps)
+
+// WithHTTPClient sends the HTTP requests of the service through client
+func WithHTTPClient(client HTTPClient) Option {
+	return func(d *Deps) { d.HTTP = client }
+}
+
+// WithEmitter emits the frontend events of the service through events
+func WithEmitter(events Emitter) Option {
+	return func(d *Deps) { d.Events = events }
+}
+
+// WithRunner starts the child processes of the service through runner
+func WithRunner(runner ProcessRunner) Option {
+	return func(d *Deps) { d.Runner = runner }
+}
+
+// WithHardwareID reads the hardware ID of the machine through hardwareID
+func WithHardwareID(hardwareID func() (string, error)) Option {
+	return func(d *Deps) { d.HardwareID = hardwareID }
+}
+
+// Apply returns the dependencies set by opts, the rest use the defaults
+func Apply(opts []Option) Deps {
+	var d Deps
+	for _, opt := range opts {
+		opt(&d)
+	}
+	if d.Runner == nil {
+		d.Runner = ExecRunner{}
+	}
+	if d.HardwareID == nil {
+		d.HardwareID = hardware.GetHardwareID
+	}
+	return d
+}
+
+// NewHTTPClient returns a client that accounts its traffic to destination. It has no
+// timeout, callers limit each request with its context.
+func NewHTTPClient(destination string) HTTPClient {
+	return &http.Client{Transport: bandwidth.NewTransport(destination)}
+}
+
+// AppEmitter emits events directly through the Wails app
+type AppEmitter struct {
+	App *application.App
+}
+
+func (e AppEmitter) Emit(name string, data any) {
+	if e.App != nil {
+		e.App.EmitEvent(name, data)
+	}
+}
@@ -0,0 +1,33 @@
+package deps
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// ExecRunner starts processes with os/exec, hidden so installers do not flash a console
+type ExecRunner struct{}
+
+func (ExecRunner) Start(name string, args ...string) (Process, error) {
+	cmd := exec.Command(name, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow:    true,
+		CreationFlags: 0x08000000,
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return execProcess{cmd}, nil
+}
+
+type execProcess struct {
+	cmd *exec.Cmd
+}
+
+func (p execProcess) Wait() error {
+	return p.cmd.Wait()
+}
+
+func (p execProcess) Kill() error {
+	return p.cmd.Process.Kill()
+}
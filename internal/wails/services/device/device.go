@@ -1,23 +1,136 @@
 package device
 
 import (
+	"context"
+	"jarvist/pkg/hardware"
 	"os"
 	"runtime"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
 )
 
 type DeviceInfo struct {
 	Name         string
 	OS           string
 	Architecture string
+
+	// Hardware inventory, empty until it was collected
+	CPUModel          string
+	CPUCores          int
+	CPUThreads        int
+	MemoryBytes       uint64
+	Disks             []hardware.Disk
+	GPUs              []string
+	NetworkInterfaces []hardware.NetworkInterface
+	OSName            string
+	OSVersion         string
+	OSBuild           string
+	InventoryError    string
+	CollectedAt       time.Time
+}
+
+// HardwareSummary is the part of the inventory sent with license activation and
+// telemetry, without serial numbers or network addresses
+type HardwareSummary struct {
+	CPUModel string `json:"cpu_model,omitempty"`
+	CPUCores int    `json:"cpu_cores,omitempty"`
+	MemoryGB int    `json:"memory_gb,omitempty"`
+	GPU      string `json:"gpu,omitempty"`
+	OSBuild  string `json:"os_build,omitempty"`
 }
 
+// Inventory di-cache untuk semua instance, query CIM butuh beberapa detik
+var (
+	cacheMu sync.Mutex
+	cached  *DeviceInfo
+)
+
 type DeviceService struct{}
 
 func New() *DeviceService {
 	return &DeviceService{}
 }
 
+// OnStartup collects the hardware inventory in the background, so the first
+// GetDeviceInfo call does not wait for it
+func (s *DeviceService) OnStartup(ctx context.Context, options application.ServiceOptions) error {
+	go s.GetDeviceInfo()
+	return nil
+}
+
+// GetDeviceInfo returns the device info with the hardware inventory, it is collected on
+// the first call and cached afterwards
 func (s *DeviceService) GetDeviceInfo() *DeviceInfo {
+	cacheMu.Lock()
+	info := cached
+	cacheMu.Unlock()
+
+	if info == nil {
+		info, _ = s.RefreshDeviceInfo(context.Background())
+	}
+
+	clone := *info
+	return &clone
+}
+
+// GetBasicDeviceInfo returns the hostname, OS and architecture without querying the hardware
+func (s *DeviceService) GetBasicDeviceInfo() *DeviceInfo {
+	return basicDeviceInfo()
+}
+
+// RefreshDeviceInfo collects the hardware inventory again and replaces the cached info
+func (s *DeviceService) RefreshDeviceInfo(ctx context.Context) (*DeviceInfo, error) {
+	info := basicDeviceInfo()
+
+	inventory, err := hardware.CollectInventory(ctx)
+	if err != nil {
+		info.InventoryError = err.Error()
+	}
+	info.CPUModel = inventory.CPUModel
+	info.CPUCores = inventory.CPUCores
+	info.CPUThreads = inventory.CPUThreads
+	info.MemoryBytes = inventory.MemoryBytes
+	info.Disks = inventory.Disks
+	info.GPUs = inventory.GPUs
+	info.NetworkInterfaces = inventory.NetworkInterfaces
+	info.OSName = inventory.OSName
+	info.OSVersion = inventory.OSVersion
+	info.OSBuild = inventory.OSBuild
+	info.CollectedAt = inventory.CollectedAt
+
+	// Query yang dibatalkan tidak menimpa inventory yang sudah ada
+	if ctx.Err() == nil {
+		cacheMu.Lock()
+		cached = info
+		cacheMu.Unlock()
+	}
+
+	clone := *info
+	return &clone, err
+}
+
+// GetHardwareSummary returns the non-identifying part of the cached inventory
+func (s *DeviceService) GetHardwareSummary() HardwareSummary {
+	return s.GetDeviceInfo().Summary()
+}
+
+// Summary returns the non-identifying part of the inventory
+func (d *DeviceInfo) Summary() HardwareSummary {
+	summary := HardwareSummary{
+		CPUModel: d.CPUModel,
+		CPUCores: d.CPUCores,
+		MemoryGB: int((d.MemoryBytes + 1<<29) >> 30),
+		OSBuild:  d.OSBuild,
+	}
+	if len(d.GPUs) > 0 {
+		summary.GPU = d.GPUs[0]
+	}
+	return summary
+}
+
+func basicDeviceInfo() *DeviceInfo {
 	hostname, err := os.Hostname()
 	if err != nil {
 		hostname = "unknown-host"
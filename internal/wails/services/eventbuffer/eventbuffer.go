@@ -0,0 +1,230 @@
+package eventbuffer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/models"
+	"jarvist/internal/wails/services/deps"
+	"jarvist/pkg/logger"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxEventsPerChannel is how many recent events are kept for each channel
+	maxEventsPerChannel = 100
+	// eventRetention is how long buffered events are kept at all
+	eventRetention = 24 * time.Hour
+
+	cursorKeyPrefix = "event_cursor:"
+)
+
+// Emitter emits a frontend event. Services use it instead of App.EmitEvent for events the
+// frontend must not miss.
+type Emitter = deps.Emitter
+
+// Event is a buffered event as returned to the frontend
+type Event struct {
+	Seq       uint64          `json:"seq"`
+	Channel   string          `json:"channel"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// MissedEvents is returned when a window fetches the events it has not acknowledged yet
+type MissedEvents struct {
+	Events    []Event `json:"events"`
+	AckedSeq  uint64  `json:"acked_seq"`
+	LatestSeq uint64  `json:"latest_seq"`
+}
+
+// EventBufferService persists recent events per channel before emitting them, so events
+// emitted while no window is open can be fetched later. Delivery is at-least-once: events
+// are returned to a consumer until it acknowledges them, the frontend drops duplicates by seq.
+type EventBufferService struct {
+	db     *gorm.DB
+	app    *application.App
+	logger *logger.ContextLogger
+	mu     sync.Mutex
+}
+
+func New(db *gorm.DB, logger *logger.ContextLogger) *EventBufferService {
+	return &EventBufferService{
+		db:     db,
+		logger: logger.WithComponent("eventbuffer"),
+	}
+}
+
+func (s *EventBufferService) OnStartup(ctx context.Context, options application.ServiceOptions) error {
+	s.pruneExpired()
+	return nil
+}
+
+func (s *EventBufferService) OnShutdown() error {
+	return nil
+}
+
+func (s *EventBufferService) InitService(app *application.App) {
+	s.app = app
+}
+
+// Emit stores the event and then emits it live. The live payload is unchanged so existing
+// listeners keep working.
+func (s *EventBufferService) Emit(name string, data any) {
+	if err := s.store(name, data); err != nil {
+		s.logger.Error("Failed to buffer event %s: %v", name, err)
+	}
+
+	if s.app != nil {
+		s.app.EmitEvent(name, data)
+	}
+}
+
+func (s *EventBufferService) store(channel string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event := models.BufferedEvent{
+		Channel:   channel,
+		Data:      string(payload),
+		CreatedAt: time.Now(),
+	}
+	if err := s.db.Create(&event).Error; err != nil {
+		return err
+	}
+
+	// Sisakan hanya event terbaru per channel
+	return s.db.Where("channel = ? AND id NOT IN (?)", channel,
+		s.db.Model(&models.BufferedEvent{}).Select("id").Where("channel = ?", channel).
+			Order("id DESC").Limit(maxEventsPerChannel),
+	).Delete(&models.BufferedEvent{}).Error
+}
+
+func (s *EventBufferService) pruneExpired() {
+	cutoff := time.Now().Add(-eventRetention)
+	result := s.db.Where("created_at < ?", cutoff).Delete(&models.BufferedEvent{})
+	if result.Error != nil {
+		s.logger.Warning("Failed to prune buffered events: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		s.logger.Info("Pruned %d buffered events older than %s", result.RowsAffected, eventRetention)
+	}
+}
+
+// GetMissedEvents returns the buffered events after the last acknowledged seq of consumer,
+// oldest first. An empty channels list returns all channels. Call it after subscribing to
+// the live events so nothing emitted in between is lost.
+func (s *EventBufferService) GetMissedEvents(consumer string, channels []string) (MissedEvents, error) {
+	if consumer == "" {
+		return MissedEvents{}, errors.New("consumer is required")
+	}
+
+	acked, err := s.getCursor(consumer)
+	if err != nil {
+		return MissedEvents{}, err
+	}
+
+	return s.eventsAfter(acked, channels)
+}
+
+// GetEventsSince returns the buffered events after seq, oldest first
+func (s *EventBufferService) GetEventsSince(seq uint64, channels []string) (MissedEvents, error) {
+	return s.eventsAfter(seq, channels)
+}
+
+func (s *EventBufferService) eventsAfter(seq uint64, channels []string) (MissedEvents, error) {
+	query := s.db.Where("id > ?", seq)
+	if len(channels) > 0 {
+		query = query.Where("channel IN ?", channels)
+	}
+
+	var rows []models.BufferedEvent
+	if err := query.Order("id ASC").Find(&rows).Error; err != nil {
+		return MissedEvents{}, err
+	}
+
+	result := MissedEvents{
+		Events:    make([]Event, 0, len(rows)),
+		AckedSeq:  seq,
+		LatestSeq: seq,
+	}
+	for _, row := range rows {
+		result.Events = append(result.Events, Event{
+			Seq:       row.ID,
+			Channel:   row.Channel,
+			Data:      json.RawMessage(row.Data),
+			CreatedAt: row.CreatedAt,
+		})
+	}
+
+	var latest uint64
+	if err := s.db.Model(&models.BufferedEvent{}).Select("COALESCE(MAX(id), 0)").Scan(&latest).Error; err != nil {
+		return MissedEvents{}, err
+	}
+	if latest > result.LatestSeq {
+		result.LatestSeq = latest
+	}
+
+	return result, nil
+}
+
+// AckEvents records that consumer has handled all events up to seq. The cursor never moves
+// backwards.
+func (s *EventBufferService) AckEvents(consumer string, seq uint64) error {
+	if consumer == "" {
+		return errors.New("consumer is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acked, err := s.getCursor(consumer)
+	if err != nil {
+		return err
+	}
+	if seq <= acked {
+		return nil
+	}
+
+	key := cursorKeyPrefix + consumer
+	value := strconv.FormatUint(seq, 10)
+
+	var setting models.Setting
+	result := s.db.Where("key = ?", key).First(&setting)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return s.db.Create(&models.Setting{Key: key, Value: value}).Error
+	} else if result.Error != nil {
+		return result.Error
+	}
+
+	setting.Value = value
+	return s.db.Save(&setting).Error
+}
+
+func (s *EventBufferService) getCursor(consumer string) (uint64, error) {
+	var setting models.Setting
+	err := s.db.Where("key = ?", cursorKeyPrefix+consumer).First(&setting).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	seq, err := strconv.ParseUint(setting.Value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid event cursor for %s: %w", consumer, err)
+	}
+	return seq, nil
+}
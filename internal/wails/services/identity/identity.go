@@ -0,0 +1,193 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"jarvist/internal/common/config"
+	commonidentity "jarvist/internal/common/identity"
+	"jarvist/internal/common/models"
+	"jarvist/internal/wails/services/auth"
+	"jarvist/internal/wails/services/camera"
+	"jarvist/internal/wails/services/eventbuffer"
+	"jarvist/internal/wails/services/setting"
+	"jarvist/pkg/logger"
+	"strings"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
+	"gorm.io/gorm"
+)
+
+// IdentityView is the effective identity shown in the settings UI
+type IdentityView struct {
+	Identity        commonidentity.Identity `json:"identity"`
+	DataTopic       string                  `json:"data_topic"`
+	LicenseTenantID string                  `json:"license_tenant_id"`
+	LicenseClientID string                  `json:"license_client_id"`
+	Problems        []string                `json:"problems"`
+}
+
+// ValidationResult is returned before an identity change is saved
+type ValidationResult struct {
+	Valid    bool                    `json:"valid"`
+	Problems []string                `json:"problems"`
+	Changes  []commonidentity.Change `json:"changes"`
+}
+
+// IdentityService manages tenant, client and site identity in one place and propagates
+// changes to the counter .env and camera config exports
+type IdentityService struct {
+	db             *gorm.DB
+	app            *application.App
+	events         eventbuffer.Emitter
+	config         *config.Config
+	logger         *logger.ContextLogger
+	settingService *setting.SettingsService
+	cameraService  *camera.CameraService
+	guard          auth.Guard
+}
+
+func New(db *gorm.DB, cfg *config.Config, logger *logger.ContextLogger, settingService *setting.SettingsService, cameraService *camera.CameraService) *IdentityService {
+	return &IdentityService{
+		db:             db,
+		config:         cfg,
+		logger:         logger.WithComponent("identity"),
+		settingService: settingService,
+		cameraService:  cameraService,
+	}
+}
+
+func (s *IdentityService) OnStartup(ctx context.Context, options application.ServiceOptions) error {
+	return nil
+}
+
+func (s *IdentityService) OnShutdown() error {
+	return nil
+}
+
+func (s *IdentityService) InitService(app *application.App) {
+	s.app = app
+}
+
+// SetEventBuffer makes identity events go through the event buffer
+func (s *IdentityService) SetEventBuffer(events eventbuffer.Emitter) {
+	s.events = events
+}
+
+// SetGuard sets the lock guard checked before identity changes
+func (s *IdentityService) SetGuard(guard auth.Guard) {
+	s.guard = guard
+}
+
+// GetIdentity returns the effective identity and the values from the license
+func (s *IdentityService) GetIdentity() IdentityView {
+	current := commonidentity.Get(s.db)
+	licenseTenant, licenseClient := s.licensed()
+
+	return IdentityView{
+		Identity:        current,
+		DataTopic:       current.DataTopic("<date>"),
+		LicenseTenantID: licenseTenant,
+		LicenseClientID: licenseClient,
+		Problems:        s.validate(current.Normalize()),
+	}
+}
+
+// ValidateIdentity checks a proposed identity without saving it
+func (s *IdentityService) ValidateIdentity(input commonidentity.Identity) ValidationResult {
+	next := s.withCurrentTags(input).Normalize()
+	problems := s.validate(next)
+
+	return ValidationResult{
+		Valid:    len(problems) == 0,
+		Problems: problems,
+		Changes:  commonidentity.Get(s.db).Diff(next),
+	}
+}
+
+// UpdateIdentity saves the identity, writes an audit entry and regenerates the counter
+// .env and camera config. The sync service uses the new values for the next data file.
+func (s *IdentityService) UpdateIdentity(input commonidentity.Identity) ([]commonidentity.Change, error) {
+	if s.guard != nil {
+		if err := s.guard.RequireUnlocked(); err != nil {
+			return nil, err
+		}
+	}
+
+	next := s.withCurrentTags(input).Normalize()
+	if problems := s.validate(next); len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "; "))
+	}
+
+	changes, err := commonidentity.Save(s.db, next, "admin", "desktop")
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return changes, nil
+	}
+
+	for _, change := range changes {
+		s.logger.Info("Identity %s changed from %q to %q", change.Field, change.Old, change.New)
+	}
+
+	s.propagate()
+
+	if s.events != nil {
+		s.events.Emit("identity_changed", changes)
+	} else if s.app != nil {
+		s.app.EmitEvent("identity_changed", changes)
+	}
+
+	return changes, nil
+}
+
+// withCurrentTags keeps the saved tags when the input has none, an empty map clears them
+func (s *IdentityService) withCurrentTags(input commonidentity.Identity) commonidentity.Identity {
+	if input.Tags == nil {
+		input.Tags = commonidentity.GetTags(s.db)
+	}
+	return input
+}
+
+// GetIdentityAudit returns the latest identity changes, newest first
+func (s *IdentityService) GetIdentityAudit(limit int) ([]models.AuditEntry, error) {
+	return commonidentity.GetAudit(s.db, limit)
+}
+
+// propagate regenerates the files the counter reads its identity from
+func (s *IdentityService) propagate() {
+	settings, err := s.settingService.GetAllSettings()
+	if err != nil {
+		s.logger.Error("Failed to read settings for .env: %v", err)
+	} else if err := s.settingService.GenerateEnvFile(settings); err != nil {
+		s.logger.Error("Failed to regenerate .env after identity change: %v", err)
+	}
+
+	if err := s.cameraService.ExportCameraConfig(context.Background()); err != nil {
+		s.logger.Error("Failed to export camera config after identity change: %v", err)
+	}
+}
+
+// validate adds the license checks to the format checks
+func (s *IdentityService) validate(next commonidentity.Identity) []string {
+	problems := next.Validate()
+
+	licenseTenant, licenseClient := s.licensed()
+	if licenseTenant != "" && next.TenantID != licenseTenant {
+		problems = append(problems, "tenant_id does not match the license ("+licenseTenant+")")
+	}
+	if licenseClient != "" && next.ClientID != licenseClient {
+		problems = append(problems, "client_id does not match the license ("+licenseClient+")")
+	}
+
+	return problems
+}
+
+// licensed returns the tenant and client ID applied from the license, empty if unlicensed
+func (s *IdentityService) licensed() (string, string) {
+	licensed := commonidentity.Identity{
+		TenantID: s.config.TenantId,
+		ClientID: s.config.ClientId,
+	}.Normalize()
+	return licensed.TenantID, licensed.ClientID
+}
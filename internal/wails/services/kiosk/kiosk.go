@@ -0,0 +1,243 @@
+package kiosk
+
+import (
+	"context"
+	"errors"
+	"jarvist/internal/wails/services/auth"
+	"jarvist/internal/wails/services/setting"
+	"jarvist/pkg/logger"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
+	"github.com/wailsapp/wails/v3/pkg/events"
+)
+
+const (
+	enabledKey = "kiosk_enabled"
+	monitorKey = "kiosk_monitor"
+)
+
+// ErrKioskMode is returned by service-control methods while kiosk mode is active
+var ErrKioskMode = errors.New("action not available in kiosk mode")
+
+// KioskConfig is the kiosk configuration shown in the settings UI
+type KioskConfig struct {
+	Enabled bool   `json:"enabled"`
+	Monitor string `json:"monitor"`
+}
+
+// ScreenInfo describes a monitor the kiosk window can be placed on
+type ScreenInfo struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	IsPrimary bool   `json:"is_primary"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+}
+
+type KioskService struct {
+	app            *application.App
+	settingService *setting.SettingsService
+	authGuard      auth.Guard
+	logger         *logger.ContextLogger
+
+	mu       sync.Mutex
+	window   *application.WebviewWindow
+	stopChan chan struct{}
+}
+
+func New(settingService *setting.SettingsService, authGuard auth.Guard, logger *logger.ContextLogger) *KioskService {
+	return &KioskService{
+		settingService: settingService,
+		authGuard:      authGuard,
+		logger:         logger.WithComponent("kiosk"),
+	}
+}
+
+func (s *KioskService) OnStartup(ctx context.Context, options application.ServiceOptions) error {
+	s.stopChan = make(chan struct{})
+	go s.windowWatchdog(s.stopChan)
+	return nil
+}
+
+func (s *KioskService) OnShutdown() error {
+	if s.stopChan != nil {
+		close(s.stopChan)
+		s.stopChan = nil
+	}
+	return nil
+}
+
+func (s *KioskService) InitService(app *application.App) {
+	s.app = app
+}
+
+// AttachWindow registers the dashboard window managed by kiosk mode
+func (s *KioskService) AttachWindow(window *application.WebviewWindow) {
+	s.mu.Lock()
+	s.window = window
+	s.mu.Unlock()
+
+	// Cegah window ditutup selama kiosk mode aktif
+	window.RegisterHook(events.Common.WindowClosing, func(event *application.WindowEvent) {
+		if s.IsEnabled() {
+			s.logger.Info("Blocked dashboard close in kiosk mode")
+			event.Cancel()
+		}
+	})
+}
+
+// IsEnabled returns true if kiosk mode is active
+func (s *KioskService) IsEnabled() bool {
+	enabled, err := strconv.ParseBool(s.settingService.GetSettingWithDefault(enabledKey, "false"))
+	return err == nil && enabled
+}
+
+// GetConfig returns the kiosk configuration
+func (s *KioskService) GetConfig() KioskConfig {
+	return KioskConfig{
+		Enabled: s.IsEnabled(),
+		Monitor: s.settingService.GetSettingWithDefault(monitorKey, ""),
+	}
+}
+
+// SetKioskMode enables or disables kiosk mode on the given monitor ID (empty = primary)
+func (s *KioskService) SetKioskMode(enabled bool, monitor string) error {
+	if err := s.settingService.SaveSettings(map[string]string{
+		enabledKey: strconv.FormatBool(enabled),
+		monitorKey: monitor,
+	}); err != nil {
+		return err
+	}
+
+	s.logger.Info("Kiosk mode enabled: %v (monitor: %s)", enabled, monitor)
+
+	if enabled {
+		s.Apply()
+	} else {
+		s.release()
+	}
+
+	if s.app != nil {
+		s.app.EmitEvent("kiosk:changed", s.GetConfig())
+	}
+	return nil
+}
+
+// GetScreens returns the available monitors
+func (s *KioskService) GetScreens() ([]ScreenInfo, error) {
+	if s.app == nil {
+		return nil, errors.New("application not initialized")
+	}
+
+	screens, err := s.app.GetScreens()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]ScreenInfo, 0, len(screens))
+	for _, screen := range screens {
+		result = append(result, ScreenInfo{
+			ID:        screen.ID,
+			Name:      screen.Name,
+			IsPrimary: screen.IsPrimary,
+			Width:     screen.Size.Width,
+			Height:    screen.Size.Height,
+		})
+	}
+	return result, nil
+}
+
+// RequireUnlocked blocks service control in kiosk mode and otherwise defers to the PIN lock
+func (s *KioskService) RequireUnlocked() error {
+	if s.IsEnabled() {
+		return ErrKioskMode
+	}
+	if s.authGuard != nil {
+		return s.authGuard.RequireUnlocked()
+	}
+	return nil
+}
+
+// Apply moves the dashboard window to the chosen monitor and makes it fullscreen
+func (s *KioskService) Apply() {
+	s.mu.Lock()
+	window := s.window
+	s.mu.Unlock()
+
+	if window == nil || !s.IsEnabled() {
+		return
+	}
+
+	if screen := s.targetScreen(); screen != nil {
+		window.SetPosition(screen.Bounds.X, screen.Bounds.Y)
+	}
+
+	window.Show()
+	window.SetAlwaysOnTop(true)
+	window.Fullscreen()
+	window.Focus()
+}
+
+func (s *KioskService) release() {
+	s.mu.Lock()
+	window := s.window
+	s.mu.Unlock()
+
+	if window == nil {
+		return
+	}
+
+	window.SetAlwaysOnTop(false)
+	window.UnFullscreen()
+	window.Center()
+}
+
+func (s *KioskService) targetScreen() *application.Screen {
+	if s.app == nil {
+		return nil
+	}
+
+	monitor := s.settingService.GetSettingWithDefault(monitorKey, "")
+	screens, err := s.app.GetScreens()
+	if err != nil {
+		s.logger.Warn("Failed to list screens: %v", err)
+		return nil
+	}
+
+	for _, screen := range screens {
+		if monitor != "" && (screen.ID == monitor || screen.Name == monitor) {
+			return screen
+		}
+	}
+	for _, screen := range screens {
+		if screen.IsPrimary {
+			return screen
+		}
+	}
+	return nil
+}
+
+// windowWatchdog memulihkan window dashboard jika tersembunyi saat kiosk mode aktif
+func (s *KioskService) windowWatchdog(stopChan chan struct{}) {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			window := s.window
+			s.mu.Unlock()
+
+			if window != nil && s.IsEnabled() && !window.IsVisible() {
+				s.logger.Info("Recovering dashboard window in kiosk mode")
+				s.Apply()
+			}
+		case <-stopChan:
+			return
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package licenseservice
+
+import (
+	"jarvist/internal/common/licensestate"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// degradationCheckInterval is how often the license is checked against its grace period
+const degradationCheckInterval = time.Hour
+
+// gracePeriodDays is how long an expired license keeps working normally
+const gracePeriodDays = 7
+
+// DegradationStatus is the degradation shown in the UI with the renewal instructions
+type DegradationStatus struct {
+	Degraded     bool     `json:"degraded"`
+	Reason       string   `json:"reason,omitempty"`
+	Since        string   `json:"since,omitempty"`
+	ExpiryDate   string   `json:"expiryDate,omitempty"`
+	DaysExpired  int      `json:"daysExpired"`
+	Message      string   `json:"message,omitempty"`
+	Instructions []string `json:"instructions,omitempty"`
+}
+
+// SetDB gives the license service access to the shared degradation state. Without it the
+// license is still validated but the sync service is not told to pause publishing.
+func (s *LicenseService) SetDB(db *gorm.DB) {
+	s.db = db
+}
+
+// IsDegraded reports whether the license expired past the grace period on this machine.
+// Counting continues and data is kept locally, only cloud publishing is paused.
+func (s *LicenseService) IsDegraded() bool {
+	validation := s.validateLicense()
+	return validation.Status == StatusExpired && !validation.GracePeriod
+}
+
+// GetDegradation returns the degradation state and what to do to renew the license
+func (s *LicenseService) GetDegradation() DegradationStatus {
+	validation := s.validateLicense()
+	if validation.Status != StatusExpired || validation.GracePeriod {
+		return DegradationStatus{}
+	}
+
+	status := DegradationStatus{
+		Degraded:    true,
+		Reason:      licensestate.ReasonExpired,
+		ExpiryDate:  validation.License.ExpiryDate.Format("2006-01-02"),
+		DaysExpired: -validation.DaysLeft,
+		Message:     "License has expired. People counting continues and data is kept on this device, but nothing is sent to the cloud until the license is renewed.",
+		Instructions: []string{
+			"Contact your reseller or the Jarvist sales team to renew the license",
+			"Open Settings > License and enter the renewed license key to activate it again",
+			"Cloud publishing resumes automatically after activation, data collected in the meantime is sent in order",
+		},
+	}
+	if s.db != nil {
+		if state := licensestate.Get(s.db); state.Since != nil {
+			status.Since = state.Since.Format(time.RFC3339)
+		}
+	}
+	return status
+}
+
+// evaluateDegradation stores the degradation when it changes so the sync service pauses or
+// resumes publishing
+func (s *LicenseService) evaluateDegradation() {
+	if s.db == nil {
+		return
+	}
+
+	validation := s.validateLicense()
+	degraded := validation.Status == StatusExpired && !validation.GracePeriod
+
+	s.degradationMu.Lock()
+	defer s.degradationMu.Unlock()
+
+	current := licensestate.Get(s.db)
+	if current.Degraded == degraded {
+		return
+	}
+
+	state := licensestate.State{Degraded: degraded}
+	if degraded {
+		now := time.Now()
+		expiredAt := validation.License.ExpiryDate
+		state.Reason = licensestate.ReasonExpired
+		state.Since = &now
+		state.ExpiredAt = &expiredAt
+	}
+
+	if err := licensestate.Set(s.db, state); err != nil {
+		s.logger.Error("Failed to store license degradation: %v", err)
+		return
+	}
+
+	if degraded {
+		s.logger.Warning("License expired more than %d days ago, cloud publishing paused until it is renewed", gracePeriodDays)
+	} else {
+		s.logger.Info("License restored, cloud publishing resumes")
+	}
+}
+
+// watchDegradation checks the license periodically until the service shuts down
+func (s *LicenseService) watchDegradation(stop <-chan struct{}) {
+	s.evaluateDegradation()
+
+	ticker := time.NewTicker(degradationCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.evaluateDegradation()
+		case <-stop:
+			return
+		}
+	}
+}
@@ -14,14 +14,18 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
+	"jarvist/internal/common/bandwidth"
 	"jarvist/internal/common/config"
+	"jarvist/internal/wails/services/auth"
+	"jarvist/internal/wails/services/deps"
 	"jarvist/internal/wails/services/device"
-	"jarvist/pkg/hardware"
 	"jarvist/pkg/logger"
 
 	"github.com/wailsapp/wails/v3/pkg/application"
+	"gorm.io/gorm"
 )
 
 type LicenseStatus int
@@ -67,6 +71,8 @@ type LicenseInfo struct {
 	ApiKey      string    `json:"apiKey"`
 	TenantId    string    `json:"tenantId"`
 	ClientID    float64   `json:"clientID"`
+	// Offline licenses were activated from a vendor file, they are deactivated locally
+	Offline bool `json:"offline,omitempty"`
 }
 
 type LicenseValidation struct {
@@ -78,12 +84,58 @@ type LicenseValidation struct {
 	GracePeriod bool          `json:"gracePeriod"`
 }
 
+// LicenseStatusResult is the license status shown in the frontend, without sensitive data
+type LicenseStatusResult struct {
+	Valid       bool   `json:"valid"`
+	Status      int    `json:"status"`
+	Message     string `json:"message"`
+	DaysLeft    int    `json:"daysLeft"`
+	GracePeriod bool   `json:"gracePeriod"`
+	Company     string `json:"company,omitempty"`
+	ContactName string `json:"contactName,omitempty"`
+	Email       string `json:"email,omitempty"`
+	ExpiryDate  string `json:"expiryDate,omitempty"`
+}
+
+// LicenseActionResult is the result of activating or deactivating a license
+type LicenseActionResult struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// LicenseDetails is the full license shown in the settings UI, only Licensed is set when
+// there is no license
+type LicenseDetails struct {
+	Licensed    bool               `json:"licensed"`
+	ApiKey      string             `json:"apiKey,omitempty"`
+	LicenseKey  string             `json:"licenseKey,omitempty"`
+	ClientID    float64            `json:"clientId,omitempty"`
+	Company     string             `json:"company,omitempty"`
+	ContactName string             `json:"contactName,omitempty"`
+	Email       string             `json:"email,omitempty"`
+	IssueDate   string             `json:"issueDate,omitempty"`
+	ExpiryDate  string             `json:"expiryDate,omitempty"`
+	DaysLeft    int                `json:"daysLeft"`
+	Status      int                `json:"status"`
+	Message     string             `json:"message,omitempty"`
+	DeviceInfo  *device.DeviceInfo `json:"deviceInfo,omitempty"`
+}
+
 type LicenseService struct {
 	config      *config.Config
 	logger      *logger.ContextLogger
 	licenseInfo *LicenseInfo
 	encryption  *EncryptionConfig
-	device      *device.DeviceInfo
+	device      *device.DeviceService
+	db          *gorm.DB
+	http        deps.HTTPClient
+	hardwareID  func() (string, error)
+	guard       auth.Guard
+
+	activationPublicKey string
+
+	degradationMu sync.Mutex
+	stopChan      chan struct{}
 }
 
 type EncryptionConfig struct {
@@ -92,14 +144,20 @@ type EncryptionConfig struct {
 	LicensePath string
 }
 
-func New(cfg *config.Config, logger *logger.ContextLogger, secretKey, salt string) *LicenseService {
+// licenseRequestTimeout limits a request to the license server
+const licenseRequestTimeout = 10 * time.Second
+
+func New(cfg *config.Config, logger *logger.ContextLogger, secretKey, salt string, opts ...deps.Option) *LicenseService {
 	if cfg.IsDev() && secretKey == "dev_test_license_key_not_for_production" {
 		logger.Warning("Using INSECURE default license key for development!")
 	}
 
-	hash := sha256.Sum256([]byte(secretKey))
-	device := device.New()
+	d := deps.Apply(opts)
+	if d.HTTP == nil {
+		d.HTTP = deps.NewHTTPClient(bandwidth.DestinationLicense)
+	}
 
+	hash := sha256.Sum256([]byte(secretKey))
 	return &LicenseService{
 		config: cfg,
 		logger: logger.WithComponent("license"),
@@ -108,19 +166,44 @@ func New(cfg *config.Config, logger *logger.ContextLogger, secretKey, salt strin
 			Salt:        salt,
 			LicensePath: filepath.Join(cfg.DataDir, "license.dat"),
 		},
-		device: device.GetDeviceInfo(),
+		device:     device.New(),
+		http:       d.HTTP,
+		hardwareID: d.HardwareID,
 	}
 }
 
 // ServiceStartup initializes the license service
 func (s *LicenseService) OnStartup(ctx context.Context, options application.ServiceOptions) error {
 	s.LoadLicense()
+
+	s.stopChan = make(chan struct{})
+	go s.watchDegradation(s.stopChan)
 	return nil
 }
 
+func (s *LicenseService) OnShutdown() error {
+	if s.stopChan != nil {
+		close(s.stopChan)
+		s.stopChan = nil
+	}
+	return nil
+}
+
+// SetGuard sets the lock guard checked before the license is activated or deactivated
+func (s *LicenseService) SetGuard(guard auth.Guard) {
+	s.guard = guard
+}
+
+func (s *LicenseService) requireUnlocked() error {
+	if s.guard == nil {
+		return nil
+	}
+	return s.guard.RequireUnlocked()
+}
+
 // GetHardwareFingerprint returns a unique identifier for the current machine
 func (s *LicenseService) GetHardwareFingerprint() (string, error) {
-	hardwareID, err := hardware.GetHardwareID()
+	hardwareID, err := s.hardwareID()
 	if err != nil {
 		s.logger.Error("Failed to get hardware ID: %v", err)
 		return "", err
@@ -129,42 +212,43 @@ func (s *LicenseService) GetHardwareFingerprint() (string, error) {
 }
 
 // GetLicenseStatus returns current license status
-func (s *LicenseService) GetLicenseStatus() map[string]interface{} {
+func (s *LicenseService) GetLicenseStatus() LicenseStatusResult {
 	validation := s.validateLicense()
 
 	// Don't send sensitive data to frontend
-	result := map[string]interface{}{
-		"valid":       validation.Valid,
-		"status":      int(validation.Status),
-		"message":     validation.Message,
-		"daysLeft":    validation.DaysLeft,
-		"gracePeriod": validation.GracePeriod,
+	result := LicenseStatusResult{
+		Valid:       validation.Valid,
+		Status:      int(validation.Status),
+		Message:     validation.Message,
+		DaysLeft:    validation.DaysLeft,
+		GracePeriod: validation.GracePeriod,
 	}
 
 	if validation.License != nil {
-		result["company"] = validation.License.Company
-		result["contactName"] = validation.License.ContactName
-		result["email"] = validation.License.Email
-		result["expiryDate"] = validation.License.ExpiryDate.Format("2006-01-02")
+		result.Company = validation.License.Company
+		result.ContactName = validation.License.ContactName
+		result.Email = validation.License.Email
+		result.ExpiryDate = validation.License.ExpiryDate.Format("2006-01-02")
 	}
 
 	return result
 }
 
 // RegisterLicense activates a license with the given key for this machine
-func (s *LicenseService) RegisterLicense(licenseKey string) map[string]interface{} {
-	s.logger.Info("Attempting to register license: %s", licenseKey)
-
-	result := map[string]interface{}{
-		"success": false,
-		"message": "",
+func (s *LicenseService) RegisterLicense(licenseKey string) LicenseActionResult {
+	var result LicenseActionResult
+	if err := s.requireUnlocked(); err != nil {
+		result.Message = err.Error()
+		return result
 	}
 
+	s.logger.Info("Attempting to register license: %s", licenseKey)
+
 	// Get hardware fingerprint
 	hardwareID, err := s.GetHardwareFingerprint()
 	if err != nil {
 		s.logger.Error("Failed to get hardware ID: %v", err)
-		result["message"] = "Failed to identify this machine"
+		result.Message = "Failed to identify this machine"
 		return result
 	}
 
@@ -172,7 +256,7 @@ func (s *LicenseService) RegisterLicense(licenseKey string) map[string]interface
 	activationResult, err := s.activateLicenseWithServer(licenseKey, hardwareID)
 	if err != nil {
 		s.logger.Error("License activation failed: %v", err)
-		result["message"] = "Failed to contact activation server: " + err.Error()
+		result.Message = "Failed to contact activation server: " + err.Error()
 		return result
 	}
 
@@ -183,7 +267,7 @@ func (s *LicenseService) RegisterLicense(licenseKey string) map[string]interface
 		if msg, ok := activationResult["message"].(string); ok {
 			message = msg
 		}
-		result["message"] = message
+		result.Message = message
 		return result
 	}
 
@@ -235,23 +319,28 @@ func (s *LicenseService) RegisterLicense(licenseKey string) map[string]interface
 	s.licenseInfo = license
 	if err := s.saveLicense(); err != nil {
 		s.logger.Error("Failed to save license: %v", err)
-		result["message"] = "License validated but failed to save locally: " + err.Error()
+		result.Message = "License validated but failed to save locally: " + err.Error()
 		return result
 	}
 
-	result["success"] = true
-	result["message"] = "License successfully activated"
+	// Lisensi yang diperpanjang langsung melanjutkan publikasi ke cloud
+	s.evaluateDegradation()
+
+	result.Success = true
+	result.Message = "License successfully activated"
 	return result
 }
 
 // activateLicenseWithServer contacts license server for activation
 func (s *LicenseService) activateLicenseWithServer(licenseKey, hardwareID string) (map[string]interface{}, error) {
 	// Create activation request
+	deviceInfo := s.device.GetDeviceInfo()
 	requestData := map[string]interface{}{
-		"license_key": licenseKey,
-		"device_id":   hardwareID,
-		"device_name": s.device.Name,
-		"device_os":   s.device.OS,
+		"license_key":     licenseKey,
+		"device_id":       hardwareID,
+		"device_name":     deviceInfo.Name,
+		"device_os":       deviceInfo.OS,
+		"device_hardware": deviceInfo.Summary(),
 	}
 
 	jsonData, err := json.Marshal(requestData)
@@ -260,7 +349,10 @@ func (s *LicenseService) activateLicenseWithServer(licenseKey, hardwareID string
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequest("POST", s.config.ApiUrl+"/v1/license/activate", strings.NewReader(string(jsonData)))
+	ctx, cancel := context.WithTimeout(context.Background(), licenseRequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.config.ApiUrl+"/v1/license/activate", strings.NewReader(string(jsonData)))
 	if err != nil {
 		return nil, err
 	}
@@ -271,8 +363,7 @@ func (s *LicenseService) activateLicenseWithServer(licenseKey, hardwareID string
 	req.Header.Set("Authorization", "Bearer "+s.config.ApiKey)
 
 	// Send request
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := s.http.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -295,14 +386,27 @@ func (s *LicenseService) activateLicenseWithServer(licenseKey, hardwareID string
 }
 
 // DeactivateLicense deactivates the current license
-func (s *LicenseService) DeactivateLicense() map[string]interface{} {
-	result := map[string]interface{}{
-		"success": false,
-		"message": "",
+func (s *LicenseService) DeactivateLicense() LicenseActionResult {
+	var result LicenseActionResult
+	if err := s.requireUnlocked(); err != nil {
+		result.Message = err.Error()
+		return result
 	}
 
 	if s.licenseInfo == nil {
-		result["message"] = "No license is currently active"
+		result.Message = "No license is currently active"
+		return result
+	}
+
+	// Lisensi offline tidak terdaftar lewat server, vendor diberi tahu secara manual
+	if s.licenseInfo.Offline {
+		if err := os.Remove(s.encryption.LicensePath); err != nil && !os.IsNotExist(err) {
+			s.logger.Warning("Failed to remove license file: %v", err)
+		}
+		s.logger.Info("Offline license %s removed from this machine", s.maskLicenseKey(s.licenseInfo.LicenseKey))
+		s.licenseInfo = nil
+		result.Success = true
+		result.Message = "License removed from this machine, report the deactivation to your vendor"
 		return result
 	}
 
@@ -310,7 +414,7 @@ func (s *LicenseService) DeactivateLicense() map[string]interface{} {
 	deactivationResult, err := s.deactivateLicenseWithServer(s.licenseInfo.LicenseKey, s.licenseInfo.HardwareID)
 	if err != nil {
 		s.logger.Error("License deactivation failed: %v", err)
-		result["message"] = "Failed to contact activation server: " + err.Error()
+		result.Message = "Failed to contact activation server: " + err.Error()
 		return result
 	}
 
@@ -320,8 +424,8 @@ func (s *LicenseService) DeactivateLicense() map[string]interface{} {
 	}
 
 	s.licenseInfo = nil
-	result["success"] = true
-	result["message"] = "License successfully deactivated"
+	result.Success = true
+	result.Message = "License successfully deactivated"
 
 	// Log the server response as well
 	if message, ok := deactivationResult["message"].(string); ok {
@@ -346,7 +450,10 @@ func (s *LicenseService) deactivateLicenseWithServer(licenseKey, hardwareID stri
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequest("POST", s.config.ApiUrl+"/license/deactivate", strings.NewReader(string(jsonData)))
+	ctx, cancel := context.WithTimeout(context.Background(), licenseRequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.config.ApiUrl+"/license/deactivate", strings.NewReader(string(jsonData)))
 	if err != nil {
 		return nil, err
 	}
@@ -357,8 +464,7 @@ func (s *LicenseService) deactivateLicenseWithServer(licenseKey, hardwareID stri
 	req.Header.Set("Authorization", "Bearer "+s.config.ApiKey)
 
 	// Send request
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := s.http.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -408,7 +514,7 @@ func (s *LicenseService) LoadLicense() {
 	}
 
 	// Verify hardware ID
-	currentHardwareID, err := hardware.GetHardwareID()
+	currentHardwareID, err := s.hardwareID()
 	if err != nil {
 		s.logger.Error("Failed to get current hardware ID: %v", err)
 		return
@@ -471,7 +577,7 @@ func (s *LicenseService) validateLicense() LicenseValidation {
 	}
 
 	// Verify hardware ID
-	currentHardwareID, err := hardware.GetHardwareID()
+	currentHardwareID, err := s.hardwareID()
 	if err != nil {
 		validation.Message = "Failed to get hardware ID"
 		return validation
@@ -496,7 +602,7 @@ func (s *LicenseService) validateLicense() LicenseValidation {
 		validation.DaysLeft = -daysSinceExpiry
 
 		// Check for grace period (7 days)
-		if daysSinceExpiry <= 7 {
+		if daysSinceExpiry <= gracePeriodDays {
 			validation.GracePeriod = true
 			validation.Valid = true // Still valid during grace period
 		}
@@ -598,32 +704,28 @@ func (s *LicenseService) IsLicensed() bool {
 }
 
 // GetLicenseDetails returns full license details (safe for settings UI)
-func (s *LicenseService) GetLicenseDetails() map[string]interface{} {
+func (s *LicenseService) GetLicenseDetails() LicenseDetails {
 	if s.licenseInfo == nil {
-		return map[string]interface{}{
-			"licensed": false,
-		}
+		return LicenseDetails{Licensed: false}
 	}
 
 	validation := s.validateLicense()
 
-	result := map[string]interface{}{
-		"licensed":    validation.Valid,
-		"apiKey":      s.licenseInfo.ApiKey,
-		"licenseKey":  s.licenseInfo.LicenseKey,
-		"clientId":    s.licenseInfo.ClientID,
-		"company":     s.licenseInfo.Company,
-		"contactName": s.licenseInfo.ContactName,
-		"email":       s.licenseInfo.Email,
-		"issueDate":   s.licenseInfo.IssuedDate.Format("2006-01-02"),
-		"expiryDate":  s.licenseInfo.ExpiryDate.Format("2006-01-02"),
-		"daysLeft":    validation.DaysLeft,
-		"status":      int(validation.Status),
-		"message":     validation.Message,
-		"deviceInfo":  s.device,
+	return LicenseDetails{
+		Licensed:    validation.Valid,
+		ApiKey:      s.licenseInfo.ApiKey,
+		LicenseKey:  s.licenseInfo.LicenseKey,
+		ClientID:    s.licenseInfo.ClientID,
+		Company:     s.licenseInfo.Company,
+		ContactName: s.licenseInfo.ContactName,
+		Email:       s.licenseInfo.Email,
+		IssueDate:   s.licenseInfo.IssuedDate.Format("2006-01-02"),
+		ExpiryDate:  s.licenseInfo.ExpiryDate.Format("2006-01-02"),
+		DaysLeft:    validation.DaysLeft,
+		Status:      int(validation.Status),
+		Message:     validation.Message,
+		DeviceInfo:  s.device.GetDeviceInfo(),
 	}
-
-	return result
 }
 
 // maskLicenseKey returns a masked version of the license key (e.g., "XXXX-XXXX-XXXX-1234")
@@ -0,0 +1,229 @@
+package licenseservice
+
+import (
+	"encoding/json"
+	"errors"
+	"jarvist/internal/common/config"
+	"jarvist/internal/wails/services/auth"
+	"jarvist/internal/wails/services/deps"
+	"jarvist/internal/wails/services/mocks"
+	"jarvist/pkg/logger"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+const testHardwareID = "HW-TEST-1"
+
+func newTestService(t *testing.T, client *mocks.HTTPClient) *LicenseService {
+	t.Helper()
+
+	cfg := &config.Config{DataDir: t.TempDir(), ApiUrl: "http://api.test"}
+	return New(cfg, logger.NewLogger().WithComponent("test"), "test-secret", "test-salt",
+		deps.WithHTTPClient(client), deps.WithHardwareID(mocks.HardwareID(testHardwareID)))
+}
+
+func installLicense(t *testing.T, s *LicenseService, hardwareID string, expiry time.Time) {
+	t.Helper()
+
+	s.licenseInfo = &LicenseInfo{
+		LicenseKey: "KEY-1234",
+		HardwareID: hardwareID,
+		ApiKey:     "api-key",
+		TenantId:   "tenant",
+		IssuedDate: expiry.AddDate(-1, 0, 0),
+		ExpiryDate: expiry,
+		Activated:  true,
+	}
+	if err := s.saveLicense(); err != nil {
+		t.Fatalf("save license: %v", err)
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	s := newTestService(t, &mocks.HTTPClient{})
+
+	encrypted, err := s.encrypt([]byte(`{"licenseKey":"KEY"}`))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	decrypted, err := s.decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if string(decrypted) != `{"licenseKey":"KEY"}` {
+		t.Errorf("round trip: got %s", decrypted)
+	}
+
+	// Salt ikut diautentikasi, file dari instalasi lain tidak bisa dibaca
+	other := newTestService(t, &mocks.HTTPClient{})
+	other.encryption.Salt = "other-salt"
+	if _, err := other.decrypt(encrypted); err == nil {
+		t.Error("decrypt with another salt succeeded")
+	}
+}
+
+func TestValidateLicense(t *testing.T) {
+	tests := []struct {
+		name       string
+		hardwareID string
+		expiry     time.Time
+		valid      bool
+		status     LicenseStatus
+		grace      bool
+	}{
+		{"valid", testHardwareID, time.Now().AddDate(0, 0, 30), true, StatusValid, false},
+		{"in grace period", testHardwareID, time.Now().AddDate(0, 0, -3), true, StatusExpired, true},
+		{"expired", testHardwareID, time.Now().AddDate(0, 0, -gracePeriodDays-3), false, StatusExpired, false},
+		{"other machine", "HW-OTHER", time.Now().AddDate(0, 0, 30), false, StatusWrongMachine, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, &mocks.HTTPClient{})
+			installLicense(t, s, tt.hardwareID, tt.expiry)
+
+			validation := s.validateLicense()
+			if validation.Valid != tt.valid || validation.Status != tt.status || validation.GracePeriod != tt.grace {
+				t.Errorf("got valid=%v status=%d grace=%v, want valid=%v status=%d grace=%v",
+					validation.Valid, validation.Status, validation.GracePeriod, tt.valid, tt.status, tt.grace)
+			}
+		})
+	}
+}
+
+func TestValidateWithoutLicense(t *testing.T) {
+	s := newTestService(t, &mocks.HTTPClient{})
+
+	if s.IsLicensed() {
+		t.Error("licensed without a license file")
+	}
+	if details := s.GetLicenseDetails(); details.Licensed {
+		t.Errorf("details: got %+v", details)
+	}
+}
+
+func TestRegisterLicense(t *testing.T) {
+	client := mocks.NewHTTPClient(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"data": map[string]interface{}{
+				"api_key":     "new-api-key",
+				"tenant_id":   "tenant-9",
+				"client_id":   42,
+				"valid_from":  "2025-01-01T00:00:00Z",
+				"valid_until": time.Now().AddDate(1, 0, 0).UTC().Format(time.RFC3339),
+				"client_info": map[string]interface{}{"name": "Mall Pusat", "email": "ops@example.com"},
+			},
+		})
+	})
+	s := newTestService(t, client)
+
+	result := s.RegisterLicense("KEY-5678")
+	if !result.Success {
+		t.Fatalf("register: %s", result.Message)
+	}
+
+	requests := client.Requests()
+	if len(requests) != 1 || requests[0].Path != "/v1/license/activate" {
+		t.Fatalf("requests: got %+v", requests)
+	}
+	var body map[string]interface{}
+	json.Unmarshal(requests[0].Body, &body)
+	if body["license_key"] != "KEY-5678" || body["device_id"] != testHardwareID {
+		t.Errorf("activation body: got %v", body)
+	}
+
+	if _, err := os.Stat(s.encryption.LicensePath); err != nil {
+		t.Fatalf("license file not written: %v", err)
+	}
+	status := s.GetLicenseStatus()
+	if !status.Valid || status.Company != "Mall Pusat" {
+		t.Errorf("status: got %+v", status)
+	}
+	if s.config.ApiKey != "new-api-key" || s.config.TenantId != "tenant-9" {
+		t.Errorf("config not updated: api key %q, tenant %q", s.config.ApiKey, s.config.TenantId)
+	}
+}
+
+func TestRegisterLicenseFailure(t *testing.T) {
+	t.Run("rejected", func(t *testing.T) {
+		client := mocks.NewHTTPClient(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "License key already in use"})
+		})
+		s := newTestService(t, client)
+
+		result := s.RegisterLicense("KEY-5678")
+		if result.Success || result.Message != "License key already in use" {
+			t.Errorf("got %+v", result)
+		}
+		if _, err := os.Stat(s.encryption.LicensePath); !os.IsNotExist(err) {
+			t.Error("license file written for a rejected key")
+		}
+	})
+
+	t.Run("server unreachable", func(t *testing.T) {
+		client := &mocks.HTTPClient{Err: errors.New("connection refused")}
+		s := newTestService(t, client)
+
+		if result := s.RegisterLicense("KEY-5678"); result.Success {
+			t.Errorf("got %+v", result)
+		}
+	})
+}
+
+func TestDeactivateLicense(t *testing.T) {
+	client := mocks.NewHTTPClient(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "deactivated"})
+	})
+	s := newTestService(t, client)
+	installLicense(t, s, testHardwareID, time.Now().AddDate(0, 0, 30))
+
+	if result := s.DeactivateLicense(); !result.Success {
+		t.Fatalf("deactivate: %s", result.Message)
+	}
+	if _, err := os.Stat(s.encryption.LicensePath); !os.IsNotExist(err) {
+		t.Error("license file not removed")
+	}
+	if s.IsLicensed() {
+		t.Error("still licensed after deactivation")
+	}
+
+	var body map[string]interface{}
+	json.Unmarshal(client.Requests()[0].Body, &body)
+	if body["licenseKey"] != "KEY-1234" || body["hardwareID"] != testHardwareID {
+		t.Errorf("deactivation body: got %v", body)
+	}
+}
+
+type lockedGuard struct{}
+
+func (lockedGuard) RequireUnlocked() error { return auth.ErrLocked }
+
+func TestLicenseChangesRequireUnlocked(t *testing.T) {
+	client := mocks.NewHTTPClient(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+	})
+	s := newTestService(t, client)
+	installLicense(t, s, testHardwareID, time.Now().AddDate(0, 0, 30))
+	s.SetGuard(lockedGuard{})
+
+	results := map[string]LicenseActionResult{
+		"register":   s.RegisterLicense("KEY-5678"),
+		"deactivate": s.DeactivateLicense(),
+		"import":     s.ImportActivation("missing.json"),
+		"importData": s.ImportActivationData("{}"),
+	}
+	for name, result := range results {
+		if result.Success || result.Message != auth.ErrLocked.Error() {
+			t.Errorf("%s while locked: got %+v", name, result)
+		}
+	}
+	if len(client.Requests()) != 0 {
+		t.Errorf("license server contacted while locked: %d requests", len(client.Requests()))
+	}
+	if !s.IsLicensed() || s.licenseInfo.LicenseKey != "KEY-1234" {
+		t.Error("license changed while locked")
+	}
+}
@@ -0,0 +1,278 @@
+package licenseservice
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"jarvist/internal/wails/services/device"
+)
+
+const (
+	// activationRequestVersion is the format of the activation request file
+	activationRequestVersion = 1
+	// maxActivationFileSize bounds an imported activation file
+	maxActivationFileSize = 64 * 1024
+)
+
+// ActivationRequest is the file a site without internet access sends to the vendor. It is
+// signed with an HMAC of the build's license secret, so the vendor can tell it was made by
+// the app and not edited afterwards.
+type ActivationRequest struct {
+	Version        int                    `json:"version"`
+	RequestID      string                 `json:"request_id"`
+	LicenseKey     string                 `json:"license_key"`
+	DeviceID       string                 `json:"device_id"`
+	DeviceName     string                 `json:"device_name"`
+	DeviceOS       string                 `json:"device_os"`
+	DeviceHardware device.HardwareSummary `json:"device_hardware"`
+	AppVersion     string                 `json:"app_version"`
+	CreatedAt      time.Time              `json:"created_at"`
+	Signature      string                 `json:"signature,omitempty"`
+}
+
+// activationFile is the activation issued by the vendor: the grant as JSON, base64 encoded,
+// and its ed25519 signature
+type activationFile struct {
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+// activationGrant is the signed content of an activation file
+type activationGrant struct {
+	RequestID   string    `json:"request_id"`
+	LicenseKey  string    `json:"license_key"`
+	DeviceID    string    `json:"device_id"`
+	ApiKey      string    `json:"api_key"`
+	TenantID    string    `json:"tenant_id"`
+	ClientID    float64   `json:"client_id"`
+	Company     string    `json:"company"`
+	ContactName string    `json:"contact_name"`
+	Email       string    `json:"email"`
+	ValidFrom   time.Time `json:"valid_from"`
+	ValidUntil  time.Time `json:"valid_until"`
+}
+
+// SetActivationPublicKey sets the base64 ed25519 public key used to verify offline activations
+func (s *LicenseService) SetActivationPublicKey(publicKey string) {
+	s.activationPublicKey = publicKey
+}
+
+// CreateActivationRequest returns the signed activation request of this machine as JSON, to
+// be sent to the vendor when the machine cannot reach the license server
+func (s *LicenseService) CreateActivationRequest(licenseKey string) (string, error) {
+	licenseKey = strings.TrimSpace(licenseKey)
+	if licenseKey == "" {
+		return "", errors.New("license key is required")
+	}
+
+	hardwareID, err := s.GetHardwareFingerprint()
+	if err != nil {
+		return "", fmt.Errorf("failed to identify this machine: %w", err)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	deviceInfo := s.device.GetDeviceInfo()
+	request := ActivationRequest{
+		Version:        activationRequestVersion,
+		RequestID:      hex.EncodeToString(id),
+		LicenseKey:     licenseKey,
+		DeviceID:       hardwareID,
+		DeviceName:     deviceInfo.Name,
+		DeviceOS:       deviceInfo.OS,
+		DeviceHardware: deviceInfo.Summary(),
+		AppVersion:     s.config.AppVersion,
+		CreatedAt:      time.Now().UTC(),
+	}
+	request.Signature, err = s.signRequest(request)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(request, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Info("Offline activation request %s created for license %s", request.RequestID, s.maskLicenseKey(licenseKey))
+	return string(data), nil
+}
+
+// SaveActivationRequest writes the activation request of this machine to path
+func (s *LicenseService) SaveActivationRequest(licenseKey, path string) error {
+	data, err := s.CreateActivationRequest(licenseKey)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		return fmt.Errorf("failed to write activation request: %w", err)
+	}
+	return nil
+}
+
+// VerifyActivationRequest checks the signature of an activation request made by this build
+func (s *LicenseService) VerifyActivationRequest(data string) (ActivationRequest, error) {
+	var request ActivationRequest
+	if err := json.Unmarshal([]byte(data), &request); err != nil {
+		return request, fmt.Errorf("invalid activation request: %w", err)
+	}
+
+	expected, err := s.signRequest(request)
+	if err != nil {
+		return request, err
+	}
+	if !hmac.Equal([]byte(expected), []byte(request.Signature)) {
+		return request, errors.New("activation request signature does not match")
+	}
+	return request, nil
+}
+
+// signRequest returns the HMAC of the request without its signature
+func (s *LicenseService) signRequest(request ActivationRequest) (string, error) {
+	request.Signature = ""
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, s.encryption.Key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// ImportActivation reads an activation file issued by the vendor and activates the license
+// without contacting the license server
+func (s *LicenseService) ImportActivation(path string) LicenseActionResult {
+	if err := s.requireUnlocked(); err != nil {
+		return LicenseActionResult{Message: err.Error()}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return LicenseActionResult{Message: "Failed to read activation file: " + err.Error()}
+	}
+	if info.Size() > maxActivationFileSize {
+		return LicenseActionResult{Message: "Activation file is too large"}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return LicenseActionResult{Message: "Failed to read activation file: " + err.Error()}
+	}
+	return s.ImportActivationData(string(data))
+}
+
+// ImportActivationData activates the license from the content of an activation file
+func (s *LicenseService) ImportActivationData(data string) LicenseActionResult {
+	var result LicenseActionResult
+	if err := s.requireUnlocked(); err != nil {
+		result.Message = err.Error()
+		return result
+	}
+
+	if len(data) > maxActivationFileSize {
+		result.Message = "Activation file is too large"
+		return result
+	}
+
+	grant, err := s.verifyActivation([]byte(strings.TrimSpace(data)))
+	if err != nil {
+		s.logger.Warning("Offline activation rejected: %v", err)
+		result.Message = "Invalid activation file: " + err.Error()
+		return result
+	}
+
+	hardwareID, err := s.GetHardwareFingerprint()
+	if err != nil {
+		result.Message = "Failed to identify this machine"
+		return result
+	}
+	if grant.DeviceID != hardwareID {
+		result.Message = "Activation file was issued for another machine"
+		return result
+	}
+	if !grant.ValidUntil.After(time.Now()) {
+		result.Message = "Activation file has expired"
+		return result
+	}
+
+	s.licenseInfo = &LicenseInfo{
+		LicenseKey:  grant.LicenseKey,
+		HardwareID:  hardwareID,
+		ApiKey:      grant.ApiKey,
+		TenantId:    grant.TenantID,
+		ClientID:    grant.ClientID,
+		Company:     grant.Company,
+		ContactName: grant.ContactName,
+		Email:       grant.Email,
+		IssuedDate:  grant.ValidFrom,
+		ExpiryDate:  grant.ValidUntil,
+		Activated:   true,
+		Offline:     true,
+	}
+	if err := s.saveLicense(); err != nil {
+		s.logger.Error("Failed to save license: %v", err)
+		result.Message = "License validated but failed to save locally: " + err.Error()
+		return result
+	}
+	s.LoadLicense()
+	s.evaluateDegradation()
+
+	s.logger.Info("License %s activated offline from request %s", s.maskLicenseKey(grant.LicenseKey), grant.RequestID)
+	result.Success = true
+	result.Message = "License successfully activated offline"
+	return result
+}
+
+// verifyActivation checks the vendor signature of an activation file and returns its grant.
+// Development builds without a public key accept unsigned files.
+func (s *LicenseService) verifyActivation(data []byte) (activationGrant, error) {
+	var grant activationGrant
+
+	var file activationFile
+	if err := json.Unmarshal(data, &file); err != nil || file.Payload == "" {
+		return grant, errors.New("not an activation file")
+	}
+	payload, err := base64.StdEncoding.DecodeString(file.Payload)
+	if err != nil {
+		return grant, errors.New("malformed payload")
+	}
+
+	if s.activationPublicKey != "" || !s.config.IsDev() {
+		if s.activationPublicKey == "" {
+			return grant, errors.New("activation signing key not configured")
+		}
+		publicKey, err := base64.StdEncoding.DecodeString(s.activationPublicKey)
+		if err != nil || len(publicKey) != ed25519.PublicKeySize {
+			return grant, errors.New("invalid activation signing key")
+		}
+		signature, err := base64.StdEncoding.DecodeString(file.Signature)
+		if err != nil || file.Signature == "" {
+			return grant, errors.New("missing or malformed signature")
+		}
+		if !ed25519.Verify(ed25519.PublicKey(publicKey), payload, signature) {
+			return grant, errors.New("signature verification failed")
+		}
+	}
+
+	if err := json.Unmarshal(payload, &grant); err != nil {
+		return grant, fmt.Errorf("malformed grant: %w", err)
+	}
+	if grant.LicenseKey == "" || grant.DeviceID == "" || grant.ApiKey == "" {
+		return grant, errors.New("grant is missing the license key, device or API key")
+	}
+	return grant, nil
+}
@@ -0,0 +1,121 @@
+package licenseservice
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"jarvist/internal/wails/services/mocks"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signActivation returns an activation file for grant signed with key
+func signActivation(t *testing.T, key ed25519.PrivateKey, grant activationGrant) string {
+	t.Helper()
+
+	payload, err := json.Marshal(grant)
+	if err != nil {
+		t.Fatalf("marshal grant: %v", err)
+	}
+	data, err := json.Marshal(activationFile{
+		Payload:   base64.StdEncoding.EncodeToString(payload),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload)),
+	})
+	if err != nil {
+		t.Fatalf("marshal file: %v", err)
+	}
+	return string(data)
+}
+
+func TestImportActivation(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	s := newTestService(t, &mocks.HTTPClient{})
+
+	grant := activationGrant{
+		RequestID:  "req-1",
+		LicenseKey: "KEY-OFFLINE",
+		DeviceID:   testHardwareID,
+		ApiKey:     "api-key",
+		TenantID:   "tenant",
+		ValidFrom:  time.Now().Add(-time.Hour),
+		ValidUntil: time.Now().AddDate(1, 0, 0),
+	}
+	file := signActivation(t, privateKey, grant)
+
+	if result := s.ImportActivationData(file); result.Success {
+		t.Fatal("activation accepted without a signing key")
+	}
+	s.SetActivationPublicKey(base64.StdEncoding.EncodeToString(publicKey))
+
+	otherMachine := grant
+	otherMachine.DeviceID = "HW-OTHER"
+	expired := grant
+	expired.ValidUntil = time.Now().Add(-time.Hour)
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	for name, data := range map[string]string{
+		"other machine": signActivation(t, privateKey, otherMachine),
+		"expired":       signActivation(t, privateKey, expired),
+		"other key":     signActivation(t, otherKey, grant),
+		"not a file":    `{"license_key":"KEY-OFFLINE"}`,
+	} {
+		if result := s.ImportActivationData(data); result.Success {
+			t.Errorf("%s: activation accepted", name)
+		}
+	}
+	if s.IsLicensed() {
+		t.Fatal("licensed after rejected activations")
+	}
+
+	if result := s.ImportActivationData(file); !result.Success {
+		t.Fatalf("activation rejected: %s", result.Message)
+	}
+	if !s.IsLicensed() || !s.licenseInfo.Offline || s.config.ApiKey != "api-key" {
+		t.Fatalf("license after activation = %+v", s.licenseInfo)
+	}
+
+	// Lisensi offline dilepas tanpa menghubungi server
+	client := s.http.(*mocks.HTTPClient)
+	if result := s.DeactivateLicense(); !result.Success {
+		t.Fatalf("deactivate: %s", result.Message)
+	}
+	if len(client.Requests()) != 0 || s.IsLicensed() {
+		t.Fatal("offline license deactivated through the server")
+	}
+}
+
+func TestVerifyActivationRequest(t *testing.T) {
+	s := newTestService(t, &mocks.HTTPClient{})
+
+	request := ActivationRequest{
+		Version:    activationRequestVersion,
+		RequestID:  "req-1",
+		LicenseKey: "KEY-OFFLINE",
+		DeviceID:   testHardwareID,
+		CreatedAt:  time.Now().UTC(),
+	}
+	var err error
+	if request.Signature, err = s.signRequest(request); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	data, _ := json.Marshal(request)
+
+	if _, err := s.VerifyActivationRequest(string(data)); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	tampered := strings.Replace(string(data), testHardwareID, "HW-OTHER", 1)
+	if _, err := s.VerifyActivationRequest(tampered); err == nil {
+		t.Fatal("edited request accepted")
+	}
+
+	other := newTestService(t, &mocks.HTTPClient{})
+	other.encryption.Key = []byte("another build secret")
+	if _, err := other.VerifyActivationRequest(string(data)); err == nil {
+		t.Fatal("request accepted by another build")
+	}
+}
@@ -1,31 +1,84 @@
 package location
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/config"
 	"jarvist/internal/common/models"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"gorm.io/gorm"
 )
 
+const (
+	// maxFloorplanSize limits an uploaded floorplan image
+	maxFloorplanSize = 10 * 1024 * 1024
+
+	// maxLocationDepth limits the location hierarchy, deeper trees are treated as a cycle
+	maxLocationDepth = 32
+)
+
+// floorplanTypes maps the accepted image types to their file extension
+var floorplanTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
 type LocationService struct {
-	DB *gorm.DB
+	DB       *gorm.DB
+	config   *config.Config
+	onChange func()
 }
 
-func New(db *gorm.DB) *LocationService {
+func New(db *gorm.DB, cfg *config.Config) *LocationService {
 	return &LocationService{
-		DB: db,
+		DB:     db,
+		config: cfg,
+	}
+}
+
+// SetOnChange sets the function called after a location or its floorplan changed, used
+// to sync the site metadata to the backend
+func (s *LocationService) SetOnChange(onChange func()) {
+	s.onChange = onChange
+}
+
+func (s *LocationService) changed() {
+	if s.onChange != nil {
+		s.onChange()
 	}
 }
 
 func (s *LocationService) CreateLocation(input models.LocationInput) (*models.Location, error) {
+	if err := models.ValidateCoordinates(input.Latitude, input.Longitude); err != nil {
+		return nil, err
+	}
+
+	if err := s.validateParent("", input.ParentID); err != nil {
+		return nil, err
+	}
+
 	location := &models.Location{
 		Name:        input.Name,
 		Description: input.Description,
+		Address:     input.Address,
+		Latitude:    input.Latitude,
+		Longitude:   input.Longitude,
+		ParentID:    input.ParentID,
+		Category:    input.Category,
 	}
 
 	if err := s.DB.Create(location).Error; err != nil {
 		return nil, err
 	}
 
+	s.changed()
 	return location, nil
 }
 
@@ -39,24 +92,177 @@ func (s *LocationService) ListLocations() ([]models.Location, error) {
 }
 
 func (s *LocationService) UpdateLocation(id string, input models.LocationInput) (*models.Location, error) {
+	if err := models.ValidateCoordinates(input.Latitude, input.Longitude); err != nil {
+		return nil, err
+	}
+
 	var location models.Location
 	result := s.DB.First(&location, "id = ?", id)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 
+	if err := s.validateParent(location.ID, input.ParentID); err != nil {
+		return nil, err
+	}
+
 	location.Name = input.Name
 	location.Description = input.Description
+	location.Address = input.Address
+	location.Latitude = input.Latitude
+	location.Longitude = input.Longitude
+	location.ParentID = input.ParentID
+	location.Category = input.Category
+	// Lokasi dari backend yang diubah lokal tidak ditimpa sync berikutnya
+	location.LocalModified = location.RemoteID != ""
 
 	result = s.DB.Save(&location)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 
+	s.changed()
 	return &location, nil
 }
 
 func (s *LocationService) DeleteLocation(id string) error {
+	var location models.Location
+	if err := s.DB.First(&location, "id = ?", id).Error; err != nil {
+		return err
+	}
+
 	result := s.DB.Delete(&models.Location{}, "id = ?", id)
-	return result.Error
+	if result.Error != nil {
+		return result.Error
+	}
+
+	// Anak lokasi naik ke parent lokasi yang dihapus
+	if err := s.DB.Model(&models.Location{}).Where("parent_id = ?", id).Update("parent_id", location.ParentID).Error; err != nil {
+		return err
+	}
+
+	if location.FloorplanPath != "" {
+		os.Remove(location.FloorplanPath)
+	}
+
+	s.changed()
+	return nil
+}
+
+// validateParent checks that parentID exists and is not the location itself or one of its
+// children
+func (s *LocationService) validateParent(id string, parentID *string) error {
+	if parentID == nil || *parentID == "" {
+		return nil
+	}
+
+	current := *parentID
+	for depth := 0; current != ""; depth++ {
+		if current == id || depth > maxLocationDepth {
+			return errors.New("a location cannot be placed under itself")
+		}
+
+		var parent models.Location
+		if err := s.DB.Select("id", "parent_id").First(&parent, "id = ?", current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("parent location not found")
+			}
+			return err
+		}
+
+		current = ""
+		if parent.ParentID != nil {
+			current = *parent.ParentID
+		}
+	}
+	return nil
+}
+
+// UploadFloorplan stores the floorplan image of a location. data is the image as base64,
+// optionally as a data URL, and replaces an existing floorplan.
+func (s *LocationService) UploadFloorplan(id string, data string) (*models.Location, error) {
+	var location models.Location
+	if err := s.DB.First(&location, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	if _, encoded, ok := strings.Cut(data, ";base64,"); ok {
+		data = encoded
+	}
+	image, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid floorplan image: %w", err)
+	}
+	if len(image) == 0 {
+		return nil, errors.New("floorplan image is empty")
+	}
+	if len(image) > maxFloorplanSize {
+		return nil, fmt.Errorf("floorplan image is larger than %d MB", maxFloorplanSize/1024/1024)
+	}
+
+	ext, ok := floorplanTypes[http.DetectContentType(image)]
+	if !ok {
+		return nil, errors.New("floorplan must be a PNG, JPEG, GIF or WebP image")
+	}
+
+	dir := filepath.Join(s.config.DataDir, "floorplans")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create floorplan directory: %w", err)
+	}
+
+	path := filepath.Join(dir, location.ID+ext)
+	if err := os.WriteFile(path, image, 0644); err != nil {
+		return nil, fmt.Errorf("failed to save floorplan: %w", err)
+	}
+
+	// Gambar lama dengan ekstensi lain dihapus
+	if location.FloorplanPath != "" && location.FloorplanPath != path {
+		os.Remove(location.FloorplanPath)
+	}
+
+	location.FloorplanPath = path
+	if err := s.DB.Model(&location).Update("floorplan_path", path).Error; err != nil {
+		return nil, err
+	}
+
+	s.changed()
+	return &location, nil
+}
+
+// GetFloorplan returns the floorplan of a location as data URL, empty if none is uploaded
+func (s *LocationService) GetFloorplan(id string) (string, error) {
+	var location models.Location
+	if err := s.DB.First(&location, "id = ?", id).Error; err != nil {
+		return "", err
+	}
+	if location.FloorplanPath == "" {
+		return "", nil
+	}
+
+	image, err := os.ReadFile(location.FloorplanPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read floorplan: %w", err)
+	}
+	return "data:" + http.DetectContentType(image) + ";base64," + base64.StdEncoding.EncodeToString(image), nil
+}
+
+// DeleteFloorplan removes the floorplan of a location
+func (s *LocationService) DeleteFloorplan(id string) error {
+	var location models.Location
+	if err := s.DB.First(&location, "id = ?", id).Error; err != nil {
+		return err
+	}
+	if location.FloorplanPath == "" {
+		return nil
+	}
+
+	if err := os.Remove(location.FloorplanPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete floorplan: %w", err)
+	}
+	if err := s.DB.Model(&location).Update("floorplan_path", "").Error; err != nil {
+		return err
+	}
+
+	s.changed()
+	return nil
 }
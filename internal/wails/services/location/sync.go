@@ -0,0 +1,331 @@
+package location
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"jarvist/internal/common/bandwidth"
+	"jarvist/internal/common/models"
+	"jarvist/internal/common/residency"
+	"net/http"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Conflict strategies of RefreshLocations
+const (
+	StrategyKeepLocal    = "keep_local"    // Lokasi yang diubah lokal tidak ditimpa
+	StrategyPreferRemote = "prefer_remote" // Backend menimpa perubahan lokal
+)
+
+// RemoteLocation is a node of the location tree of the tenant in the backend
+type RemoteLocation struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Category    string           `json:"category"`
+	ParentID    string           `json:"parent_id"`
+	Children    []RemoteLocation `json:"children"`
+}
+
+type remoteLocationsResponse struct {
+	Success bool             `json:"success"`
+	Code    int              `json:"code"`
+	Message string           `json:"message"`
+	Data    []RemoteLocation `json:"data"`
+}
+
+// SyncConflict is a location the sync did not change as the backend asked
+type SyncConflict struct {
+	LocationID string `json:"location_id"`
+	RemoteID   string `json:"remote_id"`
+	Name       string `json:"name"`
+	Reason     string `json:"reason"`
+}
+
+// SyncResult describes a location refresh from the backend
+type SyncResult struct {
+	Created   int            `json:"created"`
+	Updated   int            `json:"updated"`
+	Linked    int            `json:"linked"` // Lokasi lokal yang dicocokkan berdasarkan nama
+	Removed   int            `json:"removed"`
+	Unchanged int            `json:"unchanged"`
+	Conflicts []SyncConflict `json:"conflicts"`
+	SyncedAt  time.Time      `json:"synced_at"`
+}
+
+// LocationNode is a location with its children, used for the config dropdowns
+type LocationNode struct {
+	models.Location
+	Children []LocationNode `json:"children"`
+}
+
+// RefreshLocations pulls the location tree of the tenant from the backend and merges it
+// into the local table. Locations are matched on their remote ID, or on their name for
+// locations created locally. Local edits are kept with StrategyKeepLocal and reported
+// as conflicts. Remote locations that were removed are deleted unless cameras use them.
+func (s *LocationService) RefreshLocations(ctx context.Context, strategy string) (SyncResult, error) {
+	if residency.LocalOnly() {
+		return SyncResult{}, residency.ErrLocalOnly
+	}
+
+	if strategy == "" {
+		strategy = StrategyKeepLocal
+	}
+	if strategy != StrategyKeepLocal && strategy != StrategyPreferRemote {
+		return SyncResult{}, fmt.Errorf("unknown conflict strategy %q", strategy)
+	}
+
+	remote, err := s.fetchRemoteLocations(ctx)
+	if err != nil {
+		return SyncResult{}, err
+	}
+
+	result := SyncResult{Conflicts: []SyncConflict{}, SyncedAt: time.Now()}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		return mergeLocations(tx, flattenRemote(remote, ""), strategy, &result)
+	})
+	if err != nil {
+		return SyncResult{}, fmt.Errorf("failed to merge locations: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetLocationTree returns the locations as a tree, sorted by name
+func (s *LocationService) GetLocationTree() ([]LocationNode, error) {
+	locations, err := s.ListLocations()
+	if err != nil {
+		return nil, err
+	}
+
+	exists := make(map[string]bool, len(locations))
+	for _, location := range locations {
+		exists[location.ID] = true
+	}
+
+	children := make(map[string][]models.Location)
+	for _, location := range locations {
+		parent := ""
+		if location.ParentID != nil && exists[*location.ParentID] {
+			parent = *location.ParentID
+		}
+		children[parent] = append(children[parent], location)
+	}
+
+	var build func(parent string, depth int) []LocationNode
+	build = func(parent string, depth int) []LocationNode {
+		nodes := []LocationNode{}
+		if depth > maxLocationDepth {
+			return nodes
+		}
+		for _, location := range children[parent] {
+			nodes = append(nodes, LocationNode{Location: location, Children: build(location.ID, depth+1)})
+		}
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+		return nodes
+	}
+	return build("", 0), nil
+}
+
+func (s *LocationService) fetchRemoteLocations(ctx context.Context) ([]RemoteLocation, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.config.ApiUrl+"/v1/app/location-tree", nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+
+	req.Header.Add("content-type", "application/json")
+	req.Header.Add("X-API-Key", s.config.ApiKey)
+
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: bandwidth.NewTransport(bandwidth.DestinationAPI),
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %v", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %v", err)
+	}
+
+	var response remoteLocationsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("error unmarshalling response: %v", err)
+	}
+	if !response.Success {
+		return nil, fmt.Errorf("API returned error: %s", response.Message)
+	}
+
+	return response.Data, nil
+}
+
+// flattenRemote returns the nodes of a nested tree with their parent set, parents first
+func flattenRemote(nodes []RemoteLocation, parentID string) []RemoteLocation {
+	var flat []RemoteLocation
+	for _, node := range nodes {
+		children := node.Children
+		node.Children = nil
+		if parentID != "" {
+			node.ParentID = parentID
+		}
+		flat = append(flat, node)
+		flat = append(flat, flattenRemote(children, node.ID)...)
+	}
+	return flat
+}
+
+func mergeLocations(tx *gorm.DB, remote []RemoteLocation, strategy string, result *SyncResult) error {
+	var locations []*models.Location
+	if err := tx.Find(&locations).Error; err != nil {
+		return err
+	}
+
+	byRemoteID := make(map[string]*models.Location)
+	for _, location := range locations {
+		if location.RemoteID != "" {
+			byRemoteID[location.RemoteID] = location
+		}
+	}
+
+	syncedAt := result.SyncedAt.Format(time.RFC3339)
+	seen := make(map[string]bool, len(remote))
+
+	// Pass pertama membuat dan memperbarui lokasi, parent diatur setelah semua ID lokal diketahui
+	for _, node := range remote {
+		if node.ID == "" || seen[node.ID] {
+			continue
+		}
+		seen[node.ID] = true
+
+		local := byRemoteID[node.ID]
+		if local == nil {
+			local = findUnlinked(locations, node.Name)
+			if local != nil {
+				local.RemoteID = node.ID
+				byRemoteID[node.ID] = local
+				result.Linked++
+			}
+		} else if local.LocalModified && strategy == StrategyKeepLocal {
+			result.Conflicts = append(result.Conflicts, SyncConflict{
+				LocationID: local.ID,
+				RemoteID:   node.ID,
+				Name:       local.Name,
+				Reason:     "changed locally since the last sync",
+			})
+			continue
+		}
+
+		if local == nil {
+			location := &models.Location{
+				Name:        node.Name,
+				Description: node.Description,
+				Category:    node.Category,
+				RemoteID:    node.ID,
+				SyncedAt:    &syncedAt,
+			}
+			if err := tx.Create(location).Error; err != nil {
+				return err
+			}
+			byRemoteID[node.ID] = location
+			result.Created++
+			continue
+		}
+
+		changed := local.Name != node.Name || local.Description != node.Description || local.Category != node.Category
+		local.Name = node.Name
+		local.Description = node.Description
+		local.Category = node.Category
+		local.LocalModified = false
+		local.SyncedAt = &syncedAt
+		if err := tx.Save(local).Error; err != nil {
+			return err
+		}
+		if changed {
+			result.Updated++
+		} else {
+			result.Unchanged++
+		}
+	}
+
+	// Pass kedua mengatur parent sesuai tree di backend
+	for _, node := range remote {
+		local := byRemoteID[node.ID]
+		if local == nil || (local.LocalModified && strategy == StrategyKeepLocal) {
+			continue
+		}
+
+		var parentID *string
+		if parent := byRemoteID[node.ParentID]; node.ParentID != "" && parent != nil {
+			parentID = &parent.ID
+		}
+		if equalParent(local.ParentID, parentID) {
+			continue
+		}
+		if err := tx.Model(local).Update("parent_id", parentID).Error; err != nil {
+			return err
+		}
+	}
+
+	// Lokasi yang sudah dihapus di backend
+	for _, location := range locations {
+		if location.RemoteID == "" || seen[location.RemoteID] {
+			continue
+		}
+
+		var cameras int64
+		if err := tx.Model(&models.Camera{}).Where("location_id = ? AND deleted_at IS NULL", location.ID).Count(&cameras).Error; err != nil {
+			return err
+		}
+
+		if cameras > 0 || (location.LocalModified && strategy == StrategyKeepLocal) {
+			reason := "removed in the backend but changed locally, kept as local location"
+			if cameras > 0 {
+				reason = fmt.Sprintf("removed in the backend but used by %d cameras, kept as local location", cameras)
+			}
+			result.Conflicts = append(result.Conflicts, SyncConflict{
+				LocationID: location.ID,
+				RemoteID:   location.RemoteID,
+				Name:       location.Name,
+				Reason:     reason,
+			})
+			if err := tx.Model(&models.Location{}).Where("id = ?", location.ID).Update("remote_id", "").Error; err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := tx.Delete(&models.Location{}, "id = ?", location.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Location{}).Where("parent_id = ?", location.ID).Update("parent_id", location.ParentID).Error; err != nil {
+			return err
+		}
+		result.Removed++
+	}
+
+	return nil
+}
+
+// findUnlinked returns a local location without remote ID with the given name
+func findUnlinked(locations []*models.Location, name string) *models.Location {
+	for _, location := range locations {
+		if location.RemoteID == "" && location.Name == name {
+			return location
+		}
+	}
+	return nil
+}
+
+func equalParent(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"jarvist/internal/common/config"
+	"jarvist/internal/wails/services/usage"
 	"os"
 	"path/filepath"
 	"sort"
@@ -38,6 +39,8 @@ func New(cfg *config.Config) *LogService {
 
 // ReadLogs reads log files from all log directories
 func (s *LogService) ReadLogs() ([]string, error) {
+	usage.Feature(usage.LogView)
+
 	var allLogs []string
 
 	// Read service logs
@@ -0,0 +1,200 @@
+// Package mocks has fakes of the service dependencies in package deps for unit tests
+package mocks
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"jarvist/internal/wails/services/deps"
+)
+
+// Request is a request received by HTTPClient, with its body read
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   []byte
+}
+
+// HTTPClient answers requests with Handler and records them. Err fails every request as if
+// the server could not be reached.
+type HTTPClient struct {
+	Handler http.Handler
+	Err     error
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+// NewHTTPClient returns a client answering requests with handler
+func NewHTTPClient(handler http.HandlerFunc) *HTTPClient {
+	return &HTTPClient{Handler: handler}
+}
+
+func (c *HTTPClient) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	c.mu.Lock()
+	c.requests = append(c.requests, Request{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  req.URL.RawQuery,
+		Header: req.Header.Clone(),
+		Body:   body,
+	})
+	err, handler := c.Err, c.Handler
+	c.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	if handler == nil {
+		return nil, errors.New("mocks: no handler")
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	response := recorder.Result()
+	response.Request = req
+	return response, nil
+}
+
+// Requests returns the requests received so far
+func (c *HTTPClient) Requests() []Request {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Request(nil), c.requests...)
+}
+
+// Event is an event emitted through Emitter
+type Event struct {
+	Name string
+	Data any
+}
+
+// Emitter records the emitted events
+type Emitter struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (e *Emitter) Emit(name string, data any) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, Event{Name: name, Data: data})
+}
+
+// Events returns the emitted events in order
+func (e *Emitter) Events() []Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Event(nil), e.events...)
+}
+
+// Named returns the emitted events with the given name
+func (e *Emitter) Named(name string) []Event {
+	var named []Event
+	for _, event := range e.Events() {
+		if event.Name == name {
+			named = append(named, event)
+		}
+	}
+	return named
+}
+
+// ProcessRunner records the started commands instead of running them. StartErr fails every
+// start, WaitErr is returned by the started processes.
+type ProcessRunner struct {
+	StartErr error
+	WaitErr  error
+
+	mu        sync.Mutex
+	commands  [][]string
+	processes []*Process
+}
+
+func (r *ProcessRunner) Start(name string, args ...string) (deps.Process, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.commands = append(r.commands, append([]string{name}, args...))
+	if r.StartErr != nil {
+		return nil, r.StartErr
+	}
+
+	process := &Process{WaitErr: r.WaitErr, done: make(chan struct{})}
+	r.processes = append(r.processes, process)
+	return process, nil
+}
+
+// Commands returns the started commands, each with its name first
+func (r *ProcessRunner) Commands() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]string(nil), r.commands...)
+}
+
+// Processes returns the started processes
+func (r *ProcessRunner) Processes() []*Process {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Process(nil), r.processes...)
+}
+
+// Process is a started fake process, Wait returns once Exit or Kill is called
+type Process struct {
+	WaitErr error
+
+	mu     sync.Mutex
+	killed bool
+	done   chan struct{}
+	once   sync.Once
+}
+
+func (p *Process) Wait() error {
+	<-p.done
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.killed {
+		return errors.New("killed")
+	}
+	return p.WaitErr
+}
+
+func (p *Process) Kill() error {
+	p.mu.Lock()
+	p.killed = true
+	p.mu.Unlock()
+	p.Exit()
+	return nil
+}
+
+// Exit ends the process
+func (p *Process) Exit() {
+	p.once.Do(func() { close(p.done) })
+}
+
+// Killed reports whether the process was killed
+func (p *Process) Killed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.killed
+}
+
+// HardwareID returns a hardware ID function that always returns id
+func HardwareID(id string) func() (string, error) {
+	return func() (string, error) { return id, nil }
+}
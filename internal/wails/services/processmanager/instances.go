@@ -0,0 +1,422 @@
+package processmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/common/config"
+	"jarvist/pkg/utils"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// CounterProcess is the batch file of the people counter
+	CounterProcess = "people_counter.bat"
+	// SyncManagerProcess is the batch file of the sync manager
+	SyncManagerProcess = "sync_manager.bat"
+
+	// CounterInstancesFile holds the counter instance definitions in the services directory,
+	// the sync service reads it for the save interval of each camera
+	CounterInstancesFile = config.CounterInstancesFile
+
+	counterInstancePrefix = "people_counter@"
+)
+
+// Environment variables set for every counter instance, they cannot be overridden
+const (
+	EnvCounterInstance = "COUNTER_INSTANCE"
+	EnvCounterProcess  = "COUNTER_PROCESS_ID"
+	EnvCameraConfig    = "CAMERA_CONFIG_PATH"
+	EnvPidFile         = "COUNTER_PID_FILE"
+	EnvStatusFile      = "COUNTER_STATUS_FILE"
+	EnvStatusEndpoint  = "COUNTER_STATUS_ENDPOINT"
+	EnvReloadSignal    = "COUNTER_RELOAD_SIGNAL"
+)
+
+var (
+	ErrUnknownInstance = errors.New("counter instance not found")
+	ErrInstanceRunning = errors.New("counter instance is running, stop it first")
+
+	instanceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+	reservedEnv         = []string{EnvCounterInstance, EnvCounterProcess, EnvCameraConfig, EnvPidFile, EnvStatusFile, EnvStatusEndpoint, EnvReloadSignal}
+)
+
+// CounterInstance is one people_counter process counting a subset of the cameras.
+// When instances are defined they replace the single default counter.
+type CounterInstance struct {
+	Name    string `json:"name"`
+	Cameras []uint `json:"cameras"`
+	// ConfigPath is the camera config of the instance, relative paths are resolved against
+	// the camera config directory. Empty means config.camera.<name>.json.
+	ConfigPath string            `json:"config_path,omitempty"`
+	Env        map[string]string `json:"env,omitempty"`
+}
+
+// CounterInstanceStatus is the state of one instance shown in the UI
+type CounterInstanceStatus struct {
+	CounterInstance
+	ProcessId      string `json:"process_id"`
+	ConfigFile     string `json:"config_file"`
+	Status         string `json:"status"`
+	Running        bool   `json:"running"`
+	ConfigExported bool   `json:"config_exported"`
+}
+
+// InstanceProcessId returns the process id of a counter instance
+func InstanceProcessId(name string) string {
+	return counterInstancePrefix + name + ".bat"
+}
+
+// instanceName returns the instance name of a process id, false for the fixed processes
+func instanceName(processId string) (string, bool) {
+	processId = normalizeProcessId(processId)
+	if !strings.HasPrefix(processId, counterInstancePrefix) {
+		return "", false
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(processId, counterInstancePrefix), ".bat"), true
+}
+
+// OnInstancesChanged sets the function called after an instance definition is saved or
+// deleted, used to export the camera config of every instance again
+func (s *ProcessManagerService) OnInstancesChanged(fn func(ctx context.Context) error) {
+	s.instancesMu.Lock()
+	s.onInstancesChanged = fn
+	s.instancesMu.Unlock()
+}
+
+// loadInstances reads the instance definitions, a missing file means no instances
+func (s *ProcessManagerService) loadInstances() {
+	path := filepath.Join(s.config.ServicesDir, CounterInstancesFile)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			s.logger.Error("Failed to read counter instances: %v", err)
+		}
+		return
+	}
+
+	var instances []CounterInstance
+	if err := json.Unmarshal(data, &instances); err != nil {
+		s.logger.Error("Failed to parse %s, counter instances ignored: %v", path, err)
+		return
+	}
+
+	s.instancesMu.Lock()
+	s.instances = instances
+	s.instancesMu.Unlock()
+
+	if len(instances) > 0 {
+		s.logger.Info("Loaded %d counter instances", len(instances))
+	}
+}
+
+// saveInstances writes the definitions, the caller holds instancesMu
+func (s *ProcessManagerService) saveInstances(instances []CounterInstance) error {
+	data, err := json.MarshalIndent(instances, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal counter instances: %w", err)
+	}
+
+	path := filepath.Join(s.config.ServicesDir, CounterInstancesFile)
+	if err := utils.WriteFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write counter instances: %w", err)
+	}
+
+	s.instances = instances
+	return nil
+}
+
+// GetCounterInstances returns the defined counter instances
+func (s *ProcessManagerService) GetCounterInstances() []CounterInstance {
+	s.instancesMu.Lock()
+	defer s.instancesMu.Unlock()
+
+	instances := make([]CounterInstance, len(s.instances))
+	copy(instances, s.instances)
+	return instances
+}
+
+// GetCounterInstance returns one instance definition
+func (s *ProcessManagerService) GetCounterInstance(name string) (CounterInstance, error) {
+	s.instancesMu.Lock()
+	defer s.instancesMu.Unlock()
+
+	index := slices.IndexFunc(s.instances, func(instance CounterInstance) bool { return instance.Name == name })
+	if index < 0 {
+		return CounterInstance{}, ErrUnknownInstance
+	}
+	return s.instances[index], nil
+}
+
+// SaveCounterInstance adds or replaces an instance definition. A camera can belong to
+// one instance only, otherwise it would be counted twice.
+func (s *ProcessManagerService) SaveCounterInstance(instance CounterInstance) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+
+	instance.Name = strings.ToLower(strings.TrimSpace(instance.Name))
+	instance.ConfigPath = strings.TrimSpace(instance.ConfigPath)
+
+	if err := validateInstance(instance); err != nil {
+		return err
+	}
+
+	s.instancesMu.Lock()
+
+	instances := make([]CounterInstance, 0, len(s.instances)+1)
+	replaced := false
+	for _, existing := range s.instances {
+		if existing.Name == instance.Name {
+			instances = append(instances, instance)
+			replaced = true
+			continue
+		}
+
+		for _, cameraId := range instance.Cameras {
+			if slices.Contains(existing.Cameras, cameraId) {
+				s.instancesMu.Unlock()
+				return fmt.Errorf("camera %d is already assigned to instance %s", cameraId, existing.Name)
+			}
+		}
+		if s.instanceConfigFile(existing) == s.instanceConfigFile(instance) {
+			s.instancesMu.Unlock()
+			return fmt.Errorf("config path is already used by instance %s", existing.Name)
+		}
+		instances = append(instances, existing)
+	}
+	if !replaced {
+		instances = append(instances, instance)
+	}
+
+	err := s.saveInstances(instances)
+	hook := s.onInstancesChanged
+	s.instancesMu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("Saved counter instance %s with %d cameras", instance.Name, len(instance.Cameras))
+	s.instancesChanged(hook)
+	return nil
+}
+
+// DeleteCounterInstance removes an instance definition. Its config file is left on disk.
+func (s *ProcessManagerService) DeleteCounterInstance(name string) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+	if s.IsProcessRunning(InstanceProcessId(name)) {
+		return ErrInstanceRunning
+	}
+
+	s.instancesMu.Lock()
+
+	index := slices.IndexFunc(s.instances, func(instance CounterInstance) bool { return instance.Name == name })
+	if index < 0 {
+		s.instancesMu.Unlock()
+		return ErrUnknownInstance
+	}
+
+	err := s.saveInstances(slices.Delete(slices.Clone(s.instances), index, index+1))
+	hook := s.onInstancesChanged
+	s.instancesMu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("Deleted counter instance %s", name)
+	s.removeSandbox(InstanceProcessId(name))
+	s.instancesChanged(hook)
+	return nil
+}
+
+func (s *ProcessManagerService) instancesChanged(hook func(ctx context.Context) error) {
+	if hook == nil {
+		return
+	}
+
+	go func() {
+		if err := hook(context.Background()); err != nil {
+			s.logger.Warn("Failed to export camera config after instance change: %v", err)
+		}
+	}()
+}
+
+func validateInstance(instance CounterInstance) error {
+	if !instanceNamePattern.MatchString(instance.Name) {
+		return fmt.Errorf("invalid instance name %q, use up to 32 lowercase letters, digits, - or _", instance.Name)
+	}
+
+	if len(instance.Cameras) == 0 {
+		return errors.New("an instance needs at least one camera")
+	}
+	for i, cameraId := range instance.Cameras {
+		if slices.Contains(instance.Cameras[:i], cameraId) {
+			return fmt.Errorf("camera %d is listed twice", cameraId)
+		}
+	}
+
+	for key := range instance.Env {
+		if key == "" || strings.ContainsAny(key, "= ") {
+			return fmt.Errorf("invalid environment variable name %q", key)
+		}
+		if slices.Contains(reservedEnv, strings.ToUpper(key)) {
+			return fmt.Errorf("environment variable %s is set by the process manager", key)
+		}
+	}
+
+	return nil
+}
+
+// instanceConfigFile returns the absolute camera config path of an instance
+func (s *ProcessManagerService) instanceConfigFile(instance CounterInstance) string {
+	path := instance.ConfigPath
+	if path == "" {
+		ext := filepath.Ext(s.cfg.CameraConfigName)
+		path = strings.TrimSuffix(s.cfg.CameraConfigName, ext) + "." + instance.Name + ext
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.cfg.CameraConfigPath, path)
+	}
+	return filepath.Clean(path)
+}
+
+// CounterInstanceConfigFile returns the camera config path of an instance
+func (s *ProcessManagerService) CounterInstanceConfigFile(name string) (string, error) {
+	instance, err := s.GetCounterInstance(name)
+	if err != nil {
+		return "", err
+	}
+	return s.instanceConfigFile(instance), nil
+}
+
+// CounterProcessIds returns the process ids of the people counter, one per instance or
+// the default counter when no instances are defined
+func (s *ProcessManagerService) CounterProcessIds() []string {
+	instances := s.GetCounterInstances()
+	if len(instances) == 0 {
+		return []string{CounterProcess}
+	}
+
+	ids := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		ids = append(ids, InstanceProcessId(instance.Name))
+	}
+	return ids
+}
+
+// managedProcessIds returns every process checked by the status monitor
+func (s *ProcessManagerService) managedProcessIds() []string {
+	ids := []string{CounterProcess, SyncManagerProcess}
+	for _, instance := range s.GetCounterInstances() {
+		ids = append(ids, InstanceProcessId(instance.Name))
+	}
+	return ids
+}
+
+// isManagedProcess reports whether processId is one of the managed processes
+func (s *ProcessManagerService) isManagedProcess(processId string) bool {
+	for _, id := range s.managedProcessIds() {
+		if id == processId {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestCounterReload asks every counter process to reload, used for files shared by all
+// instances like .env
+func (s *ProcessManagerService) RequestCounterReload(reason string, files ...string) {
+	for _, processId := range s.CounterProcessIds() {
+		s.RequestReload(processId, reason, files...)
+	}
+}
+
+// StartCounterInstance starts the counter process of an instance
+func (s *ProcessManagerService) StartCounterInstance(name string) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+	if _, err := s.GetCounterInstance(name); err != nil {
+		return err
+	}
+	return s.runBatFile(InstanceProcessId(name))
+}
+
+// StopCounterInstance stops the counter process of an instance
+func (s *ProcessManagerService) StopCounterInstance(name string) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+	if _, err := s.GetCounterInstance(name); err != nil {
+		return err
+	}
+	if !s.stopProcess(InstanceProcessId(name)) {
+		return fmt.Errorf("counter instance %s is not running", name)
+	}
+	return nil
+}
+
+// GetCounterInstanceStatuses returns the process state of every instance
+func (s *ProcessManagerService) GetCounterInstanceStatuses() []CounterInstanceStatus {
+	instances := s.GetCounterInstances()
+	statuses := make([]CounterInstanceStatus, 0, len(instances))
+
+	for _, instance := range instances {
+		processId := InstanceProcessId(instance.Name)
+		configFile := s.instanceConfigFile(instance)
+		_, err := os.Stat(configFile)
+
+		statuses = append(statuses, CounterInstanceStatus{
+			CounterInstance: instance,
+			ProcessId:       processId,
+			ConfigFile:      configFile,
+			Status:          s.GetDetailedProcessStatus(processId),
+			Running:         s.IsProcessRunning(processId),
+			ConfigExported:  err == nil,
+		})
+	}
+
+	return statuses
+}
+
+// launchSpec returns the batch file and the extra environment of a process id. Instances
+// run the counter batch file with their config, status files and overrides in the environment.
+func (s *ProcessManagerService) launchSpec(processId string) (string, []string, error) {
+	name, isInstance := instanceName(processId)
+	if !isInstance {
+		return processId, nil, nil
+	}
+
+	instance, err := s.GetCounterInstance(name)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %s", ErrUnknownInstance, name)
+	}
+
+	env := make([]string, 0, len(instance.Env)+len(reservedEnv))
+	for key, value := range instance.Env {
+		env = append(env, key+"="+value)
+	}
+	slices.Sort(env)
+
+	env = append(env,
+		EnvCounterInstance+"="+instance.Name,
+		EnvCounterProcess+"="+processId,
+		EnvCameraConfig+"="+s.instanceConfigFile(instance),
+		EnvPidFile+"="+filepath.Join(s.config.LogsDir, strings.Replace(processId, ".bat", "_pid.txt", 1)),
+		EnvStatusFile+"="+filepath.Join(s.config.LogsDir, strings.Replace(processId, ".bat", "_status.txt", 1)),
+		EnvStatusEndpoint+"="+s.GetStatusEndpoint(),
+		EnvReloadSignal+"="+s.reloadSignalPath(processId),
+	)
+
+	return CounterProcess, env, nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"jarvist/internal/common/config"
+	"jarvist/internal/wails/services/auth"
+	"jarvist/internal/wails/services/eventbuffer"
 	"jarvist/pkg/logger"
 	"os"
 	"os/exec"
@@ -21,6 +23,7 @@ import (
 type Config struct {
 	ServicesDir string
 	LogsDir     string
+	StatusPort  int
 }
 
 type EventData struct {
@@ -34,24 +37,46 @@ type EventData struct {
 
 type ProcessManagerService struct {
 	app             *application.App
+	events          eventbuffer.Emitter
 	processes       map[string]*exec.Cmd
+	sandboxJobs     map[string]*processSandbox
 	mu              sync.Mutex
 	cfg             *config.Config
 	config          Config
 	logger          *logger.ContextLogger
 	monitorStopChan chan struct{}
+	statusServer    *statusServer
+	reloadMu        sync.Mutex
+	pendingReload   map[string]*ReloadSignal
+
+	instancesMu        sync.Mutex
+	instances          []CounterInstance
+	onInstancesChanged func(ctx context.Context) error
+
+	sandboxMu sync.Mutex
+	sandboxes map[string]SandboxOptions
+
+	guard auth.Guard
 }
 
 func New(cfg *config.Config, logger *logger.ContextLogger) *ProcessManagerService {
-	return &ProcessManagerService{
-		processes: make(map[string]*exec.Cmd),
-		cfg:       cfg,
+	s := &ProcessManagerService{
+		processes:     make(map[string]*exec.Cmd),
+		sandboxJobs:   make(map[string]*processSandbox),
+		pendingReload: make(map[string]*ReloadSignal),
+		sandboxes:     make(map[string]SandboxOptions),
+		cfg:           cfg,
 		config: Config{
 			ServicesDir: filepath.Join(cfg.BinDir, "services"),
 			LogsDir:     filepath.Join(cfg.BinDir, "services", "logs"),
+			StatusPort:  DefaultStatusPort,
 		},
 		logger: logger.WithComponent("processmanager"),
 	}
+	s.statusServer = newStatusServer(s.logger, s.isManagedProcess, s.handleStatusReport)
+	s.loadInstances()
+	s.loadSandboxes()
+	return s
 }
 
 func (s *ProcessManagerService) OnStartup(ctx context.Context, options application.ServiceOptions) error {
@@ -60,6 +85,7 @@ func (s *ProcessManagerService) OnStartup(ctx context.Context, options applicati
 
 func (s *ProcessManagerService) OnShutdown() error {
 	s.StopStatusMonitor()
+	s.statusServer.stop()
 	return nil
 }
 
@@ -75,9 +101,74 @@ func (s *ProcessManagerService) InitService(app *application.App) {
 		s.logger.Info("Created logs directory: %s", s.config.LogsDir)
 	}
 
+	if err := s.statusServer.start(s.config.StatusPort); err != nil {
+		s.logger.Warn("Status push endpoint unavailable, falling back to status files: %v", err)
+	} else {
+		s.logger.Info("Status push endpoint listening at %s", StatusEndpoint(s.config.StatusPort))
+	}
+
 	s.StartStatusMonitor()
 }
 
+// SetGuard sets the lock guard checked before processes are started, stopped or changed
+func (s *ProcessManagerService) SetGuard(guard auth.Guard) {
+	s.guard = guard
+}
+
+func (s *ProcessManagerService) requireUnlocked() error {
+	if s.guard == nil {
+		return nil
+	}
+	return s.guard.RequireUnlocked()
+}
+
+// SetEventBuffer makes process events go through the event buffer so windows opened later
+// can fetch them
+func (s *ProcessManagerService) SetEventBuffer(events eventbuffer.Emitter) {
+	s.events = events
+}
+
+func (s *ProcessManagerService) emit(name string, data EventData) {
+	if s.events != nil {
+		s.events.Emit(name, data)
+	} else if s.app != nil {
+		s.app.EmitEvent(name, data)
+	}
+}
+
+// GetStatusEndpoint returns the URL the counter should push JSON status lines to
+func (s *ProcessManagerService) GetStatusEndpoint() string {
+	return StatusEndpoint(s.config.StatusPort)
+}
+
+// handleStatusReport dipanggil setiap kali proses mengirim status baru
+func (s *ProcessManagerService) handleStatusReport(report StatusReport, changed bool) {
+	if !changed {
+		return
+	}
+
+	s.logger.Debug("Pushed status for %s: %s (PID %d)", report.ProcessId, report.Status, report.PID)
+
+	// Tulis juga ke file status agar pembaca lama tetap konsisten
+	statusFilename := strings.Replace(report.ProcessId, ".bat", "_status.txt", 1)
+	statusPath := filepath.Join(s.config.LogsDir, statusFilename)
+	if err := os.WriteFile(statusPath, []byte(report.Status), 0644); err != nil {
+		s.logger.Error("Failed to write status file for %s: %v", report.ProcessId, err)
+	}
+
+	status, _ := formatStatus(report.Status)
+	eventData := EventData{
+		ProcessId: report.ProcessId,
+		Message:   report.Message,
+		PID:       report.PID,
+		Timestamp: report.Timestamp,
+		Success:   report.Status != "error",
+		Data:      map[string]interface{}{"status": status, "source": "push"},
+	}
+
+	s.emit("process_status_updated", eventData)
+}
+
 func (s *ProcessManagerService) StartStatusMonitor() {
 	s.logger.Info("Starting process status monitor")
 
@@ -111,11 +202,17 @@ func (s *ProcessManagerService) StopStatusMonitor() {
 
 func (s *ProcessManagerService) CheckRunningProcesses() {
 	s.logger.Debug("Checking running processes")
-	s.UpdateProcessStatusOnMissing("people_counter.bat")
-	s.UpdateProcessStatusOnMissing("sync_manager.bat")
+	processIds := s.managedProcessIds()
+	for _, processId := range processIds {
+		s.UpdateProcessStatusOnMissing(processId)
+	}
 
-	s.checkProcessWithStatus("people_counter_pid.txt", "people_counter_status.txt", "people_counter.bat")
-	s.checkProcessWithStatus("sync_manager_pid.txt", "sync_manager_status.txt", "sync_manager.bat")
+	for _, processId := range processIds {
+		s.checkProcessWithStatus(
+			strings.Replace(processId, ".bat", "_pid.txt", 1),
+			strings.Replace(processId, ".bat", "_status.txt", 1),
+			processId)
+	}
 }
 
 func (s *ProcessManagerService) checkProcessWithStatus(pidFileName, statusFileName, processId string) {
@@ -130,22 +227,15 @@ func (s *ProcessManagerService) checkProcessWithStatus(pidFileName, statusFileNa
 
 			if err == nil && s.isProcessRunningByPid(pid) {
 				status := "Running"
-				if _, err := os.Stat(statusPath); err == nil {
+				if report, ok := s.statusServer.latest(processId); ok {
+					if formatted, known := formatStatus(report.Status); known {
+						status = formatted
+					}
+				} else if _, err := os.Stat(statusPath); err == nil {
 					statusBytes, err := os.ReadFile(statusPath)
 					if err == nil {
-						rawStatus := strings.TrimSpace(string(statusBytes))
-
-						switch rawStatus {
-						case "initializing":
-							status = "Initializing"
-						case "loading":
-							status = "Loading"
-						case "running":
-							status = "Running"
-						case "stopped":
-							status = "Stopped"
-						case "error":
-							status = "Error"
+						if formatted, known := formatStatus(strings.TrimSpace(string(statusBytes))); known {
+							status = formatted
 						}
 					}
 				}
@@ -161,9 +251,7 @@ func (s *ProcessManagerService) checkProcessWithStatus(pidFileName, statusFileNa
 					Data:      map[string]interface{}{"status": status},
 				}
 
-				if s.app != nil {
-					s.app.EmitEvent("process_running", eventData)
-				}
+				s.emit("process_running", eventData)
 			}
 		}
 	}
@@ -179,6 +267,13 @@ func (s *ProcessManagerService) IsProcessRunning(processId string) bool {
 		return true
 	}
 
+	// Status push hanya dipercaya selama PID yang dilaporkan masih hidup
+	if report, ok := s.statusServer.latest(processId); ok && report.Status != "stopped" && report.Status != "error" &&
+		report.PID > 0 && s.isProcessRunningByPid(report.PID) {
+		s.logger.Debug("Process %s reported status %s via push", processId, report.Status)
+		return true
+	}
+
 	isRunning := s.checkProcessFromPidFile(processId)
 	if !isRunning {
 		s.logger.Debug("Process %s not found in PID file, updating status", processId)
@@ -262,9 +357,7 @@ func (s *ProcessManagerService) UpdateProcessStatusOnMissing(processId string) b
 			Success:   true,
 		}
 
-		if s.app != nil {
-			s.app.EmitEvent("process_status_updated", eventData)
-		}
+		s.emit("process_status_updated", eventData)
 
 		return true
 	}
@@ -319,6 +412,14 @@ func (s *ProcessManagerService) isProcessRunningByPid(pid int) bool {
 }
 
 func (s *ProcessManagerService) StopProcess(processId string) bool {
+	if err := s.requireUnlocked(); err != nil {
+		s.logger.Warn("Refused to stop process %s: %v", processId, err)
+		return false
+	}
+	return s.stopProcess(processId)
+}
+
+func (s *ProcessManagerService) stopProcess(processId string) bool {
 	s.logger.Info("Attempting to stop process %s", processId)
 
 	s.mu.Lock()
@@ -335,9 +436,7 @@ func (s *ProcessManagerService) StopProcess(processId string) bool {
 			Success:   true,
 		}
 
-		if s.app != nil {
-			s.app.EmitEvent("process_stopping", eventData)
-		}
+		s.emit("process_stopping", eventData)
 
 		if runtime.GOOS == "windows" {
 			killCmd := exec.Command("taskkill", "/F", "/T", "/PID", fmt.Sprint(cmd.Process.Pid))
@@ -350,6 +449,13 @@ func (s *ProcessManagerService) StopProcess(processId string) bool {
 			if output, err := killCmd.CombinedOutput(); err != nil {
 				s.logger.Error("Failed to kill process %s (PID %d): %v, output: %s",
 					processId, cmd.Process.Pid, err, string(output))
+
+				s.mu.Lock()
+				sandbox := s.sandboxJobs[processId]
+				s.mu.Unlock()
+				if sandbox.terminate() {
+					s.logger.Info("Terminated job object of process %s", processId)
+				}
 			} else {
 				s.logger.Info("Successfully killed process %s (PID %d)", processId, cmd.Process.Pid)
 			}
@@ -383,9 +489,7 @@ func (s *ProcessManagerService) StopProcess(processId string) bool {
 			Success:   true,
 		}
 
-		if s.app != nil {
-			s.app.EmitEvent("process_stopped", eventData)
-		}
+		s.emit("process_stopped", eventData)
 		return true
 	}
 
@@ -428,9 +532,7 @@ func (s *ProcessManagerService) stopProcessFromPidFile(processId string) bool {
 		Success:   true,
 	}
 
-	if s.app != nil {
-		s.app.EmitEvent("process_stopping", eventData)
-	}
+	s.emit("process_stopping", eventData)
 
 	if runtime.GOOS == "windows" {
 		killCmd := exec.Command("taskkill", "/F", "/T", "/PID", fmt.Sprint(pid))
@@ -476,14 +578,31 @@ func (s *ProcessManagerService) stopProcessFromPidFile(processId string) bool {
 		Success:   true,
 	}
 
-	if s.app != nil {
-		s.app.EmitEvent("process_stopped", eventData)
-	}
+	s.emit("process_stopped", eventData)
 	return true
 }
 
-func (s *ProcessManagerService) RunBatFile(batFilename string) error {
-	processId := batFilename
+// RunBatFile starts a batch file in the services directory. A counter instance id like
+// people_counter@north.bat starts people_counter.bat with the environment of the instance.
+func (s *ProcessManagerService) RunBatFile(processId string) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+	return s.runBatFile(processId)
+}
+
+func (s *ProcessManagerService) runBatFile(processId string) error {
+	batFilename, instanceEnv, err := s.launchSpec(processId)
+	if err != nil {
+		s.logger.Error("Cannot start %s: %v", processId, err)
+		s.emit("process_error", EventData{
+			ProcessId: processId,
+			Message:   "Error: " + err.Error(),
+			Timestamp: time.Now(),
+			Success:   false,
+		})
+		return err
+	}
 
 	if s.IsProcessRunning(processId) {
 		s.logger.Warn("Process %s is already running", processId)
@@ -495,9 +614,7 @@ func (s *ProcessManagerService) RunBatFile(batFilename string) error {
 			Success:   false,
 		}
 
-		if s.app != nil {
-			s.app.EmitEvent("process_error", eventData)
-		}
+		s.emit("process_error", eventData)
 		return nil
 	}
 
@@ -521,9 +638,7 @@ func (s *ProcessManagerService) RunBatFile(batFilename string) error {
 			Success:   false,
 		}
 
-		if s.app != nil {
-			s.app.EmitEvent("process_error", eventData)
-		}
+		s.emit("process_error", eventData)
 		return fmt.Errorf("batch file not found: %s", binPath)
 	}
 
@@ -536,9 +651,7 @@ func (s *ProcessManagerService) RunBatFile(batFilename string) error {
 			Success:   false,
 		}
 
-		if s.app != nil {
-			s.app.EmitEvent("process_error", eventData)
-		}
+		s.emit("process_error", eventData)
 		return nil
 	}
 
@@ -551,12 +664,46 @@ func (s *ProcessManagerService) RunBatFile(batFilename string) error {
 
 	cmd := exec.Command("cmd", "/C", "call", binPath)
 	cmd.Dir = filepath.Dir(binPath) // Set working directory ke lokasi file batch
+	if len(instanceEnv) > 0 {
+		// Nilai terakhir menang untuk key yang sama, jadi override instance menimpa environment aplikasi
+		cmd.Env = append(os.Environ(), instanceEnv...)
+		s.logger.Debug("  Instance environment: %v", instanceEnv)
+	}
 
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		HideWindow:    true,
 		CreationFlags: 0x08000000,
 	}
 
+	sandboxOptions, err := s.sandboxFor(processId)
+	if err != nil {
+		s.logger.Error("Cannot start %s: %v", processId, err)
+		s.emit("process_error", EventData{
+			ProcessId: processId,
+			Message:   "Error: " + err.Error(),
+			Timestamp: time.Now(),
+			Success:   false,
+		})
+		return err
+	}
+
+	var sandbox *processSandbox
+	if sandboxOptions.sandboxed() {
+		sandbox, err = prepareSandbox(cmd, sandboxOptions)
+		if err != nil {
+			s.logger.Error("Failed to prepare sandbox for %s: %v", processId, err)
+			s.emit("process_error", EventData{
+				ProcessId: processId,
+				Message:   "Error: " + err.Error(),
+				Timestamp: time.Now(),
+				Success:   false,
+			})
+			return err
+		}
+		s.logger.Info("Running %s in sandbox mode %s (memory %d MB, cpu %d%%, processes %d)", processId,
+			sandboxOptions.Mode, sandboxOptions.MemoryLimitMB, sandboxOptions.CPURatePercent, sandboxOptions.MaxProcesses)
+	}
+
 	// Log command yang akan dijalankan untuk debugging
 	s.logger.Info("Running command: %s in directory: %s", cmd.String(), cmd.Dir)
 
@@ -568,11 +715,19 @@ func (s *ProcessManagerService) RunBatFile(batFilename string) error {
 		Data:      batFilename,
 	}
 
-	if s.app != nil {
-		s.app.EmitEvent("process_started", eventData)
-	}
+	s.emit("process_started", eventData)
 
-	if err := cmd.Start(); err != nil {
+	err = cmd.Start()
+	if err == nil {
+		// Proses dibuat suspended bila ada job object, masukkan ke job lalu lanjutkan
+		err = sandbox.start(cmd.Process.Pid)
+		if err != nil {
+			cmd.Wait()
+		}
+	}
+	if err != nil {
+		sandbox.close()
+		err = sandboxStartError(sandboxOptions, err)
 		s.logger.Error("Failed to start process %s: %v", processId, err)
 
 		eventData := EventData{
@@ -582,9 +737,7 @@ func (s *ProcessManagerService) RunBatFile(batFilename string) error {
 			Success:   false,
 		}
 
-		if s.app != nil {
-			s.app.EmitEvent("process_error", eventData)
-		}
+		s.emit("process_error", eventData)
 		return err
 	}
 
@@ -592,6 +745,9 @@ func (s *ProcessManagerService) RunBatFile(batFilename string) error {
 
 	s.mu.Lock()
 	s.processes[processId] = cmd
+	if sandbox != nil {
+		s.sandboxJobs[processId] = sandbox
+	}
 	s.mu.Unlock()
 
 	// Buat goroutine untuk menunggu proses selesai
@@ -600,7 +756,11 @@ func (s *ProcessManagerService) RunBatFile(batFilename string) error {
 
 		s.mu.Lock()
 		delete(s.processes, processId)
+		if s.sandboxJobs[processId] == sandbox {
+			delete(s.sandboxJobs, processId)
+		}
 		s.mu.Unlock()
+		sandbox.close()
 
 		var eventData EventData
 		if err != nil {
@@ -621,9 +781,7 @@ func (s *ProcessManagerService) RunBatFile(batFilename string) error {
 			}
 		}
 
-		if s.app != nil {
-			s.app.EmitEvent("process_completed", eventData)
-		}
+		s.emit("process_completed", eventData)
 
 		// Pastikan status file diperbarui saat proses selesai
 		s.UpdateProcessStatusOnMissing(processId)
@@ -642,6 +800,12 @@ func getCurrentDir() string {
 }
 
 func (s *ProcessManagerService) GetDetailedProcessStatus(processId string) string {
+	if report, ok := s.statusServer.latest(processId); ok {
+		s.logger.Debug("Pushed status for %s: %s", processId, report.Status)
+		status, _ := formatStatus(report.Status)
+		return status
+	}
+
 	statusFileName := strings.Replace(processId, ".bat", "_status.txt", 1)
 	statusPath := filepath.Join(s.config.LogsDir, statusFileName)
 
@@ -756,8 +920,9 @@ func (s *ProcessManagerService) VerifyProcessStatusConsistency(processId string)
 
 func (s *ProcessManagerService) VerifyAllProcessStatusConsistency() {
 	s.logger.Debug("Verifying all process status consistency")
-	s.VerifyProcessStatusConsistency("people_counter.bat")
-	s.VerifyProcessStatusConsistency("sync_manager.bat")
+	for _, processId := range s.managedProcessIds() {
+		s.VerifyProcessStatusConsistency(processId)
+	}
 }
 
 // ForceRemoveOrphanedStatusFiles menghapus file status yang tidak konsisten
@@ -834,6 +999,15 @@ func (s *ProcessManagerService) ForceRemoveOrphanedStatusFiles() {
 }
 
 func (s *ProcessManagerService) RestartProcess(processId string) bool {
+	if err := s.requireUnlocked(); err != nil {
+		s.logger.Warn("Refused to restart process %s: %v", processId, err)
+		return false
+	}
+	return s.restartProcess(processId)
+}
+
+// restartProcess also runs for reloads the counter did not acknowledge, so it is not guarded
+func (s *ProcessManagerService) restartProcess(processId string) bool {
 	s.logger.Info("Restarting process %s", processId)
 
 	eventData := EventData{
@@ -843,19 +1017,17 @@ func (s *ProcessManagerService) RestartProcess(processId string) bool {
 		Success:   true,
 	}
 
-	if s.app != nil {
-		s.app.EmitEvent("process_restarting", eventData)
-	}
+	s.emit("process_restarting", eventData)
 
 	// First stop the process
-	stopped := s.StopProcess(processId)
+	stopped := s.stopProcess(processId)
 	s.logger.Info("Process %s stop result: %v", processId, stopped)
 
 	// Wait a bit to ensure the process is fully terminated
 	time.Sleep(2 * time.Second)
 
 	// Start the process again
-	err := s.RunBatFile(processId)
+	err := s.runBatFile(processId)
 	if err != nil {
 		s.logger.Error("Failed to restart process %s: %v", processId, err)
 
@@ -866,9 +1038,7 @@ func (s *ProcessManagerService) RestartProcess(processId string) bool {
 			Success:   false,
 		}
 
-		if s.app != nil {
-			s.app.EmitEvent("process_error", eventData)
-		}
+		s.emit("process_error", eventData)
 		return false
 	}
 
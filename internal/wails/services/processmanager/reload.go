@@ -0,0 +1,121 @@
+package processmanager
+
+import (
+	"encoding/json"
+	"jarvist/pkg/utils"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+const (
+	// ReloadSignalFile is written to the services directory when config files change.
+	// The counter reloads its config and deletes the file to acknowledge. Counter instances
+	// get their own reload.<instance>.signal, passed in COUNTER_RELOAD_SIGNAL.
+	ReloadSignalFile = "reload.signal"
+
+	// reloadAckTimeout is how long the counter has to acknowledge before it is restarted instead
+	reloadAckTimeout = 15 * time.Second
+)
+
+// ReloadSignal is the content of the reload signal file
+type ReloadSignal struct {
+	Process     string    `json:"process"`
+	Reason      string    `json:"reason"`
+	Files       []string  `json:"files"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// RequestReload asks a running process to reload its config files. If the process does not
+// delete the signal file within reloadAckTimeout it is restarted, which covers counters that
+// do not support the signal yet.
+func (s *ProcessManagerService) RequestReload(processId, reason string, files ...string) {
+	if !s.IsProcessRunning(processId) {
+		s.logger.Debug("Process %s not running, config is read on next start", processId)
+		return
+	}
+
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	signal := ReloadSignal{
+		Process:     processId,
+		Reason:      reason,
+		Files:       files,
+		RequestedAt: time.Now(),
+	}
+
+	pending := s.pendingReload[processId]
+	if pending != nil {
+		// Gabungkan dengan permintaan yang belum diakui, satu reload cukup untuk keduanya
+		signal.Files = mergeFiles(pending.Files, files)
+	}
+
+	data, err := json.MarshalIndent(signal, "", "  ")
+	if err != nil {
+		s.logger.Error("Failed to marshal reload signal: %v", err)
+		return
+	}
+
+	signalPath := s.reloadSignalPath(processId)
+	if err := utils.WriteFileAtomic(signalPath, data, 0644); err != nil {
+		s.logger.Warn("Failed to write reload signal, restarting %s instead: %v", processId, err)
+		go s.restartProcess(processId)
+		return
+	}
+
+	s.logger.Info("Requested %s to reload config: %s", processId, reason)
+
+	s.pendingReload[processId] = &signal
+	if pending != nil {
+		return
+	}
+
+	go s.waitReloadAck(processId, signalPath)
+}
+
+// waitReloadAck restarts the process if the signal file is still there after the timeout
+func (s *ProcessManagerService) waitReloadAck(processId, signalPath string) {
+	deadline := time.Now().Add(reloadAckTimeout)
+	acknowledged := false
+
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(signalPath); os.IsNotExist(err) {
+			acknowledged = true
+			break
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	s.reloadMu.Lock()
+	delete(s.pendingReload, processId)
+	s.reloadMu.Unlock()
+
+	if acknowledged {
+		s.logger.Info("Process %s acknowledged config reload", processId)
+		return
+	}
+
+	os.Remove(signalPath)
+	s.logger.Warn("Process %s did not acknowledge reload within %v, restarting", processId, reloadAckTimeout)
+	s.restartProcess(processId)
+}
+
+// reloadSignalPath returns the signal file watched by a process
+func (s *ProcessManagerService) reloadSignalPath(processId string) string {
+	if name, ok := instanceName(processId); ok {
+		return filepath.Join(s.config.ServicesDir, "reload."+name+".signal")
+	}
+	return filepath.Join(s.config.ServicesDir, ReloadSignalFile)
+}
+
+func mergeFiles(existing, added []string) []string {
+	merged := append([]string{}, existing...)
+	for _, file := range added {
+		if !slices.Contains(merged, file) {
+			merged = append(merged, file)
+		}
+	}
+	return merged
+}
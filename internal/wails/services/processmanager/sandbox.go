@@ -0,0 +1,215 @@
+package processmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/pkg/encrypt"
+	"jarvist/pkg/utils"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ProcessSandboxFile holds the sandbox options per process in the services directory
+const ProcessSandboxFile = "process_sandbox.json"
+
+// Sandbox modes of a managed process
+const (
+	// SandboxNone runs the process with the full rights of the GUI user
+	SandboxNone = "none"
+	// SandboxRestricted runs the process with a restricted copy of the GUI user's token:
+	// every privilege except bypass traverse checking is removed and the Administrators
+	// group is deny-only
+	SandboxRestricted = "restricted"
+	// SandboxUser runs the process as a dedicated local account. Starting a process as
+	// another user needs the replace a process level token right, which the service
+	// account has but an interactive user usually does not.
+	SandboxUser = "user"
+)
+
+// SandboxOptions limits what a managed process can do. The limits are applied through
+// a job object in every mode, so they also cover the processes started by the batch file.
+type SandboxOptions struct {
+	Mode   string `json:"mode"`
+	User   string `json:"user,omitempty"`
+	Domain string `json:"domain,omitempty"`
+	// Password is stored encrypted and never returned, leave it empty to keep the saved one
+	Password string `json:"password,omitempty"`
+
+	MemoryLimitMB  int `json:"memory_limit_mb,omitempty"`
+	CPURatePercent int `json:"cpu_rate_percent,omitempty"`
+	MaxProcesses   int `json:"max_processes,omitempty"`
+}
+
+// limited reports whether the options need a job object
+func (o SandboxOptions) limited() bool {
+	return o.MemoryLimitMB > 0 || o.CPURatePercent > 0 || o.MaxProcesses > 0
+}
+
+// sandboxed reports whether the process runs with anything other than the default rights
+func (o SandboxOptions) sandboxed() bool {
+	return (o.Mode != "" && o.Mode != SandboxNone) || o.limited()
+}
+
+func validateSandbox(options SandboxOptions) error {
+	switch options.Mode {
+	case "", SandboxNone, SandboxRestricted:
+	case SandboxUser:
+		if options.User == "" {
+			return errors.New("user mode needs an account name")
+		}
+	default:
+		return fmt.Errorf("unknown sandbox mode %q", options.Mode)
+	}
+
+	if options.MemoryLimitMB < 0 || options.MaxProcesses < 0 {
+		return errors.New("limits cannot be negative")
+	}
+	if options.CPURatePercent < 0 || options.CPURatePercent > 100 {
+		return errors.New("cpu rate must be between 0 and 100 percent")
+	}
+	return nil
+}
+
+// loadSandboxes reads the sandbox options, a missing file means no process is sandboxed
+func (s *ProcessManagerService) loadSandboxes() {
+	path := filepath.Join(s.config.ServicesDir, ProcessSandboxFile)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			s.logger.Error("Failed to read process sandbox options: %v", err)
+		}
+		return
+	}
+
+	sandboxes := make(map[string]SandboxOptions)
+	if err := json.Unmarshal(data, &sandboxes); err != nil {
+		s.logger.Error("Failed to parse %s, processes run without sandbox: %v", path, err)
+		return
+	}
+
+	s.sandboxMu.Lock()
+	s.sandboxes = sandboxes
+	s.sandboxMu.Unlock()
+}
+
+// saveSandboxes writes the options, the caller holds sandboxMu
+func (s *ProcessManagerService) saveSandboxes(sandboxes map[string]SandboxOptions) error {
+	data, err := json.MarshalIndent(sandboxes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal process sandbox options: %w", err)
+	}
+
+	path := filepath.Join(s.config.ServicesDir, ProcessSandboxFile)
+	if err := utils.WriteFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write process sandbox options: %w", err)
+	}
+
+	s.sandboxes = sandboxes
+	return nil
+}
+
+// GetProcessSandbox returns the sandbox options of a process without the password
+func (s *ProcessManagerService) GetProcessSandbox(processId string) SandboxOptions {
+	s.sandboxMu.Lock()
+	defer s.sandboxMu.Unlock()
+
+	options, ok := s.sandboxes[normalizeProcessId(processId)]
+	if !ok {
+		return SandboxOptions{Mode: SandboxNone}
+	}
+	options.Password = ""
+	return options
+}
+
+// SetProcessSandbox saves the sandbox options of a process. They apply from the next start.
+func (s *ProcessManagerService) SetProcessSandbox(processId string, options SandboxOptions) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+	processId = normalizeProcessId(processId)
+	if !slices.Contains(s.managedProcessIds(), processId) {
+		return fmt.Errorf("unknown process %s", processId)
+	}
+
+	options.Mode = strings.ToLower(strings.TrimSpace(options.Mode))
+	options.User = strings.TrimSpace(options.User)
+	options.Domain = strings.TrimSpace(options.Domain)
+	if err := validateSandbox(options); err != nil {
+		return err
+	}
+
+	s.sandboxMu.Lock()
+	defer s.sandboxMu.Unlock()
+
+	previous := s.sandboxes[processId]
+	if options.Mode != SandboxUser {
+		options.User, options.Domain, options.Password = "", "", ""
+	} else if options.Password != "" {
+		encrypted, err := encrypt.Encrypt([]byte(options.Password))
+		if err != nil {
+			return fmt.Errorf("failed to encrypt password: %w", err)
+		}
+		options.Password = encrypted
+	} else if previous.User == options.User && previous.Domain == options.Domain {
+		options.Password = previous.Password
+	}
+
+	sandboxes := make(map[string]SandboxOptions, len(s.sandboxes)+1)
+	for id, existing := range s.sandboxes {
+		sandboxes[id] = existing
+	}
+	if options.sandboxed() {
+		sandboxes[processId] = options
+	} else {
+		delete(sandboxes, processId)
+	}
+
+	if err := s.saveSandboxes(sandboxes); err != nil {
+		return err
+	}
+
+	s.logger.Info("Saved sandbox options for %s (mode %s), applied on next start", processId, options.Mode)
+	return nil
+}
+
+// removeSandbox drops the options of a deleted process
+func (s *ProcessManagerService) removeSandbox(processId string) {
+	s.sandboxMu.Lock()
+	defer s.sandboxMu.Unlock()
+
+	if _, ok := s.sandboxes[processId]; !ok {
+		return
+	}
+
+	sandboxes := make(map[string]SandboxOptions, len(s.sandboxes))
+	for id, existing := range s.sandboxes {
+		if id != processId {
+			sandboxes[id] = existing
+		}
+	}
+	if err := s.saveSandboxes(sandboxes); err != nil {
+		s.logger.Warn("Failed to remove sandbox options of %s: %v", processId, err)
+	}
+}
+
+// sandboxFor returns the stored options of a process with the password decrypted
+func (s *ProcessManagerService) sandboxFor(processId string) (SandboxOptions, error) {
+	s.sandboxMu.Lock()
+	options, ok := s.sandboxes[normalizeProcessId(processId)]
+	s.sandboxMu.Unlock()
+
+	if !ok || options.Mode != SandboxUser || options.Password == "" {
+		return options, nil
+	}
+
+	password, err := encrypt.Decrypt(options.Password)
+	if err != nil {
+		return options, fmt.Errorf("failed to decrypt sandbox password of %s: %w", processId, err)
+	}
+	options.Password = string(password)
+	return options, nil
+}
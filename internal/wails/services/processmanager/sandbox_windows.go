@@ -0,0 +1,232 @@
+package processmanager
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	advapi32                  = windows.NewLazySystemDLL("advapi32.dll")
+	procCreateRestrictedToken = advapi32.NewProc("CreateRestrictedToken")
+	procLogonUserW            = advapi32.NewProc("LogonUserW")
+
+	ntdll               = windows.NewLazySystemDLL("ntdll.dll")
+	procNtResumeProcess = ntdll.NewProc("NtResumeProcess")
+)
+
+const (
+	disableMaxPrivilege = 0x1
+
+	logon32LogonBatch      = 4
+	logon32ProviderDefault = 0
+
+	jobObjectCpuRateControlEnable  = 0x1
+	jobObjectCpuRateControlHardCap = 0x4
+)
+
+type jobObjectCpuRateControlInformation struct {
+	ControlFlags uint32
+	CpuRate      uint32
+}
+
+// processSandbox holds the token and job object of a process being started
+type processSandbox struct {
+	token windows.Token
+	job   windows.Handle
+}
+
+// prepareSandbox sets the token of cmd and creates the job object for the options. The
+// process is created suspended when it needs a job, so it cannot start children before
+// it is assigned. Call start after cmd.Start and close when the process exits.
+func prepareSandbox(cmd *exec.Cmd, options SandboxOptions) (*processSandbox, error) {
+	sandbox := &processSandbox{}
+
+	var err error
+	switch options.Mode {
+	case SandboxRestricted:
+		sandbox.token, err = restrictedToken()
+	case SandboxUser:
+		sandbox.token, err = logonToken(options)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if sandbox.token != 0 {
+		cmd.SysProcAttr.Token = syscall.Token(sandbox.token)
+	}
+
+	if options.limited() {
+		sandbox.job, err = createLimitedJob(options)
+		if err != nil {
+			sandbox.close()
+			return nil, err
+		}
+		cmd.SysProcAttr.CreationFlags |= windows.CREATE_SUSPENDED
+	}
+
+	return sandbox, nil
+}
+
+// start assigns the suspended process to the job and resumes it. On failure the process
+// is terminated, it never runs outside its limits.
+func (sb *processSandbox) start(pid int) error {
+	if sb == nil || sb.job == 0 {
+		return nil
+	}
+
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE|windows.PROCESS_SUSPEND_RESUME, false, uint32(pid))
+	if err != nil {
+		return fmt.Errorf("failed to open process %d: %w", pid, err)
+	}
+	defer windows.CloseHandle(process)
+
+	if err := windows.AssignProcessToJobObject(sb.job, process); err != nil {
+		windows.TerminateProcess(process, 1)
+		return fmt.Errorf("failed to assign process %d to job object: %w", pid, err)
+	}
+
+	if status, _, _ := procNtResumeProcess.Call(uintptr(process)); status != 0 {
+		windows.TerminateProcess(process, 1)
+		return fmt.Errorf("failed to resume process %d: NTSTATUS 0x%x", pid, status)
+	}
+	return nil
+}
+
+// terminate kills every process in the job, including ones taskkill could not reach
+func (sb *processSandbox) terminate() bool {
+	if sb == nil || sb.job == 0 {
+		return false
+	}
+	return windows.TerminateJobObject(sb.job, 1) == nil
+}
+
+// close releases the token and the job handle. The job has no kill on close limit, so
+// processes that outlive the app keep running with their limits.
+func (sb *processSandbox) close() {
+	if sb == nil {
+		return
+	}
+	if sb.token != 0 {
+		sb.token.Close()
+		sb.token = 0
+	}
+	if sb.job != 0 {
+		windows.CloseHandle(sb.job)
+		sb.job = 0
+	}
+}
+
+// restrictedToken returns a primary token of the current user without privileges and with
+// the Administrators group set to deny-only
+func restrictedToken() (windows.Token, error) {
+	var current windows.Token
+	access := uint32(windows.TOKEN_DUPLICATE | windows.TOKEN_ASSIGN_PRIMARY | windows.TOKEN_QUERY | windows.TOKEN_ADJUST_DEFAULT | windows.TOKEN_ADJUST_SESSIONID)
+	if err := windows.OpenProcessToken(windows.CurrentProcess(), access, &current); err != nil {
+		return 0, fmt.Errorf("failed to open process token: %w", err)
+	}
+	defer current.Close()
+
+	admins, err := windows.CreateWellKnownSid(windows.WinBuiltinAdministratorsSid)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create administrators SID: %w", err)
+	}
+	disabled := windows.SIDAndAttributes{Sid: admins}
+
+	var restricted windows.Token
+	ret, _, err := procCreateRestrictedToken.Call(
+		uintptr(current),
+		disableMaxPrivilege,
+		1, uintptr(unsafe.Pointer(&disabled)),
+		0, 0,
+		0, 0,
+		uintptr(unsafe.Pointer(&restricted)),
+	)
+	if ret == 0 {
+		return 0, fmt.Errorf("failed to create restricted token: %w", err)
+	}
+	return restricted, nil
+}
+
+// logonToken logs on the dedicated account with a batch logon
+func logonToken(options SandboxOptions) (windows.Token, error) {
+	user, err := windows.UTF16PtrFromString(options.User)
+	if err != nil {
+		return 0, err
+	}
+	domain, err := windows.UTF16PtrFromString(options.Domain)
+	if err != nil {
+		return 0, err
+	}
+	password, err := windows.UTF16PtrFromString(options.Password)
+	if err != nil {
+		return 0, err
+	}
+
+	var token windows.Token
+	ret, _, err := procLogonUserW.Call(
+		uintptr(unsafe.Pointer(user)),
+		uintptr(unsafe.Pointer(domain)),
+		uintptr(unsafe.Pointer(password)),
+		logon32LogonBatch,
+		logon32ProviderDefault,
+		uintptr(unsafe.Pointer(&token)),
+	)
+	if ret == 0 {
+		return 0, fmt.Errorf("failed to log on %s: %w", options.User, err)
+	}
+	return token, nil
+}
+
+func createLimitedJob(options SandboxOptions) (windows.Handle, error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create job object: %w", err)
+	}
+
+	var limits windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION
+	if options.MemoryLimitMB > 0 {
+		limits.BasicLimitInformation.LimitFlags |= windows.JOB_OBJECT_LIMIT_PROCESS_MEMORY
+		limits.ProcessMemoryLimit = uintptr(options.MemoryLimitMB) << 20
+	}
+	if options.MaxProcesses > 0 {
+		limits.BasicLimitInformation.LimitFlags |= windows.JOB_OBJECT_LIMIT_ACTIVE_PROCESS
+		limits.BasicLimitInformation.ActiveProcessLimit = uint32(options.MaxProcesses)
+	}
+
+	if limits.BasicLimitInformation.LimitFlags != 0 {
+		if _, err := windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation,
+			uintptr(unsafe.Pointer(&limits)), uint32(unsafe.Sizeof(limits))); err != nil {
+			windows.CloseHandle(job)
+			return 0, fmt.Errorf("failed to set job limits: %w", err)
+		}
+	}
+
+	if options.CPURatePercent > 0 {
+		// CpuRate dalam seperseratus persen
+		rate := jobObjectCpuRateControlInformation{
+			ControlFlags: jobObjectCpuRateControlEnable | jobObjectCpuRateControlHardCap,
+			CpuRate:      uint32(options.CPURatePercent * 100),
+		}
+		if _, err := windows.SetInformationJobObject(job, windows.JobObjectCpuRateControlInformation,
+			uintptr(unsafe.Pointer(&rate)), uint32(unsafe.Sizeof(rate))); err != nil {
+			windows.CloseHandle(job)
+			return 0, fmt.Errorf("failed to set job cpu rate: %w", err)
+		}
+	}
+
+	return job, nil
+}
+
+// sandboxStartError explains the error when a dedicated account cannot be used
+func sandboxStartError(options SandboxOptions, err error) error {
+	if options.Mode == SandboxUser && errors.Is(err, windows.ERROR_PRIVILEGE_NOT_HELD) {
+		return fmt.Errorf("%w: starting a process as %s needs the replace a process level token right", err, options.User)
+	}
+	return err
+}
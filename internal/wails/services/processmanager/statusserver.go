@@ -0,0 +1,202 @@
+package processmanager
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/pkg/logger"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultStatusPort adalah port localhost tempat counter mengirim status
+	DefaultStatusPort = 8765
+	// StatusStaleAfter menentukan kapan status yang di-push dianggap kadaluarsa
+	// dan ProcessManager kembali membaca file _status.txt
+	StatusStaleAfter = 30 * time.Second
+)
+
+// StatusReport is a single status line pushed by a managed process.
+//
+// Protocol: the process sends one JSON object per line (JSON lines) in the
+// body of a POST to http://127.0.0.1:<port>/status. A long-lived chunked
+// request may be kept open and written to line by line.
+type StatusReport struct {
+	ProcessId string    `json:"process_id"`
+	Status    string    `json:"status"`
+	PID       int       `json:"pid,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type statusServer struct {
+	server   *http.Server
+	mu       sync.RWMutex
+	reports  map[string]StatusReport
+	accepts  func(processId string) bool
+	onReport func(report StatusReport, changed bool)
+	logger   *logger.ContextLogger
+}
+
+// StatusEndpoint returns the URL managed processes should push status lines to
+func StatusEndpoint(port int) string {
+	return fmt.Sprintf("http://127.0.0.1:%d/status", port)
+}
+
+// newStatusServer returns a status server that records reports of the process ids accepted
+// by accepts. The port is open to every local user, the ids are used in file names.
+func newStatusServer(logger *logger.ContextLogger, accepts func(processId string) bool, onReport func(report StatusReport, changed bool)) *statusServer {
+	return &statusServer{
+		reports:  make(map[string]StatusReport),
+		accepts:  accepts,
+		onReport: onReport,
+		logger:   logger,
+	}
+}
+
+func (ss *statusServer) start(port int) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return fmt.Errorf("failed to listen on status port %d: %w", port, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", ss.handleStatus)
+
+	ss.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func(server *http.Server) {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			ss.logger.Error("Status server stopped unexpectedly: %v", err)
+		}
+	}(ss.server)
+
+	return nil
+}
+
+func (ss *statusServer) stop() {
+	if ss.server == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	ss.server.Shutdown(ctx)
+	ss.server = nil
+}
+
+func (ss *statusServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+	case http.MethodGet:
+		ss.mu.RLock()
+		defer ss.mu.RUnlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ss.reports)
+		return
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	accepted := 0
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var report StatusReport
+		if err := json.Unmarshal([]byte(line), &report); err != nil {
+			http.Error(w, "invalid status line: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if report.ProcessId == "" || report.Status == "" {
+			http.Error(w, "process_id and status are required", http.StatusBadRequest)
+			return
+		}
+
+		if ss.accepts != nil && !ss.accepts(normalizeProcessId(report.ProcessId)) {
+			http.Error(w, "unknown process_id: "+report.ProcessId, http.StatusBadRequest)
+			return
+		}
+
+		ss.record(report)
+		accepted++
+	}
+
+	if err := scanner.Err(); err != nil {
+		http.Error(w, "failed to read status stream: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"accepted": accepted})
+}
+
+func (ss *statusServer) record(report StatusReport) {
+	report.ProcessId = normalizeProcessId(report.ProcessId)
+	report.Status = strings.ToLower(strings.TrimSpace(report.Status))
+	// Gunakan waktu penerimaan agar jam proses yang tidak sinkron tidak
+	// membuat status langsung dianggap kadaluarsa
+	report.Timestamp = time.Now()
+
+	ss.mu.Lock()
+	previous, exists := ss.reports[report.ProcessId]
+	ss.reports[report.ProcessId] = report
+	ss.mu.Unlock()
+
+	changed := !exists || previous.Status != report.Status || previous.PID != report.PID
+	if ss.onReport != nil {
+		ss.onReport(report, changed)
+	}
+}
+
+// latest returns the most recent pushed status if it is still fresh
+func (ss *statusServer) latest(processId string) (StatusReport, bool) {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+
+	report, exists := ss.reports[normalizeProcessId(processId)]
+	if !exists || time.Since(report.Timestamp) > StatusStaleAfter {
+		return StatusReport{}, false
+	}
+	return report, true
+}
+
+// normalizeProcessId maps "people_counter" and "people_counter.bat" to the same id
+func normalizeProcessId(processId string) string {
+	processId = strings.TrimSpace(processId)
+	if !strings.HasSuffix(processId, ".bat") {
+		processId += ".bat"
+	}
+	return processId
+}
+
+// formatStatus converts a raw status value into the label shown in the UI
+func formatStatus(rawStatus string) (string, bool) {
+	switch rawStatus {
+	case "initializing":
+		return "Initializing", true
+	case "loading":
+		return "Loading", true
+	case "running":
+		return "Running", true
+	case "stopped":
+		return "Stopped", true
+	case "error":
+		return "Error", true
+	}
+	return rawStatus, false
+}
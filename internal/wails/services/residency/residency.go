@@ -0,0 +1,119 @@
+package residency
+
+import (
+	"context"
+	"jarvist/internal/common/models"
+	commonresidency "jarvist/internal/common/residency"
+	"jarvist/internal/wails/services/auth"
+	"jarvist/internal/wails/services/eventbuffer"
+	"jarvist/pkg/logger"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
+	"gorm.io/gorm"
+)
+
+// ResidencyView is the data residency mode shown in the UI
+type ResidencyView struct {
+	State     commonresidency.State `json:"state"`
+	LocalOnly bool                  `json:"local_only"`
+	Modes     []string              `json:"modes"`
+}
+
+// ResidencyService switches a site between cloud publishing and local-only mode. The sync
+// service follows the stored mode within its policy check, the desktop app at once.
+type ResidencyService struct {
+	db       *gorm.DB
+	app      *application.App
+	events   eventbuffer.Emitter
+	logger   *logger.ContextLogger
+	guard    auth.Guard
+	verifier auth.Verifier
+}
+
+func New(db *gorm.DB, logger *logger.ContextLogger, verifier auth.Verifier) *ResidencyService {
+	return &ResidencyService{
+		db:       db,
+		logger:   logger.WithComponent("residency"),
+		verifier: verifier,
+	}
+}
+
+func (s *ResidencyService) OnStartup(ctx context.Context, options application.ServiceOptions) error {
+	return nil
+}
+
+func (s *ResidencyService) OnShutdown() error {
+	return nil
+}
+
+func (s *ResidencyService) InitService(app *application.App) {
+	s.app = app
+}
+
+// SetEventBuffer makes residency events go through the event buffer
+func (s *ResidencyService) SetEventBuffer(events eventbuffer.Emitter) {
+	s.events = events
+}
+
+// SetGuard sets the lock guard checked before the mode is switched
+func (s *ResidencyService) SetGuard(guard auth.Guard) {
+	s.guard = guard
+}
+
+// GetDataResidency returns the current mode
+func (s *ResidencyService) GetDataResidency() ResidencyView {
+	state := commonresidency.Get(s.db)
+	return ResidencyView{
+		State:     state,
+		LocalOnly: state.LocalOnly(),
+		Modes:     commonresidency.Modes,
+	}
+}
+
+// GetDataResidencyAudit returns the latest mode switches, newest first
+func (s *ResidencyService) GetDataResidencyAudit(limit int) ([]models.AuditEntry, error) {
+	return commonresidency.GetAudit(s.db, limit)
+}
+
+// SetDataResidency switches the mode after verifying the admin PIN, even while the app is
+// unlocked. The switch is written to the audit table with the reason.
+func (s *ResidencyService) SetDataResidency(mode, pin, reason string) (ResidencyView, error) {
+	if s.guard != nil {
+		if err := s.guard.RequireUnlocked(); err != nil {
+			return ResidencyView{}, err
+		}
+	}
+	if err := commonresidency.Validate(mode); err != nil {
+		return ResidencyView{}, err
+	}
+	if s.verifier == nil {
+		return ResidencyView{}, auth.ErrNoPIN
+	}
+	if err := s.verifier.VerifyPIN(pin); err != nil {
+		return ResidencyView{}, err
+	}
+
+	previous := commonresidency.Get(s.db)
+	if _, err := commonresidency.Set(s.db, mode, "admin", "desktop", reason); err != nil {
+		return ResidencyView{}, err
+	}
+
+	view := s.GetDataResidency()
+	if previous.Mode == mode {
+		return view, nil
+	}
+
+	if view.LocalOnly {
+		s.logger.Warn("Local-only mode enabled, data no longer leaves the site")
+	} else {
+		s.logger.Info("Local-only mode disabled, cloud publishing resumes")
+	}
+
+	if s.events != nil {
+		s.events.Emit("residency_changed", view)
+	} else if s.app != nil {
+		s.app.EmitEvent("residency_changed", view)
+	}
+
+	return view, nil
+}
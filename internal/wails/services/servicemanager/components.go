@@ -0,0 +1,131 @@
+package servicemanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"jarvist/internal/common/config"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// componentRestartTimeout covers stopping, starting and the health check of a component
+const componentRestartTimeout = 90 * time.Second
+
+// ComponentRestart is the last restart of a sync service component
+type ComponentRestart struct {
+	Component  string    `json:"component"`
+	Success    bool      `json:"success"`
+	Healthy    bool      `json:"healthy"`
+	StopMs     int64     `json:"stop_ms"`
+	StartMs    int64     `json:"start_ms"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// ComponentInfo is a sync service component that can be restarted on its own
+type ComponentInfo struct {
+	Key         string            `json:"key"`
+	Name        string            `json:"name"`
+	Healthy     bool              `json:"healthy"`
+	HealthError string            `json:"health_error,omitempty"`
+	Restarting  bool              `json:"restarting"`
+	LastRestart *ComponentRestart `json:"last_restart,omitempty"`
+}
+
+// GetComponents returns the restartable components of the sync service
+func (s *ServiceManager) GetComponents() ([]ComponentInfo, error) {
+	var result struct {
+		Components []ComponentInfo `json:"components"`
+	}
+	if err := s.syncApiRequest(http.MethodGet, "/components", 5*time.Second, &result); err != nil {
+		return nil, err
+	}
+	return result.Components, nil
+}
+
+// RestartComponent restarts one sync service component (synchronizer, mqtt_sender or
+// cleanup) without restarting the Windows service and returns the health check result
+func (s *ServiceManager) RestartComponent(key string) (ComponentRestart, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return ComponentRestart{}, err
+	}
+
+	s.logger.Info("Restarting sync service component %s", key)
+
+	var result ComponentRestart
+	path := "/components/" + url.PathEscape(key) + "/restart"
+	if err := s.syncApiRequest(http.MethodPost, path, componentRestartTimeout, &result); err != nil {
+		// Restart yang gagal health check tetap mengembalikan hasilnya
+		if result.Component != "" {
+			return result, fmt.Errorf("restart of %s failed: %s", key, result.Error)
+		}
+		return ComponentRestart{}, err
+	}
+	return result, nil
+}
+
+// syncApiRequest calls the sync service API and decodes the JSON response into out
+func (s *ServiceManager) syncApiRequest(method, path string, timeout time.Duration, out any) error {
+	return s.syncApiRequestBody(method, path, timeout, nil, out)
+}
+
+// syncApiRequestBody calls the sync service API with a JSON body, nil sends no body
+func (s *ServiceManager) syncApiRequestBody(method, path string, timeout time.Duration, in any, out any) error {
+	return SyncApiRequest(s.config, method, path, timeout, in, out)
+}
+
+// SyncApiRequest calls the sync service API with a JSON body and decodes the JSON response
+// into out, nil sends no body. Used by the services that proxy the sync service API.
+func SyncApiRequest(cfg *config.Config, method, path string, timeout time.Duration, in any, out any) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(cfg.SyncApiURL(), "/")+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if cfg.SyncApiUsername != "" {
+		req.SetBasicAuth(cfg.SyncApiUsername, cfg.SyncApiPassword)
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sync service not reachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		// Respons error berisi {"error": "..."} atau hasil restart yang gagal
+		json.Unmarshal(data, out)
+
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("sync service: %s", apiErr.Error)
+		}
+		return fmt.Errorf("sync service returned status %d", resp.StatusCode)
+	}
+
+	return json.Unmarshal(data, out)
+}
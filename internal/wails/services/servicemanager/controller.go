@@ -0,0 +1,72 @@
+package servicemanager
+
+import (
+	"fmt"
+	"runtime"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// Controller stops and starts the sync service for the update flow. It skips the lock guard
+// of the bound methods, updates run in kiosk mode and while the app is locked as well.
+type Controller struct {
+	manager *ServiceManager
+}
+
+// NewController returns the update controller of manager. It is not a method so that it is
+// not bound to the frontend.
+func NewController(manager *ServiceManager) *Controller {
+	return &Controller{manager: manager}
+}
+
+// StopService asks the service control manager to stop the service. Unlike --stop it does
+// not run the installed executable, which may be a faulty update that never started.
+func (c *Controller) StopService() (string, error) {
+	return c.manager.guardedCommand("StopService", c.manager.stopServiceControl)
+}
+
+func (c *Controller) StartService() (string, error) {
+	return c.manager.guardedCommand("StartService", func() (string, error) {
+		return c.manager.runCommand("--start")
+	})
+}
+
+func (c *Controller) IsServiceRunning() (bool, error) {
+	return c.manager.IsServiceRunning()
+}
+
+func (c *Controller) GetServiceBinaryPath() string {
+	return c.manager.GetServiceBinaryPath()
+}
+
+// stopServiceControl sends the stop control to the service, a stopped service is not an error
+func (s *ServiceManager) stopServiceControl() (string, error) {
+	if runtime.GOOS != "windows" {
+		return "", fmt.Errorf("service management is only supported on Windows")
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	service, err := m.OpenService(s.serviceName)
+	if err != nil {
+		return "", fmt.Errorf("failed to open service: %w", err)
+	}
+	defer service.Close()
+
+	s.logger.Info("Stopping service through the service control manager...")
+	if _, err := service.Control(svc.Stop); err != nil {
+		if err == windows.ERROR_SERVICE_NOT_ACTIVE {
+			return "Service already stopped", nil
+		}
+		return "", fmt.Errorf("failed to stop service: %w", err)
+	}
+
+	// Stop hanya diminta, updater menunggu sampai service benar-benar berhenti
+	return "Service stop requested", nil
+}
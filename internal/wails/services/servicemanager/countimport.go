@@ -0,0 +1,119 @@
+package servicemanager
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// countImportTimeout bounds an import, a year of counts of many cameras takes a while
+	countImportTimeout = 5 * time.Minute
+	// maxCountImportSize matches the request body limit of the sync service
+	maxCountImportSize = 32 * 1024 * 1024
+)
+
+// CountImportError is a problem with one line of the CSV
+type CountImportError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// CountImportReport is the result of an import of historical counts, nothing is stored when
+// it has errors
+type CountImportReport struct {
+	ID          string             `json:"id,omitempty"`
+	Source      string             `json:"source,omitempty"`
+	DryRun      bool               `json:"dry_run"`
+	Rows        int                `json:"rows"`
+	Imported    int                `json:"imported"`
+	Replaced    int                `json:"replaced"`
+	SkippedLive int                `json:"skipped_live"`
+	Cameras     []int              `json:"cameras"`
+	From        string             `json:"from,omitempty"`
+	To          string             `json:"to,omitempty"`
+	ErrorCount  int                `json:"error_count"`
+	Errors      []CountImportError `json:"errors,omitempty"`
+}
+
+// CountImport is a stored import of historical counts
+type CountImport struct {
+	ID         string    `json:"id"`
+	Records    int64     `json:"records"`
+	Cameras    int64     `json:"cameras"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	ImportedAt time.Time `json:"imported_at"`
+}
+
+// ImportHistoricalCounts loads a CSV export of an older counting system into the local
+// analytics, flagged as imported. The CSV needs a timestamp (or date and time), camera, in and
+// out column. Timestamps without offset are read in timezone, empty for the local zone. With
+// dryRun the file is only validated; rows with problems are returned in the report.
+func (s *ServiceManager) ImportHistoricalCounts(path, timezone string, dryRun bool) (CountImportReport, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return CountImportReport{}, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return CountImportReport{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if info.Size() > maxCountImportSize {
+		return CountImportReport{}, fmt.Errorf("%s is larger than %d MB, split the export by year", filepath.Base(path), maxCountImportSize/1024/1024)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return CountImportReport{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	request := map[string]any{
+		"csv":      string(data),
+		"source":   filepath.Base(path),
+		"timezone": timezone,
+		"dry_run":  dryRun,
+	}
+	var report CountImportReport
+	if err := s.syncApiRequestBody(http.MethodPost, "/data/import", countImportTimeout, request, &report); err != nil {
+		// Baris yang bermasalah dikembalikan di laporan untuk ditampilkan
+		if report.ErrorCount > 0 {
+			return report, nil
+		}
+		return CountImportReport{}, err
+	}
+
+	if !dryRun {
+		s.logger.Info("Imported %d historical counts from %s as %s", report.Imported, report.Source, report.ID)
+	}
+	return report, nil
+}
+
+// GetHistoricalCountImports lists the stored imports of historical counts
+func (s *ServiceManager) GetHistoricalCountImports() ([]CountImport, error) {
+	var imports []CountImport
+	if err := s.syncApiRequest(http.MethodGet, "/data/imports", 10*time.Second, &imports); err != nil {
+		return nil, err
+	}
+	return imports, nil
+}
+
+// DeleteHistoricalCountImport removes the counts of one import, to undo an import of the
+// wrong file
+func (s *ServiceManager) DeleteHistoricalCountImport(id string) (int64, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return 0, err
+	}
+
+	var result struct {
+		Deleted int64 `json:"deleted"`
+	}
+	if err := s.syncApiRequest(http.MethodDelete, "/data/imports/"+url.PathEscape(id), 30*time.Second, &result); err != nil {
+		return 0, err
+	}
+
+	s.logger.Info("Deleted %d historical counts of import %s", result.Deleted, id)
+	return result.Deleted, nil
+}
@@ -0,0 +1,73 @@
+package servicemanager
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// SyncJobRun is one run of a sync service job
+type SyncJobRun struct {
+	ID         string    `json:"id"`
+	Job        string    `json:"job"`
+	Trigger    string    `json:"trigger"`
+	Status     string    `json:"status"` // running, success, failed or skipped
+	Error      string    `json:"error,omitempty"`
+	Result     any       `json:"result,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// SyncJob is a scheduled or on-demand maintenance job of the sync service
+type SyncJob struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Schedule    string       `json:"schedule,omitempty"`
+	OnDemand    bool         `json:"on_demand"`
+	JitterSec   float64      `json:"jitter_sec"`
+	Singleton   bool         `json:"singleton"`
+	Locks       []string     `json:"locks,omitempty"`
+	Running     bool         `json:"running"`
+	NextRun     *time.Time   `json:"next_run,omitempty"`
+	LastRun     *SyncJobRun  `json:"last_run,omitempty"`
+	Runs        int          `json:"runs"`
+	Failures    int          `json:"failures"`
+	Skipped     int          `json:"skipped"`
+	History     []SyncJobRun `json:"history"`
+}
+
+// GetSyncJobs returns the jobs of the sync service with their recent runs
+func (s *ServiceManager) GetSyncJobs() ([]SyncJob, error) {
+	var result struct {
+		Jobs []SyncJob `json:"jobs"`
+	}
+	if err := s.syncApiRequest(http.MethodGet, "/jobs", 5*time.Second, &result); err != nil {
+		return nil, err
+	}
+	return result.Jobs, nil
+}
+
+// RunSyncJob starts a sync service job, like vacuum_db or rescan_data_dir, and returns the
+// run to poll with GetSyncJobRun
+func (s *ServiceManager) RunSyncJob(name string) (SyncJobRun, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return SyncJobRun{}, err
+	}
+
+	s.logger.Info("Running sync service job %s", name)
+
+	var run SyncJobRun
+	if err := s.syncApiRequest(http.MethodPost, "/jobs/"+url.PathEscape(name)+"/run", 10*time.Second, &run); err != nil {
+		return SyncJobRun{}, err
+	}
+	return run, nil
+}
+
+// GetSyncJobRun returns the state of a sync service job run
+func (s *ServiceManager) GetSyncJobRun(id string) (SyncJobRun, error) {
+	var run SyncJobRun
+	if err := s.syncApiRequest(http.MethodGet, "/jobs/runs/"+url.PathEscape(id), 5*time.Second, &run); err != nil {
+		return SyncJobRun{}, err
+	}
+	return run, nil
+}
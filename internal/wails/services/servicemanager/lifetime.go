@@ -0,0 +1,46 @@
+package servicemanager
+
+import (
+	"net/http"
+	"time"
+)
+
+// CameraLifetime is the in/out total of one camera since install
+type CameraLifetime struct {
+	CCTVID    int       `json:"cctv_id"`
+	InTotal   int64     `json:"in_total"`
+	OutTotal  int64     `json:"out_total"`
+	Records   int64     `json:"records"`
+	FirstAt   time.Time `json:"first_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// VisitorMilestone is a multiple of the milestone step the site total passed. A baseline
+// milestone was passed before the counter existed and was not announced.
+type VisitorMilestone struct {
+	Visitors  int64     `json:"visitors"`
+	InTotal   int64     `json:"in_total"`
+	ReachedAt time.Time `json:"reached_at"`
+	Baseline  bool      `json:"baseline"`
+}
+
+// LifetimeCounts are the counts of the site since install, for customer-facing dashboards
+type LifetimeCounts struct {
+	InTotal       int64              `json:"in_total"`
+	OutTotal      int64              `json:"out_total"`
+	Records       int64              `json:"records"`
+	Since         *time.Time         `json:"since,omitempty"`
+	Cameras       []CameraLifetime   `json:"cameras"`
+	MilestoneStep int64              `json:"milestone_step"`
+	NextMilestone int64              `json:"next_milestone"`
+	Milestones    []VisitorMilestone `json:"milestones"` // Newest first
+}
+
+// GetLifetimeCounts returns the total in/out counts since install and the visitor milestones
+func (s *ServiceManager) GetLifetimeCounts() (LifetimeCounts, error) {
+	var counts LifetimeCounts
+	if err := s.syncApiRequest(http.MethodGet, "/data/lifetime", 10*time.Second, &counts); err != nil {
+		return LifetimeCounts{}, err
+	}
+	return counts, nil
+}
@@ -0,0 +1,85 @@
+package servicemanager
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// MaintenanceState is the maintenance mode of the sync service. While it is active data
+// publishing and alerts are paused, it ends on its own at ExpiresAt.
+type MaintenanceState struct {
+	Active       bool       `json:"active"`
+	Reason       string     `json:"reason,omitempty"`
+	Actor        string     `json:"actor,omitempty"`
+	Source       string     `json:"source,omitempty"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	RemainingSec float64    `json:"remaining_sec,omitempty"`
+}
+
+// MaintenanceWindow is a past maintenance period, shown in the timeline
+type MaintenanceWindow struct {
+	StartedAt   time.Time `json:"started_at"`
+	EndedAt     time.Time `json:"ended_at"`
+	DurationSec float64   `json:"duration_sec"`
+	Reason      string    `json:"reason,omitempty"`
+	Actor       string    `json:"actor,omitempty"`
+	Source      string    `json:"source"`
+	EndedBy     string    `json:"ended_by"` // manual or expired
+}
+
+// MaintenanceStatus is the current maintenance mode with the past windows
+type MaintenanceStatus struct {
+	State   MaintenanceState    `json:"state"`
+	History []MaintenanceWindow `json:"history"`
+}
+
+// maintenanceActor is recorded as the actor of switches made from the desktop app
+const maintenanceActor = "admin"
+
+// GetMaintenance returns the maintenance mode of the sync service
+func (s *ServiceManager) GetMaintenance() (MaintenanceStatus, error) {
+	var status MaintenanceStatus
+	if err := s.syncApiRequest(http.MethodGet, "/maintenance", 5*time.Second, &status); err != nil {
+		return MaintenanceStatus{}, err
+	}
+	return status, nil
+}
+
+// EnableMaintenance pauses publishing and alerts for durationMin minutes, 0 uses the default
+// of the sync service. A running maintenance window is extended.
+func (s *ServiceManager) EnableMaintenance(durationMin int, reason string) (MaintenanceState, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return MaintenanceState{}, err
+	}
+
+	s.logger.Info("Enabling maintenance mode for %d minutes: %s", durationMin, reason)
+
+	request := map[string]any{
+		"duration_min": durationMin,
+		"reason":       reason,
+		"actor":        maintenanceActor,
+	}
+	var state MaintenanceState
+	if err := s.syncApiRequestBody(http.MethodPost, "/maintenance", 5*time.Second, request, &state); err != nil {
+		return MaintenanceState{}, err
+	}
+	return state, nil
+}
+
+// DisableMaintenance ends maintenance mode before it expires
+func (s *ServiceManager) DisableMaintenance() (MaintenanceState, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return MaintenanceState{}, err
+	}
+
+	s.logger.Info("Disabling maintenance mode")
+
+	var state MaintenanceState
+	path := "/maintenance?actor=" + url.QueryEscape(maintenanceActor)
+	if err := s.syncApiRequest(http.MethodDelete, path, 5*time.Second, &state); err != nil {
+		return MaintenanceState{}, err
+	}
+	return state, nil
+}
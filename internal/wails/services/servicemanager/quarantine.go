@@ -0,0 +1,103 @@
+package servicemanager
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DataValidationRules decide which counts are quarantined instead of published
+type DataValidationRules struct {
+	Enabled            bool `json:"enabled"`
+	RejectNegative     bool `json:"reject_negative"`
+	MaxCountPerEntry   int  `json:"max_count_per_entry"` // 0 disables the rule
+	RejectFuture       bool `json:"reject_future"`
+	FutureToleranceMin int  `json:"future_tolerance_min"`
+}
+
+// RuleViolation is a validation rule a quarantined entry breaks
+type RuleViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// QuarantinedCount is a count held back by the validation rules
+type QuarantinedCount struct {
+	ID            uint            `json:"id"`
+	Filename      string          `json:"filename"`
+	DateFolder    string          `json:"date_folder"`
+	CCTVID        int             `json:"cctv_id"`
+	Data          map[string]any  `json:"data"`
+	Violations    []RuleViolation `json:"violations"`
+	Status        string          `json:"status"` // quarantined, released or discarded
+	QuarantinedAt time.Time       `json:"quarantined_at"`
+	ReviewedAt    *time.Time      `json:"reviewed_at,omitempty"`
+	ReviewedBy    string          `json:"reviewed_by,omitempty"`
+}
+
+// QuarantineList is a page of quarantined counts with the number of counts per status
+type QuarantineList struct {
+	Entries []QuarantinedCount `json:"entries"`
+	Counts  map[string]int64   `json:"counts"`
+}
+
+// GetDataValidationRules returns the rules that quarantine anomalous counts
+func (s *ServiceManager) GetDataValidationRules() (DataValidationRules, error) {
+	var rules DataValidationRules
+	if err := s.syncApiRequest(http.MethodGet, "/data/validation", 5*time.Second, &rules); err != nil {
+		return DataValidationRules{}, err
+	}
+	return rules, nil
+}
+
+// UpdateDataValidationRules replaces the validation rules, they apply to the next data file
+func (s *ServiceManager) UpdateDataValidationRules(rules DataValidationRules) (DataValidationRules, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return DataValidationRules{}, err
+	}
+
+	var applied DataValidationRules
+	if err := s.syncApiRequestBody(http.MethodPut, "/data/validation", 5*time.Second, rules, &applied); err != nil {
+		return DataValidationRules{}, err
+	}
+
+	s.logger.Info("Updated data validation rules: %+v", applied)
+	return applied, nil
+}
+
+// GetQuarantinedCounts lists quarantined counts with the given status, empty for the ones
+// waiting for review
+func (s *ServiceManager) GetQuarantinedCounts(status string) (QuarantineList, error) {
+	var list QuarantineList
+	path := "/data/quarantine?status=" + url.QueryEscape(status)
+	if err := s.syncApiRequest(http.MethodGet, path, 10*time.Second, &list); err != nil {
+		return QuarantineList{}, err
+	}
+	return list, nil
+}
+
+// ReleaseQuarantinedCount publishes a quarantined count the operator checked
+func (s *ServiceManager) ReleaseQuarantinedCount(id uint) (QuarantinedCount, error) {
+	return s.reviewQuarantinedCount(id, "release")
+}
+
+// DiscardQuarantinedCount drops a quarantined count for good
+func (s *ServiceManager) DiscardQuarantinedCount(id uint) (QuarantinedCount, error) {
+	return s.reviewQuarantinedCount(id, "discard")
+}
+
+func (s *ServiceManager) reviewQuarantinedCount(id uint, decision string) (QuarantinedCount, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return QuarantinedCount{}, err
+	}
+
+	var entry QuarantinedCount
+	path := fmt.Sprintf("/data/quarantine/%d/%s", id, decision)
+	if err := s.syncApiRequest(http.MethodPost, path, 10*time.Second, &entry); err != nil {
+		return QuarantinedCount{}, err
+	}
+
+	s.logger.Info("Quarantined count %d of %s: %s", id, entry.Filename, entry.Status)
+	return entry, nil
+}
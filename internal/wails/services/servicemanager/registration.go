@@ -0,0 +1,62 @@
+package servicemanager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// SiteRegistration is the registration of the site with the backend. Until it is accepted,
+// skipped or the site published before registration existed, data stays in the local queue.
+type SiteRegistration struct {
+	Status     string          `json:"status"` // pending, waiting, accepted, rejected, skipped or legacy
+	RequestID  string          `json:"request_id,omitempty"`
+	Attempts   int             `json:"attempts"`
+	SentAt     *time.Time      `json:"sent_at,omitempty"`
+	AnsweredAt *time.Time      `json:"answered_at,omitempty"`
+	Reason     string          `json:"reason,omitempty"`
+	Config     json.RawMessage `json:"config,omitempty"`
+}
+
+// GetSiteRegistration returns the registration of the site with the backend
+func (s *ServiceManager) GetSiteRegistration() (SiteRegistration, error) {
+	var registration SiteRegistration
+	if err := s.syncApiRequest(http.MethodGet, "/registration", 5*time.Second, &registration); err != nil {
+		return SiteRegistration{}, err
+	}
+	return registration, nil
+}
+
+// RetrySiteRegistration sends the site registration again, after the site was set up on the
+// backend following a rejection
+func (s *ServiceManager) RetrySiteRegistration() (SiteRegistration, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return SiteRegistration{}, err
+	}
+
+	s.logger.Info("Retrying site registration")
+
+	var registration SiteRegistration
+	if err := s.syncApiRequest(http.MethodPost, "/registration/retry", 15*time.Second, &registration); err != nil {
+		return SiteRegistration{}, err
+	}
+	return registration, nil
+}
+
+// SkipSiteRegistration lets the site publish without an answer of the backend, for backends
+// that do not handle registrations
+func (s *ServiceManager) SkipSiteRegistration() (SiteRegistration, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return SiteRegistration{}, err
+	}
+
+	s.logger.Info("Skipping site registration")
+
+	var registration SiteRegistration
+	path := "/registration/skip?actor=" + url.QueryEscape(maintenanceActor)
+	if err := s.syncApiRequest(http.MethodPost, path, 5*time.Second, &registration); err != nil {
+		return SiteRegistration{}, err
+	}
+	return registration, nil
+}
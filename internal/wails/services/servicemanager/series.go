@@ -0,0 +1,49 @@
+package servicemanager
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// CountBucket holds the in/out totals from Start until the start of the next bucket
+type CountBucket struct {
+	Start    time.Time `json:"start"`
+	Records  int64     `json:"records"`
+	InCount  int64     `json:"in_count"`
+	OutCount int64     `json:"out_count"`
+}
+
+// CountSeries is a downsampled series of the counts, every bucket of the range is listed
+type CountSeries struct {
+	Bucket   string        `json:"bucket"`
+	Timezone string        `json:"timezone"`
+	From     time.Time     `json:"from"`
+	To       time.Time     `json:"to"`
+	CCTVID   int           `json:"cctv_id,omitempty"`
+	Buckets  []CountBucket `json:"buckets"`
+	InCount  int64         `json:"in_count"`
+	OutCount int64         `json:"out_count"`
+}
+
+// GetCountSeries returns the counts from-to (YYYY-MM-DD, to included) summed per bucket in
+// the site time zone. bucket is hour, day or week, empty picks one from the range so months
+// of data are returned per week instead of per hour. cctvID 0 sums all cameras.
+func (s *ServiceManager) GetCountSeries(from, to string, cctvID int, bucket string) (CountSeries, error) {
+	query := url.Values{}
+	query.Set("from", from)
+	query.Set("to", to)
+	if cctvID > 0 {
+		query.Set("cctv_id", strconv.Itoa(cctvID))
+	}
+	if bucket != "" {
+		query.Set("bucket", bucket)
+	}
+
+	var series CountSeries
+	if err := s.syncApiRequest(http.MethodGet, "/data/series?"+query.Encode(), 30*time.Second, &series); err != nil {
+		return CountSeries{}, err
+	}
+	return series, nil
+}
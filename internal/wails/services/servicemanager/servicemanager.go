@@ -1,25 +1,42 @@
 package servicemanager
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"jarvist/internal/common/buildinfo"
 	"jarvist/internal/common/config"
+	"jarvist/internal/wails/services/auth"
+	"jarvist/internal/wails/services/callguard"
+	"jarvist/internal/wails/services/usage"
 	"jarvist/pkg/logger"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
 	"golang.org/x/sys/windows"
 	"golang.org/x/sys/windows/svc/mgr"
 )
 
+// serviceCommandTimeout limits one run of the sync-manager binary, serviceCallTimeout a
+// bound method that may run it more than once
+const (
+	serviceCommandTimeout = 2 * time.Minute
+	serviceCallTimeout    = 5 * time.Minute
+)
+
 // ServiceManager handles interactions with the Windows system service
 type ServiceManager struct {
 	config        *config.Config
 	logger        *logger.ContextLogger
 	serviceBinary string
 	serviceName   string
+	guard         auth.Guard
+	calls         *callguard.Guard
 }
 
 func New(config *config.Config, logger *logger.Logger) *ServiceManager {
@@ -30,12 +47,32 @@ func New(config *config.Config, logger *logger.Logger) *ServiceManager {
 	execDir := filepath.Dir(execPath)
 	serviceBinary := filepath.Join(execDir, "sync-manager.exe")
 
+	serviceLogger := logger.WithComponent("service-manager")
+
 	return &ServiceManager{
 		config:        config,
-		logger:        logger.WithComponent("service-manager"),
+		logger:        serviceLogger,
 		serviceBinary: serviceBinary,
 		serviceName:   "jarvist-sync",
+		calls:         callguard.New(serviceLogger).WithSlowThreshold(30 * time.Second),
+	}
+}
+
+// SetGuard sets the lock guard checked before service control actions
+func (s *ServiceManager) SetGuard(guard auth.Guard) {
+	s.guard = guard
+}
+
+func (s *ServiceManager) requireUnlocked() error {
+	if s.guard == nil {
+		return nil
 	}
+	return s.guard.RequireUnlocked()
+}
+
+// GetServiceBinaryPath returns the path of the sync-manager executable
+func (s *ServiceManager) GetServiceBinaryPath() string {
+	return s.serviceBinary
 }
 
 func (s *ServiceManager) runCommand(args ...string) (string, error) {
@@ -43,12 +80,33 @@ func (s *ServiceManager) runCommand(args ...string) (string, error) {
 		return "", fmt.Errorf("service management is only supported on Windows")
 	}
 
-	cmd := exec.Command(s.serviceBinary, args...)
+	// Binary yang hang dimatikan setelah timeout
+	ctx, cancel := context.WithTimeout(context.Background(), serviceCommandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, s.serviceBinary, args...)
 	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return string(output), fmt.Errorf("%s %s timed out after %s", filepath.Base(s.serviceBinary), strings.Join(args, " "), serviceCommandTimeout)
+	}
 	return string(output), err
 }
 
+// guardedCommand runs a service control method through the call guard
+func (s *ServiceManager) guardedCommand(method string, fn func() (string, error)) (string, error) {
+	return callguard.Call(s.calls, context.Background(), method, serviceCallTimeout, func(ctx context.Context) (string, error) {
+		return fn()
+	})
+}
+
 func (s *ServiceManager) InstallService() (string, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return "", err
+	}
+	return s.guardedCommand("InstallService", s.installService)
+}
+
+func (s *ServiceManager) installService() (string, error) {
 	s.logger.Info("Installing service...")
 	output, err := s.runCommand("--install")
 	if err != nil {
@@ -67,16 +125,32 @@ func (s *ServiceManager) InstallService() (string, error) {
 }
 
 func (s *ServiceManager) UninstallService() (string, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return "", err
+	}
 	s.logger.Info("Uninstalling service...")
-	return s.runCommand("--uninstall")
+	return s.guardedCommand("UninstallService", func() (string, error) {
+		return s.runCommand("--uninstall")
+	})
 }
 
 func (s *ServiceManager) StartService() (string, error) {
 	s.logger.Info("Starting service...")
-	return s.runCommand("--start")
+	usage.Feature(usage.ServiceControl)
+	return s.guardedCommand("StartService", func() (string, error) {
+		return s.runCommand("--start")
+	})
 }
 
 func (s *ServiceManager) StopService() (string, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return "", err
+	}
+	usage.Feature(usage.ServiceControl)
+	return s.guardedCommand("StopService", s.stopService)
+}
+
+func (s *ServiceManager) stopService() (string, error) {
 	s.logger.Info("Stopping service...")
 	return s.runCommand("--stop")
 }
@@ -261,16 +335,24 @@ func (s *ServiceManager) GetServiceDetails() (map[string]interface{}, error) {
 
 // RestartService restarts the Windows service
 func (s *ServiceManager) RestartService() (string, error) {
-	s.logger.Info("Restarting service...")
-	_, err := s.StopService()
-	if err != nil {
-		return "", fmt.Errorf("failed to stop service: %w", err)
+	if err := s.requireUnlocked(); err != nil {
+		return "", err
 	}
 
-	// Wait a moment for the service to fully stop
-	time.Sleep(2 * time.Second)
+	s.logger.Info("Restarting service...")
+	usage.Feature(usage.ServiceControl)
+	return s.guardedCommand("RestartService", func() (string, error) {
+		_, err := s.stopService()
+		if err != nil {
+			return "", fmt.Errorf("failed to stop service: %w", err)
+		}
+
+		// Wait a moment for the service to fully stop
+		time.Sleep(2 * time.Second)
 
-	return s.StartService()
+		s.logger.Info("Starting service...")
+		return s.runCommand("--start")
+	})
 }
 
 func (s *ServiceManager) CheckAndInstallService() (string, error) {
@@ -286,7 +368,7 @@ func (s *ServiceManager) CheckAndInstallService() (string, error) {
 	}
 
 	s.logger.Info("Service not installed, installing...")
-	return s.InstallService()
+	return s.installService()
 }
 
 func (s *ServiceManager) EnsureServiceRunning() (string, error) {
@@ -299,7 +381,7 @@ func (s *ServiceManager) EnsureServiceRunning() (string, error) {
 
 	if !installed {
 		s.logger.Info("Service not installed, installing...")
-		return s.InstallService()
+		return s.installService()
 	}
 
 	running, err := s.IsServiceRunning()
@@ -314,3 +396,96 @@ func (s *ServiceManager) EnsureServiceRunning() (string, error) {
 
 	return "Service is already installed and running", nil
 }
+
+// GetServiceVersion returns the build info reported by the installed service binary
+func (s *ServiceManager) GetServiceVersion() (buildinfo.BuildInfo, error) {
+	var info buildinfo.BuildInfo
+
+	output, err := s.runCommand("--version")
+	if err != nil {
+		return info, fmt.Errorf("failed to query service version: %w", err)
+	}
+
+	// Ambil baris JSON terakhir, abaikan output lain dari binary
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(line, "{") {
+			if err := json.Unmarshal([]byte(line), &info); err != nil {
+				return info, fmt.Errorf("failed to parse service version: %w", err)
+			}
+			return info, nil
+		}
+	}
+
+	return info, fmt.Errorf("service binary did not report a version")
+}
+
+// CheckCompatibility verifies the installed service version against the range supported by this build
+func (s *ServiceManager) CheckCompatibility() buildinfo.CompatResult {
+	appInfo := s.config.BuildInfo
+
+	serviceVersion := ""
+	if info, err := s.GetServiceVersion(); err != nil {
+		s.logger.Warn("Failed to get service version: %v", err)
+	} else {
+		serviceVersion = info.ProductVersion
+	}
+
+	result := buildinfo.CheckCompatibility(appInfo.ProductVersion, serviceVersion, appInfo.Compat)
+	if !result.Compatible {
+		s.logger.Warn("Service version mismatch: %s", result.Message)
+	} else {
+		s.logger.Info("Service version %s is compatible with app version %s", serviceVersion, appInfo.ProductVersion)
+	}
+
+	return result
+}
+
+// NetworkStatus is the connectivity state reported by the sync service health endpoint
+type NetworkStatus struct {
+	ServiceReachable bool   `json:"service_reachable"`
+	State            string `json:"network_state"`
+	BrokerReachable  bool   `json:"broker_reachable"`
+}
+
+// GetNetworkStatus returns the connectivity state seen by the sync service, used for offline banners
+func (s *ServiceManager) GetNetworkStatus() NetworkStatus {
+	client := &http.Client{
+		Timeout: 3 * time.Second,
+	}
+
+	resp, err := client.Get(s.config.SyncApiURL() + "/health")
+	if err != nil {
+		return NetworkStatus{State: "unknown"}
+	}
+	defer resp.Body.Close()
+
+	status := NetworkStatus{ServiceReachable: true}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil || status.State == "" {
+		status.State = "unknown"
+	}
+	return status
+}
+
+// SyncApiEndpoint is the API port of the sync service and why it differs from the configured one
+type SyncApiEndpoint struct {
+	URL      string `json:"url"`
+	Recorded bool   `json:"recorded"` // False until the sync service has started once with this version
+	config.SyncEndpoint
+}
+
+// GetSyncApiEndpoint returns the port the sync service API listens on. It is read from the
+// file the service writes, so a port conflict is reported even when the API is not reachable.
+func (s *ServiceManager) GetSyncApiEndpoint() (SyncApiEndpoint, error) {
+	endpoint, recorded, err := config.LoadSyncEndpoint()
+	if err != nil {
+		return SyncApiEndpoint{}, err
+	}
+
+	return SyncApiEndpoint{
+		URL:          s.config.SyncApiURL(),
+		Recorded:     recorded,
+		SyncEndpoint: endpoint,
+	}, nil
+}
@@ -0,0 +1,45 @@
+package servicemanager
+
+import "jarvist/internal/common/config"
+
+// ServiceStartup is what the sync service waits for on boot and how far the last start got.
+// It is read from the files the service writes, the API is not up while it waits.
+type ServiceStartup struct {
+	Conditions config.StartupConditions `json:"conditions"`
+	State      *config.StartupState     `json:"state,omitempty"` // Nil until the service started once with this version
+	Waiting    bool                     `json:"waiting"`
+}
+
+// GetServiceStartup returns the startup conditions and the progress of the last start
+func (s *ServiceManager) GetServiceStartup() (ServiceStartup, error) {
+	conditions, err := config.LoadStartupConditions()
+	if err != nil {
+		return ServiceStartup{}, err
+	}
+
+	startup := ServiceStartup{Conditions: conditions}
+	state, recorded, err := config.LoadStartupState()
+	if err != nil {
+		return ServiceStartup{}, err
+	}
+	if recorded {
+		startup.State = &state
+		startup.Waiting = state.InProgress()
+	}
+	return startup, nil
+}
+
+// UpdateStartupConditions stores what the sync service waits for on boot, it applies on the
+// next start of the service
+func (s *ServiceManager) UpdateStartupConditions(conditions config.StartupConditions) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+	if err := config.SaveStartupConditions(conditions); err != nil {
+		return err
+	}
+
+	s.logger.Info("Startup conditions updated: delay %ds, network %t, %d paths, max wait %ds",
+		conditions.DelaySec, conditions.WaitNetwork, len(conditions.WaitPaths), conditions.MaxWaitSec)
+	return nil
+}
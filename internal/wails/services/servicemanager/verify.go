@@ -0,0 +1,84 @@
+package servicemanager
+
+import (
+	"net/http"
+	"time"
+)
+
+// verifyTimeout bounds a verification run of the sync service, probing many cameras takes
+// a while
+const verifyTimeout = 6 * time.Minute
+
+// VerificationStep is the outcome of one part of the installation verification
+type VerificationStep struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"` // pass, warn, fail or skip
+	Message  string `json:"message"`
+	Hint     string `json:"hint,omitempty"`
+	Details  any    `json:"details,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// VerificationSignOff records the installer who accepted a passed report
+type VerificationSignOff struct {
+	Installer string    `json:"installer"`
+	Notes     string    `json:"notes,omitempty"`
+	SignedAt  time.Time `json:"signed_at"`
+}
+
+// VerificationReport is the pass or fail report of the installation verification
+type VerificationReport struct {
+	ID         string               `json:"id"`
+	Passed     bool                 `json:"passed"`
+	Steps      []VerificationStep   `json:"steps"`
+	Identity   map[string]any       `json:"identity"`
+	Version    string               `json:"version,omitempty"`
+	StartedAt  time.Time            `json:"started_at"`
+	FinishedAt time.Time            `json:"finished_at"`
+	SignOff    *VerificationSignOff `json:"sign_off,omitempty"`
+}
+
+// RunInstallVerification runs the installation smoke test in the sync service: it probes
+// every camera, sends a synthetic data file through decrypt and publish to a test topic and
+// checks the service components
+func (s *ServiceManager) RunInstallVerification() (VerificationReport, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return VerificationReport{}, err
+	}
+
+	s.logger.Info("Running installation verification")
+
+	var report VerificationReport
+	if err := s.syncApiRequest(http.MethodPost, "/verify", verifyTimeout, &report); err != nil {
+		return VerificationReport{}, err
+	}
+
+	s.logger.Info("Installation verification %s finished (passed: %v)", report.ID, report.Passed)
+	return report, nil
+}
+
+// GetInstallVerification returns the report of the last installation verification
+func (s *ServiceManager) GetInstallVerification() (VerificationReport, error) {
+	var report VerificationReport
+	if err := s.syncApiRequest(http.MethodGet, "/verify", 5*time.Second, &report); err != nil {
+		return VerificationReport{}, err
+	}
+	return report, nil
+}
+
+// SignOffInstallVerification records the installer accepting the last report, only a
+// passed report can be signed off
+func (s *ServiceManager) SignOffInstallVerification(installer, notes string) (VerificationReport, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return VerificationReport{}, err
+	}
+
+	request := map[string]string{"installer": installer, "notes": notes}
+	var report VerificationReport
+	if err := s.syncApiRequestBody(http.MethodPost, "/verify/signoff", 5*time.Second, request, &report); err != nil {
+		return VerificationReport{}, err
+	}
+
+	s.logger.Info("Installation verification %s signed off by %s", report.ID, installer)
+	return report, nil
+}
@@ -7,10 +7,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"jarvist/internal/common/bandwidth"
 	"jarvist/internal/common/config"
 	"jarvist/internal/common/models"
+	"jarvist/internal/common/residency"
+	"jarvist/internal/wails/services/auth"
 	licenseservice "jarvist/internal/wails/services/license"
+	"jarvist/internal/wails/services/processmanager"
+	"jarvist/internal/wails/services/usage"
 	"jarvist/pkg/logger"
+	"jarvist/pkg/utils"
 	"log"
 	"net/http"
 	"os"
@@ -36,6 +42,8 @@ type SettingsService struct {
 	logger         *logger.ContextLogger
 	requiredKeys   []string
 	licenseService *licenseservice.LicenseService
+	guard          auth.Guard
+	process        *processmanager.ProcessManagerService
 }
 
 type EnvConfigItem struct {
@@ -72,6 +80,24 @@ func (s *SettingsService) OnStartup(ctx context.Context, options application.Ser
 	s.CheckAndCreateEnvFile()
 	return nil
 }
+
+// SetProcessManager sets the process manager used to signal the counter after .env changes
+func (s *SettingsService) SetProcessManager(process *processmanager.ProcessManagerService) {
+	s.process = process
+}
+
+// SetGuard sets the lock guard checked before settings are changed
+func (s *SettingsService) SetGuard(guard auth.Guard) {
+	s.guard = guard
+}
+
+func (s *SettingsService) requireUnlocked() error {
+	if s.guard == nil {
+		return nil
+	}
+	return s.guard.RequireUnlocked()
+}
+
 func (s *SettingsService) IsConfigured() bool {
 	var count int64
 	s.db.Model(&models.Setting{}).Count(&count)
@@ -95,7 +121,23 @@ func (s *SettingsService) IsConfigured() bool {
 	return true
 }
 
+// authSettingPrefix marks the settings of the admin PIN. AuthService reads them from the
+// database itself, they are never returned to the frontend or written to the .env file.
+const authSettingPrefix = "auth_"
+
+func isAuthSetting(key string) bool {
+	return strings.HasPrefix(key, authSettingPrefix)
+}
+
+// errResidencySetting refuses writes to the data residency mode, it only changes through
+// ResidencyService.SetDataResidency which verifies the PIN and writes the audit entry
+var errResidencySetting = errors.New(residency.Key + " can only be changed through the data residency switch")
+
 func (s *SettingsService) GetSetting(key string) (string, error) {
+	if isAuthSetting(key) {
+		return "", errors.New("setting not found")
+	}
+
 	var setting models.Setting
 	result := s.db.Where("key = ?", key).First(&setting)
 
@@ -116,6 +158,9 @@ func (s *SettingsService) GetAllSettings() (map[string]string, error) {
 
 	result := make(map[string]string)
 	for _, setting := range settings {
+		if isAuthSetting(setting.Key) {
+			continue
+		}
 		result[setting.Key] = setting.Value
 	}
 
@@ -140,6 +185,10 @@ func (s *SettingsService) GetTimeZones() ([]models.TimeZone, error) {
 }
 
 func (s *SettingsService) SavePlaceConfig(input models.SettingInput) (map[string]interface{}, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return nil, err
+	}
+
 	// Prepare the settings map
 	setting := make(map[string]string)
 	setting["site_code"] = input.SiteCode
@@ -171,7 +220,8 @@ func (s *SettingsService) SavePlaceConfig(input models.SettingInput) (map[string
 	req.Header.Add("X-API-Key", s.config.ApiKey)
 
 	client := &http.Client{
-		Timeout: 10 * time.Second,
+		Timeout:   10 * time.Second,
+		Transport: bandwidth.NewTransport(bandwidth.DestinationAPI),
 	}
 
 	resp, err := client.Do(req)
@@ -205,7 +255,7 @@ func (s *SettingsService) SavePlaceConfig(input models.SettingInput) (map[string
 	setting["site_id"] = strconv.FormatInt(int64(apiResp.Data.PlaceID), 10)
 
 	// Only save settings locally AFTER successful API call
-	if err := s.SaveSettings(setting); err != nil {
+	if err := s.saveSettings(setting); err != nil {
 		return nil, fmt.Errorf("API call was successful but failed to save settings to local database: %w", err)
 	}
 
@@ -224,6 +274,10 @@ func (s *SettingsService) SavePlaceConfig(input models.SettingInput) (map[string
 }
 
 func (s *SettingsService) UpdatePlaceConfig(placeId uint, input models.SettingInput) (map[string]interface{}, error) {
+	if err := s.requireUnlocked(); err != nil {
+		return nil, err
+	}
+
 	// Prepare the settings map
 	setting := make(map[string]string)
 	setting["site_code"] = input.SiteCode
@@ -253,7 +307,8 @@ func (s *SettingsService) UpdatePlaceConfig(placeId uint, input models.SettingIn
 	req.Header.Add("X-API-Key", s.config.ApiKey)
 
 	client := &http.Client{
-		Timeout: 10 * time.Second,
+		Timeout:   10 * time.Second,
+		Transport: bandwidth.NewTransport(bandwidth.DestinationAPI),
 	}
 
 	resp, err := client.Do(req)
@@ -286,7 +341,7 @@ func (s *SettingsService) UpdatePlaceConfig(placeId uint, input models.SettingIn
 
 	setting["site_id"] = strconv.FormatInt(int64(apiResp.Data.PlaceID), 10)
 
-	if err := s.SaveSettings(setting); err != nil {
+	if err := s.saveSettings(setting); err != nil {
 		return nil, fmt.Errorf("API call was successful but failed to save settings to local database: %w", err)
 	}
 
@@ -305,6 +360,14 @@ func (s *SettingsService) UpdatePlaceConfig(placeId uint, input models.SettingIn
 }
 
 func (s *SettingsService) SaveSetting(key, value string) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+	if key == residency.Key {
+		return errResidencySetting
+	}
+	usage.Feature(usage.SettingsChange)
+
 	var setting models.Setting
 	result := s.db.Where("key = ?", key).First(&setting)
 
@@ -323,6 +386,17 @@ func (s *SettingsService) SaveSetting(key, value string) error {
 }
 
 func (s *SettingsService) SaveSettings(settings map[string]string) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+	if _, ok := settings[residency.Key]; ok {
+		return errResidencySetting
+	}
+	usage.Feature(usage.SettingsChange)
+	return s.saveSettings(settings)
+}
+
+func (s *SettingsService) saveSettings(settings map[string]string) error {
 	return s.db.Transaction(func(tx *gorm.DB) error {
 		for key, value := range settings {
 			var setting models.Setting
@@ -349,6 +423,30 @@ func (s *SettingsService) SaveSettings(settings map[string]string) error {
 	})
 }
 
+// GetUploadPauseState returns whether non-critical uploads are paused on this device
+func (s *SettingsService) GetUploadPauseState() bandwidth.PauseState {
+	return bandwidth.GetPauseState(s.db)
+}
+
+// SetUploadPauseMode sets auto, pause or resume; the sync service applies it within 30 seconds
+func (s *SettingsService) SetUploadPauseMode(mode string) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+	return bandwidth.SetPauseMode(s.db, mode)
+}
+
+// SetMeteredConnection flags the connection as metered and sets the daily cap in MB (0 = no cap)
+func (s *SettingsService) SetMeteredConnection(metered bool, dailyCapMB int) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+	if err := bandwidth.SetMetered(s.db, metered); err != nil {
+		return err
+	}
+	return bandwidth.SetDailyCap(s.db, dailyCapMB)
+}
+
 func (s *SettingsService) CreateDefaultSettings() error {
 	defaultSettings := map[string]string{
 		"default_timezone":     "Asia/Jakarta",
@@ -356,10 +454,17 @@ func (s *SettingsService) CreateDefaultSettings() error {
 		"log_level":            "info",
 	}
 
-	return s.SaveSettings(defaultSettings)
+	return s.saveSettings(defaultSettings)
 }
 
 func (s *SettingsService) DeleteSetting(key string) error {
+	if err := s.requireUnlocked(); err != nil {
+		return err
+	}
+	if key == residency.Key {
+		return errResidencySetting
+	}
+	usage.Feature(usage.SettingsChange)
 	return s.db.Where("key = ?", key).Delete(&models.Setting{}).Error
 }
 
@@ -404,14 +509,17 @@ func (s *SettingsService) GenerateEnvFile(settings map[string]string) error {
 			{Key: "RESET_TIME", Value: "00:01", Description: "Time to reset the application daily"},
 			{Key: "API_ENDPOINT", Value: "https://vision-map.pitds.my.id/v1/people-counting", Description: "API endpoint for data uploads"},
 			{Key: "API_KEY", Value: "4pPk3y1", Description: "API key for authentication"},
+			{Key: "STATUS_ENDPOINT", Value: processmanager.StatusEndpoint(processmanager.DefaultStatusPort), Description: "Endpoint for pushing JSON-line process status"},
+			{Key: "RELOAD_SIGNAL_PATH", Value: processmanager.ReloadSignalFile, Description: "File that requests a config reload, delete it to acknowledge"},
+			{Key: config.BackfillDirEnv, Value: config.BackfillDirName, Description: "Directory of requests to re-export cached data, delete a request to acknowledge"},
 		},
 	}
 
 	if s.licenseService != nil {
 		licenseDetails := s.licenseService.GetLicenseDetails()
 
-		if clientID, ok := licenseDetails["clientId"]; ok && clientID != nil {
-			clientIDStr := fmt.Sprintf("%.0f", clientID.(float64))
+		if licenseDetails.ClientID != 0 {
+			clientIDStr := fmt.Sprintf("%.0f", licenseDetails.ClientID)
 			for i, item := range cfg.Items {
 				if item.Key == "CLIENT_ID" {
 					cfg.Items[i].Value = clientIDStr
@@ -431,6 +539,16 @@ func (s *SettingsService) GenerateEnvFile(settings map[string]string) error {
 		}
 	}
 
+	// A relocated services data directory is passed as an absolute path
+	if dataDir := s.config.ServicesDataDir; !strings.EqualFold(filepath.Clean(dataDir), filepath.Join(s.config.ServicesDir, "data")) {
+		for i, item := range cfg.Items {
+			if item.Key == "LOCAL_SAVE_DATA_PATH" {
+				cfg.Items[i].Value = dataDir
+				break
+			}
+		}
+	}
+
 	savePath := filepath.Join(filepath.Dir(s.config.BinDir), "bin", "services", ".env")
 
 	err := os.MkdirAll(filepath.Dir(savePath), 0755)
@@ -452,10 +570,18 @@ func (s *SettingsService) GenerateEnvFile(settings map[string]string) error {
 		}
 	}
 
-	err = os.WriteFile(savePath, []byte(content.String()), 0644)
+	if existing, err := os.ReadFile(savePath); err == nil && string(existing) == content.String() {
+		return nil
+	}
+
+	err = utils.WriteFileAtomic(savePath, []byte(content.String()), 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write .env file: %w", err)
 	}
 
+	if s.process != nil {
+		s.process.RequestCounterReload(".env changed", savePath)
+	}
+
 	return nil
 }
@@ -0,0 +1,186 @@
+package site
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"jarvist/internal/common/bandwidth"
+	"jarvist/internal/common/models"
+	"jarvist/internal/common/residency"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Setting keys of the site metadata
+const (
+	settingAddress   = "site_address"
+	settingLatitude  = "site_latitude"
+	settingLongitude = "site_longitude"
+)
+
+// SiteMetadata is the address and map position of the site
+type SiteMetadata struct {
+	Address   string   `json:"address"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+}
+
+// locationMetadata is a location as synced to the backend
+type locationMetadata struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	Description   string   `json:"description,omitempty"`
+	Address       string   `json:"address,omitempty"`
+	Latitude      *float64 `json:"latitude,omitempty"`
+	Longitude     *float64 `json:"longitude,omitempty"`
+	Floorplan     string   `json:"floorplan,omitempty"` // Base64
+	FloorplanType string   `json:"floorplan_type,omitempty"`
+}
+
+// GetSiteMetadata returns the address and coordinates of the site
+func (s *SiteService) GetSiteMetadata() SiteMetadata {
+	metadata := SiteMetadata{
+		Address: s.settingService.GetSettingWithDefault(settingAddress, ""),
+	}
+	metadata.Latitude = parseCoordinate(s.settingService.GetSettingWithDefault(settingLatitude, ""))
+	metadata.Longitude = parseCoordinate(s.settingService.GetSettingWithDefault(settingLongitude, ""))
+	return metadata
+}
+
+// SaveSiteMetadata saves the address and coordinates of the site and syncs them to the backend
+func (s *SiteService) SaveSiteMetadata(metadata SiteMetadata) error {
+	if err := models.ValidateCoordinates(metadata.Latitude, metadata.Longitude); err != nil {
+		return err
+	}
+
+	settings := map[string]string{
+		settingAddress:   strings.TrimSpace(metadata.Address),
+		settingLatitude:  formatCoordinate(metadata.Latitude),
+		settingLongitude: formatCoordinate(metadata.Longitude),
+	}
+	if err := s.settingService.SaveSettings(settings); err != nil {
+		return fmt.Errorf("failed to save site metadata: %w", err)
+	}
+
+	s.SyncMetadataAsync()
+	return nil
+}
+
+// SyncMetadata sends the site metadata and the locations with their floorplans to the backend
+func (s *SiteService) SyncMetadata() error {
+	if residency.LocalOnly() {
+		return residency.ErrLocalOnly
+	}
+
+	siteID, err := s.settingService.GetSetting("site_id")
+	if err != nil {
+		return fmt.Errorf("failed to get site id: %w", err)
+	}
+	siteIDInt, err := strconv.Atoi(siteID)
+	if err != nil {
+		return fmt.Errorf("failed to convert site id to integer: %w", err)
+	}
+
+	var locations []models.Location
+	if err := s.db.Find(&locations).Error; err != nil {
+		return fmt.Errorf("failed to list locations: %w", err)
+	}
+
+	locationsSync := make([]locationMetadata, 0, len(locations))
+	for _, location := range locations {
+		item := locationMetadata{
+			ID:          location.ID,
+			Name:        location.Name,
+			Description: location.Description,
+			Address:     location.Address,
+			Latitude:    location.Latitude,
+			Longitude:   location.Longitude,
+		}
+		if location.FloorplanPath != "" {
+			if image, err := os.ReadFile(location.FloorplanPath); err == nil {
+				item.Floorplan = base64.StdEncoding.EncodeToString(image)
+				item.FloorplanType = http.DetectContentType(image)
+			} else {
+				s.logger.Warn("Failed to read floorplan of location %s: %v", location.ID, err)
+			}
+		}
+		locationsSync = append(locationsSync, item)
+	}
+
+	metadata := s.GetSiteMetadata()
+	requestBody := map[string]interface{}{
+		"site_id":   siteIDInt,
+		"address":   metadata.Address,
+		"latitude":  metadata.Latitude,
+		"longitude": metadata.Longitude,
+		"locations": locationsSync,
+	}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return fmt.Errorf("failed to marshal site metadata: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", s.config.ApiUrl+"/v1/app/site-metadata/sync", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Add("X-API-Key", s.config.ApiKey)
+
+	client := &http.Client{
+		Timeout:   60 * time.Second,
+		Transport: bandwidth.NewTransport(bandwidth.DestinationAPI),
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send sync request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var syncResponse struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&syncResponse); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	if !syncResponse.Success {
+		return fmt.Errorf("sync failed: %s", syncResponse.Message)
+	}
+
+	s.logger.Info("Site metadata synchronization completed: %s", syncResponse.Message)
+	return nil
+}
+
+// SyncMetadataAsync runs SyncMetadata in the background
+func (s *SiteService) SyncMetadataAsync() {
+	go func() {
+		if err := s.SyncMetadata(); err != nil {
+			s.logger.Error("Background site metadata sync error: %v", err)
+		}
+	}()
+}
+
+func parseCoordinate(value string) *float64 {
+	if value == "" {
+		return nil
+	}
+	coordinate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil
+	}
+	return &coordinate
+}
+
+func formatCoordinate(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"jarvist/internal/common/bandwidth"
 	"jarvist/internal/common/config"
+	"jarvist/internal/wails/services/setting"
 	"jarvist/pkg/logger"
 	"net/http"
 
@@ -25,16 +27,18 @@ type SiteCategory struct {
 }
 
 type SiteService struct {
-	db     *gorm.DB
-	config *config.Config
-	logger *logger.ContextLogger
+	db             *gorm.DB
+	config         *config.Config
+	logger         *logger.ContextLogger
+	settingService *setting.SettingsService
 }
 
-func New(db *gorm.DB, cfg *config.Config, logger *logger.ContextLogger) *SiteService {
+func New(db *gorm.DB, cfg *config.Config, logger *logger.ContextLogger, settingService *setting.SettingsService) *SiteService {
 	return &SiteService{
-		db:     db,
-		config: cfg,
-		logger: logger,
+		db:             db,
+		config:         cfg,
+		logger:         logger,
+		settingService: settingService,
 	}
 }
 
@@ -56,7 +60,7 @@ func (s *SiteService) ValidateSiteCode(placeCode string) (interface{}, error) {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Add("X-API-Key", s.config.ApiKey)
 
-	client := &http.Client{}
+	client := &http.Client{Transport: bandwidth.NewTransport(bandwidth.DestinationAPI)}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send HTTP request: %w", err)
@@ -112,7 +116,8 @@ func (s *SiteService) GetSiteCetegories() ([]SiteCategory, error) {
 	req.Header.Add("content-type", "application/json")
 	req.Header.Add("X-API-Key", s.config.ApiKey)
 
-	res, err := http.DefaultClient.Do(req)
+	client := &http.Client{Transport: bandwidth.NewTransport(bandwidth.DestinationAPI)}
+	res, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("error sending request: %v", err)
 	}
@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"jarvist/internal/common/bandwidth"
 	"jarvist/internal/common/config"
+	"jarvist/internal/common/identity"
 	"jarvist/pkg/hardware"
 	"net/http"
 	"os"
@@ -16,6 +18,7 @@ import (
 	"time"
 
 	"github.com/wailsapp/wails/v3/pkg/application"
+	"gorm.io/gorm"
 )
 
 // StatsService untuk mengirim statistik
@@ -31,10 +34,11 @@ type StatsService struct {
 	mu           sync.Mutex // Mutex untuk perlindungan isRunning
 	client       *http.Client
 	sessionID    uint
+	db           *gorm.DB
 }
 
 // NewStatsService membuat service statistik baru
-func New(cfg *config.Config) *StatsService {
+func New(db *gorm.DB, cfg *config.Config) *StatsService {
 	currentHardwareID, _ := hardware.GetHardwareID()
 
 	return &StatsService{
@@ -44,9 +48,11 @@ func New(cfg *config.Config) *StatsService {
 		isRunning:    false,
 		startupTime:  time.Now(),
 		client: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout:   10 * time.Second,
+			Transport: bandwidth.NewTransport(bandwidth.DestinationAPI),
 		},
 		cfg: cfg,
+		db:  db,
 	}
 }
 
@@ -212,6 +218,11 @@ func (s *StatsService) sendHeartbeat() {
 			"uptime":      time.Since(s.startupTime).Seconds(),
 		},
 	}
+	if s.db != nil {
+		if tags := identity.GetTags(s.db); len(tags) > 0 {
+			heartbeat["tags"] = tags
+		}
+	}
 
 	s.sendRequestAsync("stats/heartbeat", heartbeat)
 }
@@ -8,6 +8,7 @@ import (
 	"image/jpeg"
 	_ "image/png"
 	"io"
+	"jarvist/internal/wails/services/usage"
 	"log"
 	"net/http"
 	"os"
@@ -299,6 +300,8 @@ func (s *StreamService) frameGenerator() {
 }
 
 func (s *StreamService) StartStream() (string, error) {
+	usage.Feature(usage.StreamView)
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
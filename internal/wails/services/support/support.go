@@ -0,0 +1,300 @@
+package support
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"jarvist/internal/common/bandwidth"
+	"jarvist/internal/common/config"
+	"jarvist/internal/common/redact"
+	"jarvist/internal/wails/services/device"
+	"jarvist/internal/wails/services/usage"
+	"jarvist/pkg/logger"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	maxLogFilesPerDir = 5
+	maxLogFileBytes   = 1 << 20 // 1MB terakhir dari setiap file log
+)
+
+// TicketInput is the data entered by the user in the support form
+type TicketInput struct {
+	Subject           string `json:"subject"`
+	Description       string `json:"description"`
+	ContactEmail      string `json:"contact_email"`
+	AttachDiagnostics bool   `json:"attach_diagnostics"`
+}
+
+// TicketResult is returned to the UI after the ticket is filed
+type TicketResult struct {
+	TicketID string `json:"ticket_id"`
+	Message  string `json:"message"`
+}
+
+type SupportService struct {
+	cfg    *config.Config
+	logger *logger.ContextLogger
+	client *http.Client
+}
+
+func New(cfg *config.Config, logger *logger.ContextLogger) *SupportService {
+	return &SupportService{
+		cfg:    cfg,
+		logger: logger.WithComponent("support"),
+		client: &http.Client{
+			Timeout:   60 * time.Second,
+			Transport: bandwidth.NewTransport(bandwidth.DestinationAPI),
+		},
+	}
+}
+
+// CreateTicket files a support ticket via the backend API and returns the ticket ID
+func (s *SupportService) CreateTicket(input TicketInput) (*TicketResult, error) {
+	if strings.TrimSpace(input.Description) == "" {
+		return nil, fmt.Errorf("description is required")
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	fields := map[string]string{
+		"subject":       input.Subject,
+		"description":   input.Description,
+		"contact_email": input.ContactEmail,
+		"tenant_id":     s.cfg.TenantId,
+		"client_id":     s.cfg.ClientId,
+		"app_version":   s.cfg.AppVersion,
+	}
+	for key, value := range fields {
+		if err := writer.WriteField(key, value); err != nil {
+			return nil, fmt.Errorf("failed to write form field %s: %w", key, err)
+		}
+	}
+
+	if input.AttachDiagnostics {
+		bundlePath, err := s.BuildDiagnosticsBundle()
+		if err != nil {
+			s.logger.Warn("Failed to build diagnostics bundle, sending ticket without it: %v", err)
+		} else {
+			defer os.Remove(bundlePath)
+			if err := attachFile(writer, "diagnostics", bundlePath); err != nil {
+				return nil, fmt.Errorf("failed to attach diagnostics bundle: %w", err)
+			}
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize request body: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/v1/app/support/tickets", s.cfg.ApiUrl), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Add("X-API-Key", s.cfg.ApiKey)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send ticket request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var apiResp struct {
+		Success bool   `json:"success"`
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Data    struct {
+			TicketID string `json:"ticket_id"`
+		} `json:"data"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if !apiResp.Success {
+		return nil, fmt.Errorf("API returned error: %s (code: %d)", apiResp.Message, apiResp.Code)
+	}
+
+	s.logger.Info("Support ticket created: %s", apiResp.Data.TicketID)
+
+	return &TicketResult{
+		TicketID: apiResp.Data.TicketID,
+		Message:  apiResp.Message,
+	}, nil
+}
+
+// BuildDiagnosticsBundle creates a zip with build info, system info and recent logs, the
+// logs are redacted before they are added
+func (s *SupportService) BuildDiagnosticsBundle() (string, error) {
+	usage.Feature(usage.DiagnosticsBuild)
+
+	bundleDir := filepath.Join(s.cfg.TempDir, "diagnostics")
+	if err := os.MkdirAll(bundleDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create diagnostics directory: %w", err)
+	}
+
+	bundlePath := filepath.Join(bundleDir, fmt.Sprintf("diagnostics_%s.zip", time.Now().Format("20060102_150405")))
+	file, err := os.Create(bundlePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create diagnostics bundle: %w", err)
+	}
+	defer file.Close()
+
+	zipWriter := zip.NewWriter(file)
+
+	if err := writeJSONEntry(zipWriter, "system.json", s.systemInfo()); err != nil {
+		zipWriter.Close()
+		return "", err
+	}
+
+	logDirs := []string{
+		s.cfg.LogDir,
+		filepath.Join(s.cfg.BinDir, "services", "logs"),
+	}
+	for i, dir := range logDirs {
+		for _, logPath := range recentLogFiles(dir, maxLogFilesPerDir) {
+			entryName := fmt.Sprintf("logs/%d/%s", i, filepath.Base(logPath))
+			if err := addFileTail(zipWriter, entryName, logPath, maxLogFileBytes); err != nil {
+				s.logger.Warn("Failed to add log file %s to bundle: %v", logPath, err)
+			}
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return "", fmt.Errorf("failed to finalize diagnostics bundle: %w", err)
+	}
+
+	s.logger.Info("Diagnostics bundle created at %s", bundlePath)
+	return bundlePath, nil
+}
+
+// systemInfo returns non-sensitive environment details; API keys are never included
+func (s *SupportService) systemInfo() map[string]interface{} {
+	deviceInfo := device.New().GetDeviceInfo()
+
+	return map[string]interface{}{
+		"app_name":     s.cfg.AppName,
+		"app_version":  s.cfg.AppVersion,
+		"environment":  s.cfg.Environment,
+		"build_info":   s.cfg.BuildInfo,
+		"os":           runtime.GOOS,
+		"arch":         runtime.GOARCH,
+		"hostname":     deviceInfo.Name,
+		"hardware":     deviceInfo.Summary(),
+		"num_cpu":      runtime.NumCPU(),
+		"go_version":   runtime.Version(),
+		"tenant_id":    s.cfg.TenantId,
+		"generated_at": time.Now().Format(time.RFC3339),
+	}
+}
+
+func writeJSONEntry(zipWriter *zip.Writer, name string, data interface{}) error {
+	entry, err := zipWriter.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create %s in bundle: %w", name, err)
+	}
+
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		return fmt.Errorf("failed to write %s to bundle: %w", name, err)
+	}
+	return nil
+}
+
+func addFileTail(zipWriter *zip.Writer, name, path string, maxBytes int64) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() > maxBytes {
+		if _, err := file.Seek(info.Size()-maxBytes, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	entry, err := zipWriter.Create(name)
+	if err != nil {
+		return err
+	}
+
+	// Log bisa memuat URL kamera dan kredensial, setiap baris disamarkan sebelum dibundel
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), int(maxBytes))
+	for scanner.Scan() {
+		if _, err := io.WriteString(entry, redactLogLine(scanner.Text())+"\n"); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// redactLogLine masks a log line. JSON lines are masked field by field, so the key rules
+// cover the structured fields and params of an entry as well.
+func redactLogLine(line string) string {
+	if strings.HasPrefix(strings.TrimSpace(line), "{") && json.Valid([]byte(line)) {
+		return string(redact.JSON([]byte(line)))
+	}
+	return redact.String(line)
+}
+
+func recentLogFiles(dir string, limit int) []string {
+	var files []string
+	for _, pattern := range []string{"*.log", "*.logs"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err == nil {
+			files = append(files, matches...)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		infoI, err1 := os.Stat(files[i])
+		infoJ, err2 := os.Stat(files[j])
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		return infoI.ModTime().After(infoJ.ModTime())
+	})
+
+	if len(files) > limit {
+		files = files[:limit]
+	}
+	return files
+}
+
+func attachFile(writer *multipart.Writer, field, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	part, err := writer.CreateFormFile(field, filepath.Base(path))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(part, file)
+	return err
+}
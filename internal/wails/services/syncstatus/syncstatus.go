@@ -0,0 +1,219 @@
+package syncstatus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"jarvist/internal/common/config"
+	"jarvist/internal/wails/services/deps"
+	"jarvist/pkg/logger"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
+)
+
+const (
+	// EventName is emitted with the Status whenever the sync status changes
+	EventName = "sync:status"
+
+	pollInterval      = 2 * time.Second
+	heartbeatInterval = 30 * time.Second
+	requestTimeout    = 3 * time.Second
+	livePath          = "/api/sync/live"
+)
+
+// SyncStatus is the file sync part of the live status
+type SyncStatus struct {
+	PendingFiles  int    `json:"pending_files"`
+	InSyncProcess bool   `json:"in_sync_process"`
+	WatcherActive bool   `json:"watcher_active"`
+	LastFolder    string `json:"last_folder,omitempty"`
+	LastFile      string `json:"last_file,omitempty"`
+	LastSyncedAt  string `json:"last_synced_at,omitempty"`
+}
+
+// MQTTStatus is the sender part of the live status
+type MQTTStatus struct {
+	Connected       bool   `json:"connected"`
+	Transport       string `json:"transport"`
+	UploadPaused    bool   `json:"upload_paused"`
+	PendingMessages int64  `json:"pending_messages"`
+	DeadLetters     int64  `json:"dead_letters"`
+}
+
+// Status is the live sync status pushed to the frontend. Reachable is false while the sync
+// service does not answer, Sync and MQTT then keep the last known values.
+type Status struct {
+	Reachable bool       `json:"reachable"`
+	Error     string     `json:"error,omitempty"`
+	Sync      SyncStatus `json:"sync"`
+	MQTT      MQTTStatus `json:"mqtt"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// same reports whether two statuses show the same thing, ignoring when they were read
+func (s Status) same(other Status) bool {
+	return s.Reachable == other.Reachable && s.Error == other.Error && s.Sync == other.Sync && s.MQTT == other.MQTT
+}
+
+// SyncStatusService polls the live status of the sync service and pushes it to the frontend
+// as EventName events, so the UI does not have to poll. An event is emitted when the status
+// changes and at least every heartbeatInterval.
+type SyncStatusService struct {
+	cfg    *config.Config
+	logger *logger.ContextLogger
+	events deps.Emitter
+	http   deps.HTTPClient
+
+	mu          sync.Mutex
+	status      Status
+	lastEmitted time.Time
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+func New(cfg *config.Config, logger *logger.ContextLogger, opts ...deps.Option) *SyncStatusService {
+	d := deps.Apply(opts)
+	if d.HTTP == nil {
+		// API sync service berjalan lokal, tidak dihitung sebagai pemakaian bandwidth
+		d.HTTP = &http.Client{}
+	}
+	return &SyncStatusService{
+		cfg:    cfg,
+		logger: logger,
+		events: d.Events,
+		http:   d.HTTP,
+	}
+}
+
+func (s *SyncStatusService) InitService(app *application.App) {
+	if s.events == nil {
+		s.events = deps.AppEmitter{App: app}
+	}
+}
+
+func (s *SyncStatusService) OnStartup(ctx context.Context, options application.ServiceOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		return nil
+	}
+	pollCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.run(pollCtx, s.done)
+	return nil
+}
+
+func (s *SyncStatusService) OnShutdown() error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel, s.done = nil, nil
+	s.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	return nil
+}
+
+// GetSyncStatus returns the last status read from the sync service
+func (s *SyncStatusService) GetSyncStatus() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Refresh reads the status now and emits it when it changed
+func (s *SyncStatusService) Refresh() Status {
+	return s.poll(context.Background())
+}
+
+func (s *SyncStatusService) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll reads the live status and emits it when it changed or the heartbeat is due
+func (s *SyncStatusService) poll(ctx context.Context) Status {
+	status, err := s.fetch(ctx)
+	if ctx.Err() != nil {
+		return s.GetSyncStatus()
+	}
+
+	s.mu.Lock()
+	if err != nil {
+		// Nilai terakhir tetap ditampilkan, hanya ditandai tidak terjangkau
+		status.Sync, status.MQTT = s.status.Sync, s.status.MQTT
+		status.Error = err.Error()
+	}
+	status.UpdatedAt = time.Now()
+	wasReachable := s.status.Reachable || s.status.UpdatedAt.IsZero()
+	emit := !status.same(s.status) || time.Since(s.lastEmitted) >= heartbeatInterval
+	s.status = status
+	if emit {
+		s.lastEmitted = status.UpdatedAt
+	}
+	s.mu.Unlock()
+
+	if err != nil && wasReachable {
+		s.logger.Warning("Sync service status not available: %v", err)
+	}
+	if emit {
+		s.emit(status)
+	}
+	return status
+}
+
+func (s *SyncStatusService) fetch(ctx context.Context) (Status, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	url := strings.TrimSuffix(s.cfg.SyncApiURL(), "/") + livePath
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Status{}, err
+	}
+	if s.cfg.SyncApiUsername != "" {
+		req.SetBasicAuth(s.cfg.SyncApiUsername, s.cfg.SyncApiPassword)
+	}
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return Status{}, fmt.Errorf("sync service not reachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Status{}, fmt.Errorf("sync service returned status %d", resp.StatusCode)
+	}
+
+	status := Status{Reachable: true}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return Status{}, fmt.Errorf("invalid sync status: %w", err)
+	}
+	return status, nil
+}
+
+// emit sends a frontend event, events before InitService are dropped
+func (s *SyncStatusService) emit(status Status) {
+	if s.events != nil {
+		s.events.Emit(EventName, status)
+	}
+}
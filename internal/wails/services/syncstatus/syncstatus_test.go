@@ -0,0 +1,65 @@
+package syncstatus
+
+import (
+	"errors"
+	"jarvist/internal/common/config"
+	"jarvist/internal/wails/services/deps"
+	"jarvist/internal/wails/services/mocks"
+	"jarvist/pkg/logger"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPollEmitsOnlyChanges(t *testing.T) {
+	var pending atomic.Int32
+	client := mocks.NewHTTPClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != livePath {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"sync":{"pending_files":` + strconv.Itoa(int(pending.Load())) +
+			`,"last_folder":"20250101"},"mqtt":{"connected":true,"transport":"mqtt","pending_messages":4}}`))
+	})
+	events := &mocks.Emitter{}
+	cfg := &config.Config{SyncApi: "http://sync.test", SyncApiUsername: "admin", SyncApiPassword: "secret"}
+	s := New(cfg, logger.NewLogger().WithComponent("syncstatus"), deps.WithHTTPClient(client), deps.WithEmitter(events))
+
+	status := s.Refresh()
+	if !status.Reachable || status.Sync.LastFolder != "20250101" || !status.MQTT.Connected || status.MQTT.PendingMessages != 4 {
+		t.Fatalf("status = %+v", status)
+	}
+	if user, _, ok := requestAuth(client); !ok || user != "admin" {
+		t.Fatal("request sent without the sync API credentials")
+	}
+
+	s.Refresh()
+	if n := len(events.Named(EventName)); n != 1 {
+		t.Fatalf("%d events for an unchanged status, want 1", n)
+	}
+
+	pending.Store(3)
+	if s.Refresh().Sync.PendingFiles != 3 || len(events.Named(EventName)) != 2 {
+		t.Fatal("changed status not emitted")
+	}
+
+	client.Err = errors.New("connection refused")
+	status = s.Refresh()
+	if status.Reachable || status.Error == "" || status.Sync.PendingFiles != 3 {
+		t.Fatalf("unreachable status = %+v, want the last values kept", status)
+	}
+	if len(events.Named(EventName)) != 3 {
+		t.Fatal("lost connection not emitted")
+	}
+}
+
+func requestAuth(client *mocks.HTTPClient) (string, string, bool) {
+	requests := client.Requests()
+	if len(requests) == 0 {
+		return "", "", false
+	}
+	r := &http.Request{Header: requests[0].Header}
+	return r.BasicAuth()
+}
@@ -0,0 +1,357 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"jarvist/internal/common/bandwidth"
+	"jarvist/internal/common/config"
+	"jarvist/internal/common/models"
+	"jarvist/internal/common/residency"
+	"jarvist/internal/wails/services/device"
+	"jarvist/internal/wails/services/setting"
+	"jarvist/internal/wails/services/usage"
+	"jarvist/pkg/hardware"
+	"jarvist/pkg/logger"
+	"net/http"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// SettingKey menyimpan persetujuan user untuk telemetry
+	SettingKey = "telemetry_enabled"
+	// reportedAtKey menyimpan waktu laporan terakhir, batas hitungan unclean shutdown
+	reportedAtKey = "telemetry_reported_at"
+
+	reportInterval = 24 * time.Hour
+)
+
+// allowedFields adalah satu-satunya field yang boleh dikirim ke backend
+var allowedFields = map[string]bool{
+	"install_id":      true,
+	"version":         true,
+	"os":              true,
+	"arch":            true,
+	"camera_bucket":   true,
+	"feature_usage":   true,
+	"crash_count":     true,
+	"uptime_bucket":   true,
+	"report_interval": true,
+	"hardware":        true,
+}
+
+// allowedFeatures membatasi nama fitur yang boleh dihitung
+var allowedFeatures = map[string]bool{
+	usage.CameraCreate:     true,
+	usage.CameraUpdate:     true,
+	usage.CameraDelete:     true,
+	usage.CameraCheck:      true,
+	usage.StreamView:       true,
+	usage.LogView:          true,
+	usage.UpdateCheck:      true,
+	usage.ServiceControl:   true,
+	usage.SettingsChange:   true,
+	usage.DiagnosticsBuild: true,
+}
+
+type TelemetryService struct {
+	db             *gorm.DB
+	cfg            *config.Config
+	logger         *logger.ContextLogger
+	settingService *setting.SettingsService
+	client         *http.Client
+	installID      string
+	startTime      time.Time
+
+	mu           sync.Mutex
+	featureUsage map[string]int
+	crashCount   int
+	stopChan     chan struct{}
+}
+
+func New(db *gorm.DB, cfg *config.Config, logger *logger.ContextLogger, settingService *setting.SettingsService) *TelemetryService {
+	return &TelemetryService{
+		db:             db,
+		cfg:            cfg,
+		logger:         logger.WithComponent("telemetry"),
+		settingService: settingService,
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: bandwidth.NewTransport(bandwidth.DestinationAPI),
+		},
+		startTime:    time.Now(),
+		featureUsage: make(map[string]int),
+	}
+}
+
+func (s *TelemetryService) OnStartup(ctx context.Context, options application.ServiceOptions) error {
+	if s.IsEnabled() {
+		s.start()
+	}
+	return nil
+}
+
+func (s *TelemetryService) OnShutdown() error {
+	s.stop()
+	return nil
+}
+
+// IsEnabled returns true if the user has opted in to telemetry
+func (s *TelemetryService) IsEnabled() bool {
+	enabled, err := strconv.ParseBool(s.settingService.GetSettingWithDefault(SettingKey, "false"))
+	return err == nil && enabled
+}
+
+// SetEnabled stores the consent flag and starts or stops reporting
+func (s *TelemetryService) SetEnabled(enabled bool) error {
+	if err := s.settingService.SaveSetting(SettingKey, strconv.FormatBool(enabled)); err != nil {
+		return fmt.Errorf("failed to save telemetry consent: %w", err)
+	}
+
+	if enabled {
+		// Unclean shutdown sebelum persetujuan tidak ikut dihitung
+		s.saveReportedAt(time.Now())
+		s.start()
+	} else {
+		s.stop()
+		s.reset()
+	}
+
+	s.logger.Info("Telemetry enabled: %v", enabled)
+	return nil
+}
+
+// RecordFeatureUsage increments the usage counter of an allowlisted feature
+func (s *TelemetryService) RecordFeatureUsage(feature string) {
+	if !allowedFeatures[feature] {
+		return
+	}
+
+	s.mu.Lock()
+	s.featureUsage[feature]++
+	s.mu.Unlock()
+}
+
+// RecordCrash increments the crash counter, called for panics recovered in guarded calls
+func (s *TelemetryService) RecordCrash() {
+	s.mu.Lock()
+	s.crashCount++
+	s.mu.Unlock()
+}
+
+// PreviewReport returns exactly the data that would be sent on the next report
+func (s *TelemetryService) PreviewReport() map[string]interface{} {
+	return s.buildReport(time.Now())
+}
+
+// SendNow sends a report immediately if telemetry is enabled
+func (s *TelemetryService) SendNow() error {
+	if !s.IsEnabled() {
+		return fmt.Errorf("telemetry is disabled")
+	}
+
+	reportedAt := time.Now()
+	report := s.buildReport(reportedAt)
+
+	jsonData, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal telemetry report: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/v1/app/telemetry", s.cfg.ApiUrl), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create telemetry request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Add("X-API-Key", s.cfg.ApiKey)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send telemetry report: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("telemetry report failed with status: %d", resp.StatusCode)
+	}
+
+	s.reset()
+	s.saveReportedAt(reportedAt)
+	return nil
+}
+
+func (s *TelemetryService) start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopChan != nil {
+		return
+	}
+	s.stopChan = make(chan struct{})
+	stopChan := s.stopChan
+
+	go func() {
+		ticker := time.NewTicker(reportInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if residency.LocalOnly() {
+					s.logger.Debug("Skipping telemetry report, local-only mode is on")
+					continue
+				}
+				if state := bandwidth.GetPauseState(s.db); state.Paused {
+					s.logger.Info("Skipping telemetry report, uploads paused (%s)", state.Reason)
+					continue
+				}
+				if err := s.SendNow(); err != nil {
+					s.logger.Warn("Failed to send telemetry: %v", err)
+				}
+			case <-stopChan:
+				return
+			}
+		}
+	}()
+}
+
+func (s *TelemetryService) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopChan != nil {
+		close(s.stopChan)
+		s.stopChan = nil
+	}
+}
+
+func (s *TelemetryService) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.featureUsage = make(map[string]int)
+	s.crashCount = 0
+}
+
+func (s *TelemetryService) buildReport(now time.Time) map[string]interface{} {
+	var cameraCount int64
+	s.db.Model(&models.Camera{}).Where("deleted_at IS NULL").Count(&cameraCount)
+
+	s.mu.Lock()
+	usage := make(map[string]int, len(s.featureUsage))
+	for k, v := range s.featureUsage {
+		usage[k] = v
+	}
+	crashes := s.crashCount
+	s.mu.Unlock()
+	crashes += s.uncleanShutdowns(now)
+
+	report := map[string]interface{}{
+		"install_id":      s.getInstallID(),
+		"version":         s.cfg.AppVersion,
+		"os":              runtime.GOOS,
+		"arch":            runtime.GOARCH,
+		"camera_bucket":   cameraBucket(cameraCount),
+		"feature_usage":   usage,
+		"crash_count":     crashes,
+		"uptime_bucket":   uptimeBucket(time.Since(s.startTime)),
+		"report_interval": reportInterval.String(),
+		"hardware":        device.New().GetHardwareSummary(),
+	}
+
+	return filterAllowed(report)
+}
+
+// uncleanShutdowns counts the unclean shutdowns the sync service detected since the last
+// report, from the recovery reports it writes on start
+func (s *TelemetryService) uncleanShutdowns(until time.Time) int {
+	since := s.startTime
+	var setting models.Setting
+	if err := s.db.Where("key = ?", reportedAtKey).First(&setting).Error; err == nil {
+		if reportedAt, err := time.Parse(time.RFC3339Nano, setting.Value); err == nil {
+			since = reportedAt
+		}
+	}
+
+	var count int64
+	s.db.Model(&models.RecoveryReport{}).Where("detected_at > ? AND detected_at <= ?", since, until).Count(&count)
+	return int(count)
+}
+
+// saveReportedAt stores the report time directly, the settings service refuses writes
+// while the app is locked and reports are sent in the background
+func (s *TelemetryService) saveReportedAt(reportedAt time.Time) {
+	value := reportedAt.Format(time.RFC3339Nano)
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"value": value}),
+	}).Create(&models.Setting{Key: reportedAtKey, Value: value}).Error
+	if err != nil {
+		s.logger.Warn("Failed to save telemetry report time: %v", err)
+	}
+}
+
+// getInstallID returns a one-way hash of the hardware ID so the device cannot be identified
+func (s *TelemetryService) getInstallID() string {
+	if s.installID != "" {
+		return s.installID
+	}
+
+	hardwareID, err := hardware.GetHardwareID()
+	if err != nil {
+		return "unknown"
+	}
+
+	hash := sha256.Sum256([]byte("jarvist-telemetry:" + hardwareID))
+	s.installID = hex.EncodeToString(hash[:])[:16]
+	return s.installID
+}
+
+func filterAllowed(report map[string]interface{}) map[string]interface{} {
+	filtered := make(map[string]interface{}, len(report))
+	for key, value := range report {
+		if allowedFields[key] {
+			filtered[key] = value
+		}
+	}
+	return filtered
+}
+
+func cameraBucket(count int64) string {
+	switch {
+	case count == 0:
+		return "0"
+	case count <= 2:
+		return "1-2"
+	case count <= 5:
+		return "3-5"
+	case count <= 10:
+		return "6-10"
+	default:
+		return "10+"
+	}
+}
+
+func uptimeBucket(uptime time.Duration) string {
+	switch {
+	case uptime < time.Hour:
+		return "<1h"
+	case uptime < 24*time.Hour:
+		return "1h-24h"
+	case uptime < 7*24*time.Hour:
+		return "1d-7d"
+	default:
+		return "7d+"
+	}
+}
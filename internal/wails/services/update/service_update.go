@@ -0,0 +1,406 @@
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	serviceStopTimeout   = 30 * time.Second
+	serviceHealthTimeout = 60 * time.Second
+)
+
+// Steps of a sync service update, reported in service_update_progress events
+const (
+	ServiceStepDownload = "download"
+	ServiceStepVerify   = "verify"
+	ServiceStepStop     = "stop"
+	ServiceStepSwap     = "swap"
+	ServiceStepStart    = "start"
+	ServiceStepHealth   = "health_check"
+	ServiceStepRollback = "rollback"
+	ServiceStepDone     = "done"
+)
+
+// serviceStepProgress is the overall progress when a step starts, the download fills
+// the range up to the verify step
+var serviceStepProgress = map[string]int{
+	ServiceStepDownload: 0,
+	ServiceStepVerify:   60,
+	ServiceStepStop:     65,
+	ServiceStepSwap:     75,
+	ServiceStepStart:    80,
+	ServiceStepHealth:   85,
+	ServiceStepRollback: 90,
+	ServiceStepDone:     100,
+}
+
+// ServiceUpdateProgress is the state of a running sync service update
+type ServiceUpdateProgress struct {
+	Version    string    `json:"version"`
+	Step       string    `json:"step"`
+	Progress   int       `json:"progress"`
+	Downloaded int64     `json:"downloaded"`
+	Total      int64     `json:"total"`
+	StartedAt  time.Time `json:"startedAt"`
+}
+
+// ServiceController is the subset of the service manager used by the service update flow
+type ServiceController interface {
+	StopService() (string, error)
+	StartService() (string, error)
+	IsServiceRunning() (bool, error)
+	GetServiceBinaryPath() string
+}
+
+// SetServiceController sets the controller used to stop/start the sync service during updates
+func (s *UpdateService) SetServiceController(controller ServiceController) {
+	s.serviceController = controller
+}
+
+// SetUpdatePublicKey sets the base64 ed25519 public key used to verify service binaries
+func (s *UpdateService) SetUpdatePublicKey(publicKey string) {
+	s.updatePublicKey = publicKey
+}
+
+// GetServiceUpdateProgress returns the progress of the running sync service update, nil
+// when no update is running
+func (s *UpdateService) GetServiceUpdateProgress() *ServiceUpdateProgress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.serviceProgress == nil {
+		return nil
+	}
+	progress := *s.serviceProgress
+	return &progress
+}
+
+// serviceStep records the step of the running service update and emits it to the frontend
+func (s *UpdateService) serviceStep(step, message string) {
+	s.mu.Lock()
+	if s.serviceProgress == nil {
+		s.mu.Unlock()
+		return
+	}
+	s.serviceProgress.Step = step
+	s.serviceProgress.Progress = serviceStepProgress[step]
+	progress := *s.serviceProgress
+	s.mu.Unlock()
+
+	s.emitEvent("service_update_progress", message, true, progress)
+}
+
+// serviceDownloadProgress emits the download progress when the percentage changed
+func (s *UpdateService) serviceDownloadProgress(downloaded, total int64) {
+	s.mu.Lock()
+	if s.serviceProgress == nil {
+		s.mu.Unlock()
+		return
+	}
+	previous := s.serviceProgress.Progress
+	s.serviceProgress.Downloaded = downloaded
+	s.serviceProgress.Total = total
+	if total > 0 {
+		s.serviceProgress.Progress = int(downloaded * int64(serviceStepProgress[ServiceStepVerify]) / total)
+	}
+	progress := *s.serviceProgress
+	s.mu.Unlock()
+
+	if progress.Progress != previous {
+		s.emitEvent("service_update_progress", fmt.Sprintf("Downloading sync service: %d%%", progress.Progress*100/serviceStepProgress[ServiceStepVerify]), true, progress)
+	}
+}
+
+// InstallServiceUpdate downloads, verifies and swaps the syncmanager binary,
+// rolling back to the previous binary when the new one fails its health check. The
+// frontend can cancel the call until the service is stopped.
+func (s *UpdateService) InstallServiceUpdate(ctx context.Context, updateInfo *UpdateInfo) error {
+	if updateInfo == nil {
+		return fmt.Errorf("no update info provided")
+	}
+	if s.serviceController == nil {
+		return fmt.Errorf("service controller not configured")
+	}
+
+	s.mu.Lock()
+	if s.isInstalling {
+		s.mu.Unlock()
+		return fmt.Errorf("already installing update")
+	}
+	s.isInstalling = true
+	s.serviceProgress = &ServiceUpdateProgress{Version: updateInfo.Version, StartedAt: time.Now()}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.isInstalling = false
+		s.serviceProgress = nil
+		s.mu.Unlock()
+	}()
+
+	binaryPath := s.serviceController.GetServiceBinaryPath()
+	newPath := binaryPath + ".new"
+	backupPath := binaryPath + ".bak"
+
+	s.emitEvent("service_update_download_start", "Downloading sync service update...", true, nil)
+	s.serviceStep(ServiceStepDownload, "Downloading sync service update...")
+
+	// Unduh langsung ke direktori yang sama agar rename bersifat atomik
+	digest, err := s.downloadToFile(ctx, updateInfo.DownloadURL, newPath, s.serviceDownloadProgress)
+	if err != nil {
+		os.Remove(newPath)
+		if ctx.Err() != nil {
+			s.emitEvent("service_update_cancelled", "Sync service update cancelled", false, nil)
+			return ctx.Err()
+		}
+		s.emitEvent("service_update_error", "Download failed: "+err.Error(), false, nil)
+		return fmt.Errorf("failed to download service update: %w", err)
+	}
+
+	s.serviceStep(ServiceStepVerify, "Verifying sync service update...")
+	if err := s.verifyServiceBinary(updateInfo, digest); err != nil {
+		os.Remove(newPath)
+		s.emitEvent("service_update_error", "Verification failed: "+err.Error(), false, nil)
+		return err
+	}
+
+	// Setelah service dihentikan update tidak bisa dibatalkan lagi
+	if err := ctx.Err(); err != nil {
+		os.Remove(newPath)
+		s.emitEvent("service_update_cancelled", "Sync service update cancelled", false, nil)
+		return err
+	}
+
+	s.emitEvent("service_update_install_start", "Stopping sync service...", true, nil)
+	s.serviceStep(ServiceStepStop, "Stopping sync service...")
+	if err := s.stopServiceAndWait(); err != nil {
+		os.Remove(newPath)
+		s.emitEvent("service_update_error", "Failed to stop service: "+err.Error(), false, nil)
+		return err
+	}
+
+	s.serviceStep(ServiceStepSwap, "Replacing sync service executable...")
+	os.Remove(backupPath)
+	if err := os.Rename(binaryPath, backupPath); err != nil {
+		os.Remove(newPath)
+		s.serviceController.StartService()
+		s.emitEvent("service_update_error", "Failed to back up service binary: "+err.Error(), false, nil)
+		return fmt.Errorf("failed to back up service binary: %w", err)
+	}
+
+	if err := os.Rename(newPath, binaryPath); err != nil {
+		os.Rename(backupPath, binaryPath)
+		s.serviceController.StartService()
+		s.emitEvent("service_update_error", "Failed to swap service binary: "+err.Error(), false, nil)
+		return fmt.Errorf("failed to swap service binary: %w", err)
+	}
+
+	s.serviceStep(ServiceStepStart, "Starting sync service...")
+	if _, err := s.serviceController.StartService(); err == nil {
+		s.serviceStep(ServiceStepHealth, "Waiting for sync service to become healthy...")
+		err = s.waitForServiceHealthy()
+		if err == nil {
+			os.Remove(backupPath)
+			s.serviceStep(ServiceStepDone, "Sync service updated")
+			s.emitEvent("service_update_complete", fmt.Sprintf("Sync service updated to %s", updateInfo.Version), true, updateInfo.Version)
+			return nil
+		}
+	}
+
+	// Health check gagal, kembalikan binary sebelumnya
+	s.serviceStep(ServiceStepRollback, "Update failed, restoring previous sync service...")
+	rollbackErr := s.rollbackServiceBinary(binaryPath, backupPath)
+	if rollbackErr != nil {
+		s.emitEvent("service_update_error", "Update failed and rollback failed: "+rollbackErr.Error(), false, nil)
+		return fmt.Errorf("service update failed health check and rollback failed: %w", rollbackErr)
+	}
+
+	s.emitEvent("service_update_rolled_back", "Update failed health check, previous version restored", false, nil)
+	return fmt.Errorf("service update to %s failed health check, rolled back", updateInfo.Version)
+}
+
+// RecoverServiceBinary finishes a service update interrupted by a crash or power loss. The
+// previous executable is restored when the swap left no executable in place, a leftover
+// download is removed. It returns true when the previous executable was restored.
+func (s *UpdateService) RecoverServiceBinary() (bool, error) {
+	if s.serviceController == nil {
+		return false, nil
+	}
+
+	s.mu.Lock()
+	installing := s.isInstalling
+	s.mu.Unlock()
+	if installing {
+		return false, nil
+	}
+
+	binaryPath := s.serviceController.GetServiceBinaryPath()
+	backupPath := binaryPath + ".bak"
+	os.Remove(binaryPath + ".new")
+
+	if fileExists(binaryPath) || !fileExists(backupPath) {
+		return false, nil
+	}
+	if err := os.Rename(backupPath, binaryPath); err != nil {
+		return false, fmt.Errorf("failed to restore service binary: %w", err)
+	}
+
+	s.emitEvent("service_update_rolled_back", "Interrupted sync service update, previous version restored", false, nil)
+	return true, nil
+}
+
+func (s *UpdateService) verifyServiceBinary(updateInfo *UpdateInfo, digest []byte) error {
+	if updateInfo.Checksum != "" && hex.EncodeToString(digest) != updateInfo.Checksum {
+		return fmt.Errorf("invalid checksum: expected %s, got %s", updateInfo.Checksum, hex.EncodeToString(digest))
+	}
+
+	if s.updatePublicKey == "" {
+		if s.cfg.IsDev() {
+			return nil
+		}
+		return errors.New("update signing key not configured")
+	}
+
+	publicKey, err := base64.StdEncoding.DecodeString(s.updatePublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return errors.New("invalid update signing key")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(updateInfo.Signature)
+	if err != nil || updateInfo.Signature == "" {
+		return errors.New("missing or malformed signature")
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(publicKey), digest, signature) {
+		return errors.New("signature verification failed")
+	}
+
+	return nil
+}
+
+func (s *UpdateService) stopServiceAndWait() error {
+	if _, err := s.serviceController.StopService(); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(serviceStopTimeout)
+	for time.Now().Before(deadline) {
+		running, err := s.serviceController.IsServiceRunning()
+		if err == nil && !running {
+			return nil
+		}
+		time.Sleep(1 * time.Second)
+	}
+
+	return fmt.Errorf("timed out waiting for service to stop")
+}
+
+func (s *UpdateService) waitForServiceHealthy() error {
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+	}
+
+	deadline := time.Now().Add(serviceHealthTimeout)
+	for time.Now().Before(deadline) {
+		resp, err := client.Get(s.cfg.SyncApiURL() + "/health")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+		time.Sleep(2 * time.Second)
+	}
+
+	return fmt.Errorf("service did not become healthy within %s", serviceHealthTimeout)
+}
+
+func (s *UpdateService) rollbackServiceBinary(binaryPath, backupPath string) error {
+	// Binary baru bisa gagal stop bila service tidak pernah jalan, cukup pastikan sudah berhenti
+	if err := s.stopServiceAndWait(); err != nil {
+		if running, runErr := s.serviceController.IsServiceRunning(); runErr != nil || running {
+			return err
+		}
+	}
+
+	if err := os.Remove(binaryPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove faulty binary: %w", err)
+	}
+
+	if err := os.Rename(backupPath, binaryPath); err != nil {
+		return fmt.Errorf("failed to restore backup binary: %w", err)
+	}
+
+	if _, err := s.serviceController.StartService(); err != nil {
+		return fmt.Errorf("failed to restart service after rollback: %w", err)
+	}
+
+	return nil
+}
+
+// downloadToFile writes url to path and returns the SHA-256 digest of the content. progress
+// is called with the bytes written and the content length, which is 0 when unknown.
+func (s *UpdateService) downloadToFile(ctx context.Context, url, path string, progress func(downloaded, total int64)) ([]byte, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP error: %d", resp.StatusCode)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	counter := &progressWriter{total: max(resp.ContentLength, 0), report: progress}
+	if _, err := io.Copy(io.MultiWriter(file, hash, counter), resp.Body); err != nil {
+		return nil, err
+	}
+
+	if err := file.Sync(); err != nil {
+		return nil, err
+	}
+
+	return hash.Sum(nil), nil
+}
+
+// progressWriter counts the bytes written and reports them
+type progressWriter struct {
+	written int64
+	total   int64
+	report  func(downloaded, total int64)
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.written += int64(len(p))
+	if w.report != nil {
+		w.report(w.written, w.total)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,225 @@
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jarvist/internal/wails/services/callguard"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Status values of the update lifecycle
+const (
+	StatusIdle        = "idle"
+	StatusChecking    = "checking"
+	StatusAvailable   = "available"
+	StatusDownloading = "downloading"
+	StatusPaused      = "paused"
+	StatusDownloaded  = "downloaded"
+	StatusInstalling  = "installing"
+	StatusFailed      = "failed"
+)
+
+// ErrDownloadPaused is returned by a download stopped with PauseDownload, ResumeDownload
+// continues it
+var ErrDownloadPaused = errors.New("download paused")
+
+// stateFileName is kept in the updates folder next to pending_update.json
+const stateFileName = "update_state.json"
+
+// UpdateState is the update lifecycle shown to the frontend. It is saved so skipped versions
+// and a paused download survive a restart.
+type UpdateState struct {
+	Status          string      `json:"status"`
+	Available       *UpdateInfo `json:"available,omitempty"`
+	DownloadPath    string      `json:"downloadPath,omitempty"`
+	Downloaded      int64       `json:"downloaded"`
+	Total           int64       `json:"total"`
+	Progress        int         `json:"progress"`
+	SkippedVersions []string    `json:"skippedVersions"`
+	LastError       string      `json:"lastError,omitempty"`
+	UpdatedAt       time.Time   `json:"updatedAt"`
+}
+
+// GetUpdateState returns the current update state
+func (s *UpdateService) GetUpdateState() UpdateState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.state
+	switch {
+	case s.isInstalling:
+		state.Status = StatusInstalling
+	case s.isDownloading:
+		state.Status = StatusDownloading
+	case s.isChecking:
+		state.Status = StatusChecking
+	}
+	if state.Available != nil {
+		available := *state.Available
+		state.Available = &available
+	}
+	state.SkippedVersions = slices.Clone(state.SkippedVersions)
+	return state
+}
+
+// PauseDownload stops the running download and keeps the partial file for ResumeDownload
+func (s *UpdateService) PauseDownload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isDownloading || s.downloadCancel == nil {
+		return fmt.Errorf("no download in progress")
+	}
+	s.pausing = true
+	s.downloadCancel()
+	return nil
+}
+
+// ResumeDownload continues a paused or failed download from the bytes already saved. A
+// server that does not support ranges sends the whole installer again.
+func (s *UpdateService) ResumeDownload(ctx context.Context) error {
+	s.mu.Lock()
+	status, available := s.state.Status, s.state.Available
+	s.mu.Unlock()
+
+	if available == nil {
+		return fmt.Errorf("no update to download")
+	}
+	if status != StatusPaused && status != StatusFailed {
+		return fmt.Errorf("cannot resume a download that is %s", status)
+	}
+
+	updateInfo := *available
+	return callguard.Do(s.calls, ctx, "ResumeDownload", downloadTimeout, func(ctx context.Context) error {
+		return s.downloadUpdate(ctx, &updateInfo, true)
+	})
+}
+
+// SkipVersion stops offering a version, a check finding it reports no update. Forced
+// updates cannot be skipped.
+func (s *UpdateService) SkipVersion(version string) error {
+	if version == "" {
+		return fmt.Errorf("no version provided")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	available := s.state.Available
+	if available != nil && available.Version == version {
+		if available.IsForced {
+			return fmt.Errorf("version %s is a forced update and cannot be skipped", version)
+		}
+		if s.isDownloading {
+			return fmt.Errorf("version %s is downloading, pause or cancel the download first", version)
+		}
+
+		// Unduhan versi ini tidak dipakai lagi
+		switch s.state.Status {
+		case StatusPaused, StatusFailed:
+			os.Remove(s.state.DownloadPath)
+		case StatusDownloaded:
+			os.Remove(s.state.DownloadPath)
+			os.Remove(filepath.Join(s.updatesDir(), "pending_update.json"))
+		}
+		s.resetDownloadLocked()
+		s.state.Available = nil
+		s.state.Status = StatusIdle
+	}
+
+	if !slices.Contains(s.state.SkippedVersions, version) {
+		s.state.SkippedVersions = append(s.state.SkippedVersions, version)
+	}
+	s.saveStateLocked()
+
+	s.emitEvent("update_version_skipped", fmt.Sprintf("Version %s will not be offered again", version), true, version)
+	return nil
+}
+
+// isSkipped reports whether the user skipped a version
+func (s *UpdateService) isSkipped(version string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Contains(s.state.SkippedVersions, version)
+}
+
+// updateState changes the state and saves it
+func (s *UpdateService) updateState(change func(state *UpdateState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	change(&s.state)
+	s.saveStateLocked()
+}
+
+// resetDownloadLocked forgets the download of the state, s.mu must be held
+func (s *UpdateService) resetDownloadLocked() {
+	s.state.DownloadPath = ""
+	s.state.Downloaded = 0
+	s.state.Total = 0
+	s.state.Progress = 0
+}
+
+// saveStateLocked writes the state to the updates folder, s.mu must be held
+func (s *UpdateService) saveStateLocked() {
+	s.state.UpdatedAt = time.Now()
+
+	data, err := json.Marshal(s.state)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(s.updatesDir(), 0755); err != nil {
+		return
+	}
+	os.WriteFile(filepath.Join(s.updatesDir(), stateFileName), data, 0644)
+}
+
+// loadState reads the state saved before the last restart. A download interrupted by the
+// restart becomes paused, an update that has been installed since is forgotten.
+func (s *UpdateService) loadState() {
+	s.state = UpdateState{Status: StatusIdle}
+
+	data, err := os.ReadFile(filepath.Join(s.updatesDir(), stateFileName))
+	if err != nil {
+		return
+	}
+	var state UpdateState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return
+	}
+
+	if state.Available == nil || state.Available.Version == s.currentVersion {
+		s.state.SkippedVersions = state.SkippedVersions
+		return
+	}
+
+	switch state.Status {
+	case StatusDownloading, StatusPaused:
+		state.Status = StatusPaused
+		info, err := os.Stat(state.DownloadPath)
+		if err != nil {
+			state.Status = StatusAvailable
+			state.DownloadPath = ""
+			state.Downloaded = 0
+			state.Progress = 0
+		} else {
+			state.Downloaded = info.Size()
+		}
+	case StatusDownloaded:
+		if !fileExists(state.DownloadPath) {
+			state.Status = StatusAvailable
+			state.DownloadPath = ""
+		}
+	case StatusChecking, StatusInstalling:
+		state.Status = StatusAvailable
+	}
+	s.state = state
+}
+
+func (s *UpdateService) updatesDir() string {
+	return filepath.Join(s.cfg.TempDir, "updates")
+}
@@ -0,0 +1,181 @@
+package update
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"jarvist/internal/wails/services/deps"
+	"jarvist/internal/wails/services/mocks"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stallingClient serves the installer and honours Range requests. A full request returns
+// the first half and then stalls until the request is cancelled.
+type stallingClient struct {
+	installer []byte
+
+	mu     sync.Mutex
+	ranges []string
+}
+
+func (c *stallingClient) Do(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.ranges = append(c.ranges, req.Header.Get("Range"))
+	c.mu.Unlock()
+
+	if value := req.Header.Get("Range"); value != "" {
+		offset, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(value, "bytes="), "-"))
+		rest := c.installer[offset:]
+		return &http.Response{
+			StatusCode:    http.StatusPartialContent,
+			ContentLength: int64(len(rest)),
+			Body:          io.NopCloser(bytes.NewReader(rest)),
+		}, nil
+	}
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		ContentLength: int64(len(c.installer)),
+		Body:          &stallingBody{data: c.installer[:len(c.installer)/2], ctx: req.Context()},
+	}, nil
+}
+
+func (c *stallingClient) lastRange() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ranges[len(c.ranges)-1]
+}
+
+type stallingBody struct {
+	data []byte
+	ctx  context.Context
+}
+
+func (b *stallingBody) Read(p []byte) (int, error) {
+	if len(b.data) > 0 {
+		n := copy(p, b.data)
+		b.data = b.data[n:]
+		return n, nil
+	}
+	<-b.ctx.Done()
+	return 0, b.ctx.Err()
+}
+
+func (b *stallingBody) Close() error { return nil }
+
+func withTestDeps(client deps.HTTPClient) []deps.Option {
+	return []deps.Option{deps.WithHTTPClient(client), deps.WithEmitter(&mocks.Emitter{}), deps.WithRunner(&mocks.ProcessRunner{})}
+}
+
+func TestPauseAndResumeDownload(t *testing.T) {
+	installer := []byte(strings.Repeat("installer content ", 4096))
+	client := &stallingClient{installer: installer}
+	s, events := newTestService(t, &mocks.HTTPClient{}, &mocks.ProcessRunner{})
+	s.http = client
+	info := &UpdateInfo{Version: "1.1.0", DownloadURL: "http://cdn.test/app"}
+
+	done := make(chan error, 1)
+	go func() { done <- s.downloadUpdate(context.Background(), info, false) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for s.GetUpdateState().Downloaded == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := s.PauseDownload(); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if err := <-done; !errors.Is(err, ErrDownloadPaused) {
+		t.Fatalf("download: got %v, want paused", err)
+	}
+
+	state := s.GetUpdateState()
+	if state.Status != StatusPaused || state.Downloaded == 0 || state.Available == nil {
+		t.Fatalf("state after pause = %+v", state)
+	}
+	if info, err := os.Stat(state.DownloadPath); err != nil || info.Size() == 0 {
+		t.Fatalf("partial file not kept: %v", err)
+	}
+
+	// The paused download survives a restart
+	restarted := New(s.cfg, withTestDeps(client)...)
+	if got := restarted.GetUpdateState(); got.Status != StatusPaused || got.Downloaded != state.Downloaded {
+		t.Fatalf("state after restart = %+v", got)
+	}
+
+	if err := s.ResumeDownload(context.Background()); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	content, err := os.ReadFile(state.DownloadPath)
+	if err != nil || string(content) != string(installer) {
+		t.Fatalf("resumed file has %d bytes, want %d", len(content), len(installer))
+	}
+
+	if client.lastRange() != fmt.Sprintf("bytes=%d-", state.Downloaded) {
+		t.Error("resume did not request the missing range")
+	}
+	if got := s.GetUpdateState(); got.Status != StatusDownloaded || got.Progress != 100 {
+		t.Errorf("state after resume = %+v", got)
+	}
+	got := updateEvents(events)
+	if !slices.Contains(got, "update_download_paused") || !slices.Contains(got, "update_download_complete") {
+		t.Errorf("events: got %v", got)
+	}
+}
+
+func TestSkipVersion(t *testing.T) {
+	client := mocks.NewHTTPClient(respondWith(UpdateInfo{Version: "1.1.0", DownloadURL: "http://cdn.test/app.exe"}))
+	s, events := newTestService(t, client, &mocks.ProcessRunner{})
+
+	if info, err := s.CheckForUpdates(); err != nil || info == nil {
+		t.Fatalf("check: got %+v, %v", info, err)
+	}
+	if state := s.GetUpdateState(); state.Status != StatusAvailable || state.Available.Version != "1.1.0" {
+		t.Fatalf("state = %+v", state)
+	}
+
+	if err := s.SkipVersion("1.1.0"); err != nil {
+		t.Fatalf("skip: %v", err)
+	}
+	if info, err := s.CheckForUpdates(); err != nil || info != nil {
+		t.Fatalf("skipped version offered: %+v, %v", info, err)
+	}
+	if !slices.Contains(updateEvents(events), "update_version_skipped") {
+		t.Errorf("events: got %v", updateEvents(events))
+	}
+
+	// Skipped versions survive a restart
+	restarted := New(s.cfg, withTestDeps(client)...)
+	state := restarted.GetUpdateState()
+	if state.Status != StatusIdle || !slices.Contains(state.SkippedVersions, "1.1.0") {
+		t.Fatalf("state after restart = %+v", state)
+	}
+	if err := restarted.CleanupDownloads(); err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	if !restarted.isSkipped("1.1.0") {
+		t.Error("cleanup removed the skipped versions")
+	}
+}
+
+func TestSkipVersionRefusesForcedUpdate(t *testing.T) {
+	client := mocks.NewHTTPClient(respondWith(UpdateInfo{Version: "1.2.0", IsForced: true}))
+	s, _ := newTestService(t, client, &mocks.ProcessRunner{})
+
+	if _, err := s.CheckForUpdates(); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if err := s.SkipVersion("1.2.0"); err == nil {
+		t.Fatal("forced update skipped")
+	}
+	if err := s.ResumeDownload(context.Background()); err == nil {
+		t.Error("resumed a download that was never started")
+	}
+}
@@ -1,19 +1,23 @@
 package update
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"jarvist/internal/common/bandwidth"
 	"jarvist/internal/common/config"
+	"jarvist/internal/wails/services/callguard"
+	"jarvist/internal/wails/services/deps"
+	"jarvist/internal/wails/services/eventbuffer"
+	"jarvist/internal/wails/services/usage"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"sync"
-	"syscall"
 	"time"
 
 	"github.com/wailsapp/wails/v3/pkg/application"
@@ -26,6 +30,7 @@ type UpdateInfo struct {
 	Notes       string `json:"notes"`
 	IsForced    bool   `json:"isForced"`
 	Checksum    string `json:"checksum"`
+	Signature   string `json:"signature,omitempty"`
 }
 
 type UpdateResponse struct {
@@ -44,30 +49,61 @@ type UpdateEvent struct {
 	Data      any       `json:"data,omitempty"`
 }
 
+// downloadTimeout limits a DownloadUpdate call, enough for the installer on a slow link
+const downloadTimeout = 30 * time.Minute
+
+// checkTimeout limits a request to the update server for the latest version
+const checkTimeout = 10 * time.Second
+
 type UpdateService struct {
 	currentVersion  string
 	updateServerURL string
-	updateProcess   *exec.Cmd
+	updateProcess   deps.Process
+	downloadCancel  context.CancelFunc
 	mu              sync.Mutex
 	isChecking      bool
 	isDownloading   bool
 	isInstalling    bool
+	pausing         bool
+	state           UpdateState
 	cfg             *config.Config
 	app             *application.App
+	events          eventbuffer.Emitter
+	calls           *callguard.Guard
+	http            deps.HTTPClient
+	runner          deps.ProcessRunner
+
+	serviceController ServiceController
+	updatePublicKey   string
+	serviceProgress   *ServiceUpdateProgress
 }
 
-func New(cfg *config.Config) *UpdateService {
+func New(cfg *config.Config, opts ...deps.Option) *UpdateService {
 	updateServerURL := fmt.Sprintf("%s/v1/app/updates", cfg.ApiUrl)
 
-	return &UpdateService{
+	d := deps.Apply(opts)
+	if d.HTTP == nil {
+		d.HTTP = deps.NewHTTPClient(bandwidth.DestinationUpdate)
+	}
+
+	s := &UpdateService{
 		currentVersion:  cfg.AppVersion,
 		updateServerURL: updateServerURL,
 		cfg:             cfg,
+		calls:           callguard.New(nil),
+		events:          d.Events,
+		http:            d.HTTP,
+		runner:          d.Runner,
 	}
+	s.loadState()
+	return s
 }
 
 func (s *UpdateService) InitService(app *application.App) {
 	s.app = app
+	if s.events == nil {
+		s.events = deps.AppEmitter{App: app}
+	}
 }
 
 func (s *UpdateService) GetCurrentVersion() string {
@@ -87,6 +123,7 @@ func (s *UpdateService) CheckForUpdates() (*UpdateInfo, error) {
 	s.isChecking = true
 	s.mu.Unlock()
 
+	usage.Feature(usage.UpdateCheck)
 	s.emitEvent("update_checking", "Checking for updates...", true, nil)
 
 	defer func() {
@@ -101,7 +138,10 @@ func (s *UpdateService) CheckForUpdates() (*UpdateInfo, error) {
 		runtime.GOOS,
 		runtime.GOARCH)
 
-	req, err := http.NewRequest("GET", checkURL, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", checkURL, nil)
 	if err != nil {
 		s.emitEvent("update_check_error", "Error creating request: "+err.Error(), false, nil)
 		return nil, err
@@ -110,11 +150,7 @@ func (s *UpdateService) CheckForUpdates() (*UpdateInfo, error) {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Add("X-API-Key", s.cfg.ApiKey)
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	resp, err := client.Do(req)
+	resp, err := s.http.Do(req)
 	if err != nil {
 		s.emitEvent("update_check_error", "Error: "+err.Error(), false, nil)
 		return nil, err
@@ -142,35 +178,120 @@ func (s *UpdateService) CheckForUpdates() (*UpdateInfo, error) {
 	updateInfo := response.Data
 
 	if updateInfo.Version == s.currentVersion {
+		s.updateState(func(state *UpdateState) {
+			if state.Status == StatusAvailable {
+				state.Status = StatusIdle
+				state.Available = nil
+			}
+		})
 		s.emitEvent("update_check_complete", "You are using the latest version", true, nil)
 		return nil, nil
 	}
 
+	if !updateInfo.IsForced && s.isSkipped(updateInfo.Version) {
+		s.emitEvent("update_check_complete", fmt.Sprintf("Version %s was skipped", updateInfo.Version), true, nil)
+		return nil, nil
+	}
+
+	// Unduhan yang dijeda atau selesai untuk versi yang sama tetap dipakai
+	s.updateState(func(state *UpdateState) {
+		if state.Available != nil && state.Available.Version == updateInfo.Version && state.Status != StatusIdle {
+			return
+		}
+		available := updateInfo
+		state.Status = StatusAvailable
+		state.Available = &available
+		state.LastError = ""
+	})
+
 	s.emitEvent("update_available", fmt.Sprintf("Version %s is available", updateInfo.Version), true, updateInfo)
 	return &updateInfo, nil
 }
 
-func (s *UpdateService) DownloadUpdate(updateInfo *UpdateInfo) error {
+// CheckForServiceUpdates checks whether a newer syncmanager binary is available
+func (s *UpdateService) CheckForServiceUpdates(serviceVersion string) (*UpdateInfo, error) {
+	checkURL := fmt.Sprintf("%s/check?component=syncmanager&version=%s&os=%s&arch=%s",
+		s.updateServerURL,
+		serviceVersion,
+		runtime.GOOS,
+		runtime.GOARCH)
+
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", checkURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Add("X-API-Key", s.cfg.ApiKey)
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP error: %d", resp.StatusCode)
+	}
+
+	var response UpdateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	updateInfo := response.Data
+	if updateInfo.Version == "" || updateInfo.Version == serviceVersion {
+		return nil, nil
+	}
+
+	s.emitEvent("service_update_available", fmt.Sprintf("Sync service version %s is available", updateInfo.Version), true, updateInfo)
+	return &updateInfo, nil
+}
+
+// DownloadUpdate downloads the update installer. The download stops when the frontend
+// cancels the call or CancelUpdate is called, the partial file is removed. PauseDownload
+// keeps the partial file.
+func (s *UpdateService) DownloadUpdate(ctx context.Context, updateInfo *UpdateInfo) error {
+	return callguard.Do(s.calls, ctx, "DownloadUpdate", downloadTimeout, func(ctx context.Context) error {
+		return s.downloadUpdate(ctx, updateInfo, false)
+	})
+}
+
+// downloadUpdate downloads the installer, resume continues from the partial file
+func (s *UpdateService) downloadUpdate(ctx context.Context, updateInfo *UpdateInfo, resume bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	s.mu.Lock()
 	if s.isDownloading {
 		s.mu.Unlock()
 		return fmt.Errorf("already downloading update")
 	}
 	s.isDownloading = true
+	s.pausing = false
+	s.downloadCancel = cancel
 	s.mu.Unlock()
 
 	defer func() {
 		s.mu.Lock()
 		s.isDownloading = false
+		s.pausing = false
+		s.downloadCancel = nil
 		s.mu.Unlock()
 	}()
 
-	s.emitEvent("update_download_start", "Downloading update...", true, nil)
+	if resume {
+		s.emitEvent("update_download_resume", "Resuming download...", true, nil)
+	} else {
+		s.emitEvent("update_download_start", "Downloading update...", true, nil)
+	}
 
-	downloadDir := filepath.Join(s.cfg.TempDir, "updates")
+	downloadDir := s.updatesDir()
 	if err := os.MkdirAll(downloadDir, 0755); err != nil {
-		s.emitEvent("update_download_error", "Error: "+err.Error(), false, nil)
-		return err
+		return s.failDownload("Error: "+err.Error(), err)
 	}
 
 	var fileName string
@@ -185,53 +306,109 @@ func (s *UpdateService) DownloadUpdate(updateInfo *UpdateInfo) error {
 
 	downloadPath := filepath.Join(downloadDir, fileName)
 
-	file, err := os.Create(downloadPath)
+	// Lanjutkan dari bagian yang sudah tersimpan, hash dihitung ulang dari file parsial
+	hash := sha256.New()
+	var offset int64
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		if partial, err := os.Open(downloadPath); err == nil {
+			offset, err = io.Copy(hash, partial)
+			partial.Close()
+			if err != nil {
+				offset = 0
+				hash.Reset()
+			}
+		}
+		if offset > 0 {
+			flags = os.O_WRONLY | os.O_APPEND
+		}
+	}
+
+	file, err := os.OpenFile(downloadPath, flags, 0644)
 	if err != nil {
-		s.emitEvent("update_download_error", "Error: "+err.Error(), false, nil)
-		return err
+		return s.failDownload("Error: "+err.Error(), err)
 	}
 	defer file.Close()
 
-	resp, err := http.Get(updateInfo.DownloadURL)
+	s.updateState(func(state *UpdateState) {
+		available := *updateInfo
+		state.Status = StatusDownloading
+		state.Available = &available
+		state.DownloadPath = downloadPath
+		state.Downloaded = offset
+		state.LastError = ""
+	})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, updateInfo.DownloadURL, nil)
 	if err != nil {
-		s.emitEvent("update_download_error", "Error: "+err.Error(), false, nil)
-		return err
+		return s.failDownload("Error: "+err.Error(), err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return s.stopDownload(file, downloadPath, ctx.Err())
+		}
+		return s.failDownload("Error: "+err.Error(), err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent:
+	case resp.StatusCode == http.StatusOK:
+		// The server ignored the range and sends the whole file
+		if offset > 0 {
+			if err := file.Truncate(0); err != nil {
+				return s.failDownload("Error: "+err.Error(), err)
+			}
+			offset = 0
+			hash.Reset()
+		}
+	default:
 		errorMsg := fmt.Sprintf("HTTP error: %d", resp.StatusCode)
-		s.emitEvent("update_download_error", errorMsg, false, nil)
-		return fmt.Errorf("%s", errorMsg)
+		return s.failDownload(errorMsg, fmt.Errorf("%s", errorMsg))
 	}
 
 	total := resp.ContentLength
+	if total > 0 {
+		total += offset
+	}
 	buf := make([]byte, 1024*32) // 32KB chunks
-	var downloaded int64
-	hash := sha256.New()
+	downloaded := offset
 
 	for {
 		n, err := resp.Body.Read(buf)
 		if n > 0 {
 			_, writeErr := file.Write(buf[:n])
 			if writeErr != nil {
-				s.emitEvent("update_download_error", "Error: "+writeErr.Error(), false, nil)
-				return writeErr
+				return s.failDownload("Error: "+writeErr.Error(), writeErr)
 			}
 
 			hash.Write(buf[:n])
 			downloaded += int64(n)
 
+			progress := 0
 			if total > 0 {
-				progress := int((float64(downloaded) / float64(total)) * 100)
+				progress = int((float64(downloaded) / float64(total)) * 100)
 				s.emitEvent("update_download_progress", fmt.Sprintf("Downloading: %d%%", progress), true, progress)
 			}
+
+			s.mu.Lock()
+			s.state.Downloaded = downloaded
+			s.state.Total = total
+			s.state.Progress = progress
+			s.mu.Unlock()
 		}
 
 		if err != nil {
+			if ctx.Err() != nil {
+				return s.stopDownload(file, downloadPath, ctx.Err())
+			}
 			if err != io.EOF {
-				s.emitEvent("update_download_error", "Error: "+err.Error(), false, nil)
-				return err
+				return s.failDownload("Error: "+err.Error(), err)
 			}
 			break
 		}
@@ -241,8 +418,12 @@ func (s *UpdateService) DownloadUpdate(updateInfo *UpdateInfo) error {
 		s.emitEvent("update_download_progress", "Verifying checksum...", true, 100)
 		actualChecksum := hex.EncodeToString(hash.Sum(nil))
 		if actualChecksum != updateInfo.Checksum {
-			s.emitEvent("update_download_error", "Invalid checksum! File may be corrupted.", false, nil)
-			return fmt.Errorf("invalid checksum: expected %s, got %s", updateInfo.Checksum, actualChecksum)
+			// A corrupt file must not be resumed, the next attempt starts over
+			file.Close()
+			os.Remove(downloadPath)
+			s.updateState(func(state *UpdateState) { state.DownloadPath = "" })
+			return s.failDownload("Invalid checksum! File may be corrupted.",
+				fmt.Errorf("invalid checksum: expected %s, got %s", updateInfo.Checksum, actualChecksum))
 		}
 		s.emitEvent("update_download_progress", "Checksum verified", true, 100)
 	}
@@ -260,10 +441,51 @@ func (s *UpdateService) DownloadUpdate(updateInfo *UpdateInfo) error {
 		s.emitEvent("update_flag_error", "Error creating update flag: "+err.Error(), false, nil)
 	}
 
+	s.updateState(func(state *UpdateState) {
+		state.Status = StatusDownloaded
+		state.Downloaded = downloaded
+		state.Total = downloaded
+		state.Progress = 100
+	})
+
 	s.emitEvent("update_download_complete", "Download completed. Update will be installed on restart.", true, downloadPath)
 	return nil
 }
 
+// stopDownload ends a download whose context was cancelled. A paused download keeps its
+// partial file, a cancelled one removes it.
+func (s *UpdateService) stopDownload(file *os.File, downloadPath string, err error) error {
+	file.Close()
+
+	s.mu.Lock()
+	pausing := s.pausing
+	s.mu.Unlock()
+
+	if pausing {
+		s.updateState(func(state *UpdateState) { state.Status = StatusPaused })
+		s.emitEvent("update_download_paused", "Download paused", true, nil)
+		return ErrDownloadPaused
+	}
+
+	os.Remove(downloadPath)
+	s.updateState(func(state *UpdateState) {
+		state.Status = StatusAvailable
+		s.resetDownloadLocked()
+	})
+	s.emitEvent("update_download_cancelled", "Download cancelled", false, nil)
+	return err
+}
+
+// failDownload records a failed download and emits the error
+func (s *UpdateService) failDownload(message string, err error) error {
+	s.updateState(func(state *UpdateState) {
+		state.Status = StatusFailed
+		state.LastError = err.Error()
+	})
+	s.emitEvent("update_download_error", message, false, nil)
+	return err
+}
+
 func (s *UpdateService) InstallUpdate(downloadPath string) error {
 	s.mu.Lock()
 	if s.isInstalling {
@@ -286,31 +508,32 @@ func (s *UpdateService) InstallUpdate(downloadPath string) error {
 		return fmt.Errorf("file not found: %s", downloadPath)
 	}
 
-	var cmd *exec.Cmd
+	var name string
+	var args []string
 	switch runtime.GOOS {
 	case "windows":
-		cmd = exec.Command(downloadPath, "/SILENT", "/NORESTART")
-		cmd.SysProcAttr = &syscall.SysProcAttr{
-			HideWindow:    true,
-			CreationFlags: 0x08000000,
-		}
+		name, args = downloadPath, []string{"/SILENT", "/NORESTART"}
 	case "darwin":
-		cmd = exec.Command("open", downloadPath)
+		name, args = "open", []string{downloadPath}
 	default:
-		cmd = exec.Command("tar", "-xzf", downloadPath, "-C", filepath.Dir(downloadPath))
+		name, args = "tar", []string{"-xzf", downloadPath, "-C", filepath.Dir(downloadPath)}
 	}
 
-	s.updateProcess = cmd
-
-	if err := cmd.Start(); err != nil {
+	process, err := s.runner.Start(name, args...)
+	if err != nil {
 		s.emitEvent("update_install_error", "Error: "+err.Error(), false, nil)
 		return err
 	}
 
+	s.mu.Lock()
+	s.updateProcess = process
+	s.mu.Unlock()
+
 	go func() {
-		err := cmd.Wait()
+		err := process.Wait()
 
 		if err != nil {
+			s.updateState(func(state *UpdateState) { state.LastError = err.Error() })
 			s.emitEvent("update_install_error", "Error: "+err.Error(), false, nil)
 		} else {
 			s.emitEvent("update_install_complete", "Installation completed. Please restart the application.", true, nil)
@@ -342,12 +565,27 @@ func (s *UpdateService) InstallUpdateOnRestart(downloadPath string) error {
 	return nil
 }
 
+// CancelUpdate stops a running download or update process
 func (s *UpdateService) CancelUpdate() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.updateProcess != nil && s.updateProcess.Process != nil {
-		if err := s.updateProcess.Process.Kill(); err != nil {
+	if s.downloadCancel != nil {
+		s.downloadCancel()
+	} else if s.state.Status == StatusPaused || s.state.Status == StatusFailed {
+		// A paused download has no running request, its partial file is removed here
+		if s.state.DownloadPath != "" {
+			os.Remove(s.state.DownloadPath)
+		}
+		s.resetDownloadLocked()
+		s.state.Status = StatusAvailable
+		s.state.LastError = ""
+		s.saveStateLocked()
+		s.emitEvent("update_download_cancelled", "Download cancelled", false, nil)
+	}
+
+	if s.updateProcess != nil {
+		if err := s.updateProcess.Kill(); err != nil {
 			s.emitEvent("update_cancel_error", "Error: "+err.Error(), false, nil)
 			return err
 		}
@@ -360,7 +598,7 @@ func (s *UpdateService) CancelUpdate() error {
 }
 
 func (s *UpdateService) CleanupDownloads() error {
-	downloadDir := filepath.Join(s.cfg.TempDir, "updates")
+	downloadDir := s.updatesDir()
 
 	if !fileExists(downloadDir) {
 		return nil
@@ -372,7 +610,8 @@ func (s *UpdateService) CleanupDownloads() error {
 	}
 
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		// The state keeps the skipped versions
+		if !entry.IsDir() && entry.Name() != stateFileName {
 			filePath := filepath.Join(downloadDir, entry.Name())
 			if err := os.Remove(filePath); err != nil {
 				s.emitEvent("update_cleanup_error", fmt.Sprintf("Error removing %s: %v", filePath, err), false, nil)
@@ -380,6 +619,14 @@ func (s *UpdateService) CleanupDownloads() error {
 		}
 	}
 
+	s.updateState(func(state *UpdateState) {
+		switch state.Status {
+		case StatusPaused, StatusFailed, StatusDownloaded:
+			state.Status = StatusAvailable
+			s.resetDownloadLocked()
+		}
+	})
+
 	s.emitEvent("update_cleanup_complete", "Download files cleanup completed", true, nil)
 	return nil
 }
@@ -414,22 +661,15 @@ func (s *UpdateService) InstallPendingUpdates() error {
 		return fmt.Errorf("update installer not found: %s", updatePath)
 	}
 
-	var cmd *exec.Cmd
 	switch runtime.GOOS {
 	case "windows":
-		cmd = exec.Command(updatePath, "/SILENT", "/NORESTART")
-		cmd.SysProcAttr = &syscall.SysProcAttr{
-			HideWindow:    true,
-			CreationFlags: 0x08000000,
-		}
-
+		_, err = s.runner.Start(updatePath, "/SILENT", "/NORESTART")
 	case "darwin":
-		cmd = exec.Command("open", updatePath)
+		_, err = s.runner.Start("open", updatePath)
 	default:
-		cmd = exec.Command("bash", "-c", fmt.Sprintf("nohup %s &", updatePath))
+		_, err = s.runner.Start("bash", "-c", fmt.Sprintf("nohup %s &", updatePath))
 	}
-
-	if err := cmd.Start(); err != nil {
+	if err != nil {
 		return err
 	}
 
@@ -441,6 +681,11 @@ func (s *UpdateService) InstallPendingUpdates() error {
 	return nil
 }
 
+// SetEventBuffer makes update events go through the event buffer
+func (s *UpdateService) SetEventBuffer(events eventbuffer.Emitter) {
+	s.events = events
+}
+
 func (s *UpdateService) SetUpdateServerURL(url string) {
 	s.updateServerURL = url
 }
@@ -463,7 +708,9 @@ func (s *UpdateService) emitEvent(event, message string, success bool, data any)
 	}
 
 	jsonData, _ := json.Marshal(updateEvent)
-	s.app.EmitEvent("update_event", string(jsonData))
+	if s.events != nil {
+		s.events.Emit("update_event", string(jsonData))
+	}
 }
 
 func (s *UpdateService) getPendingUpdatePath() (string, error) {
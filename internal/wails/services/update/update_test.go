@@ -0,0 +1,432 @@
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"jarvist/internal/common/config"
+	"jarvist/internal/wails/services/deps"
+	"jarvist/internal/wails/services/mocks"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestService(t *testing.T, client *mocks.HTTPClient, runner *mocks.ProcessRunner) (*UpdateService, *mocks.Emitter) {
+	t.Helper()
+
+	cfg := &config.Config{
+		ApiUrl:      "http://api.test",
+		ApiKey:      "test-key",
+		AppVersion:  "1.0.0",
+		Environment: "production",
+		TempDir:     t.TempDir(),
+	}
+	events := &mocks.Emitter{}
+	return New(cfg, deps.WithHTTPClient(client), deps.WithEmitter(events), deps.WithRunner(runner)), events
+}
+
+// updateEvents returns the names of the emitted update events in order
+func updateEvents(events *mocks.Emitter) []string {
+	var names []string
+	for _, event := range events.Named("update_event") {
+		var update UpdateEvent
+		if data, ok := event.Data.(string); ok && json.Unmarshal([]byte(data), &update) == nil {
+			names = append(names, update.Event)
+		}
+	}
+	return names
+}
+
+// waitForEvent waits until the update event was emitted, install results are emitted
+// from a goroutine
+func waitForEvent(t *testing.T, events *mocks.Emitter, name string) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if slices.Contains(updateEvents(events), name) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("event %s not emitted, got %v", name, updateEvents(events))
+}
+
+func respondWith(info UpdateInfo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(UpdateResponse{Success: true, Code: 200, Data: info})
+	}
+}
+
+func TestCheckForUpdates(t *testing.T) {
+	t.Run("newer version", func(t *testing.T) {
+		client := mocks.NewHTTPClient(respondWith(UpdateInfo{Version: "1.1.0", DownloadURL: "http://cdn.test/app.exe"}))
+		s, events := newTestService(t, client, &mocks.ProcessRunner{})
+
+		info, err := s.CheckForUpdates()
+		if err != nil {
+			t.Fatalf("check: %v", err)
+		}
+		if info == nil || info.Version != "1.1.0" {
+			t.Fatalf("info: got %+v", info)
+		}
+
+		request := client.Requests()[0]
+		if request.Path != "/v1/app/updates/check" || !strings.Contains(request.Query, "version=1.0.0") {
+			t.Errorf("request: got %s?%s", request.Path, request.Query)
+		}
+		if got := request.Header.Get("X-API-Key"); got != "test-key" {
+			t.Errorf("api key: got %q", got)
+		}
+		if got := updateEvents(events); !slices.Equal(got, []string{"update_checking", "update_available"}) {
+			t.Errorf("events: got %v", got)
+		}
+	})
+
+	t.Run("up to date", func(t *testing.T) {
+		client := mocks.NewHTTPClient(respondWith(UpdateInfo{Version: "1.0.0"}))
+		s, events := newTestService(t, client, &mocks.ProcessRunner{})
+
+		info, err := s.CheckForUpdates()
+		if err != nil || info != nil {
+			t.Fatalf("check: got %+v, %v", info, err)
+		}
+		if got := updateEvents(events); !slices.Contains(got, "update_check_complete") {
+			t.Errorf("events: got %v", got)
+		}
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		client := mocks.NewHTTPClient(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+		s, events := newTestService(t, client, &mocks.ProcessRunner{})
+
+		if _, err := s.CheckForUpdates(); err == nil {
+			t.Fatal("check succeeded on HTTP 500")
+		}
+		if got := updateEvents(events); !slices.Contains(got, "update_check_error") {
+			t.Errorf("events: got %v", got)
+		}
+	})
+}
+
+func TestCheckForServiceUpdates(t *testing.T) {
+	client := mocks.NewHTTPClient(respondWith(UpdateInfo{Version: "2.0.1"}))
+	s, _ := newTestService(t, client, &mocks.ProcessRunner{})
+
+	info, err := s.CheckForServiceUpdates("2.0.0")
+	if err != nil || info == nil || info.Version != "2.0.1" {
+		t.Fatalf("check: got %+v, %v", info, err)
+	}
+	if query := client.Requests()[0].Query; !strings.Contains(query, "component=syncmanager") || !strings.Contains(query, "version=2.0.0") {
+		t.Errorf("query: got %s", query)
+	}
+
+	if info, err := s.CheckForServiceUpdates("2.0.1"); err != nil || info != nil {
+		t.Errorf("same version: got %+v, %v", info, err)
+	}
+}
+
+func TestDownloadUpdate(t *testing.T) {
+	installer := []byte("installer content")
+	sum := sha256.Sum256(installer)
+	client := mocks.NewHTTPClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(installer)
+	})
+
+	t.Run("verified", func(t *testing.T) {
+		s, events := newTestService(t, client, &mocks.ProcessRunner{})
+
+		err := s.downloadUpdate(context.Background(), &UpdateInfo{Version: "1.1.0", DownloadURL: "http://cdn.test/app", Checksum: hex.EncodeToString(sum[:])}, false)
+		if err != nil {
+			t.Fatalf("download: %v", err)
+		}
+
+		pending, err := s.readPendingUpdateFile(filepath.Join(s.cfg.TempDir, "updates", "pending_update.json"))
+		if err != nil {
+			t.Fatalf("pending update: %v", err)
+		}
+		content, err := os.ReadFile(pending)
+		if err != nil || string(content) != string(installer) {
+			t.Errorf("downloaded file: got %q, %v", content, err)
+		}
+		if !s.CheckPendingUpdates() {
+			t.Error("no pending update after download")
+		}
+		if got := updateEvents(events); !slices.Contains(got, "update_download_complete") {
+			t.Errorf("events: got %v", got)
+		}
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		s, events := newTestService(t, client, &mocks.ProcessRunner{})
+
+		err := s.downloadUpdate(context.Background(), &UpdateInfo{Version: "1.1.0", DownloadURL: "http://cdn.test/app", Checksum: strings.Repeat("0", 64)}, false)
+		if err == nil || !strings.Contains(err.Error(), "invalid checksum") {
+			t.Fatalf("download: got %v, want checksum error", err)
+		}
+		if s.CheckPendingUpdates() {
+			t.Error("pending update recorded for a corrupt download")
+		}
+		if got := updateEvents(events); !slices.Contains(got, "update_download_error") {
+			t.Errorf("events: got %v", got)
+		}
+	})
+}
+
+func TestInstallUpdate(t *testing.T) {
+	runner := &mocks.ProcessRunner{}
+	s, events := newTestService(t, &mocks.HTTPClient{}, runner)
+
+	if err := s.InstallUpdate(filepath.Join(s.cfg.TempDir, "missing.exe")); err == nil {
+		t.Fatal("installed a missing file")
+	}
+	if len(runner.Commands()) != 0 {
+		t.Fatalf("started %v for a missing file", runner.Commands())
+	}
+
+	installer := filepath.Join(s.cfg.TempDir, "update-1.1.0.exe")
+	if err := os.WriteFile(installer, []byte("installer"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InstallUpdate(installer); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+
+	commands := runner.Commands()
+	if len(commands) != 1 || !slices.Contains(commands[0], installer) {
+		t.Fatalf("commands: got %v", commands)
+	}
+
+	runner.Processes()[0].Exit()
+	waitForEvent(t, events, "update_install_complete")
+}
+
+func TestCancelUpdateKillsInstaller(t *testing.T) {
+	runner := &mocks.ProcessRunner{}
+	s, events := newTestService(t, &mocks.HTTPClient{}, runner)
+
+	installer := filepath.Join(s.cfg.TempDir, "update-1.1.0.exe")
+	if err := os.WriteFile(installer, []byte("installer"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InstallUpdate(installer); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+
+	if err := s.CancelUpdate(); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if !runner.Processes()[0].Killed() {
+		t.Error("installer not killed")
+	}
+	waitForEvent(t, events, "update_cancelled")
+}
+
+func TestVerifyServiceBinary(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte("syncmanager"))
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, digest[:]))
+
+	s, _ := newTestService(t, &mocks.HTTPClient{}, &mocks.ProcessRunner{})
+	s.SetUpdatePublicKey(base64.StdEncoding.EncodeToString(publicKey))
+
+	tests := []struct {
+		name    string
+		info    UpdateInfo
+		wantErr string
+	}{
+		{"signed", UpdateInfo{Checksum: hex.EncodeToString(digest[:]), Signature: signature}, ""},
+		{"wrong checksum", UpdateInfo{Checksum: strings.Repeat("0", 64), Signature: signature}, "invalid checksum"},
+		{"unsigned", UpdateInfo{}, "missing or malformed signature"},
+		{"bad signature", UpdateInfo{Signature: base64.StdEncoding.EncodeToString(make([]byte, ed25519.SignatureSize))}, "signature verification failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.verifyServiceBinary(&tt.info, digest[:])
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("got %v, want no error", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("got %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fakeServiceController stops and starts the sync service in memory. stopErrs and startErrs
+// are returned by the next calls in order, a failed call leaves the state unchanged.
+type fakeServiceController struct {
+	binaryPath string
+	running    bool
+	calls      []string
+	stopErrs   []error
+	startErrs  []error
+}
+
+func (c *fakeServiceController) StopService() (string, error) {
+	c.calls = append(c.calls, "stop")
+	if err := nextErr(&c.stopErrs); err != nil {
+		return "", err
+	}
+	c.running = false
+	return "", nil
+}
+
+func (c *fakeServiceController) StartService() (string, error) {
+	c.calls = append(c.calls, "start")
+	if err := nextErr(&c.startErrs); err != nil {
+		return "", err
+	}
+	c.running = true
+	return "", nil
+}
+
+func nextErr(errs *[]error) error {
+	if len(*errs) == 0 {
+		return nil
+	}
+	err := (*errs)[0]
+	*errs = (*errs)[1:]
+	return err
+}
+
+func (c *fakeServiceController) IsServiceRunning() (bool, error) { return c.running, nil }
+func (c *fakeServiceController) GetServiceBinaryPath() string    { return c.binaryPath }
+
+func TestInstallServiceUpdate(t *testing.T) {
+	health := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer health.Close()
+
+	binary := []byte("syncmanager v2")
+	s, events := newTestService(t, mocks.NewHTTPClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(binary)
+	}), &mocks.ProcessRunner{})
+	s.cfg.Environment = "development"
+	s.cfg.SyncApi = health.URL
+
+	binaryPath := filepath.Join(t.TempDir(), "sync-manager.exe")
+	if err := os.WriteFile(binaryPath, []byte("syncmanager v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	controller := &fakeServiceController{binaryPath: binaryPath, running: true}
+	s.SetServiceController(controller)
+
+	if err := s.InstallServiceUpdate(context.Background(), &UpdateInfo{Version: "2.0.0", DownloadURL: "http://updates.test/sync-manager.exe"}); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+
+	if data, _ := os.ReadFile(binaryPath); string(data) != string(binary) {
+		t.Errorf("binary = %q, want the update", data)
+	}
+	if fileExists(binaryPath+".bak") || fileExists(binaryPath+".new") {
+		t.Error("backup or download left behind")
+	}
+	if !slices.Equal(controller.calls, []string{"stop", "start"}) {
+		t.Errorf("service calls = %v, want stop, start", controller.calls)
+	}
+	if s.GetServiceUpdateProgress() != nil {
+		t.Error("progress kept after the update finished")
+	}
+
+	var steps []string
+	for _, event := range events.Named("update_event") {
+		var update UpdateEvent
+		json.Unmarshal([]byte(event.Data.(string)), &update)
+		if update.Event != "service_update_progress" {
+			continue
+		}
+		data, _ := json.Marshal(update.Data)
+		var progress ServiceUpdateProgress
+		json.Unmarshal(data, &progress)
+		if len(steps) == 0 || steps[len(steps)-1] != progress.Step {
+			steps = append(steps, progress.Step)
+		}
+	}
+	want := []string{ServiceStepDownload, ServiceStepVerify, ServiceStepStop, ServiceStepSwap, ServiceStepStart, ServiceStepHealth, ServiceStepDone}
+	if !slices.Equal(steps, want) {
+		t.Errorf("steps = %v, want %v", steps, want)
+	}
+	waitForEvent(t, events, "service_update_complete")
+}
+
+func TestInstallServiceUpdateRollsBack(t *testing.T) {
+	binary := []byte("syncmanager v2")
+	s, _ := newTestService(t, mocks.NewHTTPClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(binary)
+	}), &mocks.ProcessRunner{})
+	s.cfg.Environment = "development"
+
+	binaryPath := filepath.Join(t.TempDir(), "sync-manager.exe")
+	if err := os.WriteFile(binaryPath, []byte("syncmanager v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The new binary never starts, stopping it again fails because it is already stopped
+	controller := &fakeServiceController{
+		binaryPath: binaryPath,
+		running:    true,
+		stopErrs:   []error{nil, errors.New("service is already stopped")},
+		startErrs:  []error{errors.New("service failed to start")},
+	}
+	s.SetServiceController(controller)
+
+	err := s.InstallServiceUpdate(context.Background(), &UpdateInfo{Version: "2.0.0", DownloadURL: "http://updates.test/sync-manager.exe"})
+	if err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("install: got %v, want a rolled back error", err)
+	}
+
+	if data, _ := os.ReadFile(binaryPath); string(data) != "syncmanager v1" {
+		t.Errorf("binary = %q, want the previous version restored", data)
+	}
+	if fileExists(binaryPath + ".bak") {
+		t.Error("backup left behind")
+	}
+	if !slices.Equal(controller.calls, []string{"stop", "start", "stop", "start"}) {
+		t.Errorf("service calls = %v, want stop, start, stop, start", controller.calls)
+	}
+	if !controller.running {
+		t.Error("previous service not started after the rollback")
+	}
+}
+
+func TestRecoverServiceBinary(t *testing.T) {
+	s, events := newTestService(t, &mocks.HTTPClient{}, &mocks.ProcessRunner{})
+	binaryPath := filepath.Join(t.TempDir(), "sync-manager.exe")
+	s.SetServiceController(&fakeServiceController{binaryPath: binaryPath})
+
+	// The swap was interrupted after the executable was moved to the backup
+	os.WriteFile(binaryPath+".bak", []byte("syncmanager v1"), 0644)
+	os.WriteFile(binaryPath+".new", []byte("syncmanager v2"), 0644)
+
+	restored, err := s.RecoverServiceBinary()
+	if err != nil || !restored {
+		t.Fatalf("recover = %v, %v, want restored", restored, err)
+	}
+	if data, _ := os.ReadFile(binaryPath); string(data) != "syncmanager v1" {
+		t.Errorf("binary = %q, want the previous version", data)
+	}
+	if fileExists(binaryPath+".new") || fileExists(binaryPath+".bak") {
+		t.Error("leftover files not removed")
+	}
+	waitForEvent(t, events, "service_update_rolled_back")
+
+	// Nothing to recover once the executable is in place
+	if restored, err := s.RecoverServiceBinary(); err != nil || restored {
+		t.Errorf("second recover = %v, %v, want nothing to do", restored, err)
+	}
+}
@@ -0,0 +1,58 @@
+// Package usage passes feature usage and crashes from the services to telemetry. The
+// telemetry service depends on the settings service, so the services report here and the
+// telemetry service is set as the recorder on startup.
+package usage
+
+import "sync"
+
+// Features counted by telemetry, the only names it accepts
+const (
+	CameraCreate     = "camera_create"
+	CameraUpdate     = "camera_update"
+	CameraDelete     = "camera_delete"
+	CameraCheck      = "camera_check"
+	StreamView       = "stream_view"
+	LogView          = "log_view"
+	UpdateCheck      = "update_check"
+	ServiceControl   = "service_control"
+	SettingsChange   = "settings_change"
+	DiagnosticsBuild = "diagnostics_build"
+)
+
+// Recorder counts the usage, implemented by the telemetry service
+type Recorder interface {
+	RecordFeatureUsage(feature string)
+	RecordCrash()
+}
+
+var (
+	mu       sync.RWMutex
+	recorder Recorder
+)
+
+// SetRecorder sets the recorder, nil drops the usage
+func SetRecorder(r Recorder) {
+	mu.Lock()
+	recorder = r
+	mu.Unlock()
+}
+
+// Feature records one use of a feature
+func Feature(feature string) {
+	if r := current(); r != nil {
+		r.RecordFeatureUsage(feature)
+	}
+}
+
+// Crash records a recovered panic
+func Crash() {
+	if r := current(); r != nil {
+		r.RecordCrash()
+	}
+}
+
+func current() Recorder {
+	mu.RLock()
+	defer mu.RUnlock()
+	return recorder
+}
@@ -9,24 +9,36 @@ import (
 	"runtime/debug"
 	"time"
 
+	"jarvist/internal/common/bandwidth"
 	"jarvist/internal/common/buildinfo"
 	"jarvist/internal/common/config"
 	"jarvist/internal/common/database"
 	"jarvist/internal/common/ffmpeg"
+	commonresidency "jarvist/internal/common/residency"
+	desktopapi "jarvist/internal/wails/api"
 	applicationservice "jarvist/internal/wails/services/application"
+	"jarvist/internal/wails/services/auth"
 	"jarvist/internal/wails/services/camera"
 	configservice "jarvist/internal/wails/services/config"
 	"jarvist/internal/wails/services/device"
+	"jarvist/internal/wails/services/eventbuffer"
+	"jarvist/internal/wails/services/identity"
+	"jarvist/internal/wails/services/kiosk"
 	licenseservice "jarvist/internal/wails/services/license"
 	"jarvist/internal/wails/services/location"
 	"jarvist/internal/wails/services/logmanager"
 	"jarvist/internal/wails/services/processmanager"
+	"jarvist/internal/wails/services/residency"
 	"jarvist/internal/wails/services/servicemanager"
 	"jarvist/internal/wails/services/setting"
 	"jarvist/internal/wails/services/site"
 	"jarvist/internal/wails/services/stats"
 	"jarvist/internal/wails/services/stream"
+	"jarvist/internal/wails/services/support"
+	"jarvist/internal/wails/services/syncstatus"
+	"jarvist/internal/wails/services/telemetry"
 	"jarvist/internal/wails/services/update"
+	"jarvist/internal/wails/services/usage"
 	"jarvist/pkg/logger"
 
 	"github.com/wailsapp/wails/v3/pkg/application"
@@ -40,6 +52,9 @@ var (
 	defaultLicenseKey  = "dev_test_license_key_not_for_production"
 	defaultLicenseSalt = "dev_test_salt_not_for_production"
 	buildMode          = "development"
+	updatePublicKey    = ""
+	// activationPublicKey verifies the offline license activation files issued by the vendor
+	activationPublicKey = ""
 )
 
 // createSPAHandler membuat handler HTTP untuk Single Page Application
@@ -110,21 +125,77 @@ func main() {
 		log.Fatal("Failed to run migrations: ", err)
 	}
 
+	// Mode local-only berlaku untuk semua HTTP client desktop sejak awal
+	commonresidency.Refresh(database.GetDB())
+
 	// ==========================================
 	// Inisialisasi Services
 	// ==========================================
 	deviceService := device.New()
+	authService := auth.New(database.GetDB(), appLogger.WithComponent("authservice"))
 	licenseService := licenseservice.New(appConfig, appLogger.WithComponent("licenseservice"), defaultLicenseKey, defaultLicenseSalt)
+	licenseService.SetDB(database.GetDB())
+	licenseService.SetActivationPublicKey(activationPublicKey)
 	settingService := setting.New(database.GetDB(), appConfig, appLogger.WithComponent("settingservice"), licenseService)
-	siteService := site.New(database.GetDB(), appConfig, appLogger.WithComponent("siteservice"))
+	siteService := site.New(database.GetDB(), appConfig, appLogger.WithComponent("siteservice"), settingService)
 	appService := applicationservice.New(nil)
-	locationService := location.New(database.GetDB())
+	locationService := location.New(database.GetDB(), appConfig)
 	updateService := update.New(appConfig)
 	processManagerService := processmanager.New(appConfig, appLogger.WithComponent("processmanagerservice"))
 	cameraService := camera.New(database.GetDB(), settingService, appConfig, appLogger.WithComponent("cameraservice"), processManagerService)
 	streamService := stream.New()
-	statsService := stats.New(appConfig)
+	statsService := stats.New(database.GetDB(), appConfig)
 	serviceManager := servicemanager.New(appConfig, appLogger)
+	supportService := support.New(appConfig, appLogger.WithComponent("supportservice"))
+	telemetryService := telemetry.New(database.GetDB(), appConfig, appLogger.WithComponent("telemetryservice"), settingService)
+	// Pemakaian fitur dan panic dari service lain dihitung oleh telemetry
+	usage.SetRecorder(telemetryService)
+
+	kioskService := kiosk.New(settingService, authService, appLogger.WithComponent("kioskservice"))
+	identityService := identity.New(database.GetDB(), appConfig, appLogger.WithComponent("identityservice"), settingService, cameraService)
+	configService := configservice.New(appConfig, appLogger.WithComponent("configservice"))
+	residencyService := residency.New(database.GetDB(), appLogger.WithComponent("residencyservice"), authService)
+	eventBufferService := eventbuffer.New(database.GetDB(), appLogger.WithComponent("eventbufferservice"))
+	syncStatusService := syncstatus.New(appConfig, appLogger.WithComponent("syncstatusservice"))
+
+	settingService.SetGuard(authService)
+	licenseService.SetGuard(authService)
+	settingService.SetProcessManager(processManagerService)
+	processManagerService.OnInstancesChanged(cameraService.ExportCameraConfig)
+	cameraService.SetGuard(authService)
+	identityService.SetGuard(authService)
+	residencyService.SetGuard(authService)
+	locationService.SetOnChange(siteService.SyncMetadataAsync)
+	configService.SetGuard(authService)
+	configService.SetComponentRestarter(serviceManager)
+	// Kiosk guard menolak kontrol service dan proses, lalu memeriksa PIN admin
+	serviceManager.SetGuard(kioskService)
+	processManagerService.SetGuard(kioskService)
+
+	processManagerService.SetEventBuffer(eventBufferService)
+	updateService.SetEventBuffer(eventBufferService)
+	identityService.SetEventBuffer(eventBufferService)
+	residencyService.SetEventBuffer(eventBufferService)
+
+	updateService.SetServiceController(servicemanager.NewController(serviceManager))
+	updateService.SetUpdatePublicKey(updatePublicKey)
+
+	// REST API lokal untuk otomasi lewat script, hanya aktif bila diaktifkan di config
+	if appConfig.DesktopApiEnabled {
+		desktopAPI := desktopapi.New(appConfig, appLogger.WithComponent("desktopapi"), desktopapi.Services{
+			Camera:       cameraService,
+			Settings:     settingService,
+			License:      licenseService,
+			Processes:    processManagerService,
+			Guard:        authService,
+			ProcessGuard: kioskService,
+		})
+		if err := desktopAPI.Start(); err != nil {
+			log.Printf("Warning: Failed to start desktop API: %v", err)
+		} else {
+			defer desktopAPI.Stop()
+		}
+	}
 
 	// ==========================================
 	// Inisialisasi Aplikasi Wails
@@ -134,12 +205,13 @@ func main() {
 		Description: "Jarvist Application",
 		Services: []application.Service{
 			application.NewService(appService),
+			application.NewService(authService),
 			application.NewService(buildInfoService),
 			application.NewService(deviceService),
 			application.NewService(licenseService),
 			application.NewService(settingService),
 			application.NewService(siteService),
-			application.NewService(configservice.New(appConfig, appLogger.WithComponent("configservice"))),
+			application.NewService(configService),
 			application.NewService(cameraService),
 			application.NewService(locationService),
 			application.NewService(updateService),
@@ -148,6 +220,13 @@ func main() {
 			application.NewService(statsService),
 			application.NewService(logmanager.New(appConfig)),
 			application.NewService(serviceManager),
+			application.NewService(telemetryService),
+			application.NewService(supportService),
+			application.NewService(kioskService),
+			application.NewService(identityService),
+			application.NewService(residencyService),
+			application.NewService(eventBufferService),
+			application.NewService(syncStatusService),
 		},
 		Assets: application.AssetOptions{
 			Handler: createSPAHandler(assets),
@@ -159,11 +238,17 @@ func main() {
 
 	// Set app ke service-service yang membutuhkan
 	appService.InitService(app)
+	eventBufferService.InitService(app)
+	authService.InitService(app)
+	kioskService.InitService(app)
+	identityService.InitService(app)
+	residencyService.InitService(app)
 	cameraService.InitService(app)
 	updateService.InitService(app)
 	processManagerService.InitService(app)
 	streamService.InitService(app)
 	statsService.InitService(app)
+	syncStatusService.InitService(app)
 
 	// ==========================================
 	// Setup Window
@@ -239,6 +324,24 @@ func main() {
 			time.Sleep(1 * time.Second)
 		}
 
+		go func() {
+			if !settingService.IsConfigured() {
+				return
+			}
+
+			compat := serviceManager.CheckCompatibility()
+			if compat.Compatible {
+				return
+			}
+
+			eventBufferService.Emit("service_compat_warning", compat)
+			if compat.UpdateRecommended {
+				if _, err := updateService.CheckForServiceUpdates(compat.ServiceVersion); err != nil {
+					log.Printf("Error checking service updates: %v", err)
+				}
+			}
+		}()
+
 		if buildMode == "production" {
 			if err := updateService.InstallPendingUpdates(); err != nil {
 				log.Printf("Error checking pending updates: %v\n", err)
@@ -262,10 +365,21 @@ func main() {
 	go func() {
 		time.Sleep(10 * time.Second)
 
-		if licenseService.IsLicensed() && settingService.IsConfigured() {
+		// Lisensi yang kedaluwarsa melewati masa tenggang tetap menghitung secara lokal,
+		// publikasi ke cloud dijeda dan UI menampilkan instruksi perpanjangan
+		degraded := licenseService.IsDegraded()
+
+		if (licenseService.IsLicensed() || degraded) && settingService.IsConfigured() {
 			// Sudah berlisensi dan terkonfigurasi - tampilkan window utama
 			mainWindow.Show()
 			splashWindow.Close()
+
+			kioskService.AttachWindow(mainWindow)
+			kioskService.Apply()
+
+			if degraded {
+				app.EmitEvent("license:degraded", licenseService.GetDegradation())
+			}
 		} else if licenseService.IsLicensed() && !settingService.IsConfigured() {
 			// Berlisensi tapi belum terkonfigurasi
 			splashWindow.Close()
@@ -278,6 +392,13 @@ func main() {
 	}()
 
 	if settingService.IsConfigured() {
+		// Update service yang terputus bisa meninggalkan service tanpa executable
+		if restored, err := updateService.RecoverServiceBinary(); err != nil {
+			app.Logger.Error("Failed to recover service binary: " + err.Error())
+		} else if restored {
+			app.Logger.Warn("Interrupted service update found, previous service binary restored")
+		}
+
 		result, err := serviceManager.CheckAndInstallService()
 		if err != nil {
 			app.Logger.Error("Failed to check/install service: " + err.Error())
@@ -325,5 +446,6 @@ func main() {
 	// ==========================================
 	cameraService.StopBackgroundChecking()
 	ffmpeg.KillAllProcesses()
+	bandwidth.Flush()
 	database.CloseDatabase()
 }
@@ -0,0 +1,183 @@
+package hardware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// inventoryTimeout limits the PowerShell query, CIM can hang on broken WMI repositories
+const inventoryTimeout = 30 * time.Second
+
+// Disk is a local fixed drive
+type Disk struct {
+	Name       string `json:"name"`
+	FileSystem string `json:"fileSystem,omitempty"`
+	SizeBytes  uint64 `json:"sizeBytes"`
+	FreeBytes  uint64 `json:"freeBytes"`
+}
+
+// NetworkInterface is a network adapter with a hardware address
+type NetworkInterface struct {
+	Name      string   `json:"name"`
+	MAC       string   `json:"mac"`
+	Addresses []string `json:"addresses,omitempty"`
+	Up        bool     `json:"up"`
+}
+
+// Inventory describes the hardware and OS of this machine
+type Inventory struct {
+	CPUModel          string             `json:"cpuModel"`
+	CPUCores          int                `json:"cpuCores"`
+	CPUThreads        int                `json:"cpuThreads"`
+	MemoryBytes       uint64             `json:"memoryBytes"`
+	Disks             []Disk             `json:"disks"`
+	GPUs              []string           `json:"gpus"`
+	NetworkInterfaces []NetworkInterface `json:"networkInterfaces"`
+	OSName            string             `json:"osName"`
+	OSVersion         string             `json:"osVersion"`
+	OSBuild           string             `json:"osBuild"`
+	CollectedAt       time.Time          `json:"collectedAt"`
+}
+
+// inventoryScript reads the hardware from CIM in one PowerShell call
+const inventoryScript = `$ErrorActionPreference = 'SilentlyContinue'
+$cs = Get-CimInstance Win32_ComputerSystem
+$os = Get-CimInstance Win32_OperatingSystem
+$nt = Get-ItemProperty 'HKLM:\SOFTWARE\Microsoft\Windows NT\CurrentVersion'
+[pscustomobject]@{
+  cpus = @(Get-CimInstance Win32_Processor | ForEach-Object { [pscustomobject]@{ name = "$($_.Name)".Trim(); cores = [int]$_.NumberOfCores; threads = [int]$_.NumberOfLogicalProcessors } })
+  memory = [uint64]$cs.TotalPhysicalMemory
+  osName = "$($os.Caption)"
+  osVersion = "$($os.Version)"
+  osBuild = "$($os.BuildNumber)"
+  ubr = "$($nt.UBR)"
+  gpus = @(Get-CimInstance Win32_VideoController | ForEach-Object { "$($_.Name)" })
+  disks = @(Get-CimInstance Win32_LogicalDisk -Filter 'DriveType=3' | ForEach-Object { [pscustomobject]@{ name = "$($_.DeviceID)"; fs = "$($_.FileSystem)"; size = [uint64]$_.Size; free = [uint64]$_.FreeSpace } })
+} | ConvertTo-Json -Depth 4 -Compress`
+
+type cimInventory struct {
+	CPUs []struct {
+		Name    string `json:"name"`
+		Cores   int    `json:"cores"`
+		Threads int    `json:"threads"`
+	} `json:"cpus"`
+	Memory    uint64   `json:"memory"`
+	OSName    string   `json:"osName"`
+	OSVersion string   `json:"osVersion"`
+	OSBuild   string   `json:"osBuild"`
+	UBR       string   `json:"ubr"`
+	GPUs      []string `json:"gpus"`
+	Disks     []struct {
+		Name string `json:"name"`
+		FS   string `json:"fs"`
+		Size uint64 `json:"size"`
+		Free uint64 `json:"free"`
+	} `json:"disks"`
+}
+
+// CollectInventory reads the hardware inventory. Fields that cannot be read stay empty,
+// the error is only returned when the CIM query failed completely.
+func CollectInventory(ctx context.Context) (Inventory, error) {
+	inventory := Inventory{
+		CPUThreads:        runtime.NumCPU(),
+		Disks:             []Disk{},
+		GPUs:              []string{},
+		NetworkInterfaces: networkInterfaces(),
+		CollectedAt:       time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, inventoryTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", inventoryScript)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow:    true,
+		CreationFlags: 0x08000000,
+	}
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return inventory, fmt.Errorf("failed to query hardware inventory: %w", err)
+	}
+
+	var cim cimInventory
+	if err := json.Unmarshal(out.Bytes(), &cim); err != nil {
+		return inventory, fmt.Errorf("failed to parse hardware inventory: %w", err)
+	}
+
+	// Mesin multi-socket dihitung sebagai total core dan thread
+	var cores, threads int
+	for _, cpu := range cim.CPUs {
+		if inventory.CPUModel == "" {
+			inventory.CPUModel = cpu.Name
+		}
+		cores += cpu.Cores
+		threads += cpu.Threads
+	}
+	inventory.CPUCores = cores
+	if threads > 0 {
+		inventory.CPUThreads = threads
+	}
+
+	inventory.MemoryBytes = cim.Memory
+	inventory.OSName = cim.OSName
+	inventory.OSVersion = cim.OSVersion
+	inventory.OSBuild = cim.OSBuild
+	if cim.OSBuild != "" && cim.UBR != "" {
+		inventory.OSBuild = cim.OSBuild + "." + cim.UBR
+	}
+
+	for _, gpu := range cim.GPUs {
+		if gpu = strings.TrimSpace(gpu); gpu != "" {
+			inventory.GPUs = append(inventory.GPUs, gpu)
+		}
+	}
+	for _, disk := range cim.Disks {
+		inventory.Disks = append(inventory.Disks, Disk{
+			Name:       disk.Name,
+			FileSystem: disk.FS,
+			SizeBytes:  disk.Size,
+			FreeBytes:  disk.Free,
+		})
+	}
+
+	return inventory, nil
+}
+
+// networkInterfaces returns the adapters with a hardware address, loopback is skipped
+func networkInterfaces() []NetworkInterface {
+	result := []NetworkInterface{}
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return result
+	}
+
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback != 0 || len(iface.HardwareAddr) == 0 {
+			continue
+		}
+
+		item := NetworkInterface{
+			Name: iface.Name,
+			MAC:  iface.HardwareAddr.String(),
+			Up:   iface.Flags&net.FlagUp != 0,
+		}
+		if addrs, err := iface.Addrs(); err == nil {
+			for _, addr := range addrs {
+				item.Addresses = append(item.Addresses, addr.String())
+			}
+		}
+		result = append(result, item)
+	}
+	return result
+}
@@ -0,0 +1,162 @@
+package logger
+
+import (
+	"fmt"
+	"jarvist/internal/common/models"
+	"os"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	dbPruneEvery    = 500             // Inserts between two size checks
+	dbPruneInterval = 5 * time.Minute // Longest time between two size checks
+	dbPruneChunk    = 1000            // Rows deleted per statement, keeps the write lock short
+	dbPruneTarget   = 0.9             // Prune down to this part of the limit so the next insert does not prune again
+)
+
+// dbPruneState tracks the size checks of the log table
+type dbPruneState struct {
+	mu         sync.Mutex
+	running    bool
+	inserts    int
+	lastCheck  time.Time
+	lastPrune  time.Time
+	prunedRows int64
+	rows       int64
+	bytes      int64
+}
+
+// maybePruneDatabase checks the size of the log table every dbPruneEvery inserts or
+// dbPruneInterval and deletes the oldest rows over the limits. It runs on the async writer,
+// so a slow prune never blocks logging.
+func (l *Logger) maybePruneDatabase(db *gorm.DB) {
+	if l.options.DbMaxRows <= 0 && l.options.DbMaxSizeMB <= 0 {
+		return
+	}
+
+	state := &l.dbPrune
+	state.mu.Lock()
+	state.inserts++
+	due := state.inserts >= dbPruneEvery || time.Since(state.lastCheck) >= dbPruneInterval
+	if !due || state.running {
+		state.mu.Unlock()
+		return
+	}
+	state.running = true
+	state.inserts = 0
+	state.lastCheck = time.Now()
+	state.mu.Unlock()
+
+	pruned, err := l.pruneDatabase(db)
+
+	state.mu.Lock()
+	state.running = false
+	if pruned > 0 {
+		state.lastPrune = time.Now()
+		state.prunedRows += pruned
+	}
+	state.mu.Unlock()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error pruning log table: %v\n", err)
+	}
+}
+
+// pruneDatabase deletes the oldest rows in chunks until the table is below its limits
+func (l *Logger) pruneDatabase(db *gorm.DB) (int64, error) {
+	rows, bytes, err := logTableSize(db)
+	if err != nil {
+		return 0, err
+	}
+	l.setTableSize(rows, bytes)
+
+	excess := int64(0)
+	if maxRows := int64(l.options.DbMaxRows); maxRows > 0 && rows > maxRows {
+		excess = rows - int64(float64(maxRows)*dbPruneTarget)
+	}
+	if maxBytes := int64(l.options.DbMaxSizeMB) * 1024 * 1024; maxBytes > 0 && bytes > maxBytes && rows > 0 {
+		// Baris dihapus dari yang terlama, jumlahnya diperkirakan dari ukuran rata-rata
+		rowBytes := bytes / rows
+		if rowBytes == 0 {
+			rowBytes = 1
+		}
+		if sizeExcess := (bytes - int64(float64(maxBytes)*dbPruneTarget)) / rowBytes; sizeExcess > excess {
+			excess = sizeExcess
+		}
+	}
+
+	var pruned int64
+	for pruned < excess {
+		chunk := min(int64(dbPruneChunk), excess-pruned)
+		oldest := db.Model(&models.LogEntry{}).Select("id").Order("id ASC").Limit(int(chunk))
+		result := db.Where("id IN (?)", oldest).Delete(&models.LogEntry{})
+		if result.Error != nil {
+			return pruned, result.Error
+		}
+		if result.RowsAffected == 0 {
+			break
+		}
+		pruned += result.RowsAffected
+	}
+
+	if pruned > 0 {
+		rows, bytes, err = logTableSize(db)
+		if err == nil {
+			l.setTableSize(rows, bytes)
+		}
+	}
+	return pruned, nil
+}
+
+func (l *Logger) setTableSize(rows, bytes int64) {
+	l.dbPrune.mu.Lock()
+	defer l.dbPrune.mu.Unlock()
+	l.dbPrune.rows = rows
+	l.dbPrune.bytes = bytes
+}
+
+// logTableSize returns the row count and the estimated size of the log table. The size
+// is the length of the text columns, SQLite has no cheap way to get the real table size.
+func logTableSize(db *gorm.DB) (int64, int64, error) {
+	var result struct {
+		Count int64
+		Bytes int64
+	}
+	err := db.Model(&models.LogEntry{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(LENGTH(message) + LENGTH(component) + LENGTH(level)), 0) AS bytes").
+		Scan(&result).Error
+	return result.Count, result.Bytes, err
+}
+
+// DatabaseTableStats returns the current size of the log table and its limits
+func (l *Logger) DatabaseTableStats() map[string]interface{} {
+	l.dbMu.Lock()
+	db := l.db
+	l.dbMu.Unlock()
+
+	stats := map[string]interface{}{
+		"table_max_rows":    l.options.DbMaxRows,
+		"table_max_size_mb": l.options.DbMaxSizeMB,
+	}
+
+	if db != nil {
+		if rows, bytes, err := logTableSize(db); err == nil {
+			l.setTableSize(rows, bytes)
+		}
+	}
+
+	state := &l.dbPrune
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	stats["table_rows"] = state.rows
+	stats["table_bytes"] = state.bytes
+	stats["pruned_rows"] = state.prunedRows
+	if !state.lastPrune.IsZero() {
+		stats["last_prune"] = state.lastPrune.Format(time.RFC3339)
+	}
+	return stats
+}
@@ -0,0 +1,252 @@
+package logger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EventCode identifies a structured operational log event. The backend localizes and
+// aggregates events by code and params, the rendered message stays as free-text fallback.
+type EventCode string
+
+// Catalogued events, codes must never be renamed once shipped
+const (
+	EventMQTTConnected            EventCode = "MQTT_CONNECTED"
+	EventMQTTDisconnected         EventCode = "MQTT_DISCONNECTED"
+	EventMQTTConnectFailed        EventCode = "MQTT_CONNECT_FAILED"
+	EventMQTTReconnectCooldown    EventCode = "MQTT_RECONNECT_COOLDOWN"
+	EventMQTTConnectionFlapping   EventCode = "MQTT_CONNECTION_FLAPPING"
+	EventSyncJournalRecovered     EventCode = "SYNC_JOURNAL_RECOVERED"
+	EventSyncDataLost             EventCode = "SYNC_DATA_LOST"
+	EventSyncFolderArchived       EventCode = "SYNC_FOLDER_ARCHIVED"
+	EventCleanupCompleted         EventCode = "CLEANUP_COMPLETED"
+	EventCleanupQuotaEnforced     EventCode = "CLEANUP_QUOTA_ENFORCED"
+	EventCleanupQuotaExceeded     EventCode = "CLEANUP_QUOTA_EXCEEDED"
+	EventIntegrityQuarantined     EventCode = "INTEGRITY_FILE_QUARANTINED"
+	EventPowerSuspended           EventCode = "POWER_SUSPENDED"
+	EventPowerResumed             EventCode = "POWER_RESUMED"
+	EventWatchdogUnhealthy        EventCode = "WATCHDOG_UNHEALTHY"
+	EventWatchdogRestart          EventCode = "WATCHDOG_RESTART"
+	EventJobFailed                EventCode = "JOB_FAILED"
+	EventAPIPortConflict          EventCode = "API_PORT_CONFLICT"
+	EventAPIPortFallback          EventCode = "API_PORT_FALLBACK"
+	EventMaintenanceStarted       EventCode = "MAINTENANCE_STARTED"
+	EventMaintenanceEnded         EventCode = "MAINTENANCE_ENDED"
+	EventDataGapDetected          EventCode = "DATA_GAP_DETECTED"
+	EventDataGapBackfill          EventCode = "DATA_GAP_BACKFILL"
+	EventLicenseDegraded          EventCode = "LICENSE_DEGRADED"
+	EventLicenseRestored          EventCode = "LICENSE_RESTORED"
+	EventSiteRegistered           EventCode = "SITE_REGISTERED"
+	EventSiteRegistrationRejected EventCode = "SITE_REGISTRATION_REJECTED"
+	EventStartupWaitTimeout       EventCode = "STARTUP_WAIT_TIMEOUT"
+	EventVisitorMilestone         EventCode = "VISITOR_MILESTONE"
+	EventLocalOnlyEnabled         EventCode = "LOCAL_ONLY_ENABLED"
+	EventLocalOnlyDisabled        EventCode = "LOCAL_ONLY_DISABLED"
+	EventUncleanShutdown          EventCode = "UNCLEAN_SHUTDOWN"
+)
+
+// EventDef describes a catalogued event. Template placeholders are written as {param}.
+type EventDef struct {
+	Code     EventCode `json:"code"`
+	Template string    `json:"template"`
+	Params   []string  `json:"params"`
+}
+
+var eventCatalog = map[EventCode]EventDef{
+	EventMQTTConnected: {
+		Template: "Connected to MQTT broker {broker}",
+		Params:   []string{"broker"},
+	},
+	EventMQTTDisconnected: {
+		Template: "Disconnected from MQTT broker: {error}, data is stored locally",
+		Params:   []string{"error"},
+	},
+	EventMQTTConnectFailed: {
+		Template: "Failed to connect to MQTT broker {broker} (attempt {attempt}): {error}",
+		Params:   []string{"broker", "attempt", "error"},
+	},
+	EventMQTTReconnectCooldown: {
+		Template: "Reconnect budget of {attempts} attempts per {window} used up, cooling down for {cooldown}",
+		Params:   []string{"attempts", "window", "cooldown"},
+	},
+	EventMQTTConnectionFlapping: {
+		Template: "Connection dropped {uptime} after connecting, {flaps} flaps in the last hour",
+		Params:   []string{"uptime", "flaps"},
+	},
+	EventSyncJournalRecovered: {
+		Template: "Journal recovery resolved {recovered} of {total} in-doubt files",
+		Params:   []string{"recovered", "total"},
+	},
+	EventSyncDataLost: {
+		Template: "{filename} is marked processed but its message and file are gone, data lost",
+		Params:   []string{"filename"},
+	},
+	EventSyncFolderArchived: {
+		Template: "Archived folder {folder}: compressed {files} files, saved {bytes} bytes",
+		Params:   []string{"folder", "files", "bytes"},
+	},
+	EventCleanupCompleted: {
+		Template: "Data cleanup completed in {duration}",
+		Params:   []string{"duration"},
+	},
+	EventCleanupQuotaEnforced: {
+		Template: "Storage quota for {category} exceeded ({used} of {limit} bytes), deleted {files} oldest files and reclaimed {reclaimed} bytes",
+		Params:   []string{"category", "used", "limit", "files", "reclaimed"},
+	},
+	EventCleanupQuotaExceeded: {
+		Template: "Storage quota for {category} still exceeded after cleanup: {used} of {limit} bytes, {protected} bytes cannot be deleted yet",
+		Params:   []string{"category", "used", "limit", "protected"},
+	},
+	EventIntegrityQuarantined: {
+		Template: "Quarantined {folder}/{filename}: {reason} ({detail})",
+		Params:   []string{"folder", "filename", "reason", "detail"},
+	},
+	EventPowerSuspended: {
+		Template: "System is going to sleep, background workers paused",
+		Params:   []string{},
+	},
+	EventPowerResumed: {
+		Template: "System resumed after sleeping {duration} ({from} to {to}, detected by {source}), no data was collected in this window",
+		Params:   []string{"from", "to", "duration", "source"},
+	},
+	EventWatchdogUnhealthy: {
+		Template: "Health check of {component} failed: {error}, the OS watchdog is no longer notified",
+		Params:   []string{"component", "error"},
+	},
+	EventWatchdogRestart: {
+		Template: "{component} unhealthy for {duration}, exiting so the service manager restarts the service",
+		Params:   []string{"component", "duration"},
+	},
+	EventJobFailed: {
+		Template: "Scheduled job {job} failed after {duration}: {error}",
+		Params:   []string{"job", "duration", "error"},
+	},
+	EventAPIPortConflict: {
+		Template: "API port {port} is in use by {owner}",
+		Params:   []string{"port", "owner"},
+	},
+	EventAPIPortFallback: {
+		Template: "API port {configured} is in use by {owner}, listening on fallback port {port}",
+		Params:   []string{"configured", "owner", "port"},
+	},
+	EventMaintenanceStarted: {
+		Template: "Maintenance mode started by {actor} via {source} until {until} ({reason}), publishing and alerts are paused",
+		Params:   []string{"actor", "source", "until", "reason"},
+	},
+	EventMaintenanceEnded: {
+		Template: "Maintenance mode ended ({ended_by}) after {duration}, from {from} to {to}",
+		Params:   []string{"from", "to", "duration", "ended_by"},
+	},
+	EventDataGapDetected: {
+		Template: "Camera {cctv_id} has no data from {from} to {to}, {missing} files missing at a save interval of {interval}",
+		Params:   []string{"cctv_id", "from", "to", "missing", "interval"},
+	},
+	EventDataGapBackfill: {
+		Template: "Requested the counter to re-export camera {cctv_id} from {from} to {to} (request {request})",
+		Params:   []string{"cctv_id", "from", "to", "request"},
+	},
+	EventLicenseDegraded: {
+		Template: "License {reason} since {expired_at}, cloud publishing paused and data kept locally until it is renewed",
+		Params:   []string{"reason", "expired_at"},
+	},
+	EventLicenseRestored: {
+		Template: "License restored after {duration} degraded, cloud publishing resumes",
+		Params:   []string{"duration"},
+	},
+	EventLocalOnlyEnabled: {
+		Template: "Local-only mode enabled by {actor}, data stays on the site and is not published",
+		Params:   []string{"actor"},
+	},
+	EventLocalOnlyDisabled: {
+		Template: "Local-only mode disabled by {actor}, cloud publishing resumes for new data",
+		Params:   []string{"actor"},
+	},
+	EventSiteRegistered: {
+		Template: "Site registration {request} accepted by the backend, data publishing starts",
+		Params:   []string{"request"},
+	},
+	EventSiteRegistrationRejected: {
+		Template: "Site registration rejected by the backend: {reason}, data is kept locally",
+		Params:   []string{"reason"},
+	},
+	EventStartupWaitTimeout: {
+		Template: "Started after waiting {waited} although {pending} was not ready",
+		Params:   []string{"pending", "waited"},
+	},
+	EventVisitorMilestone: {
+		Template: "Site passed {visitors} visitors since install ({total} counted)",
+		Params:   []string{"visitors", "total"},
+	},
+	EventUncleanShutdown: {
+		Template: "Started after an unclean shutdown, last alive {last_alive}, data affected: {data_affected}",
+		Params:   []string{"last_alive", "data_affected"},
+	},
+}
+
+// Catalog returns all catalogued events sorted by code
+func Catalog() []EventDef {
+	defs := make([]EventDef, 0, len(eventCatalog))
+	for code, def := range eventCatalog {
+		def.Code = code
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].Code < defs[j].Code
+	})
+	return defs
+}
+
+// logEvent is the structured part of a log line forwarded over MQTT
+type logEvent struct {
+	code   EventCode
+	params map[string]interface{}
+}
+
+// renderEvent fills the template of an event, unknown codes render as the code with params
+func renderEvent(code EventCode, params map[string]interface{}) string {
+	def, ok := eventCatalog[code]
+	if !ok {
+		keys := make([]string, 0, len(params))
+		for key := range params {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		parts := []string{string(code)}
+		for _, key := range keys {
+			parts = append(parts, fmt.Sprintf("%s=%v", key, params[key]))
+		}
+		return strings.Join(parts, " ")
+	}
+
+	message := def.Template
+	for key, value := range params {
+		message = strings.ReplaceAll(message, "{"+key+"}", fmt.Sprint(value))
+	}
+	return message
+}
+
+func eventParams(params []Field) map[string]interface{} {
+	values := make(map[string]interface{}, len(params))
+	for _, param := range params {
+		values[param.Key] = param.Value
+	}
+	return values
+}
+
+// Event logs a catalogued event. File and console get the rendered message, MQTT logs
+// additionally carry event_code and params.
+func (l *Logger) Event(level LogLevel, component string, code EventCode, params ...Field) {
+	l.logEvent(level, component, code, eventParams(params))
+}
+
+// Event logs a catalogued event with the component of the context logger
+func (cl *ContextLogger) Event(level LogLevel, code EventCode, params ...Field) {
+	cl.logger.logEvent(level, cl.component, code, eventParams(params))
+}
+
+func (l *Logger) logEvent(level LogLevel, component string, code EventCode, params map[string]interface{}) {
+	message := renderEvent(code, params)
+	l.output(level, component, &logEvent{code: code, params: params}, message)
+}
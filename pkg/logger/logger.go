@@ -74,25 +74,29 @@ func ParseLogLevel(level string) LogLevel {
 
 // LoggerOptions configures a Logger
 type LoggerOptions struct {
-	Level           LogLevel  // Minimum log level to display
-	EnableConsole   bool      // Whether to log to console
-	EnableFile      bool      // Whether to log to file
-	EnableDatabase  bool      // Whether to log to database
-	EnableMQTT      bool      // Whether to log to MQTT
-	LogDir          string    // Log directory path
-	LogFileName     string    // Log file name
-	TimeFormat      string    // Format for timestamp
-	IncludeLocation bool      // Whether to include file/line location
-	MaxSizeMB       int       // Maximum size of log file in MB before rotation
-	MaxBackups      int       // Maximum number of old log files to keep
-	MaxAgeDays      int       // Maximum age of old log files in days
-	OutputWriter    io.Writer // Custom output writer (optional)
-	DatePattern     string    // Date pattern for log file rotation
-	MQTTTopic       string    // MQTT topic for logs (if MQTT enabled)
-	MQTTMinLevel    LogLevel  // Minimum log level to send to MQTT
-	DbMinLevel      LogLevel  // Minimum log level to send to database
-	FileMinLevel    LogLevel  // Minimum log level to write to file
-	TableName       string    // Database table name for logs
+	Level           LogLevel     // Minimum log level to display
+	EnableConsole   bool         // Whether to log to console
+	EnableFile      bool         // Whether to log to file
+	EnableDatabase  bool         // Whether to log to database
+	EnableMQTT      bool         // Whether to log to MQTT
+	LogDir          string       // Log directory path
+	LogFileName     string       // Log file name
+	TimeFormat      string       // Format for timestamp
+	IncludeLocation bool         // Whether to include file/line location
+	MaxSizeMB       int          // Maximum size of log file in MB before rotation
+	MaxBackups      int          // Maximum number of old log files to keep
+	MaxAgeDays      int          // Maximum age of old log files in days
+	OutputWriter    io.Writer    // Custom output writer (optional)
+	DatePattern     string       // Date pattern for log file rotation
+	MQTTTopic       string       // MQTT topic for logs (if MQTT enabled)
+	MQTTMinLevel    LogLevel     // Minimum log level to send to MQTT
+	DbMinLevel      LogLevel     // Minimum log level to send to database
+	FileMinLevel    LogLevel     // Minimum log level to write to file
+	TableName       string       // Database table name for logs
+	RotationMode    RotationMode // How the active log file is rotated
+	CompressBackups bool         // Whether rotated log files are compressed with gzip
+	DbMaxRows       int          // Maximum number of rows in the log table, 0 for no limit
+	DbMaxSizeMB     int          // Maximum estimated size of the log table in MB, 0 for no limit
 }
 
 // DefaultOptions returns the default logger options
@@ -117,6 +121,10 @@ func DefaultOptions() *LoggerOptions {
 		DbMinLevel:      LevelWarn,     // Only send INFO and above to database by default
 		FileMinLevel:    LevelInfo,     // By default, file level is the same as global level
 		TableName:       "log_entries", // Default table name
+		RotationMode:    RotateCopyTruncate,
+		CompressBackups: true,
+		DbMaxRows:       200000,
+		DbMaxSizeMB:     100,
 	}
 }
 
@@ -133,6 +141,7 @@ type Logger struct {
 	dbMu          sync.Mutex
 	mqttMu        sync.Mutex
 	hostname      string // Cache hostname for MQTT logs
+	dbPrune       dbPruneState
 }
 
 // New creates a new Logger with the specified options
@@ -213,7 +222,7 @@ func (l *Logger) SetMQTTOptions(enabled bool, topic string, minLevel LogLevel) {
 	l.options.MQTTMinLevel = minLevel
 }
 
-func (l *Logger) logToMQTT(level LogLevel, component string, message string, fields map[string]interface{}) {
+func (l *Logger) logToMQTT(level LogLevel, component string, message string, fields map[string]interface{}, event *logEvent) {
 	if !l.options.EnableMQTT || level < l.options.MQTTMinLevel {
 		return
 	}
@@ -243,6 +252,11 @@ func (l *Logger) logToMQTT(level LogLevel, component string, message string, fie
 		logPayload["fields"] = fields
 	}
 
+	if event != nil {
+		logPayload["event_code"] = string(event.code)
+		logPayload["params"] = event.params
+	}
+
 	topic := l.options.MQTTTopic
 	if topic == "" {
 		topic = "logs"
@@ -291,12 +305,19 @@ func (l *Logger) logToDatabase(timestamp time.Time, level LogLevel, component st
 		if err := database.Create(&entry).Error; err != nil {
 			// If there's an error, log to stderr to avoid recursive logging
 			fmt.Fprintf(os.Stderr, "Error logging to database: %v\n", err)
+			return
 		}
+		l.maybePruneDatabase(database)
 	}(db, logEntry)
 }
 
 // log logs a message at the specified level
 func (l *Logger) log(level LogLevel, component string, message string, args ...interface{}) {
+	l.output(level, component, nil, message, args...)
+}
+
+// output writes a log line to all outputs, event is set for catalogued events
+func (l *Logger) output(level LogLevel, component string, event *logEvent, message string, args ...interface{}) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
@@ -327,7 +348,7 @@ func (l *Logger) log(level LogLevel, component string, message string, args ...i
 	// Get caller information if enabled
 	location := ""
 	if l.options.IncludeLocation {
-		location = getCallerInfo(3) // Adjust level to get the actual caller, not the logger method
+		location = getCallerInfo(4) // Skip output, log (or logEvent) and the logger method
 	}
 
 	// Format the log message
@@ -382,7 +403,7 @@ func (l *Logger) log(level LogLevel, component string, message string, args ...i
 
 	// Log to MQTT if enabled
 	if l.options.EnableMQTT {
-		l.logToMQTT(level, component, formattedMsg, fields)
+		l.logToMQTT(level, component, formattedMsg, fields, event)
 	}
 
 	// For fatal logs, terminate the application
@@ -451,6 +472,11 @@ func (l *Logger) GetLogStats() (map[string]interface{}, error) {
 		stats["newest_log"] = newestLog.Timestamp.Format(time.RFC3339)
 	}
 
+	// Table size against the limits enforced while writing
+	for key, value := range l.DatabaseTableStats() {
+		stats[key] = value
+	}
+
 	return stats, nil
 }
 
@@ -534,49 +560,34 @@ func (l *Logger) checkRotation() {
 	currentDateStr := now.Format(l.options.DatePattern)
 	lastDateStr := l.lastRotated.Format(l.options.DatePattern)
 
-	// If date has changed, rotate the log file
+	// If date has changed, continue in the log file of the new date
 	if currentDateStr != lastDateStr {
-		l.rotateLogFile()
+		l.reopenLogFile()
 		l.lastRotated = now
 	}
 }
 
-// rotateLogFile rotates log files, keeping up to MaxBackups old logs
+// rotateLogFile moves the active log file to a timestamped backup, keeping up to MaxBackups old logs
 func (l *Logger) rotateLogFile() {
 	if l.logFile == nil {
 		return
 	}
 
-	l.logFile.Close()
-	l.logFile = nil
+	logFilePath := l.logFile.Name()
 
-	// Remove from writers
-	for i, w := range l.writers {
-		if w == l.logFile {
-			l.writers = append(l.writers[:i], l.writers[i+1:]...)
-			break
-		}
-	}
+	backupPath := backupPathFor(logFilePath)
 
-	// Generate current log file name with date pattern
-	logFileName := l.options.LogFileName
-	if l.options.DatePattern != "" {
-		// Add date prefix to log file name
-		dateStr := time.Now().Format(l.options.DatePattern)
-		ext := filepath.Ext(logFileName)
-		baseName := strings.TrimSuffix(logFileName, ext)
-		logFileName = fmt.Sprintf("%s_%s%s", baseName, dateStr, ext)
+	var err error
+	if l.options.RotationMode == RotateCopyTruncate {
+		err = copyTruncate(logFilePath, backupPath)
+	} else {
+		l.closeLogFile()
+		err = os.Rename(logFilePath, backupPath)
 	}
-
-	logFilePath := filepath.Join(l.options.LogDir, logFileName)
-
-	// Generate a unique backup suffix based on timestamp
-	timestamp := time.Now().Format("20060102-150405")
-	backupPath := fmt.Sprintf("%s.%s", logFilePath, timestamp)
-
-	// Rename current log file if it exists
-	if _, err := os.Stat(logFilePath); err == nil {
-		os.Rename(logFilePath, backupPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error rotating log file %s: %v\n", logFilePath, err)
+	} else if l.options.CompressBackups {
+		go compressLogFile(backupPath)
 	}
 
 	// Reset file size
@@ -585,10 +596,39 @@ func (l *Logger) rotateLogFile() {
 	// Clean up old log files if we have too many
 	l.cleanupOldLogFiles()
 
-	// Setup new log file
+	// Setup new log file, copy-truncate keeps writing to the same file
+	if l.logFile == nil {
+		l.setupLogFile()
+	}
+}
+
+// reopenLogFile closes the log file and opens the file for the current date again
+func (l *Logger) reopenLogFile() {
+	if l.logFile == nil {
+		return
+	}
+
+	l.closeLogFile()
 	l.setupLogFile()
 }
 
+// closeLogFile removes the log file from the writers and closes it
+func (l *Logger) closeLogFile() {
+	if l.logFile == nil {
+		return
+	}
+
+	for i, w := range l.writers {
+		if w == l.logFile {
+			l.writers = append(l.writers[:i], l.writers[i+1:]...)
+			break
+		}
+	}
+
+	l.logFile.Close()
+	l.logFile = nil
+}
+
 // cleanupOldLogFiles removes old log files beyond MaxBackups or older than MaxAgeDays
 func (l *Logger) cleanupOldLogFiles() {
 	logFilePath := filepath.Join(l.options.LogDir, l.options.LogFileName)
@@ -720,18 +760,7 @@ func (l *Logger) UpdateLogPath(logDir string, logFileName string) error {
 	defer l.mu.Unlock()
 
 	// Close existing log file if any
-	if l.logFile != nil {
-		l.logFile.Close()
-		l.logFile = nil
-
-		// Remove logFile from writers
-		for i, w := range l.writers {
-			if w == l.logFile {
-				l.writers = append(l.writers[:i], l.writers[i+1:]...)
-				break
-			}
-		}
-	}
+	l.closeLogFile()
 
 	// Update paths
 	if logDir != "" {
@@ -776,15 +805,30 @@ func (l *Logger) ForceRotate() error {
 	return nil
 }
 
+// Reopen closes and reopens the log file, so an external tool that moved the file can
+// have the logger continue in a new one
+func (l *Logger) Reopen() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.options.EnableFile {
+		return fmt.Errorf("file logging is disabled")
+	}
+
+	l.closeLogFile()
+	l.setupLogFile()
+	if l.logFile == nil {
+		return fmt.Errorf("failed to reopen log file")
+	}
+	return nil
+}
+
 // Close closes the log file
 func (l *Logger) Close() {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	if l.logFile != nil {
-		l.logFile.Close()
-		l.logFile = nil
-	}
+	l.closeLogFile()
 }
 
 // Trace logs a message at the TRACE level
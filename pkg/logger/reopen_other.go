@@ -0,0 +1,37 @@
+//go:build !windows
+
+package logger
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ReopenOnSignal reopens the log file whenever the process receives SIGHUP, the way log
+// shippers ask for it after moving the file. The returned function stops listening.
+func (l *Logger) ReopenOnSignal() (stop func()) {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for {
+			select {
+			case <-signals:
+				if err := l.Reopen(); err != nil {
+					l.Error("logger", "Failed to reopen log file: %v", err)
+				} else {
+					l.Info("logger", "Log file reopened on SIGHUP")
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
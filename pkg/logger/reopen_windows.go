@@ -0,0 +1,7 @@
+package logger
+
+// ReopenOnSignal does nothing on Windows, which has no SIGHUP. Use Reopen, for example
+// through the sync service API, instead.
+func (l *Logger) ReopenOnSignal() (stop func()) {
+	return func() {}
+}
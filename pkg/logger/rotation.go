@@ -0,0 +1,112 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// RotationMode defines how the active log file is rotated
+type RotationMode int
+
+const (
+	// RotateCopyTruncate copies the log file to the backup and truncates it, the file stays
+	// open so other processes holding it keep working
+	RotateCopyTruncate RotationMode = iota
+	// RotateRename renames the log file to the backup and opens a new one
+	RotateRename
+)
+
+// backupPathFor returns a unique backup path based on the timestamp, a counter is added
+// when the file rotated more than once in the same second
+func backupPathFor(logFilePath string) string {
+	timestamp := time.Now().Format("20060102-150405")
+	backupPath := fmt.Sprintf("%s.%s", logFilePath, timestamp)
+
+	for i := 1; backupExists(backupPath); i++ {
+		backupPath = fmt.Sprintf("%s.%s-%d", logFilePath, timestamp, i)
+	}
+	return backupPath
+}
+
+func backupExists(path string) bool {
+	for _, candidate := range []string{path, path + ".gz"} {
+		if _, err := os.Stat(candidate); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// copyTruncate copies the log file to backupPath and empties it
+func copyTruncate(logFilePath, backupPath string) error {
+	src, err := os.Open(logFilePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(backupPath, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(backupPath)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(backupPath)
+		return err
+	}
+
+	// Truncate lewat path, handle O_APPEND di Windows tidak punya akses untuk SetEndOfFile
+	return os.Truncate(logFilePath, 0)
+}
+
+// compressLogFile compresses a rotated log file to path.gz and removes the original. The
+// modification time is kept so age based cleanup still works.
+func compressLogFile(path string) {
+	if err := gzipFile(path); err != nil {
+		fmt.Fprintf(os.Stderr, "Error compressing log file %s: %v\n", path, err)
+	}
+}
+
+func gzipFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	gzPath := path + ".gz"
+	dst, err := os.OpenFile(gzPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(gzPath)
+		return err
+	}
+
+	os.Chtimes(gzPath, info.ModTime(), info.ModTime())
+	src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package utils
+
+import "os"
+
+// replaceFile renames from over to, rename is atomic on POSIX file systems
+func replaceFile(from, to string) error {
+	return os.Rename(from, to)
+}
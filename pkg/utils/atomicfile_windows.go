@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	replaceAttempts = 10
+	replaceBackoff  = 100 * time.Millisecond
+)
+
+// replaceFile renames from over to with write-through. The replace fails while another
+// process (e.g. the python counter) has the target open without delete sharing, so it is retried.
+func replaceFile(from, to string) error {
+	// MoveFileEx does not get the automatic long path handling of the os package
+	fromPtr, err := windows.UTF16PtrFromString(LongPath(from))
+	if err != nil {
+		return err
+	}
+	toPtr, err := windows.UTF16PtrFromString(LongPath(to))
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = windows.MoveFileEx(fromPtr, toPtr, windows.MOVEFILE_REPLACE_EXISTING|windows.MOVEFILE_WRITE_THROUGH)
+		if err == nil || attempt == replaceAttempts {
+			return err
+		}
+		time.Sleep(replaceBackoff)
+	}
+}
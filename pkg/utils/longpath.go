@@ -0,0 +1,20 @@
+package utils
+
+import "strings"
+
+// longPathPrefix makes Windows APIs skip path normalization and the MAX_PATH limit
+const longPathPrefix = `\\?\`
+
+// StripLongPath removes the \\?\ prefix added by LongPath, for display and for storing paths
+func StripLongPath(path string) string {
+	if strings.HasPrefix(path, longPathPrefix+`UNC\`) {
+		return `\\` + path[len(longPathPrefix)+4:]
+	}
+	return strings.TrimPrefix(path, longPathPrefix)
+}
+
+// IsUNCPath reports whether path points to a network share (\\server\share\...)
+func IsUNCPath(path string) bool {
+	path = StripLongPath(path)
+	return strings.HasPrefix(path, `\\`) && !strings.HasPrefix(path, `\\.\`)
+}
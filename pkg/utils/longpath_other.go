@@ -0,0 +1,8 @@
+//go:build !windows
+
+package utils
+
+// LongPath is a no-op outside Windows, there is no MAX_PATH limit to work around
+func LongPath(path string) string {
+	return path
+}
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// LongPath returns an absolute path with the \\?\ prefix so it can exceed 260 characters.
+// The os package does this itself, raw Windows API calls and child processes need it
+// explicitly. Relative and device paths are returned unchanged.
+func LongPath(path string) string {
+	if strings.HasPrefix(path, longPathPrefix) || strings.HasPrefix(path, `\\.\`) || !filepath.IsAbs(path) {
+		return path
+	}
+
+	// \\?\ paths are passed to the file system as-is, so they must already be clean
+	path = filepath.Clean(path)
+	if strings.HasPrefix(path, `\\`) {
+		return longPathPrefix + `UNC\` + path[2:]
+	}
+	return longPathPrefix + path
+}